@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/chart"
+)
+
+const (
+	defaultActivityDays = 14
+	maxActivityDays     = 90
+	activityUsageMsg    = `Usage:
+/activity [days] - messages per day, 14 days by default
+/activity hours [days] - messages per hour of the day
+/activity ai [days] - AI answers per day`
+)
+
+// aiUsageColor tells the AI usage chart apart from the message charts
+var aiUsageColor = chart.DefaultColor
+
+func init() {
+	aiUsageColor.R, aiUsageColor.G, aiUsageColor.B = 0x8e, 0x44, 0xad
+}
+
+// dailyChart folds hourly counts into one bar per local day of the days ending with now
+func dailyChart(title string, hourly map[time.Time]int, now time.Time, days int, loc *time.Location) chart.Bar {
+	local := now.In(loc)
+	first := time.Date(local.Year(), local.Month(), local.Day()-days+1, 0, 0, 0, 0, loc)
+
+	bar := chart.Bar{Title: title, Labels: make([]string, days), Values: make([]float64, days)}
+	for i := range days {
+		bar.Labels[i] = first.AddDate(0, 0, i).Format("Jan 2")
+	}
+	for hour, count := range hourly {
+		h := hour.In(loc)
+		day := time.Date(h.Year(), h.Month(), h.Day(), 0, 0, 0, 0, loc)
+		// Round, since days across a DST change aren't 24 hours long
+		if i := int(day.Sub(first).Hours()/24 + 0.5); i >= 0 && i < days {
+			bar.Values[i] += float64(count)
+		}
+	}
+	return bar
+}
+
+// hourOfDayChart folds hourly counts into one bar per local hour of the day
+func hourOfDayChart(title string, hourly map[time.Time]int, loc *time.Location) chart.Bar {
+	bar := chart.Bar{Title: title, Labels: make([]string, 24), Values: make([]float64, 24)}
+	for i := range 24 {
+		bar.Labels[i] = strconv.Itoa(i)
+	}
+	for hour, count := range hourly {
+		bar.Values[hour.In(loc).Hour()] += float64(count)
+	}
+	return bar
+}
+
+// sendChart sends a chart as a photo in reply to msg, or to chatID when msg is nil
+func (bs *BotService) sendChart(chatID int64, msg *tgbotapi.Message, kind messageKind, bar chart.Bar, caption string) error {
+	data, err := bar.PNG()
+	if err != nil {
+		return err
+	}
+	return bs.sendPNG(chatID, msg, kind, "chart.png", data, caption)
+}
+
+// sendPNG sends a rendered image as a photo in reply to msg, or to chatID when msg is nil
+func (bs *BotService) sendPNG(chatID int64, msg *tgbotapi.Message, kind messageKind, name string, data []byte, caption string) error {
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	photo.Caption = caption
+	if msg != nil {
+		photo.ReplyToMessageID = msg.MessageID
+	}
+	silent, _ := bs.sendOptions(chatID, kind)
+	photo.DisableNotification = silent
+	_, err := bs.api.Send(photo)
+	return err
+}
+
+func (bs *BotService) handleActivity(msg *tgbotapi.Message) {
+	args := strings.Fields(strings.ToLower(msg.CommandArguments()))
+	mode := "days"
+	if len(args) > 0 && (args[0] == "hours" || args[0] == "ai") {
+		mode, args = args[0], args[1:]
+	}
+	days := defaultActivityDays
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > maxActivityDays || len(args) > 1 {
+			bs.Reply(msg, activityUsageMsg)
+			return
+		}
+		days = n
+	}
+
+	now := time.Now()
+	loc := bs.chatLocation(msg.Chat.ID)
+	from := now.AddDate(0, 0, -days)
+	var bar chart.Bar
+	var caption string
+
+	if mode == "ai" {
+		hourly, err := bs.store.AIResponsesHourly(msg.Chat.ID, from, now)
+		if err != nil {
+			log.Printf("Error counting AI responses of chat %d: %v", msg.Chat.ID, err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bar = dailyChart("AI answers per day", hourly, now, days, loc)
+		bar.Color = aiUsageColor
+		total := 0
+		for _, n := range hourly {
+			total += n
+		}
+		caption = fmt.Sprintf("🤖 %d AI answers in the last %d days (as far as /history keeps them)", total, days)
+	} else {
+		activity, err := bs.store.Activity(msg.Chat.ID, from, now, 1)
+		if err != nil {
+			log.Printf("Error loading activity of chat %d: %v", msg.Chat.ID, err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		if activity.Messages == 0 {
+			bs.Reply(msg, fmt.Sprintf("No messages in the last %d days.", days))
+			return
+		}
+		if mode == "hours" {
+			bar = hourOfDayChart("Messages per hour ("+loc.String()+")", activity.Hourly, loc)
+		} else {
+			bar = dailyChart("Messages per day", activity.Hourly, now, days, loc)
+		}
+		caption = fmt.Sprintf("📊 %d messages from %d people in the last %d days", activity.Messages, activity.Posters, days)
+	}
+
+	if err := bs.sendChart(msg.Chat.ID, msg, kindAnswer, bar, caption); err != nil {
+		log.Printf("failed to send activity chart: %v", err)
+		bs.Reply(msg, caption)
+	}
+}
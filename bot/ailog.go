@@ -0,0 +1,184 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	defaultHistoryEntries = 5
+	maxHistoryEntries     = 20
+	historyUsageMsg       = `Usage:
+/history [n] - show the last n AI responses in this chat (default 5)
+/history export - get every logged prompt and response as a JSON file
+/history retention <days|off> - how long responses are kept; off stops logging and deletes the log`
+)
+
+// origin is the chat message a model call was made for
+type origin struct {
+	chatID    int64
+	messageID int
+	userID    int64
+}
+
+type originKey struct{}
+
+// messageContext returns a context for model calls made on behalf of msg; generate logs their prompts
+// and responses to the chat's AI response log, and /cancel aborts them while msg is in flight
+func (bs *BotService) messageContext(msg *tgbotapi.Message) context.Context {
+	o := origin{chatID: msg.Chat.ID, messageID: msg.MessageID}
+	if msg.From != nil {
+		o.userID = msg.From.ID
+	}
+	parent := context.Background()
+	if r, ok := bs.inflightRequest(msg.Chat.ID, msg.MessageID); ok {
+		parent = r.ctx
+	}
+	ctx := context.WithValue(parent, originKey{}, o)
+	if bs.familyChat(msg.Chat.ID) {
+		ctx = llm.WithStrictSafety(ctx)
+	}
+	return ctx
+}
+
+// aiLogRetentionDays is how many days a chat's AI responses are kept, 0 when logging is off
+func (bs *BotService) aiLogRetentionDays(chatID int64) int {
+	if !bs.historyEnabled(chatID) {
+		return 0
+	}
+	if days := bs.store.ChatSettings(chatID).AILogRetentionDays; days != nil {
+		return *days
+	}
+	return bs.config().AILogRetentionDays
+}
+
+// logAIResponse records a model call made with messageContext, if the chat keeps an AI log
+func (bs *BotService) logAIResponse(ctx context.Context, prompt, response string, genErr error) {
+	o, ok := ctx.Value(originKey{}).(origin)
+	if !ok {
+		return
+	}
+	days := bs.aiLogRetentionDays(o.chatID)
+	if days <= 0 {
+		return
+	}
+
+	now := time.Now()
+	record := store.AIResponse{
+		ChatID:    o.chatID,
+		MessageID: o.messageID,
+		UserID:    o.userID,
+		Prompt:    prompt,
+		Response:  response,
+		CreatedAt: now,
+		ExpiresAt: now.AddDate(0, 0, days),
+	}
+	if genErr != nil {
+		record.Error = genErr.Error()
+	}
+	if err := bs.store.InsertAIResponse(record); err != nil {
+		log.Printf("Error logging AI response for chat %d: %v", o.chatID, err)
+	}
+}
+
+func (bs *BotService) handleHistory(msg *tgbotapi.Message) {
+	args := strings.Fields(strings.ToLower(msg.CommandArguments()))
+	switch {
+	case len(args) == 0:
+		bs.showHistory(msg, defaultHistoryEntries)
+	case args[0] == "export":
+		bs.exportHistory(msg)
+	case args[0] == "retention" && len(args) == 2:
+		bs.setHistoryRetention(msg, args[1])
+	default:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			bs.Reply(msg, historyUsageMsg)
+			return
+		}
+		bs.showHistory(msg, min(n, maxHistoryEntries))
+	}
+}
+
+func (bs *BotService) showHistory(msg *tgbotapi.Message, n int) {
+	responses, err := bs.store.AIResponses(msg.Chat.ID, n)
+	if err != nil {
+		log.Printf("Error loading AI responses for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(responses) == 0 {
+		status := "No AI responses logged in this chat."
+		if bs.aiLogRetentionDays(msg.Chat.ID) <= 0 {
+			status = "The AI response log is off in this chat."
+		}
+		bs.Reply(msg, status+"\n\n"+historyUsageMsg)
+		return
+	}
+
+	loc := bs.chatLocation(msg.Chat.ID)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🗂 Last %d AI responses (kept %d days):\n", len(responses), bs.aiLogRetentionDays(msg.Chat.ID)))
+	for _, r := range responses {
+		answer := r.Response
+		if r.Error != "" {
+			answer = "⚠️ error: " + r.Error
+		}
+		sb.WriteString(fmt.Sprintf("\n[%s] for message %d:\n%s\n", r.CreatedAt.In(loc).Format("2006-01-02 15:04"), r.MessageID, truncateRunes(answer, 300)))
+	}
+	bs.Reply(msg, sb.String())
+}
+
+func (bs *BotService) exportHistory(msg *tgbotapi.Message) {
+	responses, err := bs.store.AIResponses(msg.Chat.ID, 0)
+	if err != nil {
+		log.Printf("Error loading AI responses for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	data, err := json.MarshalIndent(responses, "", "  ")
+	if err != nil {
+		log.Printf("Error encoding AI responses: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	bs.sendFile(msg, fmt.Sprintf("ai-history-%d.json", msg.Chat.ID), data, fmt.Sprintf("🗂 %d logged AI responses", len(responses)))
+}
+
+func (bs *BotService) setHistoryRetention(msg *tgbotapi.Message, arg string) {
+	days := 0
+	if arg != "off" {
+		var err error
+		if days, err = strconv.Atoi(arg); err != nil || days <= 0 {
+			bs.Reply(msg, historyUsageMsg)
+			return
+		}
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"ai_log_retention_days": days}); err != nil {
+		log.Printf("Error saving AI log retention: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	// Existing records follow the new retention too
+	if err := bs.store.SetAIResponseRetention(msg.Chat.ID, days); err != nil {
+		log.Printf("Error applying AI log retention for chat %d: %v", msg.Chat.ID, err)
+	}
+
+	if days == 0 {
+		bs.Reply(msg, "🗂 AI response log turned off and cleared for this chat.")
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("🗂 AI responses in this chat are now kept for %d days.", days))
+}
@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Alias modes of a chat, stored in store.ChatSettings.AliasMode
+const (
+	// aliasStart answers messages that open with an alias, like "buddy, ..." or "hey ChatBuddy ..."; the default
+	aliasStart = ""
+	// aliasAnywhere answers messages that mention an alias anywhere as a word
+	aliasAnywhere = "anywhere"
+	// aliasOff only answers @mentions, replies and commands
+	aliasOff = "off"
+)
+
+const aliasUsageMsg = `Usage:
+/alias start - answer messages that start with my name, like "hey buddy, ..." (default)
+/alias anywhere - answer whenever my name is mentioned
+/alias off - only answer @mentions and replies
+
+` + nicknameUsageMsg
+
+// aliasMatcher finds the bot's alias names in message text
+type aliasMatcher struct {
+	start    *regexp.Regexp
+	anywhere *regexp.Regexp
+}
+
+// newAliasMatcher compiles the patterns for the given names; it returns nil without names.
+// Go's \b only knows ASCII, so word boundaries are spelled out to work with any script.
+func newAliasMatcher(names []string) *aliasMatcher {
+	var quoted []string
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			quoted = append(quoted, regexp.QuoteMeta(name))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+
+	alias := `(?:` + strings.Join(quoted, "|") + `)`
+	const boundaryAfter = `(?:$|[^\p{L}\p{N}_])`
+	return &aliasMatcher{
+		start:    regexp.MustCompile(`(?i)^\s*(?:(?:hey|hi|hello|ok|okay|yo|dear)[\s,]+)?` + alias + boundaryAfter),
+		anywhere: regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}_])` + alias + boundaryAfter),
+	}
+}
+
+// isAliasAddressed reports whether a message calls the bot by one of its alias names,
+// according to the chat's alias mode
+func (bs *BotService) isAliasAddressed(msg *tgbotapi.Message) bool {
+	aliases := bs.aliases.Load()
+	// The chat settings are only loaded for the few messages that contain an alias
+	if aliases == nil || msg.Text == "" || !aliases.anywhere.MatchString(msg.Text) {
+		return false
+	}
+
+	switch bs.store.ChatSettings(msg.Chat.ID).AliasMode {
+	case aliasOff:
+		return false
+	case aliasAnywhere:
+		return true
+	default:
+		return aliases.start.MatchString(msg.Text)
+	}
+}
+
+// stripAlias removes a leading greeting and alias, so "hey buddy, what's up?" becomes "what's up?"
+func (bs *BotService) stripAlias(text string) string {
+	aliases := bs.aliases.Load()
+	if aliases == nil {
+		return text
+	}
+	if loc := aliases.start.FindStringIndex(text); loc != nil {
+		return strings.TrimSpace(text[loc[1]:])
+	}
+	return text
+}
+
+func (bs *BotService) handleAliasCommand(msg *tgbotapi.Message) {
+	args := strings.TrimSpace(msg.CommandArguments())
+	if fields := strings.Fields(args); len(fields) > 0 && (strings.HasPrefix(args, "@") || strings.EqualFold(fields[0], "names") || strings.EqualFold(fields[0], "forget")) {
+		bs.handleNicknameCommand(msg, args)
+		return
+	}
+	mode := strings.ToLower(args)
+	var reply string
+	switch mode {
+	case "start":
+		mode, reply = aliasStart, "I'll answer messages that start with my name."
+	case aliasAnywhere:
+		reply = "I'll answer whenever my name is mentioned."
+	case aliasOff:
+		reply = "I'll only answer @mentions and replies."
+	default:
+		bs.Reply(msg, aliasUsageMsg)
+		return
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"alias_mode": mode}); err != nil {
+		log.Printf("Error saving alias mode for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, reply)
+}
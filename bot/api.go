@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// apiMessage is the JSON representation of a stored message
+type apiMessage struct {
+	ChatID    int64     `json:"chat_id"`
+	MessageID int       `json:"message_id"`
+	From      string    `json:"from"`
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (bs *BotService) registerAPIRoutes(mux *http.ServeMux) {
+	if bs.config().APIToken == "" {
+		return
+	}
+	mux.Handle("GET /api/chats/{id}/summary", bs.requireAPIToken(http.HandlerFunc(bs.handleAPISummary)))
+	mux.Handle("GET /api/chats/{id}/search", bs.requireAPIToken(http.HandlerFunc(bs.handleAPISearch)))
+}
+
+// requireAPIToken only lets through requests carrying "Authorization: Bearer <API_TOKEN>"
+func (bs *BotService) requireAPIToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(bs.config().APIToken)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, "invalid or missing API token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleAPISummary returns a summary of the latest messages, or of a day with ?range=today|yesterday|this week|last week
+func (bs *BotService) handleAPISummary(w http.ResponseWriter, r *http.Request) {
+	chatID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid chat id")
+		return
+	}
+
+	var messages []string
+	if expr := r.URL.Query().Get("range"); expr != "" {
+		from, to, ok := parseDayRange(expr, time.Now().In(bs.chatLocation(chatID)))
+		if !ok {
+			writeJSONError(w, http.StatusBadRequest, "range must be today, yesterday, this week or last week")
+			return
+		}
+		messages, err = bs.fetchMessagesInRangeFromDB(chatID, from, to, maxRangeMessagesToFetch)
+	} else {
+		messages, err = bs.fetchMessagesFromDB(chatID, maxMessagesToFetch)
+	}
+	if err != nil {
+		log.Printf("API summary fetch error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch messages")
+		return
+	}
+	if len(messages) == 0 {
+		writeJSON(w, http.StatusOK, map[string]any{"chat_id": chatID, "message_count": 0, "summary": ""})
+		return
+	}
+
+	summary, err := bs.generateSummary(r.Context(), messages, "")
+	if err != nil {
+		log.Printf("API summary generation error: %v", err)
+		writeJSONError(w, http.StatusBadGateway, "failed to generate summary")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"chat_id":       chatID,
+		"message_count": len(messages),
+		"summary":       summary,
+	})
+}
+
+// handleAPISearch runs a full-text search over a chat's stored messages
+func (bs *BotService) handleAPISearch(w http.ResponseWriter, r *http.Request) {
+	chatID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid chat id")
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeJSONError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	limit := defaultSearchLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxSearchLimit)
+	}
+
+	var messages []store.Message
+	if r.URL.Query().Get("semantic") == "true" {
+		messages, err = bs.searchMessages(r.Context(), chatID, query, limit)
+	} else {
+		messages, err = bs.store.SearchMessages(chatID, query, limit)
+	}
+	if err != nil {
+		log.Printf("API search error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "search failed")
+		return
+	}
+
+	results := make([]apiMessage, 0, len(messages))
+	for _, m := range messages {
+		results = append(results, newAPIMessage(m))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"chat_id": chatID, "query": query, "results": results})
+}
+
+func newAPIMessage(m store.Message) apiMessage {
+	return apiMessage{
+		ChatID:    m.ChatID,
+		MessageID: m.MessageID,
+		From:      m.Author(),
+		Text:      m.Text,
+		Timestamp: m.Timestamp,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write JSON response: %v", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
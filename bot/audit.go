@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+const (
+	defaultAuditEntries = 10
+	maxAuditEntries     = 50
+	// auditBotActor is the actor of actions the bot takes on its own, like muting after link warnings
+	auditBotActor = "ChatBuddy"
+)
+
+// audit records an admin or configuration action in a chat's audit log; a nil user is the bot itself
+func (bs *BotService) audit(chatID int64, user *tgbotapi.User, action, details string) {
+	entry := store.AuditEntry{ChatID: chatID, Actor: auditBotActor, Action: action, Details: truncateRunes(details, 500), CreatedAt: time.Now()}
+	if user != nil {
+		entry.ActorID = user.ID
+		entry.Actor = displayName(user)
+	}
+	bs.saveAuditEntry(entry)
+}
+
+func (bs *BotService) saveAuditEntry(entry store.AuditEntry) {
+	if err := bs.store.InsertAuditEntry(entry); err != nil {
+		log.Printf("Error saving audit entry for chat %d: %v", entry.ChatID, err)
+	}
+}
+
+func (bs *BotService) handleAudit(msg *tgbotapi.Message) {
+	n := defaultAuditEntries
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		var err error
+		if n, err = strconv.Atoi(arg); err != nil || n <= 0 {
+			bs.Reply(msg, "Usage: /audit [n] - show the last n admin actions in this chat")
+			return
+		}
+		n = min(n, maxAuditEntries)
+	}
+
+	entries, err := bs.store.AuditEntries(msg.Chat.ID, n)
+	if err != nil {
+		log.Printf("Error loading audit log for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(entries) == 0 {
+		bs.Reply(msg, "No admin actions recorded in this chat yet.")
+		return
+	}
+
+	loc := bs.chatLocation(msg.Chat.ID)
+	var sb strings.Builder
+	sb.WriteString("🧾 Recent admin actions:\n")
+	for _, e := range entries {
+		sb.WriteString(fmt.Sprintf("\n[%s] %s: %s", e.CreatedAt.In(loc).Format("2006-01-02 15:04"), e.Actor, e.Action))
+		if e.Details != "" {
+			sb.WriteString(" " + e.Details)
+		}
+	}
+	bs.Reply(msg, sb.String())
+}
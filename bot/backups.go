@@ -0,0 +1,228 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/objectstore"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	backupPrefix       = "backups/"
+	noBackupStorageMsg = "No backup storage is configured. Set BACKUP_DIR or the S3_* variables."
+)
+
+// backupRunning keeps scheduled and manual backups from overlapping
+var backupRunning atomic.Bool
+
+// objectStore returns the configured storage for backups: an S3-compatible bucket if S3_BUCKET is set,
+// else BACKUP_DIR on disk, else nil
+func (cfg *Config) objectStore() objectstore.Store {
+	switch {
+	case cfg.S3.Bucket != "":
+		s3 := cfg.S3
+		return &s3
+	case cfg.BackupDir != "":
+		return objectstore.Disk{Dir: cfg.BackupDir}
+	default:
+		return nil
+	}
+}
+
+// backupDatabase dumps the database to a new backup object and deletes the oldest backups beyond keep
+func backupDatabase(ctx context.Context, db *store.Store, objects objectstore.Store, keep int) (string, map[string]int, error) {
+	// Dump to a temporary file first, so the upload knows its size and Mongo isn't read at upload speed
+	tmp, err := os.CreateTemp("", "chatbuddy-backup-*")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	counts, err := db.Backup(ctx, tmp)
+	if err != nil {
+		return "", counts, err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", counts, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", counts, err
+	}
+
+	key := backupPrefix + "chatbuddy-" + time.Now().UTC().Format("20060102-150405") + ".jsonl.gz"
+	if err := objects.Put(ctx, key, tmp, size); err != nil {
+		return "", counts, fmt.Errorf("uploading %s: %w", key, err)
+	}
+
+	if keep > 0 {
+		keys, err := objects.List(ctx, backupPrefix)
+		if err != nil {
+			log.Printf("Error listing backups: %v", err)
+		}
+		for len(keys) > keep {
+			if err := objects.Delete(ctx, keys[0]); err != nil {
+				log.Printf("Error deleting old backup %s: %v", keys[0], err)
+			}
+			keys = keys[1:]
+		}
+	}
+	return key, counts, nil
+}
+
+// restoreDatabase loads a backup from a file path or a key of the object store
+func restoreDatabase(ctx context.Context, db *store.Store, objects objectstore.Store, source string, overwrite bool) (map[string]int, []string, error) {
+	var r io.ReadCloser
+	f, err := os.Open(source)
+	switch {
+	case err == nil:
+		r = f
+	case objects == nil:
+		return nil, nil, err
+	default:
+		if r, err = objects.Get(ctx, source); err != nil {
+			return nil, nil, fmt.Errorf("loading backup %s: %w", source, err)
+		}
+	}
+	defer r.Close()
+	return db.Restore(ctx, r, overwrite)
+}
+
+func formatBackupCounts(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	total := 0
+	for name, n := range counts {
+		names = append(names, fmt.Sprintf("%s %d", name, n))
+		total += n
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("%d documents (%s)", total, strings.Join(names, ", "))
+}
+
+// runBackup makes a backup now, unless one is already running
+func (bs *BotService) runBackup() (string, map[string]int, error) {
+	objects := bs.config().objectStore()
+	if objects == nil {
+		return "", nil, errors.New(noBackupStorageMsg)
+	}
+	if !backupRunning.CompareAndSwap(false, true) {
+		return "", nil, errors.New("a backup is already running")
+	}
+	defer backupRunning.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	// With Redis, other instances also wait for this backup instead of starting their own
+	if bs.cache != nil {
+		unlock, ok, err := bs.cache.Lock(ctx, "lock:backup", time.Hour)
+		switch {
+		case err != nil:
+			log.Printf("Error locking backup in Redis, backing up anyway: %v", err)
+		case !ok:
+			return "", nil, errors.New("another instance is making a backup")
+		default:
+			defer unlock()
+		}
+	}
+
+	key, counts, err := backupDatabase(ctx, bs.store, objects, bs.config().BackupKeep)
+	if err != nil {
+		return "", counts, err
+	}
+	bs.saveLastBackup(time.Now())
+	log.Printf("Backup %s written: %s", key, formatBackupCounts(counts))
+	return key, counts, nil
+}
+
+// runScheduledBackups starts a backup every BACKUP_INTERVAL_HOURS, counting from the last one even across restarts
+func (bs *BotService) runScheduledBackups(now time.Time) {
+	interval := time.Duration(bs.config().BackupIntervalHours) * time.Hour
+	if interval <= 0 || backupRunning.Load() || bs.config().objectStore() == nil || now.Sub(bs.lastBackup()) < interval {
+		return
+	}
+	bs.goSafe("scheduled backup", func() {
+		if _, _, err := bs.runBackup(); err != nil {
+			log.Printf("Error making scheduled backup: %v", err)
+		}
+	})
+}
+
+func (bs *BotService) lastBackup() time.Time {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var state struct {
+		LastAt time.Time `bson:"last_at"`
+	}
+	err := bs.db.Collection("bot_state").FindOne(ctx, bson.M{"_id": "backup"}).Decode(&state)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		log.Printf("Error loading backup state: %v", err)
+		// Pretend a backup just ran, so a database problem doesn't start one every minute
+		return time.Now()
+	}
+	return state.LastAt
+}
+
+func (bs *BotService) saveLastBackup(at time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := bs.db.Collection("bot_state").UpdateOne(ctx,
+		bson.M{"_id": "backup"},
+		bson.M{"$set": bson.M{"last_at": at}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("Error saving backup state: %v", err)
+	}
+}
+
+func (bs *BotService) handleBackup(msg *tgbotapi.Message) {
+	objects := bs.config().objectStore()
+	if objects == nil {
+		bs.Reply(msg, noBackupStorageMsg)
+		return
+	}
+
+	if strings.TrimSpace(msg.CommandArguments()) == "list" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		keys, err := objects.List(ctx, backupPrefix)
+		if err != nil {
+			log.Printf("Error listing backups: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		if len(keys) == 0 {
+			bs.Reply(msg, "No backups yet. Run /backup to make one.")
+			return
+		}
+		bs.Reply(msg, "💾 Backups, oldest first:\n"+strings.Join(keys, "\n")+"\n\nRestore one on a fresh deployment with: chatbuddy restore <key>")
+		return
+	}
+
+	bs.Reply(msg, "💾 Backing up the database, this may take a while...")
+	key, counts, err := bs.runBackup()
+	if err != nil {
+		log.Printf("Error making backup: %v", err)
+		bs.Reply(msg, "Backup failed: "+err.Error())
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("💾 Backup saved as %s: %s", key, formatBackupCounts(counts)))
+}
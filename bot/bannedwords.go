@@ -0,0 +1,222 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	bannedWordWarnAction = "warn"
+	banWordUsageMsg      = `Usage:
+/banword <word or phrase>[, <another>] - ban words in this chat
+/banword action delete|warn - delete matching messages (default) or only warn
+/unbanword <word or phrase> - allow it again
+/banword alone lists the banned words. Admins' messages are never filtered.`
+)
+
+// leetspeak maps look-alike characters to the letters they stand for
+var leetspeak = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '9': 'g', '@': 'a', '$': 's',
+}
+
+// leetPunctuation are look-alikes that are also punctuation, so "sh!t" differs from "shit!"
+var leetPunctuation = map[rune]rune{'!': 'i', '|': 'l', '+': 't'}
+
+// normalizeForFilter lowercases text, undoes leetspeak and drops punctuation inside words, so "Sh1t" and
+// "s.h.i.t" both become "shit"; words stay single-space-separated. With punctuation set, leetPunctuation is undone too.
+func normalizeForFilter(text string, punctuation bool) string {
+	var sb strings.Builder
+	space := true
+	for _, r := range strings.ToLower(text) {
+		if letter, ok := leetspeak[r]; ok {
+			r = letter
+		} else if letter, ok := leetPunctuation[r]; ok && punctuation {
+			r = letter
+		}
+		switch {
+		case unicode.IsLetter(r):
+			sb.WriteRune(r)
+			space = false
+		case unicode.IsSpace(r) && !space:
+			sb.WriteRune(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// bannedWordPatterns caches the compiled pattern of each banned word
+var bannedWordPatterns sync.Map
+
+// bannedWordPattern matches a normalized banned word as whole words, allowing stretched letters like "shiiit"
+func bannedWordPattern(word string) *regexp.Regexp {
+	if pattern, ok := bannedWordPatterns.Load(word); ok {
+		return pattern.(*regexp.Regexp)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`(?:^| )`)
+	runes := []rune(normalizeForFilter(word, false))
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && runes[j] == runes[i] {
+			j++
+		}
+		fmt.Fprintf(&sb, "%s{%d,}", regexp.QuoteMeta(string(runes[i])), j-i)
+		i = j
+	}
+	sb.WriteString(`(?: |$)`)
+
+	pattern := regexp.MustCompile(sb.String())
+	bannedWordPatterns.Store(word, pattern)
+	return pattern
+}
+
+// findBannedWord returns the first banned word or phrase that appears as whole words in text,
+// reading punctuation both as punctuation and as leetspeak
+func findBannedWord(text string, banned []string) string {
+	plain := normalizeForFilter(text, false)
+	leet := normalizeForFilter(text, true)
+	for _, word := range banned {
+		if normalizeForFilter(word, false) == "" {
+			continue
+		}
+		if pattern := bannedWordPattern(word); pattern.MatchString(plain) || pattern.MatchString(leet) {
+			return word
+		}
+	}
+	return ""
+}
+
+// bannedWords deletes or warns about messages with a banned word; deleted messages go no further
+func (bs *BotService) bannedWords(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		msg := update.Message
+		if msg == nil || msg.From == nil || msg.Chat.IsPrivate() || (msg.Text == "" && msg.Caption == "") {
+			next(update)
+			return
+		}
+
+		settings := bs.store.ChatSettings(msg.Chat.ID)
+		word := ""
+		if len(settings.BannedWords) > 0 {
+			word = findBannedWord(msg.Text+"\n"+msg.Caption, settings.BannedWords)
+		}
+		if word == "" || bs.isChatAdmin(msg.Chat.ID, msg.From.ID) {
+			next(update)
+			return
+		}
+
+		if bs.enforceBannedWord(msg, word, settings) {
+			return
+		}
+		next(update)
+	}
+}
+
+// enforceBannedWord deletes the message or warns its sender, and reports deleted messages to admins when
+// moderation mode is on; it returns whether the message was deleted
+func (bs *BotService) enforceBannedWord(msg *tgbotapi.Message, word string, settings store.ChatSettings) bool {
+	deleted := false
+	if settings.BannedWordAction != bannedWordWarnAction {
+		if _, err := bs.api.Request(tgbotapi.NewDeleteMessage(msg.Chat.ID, msg.MessageID)); err != nil {
+			// Without the delete permission the sender is warned instead
+			log.Printf("failed to delete message %d in chat %d: %v", msg.MessageID, msg.Chat.ID, err)
+		} else {
+			deleted = true
+		}
+	}
+
+	if deleted {
+		bs.sendResponse(tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("🚫 Removed a message from %s with a banned word.", displayName(msg.From))))
+	} else {
+		bs.Reply(msg, fmt.Sprintf("⚠️ %s, please watch your language, that word isn't allowed here.", displayName(msg.From)))
+	}
+
+	if settings.ModerationEnabled {
+		outcome := "the sender was warned"
+		if deleted {
+			outcome = "the message was deleted"
+		}
+		bs.alertAdmins(msg, "Banned word list", fmt.Sprintf("contains %q, %s", word, outcome))
+	}
+	return deleted
+}
+
+func (bs *BotService) handleBanWord(msg *tgbotapi.Message) {
+	args := strings.TrimSpace(msg.CommandArguments())
+	sub, rest, _ := strings.Cut(args, " ")
+
+	switch {
+	case args == "":
+		settings := bs.store.ChatSettings(msg.Chat.ID)
+		if len(settings.BannedWords) == 0 {
+			bs.Reply(msg, "No banned words in this chat.\n\n"+banWordUsageMsg)
+			return
+		}
+		action := "deleted"
+		if settings.BannedWordAction == bannedWordWarnAction {
+			action = "warned about"
+		}
+		bs.Reply(msg, fmt.Sprintf("Banned words (messages are %s):\n%s", action, strings.Join(settings.BannedWords, ", ")))
+		return
+	case strings.EqualFold(sub, "action"):
+		var action string
+		switch strings.ToLower(strings.TrimSpace(rest)) {
+		case "delete":
+		case bannedWordWarnAction:
+			action = bannedWordWarnAction
+		default:
+			bs.Reply(msg, banWordUsageMsg)
+			return
+		}
+		if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"banned_word_action": action}); err != nil {
+			log.Printf("Error saving banned word action for chat %d: %v", msg.Chat.ID, err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		if action == bannedWordWarnAction {
+			bs.Reply(msg, "I'll warn about messages with banned words.")
+		} else {
+			bs.Reply(msg, "I'll delete messages with banned words.")
+		}
+		return
+	}
+
+	words := splitWordList(args)
+	if err := bs.store.AddToChatList(msg.Chat.ID, "banned_words", words...); err != nil {
+		log.Printf("Error saving banned words for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("Banned %d word(s) ✅", len(words)))
+}
+
+func (bs *BotService) handleUnbanWord(msg *tgbotapi.Message) {
+	words := splitWordList(msg.CommandArguments())
+	if err := bs.store.RemoveFromChatList(msg.Chat.ID, "banned_words", words...); err != nil {
+		log.Printf("Error removing banned words for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, "Removed from the banned words ✅")
+}
+
+// splitWordList splits a comma-separated list of words or phrases, lowercased
+func splitWordList(list string) []string {
+	var words []string
+	for _, word := range strings.Split(list, ",") {
+		if word = strings.ToLower(strings.Join(strings.Fields(word), " ")); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
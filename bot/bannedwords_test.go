@@ -0,0 +1,64 @@
+package bot
+
+import "testing"
+
+func TestNormalizeForFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		punctuation bool
+		want        string
+	}{
+		{name: "lowercase", text: "Hello World", want: "hello world"},
+		{name: "digits", text: "Sh1t", want: "shit"},
+		{name: "symbols", text: "@$$", want: "ass"},
+		{name: "all digit substitutions", text: "0134578 9", want: "oieastb g"},
+		{name: "dotted letters", text: "s.h.i.t", want: "shit"},
+		{name: "collapsed spaces", text: "  bad \n\t word  ", want: "bad word"},
+		{name: "exclamation as punctuation", text: "sh!t", want: "sht"},
+		{name: "exclamation as leetspeak", text: "sh!t", punctuation: true, want: "shit"},
+		{name: "pipe and plus as leetspeak", text: "|o+", punctuation: true, want: "lot"},
+		{name: "non-latin letters", text: "Привет, мир", want: "привет мир"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeForFilter(tt.text, tt.punctuation); got != tt.want {
+				t.Errorf("normalizeForFilter(%q, %t) = %q, want %q", tt.text, tt.punctuation, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindBannedWord(t *testing.T) {
+	banned := []string{"shit", "ass", "hell", "bad word"}
+	tests := []struct {
+		name string
+		text string
+		// want is the banned word found; empty means none
+		want string
+	}{
+		{name: "plain", text: "oh shit", want: "shit"},
+		{name: "uppercase", text: "OH SHIT", want: "shit"},
+		{name: "digit", text: "what the h3ll", want: "hell"},
+		{name: "symbols", text: "you @$$", want: "ass"},
+		{name: "dotted", text: "s.h.i.t happens", want: "shit"},
+		{name: "exclamation inside", text: "sh!t", want: "shit"},
+		{name: "exclamation after", text: "shit!", want: "shit"},
+		{name: "stretched letters", text: "shiiiiit", want: "shit"},
+		{name: "phrase with leetspeak", text: "that's a b4d   w0rd", want: "bad word"},
+
+		{name: "inside a longer word", text: "a classic assassin in the grass"},
+		{name: "prefix of a word", text: "hello there"},
+		{name: "mushroom", text: "shiitake soup"},
+		{name: "half a phrase", text: "bad weather"},
+		{name: "price", text: "coffee is $5"},
+		{name: "empty", text: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findBannedWord(tt.text, banned); got != tt.want {
+				t.Errorf("findBannedWord(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
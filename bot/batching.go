@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// questionBatchWindow and maxQuestionFragments bound how many of the asker's preceding messages are joined
+	// with a mention, for questions typed as several messages with the mention last
+	questionBatchWindow  = 2 * time.Minute
+	maxQuestionFragments = 5
+)
+
+// questionFragments returns the messages the sender of a group mention posted right before it, oldest first:
+// consecutive, within questionBatchWindow, and not themselves addressed to the bot, which answered those already
+func (bs *BotService) questionFragments(msg *tgbotapi.Message) []string {
+	if msg.From == nil || msg.Chat.IsPrivate() || !bs.historyEnabled(msg.Chat.ID) || bs.isReplyToBot(msg) {
+		return nil
+	}
+
+	recent, err := bs.store.FindMessages(bson.M{
+		"chat_id":    msg.Chat.ID,
+		"message_id": bson.M{"$lt": msg.MessageID},
+		"timestamp":  bson.M{"$gte": msg.Time().Add(-questionBatchWindow)},
+	}, maxQuestionFragments)
+	if err != nil {
+		return nil
+	}
+
+	var fragments []string
+	for _, m := range recent { // newest first
+		if m.FromID != msg.From.ID || strings.HasPrefix(m.Text, "/") || bs.isBotMentioned(m.Text) || bs.stripAlias(m.Text) != m.Text {
+			break
+		}
+		fragments = append(fragments, m.Text)
+	}
+	slices.Reverse(fragments)
+	return fragments
+}
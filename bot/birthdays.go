@@ -0,0 +1,243 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	birthdayUsageMsg   = "Usage: /birthday set <day month>, e.g. /birthday set 14 March, or /birthday remove"
+	birthdayInvalidMsg = "I couldn't read that date. Try something like 14 March or 03/14."
+	birthdayRemovedMsg = "Your birthday was removed from this chat."
+	noBirthdaysMsg     = "No birthdays saved yet. Add yours with /birthday set 14 March."
+	upcomingBirthdays  = 10
+)
+
+// birthdayLayouts are the accepted ways to write a day and month
+var birthdayLayouts = []string{"2 January", "January 2", "2 Jan", "Jan 2", "2.1", "1/2", "2-1"}
+
+// Birthday is a user's birthday registered in a chat
+type Birthday struct {
+	ChatID    int64      `bson:"chat_id"`
+	UserID    int64      `bson:"user_id"`
+	Username  string     `bson:"username"`
+	FirstName string     `bson:"first_name"`
+	Month     time.Month `bson:"month"`
+	Day       int        `bson:"day"`
+}
+
+func (bs *BotService) handleBirthday(msg *tgbotapi.Message) {
+	sub, rest, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+
+	switch strings.ToLower(sub) {
+	case "set":
+		month, day, ok := parseBirthday(rest)
+		if !ok {
+			bs.Reply(msg, birthdayInvalidMsg)
+			return
+		}
+		birthday := Birthday{
+			ChatID:    msg.Chat.ID,
+			UserID:    msg.From.ID,
+			Username:  msg.From.UserName,
+			FirstName: msg.From.FirstName,
+			Month:     month,
+			Day:       day,
+		}
+		if err := bs.saveBirthday(birthday); err != nil {
+			log.Printf("Error saving birthday: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bs.Reply(msg, fmt.Sprintf("🎂 Got it! I'll remember your birthday on %d %s.", day, month))
+	case "remove":
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, err := bs.db.Collection("birthdays").DeleteOne(ctx, bson.M{"chat_id": msg.Chat.ID, "user_id": msg.From.ID}); err != nil {
+			log.Printf("Error removing birthday: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bs.Reply(msg, birthdayRemovedMsg)
+	default:
+		bs.Reply(msg, birthdayUsageMsg)
+	}
+}
+
+func (bs *BotService) handleBirthdays(msg *tgbotapi.Message) {
+	birthdays, err := bs.loadBirthdays(bson.M{"chat_id": msg.Chat.ID})
+	if err != nil {
+		log.Printf("Error loading birthdays: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(birthdays) == 0 {
+		bs.Reply(msg, noBirthdaysMsg)
+		return
+	}
+
+	today, _ := dayBounds(time.Now().In(bs.chatLocation(msg.Chat.ID)))
+	sort.Slice(birthdays, func(i, j int) bool {
+		return nextBirthday(birthdays[i], today).Before(nextBirthday(birthdays[j], today))
+	})
+	if len(birthdays) > upcomingBirthdays {
+		birthdays = birthdays[:upcomingBirthdays]
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🎂 Upcoming birthdays:\n")
+	for _, b := range birthdays {
+		next := nextBirthday(b, today)
+		days := int(math.Round(next.Sub(today).Hours() / 24)) // DST days are not always 24h
+		when := fmt.Sprintf("in %d days", days)
+		switch days {
+		case 0:
+			when = "today 🎉"
+		case 1:
+			when = "tomorrow"
+		}
+		sb.WriteString(fmt.Sprintf("- %s: %d %s (%s)\n", birthdayName(b), b.Day, b.Month, when))
+	}
+	bs.Reply(msg, sb.String())
+}
+
+// sendBirthdayGreetings posts a personalized greeting in the morning of a member's birthday
+func (bs *BotService) sendBirthdayGreetings(now time.Time) {
+	// Local dates differ by at most a day, so look at yesterday, today and tomorrow in UTC
+	var dates bson.A
+	for _, offset := range []int{-1, 0, 1} {
+		d := now.UTC().AddDate(0, 0, offset)
+		dates = append(dates, bson.M{"month": d.Month(), "day": d.Day()})
+		if d.Month() == time.February && d.Day() == 28 {
+			dates = append(dates, bson.M{"month": time.February, "day": 29})
+		}
+	}
+
+	birthdays, err := bs.loadBirthdays(bson.M{"$or": dates})
+	if err != nil {
+		log.Printf("Error loading birthdays for greetings: %v", err)
+		return
+	}
+
+	byChat := make(map[int64][]Birthday)
+	for _, b := range birthdays {
+		byChat[b.ChatID] = append(byChat[b.ChatID], b)
+	}
+
+	for chatID, chatBirthdays := range byChat {
+		local := now.In(bs.chatLocation(chatID))
+		if local.Hour() != morningReminderHour {
+			continue
+		}
+
+		today, _ := dayBounds(local)
+		var names []string
+		for _, b := range chatBirthdays {
+			if nextBirthday(b, today).Equal(today) {
+				names = append(names, birthdayName(b))
+			}
+		}
+		if len(names) == 0 || !bs.claimDailyRun("birthday_greetings", chatID, local.Format("2006-01-02")) {
+			continue
+		}
+
+		bs.sendNonEssential(chatID, bs.generateBirthdayGreeting(names))
+	}
+}
+
+func (bs *BotService) generateBirthdayGreeting(names []string) string {
+	fallback := fmt.Sprintf("🎉 Happy birthday, %s! 🎂", strings.Join(names, " and "))
+
+	prompt := fmt.Sprintf(`You are a helpful and witty Telegram bot in a group chat. Today is the birthday of the members below.
+Write a short, warm and playful birthday greeting for the group (2-3 sentences maximum).
+Mention everyone by the name given, add a couple of fitting emojis, and do not use markdown formatting.
+
+%s`, untrusted("member names", strings.Join(names, ", ")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	greeting, err := bs.generate(ctx, prompt)
+	if err != nil {
+		log.Printf("gemini birthday greeting error: %v", err)
+		return fallback
+	}
+	return greeting
+}
+
+func (bs *BotService) saveBirthday(b Birthday) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := bs.db.Collection("birthdays").UpdateOne(ctx,
+		bson.M{"chat_id": b.ChatID, "user_id": b.UserID},
+		bson.M{"$set": b},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (bs *BotService) loadBirthdays(filter bson.M) ([]Birthday, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := bs.db.Collection("birthdays").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var birthdays []Birthday
+	if err := cursor.All(ctx, &birthdays); err != nil {
+		return nil, err
+	}
+	return birthdays, nil
+}
+
+// parseBirthday reads a day and month written in one of birthdayLayouts
+func parseBirthday(text string) (time.Month, int, bool) {
+	text = strings.TrimSpace(text)
+	for _, layout := range birthdayLayouts {
+		// Parse against a leap year so 29 February is accepted
+		if t, err := time.Parse(layout+" 2006", text+" 2024"); err == nil {
+			return t.Month(), t.Day(), true
+		}
+	}
+	return 0, 0, false
+}
+
+// nextBirthday returns the next occurrence of a birthday on or after today.
+// 29 February is celebrated on 28 February in non-leap years.
+func nextBirthday(b Birthday, today time.Time) time.Time {
+	for year := today.Year(); ; year++ {
+		day := b.Day
+		if b.Month == time.February && day == 29 && !isLeapYear(year) {
+			day = 28
+		}
+		next := time.Date(year, b.Month, day, 0, 0, 0, 0, today.Location())
+		if !next.Before(today) {
+			return next
+		}
+	}
+}
+
+func isLeapYear(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+func birthdayName(b Birthday) string {
+	if b.Username != "" {
+		return "@" + b.Username
+	}
+	return b.FirstName
+}
@@ -0,0 +1,598 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/cache"
+	"github.com/sg-milad/ChatBuddy/eventbus"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/store"
+	"github.com/sg-milad/ChatBuddy/vectorstore"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	responseErrorMsg    = "I can't process that right now, try again later!"
+	unknownCmdMsg       = "I'm not sure how to respond to that."
+	fetchingMessagesMsg = "Fetching recent messages for summary... This may take a moment."
+	maxMessagesToFetch  = 200
+)
+
+// BotService is a running ChatBuddy bot; create one with NewBot
+type BotService struct {
+	api        TelegramClient
+	gemini     llm.Generator
+	store      *store.Store
+	botMention string
+	id         int64
+	db         *mongo.Database
+	// cache is the optional Redis shared by instances, nil without REDIS_URL
+	cache *cache.Redis
+	// bus publishes events for workers, nil without EVENT_BUS_URL
+	bus *eventbus.NATS
+	// vectors holds message embeddings for semantic search, see VECTOR_STORE
+	vectors vectorstore.VectorStore
+	// cfg is swapped on reload, read it with config()
+	cfg atomic.Pointer[Config]
+	// prompts are the prompt overrides from PROMPTS_FILE, also swapped on reload
+	prompts atomic.Pointer[promptTemplates]
+	// aliases matches the names from BOT_ALIASES, also swapped on reload
+	aliases atomic.Pointer[aliasMatcher]
+	// scamBlocklist holds the domains from SCAM_BLOCKLIST_FILE, also swapped on reload
+	scamBlocklist atomic.Pointer[domainSet]
+	// startedAt is shown on the admin dashboard
+	startedAt time.Time
+	// chatInfos caches chat metadata by chat ID, see chatInfo
+	chatInfos sync.Map
+	// chatActivity counts messages per chat until they are written to the chats registry, see recordChatActivity
+	chatActivity sync.Map
+	// sendQueue holds digests and notifications back while their chat is throttled
+	sendQueue sendQueue
+	// cannotPost holds the reason the bot may not post in a chat by chat ID, see markCannotPost
+	cannotPost sync.Map
+	// inflight holds the questions and AI commands being answered by inflightKey, for /cancel
+	inflight sync.Map
+	// premiumCache caches entitlement lookups by "scope:id", see premiumUntil
+	premiumCache sync.Map
+	// rates caches the exchange rates for /convert, see exchangeRates
+	rates exchangeRates
+
+	// metrics count the updates and AI requests this bot handled, shown per bot on the dashboard
+	metrics botMetrics
+	// fleet lists every bot run with this one, set on the main bot of a Fleet; fleetMain and spec are set
+	// on the extra bots, which share its connections
+	fleet     []*BotService
+	fleetMain *BotService
+	spec      *BotSpec
+
+	// Commands and handlers, including those registered by programs embedding the bot
+	// commands routes /name to its handler; commandList keeps them in /help order
+	commands        map[string]Command
+	commandList     []Command
+	messageHandlers []HandlerFunc
+	middlewares     []Middleware
+
+	// pipeline is the middleware chain every update goes through, see buildPipeline
+	pipeline UpdateHandler
+	limiter  rateLimiter
+	// chatLimiter counts AI replies per chat for the cooldown
+	chatLimiter rateLimiter
+	admins      adminCache
+
+	maintenance maintenanceState
+	reporter    errorReporter
+	spam        spamDetector
+}
+
+// Run starts the background jobs and HTTP servers and handles updates until the update channel closes.
+// The extra bots of a Fleet only handle their updates; the main bot runs the process-wide work.
+func (bs *BotService) Run() {
+	bs.startedAt = time.Now()
+	if bs.fleetMain == nil {
+		bs.startBackgroundWork()
+	}
+	bs.loadMaintenanceState()
+	// Every bot has its own send queue and flood limits
+	go bs.runSendQueue()
+
+	bs.registerBotCommands()
+
+	updates := bs.api.GetUpdatesChan(tgbotapi.NewUpdate(0))
+	for update := range updates {
+		bs.HandleUpdate(update)
+	}
+}
+
+// migrate applies the pending schema migrations and logs each one
+func migrate(db *store.Store) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	applied, err := db.Migrate(ctx, store.Migrations)
+	for _, m := range applied {
+		log.Printf("Applied migration %d: %s", m.Version, m.Description)
+	}
+	return err
+}
+
+// startBackgroundWork creates the indexes and starts error reporting, the scheduler, the embedding worker
+// and the HTTP servers
+func (bs *BotService) startBackgroundWork() {
+	// Forward logged errors to ERROR_CHAT_ID, if set
+	recentErrors.setNotify(bs.reportLoggedError)
+
+	if err := migrate(bs.store); err != nil {
+		log.Printf("Error migrating the database: %v", err)
+	}
+	bs.loadCannotPost()
+
+	go bs.runScheduler()
+	bs.startEmbeddingWorker()
+	bs.startHTTPServer()
+	bs.startDashboard()
+}
+
+// HandleUpdate processes a single update; Run calls it for every update received from Telegram
+func (bs *BotService) HandleUpdate(update tgbotapi.Update) {
+	bs.metrics.updates.Add(1)
+	bs.pipeline(update)
+}
+
+func (bs *BotService) handleCallback(cb *tgbotapi.CallbackQuery) {
+	kind, _, _ := strings.Cut(cb.Data, ":")
+
+	switch kind {
+	case "draft":
+		bs.handleDraftCallback(cb)
+	case "event":
+		bs.handleEventCallback(cb)
+	case "onboard":
+		bs.handleOnboardingCallback(cb)
+	case "consent":
+		bs.handleConsentCallback(cb)
+	case "setup":
+		bs.handleSetupCallback(cb)
+	case "rest":
+		bs.handleRemainderCallback(cb)
+	default:
+		bs.answerCallback(cb.ID, "")
+	}
+}
+
+func (bs *BotService) answerCallback(callbackID, text string) {
+	if _, err := bs.api.Request(tgbotapi.NewCallback(callbackID, text)); err != nil {
+		log.Printf("failed to answer callback query: %v", err)
+	}
+}
+
+func (bs *BotService) storeMessage(msg *tgbotapi.Message) {
+	text := msg.Text
+	if text == "" {
+		text = msg.Caption // Media posts carry their text in the caption
+	}
+	if text == "" {
+		return // Skip empty messages
+	}
+
+	username := ""
+	firstName := ""
+	lastName := ""
+	var fromID int64
+
+	if msg.From != nil {
+		fromID = msg.From.ID
+		username = msg.From.UserName
+		firstName = msg.From.FirstName
+		lastName = msg.From.LastName
+	} else if msg.SenderChat != nil {
+		// Channel posts and anonymous admins are sent on behalf of a chat
+		username = msg.SenderChat.UserName
+		firstName = msg.SenderChat.Title
+	}
+
+	message := store.Message{
+		ChatID:        msg.Chat.ID,
+		MessageID:     msg.MessageID,
+		FromID:        fromID,
+		FromUsername:  username,
+		FromFirstName: firstName,
+		FromLastName:  lastName,
+		Text:          text,
+		Timestamp:     msg.Time(),
+		Language:      detectLanguage(text),
+		Media:         mediaKind(msg),
+	}
+	if msg.ReplyToMessage != nil {
+		message.ReplyTo = msg.ReplyToMessage.MessageID
+	}
+
+	if err := bs.store.InsertMessage(message); err != nil {
+		log.Printf("Error storing message in MongoDB: %v", err)
+	}
+}
+
+func (bs *BotService) handleSummaryRequest(msg *tgbotapi.Message) {
+	bs.publish(messageEvent(eventbus.SummaryRequested, msg))
+
+	// "/summary today" or "/summary yesterday" restricts the summary to that day in the chat timezone
+	loc := bs.chatLocation(msg.Chat.ID)
+	filter := bson.M{"chat_id": msg.Chat.ID}
+	// Without a range the latest messages are summarized; a day or week is summarized whole, in chunks
+	limit := maxMessagesToFetch
+	if arg := msg.CommandArguments(); arg != "" {
+		from, to, ok := parseDayRange(arg, time.Now().In(loc))
+		if !ok {
+			bs.Reply(msg, "Usage: /summary [today|yesterday|this week|last week]")
+			return
+		}
+		filter["timestamp"] = bson.M{"$gte": from, "$lt": to}
+		limit = maxRangeMessagesToFetch
+	}
+	stored, err := bs.store.FindMessages(filter, limit)
+	if err != nil {
+		errorMsg := tgbotapi.NewMessage(msg.Chat.ID, "Failed to fetch messages: "+err.Error())
+		errorMsg.ReplyToMessageID = msg.MessageID
+		bs.sendResponse(errorMsg)
+		return
+	}
+
+	if len(stored) == 0 {
+		noMsgReply := tgbotapi.NewMessage(msg.Chat.ID, "No recent messages found to summarize.")
+		noMsgReply.ReplyToMessageID = msg.MessageID
+		bs.sendResponse(noMsgReply)
+		return
+	}
+
+	summary := bs.summarizeMessages(bs.billedContext(msg), formatMessages(stored, loc, bs.chatNicknames(msg.Chat.ID)), bs.summaryLanguage(msg.Chat.ID, stored))
+
+	response := tgbotapi.NewMessage(msg.Chat.ID, summary)
+	response.ReplyToMessageID = msg.MessageID
+	sent := bs.sendAs(kindAnswer, response)
+	if sent.MessageID != 0 && !msg.Chat.IsPrivate() && bs.store.ChatSettings(msg.Chat.ID).PinSummaries {
+		bs.pinBotMessage(msg.Chat.ID, sent.MessageID)
+	}
+}
+
+func (bs *BotService) fetchMessagesFromDB(chatID int64, limit int) ([]string, error) {
+	// Define query to get messages from the specific chat
+	filter := bson.M{"chat_id": chatID}
+	return bs.queryMessages(filter, limit, bs.chatLocation(chatID))
+}
+
+// fetchMessagesSinceFromDB returns formatted messages of a chat posted after since
+func (bs *BotService) fetchMessagesSinceFromDB(chatID int64, since time.Time, limit int) ([]string, error) {
+	filter := bson.M{
+		"chat_id":   chatID,
+		"timestamp": bson.M{"$gte": since},
+	}
+	return bs.queryMessages(filter, limit, bs.chatLocation(chatID))
+}
+
+// fetchMessagesInRangeFromDB returns formatted messages of a chat posted in [from, to)
+func (bs *BotService) fetchMessagesInRangeFromDB(chatID int64, from, to time.Time, limit int) ([]string, error) {
+	filter := bson.M{
+		"chat_id":   chatID,
+		"timestamp": bson.M{"$gte": from, "$lt": to},
+	}
+	return bs.queryMessages(filter, limit, bs.chatLocation(chatID))
+}
+
+// queryMessages returns the latest messages matching filter in chronological order,
+// formatted with timestamps in loc
+func (bs *BotService) queryMessages(filter bson.M, limit int, loc *time.Location) ([]string, error) {
+	dbMessages, err := bs.store.FindMessages(filter, limit)
+	if err != nil || len(dbMessages) == 0 {
+		return nil, err
+	}
+	return formatMessages(dbMessages, loc, bs.chatNicknames(dbMessages[0].ChatID)), nil
+}
+
+// formatMessages formats stored messages, newest first as FindMessages returns them, in chronological order
+// with timestamps in loc and senders called by their nicknames, see speaker
+func formatMessages(dbMessages []store.Message, loc *time.Location, nicknames map[string]string) []string {
+	var messages []string
+	for i := len(dbMessages) - 1; i >= 0; i-- { // Reverse to get chronological order
+		msg := dbMessages[i]
+		timestamp := msg.Timestamp.In(loc).Format("2006-01-02 15:04:05")
+		messages = append(messages, fmt.Sprintf("[%s] %s: %s", timestamp, speaker(msg, nicknames), msg.Text))
+	}
+	return messages
+}
+
+func (bs *BotService) summarizeMessages(ctx context.Context, messages []string, language string) string {
+	summary, err := bs.generateSummary(ctx, messages, language)
+	if err != nil {
+		log.Printf("gemini summarization error: %v", err)
+		return "I couldn't generate a summary due to an error. Please try again later."
+	}
+	return summary
+}
+
+// generateSummary asks the model for a concise summary of formatted chat messages, in language when set, see
+// summaryLanguage, and has it rewritten once when it fails checkSummary. Conversations too long for one request
+// are summarized in chunks whose notes are merged, see chunkedSummaryPrompt.
+func (bs *BotService) generateSummary(ctx context.Context, messages []string, language string) (string, error) {
+	var prompt string
+	if needsChunking(messages) {
+		var err error
+		if prompt, err = bs.chunkedSummaryPrompt(ctx, messages, language); err != nil {
+			return "", err
+		}
+	} else {
+		prompt = bs.summaryPrompt(messages, language)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 90*time.Second) // Longer timeout for processing many messages
+	defer cancel()
+
+	summary, err := bs.generate(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	// A summary failing the quality check is written again once, told what to fix
+	problems := checkSummary(messages, language, summary)
+	if len(problems) == 0 {
+		return summary, nil
+	}
+	log.Printf("Summary failed the quality check, rewriting it: %s", strings.Join(problems, "; "))
+	rewrite, err := bs.generate(ctx, fmt.Sprintf("%s\n\nA first draft of this summary had these problems, avoid them:\n- %s\n\nFirst draft:\n%s",
+		prompt, strings.Join(problems, "\n- "), summary))
+	if err != nil {
+		log.Printf("gemini summary rewrite error, keeping the first draft: %v", err)
+		return summary, nil
+	}
+	return rewrite, nil
+}
+
+// summaryPrompt is the prompt of the summary of formatted messages, the "summary" template when PROMPTS_FILE
+// has one
+func (bs *BotService) summaryPrompt(messages []string, language string) string {
+	combinedMessages := untrusted("chat messages", strings.Join(messages, "\n"))
+
+	responseLanguage := cmp.Or(language, "Same as the user's message")
+	prompt, ok := bs.customPrompt("summary", map[string]any{"Count": len(messages), "Messages": combinedMessages, "Language": language})
+	if !ok {
+		prompt = fmt.Sprintf(`Below are the latest %d messages from a Telegram chat. Please provide a concise summary of the main topics and conversations:
+
+%s
+
+Summary instructions:
+1. Identify the main topics discussed
+2. Note any questions asked and answers given
+3. Highlight any decisions made or important information shared
+4. Keep all responses brief and concise(4-5 sentences maximum)
+5. Format the summary in plain text (no markdown)
+6. Response language: %s`, len(messages), combinedMessages, responseLanguage)
+	}
+	return prompt
+}
+
+func (bs *BotService) handleQuery(msg *tgbotapi.Message) {
+	// Replies like "thanks" or "lol" get a reaction or nothing instead of a full answer
+	if bs.isPlainReplyToBot(msg) {
+		switch action, reaction := bs.classifyReply(msg); action {
+		case replyReact:
+			bs.react(msg, reaction)
+			return
+		case replyIgnore:
+			return
+		}
+	}
+
+	question := bs.extractQuestion(msg)
+	if followUp, ok := bs.followUpPart(msg, question); ok {
+		question = followUp
+	}
+
+	// Grounded chats get every other question answered from their history and knowledge base only, so
+	// conversions, code and math go through generateResponse too
+	settings := bs.store.ChatSettings(msg.Chat.ID)
+	var response string
+	if settings.Rules != "" && bs.isRulesQuestion(question) {
+		response = bs.answerRulesQuestion(msg, settings.Rules, question)
+	} else if bs.isTodoQuestion(question) {
+		response = bs.answerFromTodos(msg, question)
+	} else if bs.isCommitmentsQuestion(question) {
+		response = bs.answerFromCommitments(msg, question)
+	} else if answer, ok := bs.conversionAnswer(question); ok && !settings.Grounded {
+		response = answer
+	} else if looksLikeCode(question) && !settings.Grounded {
+		bs.answerCode(msg, question)
+		return
+	} else if looksLikeMath(question) && !settings.Grounded {
+		bs.answerMath(msg, question)
+		return
+	} else if questions := bs.splitQuestions(msg, question); len(questions) > 1 {
+		bs.answerParts(msg, questions)
+		return
+	} else {
+		response = bs.generateResponse(msg, question)
+	}
+	if displayMathRe.MatchString(response) {
+		bs.replyWithMath(msg, response)
+		return
+	}
+
+	reply := tgbotapi.NewMessage(msg.Chat.ID, response)
+
+	reply.ReplyToMessageID = msg.MessageID
+	bs.sendResponse(reply)
+}
+
+func (bs *BotService) isBotMentioned(text string) bool {
+	return strings.Contains(strings.ToLower(text), strings.ToLower(bs.botMention))
+}
+
+func (bs *BotService) extractQuestion(msg *tgbotapi.Message) string {
+	text := msg.Text
+	if msg.IsCommand() {
+		// /ask and /ai carry the question as their arguments
+		text = msg.CommandArguments()
+	}
+	cleanText := bs.stripAlias(strings.ReplaceAll(text, bs.botMention, ""))
+	// "my build fails" / "with this error: ..." / "@bot" asks about all three
+	if fragments := bs.questionFragments(msg); len(fragments) > 0 {
+		cleanText = strings.TrimSpace(strings.Join(fragments, "\n") + "\n" + cleanText)
+	}
+
+	// A reply to the bot continues the conversation, see conversationHistory
+	if msg.ReplyToMessage != nil && !bs.isReplyToBot(msg) {
+		return fmt.Sprintf("%s\n\n%s", cleanText, msg.ReplyToMessage.Text)
+	}
+	return cleanText
+}
+
+// conversationHistory returns the earlier turns of the conversation a question continues: the bot's answer
+// it replies to
+func (bs *BotService) conversationHistory(msg *tgbotapi.Message) []llm.Turn {
+	if !bs.isReplyToBot(msg) || msg.ReplyToMessage.Text == "" {
+		return nil
+	}
+	return []llm.Turn{{Role: llm.RoleModel, Text: msg.ReplyToMessage.Text}}
+}
+
+func (bs *BotService) generateResponse(msg *tgbotapi.Message, query string) string {
+	query, style := bs.questionStyle(msg.Chat.ID, query)
+	ctx, cancel := context.WithTimeout(bs.billedContext(msg), 60*time.Second) // 60s timeout
+	defer cancel()
+	if style.MaxOutputTokens > 0 {
+		ctx = llm.WithMaxOutputTokens(ctx, style.MaxOutputTokens)
+	}
+	if history := bs.conversationHistory(msg); len(history) > 0 {
+		ctx = llm.WithHistory(ctx, history...)
+	}
+	if bs.store.ChatSettings(msg.Chat.ID).Grounded {
+		return bs.groundedAnswer(bs.premiumContext(ctx, msg.Chat), msg.Chat, query, style)
+	}
+	// A knowledge base big enough to cache is read whole from the model's context cache, otherwise the
+	// passages matching the question go in the instructions
+	knowledge := ""
+	if whole := bs.cachedKnowledge(msg.Chat); whole != "" {
+		ctx = llm.WithCachedContext(ctx, knowledgeCacheKey(msg.Chat.ID), whole)
+	} else {
+		knowledge = bs.knowledgeContext(msg.Chat, query)
+	}
+	// The instructions go in the system role and the question is the user's message
+	ctx = llm.WithSystemInstruction(ctx, bs.responseInstruction(msg.Chat, query, knowledge, style))
+	// The discussion before the question goes with it, so "what does he mean by that?" has something to refer
+	// to; it changes with every question, so it's kept out of the instructions the context cache is keyed by
+	prompt := query
+	if recent := bs.surroundingContext(msg); recent != "" {
+		prompt = recent + "\n\nThe question:\n" + query
+	}
+
+	tools := bs.assistantTools(msg)
+	var sources []webResult
+	if search := bs.searchProvider(); search != nil && bs.store.ChatSettings(msg.Chat.ID).WebSearch {
+		tools = append(tools, webSearchTool(search, &sources))
+	}
+	ctx = llm.WithTools(bs.premiumContext(ctx, msg.Chat), tools...)
+	response, err := bs.generate(ctx, prompt)
+	if errors.Is(err, errCancelled) {
+		return ""
+	}
+	if errors.Is(err, errFamilyFiltered) {
+		return familyFilteredMsg
+	}
+	if err != nil {
+		log.Printf("gemini generation error: %v", err)
+		return responseErrorMsg
+	}
+	return withSources(response, sources)
+}
+
+// responseInstruction is the system instruction of answers to mentions and replies: the bot's persona, the
+// chat, its glossary and nicknames and the knowledge matching the question, and the style guidelines
+func (bs *BotService) responseInstruction(chat *tgbotapi.Chat, query, knowledge string, style answerStyle) string {
+	chatContext := bs.chatContext(chat)
+	glossary := strings.TrimSpace(bs.glossaryContext(chat) + "\n\n" + bs.nicknamesContext(chat))
+	data := map[string]any{"Question": query, "Chat": chatContext, "Knowledge": knowledge, "Glossary": glossary,
+		"Style": style.Length + ". " + style.Tone + "."}
+	if instruction, ok := bs.customPrompt("response", data); ok {
+		return instruction
+	}
+	if chatContext != "" {
+		chatContext = " " + chatContext
+	}
+	if glossary != "" {
+		knowledge = strings.TrimSpace(glossary + "\n\n" + knowledge)
+	}
+	if knowledge != "" {
+		knowledge = "\n\n" + knowledge
+	}
+	return fmt.Sprintf(`You are a helpful and witty Telegram bot.%s Answer the user's messages.%s
+
+Follow these response guidelines:
+1. %s
+2. DO NOT use markdown formatting (no asterisks for bold/italic)
+3. %s
+4. Focus only on the most essential information
+5. Learn from the user's instructions and feedback during this conversation and adapt your responses accordingly.
+Response language: %s`, chatContext, knowledge, style.Length, style.Tone, bs.responseLanguage(chat))
+}
+
+// responseLanguage is the language answers in chat should be in: the one picked during onboarding in DMs,
+// the user's own otherwise
+func (bs *BotService) responseLanguage(chat *tgbotapi.Chat) string {
+	if chat.IsPrivate() {
+		if code := bs.store.UserPreferences(chat.ID).Language; code != "" && languageName(code) != "" {
+			return languageName(code)
+		}
+	}
+	return "Same as the user's message"
+}
+
+// Reply sends text as a reply to msg
+func (bs *BotService) Reply(msg *tgbotapi.Message, text string) {
+	response := tgbotapi.NewMessage(msg.Chat.ID, text)
+	response.ReplyToMessageID = msg.MessageID
+	bs.sendResponse(response)
+}
+
+// sendResponse sends a message with the chat's send options for answers
+func (bs *BotService) sendResponse(response tgbotapi.MessageConfig) {
+	bs.sendAs(kindAnswer, response)
+}
+
+// send sends a message as is, split into chunks Telegram accepts, and returns the first chunk sent. A chunk
+// that still fails after retrying ends the message: the rest is kept and the last chunk sent gets a button
+// delivering it in a DM.
+func (bs *BotService) send(response tgbotapi.MessageConfig) tgbotapi.Message {
+	var first, last tgbotapi.Message
+	chunks := splitMessage(response.Text, maxMessageLength, response.ParseMode == tgbotapi.ModeHTML)
+
+	for i, text := range chunks {
+		chunk := response
+		chunk.Text = text
+		if i < len(chunks)-1 {
+			// Buttons go with the last chunk
+			chunk.ReplyMarkup = nil
+		}
+		sent, err := bs.sendChunk(chunk)
+		if err != nil {
+			log.Printf("failed to send message chunk %d of %d: %v", i+1, len(chunks), err)
+			bs.markCannotPost(response.ChatID, err)
+			if i > 0 {
+				bs.offerRemainder(last, response.ParseMode, strings.Join(chunks[i:], ""))
+			}
+			return first
+		}
+		if i == 0 {
+			first = sent
+			bs.clearCannotPost(response.ChatID)
+		}
+		bs.noteSent(response.ChatID)
+		last = sent
+	}
+	return first
+}
@@ -0,0 +1,255 @@
+// Package bottest provides fake Telegram and model clients and an update replay harness,
+// so handler logic can be exercised without live tokens.
+package bottest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/bot"
+	"github.com/sg-milad/ChatBuddy/llm"
+)
+
+var (
+	_ bot.TelegramClient = (*FakeTelegram)(nil)
+	_ llm.Generator      = (*FakeGenerator)(nil)
+)
+
+// Sent is a request the bot made through FakeTelegram
+type Sent struct {
+	// Config is the original request, e.g. a tgbotapi.MessageConfig
+	Config tgbotapi.Chattable
+	// ChatID, MessageID and Text are filled in for the request types the bot uses
+	ChatID    int64
+	MessageID int
+	Text      string
+	// Endpoint and Params are set instead of Config for raw MakeRequest calls, e.g. setMessageReaction
+	Endpoint string
+	Params   tgbotapi.Params
+}
+
+// FakeTelegram implements bot.TelegramClient in memory, recording everything the bot sends
+type FakeTelegram struct {
+	// Chats answers GetChat; unknown chats come back as a bare group with the requested ID
+	Chats map[int64]tgbotapi.Chat
+	// Members answers GetChatMember and GetChatAdministrators, keyed by chat ID
+	Members map[int64][]tgbotapi.ChatMember
+	// Updates is returned from GetUpdatesChan, so a test can drive Run
+	Updates chan tgbotapi.Update
+	// Files answers GetFileDirectURL, from file ID to URL
+	Files map[string]string
+
+	mu     sync.Mutex
+	sent   []Sent
+	nextID int
+	notify chan struct{}
+}
+
+// NewFakeTelegram returns an empty fake
+func NewFakeTelegram() *FakeTelegram {
+	return &FakeTelegram{
+		Chats:   make(map[int64]tgbotapi.Chat),
+		Members: make(map[int64][]tgbotapi.ChatMember),
+		Updates: make(chan tgbotapi.Update, 100),
+		Files:   make(map[string]string),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// SetAdmin makes user an administrator of chat
+func (f *FakeTelegram) SetAdmin(chatID int64, user tgbotapi.User) {
+	f.Members[chatID] = append(f.Members[chatID], tgbotapi.ChatMember{User: &user, Status: "administrator"})
+}
+
+func (f *FakeTelegram) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	sent := f.record(c)
+	return tgbotapi.Message{
+		MessageID: sent.MessageID,
+		Chat:      &tgbotapi.Chat{ID: sent.ChatID},
+		Text:      sent.Text,
+		Date:      int(time.Now().Unix()),
+	}, nil
+}
+
+func (f *FakeTelegram) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	f.record(c)
+	return &tgbotapi.APIResponse{Ok: true, Result: []byte("true")}, nil
+}
+
+func (f *FakeTelegram) MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error) {
+	chatID, _ := strconv.ParseInt(params["chat_id"], 10, 64)
+	messageID, _ := strconv.Atoi(params["message_id"])
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.add(Sent{Endpoint: endpoint, Params: params, ChatID: chatID, MessageID: messageID})
+	return &tgbotapi.APIResponse{Ok: true, Result: []byte("true")}, nil
+}
+
+func (f *FakeTelegram) GetChat(config tgbotapi.ChatInfoConfig) (tgbotapi.Chat, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if chat, ok := f.Chats[config.ChatID]; ok {
+		return chat, nil
+	}
+	return tgbotapi.Chat{ID: config.ChatID, Type: "group"}, nil
+}
+
+func (f *FakeTelegram) GetChatMember(config tgbotapi.GetChatMemberConfig) (tgbotapi.ChatMember, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, member := range f.Members[config.ChatID] {
+		if member.User != nil && member.User.ID == config.UserID {
+			return member, nil
+		}
+	}
+	return tgbotapi.ChatMember{User: &tgbotapi.User{ID: config.UserID}, Status: "member"}, nil
+}
+
+func (f *FakeTelegram) GetChatAdministrators(config tgbotapi.ChatAdministratorsConfig) ([]tgbotapi.ChatMember, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var admins []tgbotapi.ChatMember
+	for _, member := range f.Members[config.ChatID] {
+		if member.IsAdministrator() || member.IsCreator() {
+			admins = append(admins, member)
+		}
+	}
+	return admins, nil
+}
+
+func (f *FakeTelegram) GetChatMembersCount(config tgbotapi.ChatMemberCountConfig) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.Members[config.ChatID]), nil
+}
+
+func (f *FakeTelegram) GetUpdatesChan(tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
+	return f.Updates
+}
+
+func (f *FakeTelegram) GetFileDirectURL(fileID string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	url, ok := f.Files[fileID]
+	if !ok {
+		return "", fmt.Errorf("fake: unknown file %q", fileID)
+	}
+	return url, nil
+}
+
+// Sent returns everything sent so far, oldest first
+func (f *FakeTelegram) Sent() []Sent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Sent(nil), f.sent...)
+}
+
+// Texts returns the text of everything sent so far, oldest first
+func (f *FakeTelegram) Texts() []string {
+	var texts []string
+	for _, s := range f.Sent() {
+		if s.Text != "" {
+			texts = append(texts, s.Text)
+		}
+	}
+	return texts
+}
+
+// WaitForSent blocks until at least n requests were sent, for handlers that reply from a goroutine
+func (f *FakeTelegram) WaitForSent(n int, timeout time.Duration) ([]Sent, error) {
+	deadline := time.After(timeout)
+	for {
+		if sent := f.Sent(); len(sent) >= n {
+			return sent, nil
+		}
+		select {
+		case <-f.notify:
+		case <-deadline:
+			return f.Sent(), fmt.Errorf("timed out waiting for %d sent messages, got %d", n, len(f.Sent()))
+		}
+	}
+}
+
+// Reset forgets everything sent so far
+func (f *FakeTelegram) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = nil
+}
+
+func (f *FakeTelegram) record(c tgbotapi.Chattable) Sent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	sent := Sent{Config: c, MessageID: f.nextID}
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		sent.ChatID, sent.Text = c.ChatID, c.Text
+	case tgbotapi.EditMessageTextConfig:
+		sent.ChatID, sent.MessageID, sent.Text = c.ChatID, c.MessageID, c.Text
+	case tgbotapi.DocumentConfig:
+		sent.ChatID, sent.Text = c.ChatID, c.Caption
+	case tgbotapi.DeleteMessageConfig:
+		sent.ChatID, sent.MessageID = c.ChatID, c.MessageID
+	case tgbotapi.CallbackConfig:
+		sent.Text = c.Text
+	}
+	f.add(sent)
+	return sent
+}
+
+// add appends a request and wakes up WaitForSent; f.mu must be held
+func (f *FakeTelegram) add(sent Sent) {
+	f.sent = append(f.sent, sent)
+	select {
+	case f.notify <- struct{}{}:
+	default:
+	}
+}
+
+// FakeGenerator implements llm.Generator with canned answers and records every prompt
+type FakeGenerator struct {
+	// Respond computes the answer to a prompt; when nil, a Rules entry whose key
+	// appears in the prompt wins (keep keys distinct), falling back to Default
+	Respond func(prompt string) (string, error)
+	Rules   map[string]string
+	Default string
+
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (g *FakeGenerator) GenerateText(_ context.Context, prompt string) (string, error) {
+	g.mu.Lock()
+	g.prompts = append(g.prompts, prompt)
+	g.mu.Unlock()
+
+	if g.Respond != nil {
+		return g.Respond(prompt)
+	}
+	for key, answer := range g.Rules {
+		if strings.Contains(prompt, key) {
+			return answer, nil
+		}
+	}
+	return g.Default, nil
+}
+
+// Prompts returns every prompt received so far, oldest first
+func (g *FakeGenerator) Prompts() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.prompts...)
+}
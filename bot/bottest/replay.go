@@ -0,0 +1,149 @@
+package bottest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/bot"
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+// Self is the bot user the harness runs as
+var Self = tgbotapi.User{ID: 1000, IsBot: true, FirstName: "ChatBuddy", UserName: "chatbuddy_test_bot"}
+
+// Harness is a bot wired to fakes instead of Telegram and Gemini
+type Harness struct {
+	Bot       *bot.BotService
+	Telegram  *FakeTelegram
+	Generator *FakeGenerator
+}
+
+// New builds a bot on top of fakes; s should point at a throwaway database, see ConnectTestStore
+func New(s *store.Store, opts ...bot.Option) (*Harness, error) {
+	h := &Harness{Telegram: NewFakeTelegram(), Generator: &FakeGenerator{}}
+
+	opts = append([]bot.Option{
+		bot.WithConfig(&bot.Config{DefaultLocation: time.UTC}),
+		bot.WithTelegram(h.Telegram, Self),
+		bot.WithGenerator(h.Generator),
+		bot.WithStore(s),
+	}, opts...)
+
+	b, err := bot.NewBot(opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.Bot = b
+	return h, nil
+}
+
+// ConnectTestStore creates a uniquely named database on the MongoDB at uri; call the returned func to drop it
+func ConnectTestStore(uri string) (*store.Store, func(), error) {
+	name := fmt.Sprintf("chatbuddy_test_%d", time.Now().UnixNano())
+	s, err := store.Connect(uri, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = s.DB().Drop(ctx)
+		s.Close()
+	}
+	return s, cleanup, nil
+}
+
+// Replay feeds updates to the bot in order, as if they came from Telegram
+func (h *Harness) Replay(updates ...tgbotapi.Update) {
+	for _, update := range updates {
+		h.Bot.HandleUpdate(update)
+	}
+}
+
+// ReplayFile feeds the updates stored in a file to the bot, see LoadUpdates
+func (h *Harness) ReplayFile(path string) error {
+	updates, err := LoadUpdates(path)
+	if err != nil {
+		return err
+	}
+	h.Replay(updates...)
+	return nil
+}
+
+// LoadUpdates reads updates in Bot API JSON format, either as one JSON array
+// (like a getUpdates result) or as one update per line
+func LoadUpdates(path string) ([]tgbotapi.Update, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	var updates []tgbotapi.Update
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &updates); err != nil {
+			return nil, fmt.Errorf("error decoding %s: %w", path, err)
+		}
+		return updates, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var update tgbotapi.Update
+		if err := json.Unmarshal(text, &update); err != nil {
+			return nil, fmt.Errorf("error decoding %s line %d: %w", path, line, err)
+		}
+		updates = append(updates, update)
+	}
+	return updates, scanner.Err()
+}
+
+var nextUpdateID atomic.Int64
+
+// Message builds an update for a text message; a leading /command is marked up as a bot command
+func Message(chat tgbotapi.Chat, from tgbotapi.User, text string) tgbotapi.Update {
+	id := int(nextUpdateID.Add(1))
+	msg := &tgbotapi.Message{
+		MessageID: id,
+		From:      &from,
+		Chat:      &chat,
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}
+	if strings.HasPrefix(text, "/") {
+		command, _, _ := strings.Cut(text, " ")
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}}
+	}
+	return tgbotapi.Update{UpdateID: id, Message: msg}
+}
+
+// ReplyTo builds an update for a text message replying to another message
+func ReplyTo(parent *tgbotapi.Message, from tgbotapi.User, text string) tgbotapi.Update {
+	update := Message(*parent.Chat, from, text)
+	update.Message.ReplyToMessage = parent
+	return update
+}
+
+// Group returns a group chat with the given ID
+func Group(id int64, title string) tgbotapi.Chat {
+	return tgbotapi.Chat{ID: id, Type: "supergroup", Title: title}
+}
+
+// Private returns the DM chat with user
+func Private(user tgbotapi.User) tgbotapi.Chat {
+	return tgbotapi.Chat{ID: user.ID, Type: "private", FirstName: user.FirstName, UserName: user.UserName}
+}
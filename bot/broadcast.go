@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// broadcastDelay keeps broadcasts well under Telegram's limit of about 30 messages per second
+const broadcastDelay = 50 * time.Millisecond
+
+// handleBroadcast sends an announcement from the owner to every chat the bot has seen
+func (bs *BotService) handleBroadcast(msg *tgbotapi.Message) {
+	text := strings.TrimSpace(msg.CommandArguments())
+
+	chatIDs, err := bs.store.ChatIDs()
+	if err != nil {
+		log.Printf("Error loading chats for broadcast: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	sent, failed := 0, 0
+	for _, chatID := range chatIDs {
+		if _, err := bs.api.Send(tgbotapi.NewMessage(chatID, "📢 "+text)); err != nil {
+			// Chats the bot was removed from or users who blocked it can't be reached
+			log.Printf("failed to broadcast to chat %d: %v", chatID, err)
+			failed++
+		} else {
+			sent++
+		}
+		time.Sleep(broadcastDelay)
+	}
+	bs.Reply(msg, fmt.Sprintf("📢 Broadcast sent to %d chats (%d failed).", sent, failed))
+}
@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sg-milad/ChatBuddy/llm"
+)
+
+// rateLimitWindow is the fixed window of the shared rate-limit counters in Redis
+const rateLimitWindow = time.Minute
+
+// allowRequest counts a request against limit per minute. With Redis the count is shared by all instances
+// in fixed one-minute windows; without it, or when Redis fails, the local sliding window in limiter is used.
+func (bs *BotService) allowRequest(limiter *rateLimiter, name string, key int64, limit int, now time.Time) (allowed, warn bool) {
+	if bs.cache == nil {
+		return limiter.allow(key, limit, now)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	window := now.Unix() / int64(rateLimitWindow.Seconds())
+	n, err := bs.cache.Incr(ctx, fmt.Sprintf("ratelimit:%s:%d:%d", name, key, window), 2*rateLimitWindow)
+	if err != nil {
+		log.Printf("Error counting %s in Redis, using the local limit: %v", name, err)
+		return limiter.allow(key, limit, now)
+	}
+	// Only the first request over the limit warns
+	return n <= int64(limit), n == int64(limit)+1
+}
+
+// claimJobRun takes the shared lock of a scheduled job for this tick, so only one instance runs it.
+// The lock is left to expire instead of released, so instances whose tickers are a few seconds apart don't rerun it.
+func (bs *BotService) claimJobRun(name string) bool {
+	if bs.cache == nil {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, ok, err := bs.cache.Lock(ctx, "lock:job:"+name, schedulerInterval-5*time.Second)
+	if err != nil {
+		log.Printf("Error locking job %s in Redis, running it anyway: %v", name, err)
+		return true
+	}
+	return ok
+}
+
+// cachedResponse returns an answer the model gave to the same prompt within RESPONSE_CACHE_MINUTES
+func (bs *BotService) cachedResponse(ctx context.Context, prompt string) (string, bool) {
+	if bs.cache == nil || bs.config().ResponseCacheMinutes <= 0 {
+		return "", false
+	}
+	cached, err := bs.cache.Get(ctx, responseCacheKey(ctx, prompt))
+	if err != nil {
+		return "", false
+	}
+	return string(cached), true
+}
+
+func (bs *BotService) cacheResponse(ctx context.Context, prompt, text string) {
+	if bs.cache == nil || bs.config().ResponseCacheMinutes <= 0 {
+		return
+	}
+	ttl := time.Duration(bs.config().ResponseCacheMinutes) * time.Minute
+	if err := bs.cache.Set(ctx, responseCacheKey(ctx, prompt), []byte(text), ttl); err != nil {
+		log.Printf("Error caching response: %v", err)
+	}
+}
+
+// responseCacheKey hashes the prompt with the model it is sent to, as premium chats may use another one, the
+// output cap of its answer style, its safety mode, so family chats don't get answers made without their filter,
+// its system instruction, cached context and conversation history, and the schema of JSON answers
+func responseCacheKey(ctx context.Context, prompt string) string {
+	h := sha256.New()
+	_, cachedContext := llm.CachedContextFromContext(ctx)
+	fmt.Fprintf(h, "%s\n%d\n%t\n%s\n%s\n", llm.ModelFromContext(ctx), llm.MaxOutputTokensFromContext(ctx), llm.StrictSafetyFromContext(ctx),
+		llm.SystemInstructionFromContext(ctx), cachedContext)
+	for _, turn := range llm.HistoryFromContext(ctx) {
+		fmt.Fprintf(h, "%s: %s\n", turn.Role, turn.Text)
+	}
+	if schema := llm.JSONSchemaFromContext(ctx); schema != nil {
+		json.NewEncoder(h).Encode(schema)
+	}
+	h.Write([]byte(prompt))
+	return "response:" + hex.EncodeToString(h.Sum(nil))
+}
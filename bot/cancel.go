@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	cancelledMsg       = "🛑 Cancelled."
+	nothingToCancelMsg = "There's nothing of yours I'm still working on here."
+	cancelNotYoursMsg  = "Only the person who asked or an admin can cancel that."
+)
+
+// errCancelled is returned by generate when the request was aborted with /cancel
+var errCancelled = errors.New("cancelled with /cancel")
+
+// inflightKey identifies a request by the message that asked for it
+type inflightKey struct {
+	chatID    int64
+	messageID int
+}
+
+// inflightRequest is a question or AI command being answered; messageContext derives its contexts from ctx, so
+// cancel aborts every model call made for it
+type inflightRequest struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	userID    int64
+	cancelled atomic.Bool
+}
+
+// startRequest registers msg as in flight until the returned function is called
+func (bs *BotService) startRequest(msg *tgbotapi.Message) (done func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &inflightRequest{ctx: ctx, cancel: cancel}
+	if msg.From != nil {
+		r.userID = msg.From.ID
+	}
+	key := inflightKey{msg.Chat.ID, msg.MessageID}
+	bs.inflight.Store(key, r)
+	return func() {
+		bs.inflight.Delete(key)
+		cancel()
+	}
+}
+
+func (bs *BotService) inflightRequest(chatID int64, messageID int) (*inflightRequest, bool) {
+	r, ok := bs.inflight.Load(inflightKey{chatID, messageID})
+	if !ok {
+		return nil, false
+	}
+	return r.(*inflightRequest), true
+}
+
+// isCancelled reports whether the request asked for by a message was cancelled; its replies are dropped
+func (bs *BotService) isCancelled(chatID int64, messageID int) bool {
+	r, ok := bs.inflightRequest(chatID, messageID)
+	return ok && r.cancelled.Load()
+}
+
+// handleCancel aborts the replied-to request, which admins may do for anyone, or all of the sender's requests
+// in the chat
+func (bs *BotService) handleCancel(msg *tgbotapi.Message) {
+	if reply := msg.ReplyToMessage; reply != nil {
+		r, ok := bs.inflightRequest(msg.Chat.ID, reply.MessageID)
+		switch {
+		case !ok:
+			bs.Reply(msg, nothingToCancelMsg)
+		case r.userID != msg.From.ID && bs.userRole(msg.Chat, msg.From) < ChatAdmin:
+			bs.Reply(msg, cancelNotYoursMsg)
+		default:
+			r.cancelled.Store(true)
+			r.cancel()
+			bs.Reply(msg, cancelledMsg)
+		}
+		return
+	}
+
+	n := 0
+	bs.inflight.Range(func(k, v any) bool {
+		if r := v.(*inflightRequest); k.(inflightKey).chatID == msg.Chat.ID && r.userID == msg.From.ID {
+			r.cancelled.Store(true)
+			r.cancel()
+			n++
+		}
+		return true
+	})
+	if n == 0 {
+		bs.Reply(msg, nothingToCancelMsg)
+		return
+	}
+	bs.Reply(msg, cancelledMsg)
+}
@@ -0,0 +1,348 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	channelDigestWindow   = 7 * 24 * time.Hour
+	draftStyleSampleSize  = 20
+	notLinkedChannelMsg   = "This group isn't linked to a channel, so there's nothing to digest."
+	noChannelPostsMsg     = "No channel posts from the last week found to summarize."
+	draftUsageMsg         = "Usage: /draft @channel <topic of the post>"
+	draftNotAdminMsg      = "You need to be an admin of that channel to draft posts for it."
+	draftChannelErrMsg    = "I couldn't find that channel. Make sure I'm an admin there and it has posted at least once since I joined."
+	draftStatusPending    = "pending"
+	draftStatusPublished  = "published"
+	draftStatusDiscarded  = "discarded"
+	draftPublishedSuffix  = "\n\n✅ Published to the channel."
+	draftDiscardedSuffix  = "\n\n🗑 Draft discarded."
+	draftNotFoundCallback = "This draft is no longer available."
+)
+
+// Channel is a channel the bot has seen posts from, stored in MongoDB
+type Channel struct {
+	ChatID    int64     `bson:"chat_id"`
+	Title     string    `bson:"title"`
+	Username  string    `bson:"username"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// ChannelDraft is an AI-suggested channel post awaiting an admin's decision
+type ChannelDraft struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	ChannelID int64              `bson:"channel_id"`
+	UserID    int64              `bson:"user_id"`
+	Topic     string             `bson:"topic"`
+	Text      string             `bson:"text"`
+	Status    string             `bson:"status"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+func (bs *BotService) handleChannelPost(post *tgbotapi.Message) {
+	if bs.historyEnabled(post.Chat.ID) {
+		bs.storeMessage(post)
+	}
+	bs.registerChannel(post.Chat)
+}
+
+func (bs *BotService) registerChannel(chat *tgbotapi.Chat) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := bs.db.Collection("channels").UpdateOne(
+		ctx,
+		bson.M{"chat_id": chat.ID},
+		bson.M{"$set": Channel{
+			ChatID:    chat.ID,
+			Title:     chat.Title,
+			Username:  chat.UserName,
+			UpdatedAt: time.Now(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("Error registering channel %d: %v", chat.ID, err)
+	}
+}
+
+// handleChannelDigest summarizes the last week of posts of the channel linked to a discussion group
+func (bs *BotService) handleChannelDigest(msg *tgbotapi.Message) {
+	chat, err := bs.api.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: msg.Chat.ID}})
+	if err != nil {
+		log.Printf("failed to get chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if chat.LinkedChatID == 0 {
+		bs.Reply(msg, notLinkedChannelMsg)
+		return
+	}
+
+	posts, err := bs.fetchMessagesSinceFromDB(chat.LinkedChatID, time.Now().Add(-channelDigestWindow), maxMessagesToFetch)
+	if err != nil {
+		bs.Reply(msg, "Failed to fetch channel posts: "+err.Error())
+		return
+	}
+	if len(posts) == 0 {
+		bs.Reply(msg, noChannelPostsMsg)
+		return
+	}
+
+	prompt := fmt.Sprintf(`Below are the %d posts published in a Telegram channel during the last week. Write a digest for the members of the channel's discussion group:
+
+%s
+
+Digest instructions:
+1. Group related posts into a few short themes
+2. Mention the most important announcements first
+3. Keep it brief (5-8 sentences maximum)
+4. Format the digest in plain text (no markdown)
+5. Response language: Same as the channel posts`, len(posts), untrusted("channel posts", strings.Join(posts, "\n")))
+
+	ctx, cancel := context.WithTimeout(bs.billedContext(msg), 90*time.Second)
+	defer cancel()
+
+	digest, err := bs.generate(ctx, prompt)
+	if err != nil {
+		log.Printf("gemini channel digest error: %v", err)
+		bs.Reply(msg, "I couldn't generate a digest due to an error. Please try again later.")
+		return
+	}
+	bs.Reply(msg, digest)
+}
+
+func (bs *BotService) handleDraftCommand(msg *tgbotapi.Message) {
+	channelRef, topic, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+	topic = strings.TrimSpace(topic)
+	if channelRef == "" || topic == "" {
+		bs.Reply(msg, draftUsageMsg+bs.listAdminChannels(msg.From.ID))
+		return
+	}
+
+	channel, err := bs.resolveChannel(channelRef)
+	if err != nil {
+		log.Printf("failed to resolve channel %q: %v", channelRef, err)
+		bs.Reply(msg, draftChannelErrMsg)
+		return
+	}
+
+	if !bs.isChatAdmin(channel.ID, msg.From.ID) {
+		bs.Reply(msg, draftNotAdminMsg)
+		return
+	}
+
+	text, err := bs.generateChannelDraft(bs.billedContext(msg), channel.ID, topic)
+	if err != nil {
+		log.Printf("gemini draft error: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	draft := ChannelDraft{
+		ChannelID: channel.ID,
+		UserID:    msg.From.ID,
+		Topic:     topic,
+		Text:      text,
+		Status:    draftStatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := bs.db.Collection("channel_drafts").InsertOne(ctx, draft)
+	if err != nil {
+		log.Printf("Error storing channel draft: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	draft.ID = res.InsertedID.(primitive.ObjectID)
+
+	response := tgbotapi.NewMessage(msg.Chat.ID, draft.Text)
+	response.ReplyMarkup = draftKeyboard(draft.ID)
+	if _, err := bs.api.Send(response); err != nil {
+		log.Printf("failed to send channel draft: %v", err)
+	}
+}
+
+// listAdminChannels returns a hint listing the known channels the user administers
+func (bs *BotService) listAdminChannels(userID int64) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := bs.db.Collection("channels").Find(ctx, bson.M{})
+	if err != nil {
+		log.Printf("Error listing channels: %v", err)
+		return ""
+	}
+	defer cursor.Close(ctx)
+
+	var channels []Channel
+	if err := cursor.All(ctx, &channels); err != nil {
+		log.Printf("Error decoding channels: %v", err)
+		return ""
+	}
+
+	var lines []string
+	for _, channel := range channels {
+		if !bs.isChatAdmin(channel.ChatID, userID) {
+			continue
+		}
+		ref := strconv.FormatInt(channel.ChatID, 10)
+		if channel.Username != "" {
+			ref = "@" + channel.Username
+		}
+		lines = append(lines, fmt.Sprintf("- %s (%s)", channel.Title, ref))
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\n\nChannels you can draft for:\n" + strings.Join(lines, "\n")
+}
+
+// resolveChannel looks up a channel by @username or numeric chat ID
+func (bs *BotService) resolveChannel(ref string) (tgbotapi.Chat, error) {
+	config := tgbotapi.ChatConfig{SuperGroupUsername: ref}
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		config = tgbotapi.ChatConfig{ChatID: id}
+	} else if !strings.HasPrefix(ref, "@") {
+		config.SuperGroupUsername = "@" + ref
+	}
+
+	chat, err := bs.api.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: config})
+	if err != nil {
+		return chat, err
+	}
+	if !chat.IsChannel() {
+		return chat, errors.New("chat is not a channel")
+	}
+	return chat, nil
+}
+
+func (bs *BotService) generateChannelDraft(ctx context.Context, channelID int64, topic string) (string, error) {
+	// Recent posts give the model a feel for the channel's voice
+	recent, err := bs.fetchMessagesFromDB(channelID, draftStyleSampleSize)
+	if err != nil {
+		log.Printf("failed to fetch channel posts for draft style: %v", err)
+	}
+
+	prompt := fmt.Sprintf(`You are helping the admin of a Telegram channel write a new post about: "%s"
+
+Recent posts of the channel, to match its tone and style:
+%s
+
+Post instructions:
+1. Write only the post itself, ready to publish
+2. Match the tone, length and language of the recent posts
+3. Do not use markdown formatting
+4. If there are no recent posts, write a short friendly post in the language of the topic`, topic, strings.Join(recent, "\n"))
+
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	return bs.generate(ctx, prompt)
+}
+
+func draftKeyboard(id primitive.ObjectID) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📢 Publish", "draft:publish:"+id.Hex()),
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Regenerate", "draft:regen:"+id.Hex()),
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Discard", "draft:discard:"+id.Hex()),
+		),
+	)
+}
+
+func (bs *BotService) handleDraftCallback(cb *tgbotapi.CallbackQuery) {
+	parts := strings.Split(cb.Data, ":")
+	if len(parts) != 3 || cb.Message == nil {
+		bs.answerCallback(cb.ID, "")
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(parts[2])
+	if err != nil {
+		bs.answerCallback(cb.ID, draftNotFoundCallback)
+		return
+	}
+
+	drafts := bs.db.Collection("channel_drafts")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var draft ChannelDraft
+	err = drafts.FindOne(ctx, bson.M{"_id": id, "user_id": cb.From.ID, "status": draftStatusPending}).Decode(&draft)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("Error loading channel draft: %v", err)
+		}
+		bs.answerCallback(cb.ID, draftNotFoundCallback)
+		return
+	}
+
+	chatID, messageID := cb.Message.Chat.ID, cb.Message.MessageID
+
+	switch parts[1] {
+	case "publish":
+		if _, err := bs.api.Send(tgbotapi.NewMessage(draft.ChannelID, draft.Text)); err != nil {
+			log.Printf("failed to publish channel draft: %v", err)
+			bs.answerCallback(cb.ID, "I couldn't post to the channel. Am I still an admin there?")
+			return
+		}
+		bs.setDraftStatus(id, draftStatusPublished)
+		bs.editMessageText(chatID, messageID, draft.Text+draftPublishedSuffix)
+		bs.answerCallback(cb.ID, "Published!")
+	case "regen":
+		// The admin pressing the button pays, like for the first draft
+		billed := bs.billedContext(&tgbotapi.Message{MessageID: messageID, Chat: cb.Message.Chat, From: cb.From})
+		text, err := bs.generateChannelDraft(billed, draft.ChannelID, draft.Topic)
+		if err != nil {
+			log.Printf("gemini draft error: %v", err)
+			bs.answerCallback(cb.ID, responseErrorMsg)
+			return
+		}
+		if _, err := drafts.UpdateByID(ctx, id, bson.M{"$set": bson.M{"text": text}}); err != nil {
+			log.Printf("Error updating channel draft: %v", err)
+		}
+		edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, draftKeyboard(id))
+		if _, err := bs.api.Send(edit); err != nil {
+			log.Printf("failed to edit channel draft: %v", err)
+		}
+		bs.answerCallback(cb.ID, "")
+	case "discard":
+		bs.setDraftStatus(id, draftStatusDiscarded)
+		bs.editMessageText(chatID, messageID, draft.Text+draftDiscardedSuffix)
+		bs.answerCallback(cb.ID, "")
+	default:
+		bs.answerCallback(cb.ID, "")
+	}
+}
+
+func (bs *BotService) setDraftStatus(id primitive.ObjectID, status string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := bs.db.Collection("channel_drafts").UpdateByID(ctx, id, bson.M{"$set": bson.M{"status": status}}); err != nil {
+		log.Printf("Error updating channel draft status: %v", err)
+	}
+}
+
+func (bs *BotService) editMessageText(chatID int64, messageID int, text string) {
+	if _, err := bs.api.Send(tgbotapi.NewEditMessageText(chatID, messageID, text)); err != nil {
+		log.Printf("failed to edit message: %v", err)
+	}
+}
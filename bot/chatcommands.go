@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const chatCommandDisabledMsg = "/%s is disabled by this chat's admins."
+
+// alwaysEnabled are the commands a chat can't turn off, so help and the way back stay available
+var alwaysEnabled = []string{"start", "help", "disable", "enable", "enabled"}
+
+// disabledInChat reports whether the chat's admins turned a command, or the command it is an alias of, off
+// with /disable
+func (bs *BotService) disabledInChat(chatID int64, name string) bool {
+	if chatID > 0 {
+		return false
+	}
+	return slices.Contains(bs.store.ChatSettings(chatID).DisabledCommands, bs.mainCommand(name))
+}
+
+// mainCommand is the name of the command an alias like /ai stands for, or name itself
+func (bs *BotService) mainCommand(name string) string {
+	if cmd, ok := bs.commands[name]; ok && cmd.AliasOf != "" {
+		return cmd.AliasOf
+	}
+	return name
+}
+
+// commandNames parses the command names of /disable and /enable, with or without "/", aliases standing for
+// their command; unknown lists the names of no command
+func (bs *BotService) commandNames(args string) (names, unknown []string) {
+	for _, field := range strings.Fields(strings.ReplaceAll(args, ",", " ")) {
+		name := bs.mainCommand(strings.ToLower(strings.TrimPrefix(field, "/")))
+		if cmd, ok := bs.commands[name]; !ok || cmd.Hidden {
+			unknown = append(unknown, field)
+		} else if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names, unknown
+}
+
+func (bs *BotService) handleDisableCommands(msg *tgbotapi.Message) {
+	names, unknown := bs.commandNames(msg.CommandArguments())
+	if len(unknown) > 0 {
+		bs.Reply(msg, fmt.Sprintf("There's no %s command. See /enabled for the commands of this chat.", strings.Join(unknown, ", ")))
+		return
+	}
+	for _, name := range names {
+		if slices.Contains(alwaysEnabled, name) {
+			bs.Reply(msg, fmt.Sprintf("/%s can't be turned off.", name))
+			return
+		}
+	}
+
+	if err := bs.store.AddToChatList(msg.Chat.ID, "disabled_commands", names...); err != nil {
+		log.Printf("Error disabling commands in chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.publishChatMenus(msg.Chat.ID, bs.store.ChatSettings(msg.Chat.ID).DisabledCommands)
+	bs.Reply(msg, fmt.Sprintf("🚫 Turned off /%s in this chat. /enable turns them back on.", strings.Join(names, ", /")))
+}
+
+func (bs *BotService) handleEnableCommands(msg *tgbotapi.Message) {
+	names, unknown := bs.commandNames(msg.CommandArguments())
+	if len(unknown) > 0 {
+		bs.Reply(msg, fmt.Sprintf("There's no %s command. See /enabled for the commands of this chat.", strings.Join(unknown, ", ")))
+		return
+	}
+
+	if err := bs.store.RemoveFromChatList(msg.Chat.ID, "disabled_commands", names...); err != nil {
+		log.Printf("Error enabling commands in chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.publishChatMenus(msg.Chat.ID, bs.store.ChatSettings(msg.Chat.ID).DisabledCommands)
+	bs.Reply(msg, fmt.Sprintf("✅ /%s is on in this chat.", strings.Join(names, ", /")))
+}
+
+// handleEnabledCommands shows which commands the chat's admins left on and turned off
+func (bs *BotService) handleEnabledCommands(msg *tgbotapi.Message) {
+	disabled := bs.store.ChatSettings(msg.Chat.ID).DisabledCommands
+	var on, off []string
+	for _, cmd := range bs.commandList {
+		// Commands off for the whole bot or only usable by the owner aren't the chat's to choose
+		if cmd.Hidden || cmd.Role == Owner || cmd.Chats == PrivateChats || bs.commandDisabled(cmd.Name) {
+			continue
+		}
+		if slices.Contains(disabled, cmd.Name) {
+			off = append(off, "/"+cmd.Name)
+		} else {
+			on = append(on, "/"+cmd.Name)
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "✅ On: %s", strings.Join(on, " "))
+	if len(off) > 0 {
+		fmt.Fprintf(&sb, "\n\n🚫 Turned off by admins: %s", strings.Join(off, " "))
+	}
+	sb.WriteString("\n\nAdmins turn commands off with /disable <command> and back on with /enable <command>.")
+	bs.Reply(msg, sb.String())
+}
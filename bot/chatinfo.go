@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// chatInfoTTL is how long chat metadata is reused; title and member changes refresh it sooner
+const chatInfoTTL = 6 * time.Hour
+
+// chatInfo is the chat metadata given to prompts and shown on the dashboard
+type chatInfo struct {
+	Title       string
+	Description string
+	// MemberCount is 0 when unknown
+	MemberCount int
+	// SlowModeDelay is the seconds members must wait between messages, 0 without slow mode
+	SlowModeDelay int
+	fetched       time.Time
+}
+
+// chatInfo returns the cached metadata of a chat, fetching it from the Bot API when missing or stale
+func (bs *BotService) chatInfo(chatID int64) chatInfo {
+	if cached, ok := bs.chatInfos.Load(chatID); ok {
+		if info := cached.(chatInfo); time.Since(info.fetched) < chatInfoTTL {
+			return info
+		}
+	}
+
+	info := chatInfo{fetched: time.Now()}
+	chat, err := bs.api.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}})
+	if err != nil {
+		// Cache the failure too, so a chat the bot left isn't looked up on every call
+		log.Printf("failed to get chat %d: %v", chatID, err)
+		bs.chatInfos.Store(chatID, info)
+		return info
+	}
+	info.Title = chatDisplayName(chat)
+	info.Description = chat.Description
+	info.SlowModeDelay = chat.SlowModeDelay
+
+	if !chat.IsPrivate() {
+		count, err := bs.api.GetChatMembersCount(tgbotapi.ChatMemberCountConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}})
+		if err != nil {
+			log.Printf("failed to get member count of chat %d: %v", chatID, err)
+		}
+		info.MemberCount = count
+	}
+
+	bs.chatInfos.Store(chatID, info)
+	return info
+}
+
+// refreshChatInfo drops the cached metadata when a service message shows it changed
+func (bs *BotService) refreshChatInfo(msg *tgbotapi.Message) {
+	if msg.NewChatTitle != "" || len(msg.NewChatMembers) > 0 || msg.LeftChatMember != nil {
+		bs.chatInfos.Delete(msg.Chat.ID)
+	}
+}
+
+// chatContext describes a group or channel for prompts, e.g. `You are assisting the "Gophers Berlin" Telegram group (120 members).`;
+// it is empty for private chats and chats without a title
+func (bs *BotService) chatContext(chat *tgbotapi.Chat) string {
+	if chat == nil || chat.IsPrivate() {
+		return ""
+	}
+	info := bs.chatInfo(chat.ID)
+	if info.Title == "" {
+		return ""
+	}
+
+	kind := "group"
+	if chat.IsChannel() {
+		kind = "channel"
+	}
+	context := fmt.Sprintf("You are assisting the %q Telegram %s", info.Title, kind)
+	if info.MemberCount > 0 {
+		context += fmt.Sprintf(" (%d members)", info.MemberCount)
+	}
+	context += "."
+	if description := strings.TrimSpace(info.Description); description != "" {
+		context += fmt.Sprintf(" Its description: %q.", truncateRunes(description, 300))
+	}
+	return context
+}
+
+// chatDisplayName is a chat's title, or the name or @username of a private chat
+func chatDisplayName(chat tgbotapi.Chat) string {
+	title := chat.Title
+	if title == "" {
+		title = strings.TrimSpace(chat.FirstName + " " + chat.LastName)
+	}
+	if title == "" && chat.UserName != "" {
+		title = "@" + chat.UserName
+	}
+	return title
+}
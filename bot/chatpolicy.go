@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+const (
+	farewellMsg        = "👋 I'm leaving this group because %s. Thanks for having me!"
+	ownerChatLeftMsg   = "🚪 Left %q (%d) because %s."
+	policyNotAllowed   = "this bot isn't set up to serve it"
+	policyFewMembers   = "it has fewer than %d members"
+	policyInactiveDays = "nobody has talked here in %d days"
+)
+
+// leaveReason checks a group against the chat policies: LEAVE_UNAUTHORIZED_CHATS with ALLOWED_CHAT_IDS,
+// AUTO_LEAVE_MIN_MEMBERS and AUTO_LEAVE_DAYS. It returns why the bot should leave, or "" to stay; private
+// chats, channels and the reconciliation chat are never left.
+func (bs *BotService) leaveReason(chat store.ChatRecord, now time.Time) string {
+	cfg := bs.config()
+	if (chat.Type != "group" && chat.Type != "supergroup") || chat.ChatID == cfg.ReconcileChatID {
+		return ""
+	}
+	switch {
+	case cfg.LeaveUnauthorizedChats && len(cfg.AllowedChats) > 0 && !slices.Contains(cfg.AllowedChats, chat.ChatID):
+		return policyNotAllowed
+	case slices.Contains(cfg.AllowedChats, chat.ChatID):
+		// Chats the operator allowed are kept however small or quiet
+		return ""
+	case cfg.AutoLeaveMinMembers > 0 && chat.MemberCount > 0 && chat.MemberCount < cfg.AutoLeaveMinMembers:
+		return fmt.Sprintf(policyFewMembers, cfg.AutoLeaveMinMembers)
+	case cfg.AutoLeaveDays > 0 && !chat.LastActivityAt.IsZero() && chat.LastActivityAt.Before(now.AddDate(0, 0, -cfg.AutoLeaveDays)):
+		return fmt.Sprintf(policyInactiveDays, cfg.AutoLeaveDays)
+	}
+	return ""
+}
+
+// enforceChatPolicies leaves the registered groups that break a chat policy, once an hour
+func (bs *BotService) enforceChatPolicies(now time.Time) {
+	cfg := bs.config()
+	if now.Minute() != 0 || (!cfg.LeaveUnauthorizedChats && cfg.AutoLeaveMinMembers <= 0 && cfg.AutoLeaveDays <= 0) {
+		return
+	}
+	chats, err := bs.store.Chats(false)
+	if err != nil {
+		log.Printf("Error loading the chats registry: %v", err)
+		return
+	}
+	// Each bot of the fleet leaves by its own policy, which BOTS_FILE can change
+	for _, chat := range chats {
+		for _, b := range bs.chatBots(chat) {
+			if reason := b.leaveReason(chat, now); reason != "" {
+				b.leaveChat(chat, reason)
+			}
+		}
+	}
+}
+
+// leaveChat says goodbye to a group with the reason, leaves it and tells the owner
+func (bs *BotService) leaveChat(chat store.ChatRecord, reason string) {
+	bs.send(tgbotapi.NewMessage(chat.ChatID, fmt.Sprintf(farewellMsg, reason)))
+	if _, err := bs.api.Request(tgbotapi.LeaveChatConfig{ChatID: chat.ChatID}); err != nil {
+		log.Printf("Error leaving chat %d: %v", chat.ChatID, err)
+		return
+	}
+	if err := bs.store.RecordChatLeave(chat.ChatID, bs.id); err != nil {
+		log.Printf("Error marking chat %d as left: %v", chat.ChatID, err)
+	}
+	log.Printf("Left chat %d because %s", chat.ChatID, reason)
+	if owner := bs.config().OwnerID; owner != 0 {
+		bs.sendDM(owner, dmReports, kindNotification, fmt.Sprintf(ownerChatLeftMsg, chat.Title, chat.ChatID, reason))
+	}
+}
@@ -0,0 +1,201 @@
+package bot
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+const (
+	// chatActivityFlushInterval is how often a chat's activity is written to the chats registry; service
+	// messages like title changes are written right away
+	chatActivityFlushInterval = 10 * time.Minute
+	// maxChatsListed caps the chats /chats lists
+	maxChatsListed = 30
+
+	chatsUsageMsg = `Usage:
+/chats - list the chats I'm in, most recently active first
+/chats all - include the chats I left
+/chats <chat id> - show a chat's details and activity`
+)
+
+// chatActivity is what was seen of a chat since its last registry update
+type chatActivity struct {
+	mu      sync.Mutex
+	pending int64
+	flushed time.Time
+}
+
+// recordChatActivity counts a message in the chats registry, along with the chat's title, member count and
+// settings; groups upgraded to supergroups move over to the new chat
+func (bs *BotService) recordChatActivity(msg *tgbotapi.Message) {
+	if msg.MigrateToChatID != 0 {
+		if err := bs.store.RecordChatMigration(msg.Chat.ID, msg.MigrateToChatID); err != nil {
+			log.Printf("Error moving chat %d to %d in the registry: %v", msg.Chat.ID, msg.MigrateToChatID, err)
+		}
+		return
+	}
+
+	v, _ := bs.chatActivity.LoadOrStore(msg.Chat.ID, &chatActivity{})
+	a := v.(*chatActivity)
+	a.mu.Lock()
+	a.pending++
+	changed := msg.NewChatTitle != "" || len(msg.NewChatMembers) > 0 || msg.LeftChatMember != nil
+	if !changed && time.Since(a.flushed) < chatActivityFlushInterval {
+		a.mu.Unlock()
+		return
+	}
+	messages := a.pending
+	a.pending, a.flushed = 0, time.Now()
+	a.mu.Unlock()
+
+	chat := *msg.Chat
+	at := msg.Time()
+	bs.goSafe("chat registry", func() {
+		update := store.ChatActivityUpdate{
+			BotID:    bs.id,
+			Title:    chatDisplayName(chat),
+			Type:     chat.Type,
+			Messages: messages,
+			At:       at,
+			Settings: bs.store.ChatSettings(chat.ID),
+		}
+		if !chat.IsPrivate() {
+			update.MemberCount = bs.chatInfo(chat.ID).MemberCount
+		}
+		if err := bs.store.RecordChatActivity(chat.ID, update); err != nil {
+			log.Printf("Error updating chat %d in the registry: %v", chat.ID, err)
+		}
+	})
+}
+
+// handleChats lists the chats of the registry, or shows one chat's details
+func (bs *BotService) handleChats(msg *tgbotapi.Message) {
+	arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+	if arg != "" && arg != "all" {
+		chatID, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			bs.Reply(msg, chatsUsageMsg)
+			return
+		}
+		bs.replyChatDetails(msg, chatID)
+		return
+	}
+
+	chats, err := bs.store.Chats(arg == "all")
+	if err != nil {
+		log.Printf("Error loading the chats registry: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(chats) == 0 {
+		bs.Reply(msg, "No chats registered yet.\n\n"+chatsUsageMsg)
+		return
+	}
+
+	weekAgo := time.Now().AddDate(0, 0, -7)
+	var active, left int
+	for _, c := range chats {
+		if c.LeftAt != nil {
+			left++
+		} else if c.LastActivityAt.After(weekAgo) {
+			active++
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "💬 %d chats, %d active in the last 7 days", len(chats)-left, active)
+	if left > 0 {
+		fmt.Fprintf(&sb, ", %d left", left)
+	}
+	sb.WriteString(":\n")
+	for _, c := range chats[:min(len(chats), maxChatsListed)] {
+		fmt.Fprintf(&sb, "\n%s (%s", cmp.Or(c.Title, "untitled"), c.Type)
+		if c.MemberCount > 0 {
+			fmt.Fprintf(&sb, ", %d members", c.MemberCount)
+		}
+		fmt.Fprintf(&sb, ") %d\n   %d messages, last active %s", c.ChatID, c.Messages, formatChatTime(c.LastActivityAt))
+		if c.LeftAt != nil {
+			fmt.Fprintf(&sb, ", left %s", formatChatTime(*c.LeftAt))
+		} else if c.CannotPostSince != nil {
+			sb.WriteString(", can't post")
+		}
+	}
+	if len(chats) > maxChatsListed {
+		fmt.Fprintf(&sb, "\n\n…and %d more.", len(chats)-maxChatsListed)
+	}
+	sb.WriteString("\n\n/chats <chat id> shows a chat's details.")
+	bs.Reply(msg, sb.String())
+}
+
+// replyChatDetails shows a registered chat's lifecycle, settings and the last week's activity
+func (bs *BotService) replyChatDetails(msg *tgbotapi.Message, chatID int64) {
+	chat, err := bs.store.Chat(chatID)
+	if err != nil {
+		log.Printf("Error loading chat %d from the registry: %v", chatID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if chat == nil {
+		bs.Reply(msg, "That chat isn't registered.")
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "💬 %s (%s) %d", cmp.Or(chat.Title, "untitled"), chat.Type, chat.ChatID)
+	if chat.MemberCount > 0 {
+		fmt.Fprintf(&sb, "\nMembers: %d", chat.MemberCount)
+	}
+	if !chat.JoinedAt.IsZero() {
+		fmt.Fprintf(&sb, "\nJoined: %s", formatChatTime(chat.JoinedAt))
+		if chat.AddedBy != 0 {
+			fmt.Fprintf(&sb, " (added by user %d)", chat.AddedBy)
+		}
+	}
+	if chat.LeftAt != nil {
+		fmt.Fprintf(&sb, "\nLeft: %s", formatChatTime(*chat.LeftAt))
+	}
+	if chat.CannotPostSince != nil && chat.LeftAt == nil {
+		fmt.Fprintf(&sb, "\nCan't post since: %s (replies go to DMs)", formatChatTime(*chat.CannotPostSince))
+	}
+	if chat.MigratedTo != 0 {
+		fmt.Fprintf(&sb, "\nUpgraded to supergroup %d", chat.MigratedTo)
+	}
+	fmt.Fprintf(&sb, "\nLast active: %s\nMessages seen: %d", formatChatTime(chat.LastActivityAt), chat.Messages)
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -7)
+	if activity, err := bs.store.Activity(chatID, from, to, 1); err != nil {
+		log.Printf("Error loading activity of chat %d: %v", chatID, err)
+	} else if activity.Messages > 0 {
+		fmt.Fprintf(&sb, "\nLast 7 days: %d stored messages from %d people", activity.Messages, activity.Posters)
+	}
+	if hourly, err := bs.store.AIResponsesHourly(chatID, from, to); err == nil {
+		answers := 0
+		for _, n := range hourly {
+			answers += n
+		}
+		if answers > 0 {
+			fmt.Fprintf(&sb, "\nAI answers in the last 7 days: %d", answers)
+		}
+	}
+
+	s := chat.Settings
+	fmt.Fprintf(&sb, "\n\nSettings: profile %s, style %s, history %s, grounded %s, web search %s",
+		cmp.Or(s.Profile, "default"), cmp.Or(s.Style, "default"), cmp.Or(s.History, historyOn), onOff(s.Grounded), onOff(s.WebSearch))
+	bs.Reply(msg, sb.String())
+}
+
+// formatChatTime formats a registry timestamp for /chats, "never" when unset
+func formatChatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.UTC().Format("2006-01-02 15:04 UTC")
+}
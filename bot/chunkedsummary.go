@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxRangeMessagesToFetch caps the messages of a summary of a day or week, summarized in chunks
+	maxRangeMessagesToFetch = 5000
+	// summaryChunkRunes is about what one summary request holds; longer conversations are summarized in
+	// chunks of at most maxMessagesToFetch messages and this many runes
+	summaryChunkRunes = 40000
+	// summaryMergeFanIn is how many chunk notes are merged by one request
+	summaryMergeFanIn = 8
+	// summaryWorkers is how many chunk requests run at once
+	summaryWorkers = 4
+)
+
+// needsChunking reports whether formatted messages are too many or too long for one summary request
+func needsChunking(messages []string) bool {
+	return len(messages) > maxMessagesToFetch || totalRunes(messages) > summaryChunkRunes
+}
+
+func totalRunes(lines []string) int {
+	n := 0
+	for _, l := range lines {
+		n += utf8.RuneCountInString(l) + 1
+	}
+	return n
+}
+
+// chunkMessages splits formatted messages, in order, into chunks of at most maxMessagesToFetch messages and
+// about summaryChunkRunes runes; a single longer message gets a chunk of its own
+func chunkMessages(messages []string) [][]string {
+	var chunks [][]string
+	var chunk []string
+	runes := 0
+	for _, m := range messages {
+		n := utf8.RuneCountInString(m) + 1
+		if len(chunk) > 0 && (len(chunk) == maxMessagesToFetch || runes+n > summaryChunkRunes) {
+			chunks = append(chunks, chunk)
+			chunk, runes = nil, 0
+		}
+		chunk = append(chunk, m)
+		runes += n
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// chunkedSummaryPrompt is the prompt of the summary of a conversation too long for one request: each chunk of
+// messages is summarized into notes, the notes are merged summaryMergeFanIn at a time until few enough are
+// left, and the prompt asks for the summary of those
+func (bs *BotService) chunkedSummaryPrompt(ctx context.Context, messages []string, language string) (string, error) {
+	chunks := chunkMessages(messages)
+	prompts := make([]string, len(chunks))
+	first := 1
+	for i, chunk := range chunks {
+		prompts[i] = fmt.Sprintf(`Below are messages %d to %d of %d from a Telegram chat, one part of a longer conversation that is summarized in parts:
+
+%s
+
+Write notes on this part for a summary of the whole conversation:
+1. The topics discussed and who discussed them
+2. Questions asked and the answers given
+3. Decisions made and important information shared, with dates and numbers
+4. At most 10 short plain-text bullet points, in the language of the messages`,
+			first, first+len(chunk)-1, len(messages), untrusted("chat messages", strings.Join(chunk, "\n")))
+		first += len(chunk)
+	}
+	notes, err := bs.generateAll(ctx, prompts)
+	if err != nil {
+		return "", fmt.Errorf("summarizing %d chunks: %w", len(chunks), err)
+	}
+
+	for len(notes) > summaryMergeFanIn {
+		var merges []string
+		for i := 0; i < len(notes); i += summaryMergeFanIn {
+			group := notes[i:min(i+summaryMergeFanIn, len(notes))]
+			merges = append(merges, fmt.Sprintf(`Below are notes on consecutive parts of a Telegram conversation, oldest first:
+
+%s
+
+Merge them into one set of notes on these parts: keep the topics, who discussed them, questions with their answers and decisions, combine what was repeated, at most 15 short plain-text bullet points.`,
+				untrusted("part notes", joinNotes(group))))
+		}
+		if notes, err = bs.generateAll(ctx, merges); err != nil {
+			return "", fmt.Errorf("merging chunk notes: %w", err)
+		}
+	}
+
+	return fmt.Sprintf(`Below are notes on consecutive parts of a Telegram chat of %d messages, oldest first. Please provide a concise summary of the whole conversation:
+
+%s
+
+Summary instructions:
+1. Identify the main topics discussed, and who carried them
+2. Note any questions asked and answers given
+3. Highlight any decisions made or important information shared
+4. Keep it brief, a few sentences per main topic and 10 sentences at most
+5. Format the summary in plain text (no markdown)
+6. Response language: %s`, len(messages), untrusted("part notes", joinNotes(notes)), cmp.Or(language, "the main language of the notes")), nil
+}
+
+// joinNotes numbers the notes of consecutive parts
+func joinNotes(notes []string) string {
+	parts := make([]string, len(notes))
+	for i, n := range notes {
+		parts[i] = fmt.Sprintf("Part %d:\n%s", i+1, strings.TrimSpace(n))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// generateAll runs prompts, summaryWorkers at a time, and returns their answers in order; the first error
+// fails them all
+func (bs *BotService) generateAll(ctx context.Context, prompts []string) ([]string, error) {
+	answers := make([]string, len(prompts))
+	errs := make([]error, len(prompts))
+	sem := make(chan struct{}, summaryWorkers)
+	var wg sync.WaitGroup
+	for i, prompt := range prompts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			ctx, cancel := context.WithTimeout(ctx, 90*time.Second)
+			defer cancel()
+			answers[i], errs[i] = bs.generate(ctx, prompt)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return answers, nil
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// messagesOfRunes returns n messages of the given rune length, counting the line break formatMessages joins them with
+func messagesOfRunes(n, runes int) []string {
+	return slices.Repeat([]string{strings.Repeat("é", runes-1)}, n)
+}
+
+func TestNeedsChunking(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+		want     bool
+	}{
+		{name: "none"},
+		{name: "a few", messages: messagesOfRunes(10, 80)},
+		{name: "message limit", messages: messagesOfRunes(maxMessagesToFetch, 10)},
+		{name: "one over the message limit", messages: messagesOfRunes(maxMessagesToFetch+1, 10), want: true},
+		{name: "rune limit", messages: messagesOfRunes(100, summaryChunkRunes/100)},
+		{name: "one rune over", messages: append(messagesOfRunes(100, summaryChunkRunes/100), ""), want: true},
+		{name: "one long message", messages: messagesOfRunes(1, summaryChunkRunes+1), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsChunking(tt.messages); got != tt.want {
+				t.Errorf("needsChunking(%d messages of %d runes) = %t, want %t", len(tt.messages), totalRunes(tt.messages), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+		// want are the chunk sizes
+		want []int
+	}{
+		{name: "none"},
+		{name: "one chunk", messages: messagesOfRunes(10, 80), want: []int{10}},
+		{name: "by count", messages: messagesOfRunes(2*maxMessagesToFetch+1, 10), want: []int{maxMessagesToFetch, maxMessagesToFetch, 1}},
+		{name: "by runes", messages: messagesOfRunes(150, summaryChunkRunes/100), want: []int{100, 50}},
+		{name: "long message alone", messages: append(messagesOfRunes(2, 100), messagesOfRunes(1, summaryChunkRunes+1)...), want: []int{2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			var joined []string
+			for _, chunk := range chunkMessages(tt.messages) {
+				got = append(got, len(chunk))
+				joined = append(joined, chunk...)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("chunkMessages gives chunks of %v messages, want %v", got, tt.want)
+			}
+			if !slices.Equal(joined, tt.messages) {
+				t.Error("chunkMessages changed or reordered the messages")
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package bot
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const cliUsage = `usage:
+  chatbuddy [serve]                                    run the bot
+  chatbuddy migrate                                    apply pending database migrations
+  chatbuddy export -chat <id> [-o file]                write a chat's messages as JSON lines
+  chatbuddy import [-chat <id>] <result.json>          import a chat's history from a Telegram Desktop JSON export
+  chatbuddy prune -before <date|days> [-chat <id>] [-dry-run]
+                                                       delete stored messages older than a date, like 2024-01-31 or 90d
+  chatbuddy config validate                            check the configuration and the files it names
+  chatbuddy backup                                     back up the database to BACKUP_DIR or S3
+  chatbuddy restore [-overwrite] <key|file>            restore a backup into the database
+  chatbuddy embed                                      run the embedding worker until stopped`
+
+// RunCLI runs a maintenance subcommand like "backup" or "restore" without starting the bot
+func RunCLI(cfg *Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(cliUsage)
+	}
+	if args[0] == "config" {
+		if len(args) != 2 || args[1] != "validate" {
+			return errors.New(cliUsage)
+		}
+		return validateConfig(cfg)
+	}
+
+	db, err := store.Connect(cfg.MongoURI, store.DefaultDatabase)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+	defer cancel()
+
+	switch args[0] {
+	case "migrate":
+		if err := migrate(db); err != nil {
+			return err
+		}
+		version, err := db.SchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("Database schema is at version %d", version)
+		return nil
+	case "export":
+		flags := flag.NewFlagSet("export", flag.ContinueOnError)
+		chatID := flags.Int64("chat", 0, "chat to export")
+		out := flags.String("o", "", "file to write, standard output if empty")
+		if err := flags.Parse(args[1:]); err != nil || *chatID == 0 || flags.NArg() != 0 {
+			return errors.New(cliUsage)
+		}
+		return exportChat(ctx, db, *chatID, *out)
+	case "import":
+		flags := flag.NewFlagSet("import", flag.ContinueOnError)
+		chatID := flags.Int64("chat", 0, "chat to import into, the exported one if 0")
+		if err := flags.Parse(args[1:]); err != nil || flags.NArg() != 1 {
+			return errors.New(cliUsage)
+		}
+		return importExport(ctx, db, flags.Arg(0), *chatID)
+	case "prune":
+		flags := flag.NewFlagSet("prune", flag.ContinueOnError)
+		beforeArg := flags.String("before", "", "delete messages older than this date (2024-01-31) or number of days (90d)")
+		chatID := flags.Int64("chat", 0, "only prune this chat")
+		dryRun := flags.Bool("dry-run", false, "count the messages instead of deleting them")
+		if err := flags.Parse(args[1:]); err != nil || *beforeArg == "" || flags.NArg() != 0 {
+			return errors.New(cliUsage)
+		}
+		before, err := parseCutoff(*beforeArg, time.Now())
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			filter := bson.M{"timestamp": bson.M{"$lt": before}}
+			if *chatID != 0 {
+				filter["chat_id"] = *chatID
+			}
+			n, err := db.CountMessages(filter)
+			if err != nil {
+				return err
+			}
+			log.Printf("%d messages are older than %s", n, before.Format(time.RFC3339))
+			return nil
+		}
+		n, err := db.DeleteMessagesBefore(ctx, *chatID, before)
+		if err != nil {
+			return err
+		}
+		log.Printf("Deleted %d messages older than %s; their vectors stay in the vector store until the chat is forgotten", n, before.Format(time.RFC3339))
+		return nil
+	case "backup":
+		objects := cfg.objectStore()
+		if objects == nil {
+			return errors.New(noBackupStorageMsg)
+		}
+		key, counts, err := backupDatabase(ctx, db, objects, cfg.BackupKeep)
+		if err != nil {
+			return err
+		}
+		log.Printf("Backup saved as %s: %s", key, formatBackupCounts(counts))
+		return nil
+	case "restore":
+		flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+		overwrite := flags.Bool("overwrite", false, "drop collections that already have documents instead of skipping them")
+		if err := flags.Parse(args[1:]); err != nil || flags.NArg() != 1 {
+			return errors.New(cliUsage)
+		}
+		counts, skipped, err := restoreDatabase(ctx, db, cfg.objectStore(), flags.Arg(0), *overwrite)
+		if err != nil {
+			return err
+		}
+		log.Printf("Restored %s", formatBackupCounts(counts))
+		if len(skipped) > 0 {
+			log.Printf("Skipped collections that already had documents (use -overwrite to replace them): %v", skipped)
+		}
+		return nil
+	case "embed":
+		gemini, err := llm.NewGemini(context.Background(), cfg.GeminiAPIKey, cfg.Model.Model)
+		if err != nil {
+			return err
+		}
+		defer gemini.Close()
+		vectors, err := newVectorStore(cfg, db)
+		if err != nil {
+			return err
+		}
+		runEmbeddingWorker(context.Background(), db, vectors, gemini, cfg)
+		return nil
+	default:
+		return errors.New(cliUsage)
+	}
+}
+
+// validateConfig checks what LoadConfig doesn't: that the files the configuration names parse
+func validateConfig(cfg *Config) error {
+	var errs []error
+	if _, err := loadPrompts(cfg.PromptsFile); err != nil {
+		errs = append(errs, err)
+	}
+	for _, spec := range cfg.Bots {
+		if _, err := loadPrompts(spec.PromptsFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if cfg.ScamBlocklistFile != "" {
+		if _, err := os.Stat(cfg.ScamBlocklistFile); err != nil {
+			errs = append(errs, fmt.Errorf("scam blocklist: %w", err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	log.Printf("Configuration is valid")
+	return nil
+}
+
+// exportChat writes the stored messages of a chat to path, or standard output if path is empty
+func exportChat(ctx context.Context, db *store.Store, chatID int64, path string) error {
+	w := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	n := 0
+	err := db.EachMessage(ctx, chatID, func(m store.Message) error {
+		n++
+		return enc.Encode(newAPIMessage(m))
+	})
+	if err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	log.Printf("Exported %d messages of chat %d", n, chatID)
+	return nil
+}
+
+// importExport stores the messages of a Telegram Desktop export at path in chatID, or the exported chat
+func importExport(ctx context.Context, db *store.Store, path string, chatID int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	chat, messages, err := parseTelegramExport(bufio.NewReader(f), chatID)
+	if err != nil {
+		return err
+	}
+	imported, err := importMessages(ctx, db, messages)
+	if err != nil {
+		return fmt.Errorf("imported %d messages before failing: %w", imported, err)
+	}
+	log.Printf("Imported %d messages of %q into chat %d, %d were already stored", imported, chat.Title, chat.ChatID,
+		int64(len(messages))-imported)
+	return nil
+}
+
+// parseCutoff parses the -before of prune: a date, an RFC 3339 time or a number of days before now
+func parseCutoff(s string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid -before %q, use a date like 2024-01-31 or a number of days like 90d", s)
+}
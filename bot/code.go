@@ -0,0 +1,230 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+)
+
+const (
+	codeFence = "```"
+	// maxHTMLChunk keeps each HTML message under Telegram's 4096 character limit with room for the tags
+	maxHTMLChunk = 4000
+	codeUsageMsg = "Usage: /code <question>, or reply to a message with /code to ask about its code"
+)
+
+var (
+	// codeCallRe matches calls like "parse()", "os.Getenv(" or "read_file(", but not "friend(s)"
+	codeCallRe = regexp.MustCompile(`\b\w+\(\)|\b[A-Za-z_]\w*(\.[A-Za-z_]\w*)+\(|\b[a-z]+_\w*\(|\b[a-z]+[A-Z]\w*\(`)
+	// stackTraceRe matches the shapes of Python, Java, Go and JavaScript errors and stack traces
+	stackTraceRe = regexp.MustCompile(`(?m)Traceback \(most recent call last\)|File "[^"]+", line \d+|^\s*at [\w$.<>]+ ?\([^)]*:\d+(:\d+)?\)|` +
+		`^panic: |^goroutine \d+ \[|Exception in thread "|\b\w+(Error|Exception): `)
+	// codeLineRe matches lines that look like source code
+	codeLineRe = regexp.MustCompile(`(^\s*(func|def|class|import|package|#include|const|let|var|return|public|private|SELECT|for|if)\b.*[{:;(]|[{};]\s*$|=>)`)
+	// fenceLangRe is what a language tag after a code fence may look like
+	fenceLangRe = regexp.MustCompile(`^[\w+#.-]*$`)
+)
+
+// looksLikeCode reports whether a question is about code: it has a fenced block, a function call, a stack
+// trace or a couple of lines of code. Words like "code" or "python" alone don't count, "dress code" isn't one.
+func looksLikeCode(text string) bool {
+	if strings.Contains(text, codeFence) || codeCallRe.MatchString(text) || stackTraceRe.MatchString(text) {
+		return true
+	}
+	lines := 0
+	for _, line := range strings.Split(text, "\n") {
+		if codeLineRe.MatchString(line) {
+			lines++
+		}
+	}
+	return lines >= 2
+}
+
+// codeSegment is a stretch of an answer that is either prose or a fenced code block
+type codeSegment struct {
+	Code bool
+	Lang string
+	Text string
+}
+
+// parseCodeSegments splits text at its code fences; an unclosed fence runs to the end
+func parseCodeSegments(text string) []codeSegment {
+	var segments []codeSegment
+	addProse := func(s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			segments = append(segments, codeSegment{Text: s})
+		}
+	}
+
+	for {
+		start := strings.Index(text, codeFence)
+		if start < 0 {
+			break
+		}
+		addProse(text[:start])
+
+		block, rest, _ := strings.Cut(text[start+len(codeFence):], codeFence)
+		text = rest
+		lang, code, multiline := strings.Cut(block, "\n")
+		if !multiline || !fenceLangRe.MatchString(strings.TrimSpace(lang)) {
+			lang, code = "", block
+		}
+		if code = strings.Trim(code, "\n"); code != "" {
+			segments = append(segments, codeSegment{Code: true, Lang: strings.ToLower(strings.TrimSpace(lang)), Text: code})
+		}
+	}
+	addProse(text)
+	return segments
+}
+
+// html renders part of the segment's text, code as a <pre><code> block Telegram shows monospaced and copyable
+func (s codeSegment) html(text string) string {
+	switch {
+	case !s.Code:
+		return html.EscapeString(text)
+	case s.Lang == "":
+		return "<pre>" + html.EscapeString(text) + "</pre>"
+	default:
+		return fmt.Sprintf(`<pre><code class="language-%s">%s</code></pre>`, html.EscapeString(s.Lang), html.EscapeString(text))
+	}
+}
+
+// splitEscaped splits text at line breaks into parts that are at most limit bytes once HTML escaped;
+// lines longer than that are cut between runes
+func splitEscaped(text string, limit int) []string {
+	var parts []string
+	var current string
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for len(html.EscapeString(line)) > limit {
+			cut := 0
+			for i, r := range line {
+				if len(html.EscapeString(line[:i+utf8.RuneLen(r)])) > limit {
+					break
+				}
+				cut = i + utf8.RuneLen(r)
+			}
+			if current != "" {
+				parts, current = append(parts, current), ""
+			}
+			parts, line = append(parts, line[:cut]), line[cut:]
+		}
+		if len(html.EscapeString(current+line)) > limit {
+			parts, current = append(parts, current), ""
+		}
+		current += line
+	}
+	if strings.TrimSpace(current) != "" {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// renderCodeHTML turns a model answer with code fences into Telegram HTML messages of at most limit bytes,
+// splitting long code blocks into several
+func renderCodeHTML(text string, limit int) []string {
+	// Leaves room for the <pre><code class="language-..."> tags around a part
+	const tagRoom = 100
+
+	var chunks []string
+	var current strings.Builder
+	for _, segment := range parseCodeSegments(text) {
+		for _, part := range splitEscaped(segment.Text, limit-tagRoom) {
+			piece := segment.html(strings.Trim(part, "\n"))
+			if current.Len() > 0 && current.Len()+len("\n\n")+len(piece) > limit {
+				chunks = append(chunks, current.String())
+				current.Reset()
+			}
+			if current.Len() > 0 {
+				current.WriteString("\n\n")
+			}
+			current.WriteString(piece)
+		}
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// buildCodePrompt is the prompt of a code answer, in the chat's answer style, with the messages before the
+// question when the chat asked for them with /context
+func (bs *BotService) buildCodePrompt(msg *tgbotapi.Message, question string, style answerStyle) string {
+	chatContext := bs.chatContext(msg.Chat)
+	if chatContext != "" {
+		chatContext = "\n" + chatContext
+	}
+	recent := bs.surroundingContext(msg)
+	if recent != "" {
+		recent = "\n\n" + recent
+	}
+	return fmt.Sprintf(`You are a helpful programming assistant in a Telegram chat.%s%s
+
+The user asked:
+%s
+
+Follow these response guidelines:
+1. Put all code in fenced code blocks that name the language, like %sgo
+2. Keep the explanation around the code short and in plain text, with no other markdown (no asterisks, headings or tables)
+3. Prefer complete, working snippets, but keep them as short as the question allows
+4. If the question doesn't need code, answer briefly in plain text
+5. For the explanation: %s. %s.
+Response language: %s (code and identifiers stay as they are)`,
+		chatContext, recent, untrusted("question", question), codeFence, style.Length, style.Tone, bs.responseLanguage(msg.Chat))
+}
+
+// answerCode answers a question about code, with the code blocks sent as HTML so they show up monospaced
+func (bs *BotService) answerCode(msg *tgbotapi.Message, question string) {
+	question, style := bs.questionStyle(msg.Chat.ID, question)
+	ctx, cancel := context.WithTimeout(bs.billedContext(msg), 90*time.Second)
+	defer cancel()
+	// The style's output cap is left out, it would cut code off
+	if history := bs.conversationHistory(msg); len(history) > 0 {
+		ctx = llm.WithHistory(ctx, history...)
+	}
+
+	answer, err := bs.generate(bs.premiumContext(ctx, msg.Chat), bs.buildCodePrompt(msg, question, style))
+	if err != nil {
+		log.Printf("gemini code answer error: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.replyWithCode(msg, answer)
+}
+
+// replyWithCode sends an answer with code fences rendered as HTML, falling back to plain text
+// if Telegram rejects the markup
+func (bs *BotService) replyWithCode(msg *tgbotapi.Message, answer string) {
+	for i, chunk := range renderCodeHTML(answer, maxHTMLChunk) {
+		response := tgbotapi.NewMessage(msg.Chat.ID, chunk)
+		response.ParseMode = tgbotapi.ModeHTML
+		if i == 0 {
+			response.ReplyToMessageID = msg.MessageID
+		}
+		if sent := bs.sendAs(kindAnswer, response); sent.MessageID == 0 {
+			if i == 0 {
+				bs.Reply(msg, answer)
+			}
+			return
+		}
+	}
+}
+
+func (bs *BotService) handleCode(msg *tgbotapi.Message) {
+	question := strings.TrimSpace(msg.CommandArguments())
+	if reply := msg.ReplyToMessage; reply != nil && reply.Text != "" {
+		question = strings.TrimSpace(question + "\n\n" + reply.Text)
+	}
+	if question == "" {
+		bs.Reply(msg, codeUsageMsg)
+		return
+	}
+	bs.answerCode(msg, question)
+}
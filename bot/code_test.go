@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLooksLikeCode(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want bool
+	}{
+		{name: "fence", text: "why does this fail?\n```\nx = 1\n```", want: true},
+		{name: "empty call", text: "what does parse() return?", want: true},
+		{name: "method call", text: "why does os.Getenv( give me an empty string", want: true},
+		{name: "snake case call", text: "read_file(path) throws on Windows", want: true},
+		{name: "camel case call", text: "document.getElementById returns null, getElementById(id) I mean", want: true},
+		{name: "python traceback", text: "Traceback (most recent call last):\n  File \"app.py\", line 3, in <module>", want: true},
+		{name: "go panic", text: "panic: runtime error: index out of range", want: true},
+		{name: "java stack trace", text: "it crashes\n    at com.example.Main.run(Main.java:42)", want: true},
+		{name: "error type", text: "I keep getting TypeError: x is undefined", want: true},
+		{name: "lines of code", text: "if (x > 1) {\n  return y;\n}", want: true},
+
+		{name: "dress code", text: "what's the dress code for friday?"},
+		{name: "language name", text: "is python or rust easier to learn?"},
+		{name: "file format", text: "send me the json export later"},
+		{name: "parenthesized plural", text: "bring your friend(s) to the party"},
+		{name: "aside in parentheses", text: "we met (briefly) at the conference"},
+		{name: "plain question", text: "what time is the meetup?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := looksLikeCode(tt.text); got != tt.want {
+				t.Errorf("looksLikeCode(%q) = %t, want %t", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderCodeHTML(t *testing.T) {
+	fiveLines := "<pre>" + strings.TrimSuffix(strings.Repeat("0123456789\n", 5), "\n") + "</pre>"
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{name: "prose", text: "Use a map.", limit: 4000, want: []string{"Use a map."}},
+		{
+			name:  "code with a language",
+			text:  "Like this:\n```go\nm := map[string]int{}\n```\nDone.",
+			limit: 4000,
+			want:  []string{"Like this:\n\n<pre><code class=\"language-go\">m := map[string]int{}</code></pre>\n\nDone."},
+		},
+		{name: "code without a language", text: "```\nls -la\n```", limit: 4000, want: []string{"<pre>ls -la</pre>"}},
+		{
+			name:  "escaping in code",
+			text:  "```html\n<a href=\"x\">&copy;</a>\n```",
+			limit: 4000,
+			want:  []string{"<pre><code class=\"language-html\">&lt;a href=&#34;x&#34;&gt;&amp;copy;&lt;/a&gt;</code></pre>"},
+		},
+		{name: "escaping in prose", text: "Check that a < b && b > c", limit: 4000, want: []string{"Check that a &lt; b &amp;&amp; b &gt; c"}},
+		{name: "unterminated fence", text: "Try:\n```python\nprint(1 < 2)", limit: 4000, want: []string{"Try:\n\n<pre><code class=\"language-python\">print(1 &lt; 2)</code></pre>"}},
+		{name: "inline fence without a language", text: "```x < y```", limit: 4000, want: []string{"<pre>x &lt; y</pre>"}},
+		{name: "empty block", text: "Nothing:\n```\n```", limit: 4000, want: []string{"Nothing:"}},
+		{
+			name:  "long block split into closed blocks",
+			text:  "```\n" + strings.Repeat("0123456789\n", 20) + "```",
+			limit: 160,
+			want:  []string{fiveLines + "\n\n" + fiveLines, fiveLines + "\n\n" + fiveLines},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := renderCodeHTML(tt.text, tt.limit)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("renderCodeHTML(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			for _, chunk := range got {
+				if len(chunk) > tt.limit || strings.Count(chunk, "<pre>")+strings.Count(chunk, "<pre><code") != strings.Count(chunk, "</pre>")+strings.Count(chunk, "</code></pre>") {
+					t.Errorf("renderCodeHTML(%q) has the chunk %q, want at most %d bytes with closed blocks", tt.text, chunk, tt.limit)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// commandMenu is one scope of the Telegram "/" menu and which commands it lists: those usable in chats by role
+type commandMenu struct {
+	scope tgbotapi.BotCommandScope
+	chats ChatScope
+	role  Role
+}
+
+// loadCommandTranslations reads the command menu descriptions of COMMAND_TRANSLATIONS_FILE, a JSON object
+// of language codes to command names to descriptions, like {"de": {"summary": "Fasse die letzten
+// Nachrichten zusammen"}}; an empty path means none
+func loadCommandTranslations(path string) (map[string]map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command translations file: %w", err)
+	}
+	var translations map[string]map[string]string
+	if err := json.Unmarshal(data, &translations); err != nil {
+		return nil, fmt.Errorf("invalid command translations file %s: %w", path, err)
+	}
+	return translations, nil
+}
+
+// menuCommands lists the commands of a menu, described in lang when translated, leaving out disabled ones
+func (bs *BotService) menuCommands(m commandMenu, lang string, disabled []string) []tgbotapi.BotCommand {
+	translated := bs.config().CommandTranslations[lang]
+	var commands []tgbotapi.BotCommand
+	for _, cmd := range bs.commandList {
+		if cmd.Hidden || cmd.Description == "" || (cmd.Chats != AnyChat && cmd.Chats != m.chats) {
+			continue
+		}
+		if cmd.Role > m.role || bs.commandDisabled(cmd.Name) || slices.Contains(disabled, cmd.Name) {
+			continue
+		}
+		description := cmd.Description
+		if t := translated[cmd.Name]; t != "" {
+			description = t
+		}
+		commands = append(commands, tgbotapi.BotCommand{Command: cmd.Name, Description: truncateRunes(description, 256)})
+	}
+	return commands
+}
+
+// publishMenu sets the commands of a menu, for users of any language and for each translated language
+func (bs *BotService) publishMenu(m commandMenu, disabled []string) {
+	if _, err := bs.api.Request(tgbotapi.NewSetMyCommandsWithScope(m.scope, bs.menuCommands(m, "", disabled)...)); err != nil {
+		log.Printf("failed to register bot commands: %v", err)
+	}
+	for _, lang := range bs.menuLanguages() {
+		config := tgbotapi.NewSetMyCommandsWithScopeAndLanguage(m.scope, lang, bs.menuCommands(m, lang, disabled)...)
+		if _, err := bs.api.Request(config); err != nil {
+			log.Printf("failed to register bot commands in %s: %v", lang, err)
+		}
+	}
+}
+
+// publishChatMenus gives a group menus without the commands its admins turned off, or, with none off, removes
+// them so the group gets the menus of all groups again. Telegram prefers a chat's own menus to those of its
+// chat type in any language, so they are set in every translated language too.
+func (bs *BotService) publishChatMenus(chatID int64, disabled []string) {
+	menus := []commandMenu{
+		{tgbotapi.NewBotCommandScopeChat(chatID), GroupChats, Member},
+		{tgbotapi.NewBotCommandScopeChatAdministrators(chatID), GroupChats, ChatAdmin},
+	}
+	for _, m := range menus {
+		if len(disabled) > 0 {
+			bs.publishMenu(m, disabled)
+			continue
+		}
+		if _, err := bs.api.Request(tgbotapi.NewDeleteMyCommandsWithScope(m.scope)); err != nil {
+			log.Printf("failed to remove the command menu of chat %d: %v", chatID, err)
+		}
+		for _, lang := range bs.menuLanguages() {
+			if _, err := bs.api.Request(tgbotapi.NewDeleteMyCommandsWithScopeAndLanguage(m.scope, lang)); err != nil {
+				log.Printf("failed to remove the %s command menu of chat %d: %v", lang, chatID, err)
+			}
+		}
+	}
+}
+
+// menuLanguages are the language codes with command translations, sorted
+func (bs *BotService) menuLanguages() []string {
+	var langs []string
+	for lang := range bs.config().CommandTranslations {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
@@ -0,0 +1,436 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	helpIntroMsg = `How to use me:
+- Mention me like %s with a question or message, reply to one of my messages, or use /ask <question>
+- I'll reply with some AI magic!
+`
+	helpFooterMsg = `- Example: '%s What's the weather like?'
+- /help <command> explains a command
+the creator❤️ @sg_milad`
+	commandDisabledMsg = "This command is turned off."
+	startMsg           = "Hello! I'm ChatBuddy, your AI companion. Mention me with %s to chat, or use /help for more info!"
+	askHelp            = `/ask <question> (or /ai) answers like a mention does, for when typing my name is awkward.
+Reply to a message with /ask to ask about it, like "/ask is this right?"`
+)
+
+// ChatScope is where a command can be used
+type ChatScope int
+
+const (
+	AnyChat ChatScope = iota
+	GroupChats
+	PrivateChats
+)
+
+// Command describes a bot command for routing, /help and the Telegram command menu
+type Command struct {
+	Name string
+	// Usage describes the arguments, e.g. "[today|yesterday]"
+	Usage       string
+	Description string
+	// Help is the longer explanation /help <command> shows, with examples
+	Help string
+	// MinArgs is the number of required arguments; with fewer the usage is sent back
+	MinArgs int
+	// Role is the least role allowed to run the command
+	Role  Role
+	Chats ChatScope
+	// Hidden commands work but are left out of /help and the command menu
+	Hidden bool
+	// AliasOf names the command this one is another name for; /disable and /enable turn both off and on
+	AliasOf string
+	// Archive marks commands that read stored messages or the AI log; STRICT_PRIVACY turns them off
+	Archive bool
+	// AI marks commands that call the model; they get the maintenance notice while maintenance mode is on
+	AI bool
+	// Async runs the handler in a goroutine, for commands that call the model; Ack is sent first if set
+	Async   bool
+	Ack     string
+	Handler HandlerFunc
+}
+
+// builtinCommands returns the commands shipped with the bot, in /help order
+func builtinCommands() []Command {
+	return []Command{
+		{Name: "start", Hidden: true, Handler: (*BotService).handleStart},
+		{Name: "help", Usage: "[command]", Description: "Show what I can do, or explain a command", Handler: (*BotService).handleHelp},
+		{Name: "ask", Usage: "<question>", Description: "Ask me something, like mentioning me does",
+			Help: askHelp, MinArgs: 1, AI: true, Async: true, Handler: (*BotService).handleQuery},
+		{Name: "ai", Usage: "<question>", Description: "Same as /ask", MinArgs: 1, Hidden: true, AliasOf: "ask", AI: true,
+			Async: true, Handler: (*BotService).handleQuery},
+		{Name: "balance", Usage: "[buy [chat]]", Description: "Show or top up your credits and this chat's",
+			Handler: (*BotService).handleBalance},
+		{Name: "referrals", Description: "Get your invite links and see your referral rewards", Handler: (*BotService).handleReferrals},
+		{Name: "premium", Usage: "[buy [chat]]", Description: "Show or buy the premium tier with Telegram Stars",
+			Handler: (*BotService).handlePremium},
+		{Name: "digests", Usage: "[off]", Description: "List or stop the group digests you get in DMs", Chats: PrivateChats,
+			Handler: (*BotService).handleDigests},
+		{Name: "dnd", Usage: "[HH:MM-HH:MM|off]", Description: "Hold back my DMs during your quiet hours",
+			Handler: (*BotService).handleDND},
+		{Name: "notify", Usage: "[<digests|alerts|reports> on|off]", Description: "Choose which DMs you get from me",
+			Handler: (*BotService).handleNotify},
+		{Name: "summary", Usage: "[today|yesterday|this week|last week]", Description: "Summarize the latest 200 messages, or a whole day or week",
+			AI: true, Async: true, Ack: fetchingMessagesMsg, Archive: true, Handler: (*BotService).handleSummaryRequest},
+		{Name: "import", Description: "Reply to a Telegram Desktop export in a DM to import a group's history",
+			Chats: PrivateChats, Async: true, Archive: true, Handler: (*BotService).handleImport},
+		{Name: "transcript", Usage: "[today|yesterday|this week|last week] [md|html]", Description: "Get the stored messages as a Markdown or HTML transcript",
+			Help: transcriptHelp, Async: true, Archive: true, Handler: (*BotService).handleTranscript},
+		{Name: "summarylang", Usage: "[auto|split|<language code>]", Description: "Choose the language of summaries in a chat with several languages",
+			Role: ChatAdmin, Handler: (*BotService).handleSummaryLang},
+		{Name: "glossary", Usage: "[build|set <term> = <meaning>|remove <term>]", Description: "The chat's jargon and inside jokes, which I understand in answers",
+			Chats: GroupChats, Async: true, Archive: true, Handler: (*BotService).handleGlossary},
+		{Name: "summarize", Description: "Reply to a message to summarize the discussion that followed it",
+			AI: true, Async: true, Archive: true, Handler: (*BotService).handleSummarize},
+		{Name: "search", Usage: "<query>", Description: "Find messages by meaning, or by words until they're embedded",
+			Async: true, Archive: true, Handler: (*BotService).handleSearch},
+		{Name: "code", Usage: "<question>", Description: "Ask a programming question and get copyable code back",
+			AI: true, Async: true, Handler: (*BotService).handleCode},
+		{Name: "factcheck", Usage: "[claim]", Description: "Check a claim against web sources, in reply to it or inline",
+			AI: true, Async: true, Handler: (*BotService).handleFactCheck},
+		{Name: "whosaid", Usage: "<quote or paraphrase>", Description: "Find who said something like that, and when",
+			MinArgs: 1, Async: true, Archive: true, Handler: (*BotService).handleWhoSaid},
+		{Name: "topics", Usage: "[refresh]", Description: "Show what the chat has been about lately",
+			Chats: GroupChats, Async: true, Archive: true, Handler: (*BotService).handleTopics},
+		{Name: "wrapped", Usage: "[on|off]", Description: "Recap the week: top posters, highlights, topics and a story",
+			Chats: GroupChats, AI: true, Async: true, Archive: true, Handler: (*BotService).handleWrapped},
+		{Name: "activity", Usage: "[hours|ai] [days]", Description: "Chart messages per day or hour, or AI answers per day",
+			Async: true, Archive: true, Handler: (*BotService).handleActivity},
+		{Name: "wordcloud", Usage: "[days]", Description: "Draw the chat's most used words as a picture",
+			Chats: GroupChats, Async: true, Archive: true, Handler: (*BotService).handleWordCloud},
+		{Name: "whois", Usage: "@username|optout|optin", Description: "Show what someone usually talks about here, or opt out",
+			AI: true, Async: true, Archive: true, Handler: (*BotService).handleWhois},
+		{Name: "find", Usage: "#tag [#tag...]", Description: "List recent messages with the given hashtags",
+			MinArgs: 1, Async: true, Archive: true, Handler: (*BotService).handleFind},
+		{Name: "kb", Usage: "[list|remove <number>|done]", Description: "Manage the group's knowledge base from a DM",
+			Role: ChatAdmin, Handler: (*BotService).handleKnowledge},
+		{Name: "forgetmessage", Description: "Leave the replied-to message out of summaries, searches and exports",
+			Role: ChatAdmin, Chats: GroupChats, Archive: true, Handler: (*BotService).handleForgetMessage},
+		{Name: "privacy", Usage: "[history|stateless]", Description: "Choose whether I keep this group's messages for history features",
+			Role: ChatAdmin, Chats: GroupChats, Handler: (*BotService).handlePrivacy},
+		{Name: "cancel", Description: "Stop an answer I'm still working on, or reply to a question to stop that one",
+			Handler: (*BotService).handleCancel},
+		{Name: "settings", Usage: "[profile family|default]", Description: "Show this chat's settings or pick the kid-friendly family profile",
+			Role: ChatAdmin, Handler: (*BotService).handleSettings},
+		{Name: "style", Usage: "[concise|detailed|eli5|formal|sarcastic|default]", Description: "Choose how I answer here, or add !eli5 to one question",
+			Role: ChatAdmin, Handler: (*BotService).handleStyle},
+		{Name: "grounded", Usage: "[on|off]", Description: "Answer only from this chat's history and knowledge base, or say I don't know",
+			Role: ChatAdmin, Handler: (*BotService).handleGrounded},
+		{Name: "websearch", Usage: "[on|off]", Description: "Let answers search the web and cite sources",
+			Role: ChatAdmin, Handler: (*BotService).handleWebSearch},
+		{Name: "disable", Usage: "<command> [<command>...]", Description: "Turn commands off in this chat", MinArgs: 1,
+			Role: ChatAdmin, Chats: GroupChats, Handler: (*BotService).handleDisableCommands},
+		{Name: "enable", Usage: "<command> [<command>...]", Description: "Turn commands back on in this chat", MinArgs: 1,
+			Role: ChatAdmin, Chats: GroupChats, Handler: (*BotService).handleEnableCommands},
+		{Name: "enabled", Description: "List the commands that are on and off in this chat", Chats: GroupChats,
+			Handler: (*BotService).handleEnabledCommands},
+		{Name: "context", Usage: "[<1-30>|off]", Description: "Give answers the messages before a question as context",
+			Role: ChatAdmin, Chats: GroupChats, Handler: (*BotService).handleContextWindow},
+		{Name: "autotag", Usage: "[on|off]", Description: "Tag new messages with AI hashtags for /find",
+			Role: ChatAdmin, Chats: GroupChats, Archive: true, Handler: (*BotService).handleAutotag},
+		{Name: "regex", Usage: "<pattern> <text>", Description: "Test a regular expression", Help: regexHelp,
+			MinArgs: 1, Handler: (*BotService).handleRegex},
+		{Name: "json", Usage: "[pretty|minify] <json>", Description: "Check and pretty-print or minify JSON", Help: jsonHelp,
+			Handler: (*BotService).handleJSON},
+		{Name: "base64", Usage: "encode|decode <text>", Description: "Encode or decode base64", Help: base64Help,
+			Handler: (*BotService).handleBase64},
+		{Name: "hash", Usage: "[md5|sha1|sha256|sha512] <text>", Description: "Hash text", Help: hashHelp,
+			Handler: (*BotService).handleHash},
+		{Name: "uuid", Usage: "[count]", Description: "Generate random UUIDs", Help: uuidHelp, Handler: (*BotService).handleUUID},
+		{Name: "timestamp", Usage: "[unix time|date]", Description: "Convert between Unix timestamps and dates", Help: timestampHelp,
+			Handler: (*BotService).handleTimestamp},
+		{Name: "convert", Usage: "<amount> <unit> to <unit>", Description: "Convert units and currencies", Help: convertHelp,
+			MinArgs: 3, Async: true, Handler: (*BotService).handleConvert},
+		{Name: "weather", Usage: "<city>", Description: "Show the weather and forecast for a place", MinArgs: 1,
+			Async: true, Handler: (*BotService).handleWeather},
+		{Name: "wiki", Usage: "<topic>", Description: "Look something up on Wikipedia", MinArgs: 1,
+			Async: true, Handler: (*BotService).handleWiki},
+		{Name: "timezone", Usage: "[set <Area/City>|me <Area/City>]", Description: "Show or set the chat timezone or your own",
+			Handler: (*BotService).handleTimezone},
+		{Name: "channeldigest", Description: "Summarize this week's posts of the linked channel", Chats: GroupChats,
+			AI: true, Async: true, Ack: fetchingMessagesMsg, Archive: true, Handler: (*BotService).handleChannelDigest},
+		{Name: "decisions", Usage: "[list]", Description: "Extract decisions from recent messages",
+			AI: true, Async: true, Archive: true, Handler: (*BotService).handleDecisions},
+		{Name: "actionitems", Usage: "[mine]", Description: "Extract action items from recent messages",
+			AI: true, Async: true, Archive: true, Handler: (*BotService).handleActionItems},
+		{Name: "events", Usage: "[add <event>|ics|detect on|off]", Description: "Show, add or export the chat calendar",
+			AI: true, Async: true, Handler: (*BotService).handleEventsCommand},
+		{Name: "today", Description: "Show today's plans", Handler: (*BotService).handleToday},
+		{Name: "birthday", Usage: "set <day month>|remove", Description: "Save your birthday so I can greet you",
+			Handler: (*BotService).handleBirthday},
+		{Name: "birthdays", Description: "Show upcoming birthdays", Handler: (*BotService).handleBirthdays},
+		{Name: "expense", Usage: "add <amount> <what> @people|balance|settle", Description: "Split costs and see who owes whom",
+			AI: true, Async: true, Handler: (*BotService).handleExpense},
+		{Name: "todo", Usage: "[my] add <task>|list|done <n>", Description: "Shared to-do list, or your private one with my",
+			Handler: (*BotService).handleTodo},
+		{Name: "github", Usage: "link|unlink <owner/repo>|list", Description: "Get PR, issue and release notifications",
+			Handler: (*BotService).handleGitHubCommand},
+		{Name: "pr", Usage: "[owner/repo] <number>", Description: "Summarize a pull request", MinArgs: 1,
+			AI: true, Async: true, Handler: (*BotService).handlePRCommand},
+		{Name: "feed", Usage: "add <url>|list|remove <n>|digest <hour>", Description: "Follow RSS/Atom feeds with a daily digest",
+			AI: true, Async: true, Handler: (*BotService).handleFeedCommand},
+		{Name: "rules", Usage: "[question]", Description: "Show the chat rules or ask if something is allowed",
+			AI: true, Async: true, Handler: (*BotService).handleRules},
+		{Name: "draft", Usage: "@channel <topic>", Description: "Get an AI-suggested post for a channel you admin", Chats: PrivateChats,
+			Handler: (*BotService).handleDraftCommand},
+		{Name: "audit", Usage: "[n]", Description: "Show who changed settings and took admin actions here",
+			Role: ChatAdmin, Handler: (*BotService).handleAudit},
+		{Name: "usage", Usage: "[days]", Description: "Show the AI requests, tokens and latency of this chat",
+			Role: ChatAdmin, Handler: (*BotService).handleUsage},
+		{Name: "history", Usage: "[n|export|retention <days|off>]", Description: "Audit what I answered in this chat",
+			Role: ChatAdmin, Archive: true, Handler: (*BotService).handleHistory},
+		{Name: "pin", Usage: "[summaries|digests on|off]", Description: "Pin the replied-to message, or pin summaries and digests automatically",
+			Role: ChatAdmin, Chats: GroupChats, Handler: (*BotService).handlePin},
+		{Name: "pins", Usage: "[forget <n>]", Description: "List the pinned messages with a one-line summary of each",
+			Chats: GroupChats, AI: true, Async: true, Archive: true, Handler: (*BotService).handlePins},
+		{Name: "quiethours", Usage: "[HH:MM-HH:MM|off]", Description: "Hold back digests and reminders at night",
+			Role: ChatAdmin, Handler: (*BotService).handleQuietHours},
+		{Name: "sendoptions", Usage: "[<answers|digests|notifications> silent|previews on|off|default]", Description: "Choose which of my messages are silent or show link previews",
+			Role: ChatAdmin, Handler: (*BotService).handleSendOptions},
+		{Name: "setrules", Usage: "<rules>", Description: "Set the chat rules (or reply to a message with them)",
+			Role: ChatAdmin, Chats: GroupChats, Handler: (*BotService).handleSetRules},
+		{Name: "moderation", Usage: "on|off", Description: "Get alerts about likely rule violations",
+			Role: ChatAdmin, Chats: GroupChats, Handler: (*BotService).handleModerationCommand},
+		{Name: "alias", Usage: "start|anywhere|off|names|@user = <nickname>", Description: "Choose when I answer to my name, or set members' nicknames",
+			Role: ChatAdmin, Handler: (*BotService).handleAliasCommand},
+		{Name: "banword", Usage: "[<word>[, <word>]|action delete|warn]", Description: "Ban words in this chat or list them", Chats: GroupChats,
+			Role: ChatAdmin, Handler: (*BotService).handleBanWord},
+		{Name: "unbanword", Usage: "<word>[, <word>]", Description: "Allow banned words again", MinArgs: 1, Chats: GroupChats,
+			Role: ChatAdmin, Handler: (*BotService).handleUnbanWord},
+		{Name: "linkpolicy", Usage: "[off|block|allowlist|allow <domain>|disallow <domain>|invites on|off]", Description: "Control which links non-admins may post",
+			Chats: GroupChats, Role: ChatAdmin, Handler: (*BotService).handleLinkPolicy},
+		{Name: "spam", Usage: "flag|delete|off", Description: "Flag or delete repeated and cross-posted messages", Chats: GroupChats,
+			Role: ChatAdmin, Handler: (*BotService).handleSpamCommand},
+		{Name: "cooldown", Usage: "[<n>|off|default]", Description: "Limit how many AI replies I send per minute here", Chats: GroupChats,
+			Role: ChatAdmin, Handler: (*BotService).handleCooldownCommand},
+		{Name: "bots", Usage: "on|off", Description: "Choose whether I answer other bots", Chats: GroupChats,
+			Role: ChatAdmin, Handler: (*BotService).handleBotsCommand},
+		{Name: "hook", Usage: "create|revoke", Description: "Webhook URL so CI, monitoring or Zapier can post here",
+			Role: ChatAdmin, Handler: (*BotService).handleHookCommand},
+		{Name: "broadcast", Usage: "<announcement>", Description: "Send an announcement to every chat", MinArgs: 1,
+			Role: Owner, Chats: PrivateChats, Async: true, Handler: (*BotService).handleBroadcast},
+		{Name: "chats", Usage: "[all|<chat id>]", Description: "List the chats I'm in with their activity",
+			Role: Owner, Handler: (*BotService).handleChats},
+		{Name: "maintenance", Usage: "on [notice]|off", Description: "Pause AI features with a notice during planned downtime",
+			Role: Owner, Handler: (*BotService).handleMaintenance},
+		{Name: "backup", Usage: "[list]", Description: "Back up the database now, or list backups",
+			Role: Owner, Handler: (*BotService).handleBackup},
+		{Name: "embeddings", Description: "Show how many messages are embedded for semantic search",
+			Role: Owner, Archive: true, Handler: (*BotService).handleEmbeddings},
+		{Name: "reload", Description: "Reload the configuration, prompts and model settings",
+			Role: Owner, Handler: (*BotService).handleReload},
+	}
+}
+
+// registerCommand adds a command, replacing any command with the same name in place
+func (bs *BotService) registerCommand(cmd Command) {
+	cmd.Name = strings.ToLower(strings.TrimPrefix(cmd.Name, "/"))
+	if _, ok := bs.commands[cmd.Name]; ok {
+		for i := range bs.commandList {
+			if bs.commandList[i].Name == cmd.Name {
+				bs.commandList[i] = cmd
+			}
+		}
+	} else {
+		bs.commandList = append(bs.commandList, cmd)
+	}
+	bs.commands[cmd.Name] = cmd
+}
+
+func (bs *BotService) handleCommand(msg *tgbotapi.Message) {
+	cmd, ok := bs.commands[msg.Command()]
+	if !ok {
+		bs.sendResponse(tgbotapi.NewMessage(msg.Chat.ID, unknownCmdMsg))
+		return
+	}
+	bs.metrics.commands.Add(1)
+
+	switch {
+	case cmd.Archive && bs.config().StrictPrivacy:
+		bs.Reply(msg, strictPrivacyMsg)
+		return
+	case cmd.Archive && !bs.historyEnabled(msg.Chat.ID):
+		bs.Reply(msg, historyCommandOffMsg)
+		return
+	case bs.commandDisabled(cmd.Name):
+		bs.Reply(msg, commandDisabledMsg)
+		return
+	case bs.disabledInChat(msg.Chat.ID, cmd.Name):
+		bs.Reply(msg, fmt.Sprintf(chatCommandDisabledMsg, cmd.Name))
+		return
+	case cmd.Chats == GroupChats && msg.Chat.IsPrivate():
+		bs.Reply(msg, groupOnlyMsg)
+		return
+	case cmd.Chats == PrivateChats && !msg.Chat.IsPrivate():
+		bs.Reply(msg, privateOnlyMsg)
+		return
+	case cmd.Role > Member && bs.userRole(msg.Chat, msg.From) < cmd.Role:
+		if cmd.Role == Owner {
+			bs.Reply(msg, ownerOnlyMsg)
+		} else {
+			bs.Reply(msg, adminOnlyMsg)
+		}
+		return
+	case len(strings.Fields(msg.CommandArguments())) < cmd.MinArgs:
+		bs.Reply(msg, "Usage: "+cmd.usageLine())
+		return
+	}
+
+	// Every admin and owner command goes to the audit log, with its arguments
+	if cmd.Role > Member {
+		bs.audit(msg.Chat.ID, msg.From, "/"+cmd.Name, msg.CommandArguments())
+	}
+
+	if cmd.Ack != "" {
+		bs.Reply(msg, cmd.Ack)
+	}
+	handler := cmd.Handler
+	if cmd.AI {
+		handler = func(bs *BotService, msg *tgbotapi.Message) {
+			done := bs.startRequest(msg)
+			defer done()
+			cmd.Handler(bs, msg)
+		}
+	}
+	if cmd.Async {
+		bs.goSafe("/"+cmd.Name, func() { handler(bs, msg) })
+		return
+	}
+	handler(bs, msg)
+}
+
+// commandDisabled reports whether a command is listed in DISABLED_COMMANDS or needs the archive STRICT_PRIVACY
+// doesn't keep
+func (bs *BotService) commandDisabled(name string) bool {
+	if bs.config().StrictPrivacy && bs.commands[name].Archive {
+		return true
+	}
+	return slices.Contains(bs.config().DisabledCommands, name)
+}
+
+func (bs *BotService) handleStart(msg *tgbotapi.Message) {
+	payload := msg.CommandArguments()
+	if strings.HasPrefix(payload, referralStartPrefix) && bs.recordReferral(msg, payload) && msg.Chat.IsPrivate() {
+		bs.Reply(msg, referralWelcomeMsg)
+	}
+
+	switch {
+	case msg.Chat.IsPrivate() && strings.HasPrefix(payload, groupStartPrefix):
+		bs.startOnboarding(msg, payload)
+	case msg.Chat.IsPrivate() && strings.HasPrefix(payload, knowledgeStartPrefix):
+		bs.startKnowledgeTraining(msg, payload)
+	case !msg.Chat.IsPrivate():
+		// In groups, offer the deep link that sets members up in a DM
+		reply := tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf(startMsg, bs.botMention))
+		reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("💬 Set me up in a DM", bs.onboardingLink(msg.Chat.ID)),
+		))
+		bs.sendResponse(reply)
+	default:
+		bs.sendResponse(tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf(startMsg, bs.botMention)))
+	}
+}
+
+func (bs *BotService) handleHelp(msg *tgbotapi.Message) {
+	if name := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(msg.CommandArguments()), "/")); name != "" {
+		cmd, ok := bs.commands[name]
+		if !ok || cmd.Hidden || bs.commandDisabled(name) {
+			bs.Reply(msg, fmt.Sprintf("There's no /%s command.", name))
+			return
+		}
+		bs.Reply(msg, cmd.helpText())
+		return
+	}
+
+	// Owner commands are only listed for the owner; admin commands are listed for everyone
+	showOwner := bs.userRole(msg.Chat, msg.From) == Owner
+	bs.sendResponse(tgbotapi.NewMessage(msg.Chat.ID, bs.helpText(msg.Chat.ID, showOwner)))
+}
+
+// helpText lists the commands visible in a chat, with admin and owner commands in their own sections
+func (bs *BotService) helpText(chatID int64, showOwner bool) string {
+	var sb, admin, owner strings.Builder
+	sb.WriteString(fmt.Sprintf(helpIntroMsg, bs.botMention))
+	for _, cmd := range bs.commandList {
+		if cmd.Hidden || bs.commandDisabled(cmd.Name) || bs.disabledInChat(chatID, cmd.Name) {
+			continue
+		}
+		line := fmt.Sprintf("- %s - %s\n", cmd.usageLine(), cmd.Description)
+		switch cmd.Role {
+		case Owner:
+			owner.WriteString(line)
+		case ChatAdmin:
+			admin.WriteString(line)
+		default:
+			sb.WriteString(line)
+		}
+	}
+	if admin.Len() > 0 {
+		sb.WriteString("Admins:\n" + admin.String())
+	}
+	if showOwner && owner.Len() > 0 {
+		sb.WriteString("Owner:\n" + owner.String())
+	}
+	sb.WriteString(fmt.Sprintf(helpFooterMsg, bs.botMention))
+	return sb.String()
+}
+
+// helpText explains a single command for /help <command>
+func (cmd Command) helpText() string {
+	text := cmd.usageLine()
+	if cmd.Description != "" {
+		text += "\n" + cmd.Description
+	}
+	if cmd.Help != "" {
+		text += "\n\n" + cmd.Help
+	}
+	switch cmd.Role {
+	case ChatAdmin:
+		text += "\n\nAdmins only."
+	case Owner:
+		text += "\n\nBot owner only."
+	}
+	return text
+}
+
+func (cmd Command) usageLine() string {
+	if cmd.Usage == "" {
+		return "/" + cmd.Name
+	}
+	return "/" + cmd.Name + " " + cmd.Usage
+}
+
+// registerBotCommands publishes the command menus: members and admins of groups,
+// private chats and the owner's DM each see only the commands they can use there, and groups that turned
+// commands off get menus without them
+func (bs *BotService) registerBotCommands() {
+	menus := []commandMenu{
+		{tgbotapi.NewBotCommandScopeAllPrivateChats(), PrivateChats, ChatAdmin},
+		{tgbotapi.NewBotCommandScopeAllGroupChats(), GroupChats, Member},
+		{tgbotapi.NewBotCommandScopeAllChatAdministrators(), GroupChats, ChatAdmin},
+	}
+	if bs.config().OwnerID != 0 {
+		menus = append(menus, commandMenu{tgbotapi.NewBotCommandScopeChat(bs.config().OwnerID), PrivateChats, Owner})
+	}
+	for _, m := range menus {
+		bs.publishMenu(m, nil)
+	}
+
+	chats, err := bs.store.ChatsWithDisabledCommands()
+	if err != nil {
+		log.Printf("Error loading the chats with disabled commands: %v", err)
+		return
+	}
+	for _, c := range chats {
+		bs.publishChatMenus(c.ChatID, c.DisabledCommands)
+	}
+}
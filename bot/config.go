@@ -0,0 +1,638 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/objectstore"
+)
+
+type Config struct {
+	BotToken     string
+	GeminiAPIKey string
+	MongoURI     string
+	// OwnerID is the Telegram user ID of the bot operator, who may run owner-only commands
+	OwnerID int64
+	// ErrorChatID is the chat that gets unexpected errors and panics, rate-limited and deduplicated
+	ErrorChatID int64
+	// DefaultLocation is the timezone for chats and users that haven't set one
+	DefaultLocation *time.Location
+
+	// Optional HTTP server for webhooks; disabled when HTTPAddr is empty
+	HTTPAddr            string
+	PublicURL           string
+	GitHubWebhookSecret string
+	GitHubToken         string
+	// APIToken enables the REST API under /api; requests must send it as a bearer token
+	APIToken string
+
+	// Optional admin dashboard; disabled unless DashboardAddr and DashboardPassword are set
+	DashboardAddr     string
+	DashboardUser     string
+	DashboardPassword string
+
+	// AllowedChats restricts the bot to these chat IDs; empty means every chat
+	AllowedChats []int64
+	// RateLimitPerMinute caps commands and questions per user; 0 disables the limit
+	RateLimitPerMinute int
+	// ChatAIRepliesPerMinute caps AI replies per group chat, chats can change it with /cooldown; 0 disables it
+	ChatAIRepliesPerMinute int
+	// MaintenanceMessage replaces the built-in maintenance notice
+	MaintenanceMessage string
+
+	// Model holds the Gemini model name and generation parameters
+	Model llm.Params
+	// PromptsFile is an optional JSON file with prompt template overrides, see prompts.go
+	PromptsFile string
+	// Pricing converts token usage into cost estimates, from PRICING_FILE over the built-in Gemini prices;
+	// MonthlyBudget warns the owner as the month's estimated cost nears it, 0 never
+	Pricing       Pricing
+	MonthlyBudget float64
+	// DisabledCommands are command names that are switched off
+	DisabledCommands []string
+	// CommandTranslations are the command menu descriptions by language code and command name, from
+	// COMMAND_TRANSLATIONS_FILE
+	CommandTranslations map[string]map[string]string
+	// ScamBlocklistFile lists scam domains, one per line; SafeBrowsingAPIKey enables Google Safe Browsing lookups
+	ScamBlocklistFile  string
+	SafeBrowsingAPIKey string
+	// WebSearchAPIKey and WebSearchEngineID enable web search with Google Programmable Search, used by /factcheck
+	// and /websearch; SearXNGURL is a SearXNG instance used instead when they're not set
+	WebSearchAPIKey   string
+	WebSearchEngineID string
+	SearXNGURL        string
+	// ExchangeRatesURL is a JSON exchange rates API for currency conversion, like https://open.er-api.com/v6/latest/USD
+	ExchangeRatesURL string
+	// WeatherAPIURL and WeatherGeocodingURL are Open-Meteo compatible forecast and geocoding APIs for /weather
+	WeatherAPIURL       string
+	WeatherGeocodingURL string
+	// WikipediaLanguage is the Wikipedia edition /wiki searches, like "en" or "de"
+	WikipediaLanguage string
+	// Aliases are names the bot answers to besides its @username, like "ChatBuddy" or "buddy"
+	Aliases []string
+	// Bots are extra bots run in the same process, from the JSON file in BOTS_FILE, see Fleet
+	Bots []BotSpec
+
+	// PremiumPriceStars is the Telegram Stars price of PremiumDays of premium; 0 turns off sales, grants still work
+	PremiumPriceStars int
+	PremiumDays       int
+	// PremiumLimitMultiplier raises the rate limit and chat cooldown of premium users and chats
+	PremiumLimitMultiplier int
+	// PremiumModel is the Gemini model answering premium chats; empty uses the regular model
+	PremiumModel string
+	// ReferralRewardCredits are credited to a user for each user or group they refer
+	ReferralRewardCredits int
+
+	// AILogRetentionDays is how long AI prompts and responses are kept for /history; 0 turns the log off
+	AILogRetentionDays int
+	// StrictPrivacy keeps no chat messages, AI log, cached answers, events or user IDs in logs, and turns off
+	// the commands that need the message archive
+	StrictPrivacy bool
+	// ReconcileChatID is a scratch chat stored messages are forwarded to, and the copies deleted, to find the ones
+	// deleted in Telegram; 0 turns the check off. ReconcileDays is how far back messages are checked.
+	ReconcileChatID int64
+	ReconcileDays   int
+	// AutoLeaveDays is how many days without messages the bot stays in a group before leaving it; 0 never leaves.
+	// AutoLeaveMinMembers leaves groups with fewer members, 0 never; LeaveUnauthorizedChats leaves the groups
+	// outside AllowedChats instead of just ignoring them.
+	AutoLeaveDays          int
+	AutoLeaveMinMembers    int
+	LeaveUnauthorizedChats bool
+	// PrivacyNotice replaces the notice posted when the bot is added to a group, above the history buttons
+	PrivacyNotice string
+
+	// BackupDir or S3 (when S3.Bucket is set) is where backups go; BackupIntervalHours schedules them, 0 never,
+	// and BackupKeep is how many are kept
+	BackupDir           string
+	S3                  objectstore.S3
+	BackupIntervalHours int
+	BackupKeep          int
+
+	// RedisURL shares caches, rate limits and job locks between instances; ResponseCacheMinutes is how long
+	// answers to an identical prompt are reused, 0 never
+	RedisURL             string
+	ResponseCacheMinutes int
+
+	// EventBusURL is a NATS server that receives message, summary and response events under EventSubjectPrefix
+	EventBusURL        string
+	EventSubjectPrefix string
+
+	// EmbeddingsEnabled runs the embedding worker in the bot process, EmbeddingBatchSize texts per API call
+	EmbeddingsEnabled  bool
+	EmbeddingModel     string
+	EmbeddingBatchSize int
+
+	// MathRendering renders the formulas of answers as images with the local latex and dvipng
+	MathRendering bool
+
+	// TopicsEnabled clusters each active chat's embedded messages into topics daily, one model call per chat
+	TopicsEnabled bool
+
+	// VectorStore is where embeddings go: "mongo" (Atlas Vector Search, or an in-process scan elsewhere),
+	// "qdrant" at QdrantURL or "pgvector" at PgvectorURL
+	VectorStore      string
+	QdrantURL        string
+	QdrantAPIKey     string
+	QdrantCollection string
+	PgvectorURL      string
+
+	// FilesDir keeps exports on disk, served under PublicURL/files, when no S3 bucket is set;
+	// SignedURLHours is how long download links work
+	FilesDir       string
+	SignedURLHours int
+
+	// CreditsEnabled charges AI answers and summaries to credit wallets, one credit per CreditTokens tokens
+	CreditsEnabled bool
+	CreditTokens   int
+	// StarterCredits are given to every user once; CreditsLowBalance is when they get a top-up reminder
+	StarterCredits    int
+	CreditsLowBalance int
+	// CreditsPackStars is the Telegram Stars price of CreditsPackSize credits; 0 turns off sales
+	CreditsPackSize  int
+	CreditsPackStars int
+}
+
+const (
+	envFileWarning    = "No .env file found - using system environment variables"
+	envFileLoadedMsg  = "Loaded .env file successfully"
+	requiredErrFmt    = "missing required environment variable: %s"
+	envFileLoadErrFmt = "WARNING: Error loading .env file: %v"
+
+	defaultRateLimitPerMinute     = 20
+	defaultChatAIRepliesPerMinute = 10
+	defaultAlias                  = "ChatBuddy"
+	defaultPremiumDays            = 30
+	defaultPremiumLimitMultiplier = 3
+	defaultReferralRewardCredits  = 50
+	defaultCreditTokens           = 1000
+	defaultAILogRetentionDays     = 30
+	defaultReconcileDays          = 7
+	defaultBackupKeep             = 7
+	defaultSignedURLHours         = 24
+	defaultResponseCacheMinutes   = 10
+	defaultEmbeddingBatchSize     = 50
+	defaultStarterCredits         = 100
+	defaultCreditsLowBalance      = 20
+	defaultCreditsPackSize        = 500
+
+	// maxSignedURLHours is the longest S3 presigned URLs can be valid
+	maxSignedURLHours = 7 * 24
+)
+
+func LoadConfig() (*Config, error) {
+	return loadConfig(false)
+}
+
+// loadConfig reads the configuration from the environment; with override set,
+// values in .env replace variables that are already set, so a reload picks up edits
+func loadConfig(override bool) (*Config, error) {
+	// Only load .env if file exists (local development)
+	if isLocalEnv() {
+		loadEnvFile(override)
+	}
+
+	// Validate required vars exist in environment
+	botToken, err := getRequiredEnv("TELEGRAM_BOT_TOKEN")
+	if err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
+
+	geminiKey, err := getRequiredEnv("GEMINI_API_KEY")
+	if err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
+
+	mongoURI, err := getRequiredEnv("MONGO_URI")
+	if err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
+
+	defaultLocation := time.Local
+	if tz := os.Getenv("DEFAULT_TIMEZONE"); tz != "" {
+		if defaultLocation, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("configuration error: invalid DEFAULT_TIMEZONE: %w", err)
+		}
+	}
+
+	var ownerID int64
+	if v := os.Getenv("OWNER_ID"); v != "" {
+		if ownerID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("configuration error: invalid OWNER_ID: %w", err)
+		}
+	}
+
+	var errorChatID int64
+	if v := os.Getenv("ERROR_CHAT_ID"); v != "" {
+		if errorChatID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("configuration error: invalid ERROR_CHAT_ID: %w", err)
+		}
+	}
+
+	allowedChats, err := parseChatIDs(os.Getenv("ALLOWED_CHAT_IDS"))
+	if err != nil {
+		return nil, fmt.Errorf("configuration error: invalid ALLOWED_CHAT_IDS: %w", err)
+	}
+
+	rateLimit := defaultRateLimitPerMinute
+	if v := os.Getenv("RATE_LIMIT_PER_MINUTE"); v != "" {
+		if rateLimit, err = strconv.Atoi(v); err != nil || rateLimit < 0 {
+			return nil, fmt.Errorf("configuration error: RATE_LIMIT_PER_MINUTE must be a non-negative number")
+		}
+	}
+
+	chatCooldown := defaultChatAIRepliesPerMinute
+	if v := os.Getenv("CHAT_AI_REPLIES_PER_MINUTE"); v != "" {
+		if chatCooldown, err = strconv.Atoi(v); err != nil || chatCooldown < 0 {
+			return nil, fmt.Errorf("configuration error: CHAT_AI_REPLIES_PER_MINUTE must be a non-negative number")
+		}
+	}
+
+	model, err := modelParams()
+	if err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
+
+	var disabled []string
+	for _, name := range strings.Split(os.Getenv("DISABLED_COMMANDS"), ",") {
+		if name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "/")); name != "" {
+			disabled = append(disabled, name)
+		}
+	}
+
+	aliases := []string{defaultAlias}
+	if v := os.Getenv("BOT_ALIASES"); v != "" {
+		aliases = nil
+		for _, alias := range strings.Split(v, ",") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				aliases = append(aliases, alias)
+			}
+		}
+	}
+
+	var premiumPrice int
+	if v := os.Getenv("PREMIUM_PRICE_STARS"); v != "" {
+		if premiumPrice, err = strconv.Atoi(v); err != nil || premiumPrice < 0 {
+			return nil, fmt.Errorf("configuration error: PREMIUM_PRICE_STARS must be a non-negative number")
+		}
+	}
+
+	premiumDays := defaultPremiumDays
+	if v := os.Getenv("PREMIUM_DAYS"); v != "" {
+		if premiumDays, err = strconv.Atoi(v); err != nil || premiumDays <= 0 {
+			return nil, fmt.Errorf("configuration error: PREMIUM_DAYS must be a positive number")
+		}
+	}
+
+	premiumMultiplier := defaultPremiumLimitMultiplier
+	if v := os.Getenv("PREMIUM_LIMIT_MULTIPLIER"); v != "" {
+		if premiumMultiplier, err = strconv.Atoi(v); err != nil || premiumMultiplier <= 0 {
+			return nil, fmt.Errorf("configuration error: PREMIUM_LIMIT_MULTIPLIER must be a positive number")
+		}
+	}
+
+	referralReward := defaultReferralRewardCredits
+	if v := os.Getenv("REFERRAL_REWARD_CREDITS"); v != "" {
+		if referralReward, err = strconv.Atoi(v); err != nil || referralReward < 0 {
+			return nil, fmt.Errorf("configuration error: REFERRAL_REWARD_CREDITS must be a non-negative number")
+		}
+	}
+
+	aiLogRetention := defaultAILogRetentionDays
+	if v := os.Getenv("AI_LOG_RETENTION_DAYS"); v != "" {
+		if aiLogRetention, err = strconv.Atoi(v); err != nil || aiLogRetention < 0 {
+			return nil, fmt.Errorf("configuration error: AI_LOG_RETENTION_DAYS must be a non-negative number")
+		}
+	}
+
+	var reconcileChatID int64
+	if v := os.Getenv("RECONCILE_CHAT_ID"); v != "" {
+		if reconcileChatID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("configuration error: invalid RECONCILE_CHAT_ID: %w", err)
+		}
+	}
+	reconcileDays := defaultReconcileDays
+	if v := os.Getenv("RECONCILE_DAYS"); v != "" {
+		if reconcileDays, err = strconv.Atoi(v); err != nil || reconcileDays < 1 {
+			return nil, fmt.Errorf("configuration error: RECONCILE_DAYS must be a positive number")
+		}
+	}
+
+	var autoLeaveDays int
+	if v := os.Getenv("AUTO_LEAVE_DAYS"); v != "" {
+		if autoLeaveDays, err = strconv.Atoi(v); err != nil || autoLeaveDays < 0 {
+			return nil, fmt.Errorf("configuration error: AUTO_LEAVE_DAYS must be a non-negative number")
+		}
+	}
+
+	var autoLeaveMinMembers int
+	if v := os.Getenv("AUTO_LEAVE_MIN_MEMBERS"); v != "" {
+		if autoLeaveMinMembers, err = strconv.Atoi(v); err != nil || autoLeaveMinMembers < 0 {
+			return nil, fmt.Errorf("configuration error: AUTO_LEAVE_MIN_MEMBERS must be a non-negative number")
+		}
+	}
+
+	var backupInterval int
+	if v := os.Getenv("BACKUP_INTERVAL_HOURS"); v != "" {
+		if backupInterval, err = strconv.Atoi(v); err != nil || backupInterval < 0 {
+			return nil, fmt.Errorf("configuration error: BACKUP_INTERVAL_HOURS must be a non-negative number")
+		}
+	}
+
+	backupKeep := defaultBackupKeep
+	if v := os.Getenv("BACKUP_KEEP"); v != "" {
+		if backupKeep, err = strconv.Atoi(v); err != nil || backupKeep < 0 {
+			return nil, fmt.Errorf("configuration error: BACKUP_KEEP must be a non-negative number")
+		}
+	}
+
+	responseCacheMinutes := defaultResponseCacheMinutes
+	if v := os.Getenv("RESPONSE_CACHE_MINUTES"); v != "" {
+		if responseCacheMinutes, err = strconv.Atoi(v); err != nil || responseCacheMinutes < 0 {
+			return nil, fmt.Errorf("configuration error: RESPONSE_CACHE_MINUTES must be a non-negative number")
+		}
+	}
+
+	embeddingBatchSize := defaultEmbeddingBatchSize
+	if v := os.Getenv("EMBEDDING_BATCH_SIZE"); v != "" {
+		if embeddingBatchSize, err = strconv.Atoi(v); err != nil || embeddingBatchSize <= 0 || embeddingBatchSize > llm.MaxEmbedBatch {
+			return nil, fmt.Errorf("configuration error: EMBEDDING_BATCH_SIZE must be between 1 and %d", llm.MaxEmbedBatch)
+		}
+	}
+
+	signedURLHours := defaultSignedURLHours
+	if v := os.Getenv("SIGNED_URL_HOURS"); v != "" {
+		if signedURLHours, err = strconv.Atoi(v); err != nil || signedURLHours <= 0 || signedURLHours > maxSignedURLHours {
+			return nil, fmt.Errorf("configuration error: SIGNED_URL_HOURS must be between 1 and %d", maxSignedURLHours)
+		}
+	}
+
+	creditTokens := defaultCreditTokens
+	if v := os.Getenv("CREDIT_TOKENS"); v != "" {
+		if creditTokens, err = strconv.Atoi(v); err != nil || creditTokens < 0 {
+			return nil, fmt.Errorf("configuration error: CREDIT_TOKENS must be a non-negative number")
+		}
+	}
+
+	starterCredits := defaultStarterCredits
+	if v := os.Getenv("STARTER_CREDITS"); v != "" {
+		if starterCredits, err = strconv.Atoi(v); err != nil || starterCredits < 0 {
+			return nil, fmt.Errorf("configuration error: STARTER_CREDITS must be a non-negative number")
+		}
+	}
+
+	creditsLowBalance := defaultCreditsLowBalance
+	if v := os.Getenv("CREDITS_LOW_BALANCE"); v != "" {
+		if creditsLowBalance, err = strconv.Atoi(v); err != nil || creditsLowBalance < 0 {
+			return nil, fmt.Errorf("configuration error: CREDITS_LOW_BALANCE must be a non-negative number")
+		}
+	}
+
+	creditsPackSize := defaultCreditsPackSize
+	if v := os.Getenv("CREDITS_PACK_SIZE"); v != "" {
+		if creditsPackSize, err = strconv.Atoi(v); err != nil || creditsPackSize < 0 {
+			return nil, fmt.Errorf("configuration error: CREDITS_PACK_SIZE must be a non-negative number")
+		}
+	}
+
+	var creditsPackStars int
+	if v := os.Getenv("CREDITS_PACK_STARS"); v != "" {
+		if creditsPackStars, err = strconv.Atoi(v); err != nil || creditsPackStars < 0 {
+			return nil, fmt.Errorf("configuration error: CREDITS_PACK_STARS must be a non-negative number")
+		}
+	}
+
+	dashboardUser := os.Getenv("DASHBOARD_USER")
+	if dashboardUser == "" {
+		dashboardUser = "admin"
+	}
+
+	weatherAPIURL := os.Getenv("WEATHER_API_URL")
+	if weatherAPIURL == "" {
+		weatherAPIURL = "https://api.open-meteo.com/v1/forecast"
+	}
+	weatherGeocodingURL := os.Getenv("WEATHER_GEOCODING_URL")
+	if weatherGeocodingURL == "" {
+		weatherGeocodingURL = "https://geocoding-api.open-meteo.com/v1/search"
+	}
+
+	bots, err := loadBotSpecs(os.Getenv("BOTS_FILE"))
+	if err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
+
+	pricing, err := loadPricing(os.Getenv("PRICING_FILE"))
+	if err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
+	commandTranslations, err := loadCommandTranslations(os.Getenv("COMMAND_TRANSLATIONS_FILE"))
+	if err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
+	var monthlyBudget float64
+	if v := os.Getenv("MONTHLY_BUDGET"); v != "" {
+		monthlyBudget, err = strconv.ParseFloat(v, 64)
+		if err != nil || monthlyBudget < 0 {
+			return nil, fmt.Errorf("configuration error: MONTHLY_BUDGET must be a non-negative number")
+		}
+	}
+
+	wikipediaLanguage := os.Getenv("WIKIPEDIA_LANGUAGE")
+	if wikipediaLanguage == "" {
+		wikipediaLanguage = "en"
+	}
+
+	vectorStore := os.Getenv("VECTOR_STORE")
+	switch vectorStore {
+	case "":
+		vectorStore = "mongo"
+	case "mongo":
+	case "qdrant":
+		if os.Getenv("QDRANT_URL") == "" {
+			return nil, fmt.Errorf("configuration error: VECTOR_STORE=qdrant needs QDRANT_URL")
+		}
+	case "pgvector":
+		if os.Getenv("PGVECTOR_URL") == "" {
+			return nil, fmt.Errorf("configuration error: VECTOR_STORE=pgvector needs PGVECTOR_URL")
+		}
+	default:
+		return nil, fmt.Errorf("configuration error: VECTOR_STORE must be mongo, qdrant or pgvector")
+	}
+	qdrantCollection := os.Getenv("QDRANT_COLLECTION")
+	if qdrantCollection == "" {
+		qdrantCollection = "chatbuddy_messages"
+	}
+
+	eventSubjectPrefix := os.Getenv("EVENT_SUBJECT_PREFIX")
+	if eventSubjectPrefix == "" {
+		eventSubjectPrefix = "chatbuddy"
+	}
+
+	return &Config{
+		BotToken:        botToken,
+		GeminiAPIKey:    geminiKey,
+		MongoURI:        mongoURI,
+		OwnerID:         ownerID,
+		ErrorChatID:     errorChatID,
+		DefaultLocation: defaultLocation,
+
+		HTTPAddr:            os.Getenv("HTTP_ADDR"),
+		PublicURL:           os.Getenv("PUBLIC_URL"),
+		GitHubWebhookSecret: os.Getenv("GITHUB_WEBHOOK_SECRET"),
+		GitHubToken:         os.Getenv("GITHUB_TOKEN"),
+		APIToken:            os.Getenv("API_TOKEN"),
+
+		DashboardAddr:     os.Getenv("DASHBOARD_ADDR"),
+		DashboardUser:     dashboardUser,
+		DashboardPassword: os.Getenv("DASHBOARD_PASSWORD"),
+
+		AllowedChats:           allowedChats,
+		RateLimitPerMinute:     rateLimit,
+		ChatAIRepliesPerMinute: chatCooldown,
+		MaintenanceMessage:     os.Getenv("MAINTENANCE_MESSAGE"),
+
+		Model:               model,
+		PromptsFile:         os.Getenv("PROMPTS_FILE"),
+		Pricing:             pricing,
+		MonthlyBudget:       monthlyBudget,
+		DisabledCommands:    disabled,
+		CommandTranslations: commandTranslations,
+		Aliases:             aliases,
+		Bots:                bots,
+
+		ScamBlocklistFile:   os.Getenv("SCAM_BLOCKLIST_FILE"),
+		SafeBrowsingAPIKey:  os.Getenv("SAFE_BROWSING_API_KEY"),
+		WebSearchAPIKey:     os.Getenv("WEB_SEARCH_API_KEY"),
+		WebSearchEngineID:   os.Getenv("WEB_SEARCH_ENGINE_ID"),
+		SearXNGURL:          os.Getenv("SEARXNG_URL"),
+		ExchangeRatesURL:    os.Getenv("EXCHANGE_RATES_URL"),
+		WeatherAPIURL:       weatherAPIURL,
+		WeatherGeocodingURL: weatherGeocodingURL,
+		WikipediaLanguage:   wikipediaLanguage,
+
+		PremiumPriceStars:      premiumPrice,
+		PremiumDays:            premiumDays,
+		PremiumLimitMultiplier: premiumMultiplier,
+		PremiumModel:           os.Getenv("PREMIUM_GEMINI_MODEL"),
+		ReferralRewardCredits:  referralReward,
+
+		AILogRetentionDays:     aiLogRetention,
+		StrictPrivacy:          os.Getenv("STRICT_PRIVACY") == "true",
+		PrivacyNotice:          os.Getenv("PRIVACY_NOTICE"),
+		ReconcileChatID:        reconcileChatID,
+		ReconcileDays:          reconcileDays,
+		AutoLeaveDays:          autoLeaveDays,
+		AutoLeaveMinMembers:    autoLeaveMinMembers,
+		LeaveUnauthorizedChats: os.Getenv("LEAVE_UNAUTHORIZED_CHATS") == "true",
+
+		BackupDir: os.Getenv("BACKUP_DIR"),
+		S3: objectstore.S3{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		},
+		BackupIntervalHours:  backupInterval,
+		BackupKeep:           backupKeep,
+		RedisURL:             os.Getenv("REDIS_URL"),
+		ResponseCacheMinutes: responseCacheMinutes,
+		EventBusURL:          os.Getenv("EVENT_BUS_URL"),
+		EventSubjectPrefix:   eventSubjectPrefix,
+		EmbeddingsEnabled:    os.Getenv("EMBEDDINGS_ENABLED") == "true",
+		EmbeddingModel:       os.Getenv("EMBEDDING_MODEL"),
+		EmbeddingBatchSize:   embeddingBatchSize,
+		TopicsEnabled:        os.Getenv("TOPICS_ENABLED") == "true",
+		MathRendering:        os.Getenv("MATH_RENDERING") == "true",
+		VectorStore:          vectorStore,
+		QdrantURL:            os.Getenv("QDRANT_URL"),
+		QdrantAPIKey:         os.Getenv("QDRANT_API_KEY"),
+		QdrantCollection:     qdrantCollection,
+		PgvectorURL:          os.Getenv("PGVECTOR_URL"),
+		FilesDir:             os.Getenv("FILES_DIR"),
+		SignedURLHours:       signedURLHours,
+
+		CreditsEnabled:    os.Getenv("CREDITS_ENABLED") == "true",
+		CreditTokens:      creditTokens,
+		StarterCredits:    starterCredits,
+		CreditsLowBalance: creditsLowBalance,
+		CreditsPackSize:   creditsPackSize,
+		CreditsPackStars:  creditsPackStars,
+	}, nil
+}
+
+func isLocalEnv() bool {
+	// Check for .env file existence
+	_, err := os.Stat(".env")
+	return !os.IsNotExist(err)
+}
+
+func loadEnvFile(override bool) {
+	load := godotenv.Load
+	if override {
+		load = godotenv.Overload
+	}
+	if err := load(); err != nil {
+		log.Printf(envFileLoadErrFmt, err)
+		return
+	}
+	log.Println(envFileLoadedMsg)
+}
+
+func getRequiredEnv(key string) (string, error) {
+	if value := os.Getenv(key); value != "" {
+		return value, nil
+	}
+	return "", fmt.Errorf(requiredErrFmt, key)
+}
+
+// parseChatIDs parses a comma-separated list of chat IDs
+func parseChatIDs(list string) ([]int64, error) {
+	var ids []int64
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// modelParams reads GEMINI_MODEL, GEMINI_TEMPERATURE, GEMINI_MAX_OUTPUT_TOKENS and CONTEXT_CACHE_MINUTES
+func modelParams() (llm.Params, error) {
+	p := llm.Params{Model: os.Getenv("GEMINI_MODEL")}
+	if v := os.Getenv("GEMINI_TEMPERATURE"); v != "" {
+		t, err := strconv.ParseFloat(v, 32)
+		if err != nil || t < 0 {
+			return p, fmt.Errorf("GEMINI_TEMPERATURE must be a non-negative number")
+		}
+		temperature := float32(t)
+		p.Temperature = &temperature
+	}
+	if v := os.Getenv("GEMINI_MAX_OUTPUT_TOKENS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || n <= 0 {
+			return p, fmt.Errorf("GEMINI_MAX_OUTPUT_TOKENS must be a positive number")
+		}
+		maxTokens := int32(n)
+		p.MaxOutputTokens = &maxTokens
+	}
+	if v := os.Getenv("CONTEXT_CACHE_MINUTES"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes < 0 {
+			return p, fmt.Errorf("CONTEXT_CACHE_MINUTES must be a non-negative number")
+		}
+		p.ContextCacheTTL = time.Duration(minutes) * time.Minute
+	}
+	return p, nil
+}
@@ -0,0 +1,158 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	historyPending = "pending"
+	historyOn      = "on"
+	historyOff     = "off"
+
+	defaultPrivacyNotice = `👋 Thanks for adding me! A quick privacy note before we start:
+- With history features on, I store this group's messages so I can summarize the chat, search it and answer questions about it
+- Running stateless, I keep nothing and only see the messages that mention me or reply to me
+- Either way, what you ask me is sent to an AI model to generate the answer
+
+An admin can choose below, or later with /privacy. Until then I run stateless.`
+	historyOnMsg         = "📚 History features are on: I store this group's messages for /summary, /search and the other history commands."
+	historyOffMsg        = "🔒 Running stateless: I don't store this group's messages, and the history commands are off."
+	historyOffDeletedMsg = "🔒 Running stateless: I deleted the %d stored messages of this group and won't store new ones. The history commands are off."
+	historyCommandOffMsg = "This group runs stateless, so I don't keep its messages and this command is off. An admin can turn history features on with /privacy history."
+	consentAdminsOnlyMsg = "Only admins can choose this."
+	privacyUsageMsg      = "Usage: /privacy [history|stateless]"
+	historyDecidedSuffix = "\n\n✅ %s chose: %s"
+)
+
+// historyEnabled reports whether a chat's messages may be stored for history features: not under STRICT_PRIVACY,
+// and not in groups whose admins chose to run stateless or haven't answered the privacy notice yet
+func (bs *BotService) historyEnabled(chatID int64) bool {
+	if bs.config().StrictPrivacy {
+		return false
+	}
+	switch bs.store.ChatSettings(chatID).History {
+	case historyPending, historyOff:
+		return false
+	default:
+		return true
+	}
+}
+
+// postPrivacyNotice asks the admins of a group the bot was just added to whether to keep its messages
+func (bs *BotService) postPrivacyNotice(chat *tgbotapi.Chat) {
+	if bs.config().StrictPrivacy {
+		return
+	}
+	// A group the bot is re-added to keeps the admins' earlier choice
+	if bs.store.ChatSettings(chat.ID).History != "" {
+		return
+	}
+	if err := bs.store.UpdateChatSettings(chat.ID, bson.M{"history": historyPending}); err != nil {
+		log.Printf("Error saving the history setting of chat %d: %v", chat.ID, err)
+		return
+	}
+
+	notice := tgbotapi.NewMessage(chat.ID, cmp.Or(bs.config().PrivacyNotice, defaultPrivacyNotice))
+	notice.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📚 Enable history features", "consent:"+historyOn),
+		tgbotapi.NewInlineKeyboardButtonData("🔒 Run stateless", "consent:"+historyOff),
+	))
+	bs.sendResponse(notice)
+}
+
+// handleConsentCallback records the choice an admin made on the privacy notice
+func (bs *BotService) handleConsentCallback(cb *tgbotapi.CallbackQuery) {
+	_, choice, _ := strings.Cut(cb.Data, ":")
+	if cb.Message == nil || (choice != historyOn && choice != historyOff) {
+		bs.answerCallback(cb.ID, "")
+		return
+	}
+	chatID := cb.Message.Chat.ID
+	if !bs.isChatAdmin(chatID, cb.From.ID) {
+		bs.answerCallback(cb.ID, consentAdminsOnlyMsg)
+		return
+	}
+
+	if _, err := bs.setHistory(chatID, cb.From, choice); err != nil {
+		log.Printf("Error saving the history setting of chat %d: %v", chatID, err)
+		bs.answerCallback(cb.ID, responseErrorMsg)
+		return
+	}
+	bs.audit(chatID, cb.From, "/privacy", choice)
+
+	label := "history features on"
+	if choice == historyOff {
+		label = "stateless"
+	}
+	bs.editMessageText(chatID, cb.Message.MessageID, cmp.Or(bs.config().PrivacyNotice, defaultPrivacyNotice)+
+		fmt.Sprintf(historyDecidedSuffix, displayName(cb.From), label))
+	bs.answerCallback(cb.ID, "")
+}
+
+// handlePrivacy shows or changes whether the group keeps its messages for history features
+func (bs *BotService) handlePrivacy(msg *tgbotapi.Message) {
+	var choice string
+	switch strings.ToLower(strings.TrimSpace(msg.CommandArguments())) {
+	case "history", "on":
+		choice = historyOn
+	case "stateless", "off":
+		choice = historyOff
+	case "":
+		if bs.historyEnabled(msg.Chat.ID) {
+			bs.Reply(msg, historyOnMsg+"\n\n"+privacyUsageMsg)
+		} else {
+			bs.Reply(msg, historyOffMsg+"\n\n"+privacyUsageMsg)
+		}
+		return
+	default:
+		bs.Reply(msg, privacyUsageMsg)
+		return
+	}
+
+	deleted, err := bs.setHistory(msg.Chat.ID, msg.From, choice)
+	if err != nil {
+		log.Printf("Error saving the history setting of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	switch {
+	case choice == historyOn:
+		bs.Reply(msg, historyOnMsg)
+	case deleted > 0:
+		bs.Reply(msg, fmt.Sprintf(historyOffDeletedMsg, deleted))
+	default:
+		bs.Reply(msg, historyOffMsg)
+	}
+}
+
+// setHistory saves an admin's choice and who made it; running stateless also deletes the messages and vectors
+// already stored, and returns how many messages that was
+func (bs *BotService) setHistory(chatID int64, admin *tgbotapi.User, choice string) (int64, error) {
+	if err := bs.store.UpdateChatSettings(chatID, bson.M{"history": choice, "history_decided_by": admin.ID}); err != nil {
+		return 0, err
+	}
+	if choice != historyOff {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	deleted, err := bs.store.DeleteMessagesBefore(ctx, chatID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if bs.vectors != nil {
+		if err := bs.vectors.DeleteChat(ctx, chatID); err != nil {
+			log.Printf("Error deleting the vectors of chat %d: %v", chatID, err)
+		}
+	}
+	return deleted, nil
+}
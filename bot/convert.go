@@ -0,0 +1,264 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/units"
+)
+
+const (
+	// exchangeRatesTTL is how long fetched exchange rates are used, locally and in Redis
+	exchangeRatesTTL      = time.Hour
+	exchangeRatesCacheKey = "exchange_rates"
+	convertHelp           = `Converts units (length, mass, volume, area, speed, time, data, temperature) and, when the operator set up exchange rates, currencies.
+Examples:
+/convert 100 USD to EUR
+/convert 5 miles in km
+/convert 72 F to C`
+)
+
+var (
+	// conversionRe matches "100 USD to EUR", "$100 in euros" or "5 miles into km"
+	conversionRe = regexp.MustCompile(`(?i)^([$€£¥])?\s*(-?\d[\d,]*(?:\.\d+)?)\s*(.*?)\s+(?:to|in|into|as)\s+(.+?)$`)
+	// howMuchRe matches "how much is 5 miles in km?" and "what's 100 usd in eur", leaving the conversion
+	howMuchRe = regexp.MustCompile(`(?i)^\s*(?:how much|how many|what)\s*(?:is|are|'s)\s+(.+?)\s*\??\s*$`)
+	// howManyRe matches "how many km is 5 miles?", where the target unit comes first
+	howManyRe = regexp.MustCompile(`(?i)^\s*how (?:many|much)\s+(.+?)\s+(?:is|are|in|make)\s+(.+?)\s*\??\s*$`)
+)
+
+// currencySymbols and currencyNames map what people write to ISO 4217 codes
+var (
+	currencySymbols = map[string]string{"$": "USD", "€": "EUR", "£": "GBP", "¥": "JPY"}
+	currencyNames   = map[string]string{
+		"dollar": "USD", "dollars": "USD", "euro": "EUR", "euros": "EUR", "pound sterling": "GBP",
+		"yen": "JPY", "yuan": "CNY", "rupee": "INR", "rupees": "INR", "franc": "CHF", "francs": "CHF",
+		"ruble": "RUB", "rubles": "RUB", "rial": "IRR", "rials": "IRR", "lira": "TRY", "dirham": "AED", "dirhams": "AED",
+	}
+)
+
+var (
+	// errNoExchangeRates is returned for currency conversions when EXCHANGE_RATES_URL isn't set
+	errNoExchangeRates = errors.New("currency conversion isn't set up")
+	// errExchangeRatesUnavailable wraps failures to fetch the rates; other conversion errors are the user's
+	errExchangeRatesUnavailable = errors.New("exchange rates unavailable")
+)
+
+// exchangeRates caches the latest rates fetched from EXCHANGE_RATES_URL, relative to any one currency
+type exchangeRates struct {
+	mu      sync.Mutex
+	rates   map[string]float64
+	fetched time.Time
+}
+
+// conversion is a parsed "<amount> <from> to <to>"
+type conversion struct {
+	amount   float64
+	from, to string
+}
+
+// parseConversion reads a conversion like "100 USD to EUR" or "$5 in euros"
+func parseConversion(text string) (conversion, bool) {
+	m := conversionRe.FindStringSubmatch(strings.TrimSpace(text))
+	if m == nil {
+		return conversion{}, false
+	}
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(m[2], ",", ""), 64)
+	if err != nil {
+		return conversion{}, false
+	}
+	from := strings.TrimSpace(m[3])
+	if m[1] != "" {
+		from = m[1]
+	}
+	to := strings.TrimRight(strings.TrimSpace(m[4]), "?.!")
+	if from == "" || to == "" {
+		return conversion{}, false
+	}
+	return conversion{amount, from, to}, true
+}
+
+// parseConversionQuestion reads questions like "how much is 5 miles in km" or "how many km is 5 miles"
+func parseConversionQuestion(question string) (conversion, bool) {
+	if m := howMuchRe.FindStringSubmatch(question); m != nil {
+		if c, ok := parseConversion(m[1]); ok {
+			return c, true
+		}
+	}
+	if m := howManyRe.FindStringSubmatch(question); m != nil {
+		if c, ok := parseConversion(m[2] + " to " + m[1]); ok {
+			return c, true
+		}
+	}
+	return conversion{}, false
+}
+
+// currencyCode returns the ISO code for "usd", "$" or "euros", or false if s doesn't look like a currency
+func currencyCode(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if code, ok := currencySymbols[s]; ok {
+		return code, true
+	}
+	if code, ok := currencyNames[strings.ToLower(s)]; ok {
+		return code, true
+	}
+	if len(s) == 3 && strings.IndexFunc(s, func(r rune) bool { return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') }) < 0 {
+		return strings.ToUpper(s), true
+	}
+	return "", false
+}
+
+// convert converts between units locally, or between currencies with the cached exchange rates,
+// and describes the result like "5 mile = 8.04672 km"
+func (bs *BotService) convert(ctx context.Context, c conversion) (string, error) {
+	if from, ok := units.Lookup(c.from); ok {
+		to, ok := units.Lookup(c.to)
+		if !ok {
+			return "", fmt.Errorf("I don't know the unit %q", c.to)
+		}
+		value, err := units.Convert(c.amount, c.from, c.to)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s = %s %s", formatAmount(c.amount), from.Symbol, formatAmount(value), to.Symbol), nil
+	}
+
+	from, okFrom := currencyCode(c.from)
+	to, okTo := currencyCode(c.to)
+	if !okFrom || !okTo {
+		return "", fmt.Errorf("I don't know how to convert %q to %q", c.from, c.to)
+	}
+	rates, err := bs.exchangeRates(ctx)
+	if err != nil {
+		return "", err
+	}
+	if rates[from] == 0 {
+		return "", fmt.Errorf("I don't have a rate for %s", from)
+	}
+	if rates[to] == 0 {
+		return "", fmt.Errorf("I don't have a rate for %s", to)
+	}
+	value := c.amount / rates[from] * rates[to]
+	return fmt.Sprintf("%s %s = %.2f %s (1 %s = %s %s)", formatAmount(c.amount), from, value, to, from, formatAmount(rates[to]/rates[from]), to), nil
+}
+
+// formatAmount rounds to 6 significant digits without switching to exponent notation
+func formatAmount(v float64) string {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 6, 64), 64)
+	if math.Abs(rounded) >= 1e6 {
+		rounded = math.Round(v)
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+// exchangeRates returns the rates from EXCHANGE_RATES_URL, fetched at most once per exchangeRatesTTL
+// and shared through Redis when it's set
+func (bs *BotService) exchangeRates(ctx context.Context) (map[string]float64, error) {
+	url := bs.config().ExchangeRatesURL
+	if url == "" {
+		return nil, errNoExchangeRates
+	}
+
+	bs.rates.mu.Lock()
+	defer bs.rates.mu.Unlock()
+	if bs.rates.rates != nil && time.Since(bs.rates.fetched) < exchangeRatesTTL {
+		return bs.rates.rates, nil
+	}
+
+	if bs.cache != nil {
+		if data, err := bs.cache.Get(ctx, exchangeRatesCacheKey); err == nil {
+			var rates map[string]float64
+			if json.Unmarshal(data, &rates) == nil && len(rates) > 0 {
+				bs.rates.rates, bs.rates.fetched = rates, time.Now()
+				return rates, nil
+			}
+		}
+	}
+
+	rates, err := fetchExchangeRates(ctx, url)
+	if err != nil {
+		// Stale rates beat none when the API is down
+		if bs.rates.rates != nil {
+			log.Printf("Error refreshing exchange rates, using the old ones: %v", err)
+			return bs.rates.rates, nil
+		}
+		return nil, fmt.Errorf("%w: %v", errExchangeRatesUnavailable, err)
+	}
+	bs.rates.rates, bs.rates.fetched = rates, time.Now()
+	if bs.cache != nil {
+		data, _ := json.Marshal(rates)
+		if err := bs.cache.Set(ctx, exchangeRatesCacheKey, data, exchangeRatesTTL); err != nil {
+			log.Printf("Error caching exchange rates: %v", err)
+		}
+	}
+	return rates, nil
+}
+
+// fetchExchangeRates reads a JSON document with a "rates" object of currency codes to rates and the base
+// currency in "base" or "base_code", as returned by open.er-api.com, exchangerate.host or Frankfurter
+func fetchExchangeRates(ctx context.Context, url string) (map[string]float64, error) {
+	var result struct {
+		Base     string             `json:"base"`
+		BaseCode string             `json:"base_code"`
+		Rates    map[string]float64 `json:"rates"`
+	}
+	if err := getJSON(ctx, url, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Rates) == 0 {
+		return nil, fmt.Errorf("exchange rates API returned no rates")
+	}
+	if base := result.Base + result.BaseCode; base != "" {
+		result.Rates[strings.ToUpper(base)] = 1
+	}
+	return result.Rates, nil
+}
+
+// conversionAnswer answers questions like "how much is 5 miles in km" locally; ok is false for anything
+// that isn't a conversion it can do, so the question goes to the model
+func (bs *BotService) conversionAnswer(question string) (string, bool) {
+	c, ok := parseConversionQuestion(question)
+	if !ok {
+		return "", false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	answer, err := bs.convert(ctx, c)
+	if err != nil {
+		return "", false
+	}
+	return answer, true
+}
+
+func (bs *BotService) handleConvert(msg *tgbotapi.Message) {
+	c, ok := parseConversion(msg.CommandArguments())
+	if !ok {
+		bs.Reply(msg, "Usage: /convert <amount> <unit> to <unit>\n\n"+convertHelp)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	answer, err := bs.convert(ctx, c)
+	switch {
+	case errors.Is(err, errNoExchangeRates):
+		bs.Reply(msg, "Currency conversion isn't set up on this bot.")
+	case errors.Is(err, errExchangeRatesUnavailable):
+		log.Printf("Error converting currency: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+	case err != nil:
+		bs.Reply(msg, "❌ "+err.Error())
+	default:
+		bs.Reply(msg, answer)
+	}
+}
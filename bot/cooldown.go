@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	chatCooldownMsg  = "I've answered a lot here in the last minute, so I'm taking a short breather. Ask me again in a minute!"
+	cooldownUsageMsg = `Usage:
+/cooldown <n> - answer at most n AI requests per minute in this chat
+/cooldown off - no limit for this chat
+/cooldown default - use the bot default
+Admins are never held back by the cooldown.`
+)
+
+// chatCooldown caps the AI replies per chat and minute, independent from the per-user limit,
+// so the bot doesn't dominate a fast-moving chat; admins are not limited
+func (bs *BotService) chatCooldown(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		msg := update.Message
+		if msg == nil || msg.Chat.IsPrivate() || !bs.needsAI(msg) {
+			next(update)
+			return
+		}
+
+		limit := bs.premiumLimit(bs.chatAIRepliesPerMinute(msg.Chat.ID), bs.isPremium(premiumScopeChat, msg.Chat.ID))
+		if limit <= 0 {
+			next(update)
+			return
+		}
+
+		allowed, warn := bs.allowRequest(&bs.chatLimiter, "chat", msg.Chat.ID, limit, time.Now())
+		if !allowed && bs.userRole(msg.Chat, msg.From) < ChatAdmin {
+			if warn {
+				bs.Reply(msg, chatCooldownMsg)
+			}
+			return
+		}
+		next(update)
+	}
+}
+
+// needsAI reports whether answering a message calls the model: questions to the bot and AI commands
+func (bs *BotService) needsAI(msg *tgbotapi.Message) bool {
+	if !bs.isAddressedToBot(msg) {
+		return false
+	}
+	if msg.IsCommand() {
+		cmd, ok := bs.commands[msg.Command()]
+		return ok && cmd.AI
+	}
+	return true
+}
+
+// chatAIRepliesPerMinute is the chat's cooldown limit, or the bot default if the chat has none; 0 means no limit
+func (bs *BotService) chatAIRepliesPerMinute(chatID int64) int {
+	if limit := bs.store.ChatSettings(chatID).AIRepliesPerMinute; limit != nil {
+		return *limit
+	}
+	return bs.config().ChatAIRepliesPerMinute
+}
+
+func (bs *BotService) handleCooldownCommand(msg *tgbotapi.Message) {
+	arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+
+	var change bson.M
+	var reply string
+	switch arg {
+	case "":
+		reply = "No cooldown in this chat."
+		if limit := bs.chatAIRepliesPerMinute(msg.Chat.ID); limit > 0 {
+			reply = fmt.Sprintf("I answer at most %d AI requests per minute here.", limit)
+		}
+		bs.Reply(msg, reply+"\n\n"+cooldownUsageMsg)
+		return
+	case "default":
+		change, reply = bson.M{"ai_replies_per_minute": nil}, "This chat uses the default cooldown again."
+	case "off":
+		change, reply = bson.M{"ai_replies_per_minute": 0}, "No cooldown in this chat."
+	default:
+		limit, err := strconv.Atoi(arg)
+		if err != nil || limit <= 0 {
+			bs.Reply(msg, cooldownUsageMsg)
+			return
+		}
+		change, reply = bson.M{"ai_replies_per_minute": limit}, fmt.Sprintf("I'll answer at most %d AI requests per minute here.", limit)
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, change); err != nil {
+		log.Printf("Error saving cooldown for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, reply)
+}
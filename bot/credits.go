@@ -0,0 +1,191 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+const (
+	outOfCreditsMsg = "🪫 You're out of credits, so I can't answer right now. Use /balance to top up, or /referrals to earn some."
+	lowBalanceMsg   = "🪫 Only %d credits left. Use /balance to top up, or /referrals to earn more."
+	balanceUsageMsg = `Usage:
+/balance - show your credits and this chat's
+/balance buy - buy credits for yourself
+/balance buy chat - buy credits for this group, shared by all members
+Owner: /balance grant <user|chat> <id> <amount>`
+)
+
+// payer is the credit wallet an AI request is charged to
+type payer struct {
+	scope string
+	id    int64
+	// chatID is where low-balance warnings go
+	chatID int64
+}
+
+type payerKey struct{}
+
+// billedContext is messageContext for requests of the sender, whose model calls are also charged
+// to the wallet paying for msg when credits are on
+func (bs *BotService) billedContext(msg *tgbotapi.Message) context.Context {
+	ctx := bs.messageContext(msg)
+	if p, ok := bs.payerFor(msg); ok {
+		ctx = context.WithValue(ctx, payerKey{}, p)
+	}
+	return ctx
+}
+
+// payerFor picks the wallet for a message: the user in a DM; in a group the chat's shared wallet while it has
+// credits, then the sender's. The owner and premium users and chats don't pay.
+func (bs *BotService) payerFor(msg *tgbotapi.Message) (payer, bool) {
+	if !bs.config().CreditsEnabled || msg.From == nil || bs.userRole(msg.Chat, msg.From) == Owner || bs.isPremiumChat(msg.Chat) {
+		return payer{}, false
+	}
+	if !msg.Chat.IsPrivate() && bs.creditBalance(store.CreditScopeChat, msg.Chat.ID) > 0 {
+		return payer{scope: store.CreditScopeChat, id: msg.Chat.ID, chatID: msg.Chat.ID}, true
+	}
+	if bs.isPremium(premiumScopeUser, msg.From.ID) {
+		return payer{}, false
+	}
+	return payer{scope: store.CreditScopeUser, id: msg.From.ID, chatID: msg.From.ID}, true
+}
+
+// creditBalance returns the balance of a wallet. A user's wallet is opened with the starter credits when it
+// has no entries yet, so later checks are a plain read.
+func (bs *BotService) creditBalance(scope string, id int64) int {
+	balance, opened, err := bs.store.CreditBalance(scope, id)
+	if err != nil {
+		log.Printf("Error loading credit balance of %s %d: %v", scope, id, err)
+		return balance
+	}
+	if starter := bs.config().StarterCredits; !opened && scope == store.CreditScopeUser && starter > 0 {
+		granted, err := bs.store.GrantCreditsOnce(scope, id, starter, "starter")
+		if err != nil {
+			log.Printf("Error granting starter credits to user %d: %v", id, err)
+		}
+		if granted {
+			balance += starter
+		}
+	}
+	return balance
+}
+
+// addCredits adds to a wallet, opening it first so credits given before a user's first request don't cost
+// them their starter credits
+func (bs *BotService) addCredits(scope string, id int64, amount int, reason string) error {
+	bs.creditBalance(scope, id)
+	return bs.store.AddCredits(scope, id, amount, reason)
+}
+
+// creditCost converts the tokens of a request into credits, at least one per call
+func (bs *BotService) creditCost(tokens int64) int {
+	per := int64(bs.config().CreditTokens)
+	if per <= 0 {
+		return 1
+	}
+	return int(max(1, (tokens+per-1)/per))
+}
+
+// chargeCredits debits the tokens of a model call from the payer's wallet and warns once it runs low
+func (bs *BotService) chargeCredits(p payer, usage *llm.TokenUsage) {
+	cost := bs.creditCost(usage.Total())
+	before := bs.creditBalance(p.scope, p.id)
+	if err := bs.store.AddCredits(p.scope, p.id, -cost, "ai"); err != nil {
+		log.Printf("Error charging %d credits to %s %d: %v", cost, p.scope, p.id, err)
+		return
+	}
+
+	after, low := before-cost, bs.config().CreditsLowBalance
+	if before >= low && after < low {
+		if _, err := bs.api.Send(tgbotapi.NewMessage(p.chatID, fmt.Sprintf(lowBalanceMsg, max(after, 0)))); err != nil {
+			log.Printf("failed to send low balance warning to chat %d: %v", p.chatID, err)
+		}
+	}
+}
+
+// creditGate stops AI requests whose payer has no credits left, before any model call is made
+func (bs *BotService) creditGate(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		msg := update.Message
+		if msg == nil || !bs.config().CreditsEnabled || !bs.needsAI(msg) {
+			next(update)
+			return
+		}
+		if p, ok := bs.payerFor(msg); ok && bs.creditBalance(p.scope, p.id) <= 0 {
+			bs.Reply(msg, outOfCreditsMsg)
+			return
+		}
+		next(update)
+	}
+}
+
+func (bs *BotService) handleBalance(msg *tgbotapi.Message) {
+	args := strings.Fields(strings.ToLower(msg.CommandArguments()))
+	switch {
+	case len(args) == 0:
+		status := fmt.Sprintf("🪙 Your credits: %d", bs.creditBalance(store.CreditScopeUser, msg.From.ID))
+		if !msg.Chat.IsPrivate() {
+			status += fmt.Sprintf("\nThis chat's shared credits: %d", bs.creditBalance(store.CreditScopeChat, msg.Chat.ID))
+		}
+		if !bs.config().CreditsEnabled {
+			status += "\n\nCredits aren't charged on this bot right now."
+		} else if bs.config().CreditsPackStars > 0 {
+			status += fmt.Sprintf("\n\n%d credits cost %d ⭐.", bs.config().CreditsPackSize, bs.config().CreditsPackStars)
+		}
+		bs.Reply(msg, status+"\n\n"+balanceUsageMsg)
+	case args[0] == "buy":
+		if bs.config().CreditsPackStars <= 0 {
+			bs.Reply(msg, "Credits aren't for sale on this bot.")
+			return
+		}
+		scope, id := store.CreditScopeUser, msg.From.ID
+		if len(args) > 1 && args[1] == "chat" && !msg.Chat.IsPrivate() {
+			scope, id = store.CreditScopeChat, msg.Chat.ID
+		}
+		invoice := tgbotapi.NewInvoice(msg.Chat.ID,
+			"ChatBuddy Credits",
+			fmt.Sprintf("%d credits for AI answers and summaries.", bs.config().CreditsPackSize),
+			fmt.Sprintf("credits:%s:%d", scope, id),
+			"", "", starsCurrency,
+			[]tgbotapi.LabeledPrice{{Label: fmt.Sprintf("%d credits", bs.config().CreditsPackSize), Amount: bs.config().CreditsPackStars}},
+		)
+		invoice.ReplyToMessageID = msg.MessageID
+		if _, err := bs.api.Send(invoice); err != nil {
+			log.Printf("failed to send credits invoice: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+		}
+	case args[0] == "grant" && bs.userRole(msg.Chat, msg.From) == Owner && len(args) == 4:
+		bs.audit(msg.Chat.ID, msg.From, "/balance", msg.CommandArguments())
+		id, err := strconv.ParseInt(args[2], 10, 64)
+		amount, amountErr := strconv.Atoi(args[3])
+		if err != nil || amountErr != nil || (args[1] != store.CreditScopeUser && args[1] != store.CreditScopeChat) {
+			bs.Reply(msg, balanceUsageMsg)
+			return
+		}
+		if err := bs.addCredits(args[1], id, amount, "grant"); err != nil {
+			log.Printf("Error granting credits: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bs.Reply(msg, fmt.Sprintf("🪙 Granted %d credits to %s %d, balance now %d.", amount, args[1], id, bs.creditBalance(args[1], id)))
+	default:
+		bs.Reply(msg, balanceUsageMsg)
+	}
+}
+
+// deliverCredits adds purchased credits to the wallet the invoice was for
+func (bs *BotService) deliverCredits(msg *tgbotapi.Message, payment Payment) {
+	if err := bs.addCredits(payment.Scope, payment.SubjectID, payment.Credits, "purchase:"+payment.ChargeID); err != nil {
+		log.Printf("Error adding purchased credits for payment %s: %v", payment.ChargeID, err)
+		bs.Reply(msg, "Thanks for your payment! Something went wrong adding your credits, please contact the bot owner.")
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("🪙 Thank you! %d credits added, balance now %d.", payment.Credits, bs.creditBalance(payment.Scope, payment.SubjectID)))
+}
@@ -0,0 +1,248 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+//go:embed web/dashboard.html
+var dashboardFS embed.FS
+
+var dashboardTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+}).ParseFS(dashboardFS, "web/dashboard.html"))
+
+// chatStat is one row of the dashboard's chat table
+type chatStat struct {
+	ChatID      int64     `bson:"_id"`
+	Title       string    `bson:"-"`
+	Total       int       `bson:"total"`
+	LastDay     int       `bson:"last_day"`
+	LastMessage time.Time `bson:"last_message"`
+}
+
+// startDashboard serves the admin web UI on cfg.DashboardAddr, protected by basic auth
+func (bs *BotService) startDashboard() {
+	if bs.config().DashboardAddr == "" {
+		return
+	}
+	if bs.config().DashboardPassword == "" {
+		log.Printf("DASHBOARD_ADDR is set but DASHBOARD_PASSWORD is empty, not starting the dashboard")
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", bs.handleDashboardIndex)
+	mux.HandleFunc("GET /chats/{id}", bs.handleDashboardChat)
+	mux.HandleFunc("POST /chats/{id}", bs.handleDashboardChatUpdate)
+
+	server := &http.Server{
+		Addr:              bs.config().DashboardAddr,
+		Handler:           bs.requireDashboardAuth(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("Dashboard listening on %s", bs.config().DashboardAddr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Dashboard server error: %v", err)
+		}
+	}()
+}
+
+func (bs *BotService) requireDashboardAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(bs.config().DashboardUser)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(bs.config().DashboardPassword)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="ChatBuddy dashboard"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// Basic auth is resent by the browser on cross-site requests, so reject forms posted from other origins
+		if r.Method == http.MethodPost {
+			if origin := r.Header.Get("Origin"); origin != "" {
+				if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+					http.Error(w, "cross-origin request rejected", http.StatusForbidden)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (bs *BotService) handleDashboardIndex(w http.ResponseWriter, r *http.Request) {
+	chats, err := bs.chatStats()
+	if err != nil {
+		log.Printf("Error loading chat stats: %v", err)
+	}
+	for i := range chats {
+		chats[i].Title = bs.chatTitle(chats[i].ChatID)
+	}
+
+	var usage llm.Usage
+	if reporter, ok := bs.gemini.(llm.UsageReporter); ok {
+		usage = reporter.Usage()
+	}
+
+	var bots []botStat
+	if len(bs.fleet) > 1 {
+		for _, b := range bs.fleet {
+			bots = append(bots, b.stats())
+		}
+	}
+
+	bs.renderDashboard(w, "index", map[string]any{
+		"Bot":    strings.TrimPrefix(bs.botMention, "@"),
+		"Bots":   bots,
+		"Uptime": time.Since(bs.startedAt).Round(time.Second),
+		"Chats":  chats,
+		"Errors": recentErrors.recent(),
+		"Gemini": usage,
+		"Spam":   map[string]int64{"Flagged": bs.spam.flagged.Load(), "Deleted": bs.spam.deleted.Load()},
+	})
+}
+
+func (bs *BotService) handleDashboardChat(w http.ResponseWriter, r *http.Request) {
+	chatID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid chat id", http.StatusBadRequest)
+		return
+	}
+	bs.renderChatSettings(w, chatID, r.URL.Query().Get("saved") == "1", "")
+}
+
+func (bs *BotService) handleDashboardChatUpdate(w http.ResponseWriter, r *http.Request) {
+	chatID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid chat id", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+
+	changes := bson.M{
+		"rules":                    strings.TrimSpace(r.PostForm.Get("rules")),
+		"moderation_enabled":       r.PostForm.Get("moderation_enabled") == "on",
+		"event_detection_disabled": r.PostForm.Get("event_detection_enabled") != "on",
+	}
+
+	if zone := strings.TrimSpace(r.PostForm.Get("timezone")); zone != "" {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			bs.renderChatSettings(w, chatID, false, "Unknown timezone "+zone)
+			return
+		}
+		changes["timezone"] = loc.String()
+	} else {
+		changes["timezone"] = ""
+	}
+
+	if hour := strings.TrimSpace(r.PostForm.Get("feed_digest_hour")); hour != "" {
+		h, err := strconv.Atoi(hour)
+		if err != nil || h < 0 || h > 23 {
+			bs.renderChatSettings(w, chatID, false, "Feed digest hour must be between 0 and 23")
+			return
+		}
+		changes["feed_digest_hour"] = h
+	} else {
+		changes["feed_digest_hour"] = nil
+	}
+
+	if err := bs.store.UpdateChatSettings(chatID, changes); err != nil {
+		log.Printf("Error saving settings from dashboard: %v", err)
+		bs.renderChatSettings(w, chatID, false, "Saving failed, check the logs")
+		return
+	}
+	bs.saveAuditEntry(store.AuditEntry{
+		ChatID:    chatID,
+		Actor:     "dashboard (" + bs.config().DashboardUser + ")",
+		Action:    "settings",
+		Details:   truncateRunes(fmt.Sprint(changes), 500),
+		CreatedAt: time.Now(),
+	})
+	http.Redirect(w, r, "/chats/"+strconv.FormatInt(chatID, 10)+"?saved=1", http.StatusSeeOther)
+}
+
+func (bs *BotService) renderChatSettings(w http.ResponseWriter, chatID int64, saved bool, formErr string) {
+	settings := bs.store.ChatSettings(chatID)
+	digestHour := ""
+	if settings.FeedDigestHour != nil {
+		digestHour = strconv.Itoa(*settings.FeedDigestHour)
+	}
+
+	audit, err := bs.store.AuditEntries(chatID, 20)
+	if err != nil {
+		log.Printf("Error loading audit log for chat %d: %v", chatID, err)
+	}
+
+	bs.renderDashboard(w, "chat", map[string]any{
+		"Audit":             audit,
+		"ChatID":            chatID,
+		"Title":             bs.chatTitle(chatID),
+		"Settings":          settings,
+		"DigestHour":        digestHour,
+		"DefaultDigestHour": defaultDigestHour,
+		"DefaultTimezone":   bs.config().DefaultLocation.String(),
+		"Saved":             saved,
+		"Error":             formErr,
+	})
+}
+
+func (bs *BotService) renderDashboard(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplates.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Error rendering dashboard template %s: %v", name, err)
+	}
+}
+
+// chatStats aggregates message volumes per chat, most recently active first
+func (bs *BotService) chatStats() ([]chatStat, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	dayAgo := time.Now().Add(-24 * time.Hour)
+	cursor, err := bs.db.Collection("messages").Aggregate(ctx, []bson.M{
+		{"$group": bson.M{
+			"_id":          "$chat_id",
+			"total":        bson.M{"$sum": 1},
+			"last_day":     bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gte": bson.A{"$timestamp", dayAgo}}, 1, 0}}},
+			"last_message": bson.M{"$max": "$timestamp"},
+		}},
+		{"$sort": bson.M{"last_message": -1}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stats []chatStat
+	if err := cursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// chatTitle returns a chat's display name
+func (bs *BotService) chatTitle(chatID int64) string {
+	return bs.chatInfo(chatID).Title
+}
@@ -0,0 +1,367 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	commitmentsLookback  = 30 * 24 * time.Hour
+	noDecisionsMsg       = "I couldn't find any decisions in the recent messages."
+	noActionItemsMsg     = "I couldn't find any action items in the recent messages."
+	noStoredItemsMsg     = "You have no action items from the last 30 days."
+	noStoredDecisionsMsg = "No decisions were recorded in the last 30 days. Run /decisions to extract them from recent messages."
+)
+
+// decisionsSchema is the answer of the decisions extraction
+var decisionsSchema = &llm.Schema{
+	Type: "object",
+	Properties: map[string]*llm.Schema{
+		"decisions": {Type: "array", Items: &llm.Schema{Type: "string"}},
+	},
+	Required: []string{"decisions"},
+}
+
+// actionItemsSchema is the answer of the action items extraction
+var actionItemsSchema = &llm.Schema{
+	Type: "object",
+	Properties: map[string]*llm.Schema{
+		"action_items": {Type: "array", Items: &llm.Schema{
+			Type: "object",
+			Properties: map[string]*llm.Schema{
+				"owner": {Type: "string", Description: `username without @, or "unassigned"`},
+				"task":  {Type: "string"},
+				"due":   {Type: "string", Description: "due date as written in the chat", Nullable: true},
+			},
+			Required: []string{"owner", "task"},
+		}},
+	},
+	Required: []string{"action_items"},
+}
+
+// commitmentsQuestionPattern matches questions like "what did I agree to do last week?"
+var commitmentsQuestionPattern = regexp.MustCompile(`(?i)\b(agreed?|promised?|action items?|decided|decisions?|my tasks)\b`)
+
+// Decision is a decision extracted from chat history
+type Decision struct {
+	ChatID      int64     `bson:"chat_id"`
+	Text        string    `bson:"text"`
+	ExtractedAt time.Time `bson:"extracted_at"`
+}
+
+// ActionItem is a task with an owner extracted from chat history
+type ActionItem struct {
+	ChatID      int64     `bson:"chat_id"`
+	Owner       string    `bson:"owner"`
+	Task        string    `bson:"task"`
+	Due         string    `bson:"due,omitempty"`
+	ExtractedAt time.Time `bson:"extracted_at"`
+}
+
+func (bs *BotService) handleDecisions(msg *tgbotapi.Message) {
+	if strings.TrimSpace(msg.CommandArguments()) == "list" {
+		bs.Reply(msg, bs.formatStoredDecisions(msg.Chat.ID))
+		return
+	}
+
+	var extracted struct {
+		Decisions []string `json:"decisions"`
+	}
+	err := bs.extractFromHistory(bs.billedContext(msg), msg.Chat.ID, `List every decision the participants made or agreed on, each as one short sentence. Return an empty list if no decisions were made.`,
+		decisionsSchema, &extracted, nil)
+	if err != nil {
+		log.Printf("decision extraction error: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	var decisions []Decision
+	for _, text := range extracted.Decisions {
+		if text = strings.TrimSpace(text); text != "" {
+			decisions = append(decisions, Decision{ChatID: msg.Chat.ID, Text: text, ExtractedAt: time.Now()})
+		}
+	}
+	if len(decisions) == 0 {
+		bs.Reply(msg, noDecisionsMsg)
+		return
+	}
+	bs.storeDecisions(decisions)
+
+	var sb strings.Builder
+	sb.WriteString("📌 Decisions:\n")
+	for _, d := range decisions {
+		sb.WriteString("- " + d.Text + "\n")
+	}
+	bs.Reply(msg, sb.String())
+}
+
+func (bs *BotService) handleActionItems(msg *tgbotapi.Message) {
+	if strings.TrimSpace(msg.CommandArguments()) == "mine" {
+		bs.Reply(msg, bs.formatStoredActionItems(msg.Chat.ID, msg.From))
+		return
+	}
+
+	var extracted struct {
+		ActionItems []struct {
+			Owner string  `json:"owner"`
+			Task  string  `json:"task"`
+			Due   *string `json:"due"`
+		} `json:"action_items"`
+	}
+	validate := func() error {
+		for i, item := range extracted.ActionItems {
+			if strings.TrimSpace(item.Task) == "" {
+				return fmt.Errorf("action item %d has no task", i+1)
+			}
+		}
+		return nil
+	}
+	err := bs.extractFromHistory(bs.billedContext(msg), msg.Chat.ID, `List every action item: a task someone committed to or was asked to do. The owner is their username without @, or "unassigned"; leave due empty when no date was given. Return an empty list if there are no action items.`,
+		actionItemsSchema, &extracted, validate)
+	if err != nil {
+		log.Printf("action item extraction error: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	var items []ActionItem
+	for _, extractedItem := range extracted.ActionItems {
+		item := ActionItem{
+			ChatID:      msg.Chat.ID,
+			Owner:       normalizeOwner(extractedItem.Owner),
+			Task:        strings.TrimSpace(extractedItem.Task),
+			ExtractedAt: time.Now(),
+		}
+		if extractedItem.Due != nil && !strings.EqualFold(strings.TrimSpace(*extractedItem.Due), "none") {
+			item.Due = strings.TrimSpace(*extractedItem.Due)
+		}
+		items = append(items, item)
+	}
+
+	if len(items) == 0 {
+		bs.Reply(msg, noActionItemsMsg)
+		return
+	}
+	bs.storeActionItems(items)
+
+	var sb strings.Builder
+	sb.WriteString("✅ Action items:\n")
+	for _, item := range items {
+		sb.WriteString(formatActionItem(item) + "\n")
+	}
+	bs.Reply(msg, sb.String())
+}
+
+// extractFromHistory runs an extraction prompt over recent chat history and decodes the JSON answer, matching
+// schema, into out; with no history out is left empty
+func (bs *BotService) extractFromHistory(ctx context.Context, chatID int64, instructions string, schema *llm.Schema, out any, validate func() error) error {
+	messages, err := bs.fetchMessagesFromDB(chatID, maxMessagesToFetch)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	prompt := fmt.Sprintf(`Below are the latest %d messages from a Telegram chat:
+
+%s
+
+%s
+Keep the language of the messages.`,
+		len(messages), untrusted("chat messages", strings.Join(messages, "\n")), instructions)
+
+	ctx, cancel := context.WithTimeout(ctx, 90*time.Second)
+	defer cancel()
+
+	return bs.generateJSON(ctx, prompt, schema, out, validate)
+}
+
+func (bs *BotService) storeDecisions(decisions []Decision) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := bs.db.Collection("decisions")
+	for _, d := range decisions {
+		// Re-running the extraction over overlapping history must not duplicate entries
+		_, err := collection.UpdateOne(ctx,
+			bson.M{"chat_id": d.ChatID, "text": d.Text},
+			bson.M{"$setOnInsert": d},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			log.Printf("Error storing decision: %v", err)
+		}
+	}
+}
+
+func (bs *BotService) storeActionItems(items []ActionItem) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := bs.db.Collection("action_items")
+	for _, item := range items {
+		_, err := collection.UpdateOne(ctx,
+			bson.M{"chat_id": item.ChatID, "owner": item.Owner, "task": item.Task},
+			bson.M{"$setOnInsert": item},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			log.Printf("Error storing action item: %v", err)
+		}
+	}
+}
+
+func (bs *BotService) loadDecisions(chatID int64, since time.Time) []Decision {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := bs.db.Collection("decisions").Find(ctx,
+		bson.M{"chat_id": chatID, "extracted_at": bson.M{"$gte": since}},
+		options.Find().SetSort(bson.D{{Key: "extracted_at", Value: 1}}),
+	)
+	if err != nil {
+		log.Printf("Error loading decisions: %v", err)
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var decisions []Decision
+	if err := cursor.All(ctx, &decisions); err != nil {
+		log.Printf("Error decoding decisions: %v", err)
+	}
+	return decisions
+}
+
+// loadActionItems returns the action items of a chat since a date, optionally only those of one owner
+func (bs *BotService) loadActionItems(chatID int64, owner string, since time.Time) []ActionItem {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"chat_id": chatID, "extracted_at": bson.M{"$gte": since}}
+	if owner != "" {
+		filter["owner"] = owner
+	}
+
+	cursor, err := bs.db.Collection("action_items").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "extracted_at", Value: 1}}),
+	)
+	if err != nil {
+		log.Printf("Error loading action items: %v", err)
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var items []ActionItem
+	if err := cursor.All(ctx, &items); err != nil {
+		log.Printf("Error decoding action items: %v", err)
+	}
+	return items
+}
+
+func (bs *BotService) formatStoredDecisions(chatID int64) string {
+	decisions := bs.loadDecisions(chatID, time.Now().Add(-commitmentsLookback))
+	if len(decisions) == 0 {
+		return noStoredDecisionsMsg
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📌 Decisions from the last 30 days:\n")
+	for _, d := range decisions {
+		sb.WriteString(fmt.Sprintf("- [%s] %s\n", d.ExtractedAt.Format("2006-01-02"), d.Text))
+	}
+	return sb.String()
+}
+
+func (bs *BotService) formatStoredActionItems(chatID int64, user *tgbotapi.User) string {
+	if user == nil || user.UserName == "" {
+		return noStoredItemsMsg
+	}
+
+	items := bs.loadActionItems(chatID, normalizeOwner(user.UserName), time.Now().Add(-commitmentsLookback))
+	if len(items) == 0 {
+		return noStoredItemsMsg
+	}
+
+	var sb strings.Builder
+	sb.WriteString("✅ Your action items from the last 30 days:\n")
+	for _, item := range items {
+		sb.WriteString(fmt.Sprintf("[%s] %s\n", item.ExtractedAt.Format("2006-01-02"), formatActionItem(item)))
+	}
+	return sb.String()
+}
+
+func (bs *BotService) isCommitmentsQuestion(question string) bool {
+	return commitmentsQuestionPattern.MatchString(question)
+}
+
+// answerFromCommitments answers questions about past decisions and action items from the stored records
+func (bs *BotService) answerFromCommitments(msg *tgbotapi.Message, question string) string {
+	since := time.Now().Add(-commitmentsLookback)
+	decisions := bs.loadDecisions(msg.Chat.ID, since)
+	items := bs.loadActionItems(msg.Chat.ID, "", since)
+	if len(decisions) == 0 && len(items) == 0 {
+		return bs.generateResponse(msg, question)
+	}
+
+	var records []string
+	for _, d := range decisions {
+		records = append(records, fmt.Sprintf("[%s] decision: %s", d.ExtractedAt.Format("2006-01-02"), d.Text))
+	}
+	for _, item := range items {
+		records = append(records, fmt.Sprintf("[%s] action item: %s", item.ExtractedAt.Format("2006-01-02"), formatActionItem(item)))
+	}
+
+	prompt := fmt.Sprintf(`You are a helpful Telegram bot that keeps track of a group's decisions and action items.
+Today is %s. The user asking is %s.
+
+Recorded decisions and action items (with the date they were recorded):
+%s
+
+The user asked: "%s"
+
+Answer instructions:
+1. Answer only from the records above; say so if they don't contain the answer
+2. Keep it brief (2-4 sentences or a short list), plain text, no markdown
+3. Response language: Same as the user's message`,
+		time.Now().Format("2006-01-02"), displayName(msg.From), strings.Join(records, "\n"), question)
+
+	ctx, cancel := context.WithTimeout(bs.billedContext(msg), 60*time.Second)
+	defer cancel()
+
+	answer, err := bs.generate(ctx, prompt)
+	if err != nil {
+		log.Printf("gemini commitments answer error: %v", err)
+		return responseErrorMsg
+	}
+	return answer
+}
+
+func formatActionItem(item ActionItem) string {
+	owner := "unassigned"
+	if item.Owner != "" {
+		owner = "@" + item.Owner
+	}
+	line := fmt.Sprintf("- %s: %s", owner, item.Task)
+	if item.Due != "" {
+		line += " (due " + item.Due + ")"
+	}
+	return line
+}
+
+func normalizeOwner(owner string) string {
+	owner = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(owner), "@"))
+	if owner == "unassigned" {
+		return ""
+	}
+	return owner
+}
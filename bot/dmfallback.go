@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	dmFallbackNote = "I can't post in %s because %s, so here's my reply to your message there:"
+	noRightsReason = "I don't have permission to send messages there"
+)
+
+// postingErrors are the Telegram error descriptions, lowercased, that mean the bot may not post in a chat at
+// all, with how the DM fallback explains it; removed is set for those saying the bot is no longer in the chat
+var postingErrors = []struct {
+	match, reason string
+	removed       bool
+}{
+	{"bot was kicked", "I was removed from it", true},
+	{"bot is not a member", "I'm no longer a member", true},
+	{"not enough rights", noRightsReason, false},
+	{"have no rights to send", noRightsReason, false},
+	{"chat_write_forbidden", noRightsReason, false},
+}
+
+// postingError returns how err says the bot may not post in the chat, "" for other errors, and whether the
+// bot was removed from the chat
+func postingError(err error) (reason string, removed bool) {
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) || (tgErr.Code != 400 && tgErr.Code != 403) {
+		return "", false
+	}
+	description := strings.ToLower(tgErr.Message)
+	for _, e := range postingErrors {
+		if strings.Contains(description, e.match) {
+			return e.reason, e.removed
+		}
+	}
+	return "", false
+}
+
+// markCannotPost records that the bot may not post in a chat, in the registry once per chat; a bot that was
+// removed is marked as having left
+func (bs *BotService) markCannotPost(chatID int64, err error) {
+	reason, removed := postingError(err)
+	if reason == "" {
+		return
+	}
+	if _, known := bs.cannotPost.Swap(chatID, reason); known {
+		return
+	}
+	log.Printf("Can't post in chat %d: %v", chatID, err)
+	if removed {
+		err = bs.store.RecordChatLeave(chatID, bs.id)
+	} else {
+		err = bs.store.RecordCannotPost(chatID, true)
+	}
+	if err != nil {
+		log.Printf("Error marking chat %d as one the bot can't post in: %v", chatID, err)
+	}
+}
+
+// clearCannotPost forgets that the bot couldn't post in a chat, once a message got through or its rights changed
+func (bs *BotService) clearCannotPost(chatID int64) {
+	if _, ok := bs.cannotPost.LoadAndDelete(chatID); !ok {
+		return
+	}
+	if err := bs.store.RecordCannotPost(chatID, false); err != nil {
+		log.Printf("Error clearing the posting mark of chat %d: %v", chatID, err)
+	}
+}
+
+// loadCannotPost remembers the chats the registry marks as ones the bot can't post in, so the mark is cleared
+// when a message gets through again
+func (bs *BotService) loadCannotPost() {
+	chats, err := bs.store.Chats(false)
+	if err != nil {
+		log.Printf("Error loading the chats registry: %v", err)
+		return
+	}
+	for _, c := range chats {
+		if c.CannotPostSince != nil {
+			bs.cannotPost.Store(c.ChatID, noRightsReason)
+		}
+	}
+}
+
+// replyInDM delivers a reply the bot couldn't post in a group to the user who asked for it, with a note why
+func (bs *BotService) replyInDM(response tgbotapi.MessageConfig) {
+	reason, ok := bs.cannotPost.Load(response.ChatID)
+	if !ok || response.ReplyToMessageID == 0 {
+		return
+	}
+	userID := bs.requesterOf(response.ChatID, response.ReplyToMessageID)
+	if userID == 0 {
+		return
+	}
+
+	note := fmt.Sprintf(dmFallbackNote, cmp.Or(bs.chatTitle(response.ChatID), "the group"), reason)
+	if bs.send(tgbotapi.NewMessage(userID, note)).MessageID == 0 {
+		// The user never started a chat with the bot
+		return
+	}
+	dm := tgbotapi.NewMessage(userID, response.Text)
+	dm.ParseMode = response.ParseMode
+	bs.send(dm)
+}
+
+// requesterOf returns who sent a message the bot replies to: the asker of a request in flight, or the sender
+// stored with the message; 0 when unknown
+func (bs *BotService) requesterOf(chatID int64, messageID int) int64 {
+	if r, ok := bs.inflightRequest(chatID, messageID); ok && r.userID != 0 {
+		return r.userID
+	}
+	messages, err := bs.store.MessagesByID(chatID, []int{messageID})
+	if err != nil {
+		log.Printf("Error loading message %d of chat %d: %v", messageID, chatID, err)
+		return 0
+	}
+	if len(messages) == 0 {
+		return 0
+	}
+	return messages[0].FromID
+}
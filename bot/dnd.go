@@ -0,0 +1,117 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// dmCategory is a kind of DM the bot sends on its own, which users can turn off with /notify
+type dmCategory string
+
+const (
+	// dmDigests are the copies of group digests users subscribed to
+	dmDigests dmCategory = "digests"
+	// dmAlerts are the moderation alerts sent to group admins
+	dmAlerts dmCategory = "alerts"
+	// dmReports are the owner's monthly reports, budget warnings and notices about left chats
+	dmReports dmCategory = "reports"
+)
+
+// dmCategories are the categories in /notify order, with what they are
+var (
+	dmCategories   = []dmCategory{dmDigests, dmAlerts, dmReports}
+	dmDescriptions = map[dmCategory]string{
+		dmDigests: "copies of group digests",
+		dmAlerts:  "moderation alerts of the groups you admin",
+		dmReports: "monthly reports and budget warnings (owner)",
+	}
+)
+
+const (
+	dndUsageMsg = `Usage:
+/dnd 22:00-08:00 - hold back my DMs during these hours, in your timezone (/timezone)
+/dnd off - DM any time
+Held-back DMs are sent when the hours end.`
+	notifyUsageMsg = `Usage:
+/notify - show which DMs you get
+/notify <digests|alerts|reports> on|off - turn a kind of DM on or off`
+)
+
+// sendDM sends a user a DM of a category, unless they turned it off with /notify, holding it back during
+// their /dnd hours
+func (bs *BotService) sendDM(userID int64, category dmCategory, kind messageKind, text string) {
+	if slices.Contains(bs.store.UserPreferences(userID).MutedDMs, string(category)) {
+		return
+	}
+	bs.holdOrSend(deferredMessage{ChatID: userID, Text: text, Kind: kind})
+}
+
+func (bs *BotService) handleDND(msg *tgbotapi.Message) {
+	arg := strings.TrimSpace(msg.CommandArguments())
+	loc := bs.userLocation(msg.From.ID, msg.Chat.ID)
+	switch {
+	case arg == "":
+		status := "Do not disturb is off."
+		if spec := bs.store.UserPreferences(msg.From.ID).DNDHours; spec != "" {
+			status = fmt.Sprintf("Do not disturb: %s (%s).", spec, loc)
+		}
+		bs.Reply(msg, status+"\n\n"+dndUsageMsg)
+		return
+	case strings.EqualFold(arg, "off"):
+		arg = ""
+	default:
+		if _, _, err := parseQuietHours(arg); err != nil {
+			bs.Reply(msg, fmt.Sprintf("%v\n\n%s", err, dndUsageMsg))
+			return
+		}
+		arg = strings.ReplaceAll(arg, " ", "")
+	}
+
+	if err := bs.store.UpdateUserPreferences(msg.From.ID, bson.M{"dnd_hours": arg}); err != nil {
+		log.Printf("Error saving do-not-disturb hours of user %d: %v", msg.From.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if arg == "" {
+		bs.Reply(msg, "Do not disturb is off.")
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("🌙 I'll hold back digests, alerts and reports I'd DM you from %s (%s) until the hours end.", arg, loc))
+}
+
+func (bs *BotService) handleNotify(msg *tgbotapi.Message) {
+	args := strings.Fields(strings.ToLower(msg.CommandArguments()))
+	muted := bs.store.UserPreferences(msg.From.ID).MutedDMs
+	if len(args) == 0 {
+		var sb strings.Builder
+		sb.WriteString("🔔 DMs I send you:\n")
+		for _, c := range dmCategories {
+			fmt.Fprintf(&sb, "\n%s: %s, %s", c, onOff(!slices.Contains(muted, string(c))), dmDescriptions[c])
+		}
+		bs.Reply(msg, sb.String()+"\n\n"+notifyUsageMsg)
+		return
+	}
+
+	// "/notify digest off" works as well as "digests"
+	category := dmCategory(strings.TrimSuffix(args[0], "s") + "s")
+	if len(args) != 2 || !slices.Contains(dmCategories, category) || (args[1] != "on" && args[1] != "off") {
+		bs.Reply(msg, notifyUsageMsg)
+		return
+	}
+	muted = slices.DeleteFunc(muted, func(c string) bool { return c == string(category) })
+	if args[1] == "off" {
+		muted = append(muted, string(category))
+	}
+
+	if err := bs.store.UpdateUserPreferences(msg.From.ID, bson.M{"muted_dms": muted}); err != nil {
+		log.Printf("Error saving DM preferences of user %d: %v", msg.From.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("🔔 DMs with %s are %s.", dmDescriptions[category], args[1]))
+}
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/store"
+	"github.com/sg-milad/ChatBuddy/vectorstore"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// embeddingIdleDelay is how long the worker waits after finding nothing to embed or failing
+const embeddingIdleDelay = 30 * time.Second
+
+// embedPending embeds one batch of messages that have none yet and returns how many it embedded.
+// A failed batch is retried later with backoff, see store.MarkEmbeddingFailed.
+func embedPending(ctx context.Context, db *store.Store, vectors vectorstore.VectorStore, embedder llm.Embedder, model string, batchSize int) (int, error) {
+	pending, err := db.PendingEmbeddings(batchSize)
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+
+	texts := make([]string, len(pending))
+	for i, p := range pending {
+		texts[i] = p.Text
+	}
+	embedded, err := embedder.EmbedTexts(ctx, model, texts)
+	if err != nil {
+		if markErr := db.MarkEmbeddingFailed(pending, err); markErr != nil {
+			log.Printf("Error recording failed embeddings: %v", markErr)
+		}
+		return 0, err
+	}
+
+	points := make([]vectorstore.Point, len(pending))
+	ids := make([]primitive.ObjectID, len(pending))
+	for i, p := range pending {
+		points[i] = vectorstore.Point{ID: p.ID.Hex(), ChatID: p.ChatID, MessageID: p.MessageID, Vector: embedded[i]}
+		ids[i] = p.ID
+	}
+	if err := vectors.Upsert(ctx, points); err != nil {
+		if markErr := db.MarkEmbeddingFailed(pending, err); markErr != nil {
+			log.Printf("Error recording failed embeddings: %v", markErr)
+		}
+		return 0, fmt.Errorf("storing vectors: %w", err)
+	}
+	if err := db.MarkEmbedded(ids); err != nil {
+		return 0, err
+	}
+	return len(points), nil
+}
+
+// runEmbeddingWorker embeds new and historical messages in batches until ctx is done, so message handling
+// never waits for the embedding API
+func runEmbeddingWorker(ctx context.Context, db *store.Store, vectors vectorstore.VectorStore, embedder llm.Embedder, cfg *Config) {
+	if err := db.EnsureEmbeddingIndexes(); err != nil {
+		log.Printf("Error creating index: %v", err)
+	}
+	log.Printf("Embedding worker started with %s into the %s vector store", cmp.Or(cfg.EmbeddingModel, llm.DefaultEmbeddingModel), cfg.VectorStore)
+
+	for {
+		n, err := embedBatchSafely(ctx, db, vectors, embedder, cfg)
+		if err != nil {
+			log.Printf("Error embedding messages: %v", err)
+		}
+
+		delay := time.Second
+		if err != nil || n < cfg.EmbeddingBatchSize {
+			delay = embeddingIdleDelay
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// embedBatchSafely keeps a panic in one batch from stopping the worker
+func embedBatchSafely(ctx context.Context, db *store.Store, vectors vectorstore.VectorStore, embedder llm.Embedder, cfg *Config) (n int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	batchCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	return embedPending(batchCtx, db, vectors, embedder, cfg.EmbeddingModel, cfg.EmbeddingBatchSize)
+}
+
+// startEmbeddingWorker runs the worker in the bot process when EMBEDDINGS_ENABLED is set
+func (bs *BotService) startEmbeddingWorker() {
+	if !bs.config().EmbeddingsEnabled {
+		return
+	}
+	embedder, ok := bs.gemini.(llm.Embedder)
+	if !ok {
+		log.Printf("Embeddings are enabled, but the model client can't compute them")
+		return
+	}
+	go runEmbeddingWorker(context.Background(), bs.store, bs.vectors, embedder, bs.config())
+}
+
+func (bs *BotService) handleEmbeddings(msg *tgbotapi.Message) {
+	stats, err := bs.store.EmbeddingStats()
+	if err != nil {
+		log.Printf("Error loading embedding stats: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	state := "off in the bot (EMBEDDINGS_ENABLED), a separate chatbuddy embed worker may be running"
+	if bs.config().EmbeddingsEnabled {
+		state = "on"
+	}
+	bs.Reply(msg, fmt.Sprintf("🧮 Embeddings: %s\nEmbedded: %d messages\nPending: %d\nGave up after %d attempts: %d",
+		state, stats.Embedded, stats.Pending, store.MaxEmbedAttempts, stats.Failed))
+}
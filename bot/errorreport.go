@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// errorReportDedupWindow is how long an error is not reported again after it was sent
+	errorReportDedupWindow = 15 * time.Minute
+	maxErrorReportsPerHour = 10
+	maxReportedStackLen    = 3000
+
+	// Log lines with these markers are not forwarded: panics are reported with their stack
+	// by recoverPanic, and the reporter's own failures would loop
+	panicLogMarker    = "recovered panic in "
+	reporterLogMarker = "error reporter: "
+)
+
+// errorKeyDigits is replaced in error texts before deduplication, so errors that only
+// differ in timestamps, chat IDs or counts are treated as the same error
+var errorKeyDigits = regexp.MustCompile(`\d+`)
+
+// errorReporter rate-limits and deduplicates the reports sent to ERROR_CHAT_ID
+type errorReporter struct {
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+	repeats  map[string]int
+	sent     []time.Time
+	dropped  int
+}
+
+// allow decides whether the error with key may be reported now. When it may, it also returns how many
+// duplicates of it were suppressed since its last report and how many reports the rate limit dropped.
+func (r *errorReporter) allow(key string, now time.Time) (ok bool, repeats, dropped int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.lastSent == nil {
+		r.lastSent = make(map[string]time.Time)
+		r.repeats = make(map[string]int)
+	}
+	for k, t := range r.lastSent {
+		if now.Sub(t) >= errorReportDedupWindow && k != key {
+			delete(r.lastSent, k)
+			delete(r.repeats, k)
+		}
+	}
+
+	if last, seen := r.lastSent[key]; seen && now.Sub(last) < errorReportDedupWindow {
+		r.repeats[key]++
+		return false, 0, 0
+	}
+
+	for len(r.sent) > 0 && now.Sub(r.sent[0]) >= time.Hour {
+		r.sent = r.sent[1:]
+	}
+	if len(r.sent) >= maxErrorReportsPerHour {
+		r.dropped++
+		return false, 0, 0
+	}
+
+	repeats, dropped = r.repeats[key], r.dropped
+	r.sent = append(r.sent, now)
+	r.lastSent[key] = now
+	r.repeats[key] = 0
+	r.dropped = 0
+	return true, repeats, dropped
+}
+
+func errorKey(text string) string {
+	return truncateRunes(errorKeyDigits.ReplaceAllString(text, "N"), 200)
+}
+
+// reportError sends an error, and the stack trace if given, to the admin chat set with ERROR_CHAT_ID
+func (bs *BotService) reportError(text string, stack []byte) {
+	chatID := bs.config().ErrorChatID
+	if chatID == 0 {
+		return
+	}
+	ok, repeats, dropped := bs.reporter.allow(errorKey(text), time.Now())
+	if !ok {
+		return
+	}
+
+	report := "🚨 " + text
+	if repeats > 0 {
+		report += fmt.Sprintf("\n(%d more like this since the last report)", repeats)
+	}
+	if dropped > 0 {
+		report += fmt.Sprintf("\n(%d other reports were dropped by the rate limit)", dropped)
+	}
+	if len(stack) > 0 {
+		report += "\n\n" + truncateRunes(string(stack), maxReportedStackLen)
+	}
+
+	if _, err := bs.api.Send(tgbotapi.NewMessage(chatID, truncateRunes(report, 4096))); err != nil {
+		log.Printf(reporterLogMarker+"could not send to chat %d: %v", chatID, err)
+	}
+}
+
+// reportLoggedError forwards an error line captured from the log
+func (bs *BotService) reportLoggedError(line string) {
+	if strings.Contains(line, panicLogMarker) || strings.Contains(line, reporterLogMarker) {
+		return
+	}
+	bs.reportError(line, nil)
+}
+
+// recoverPanic stops a panic in the calling goroutine, logs it and reports it with its stack trace; defer it
+func (bs *BotService) recoverPanic(where string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	text := fmt.Sprintf("%s%s: %v", panicLogMarker, where, r)
+	log.Print(text)
+	// The stack skips the logger so its lines don't show up as separate errors on the dashboard
+	os.Stderr.Write(stack)
+	bs.reportError(text, stack)
+}
+
+// goSafe runs fn in a goroutine that recovers and reports panics instead of crashing the bot
+func (bs *BotService) goSafe(where string, fn func()) {
+	go func() {
+		defer bs.recoverPanic(where)
+		fn()
+	}()
+}
+
+// recoverPanics keeps a panicking handler from taking down the update loop
+func (bs *BotService) recoverPanics(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		defer bs.recoverPanic(fmt.Sprintf("update %d (%s)", update.UpdateID, updateKind(update)))
+		next(update)
+	}
+}
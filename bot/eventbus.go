@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/eventbus"
+)
+
+// publish sends an event to the bus in the background, if EVENT_BUS_URL is set and STRICT_PRIVACY isn't
+func (bs *BotService) publish(event eventbus.Event) {
+	if bs.bus == nil || bs.config().StrictPrivacy {
+		return
+	}
+	event.At = time.Now()
+	subject := bs.config().EventSubjectPrefix + "." + event.Type
+
+	bs.goSafe("event publish", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := bs.bus.Publish(ctx, subject, event); err != nil {
+			log.Printf("Error publishing %s: %v", subject, err)
+		}
+	})
+}
+
+// messageEvent normalizes a message for the bus
+func messageEvent(eventType string, msg *tgbotapi.Message) eventbus.Event {
+	event := eventbus.Event{
+		Type:      eventType,
+		ChatID:    msg.Chat.ID,
+		ChatType:  msg.Chat.Type,
+		MessageID: msg.MessageID,
+		Text:      msg.Text,
+	}
+	if event.Text == "" {
+		event.Text = msg.Caption
+	}
+	if msg.From != nil {
+		event.UserID = msg.From.ID
+		event.Username = msg.From.UserName
+	}
+	if msg.ReplyToMessage != nil {
+		event.ReplyTo = msg.ReplyToMessage.MessageID
+	}
+	return event
+}
@@ -0,0 +1,428 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	eventStatusSuggested = "suggested"
+	eventStatusSaved     = "saved"
+	eventDateLayout      = "2006-01-02 15:04"
+	eventsLookahead      = 30 * 24 * time.Hour
+	morningReminderHour  = 8
+	noUpcomingEventsMsg  = "No upcoming events. Mention a plan like \"dinner Friday 8pm\" or use /events add <event>."
+	noEventsTodayMsg     = "Nothing planned for today."
+	eventsAddUsageMsg    = "Usage: /events add <event, e.g. dinner Friday 8pm>"
+	eventNotFoundMsg     = "I couldn't find a date and time in that. Try something like: /events add dinner Friday 8pm"
+	eventsDetectUsageMsg = "Usage: /events detect on|off"
+	eventGoneCallback    = "This event suggestion is no longer available."
+)
+
+// eventDayPattern and eventPlanPattern are a cheap prefilter so only messages that name a day and a time or an
+// activity, like "dinner Friday 8pm" or "meeting on 12.5 at 10:00", hit the model
+var (
+	eventDayPattern = regexp.MustCompile(`(?i)\b(today|tonight|tomorrow|monday|tuesday|wednesday|thursday|friday|saturday|sunday|next week|this weekend|` +
+		`\d{1,2}(st|nd|rd|th)? (january|february|march|april|may|june|july|august|september|october|november|december)|` +
+		`(january|february|march|april|may|june|july|august|september|october|november|december) \d{1,2}(st|nd|rd|th)?|` +
+		`\d{1,2}[./]\d{1,2}([./]\d{2,4})?)\b`)
+	eventPlanPattern = regexp.MustCompile(`(?i)\b(\d{1,2}(:\d{2})?\s?(am|pm)|\d{1,2}:\d{2}|noon|midnight|` +
+		`meet|meeting|meetup|call|dinner|lunch|breakfast|brunch|drinks|party|birthday|trip|flight|match|game|concert|` +
+		`movie|class|appointment|deadline|event|standup|workshop|webinar)\b`)
+)
+
+// Event is a calendar entry of a chat
+type Event struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty"`
+	ChatID          int64              `bson:"chat_id"`
+	Title           string             `bson:"title"`
+	StartsAt        time.Time          `bson:"starts_at"`
+	Status          string             `bson:"status"`
+	CreatedBy       int64              `bson:"created_by"`
+	SourceMessageID int                `bson:"source_message_id,omitempty"`
+	CreatedAt       time.Time          `bson:"created_at"`
+}
+
+func (bs *BotService) handleEventsCommand(msg *tgbotapi.Message) {
+	sub, rest, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+
+	switch strings.ToLower(sub) {
+	case "":
+		bs.Reply(msg, bs.formatEvents(msg.Chat.ID, time.Now(), time.Now().Add(eventsLookahead), noUpcomingEventsMsg))
+	case "add":
+		bs.handleAddEvent(msg, strings.TrimSpace(rest))
+	case "ics":
+		bs.sendEventsICS(msg)
+	case "detect":
+		bs.handleEventDetectionToggle(msg, strings.TrimSpace(rest))
+	default:
+		bs.Reply(msg, "Usage: /events [add <event>|ics|detect on|off]")
+	}
+}
+
+func (bs *BotService) handleToday(msg *tgbotapi.Message) {
+	start, end := dayBounds(time.Now().In(bs.chatLocation(msg.Chat.ID)))
+	bs.Reply(msg, bs.formatEvents(msg.Chat.ID, start, end, noEventsTodayMsg))
+}
+
+func (bs *BotService) handleAddEvent(msg *tgbotapi.Message, text string) {
+	if text == "" {
+		bs.Reply(msg, eventsAddUsageMsg)
+		return
+	}
+
+	title, startsAt, ok := bs.extractEvent(bs.billedContext(msg), text, bs.userLocation(msg.From.ID, msg.Chat.ID))
+	if !ok {
+		bs.Reply(msg, eventNotFoundMsg)
+		return
+	}
+
+	event := Event{
+		ChatID:    msg.Chat.ID,
+		Title:     title,
+		StartsAt:  startsAt,
+		Status:    eventStatusSaved,
+		CreatedBy: msg.From.ID,
+		CreatedAt: time.Now(),
+	}
+	if _, err := bs.insertEvent(event); err != nil {
+		log.Printf("Error storing event: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, "📅 Saved: "+formatEvent(event, bs.chatLocation(msg.Chat.ID)))
+}
+
+func (bs *BotService) handleEventDetectionToggle(msg *tgbotapi.Message, arg string) {
+	if bs.userRole(msg.Chat, msg.From) < ChatAdmin {
+		bs.Reply(msg, adminOnlyMsg)
+		return
+	}
+
+	var disabled bool
+	switch strings.ToLower(arg) {
+	case "on":
+		disabled = false
+	case "off":
+		disabled = true
+	default:
+		bs.Reply(msg, eventsDetectUsageMsg)
+		return
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"event_detection_disabled": disabled}); err != nil {
+		log.Printf("Error saving event detection setting for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if disabled {
+		bs.Reply(msg, "Event detection is off.")
+		return
+	}
+	bs.Reply(msg, "Event detection is on. I'll offer to save plans mentioned in the chat.")
+}
+
+// detectEvent offers to save an event when a message mentions a plan with a date or time
+func (bs *BotService) detectEvent(msg *tgbotapi.Message) {
+	if msg.From == nil || msg.Text == "" || msg.IsCommand() || bs.config().StrictPrivacy {
+		return
+	}
+	if !eventDayPattern.MatchString(msg.Text) || !eventPlanPattern.MatchString(msg.Text) {
+		return
+	}
+	if bs.store.ChatSettings(msg.Chat.ID).EventDetectionDisabled {
+		return
+	}
+	// A suggestion only makes sense right away, so none is made during quiet hours
+	if bs.inQuietHours(msg.Chat.ID, time.Now()) {
+		return
+	}
+
+	title, startsAt, ok := bs.extractEvent(bs.messageContext(msg), msg.Text, bs.userLocation(msg.From.ID, msg.Chat.ID))
+	if !ok || startsAt.Before(time.Now()) {
+		return
+	}
+
+	event := Event{
+		ChatID:          msg.Chat.ID,
+		Title:           title,
+		StartsAt:        startsAt,
+		Status:          eventStatusSuggested,
+		CreatedBy:       msg.From.ID,
+		SourceMessageID: msg.MessageID,
+		CreatedAt:       time.Now(),
+	}
+	id, err := bs.insertEvent(event)
+	if err != nil {
+		log.Printf("Error storing event suggestion: %v", err)
+		return
+	}
+
+	offer := tgbotapi.NewMessage(msg.Chat.ID, "📅 Save this to the chat calendar?\n"+formatEvent(event, bs.chatLocation(msg.Chat.ID)))
+	offer.ReplyToMessageID = msg.MessageID
+	offer.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Save", "event:save:"+id.Hex()),
+			tgbotapi.NewInlineKeyboardButtonData("Dismiss", "event:dismiss:"+id.Hex()),
+		),
+	)
+	if _, err := bs.api.Send(offer); err != nil {
+		log.Printf("failed to send event suggestion: %v", err)
+	}
+}
+
+// eventSchema is the answer of the event extraction
+var eventSchema = &llm.Schema{
+	Type: "object",
+	Properties: map[string]*llm.Schema{
+		"event": {Type: "boolean"},
+		"title": {Type: "string"},
+		"start": {Type: "string", Description: "YYYY-MM-DD HH:MM"},
+	},
+	Required: []string{"event"},
+}
+
+// extractEvent asks the model for a single event title and start time in text, read as local time in loc
+func (bs *BotService) extractEvent(ctx context.Context, text string, loc *time.Location) (string, time.Time, bool) {
+	now := time.Now().In(loc)
+	prompt := fmt.Sprintf(`Current date and time: %s (%s).
+
+Does this chat message mention a concrete plan or event with a date or time?
+%s
+
+Set event to false if it doesn't. Otherwise give a short title and the start as YYYY-MM-DD HH:MM; use 09:00 if only a day is given.`,
+		now.Format(eventDateLayout), now.Weekday(), untrusted("message", text))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var answer struct {
+		Event bool   `json:"event"`
+		Title string `json:"title"`
+		Start string `json:"start"`
+	}
+	var startsAt time.Time
+	validate := func() error {
+		if !answer.Event {
+			return nil
+		}
+		if strings.TrimSpace(answer.Title) == "" {
+			return errors.New("the event has no title")
+		}
+		var err error
+		startsAt, err = time.ParseInLocation(eventDateLayout, strings.TrimSpace(answer.Start), loc)
+		if err != nil {
+			return fmt.Errorf("start %q is not in the YYYY-MM-DD HH:MM format", answer.Start)
+		}
+		return nil
+	}
+	if err := bs.generateJSON(ctx, prompt, eventSchema, &answer, validate); err != nil {
+		log.Printf("gemini event extraction error: %v", err)
+		return "", time.Time{}, false
+	}
+	if !answer.Event {
+		return "", time.Time{}, false
+	}
+	return strings.TrimSpace(answer.Title), startsAt, true
+}
+
+func (bs *BotService) handleEventCallback(cb *tgbotapi.CallbackQuery) {
+	parts := strings.Split(cb.Data, ":")
+	if len(parts) != 3 || cb.Message == nil {
+		bs.answerCallback(cb.ID, "")
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(parts[2])
+	if err != nil {
+		bs.answerCallback(cb.ID, eventGoneCallback)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := bs.db.Collection("events")
+	filter := bson.M{"_id": id, "chat_id": cb.Message.Chat.ID, "status": eventStatusSuggested}
+
+	switch parts[1] {
+	case "save":
+		var event Event
+		err := events.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"status": eventStatusSaved}}).Decode(&event)
+		if err != nil {
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				log.Printf("Error saving event: %v", err)
+			}
+			bs.answerCallback(cb.ID, eventGoneCallback)
+			return
+		}
+		bs.editMessageText(cb.Message.Chat.ID, cb.Message.MessageID,
+			fmt.Sprintf("📅 Saved by %s: %s", displayName(cb.From), formatEvent(event, bs.chatLocation(event.ChatID))))
+		bs.answerCallback(cb.ID, "Saved!")
+	case "dismiss":
+		if _, err := events.DeleteOne(ctx, filter); err != nil {
+			log.Printf("Error dismissing event: %v", err)
+		}
+		if _, err := bs.api.Request(tgbotapi.NewDeleteMessage(cb.Message.Chat.ID, cb.Message.MessageID)); err != nil {
+			log.Printf("failed to delete event suggestion: %v", err)
+		}
+		bs.answerCallback(cb.ID, "")
+	default:
+		bs.answerCallback(cb.ID, "")
+	}
+}
+
+func (bs *BotService) insertEvent(event Event) (primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := bs.db.Collection("events").InsertOne(ctx, event)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return res.InsertedID.(primitive.ObjectID), nil
+}
+
+// loadEvents returns saved events starting in [from, to), optionally for a single chat (chatID 0 means all chats)
+func (bs *BotService) loadEvents(chatID int64, from, to time.Time) ([]Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"status":    eventStatusSaved,
+		"starts_at": bson.M{"$gte": from, "$lt": to},
+	}
+	if chatID != 0 {
+		filter["chat_id"] = chatID
+	}
+
+	cursor, err := bs.db.Collection("events").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "starts_at", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []Event
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (bs *BotService) formatEvents(chatID int64, from, to time.Time, emptyMsg string) string {
+	events, err := bs.loadEvents(chatID, from, to)
+	if err != nil {
+		log.Printf("Error loading events: %v", err)
+		return responseErrorMsg
+	}
+	if len(events) == 0 {
+		return emptyMsg
+	}
+
+	loc := bs.chatLocation(chatID)
+	var sb strings.Builder
+	sb.WriteString("📅 Events:\n")
+	for _, event := range events {
+		sb.WriteString("- " + formatEvent(event, loc) + "\n")
+	}
+	return sb.String()
+}
+
+func (bs *BotService) sendEventsICS(msg *tgbotapi.Message) {
+	events, err := bs.loadEvents(msg.Chat.ID, time.Now(), time.Now().Add(eventsLookahead))
+	if err != nil {
+		log.Printf("Error loading events: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(events) == 0 {
+		bs.Reply(msg, noUpcomingEventsMsg)
+		return
+	}
+
+	bs.sendFile(msg, "events.ics", buildICS(events), "")
+}
+
+// sendEventReminders posts the day's events to each chat once, during the morning reminder hour in the chat timezone
+func (bs *BotService) sendEventReminders(now time.Time) {
+	// Timezones are at most a day apart, so this window covers "today" everywhere
+	events, err := bs.loadEvents(0, now.Add(-24*time.Hour), now.Add(48*time.Hour))
+	if err != nil {
+		log.Printf("Error loading events for reminders: %v", err)
+		return
+	}
+
+	byChat := make(map[int64][]Event)
+	for _, event := range events {
+		byChat[event.ChatID] = append(byChat[event.ChatID], event)
+	}
+
+	for chatID, chatEvents := range byChat {
+		local := now.In(bs.chatLocation(chatID))
+		if local.Hour() != morningReminderHour {
+			continue
+		}
+
+		start, end := dayBounds(local)
+		var today []Event
+		for _, event := range chatEvents {
+			if !event.StartsAt.Before(start) && event.StartsAt.Before(end) {
+				today = append(today, event)
+			}
+		}
+		if len(today) == 0 || !bs.claimDailyRun("event_reminders", chatID, local.Format("2006-01-02")) {
+			continue
+		}
+
+		var sb strings.Builder
+		sb.WriteString("☀️ Good morning! Today's plans:\n")
+		for _, event := range today {
+			sb.WriteString(fmt.Sprintf("- %s %s\n", event.StartsAt.In(local.Location()).Format("15:04"), event.Title))
+		}
+		bs.sendNonEssential(chatID, sb.String())
+	}
+}
+
+func formatEvent(event Event, loc *time.Location) string {
+	return fmt.Sprintf("%s — %s", event.StartsAt.In(loc).Format("Mon Jan 2, 15:04"), event.Title)
+}
+
+// dayBounds returns the start of the day of t and the start of the following day
+func dayBounds(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// buildICS renders events as an iCalendar file; events are assumed to last an hour
+func buildICS(events []Event) []byte {
+	const stamp = "20060102T150405Z"
+	escape := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+	var sb strings.Builder
+	sb.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ChatBuddy//Chat Calendar//EN\r\n")
+	for _, event := range events {
+		sb.WriteString("BEGIN:VEVENT\r\n")
+		sb.WriteString("UID:" + event.ID.Hex() + "@chatbuddy\r\n")
+		sb.WriteString("DTSTAMP:" + event.CreatedAt.UTC().Format(stamp) + "\r\n")
+		sb.WriteString("DTSTART:" + event.StartsAt.UTC().Format(stamp) + "\r\n")
+		sb.WriteString("DURATION:PT1H\r\n")
+		sb.WriteString("SUMMARY:" + escape.Replace(event.Title) + "\r\n")
+		sb.WriteString("END:VEVENT\r\n")
+	}
+	sb.WriteString("END:VCALENDAR\r\n")
+	return []byte(sb.String())
+}
@@ -0,0 +1,408 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	expenseKindExpense    = "expense"
+	expenseKindSettlement = "settlement"
+	expenseUsageMsg       = `Usage:
+/expense add <amount> <what> @user1 @user2 - you paid, split between you and the mentioned users
+/expense <free text> - e.g. "I paid 45 for the taxi, split with everyone"
+/expense balance - who owes whom
+/expense settle @user <amount> - record that you paid someone back
+/expense settle - clear all balances (admins)`
+	expenseParseErrMsg = "I couldn't understand that expense. Try: /expense add 30 pizza @alice @bob"
+	noExpensesMsg      = "No open expenses. Everyone is square! 🤝"
+	expensesSettledMsg = "All balances settled and cleared ✅"
+	participantsSample = 200
+)
+
+var mentionPattern = regexp.MustCompile(`@(\w+)`)
+
+// Expense is a shared cost or pay-back recorded in a chat; amounts are in cents
+type Expense struct {
+	ChatID      int64            `bson:"chat_id"`
+	Kind        string           `bson:"kind"`
+	Payer       string           `bson:"payer"`
+	AmountCents int64            `bson:"amount_cents"`
+	Description string           `bson:"description"`
+	Shares      map[string]int64 `bson:"shares"`
+	Settled     bool             `bson:"settled"`
+	CreatedBy   int64            `bson:"created_by"`
+	CreatedAt   time.Time        `bson:"created_at"`
+}
+
+func (bs *BotService) handleExpense(msg *tgbotapi.Message) {
+	args := strings.TrimSpace(msg.CommandArguments())
+	sub, rest, _ := strings.Cut(args, " ")
+	rest = strings.TrimSpace(rest)
+
+	switch strings.ToLower(sub) {
+	case "":
+		bs.Reply(msg, expenseUsageMsg)
+	case "add":
+		expense, ok := parseExpense(msg, rest)
+		if !ok {
+			bs.Reply(msg, expenseParseErrMsg)
+			return
+		}
+		bs.recordExpense(msg, expense)
+	case "balance":
+		bs.Reply(msg, bs.formatBalances(msg.Chat.ID))
+	case "settle":
+		bs.handleSettle(msg, rest)
+	default:
+		// Anything else is treated as a natural-language expense
+		expense, ok := bs.parseExpenseWithAI(msg, args)
+		if !ok {
+			bs.Reply(msg, expenseParseErrMsg)
+			return
+		}
+		bs.recordExpense(msg, expense)
+	}
+}
+
+// parseExpense reads "<amount> <description> @user ..." paid by the sender and split evenly with the mentioned users
+func parseExpense(msg *tgbotapi.Message, text string) (Expense, bool) {
+	amountText, description, _ := strings.Cut(text, " ")
+	amount, ok := parseAmountCents(amountText)
+	if !ok {
+		return Expense{}, false
+	}
+
+	payer := userKey(msg.From)
+	participants := []string{payer}
+	for _, m := range mentionPattern.FindAllStringSubmatch(description, -1) {
+		participants = append(participants, strings.ToLower(m[1]))
+	}
+	description = strings.TrimSpace(mentionPattern.ReplaceAllString(description, ""))
+
+	return newExpense(msg, payer, amount, description, participants), true
+}
+
+// parseExpenseWithAI turns free text like "I paid 45 for the taxi, split with everyone" into an expense
+func (bs *BotService) parseExpenseWithAI(msg *tgbotapi.Message, text string) (Expense, bool) {
+	members := bs.recentParticipants(msg.Chat.ID)
+
+	prompt := fmt.Sprintf(`Parse this expense entry from a group chat. The author is "%s".
+Known chat members:
+%s
+
+Entry:
+%s
+
+Answer with exactly one line:
+- "NONE" if it isn't an expense
+- "EXPENSE | <amount as a number> | <short description> | <payer> | <comma-separated participants who share the cost, including the payer if they share it>"
+Use the author for "I"/"me", and all known chat members for "everyone". Use member names exactly as listed.`,
+		userKey(msg.From), untrusted("chat members", strings.Join(members, ", ")), untrusted("expense entry", text))
+
+	ctx, cancel := context.WithTimeout(bs.billedContext(msg), 30*time.Second)
+	defer cancel()
+
+	answer, err := bs.generate(ctx, prompt)
+	if err != nil {
+		log.Printf("gemini expense parse error: %v", err)
+		return Expense{}, false
+	}
+
+	parts := strings.Split(strings.TrimSpace(answer), "|")
+	if len(parts) != 5 || !strings.EqualFold(strings.TrimSpace(parts[0]), "EXPENSE") {
+		return Expense{}, false
+	}
+
+	amount, ok := parseAmountCents(strings.TrimSpace(parts[1]))
+	if !ok {
+		return Expense{}, false
+	}
+
+	var participants []string
+	for _, p := range strings.Split(parts[4], ",") {
+		if p = normalizeOwner(p); p != "" {
+			participants = append(participants, p)
+		}
+	}
+	payer := normalizeOwner(parts[3])
+	if payer == "" || len(participants) == 0 {
+		return Expense{}, false
+	}
+
+	return newExpense(msg, payer, amount, strings.TrimSpace(parts[2]), participants), true
+}
+
+// newExpense splits amount evenly between participants, giving leftover cents to the first ones
+func newExpense(msg *tgbotapi.Message, payer string, amount int64, description string, participants []string) Expense {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, p := range participants {
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+
+	shares := make(map[string]int64)
+	each, remainder := amount/int64(len(unique)), amount%int64(len(unique))
+	for i, p := range unique {
+		shares[p] = each
+		if int64(i) < remainder {
+			shares[p]++
+		}
+	}
+
+	return Expense{
+		ChatID:      msg.Chat.ID,
+		Kind:        expenseKindExpense,
+		Payer:       payer,
+		AmountCents: amount,
+		Description: description,
+		Shares:      shares,
+		CreatedBy:   msg.From.ID,
+		CreatedAt:   time.Now(),
+	}
+}
+
+func (bs *BotService) recordExpense(msg *tgbotapi.Message, expense Expense) {
+	if err := bs.insertExpense(expense); err != nil {
+		log.Printf("Error storing expense: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	var names []string
+	for p := range expense.Shares {
+		names = append(names, "@"+p)
+	}
+	sort.Strings(names)
+
+	bs.Reply(msg, fmt.Sprintf("💸 @%s paid %s for %s, split between %s.",
+		expense.Payer, formatCents(expense.AmountCents), expense.Description, strings.Join(names, ", ")))
+}
+
+func (bs *BotService) handleSettle(msg *tgbotapi.Message, args string) {
+	if args == "" {
+		if bs.userRole(msg.Chat, msg.From) < ChatAdmin {
+			bs.Reply(msg, adminOnlyMsg)
+			return
+		}
+
+		summary := bs.formatBalances(msg.Chat.ID)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, err := bs.db.Collection("expenses").UpdateMany(ctx,
+			bson.M{"chat_id": msg.Chat.ID, "settled": false},
+			bson.M{"$set": bson.M{"settled": true}},
+		); err != nil {
+			log.Printf("Error settling expenses: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bs.Reply(msg, summary+"\n\n"+expensesSettledMsg)
+		return
+	}
+
+	fields := strings.Fields(args)
+	if len(fields) != 2 || !strings.HasPrefix(fields[0], "@") {
+		bs.Reply(msg, expenseUsageMsg)
+		return
+	}
+	amount, ok := parseAmountCents(fields[1])
+	if !ok {
+		bs.Reply(msg, expenseUsageMsg)
+		return
+	}
+
+	// A pay-back is the payer covering the full amount of the receiver's "share"
+	receiver := normalizeOwner(fields[0])
+	payback := Expense{
+		ChatID:      msg.Chat.ID,
+		Kind:        expenseKindSettlement,
+		Payer:       userKey(msg.From),
+		AmountCents: amount,
+		Description: "pay-back",
+		Shares:      map[string]int64{receiver: amount},
+		CreatedBy:   msg.From.ID,
+		CreatedAt:   time.Now(),
+	}
+	if err := bs.insertExpense(payback); err != nil {
+		log.Printf("Error storing pay-back: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("🤝 Recorded: @%s paid @%s %s.", payback.Payer, receiver, formatCents(amount)))
+}
+
+func (bs *BotService) insertExpense(expense Expense) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := bs.db.Collection("expenses").InsertOne(ctx, expense)
+	return err
+}
+
+// formatBalances computes net balances of the open expenses and the transfers that would settle them
+func (bs *BotService) formatBalances(chatID int64) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := bs.db.Collection("expenses").Find(ctx, bson.M{"chat_id": chatID, "settled": false})
+	if err != nil {
+		log.Printf("Error loading expenses: %v", err)
+		return responseErrorMsg
+	}
+	defer cursor.Close(ctx)
+
+	var expenses []Expense
+	if err := cursor.All(ctx, &expenses); err != nil {
+		log.Printf("Error decoding expenses: %v", err)
+		return responseErrorMsg
+	}
+
+	transfers := settleTransfers(netBalances(expenses))
+	if len(transfers) == 0 {
+		return noExpensesMsg
+	}
+
+	var sb strings.Builder
+	sb.WriteString("💰 Balances:\n")
+	for _, t := range transfers {
+		sb.WriteString(fmt.Sprintf("- @%s owes @%s %s\n", t.from, t.to, formatCents(t.amount)))
+	}
+	return sb.String()
+}
+
+// netBalances is what each member is owed by the others, negative when they owe
+func netBalances(expenses []Expense) map[string]int64 {
+	net := make(map[string]int64)
+	for _, e := range expenses {
+		net[e.Payer] += e.AmountCents
+		for p, share := range e.Shares {
+			net[p] -= share
+		}
+	}
+	return net
+}
+
+type transfer struct {
+	from, to string
+	amount   int64
+}
+
+// settleTransfers greedily matches the biggest debtor with the biggest creditor,
+// which keeps the number of transfers small
+func settleTransfers(net map[string]int64) []transfer {
+	type balance struct {
+		user   string
+		amount int64
+	}
+	var debtors, creditors []balance
+	for user, amount := range net {
+		if amount < 0 {
+			debtors = append(debtors, balance{user, -amount})
+		} else if amount > 0 {
+			creditors = append(creditors, balance{user, amount})
+		}
+	}
+	byAmount := func(b []balance) func(i, j int) bool {
+		return func(i, j int) bool {
+			if b[i].amount != b[j].amount {
+				return b[i].amount > b[j].amount
+			}
+			return b[i].user < b[j].user
+		}
+	}
+	sort.Slice(debtors, byAmount(debtors))
+	sort.Slice(creditors, byAmount(creditors))
+
+	var transfers []transfer
+	for i, j := 0, 0; i < len(debtors) && j < len(creditors); {
+		amount := min(debtors[i].amount, creditors[j].amount)
+		transfers = append(transfers, transfer{debtors[i].user, creditors[j].user, amount})
+		debtors[i].amount -= amount
+		creditors[j].amount -= amount
+		if debtors[i].amount == 0 {
+			i++
+		}
+		if creditors[j].amount == 0 {
+			j++
+		}
+	}
+	return transfers
+}
+
+// recentParticipants returns the usernames of people who wrote in the chat recently
+func (bs *BotService) recentParticipants(chatID int64) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := bs.db.Collection("messages").Find(ctx,
+		bson.M{"chat_id": chatID},
+		options.Find().
+			SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+			SetLimit(participantsSample).
+			SetProjection(bson.M{"from_username": 1, "from_first_name": 1}),
+	)
+	if err != nil {
+		log.Printf("Error loading participants: %v", err)
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var messages []store.Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		log.Printf("Error decoding participants: %v", err)
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var participants []string
+	for _, m := range messages {
+		name := strings.ToLower(m.FromUsername)
+		if name == "" {
+			name = strings.ToLower(m.FromFirstName)
+		}
+		if name != "" && !seen[name] {
+			seen[name] = true
+			participants = append(participants, name)
+		}
+	}
+	return participants
+}
+
+// userKey identifies a user in expenses by lower-cased username, or first name if they have none
+func userKey(user *tgbotapi.User) string {
+	if user.UserName != "" {
+		return strings.ToLower(user.UserName)
+	}
+	return strings.ToLower(user.FirstName)
+}
+
+func parseAmountCents(text string) (int64, bool) {
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(text, ",", "."), 64)
+	if err != nil || amount <= 0 || math.IsInf(amount, 0) {
+		return 0, false
+	}
+	// Amounts under half a cent round to nothing
+	cents := int64(math.Round(amount * 100))
+	return cents, cents > 0
+}
+
+func formatCents(cents int64) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
@@ -0,0 +1,127 @@
+package bot
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestNewExpenseSplit(t *testing.T) {
+	msg := &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: -100}, From: &tgbotapi.User{ID: 1, UserName: "alice"}}
+	tests := []struct {
+		name         string
+		amount       int64
+		participants []string
+		want         map[string]int64
+	}{
+		{name: "even", amount: 3000, participants: []string{"alice", "bob", "carol"}, want: map[string]int64{"alice": 1000, "bob": 1000, "carol": 1000}},
+		{name: "leftover cents go to the first", amount: 1000, participants: []string{"alice", "bob", "carol"}, want: map[string]int64{"alice": 334, "bob": 333, "carol": 333}},
+		{name: "two leftover cents", amount: 500, participants: []string{"bob", "carol", "dave"}, want: map[string]int64{"bob": 167, "carol": 167, "dave": 166}},
+		{name: "duplicates count once", amount: 1000, participants: []string{"bob", "alice", "bob"}, want: map[string]int64{"bob": 500, "alice": 500}},
+		{name: "single participant", amount: 999, participants: []string{"bob"}, want: map[string]int64{"bob": 999}},
+		{name: "fewer cents than people", amount: 2, participants: []string{"alice", "bob", "carol"}, want: map[string]int64{"alice": 1, "bob": 1, "carol": 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newExpense(msg, "alice", tt.amount, "dinner", tt.participants)
+			if !maps.Equal(got.Shares, tt.want) {
+				t.Errorf("newExpense(%d, %q) shares = %v, want %v", tt.amount, tt.participants, got.Shares, tt.want)
+			}
+			var sum int64
+			for _, share := range got.Shares {
+				sum += share
+			}
+			if sum != tt.amount {
+				t.Errorf("newExpense(%d, %q) shares add up to %d", tt.amount, tt.participants, sum)
+			}
+		})
+	}
+}
+
+func TestSettleTransfers(t *testing.T) {
+	tests := []struct {
+		name     string
+		expenses []Expense
+		// want is empty when everyone is square
+		want []transfer
+	}{
+		{name: "no expenses"},
+		{
+			name:     "payer's own share",
+			expenses: []Expense{{Payer: "alice", AmountCents: 500, Shares: map[string]int64{"alice": 500}}},
+		},
+		{
+			name:     "one expense",
+			expenses: []Expense{{Payer: "alice", AmountCents: 3000, Shares: map[string]int64{"alice": 1000, "bob": 1000, "carol": 1000}}},
+			want:     []transfer{{"bob", "alice", 1000}, {"carol", "alice", 1000}},
+		},
+		{
+			name: "expenses cancel out",
+			expenses: []Expense{
+				{Payer: "alice", AmountCents: 2000, Shares: map[string]int64{"alice": 1000, "bob": 1000}},
+				{Payer: "bob", AmountCents: 2000, Shares: map[string]int64{"alice": 1000, "bob": 1000}},
+			},
+		},
+		{
+			name: "paid back",
+			expenses: []Expense{
+				{Payer: "alice", AmountCents: 1000, Shares: map[string]int64{"alice": 334, "bob": 333, "carol": 333}},
+				{Payer: "bob", AmountCents: 333, Shares: map[string]int64{"alice": 333}, Kind: expenseKindSettlement},
+				{Payer: "carol", AmountCents: 333, Shares: map[string]int64{"alice": 333}, Kind: expenseKindSettlement},
+			},
+		},
+		{
+			name: "partly paid back",
+			expenses: []Expense{
+				{Payer: "alice", AmountCents: 1000, Shares: map[string]int64{"alice": 334, "bob": 333, "carol": 333}},
+				{Payer: "bob", AmountCents: 300, Shares: map[string]int64{"alice": 300}, Kind: expenseKindSettlement},
+			},
+			want: []transfer{{"carol", "alice", 333}, {"bob", "alice", 33}},
+		},
+		{
+			name: "biggest debtor pays the biggest creditor",
+			expenses: []Expense{
+				{Payer: "alice", AmountCents: 9000, Shares: map[string]int64{"alice": 3000, "bob": 3000, "carol": 3000}},
+				{Payer: "bob", AmountCents: 3000, Shares: map[string]int64{"alice": 1000, "bob": 1000, "carol": 1000}},
+			},
+			want: []transfer{{"carol", "alice", 4000}, {"bob", "alice", 1000}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := settleTransfers(netBalances(tt.expenses))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("settleTransfers = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAmountCents(t *testing.T) {
+	tests := []struct {
+		text string
+		want int64
+		ok   bool
+	}{
+		{text: "12", want: 1200, ok: true},
+		{text: "12.5", want: 1250, ok: true},
+		{text: "12,34", want: 1234, ok: true},
+		{text: "0.1", want: 10, ok: true},
+		{text: "1.999", want: 200, ok: true},
+		{text: "0.004"},
+		{text: "0"},
+		{text: "-5"},
+		{text: "ten"},
+		{text: "1e400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, ok := parseAmountCents(tt.text)
+			if ok != tt.ok || (ok && got != tt.want) {
+				t.Errorf("parseAmountCents(%q) = %d, %t, want %d, %t", tt.text, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	factCheckSources  = 6
+	maxClaimRunes     = 1000
+	factCheckUsageMsg = "Usage: reply to a message with /factcheck, or /factcheck <claim>"
+	noWebSearchMsg    = "Fact-checking needs web search, which isn't set up for this bot."
+	noSourcesMsg      = "I couldn't find any sources about that claim, so I can't check it."
+)
+
+// factCheck looks a claim up on the web and has the model judge it against the results only,
+// citing them by number. It returns "" when the search found nothing.
+func (bs *BotService) factCheck(ctx context.Context, search searchProvider, claim string) (string, error) {
+	searchCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	results, err := search.Search(searchCtx, truncateRunes(claim, 200), factCheckSources)
+	if err != nil || len(results) == 0 {
+		return "", err
+	}
+
+	var sources, list strings.Builder
+	for i, r := range results {
+		fmt.Fprintf(&sources, "[%d] %s\n%s\n%s\n\n", i+1, r.Title, r.Link, r.Snippet)
+		fmt.Fprintf(&list, "[%d] %s\n%s\n", i+1, truncateRunes(r.Title, 80), r.Link)
+	}
+
+	prompt := fmt.Sprintf(`Fact-check the claim below using ONLY the numbered search results, not your own knowledge.
+Answer in plain text, no markdown, in this format:
+Verdict: one of True, Mostly true, Mixed, Mostly false, False, Unverifiable
+Confidence: Low, Medium or High
+Then 2-4 sentences explaining why, citing the results that support each point like [1] or [2][3].
+If the results don't address the claim, the verdict is Unverifiable. Don't cite results that aren't listed.
+Response language: the language of the claim.
+
+Claim:
+%s
+
+Search results:
+%s`, untrusted("claim", claim), untrusted("search results", sources.String()))
+
+	ctx, cancel = context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	assessment, err := bs.generate(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	return "🔍 Fact check\n\n" + strings.TrimSpace(assessment) + "\n\nSources:\n" + list.String(), nil
+}
+
+func (bs *BotService) handleFactCheck(msg *tgbotapi.Message) {
+	claim := strings.TrimSpace(msg.CommandArguments())
+	replyTo := msg.MessageID
+	if claim == "" && msg.ReplyToMessage != nil {
+		claim = strings.TrimSpace(msg.ReplyToMessage.Text + msg.ReplyToMessage.Caption)
+		replyTo = msg.ReplyToMessage.MessageID
+	}
+	if claim == "" {
+		bs.Reply(msg, factCheckUsageMsg)
+		return
+	}
+	search := bs.searchProvider()
+	if search == nil {
+		bs.Reply(msg, noWebSearchMsg)
+		return
+	}
+
+	text, err := bs.factCheck(bs.billedContext(msg), search, truncateRunes(claim, maxClaimRunes))
+	if err != nil {
+		log.Printf("Error fact-checking a claim in chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if text == "" {
+		bs.Reply(msg, noSourcesMsg)
+		return
+	}
+	response := tgbotapi.NewMessage(msg.Chat.ID, text)
+	response.ReplyToMessageID = replyTo
+	bs.sendAs(kindAnswer, response)
+}
@@ -0,0 +1,446 @@
+package bot
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	feedPollInterval    = 30 * time.Minute
+	defaultDigestHour   = 18
+	maxFeedBytes        = 5 << 20
+	maxDigestItems      = 30
+	maxFeedsPerChat     = 20
+	itemSummaryMaxRunes = 500
+	feedUsageMsg        = `Usage:
+/feed add <url> - subscribe this chat to an RSS or Atom feed
+/feed list - show subscriptions
+/feed remove <number> - unsubscribe
+/feed digest <hour 0-23> - when the daily digest of new items is posted`
+	noFeedsMsg = "This chat has no feed subscriptions. Add one with /feed add <url>."
+)
+
+// FeedSubscription is an RSS or Atom feed followed by a chat
+type FeedSubscription struct {
+	ChatID       int64     `bson:"chat_id"`
+	URL          string    `bson:"url"`
+	Title        string    `bson:"title"`
+	AddedBy      int64     `bson:"added_by"`
+	CreatedAt    time.Time `bson:"created_at"`
+	LastPolledAt time.Time `bson:"last_polled_at"`
+}
+
+// FeedItem is an entry of a subscribed feed; Posted marks items already included in a digest
+type FeedItem struct {
+	ChatID    int64     `bson:"chat_id"`
+	FeedURL   string    `bson:"feed_url"`
+	GUID      string    `bson:"guid"`
+	Title     string    `bson:"title"`
+	Link      string    `bson:"link"`
+	Summary   string    `bson:"summary"`
+	Posted    bool      `bson:"posted"`
+	FetchedAt time.Time `bson:"fetched_at"`
+}
+
+// rawFeed decodes both RSS 2.0 (<rss><channel><item>) and Atom (<feed><entry>) documents
+type rawFeed struct {
+	Title   string `xml:"title"`
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			GUID        string `xml:"guid"`
+			Description string `xml:"description"`
+		} `xml:"item"`
+	} `xml:"channel"`
+	Entries []struct {
+		Title string `xml:"title"`
+		ID    string `xml:"id"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Summary string `xml:"summary"`
+		Content string `xml:"content"`
+	} `xml:"entry"`
+}
+
+func (bs *BotService) handleFeedCommand(msg *tgbotapi.Message) {
+	sub, arg, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+	arg = strings.TrimSpace(arg)
+
+	switch strings.ToLower(sub) {
+	case "add", "remove", "digest":
+		if bs.userRole(msg.Chat, msg.From) < ChatAdmin {
+			bs.Reply(msg, adminOnlyMsg)
+			return
+		}
+	}
+
+	switch strings.ToLower(sub) {
+	case "add":
+		bs.addFeed(msg, arg)
+	case "list":
+		bs.Reply(msg, bs.formatFeeds(msg.Chat.ID))
+	case "remove":
+		bs.removeFeed(msg, arg)
+	case "digest":
+		hour, err := strconv.Atoi(arg)
+		if err != nil || hour < 0 || hour > 23 {
+			bs.Reply(msg, feedUsageMsg)
+			return
+		}
+		if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"feed_digest_hour": hour}); err != nil {
+			log.Printf("Error saving digest hour: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bs.Reply(msg, fmt.Sprintf("📰 The feed digest will be posted daily at %02d:00 (%s).", hour, bs.chatLocation(msg.Chat.ID)))
+	default:
+		bs.Reply(msg, feedUsageMsg)
+	}
+}
+
+func (bs *BotService) addFeed(msg *tgbotapi.Message, rawURL string) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		bs.Reply(msg, feedUsageMsg)
+		return
+	}
+	if len(bs.loadFeeds(bson.M{"chat_id": msg.Chat.ID})) >= maxFeedsPerChat {
+		bs.Reply(msg, fmt.Sprintf("This chat already follows %d feeds, remove one first.", maxFeedsPerChat))
+		return
+	}
+
+	title, items, err := fetchFeed(rawURL)
+	if err != nil {
+		log.Printf("failed to fetch feed %s: %v", rawURL, err)
+		bs.Reply(msg, "I couldn't read that feed. Is it a valid RSS or Atom URL?")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	feed := FeedSubscription{
+		ChatID:       msg.Chat.ID,
+		URL:          rawURL,
+		Title:        title,
+		AddedBy:      msg.From.ID,
+		CreatedAt:    time.Now(),
+		LastPolledAt: time.Now(),
+	}
+	if _, err := bs.db.Collection("feeds").UpdateOne(ctx,
+		bson.M{"chat_id": feed.ChatID, "url": feed.URL},
+		bson.M{"$set": feed},
+		options.Update().SetUpsert(true),
+	); err != nil {
+		log.Printf("Error storing feed: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	// Existing items count as seen so the first digest only has what's new
+	for i := range items {
+		items[i].ChatID = msg.Chat.ID
+		items[i].Posted = true
+	}
+	bs.storeFeedItems(items)
+
+	bs.Reply(msg, fmt.Sprintf("📰 Subscribed to %s. New items will show up in the daily digest.", title))
+}
+
+func (bs *BotService) removeFeed(msg *tgbotapi.Message, arg string) {
+	feeds := bs.loadFeeds(bson.M{"chat_id": msg.Chat.ID})
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(feeds) {
+		bs.Reply(msg, "Use /feed remove <number> with a number from /feed list.")
+		return
+	}
+	feed := feeds[n-1]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := bs.db.Collection("feeds").DeleteOne(ctx, bson.M{"chat_id": feed.ChatID, "url": feed.URL}); err != nil {
+		log.Printf("Error removing feed: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if _, err := bs.db.Collection("feed_items").DeleteMany(ctx, bson.M{"chat_id": feed.ChatID, "feed_url": feed.URL}); err != nil {
+		log.Printf("Error removing feed items: %v", err)
+	}
+	bs.Reply(msg, "Unsubscribed from "+feed.Title)
+}
+
+func (bs *BotService) formatFeeds(chatID int64) string {
+	feeds := bs.loadFeeds(bson.M{"chat_id": chatID})
+	if len(feeds) == 0 {
+		return noFeedsMsg
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📰 Feeds:\n")
+	for i, feed := range feeds {
+		sb.WriteString(fmt.Sprintf("%d. %s - %s\n", i+1, feed.Title, feed.URL))
+	}
+	return sb.String()
+}
+
+func (bs *BotService) loadFeeds(filter bson.M) []FeedSubscription {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := bs.db.Collection("feeds").Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		log.Printf("Error loading feeds: %v", err)
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var feeds []FeedSubscription
+	if err := cursor.All(ctx, &feeds); err != nil {
+		log.Printf("Error decoding feeds: %v", err)
+	}
+	return feeds
+}
+
+// storeFeedItems inserts items not seen before; already known items keep their posted state
+func (bs *BotService) storeFeedItems(items []FeedItem) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := bs.db.Collection("feed_items")
+	for _, item := range items {
+		_, err := collection.UpdateOne(ctx,
+			bson.M{"chat_id": item.ChatID, "feed_url": item.FeedURL, "guid": item.GUID},
+			bson.M{"$setOnInsert": item},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			log.Printf("Error storing feed item: %v", err)
+		}
+	}
+}
+
+// pollFeeds fetches every feed that wasn't polled within feedPollInterval and stores new items
+func (bs *BotService) pollFeeds(now time.Time) {
+	feeds := bs.loadFeeds(bson.M{"last_polled_at": bson.M{"$lt": now.Add(-feedPollInterval)}})
+
+	for _, feed := range feeds {
+		_, items, err := fetchFeed(feed.URL)
+		if err != nil {
+			log.Printf("failed to poll feed %s: %v", feed.URL, err)
+		} else {
+			for i := range items {
+				items[i].ChatID = feed.ChatID
+			}
+			bs.storeFeedItems(items)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if _, err := bs.db.Collection("feeds").UpdateOne(ctx,
+			bson.M{"chat_id": feed.ChatID, "url": feed.URL},
+			bson.M{"$set": bson.M{"last_polled_at": now}},
+		); err != nil {
+			log.Printf("Error updating feed poll time: %v", err)
+		}
+		cancel()
+	}
+}
+
+// sendFeedDigests posts one AI-written digest of the new feed items per chat, once a day at its digest hour
+func (bs *BotService) sendFeedDigests(now time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	chatIDs, err := bs.db.Collection("feed_items").Distinct(ctx, "chat_id", bson.M{"posted": false})
+	if err != nil {
+		log.Printf("Error loading chats with new feed items: %v", err)
+		return
+	}
+
+	for _, raw := range chatIDs {
+		chatID, ok := raw.(int64)
+		if !ok {
+			continue
+		}
+
+		settings := bs.store.ChatSettings(chatID)
+		hour := defaultDigestHour
+		if settings.FeedDigestHour != nil {
+			hour = *settings.FeedDigestHour
+		}
+		local := now.In(bs.chatLocation(chatID))
+		if local.Hour() != hour || !bs.claimDailyRun("feed_digest", chatID, local.Format("2006-01-02")) {
+			continue
+		}
+		bs.sendFeedDigest(chatID)
+	}
+}
+
+func (bs *BotService) sendFeedDigest(chatID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := bs.db.Collection("feed_items")
+	cursor, err := collection.Find(ctx,
+		bson.M{"chat_id": chatID, "posted": false},
+		options.Find().SetSort(bson.D{{Key: "fetched_at", Value: 1}}).SetLimit(maxDigestItems),
+	)
+	if err != nil {
+		log.Printf("Error loading feed items: %v", err)
+		return
+	}
+	var items []FeedItem
+	if err := cursor.All(ctx, &items); err != nil {
+		log.Printf("Error decoding feed items: %v", err)
+		return
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	var entries []string
+	for i, item := range items {
+		entries = append(entries, fmt.Sprintf("%d. %s\n%s", i+1, item.Title, truncateRunes(item.Summary, itemSummaryMaxRunes)))
+	}
+
+	prompt := fmt.Sprintf(`Below are %d new items from the news feeds a Telegram group follows. Write a single readable digest post:
+
+%s
+
+Digest instructions:
+1. One short line per item, grouping related items together
+2. Refer to items by their number in square brackets, e.g. [3], so links can be attached
+3. Plain text, no markdown, 10 lines maximum
+4. Response language: Same as the items`, len(items), untrusted("news feeds", strings.Join(entries, "\n\n")))
+
+	genCtx, genCancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer genCancel()
+
+	digest, err := bs.generate(genCtx, prompt)
+	if err != nil {
+		log.Printf("gemini feed digest error: %v", err)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📰 Feed digest\n\n" + digest + "\n\n")
+	for i, item := range items {
+		sb.WriteString(fmt.Sprintf("[%d] %s\n", i+1, item.Link))
+	}
+	bs.sendScheduledDigest(chatID, sb.String())
+	bs.sendDigestToSubscribers(chatID, sb.String())
+
+	var guids []string
+	for _, item := range items {
+		guids = append(guids, item.GUID)
+	}
+	if _, err := collection.UpdateMany(ctx,
+		bson.M{"chat_id": chatID, "guid": bson.M{"$in": guids}},
+		bson.M{"$set": bson.M{"posted": true}},
+	); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		log.Printf("Error marking feed items as posted: %v", err)
+	}
+}
+
+// fetchFeed downloads and parses an RSS or Atom feed
+func fetchFeed(feedURL string) (string, []FeedItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("User-Agent", "ChatBuddy feed reader")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("feed returned %s", resp.Status)
+	}
+
+	var raw rawFeed
+	decoder := xml.NewDecoder(io.LimitReader(resp.Body, maxFeedBytes))
+	decoder.Strict = false
+	if err := decoder.Decode(&raw); err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	var items []FeedItem
+	for _, it := range raw.Channel.Items {
+		guid := it.GUID
+		if guid == "" {
+			guid = it.Link
+		}
+		items = append(items, FeedItem{FeedURL: feedURL, GUID: guid, Title: it.Title, Link: it.Link, Summary: stripTags(it.Description), FetchedAt: now})
+	}
+	for _, e := range raw.Entries {
+		link := ""
+		for _, l := range e.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = l.Href
+				break
+			}
+		}
+		summary := e.Summary
+		if summary == "" {
+			summary = e.Content
+		}
+		guid := e.ID
+		if guid == "" {
+			guid = link
+		}
+		items = append(items, FeedItem{FeedURL: feedURL, GUID: guid, Title: e.Title, Link: link, Summary: stripTags(summary), FetchedAt: now})
+	}
+
+	title := raw.Channel.Title
+	if title == "" {
+		title = raw.Title
+	}
+	if title == "" {
+		title = feedURL
+	}
+	if len(items) == 0 && raw.Channel.Title == "" && raw.Title == "" {
+		return "", nil, errors.New("not an RSS or Atom feed")
+	}
+	return strings.TrimSpace(title), items, nil
+}
+
+// stripTags removes HTML markup from feed descriptions
+func stripTags(s string) string {
+	var sb strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case !inTag:
+			sb.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/objectstore"
+)
+
+const (
+	exportsPrefix       = "exports/"
+	exportKeyTimeLayout = "20060102-150405"
+	fileCleanupInterval = time.Hour
+)
+
+// errNoFileStore is returned by storeFile when files are sent as Telegram documents
+var errNoFileStore = errors.New("no file store configured")
+
+// lastFileCleanup is the Unix time of the last export cleanup, so the per-minute scheduler only lists the store hourly
+var lastFileCleanup atomic.Int64
+
+// fileStore returns where exports and other generated files go: the S3 bucket if S3_BUCKET is set,
+// else FILES_DIR served by the HTTP server under /files, else nil to send files as Telegram documents
+func (cfg *Config) fileStore() objectstore.Store {
+	switch {
+	case cfg.S3.Bucket != "":
+		s3 := cfg.S3
+		return &s3
+	case cfg.FilesDir != "" && cfg.PublicURL != "" && cfg.HTTPAddr != "":
+		return cfg.filesDisk()
+	default:
+		return nil
+	}
+}
+
+func (cfg *Config) filesDisk() objectstore.Disk {
+	// Derived from the bot token, so links survive restarts without another secret to configure
+	secret := sha256.Sum256([]byte("chatbuddy-files:" + cfg.BotToken))
+	return objectstore.Disk{
+		Dir:     cfg.FilesDir,
+		BaseURL: strings.TrimSuffix(cfg.PublicURL, "/") + "/files",
+		Secret:  secret[:],
+	}
+}
+
+func (cfg *Config) signedURLExpiry() time.Duration {
+	return time.Duration(cfg.SignedURLHours) * time.Hour
+}
+
+// sendFile uploads a generated file to the file store and replies with a signed download link,
+// falling back to a Telegram document when no store is configured or the upload fails
+func (bs *BotService) sendFile(msg *tgbotapi.Message, name string, data []byte, caption string) {
+	if url, err := bs.storeFile(msg.Chat.ID, name, data); err == nil {
+		text := fmt.Sprintf("%s\n📎 %s (%s): %s\nThe link expires in %d hours.", caption, name, formatBytes(len(data)), url, bs.config().SignedURLHours)
+		bs.Reply(msg, strings.TrimSpace(text))
+		return
+	} else if !errors.Is(err, errNoFileStore) {
+		log.Printf("Error storing %s for chat %d, sending it as a document: %v", name, msg.Chat.ID, err)
+	}
+
+	doc := tgbotapi.NewDocument(msg.Chat.ID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	doc.Caption = caption
+	doc.ReplyToMessageID = msg.MessageID
+	if _, err := bs.api.Send(doc); err != nil {
+		log.Printf("failed to send %s: %v", name, err)
+		bs.Reply(msg, responseErrorMsg)
+	}
+}
+
+// storeFile saves data under exports/<time>/<chat>-<name> and returns a signed URL for it
+func (bs *BotService) storeFile(chatID int64, name string, data []byte) (string, error) {
+	files := bs.config().fileStore()
+	if files == nil {
+		return "", errNoFileStore
+	}
+	signer, ok := files.(objectstore.Signer)
+	if !ok {
+		return "", objectstore.ErrSigningUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	key := fmt.Sprintf("%s%s/%d-%s", exportsPrefix, time.Now().UTC().Format(exportKeyTimeLayout), chatID, name)
+	if err := files.Put(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
+		return "", err
+	}
+	return signer.SignedURL(key, bs.config().signedURLExpiry())
+}
+
+// handleFileDownload serves files from FILES_DIR to holders of a signed link
+func (bs *BotService) handleFileDownload(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	files := bs.config().filesDisk()
+	if files.Dir == "" || !strings.HasPrefix(key, exportsPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+	if err := files.VerifySignedURL(key, r.URL.Query()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	body, err := files.Get(r.Context(), key)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(key)}))
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("Error serving file %s: %v", key, err)
+	}
+}
+
+// cleanupFiles deletes exports whose links have expired, about once an hour
+func (bs *BotService) cleanupFiles(now time.Time) {
+	if now.Unix()-lastFileCleanup.Load() < int64(fileCleanupInterval.Seconds()) {
+		return
+	}
+	files := bs.config().fileStore()
+	if files == nil {
+		return
+	}
+	lastFileCleanup.Store(now.Unix())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	keys, err := files.List(ctx, exportsPrefix)
+	if err != nil {
+		log.Printf("Error listing stored exports: %v", err)
+		return
+	}
+	cutoff := now.Add(-bs.config().signedURLExpiry())
+	for _, key := range keys {
+		stamp, _, _ := strings.Cut(strings.TrimPrefix(key, exportsPrefix), "/")
+		created, err := time.Parse(exportKeyTimeLayout, stamp)
+		if err != nil || created.After(cutoff) {
+			continue
+		}
+		if err := files.Delete(ctx, key); err != nil {
+			log.Printf("Error deleting expired export %s: %v", key, err)
+		}
+	}
+}
+
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
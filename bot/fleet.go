@@ -0,0 +1,224 @@
+package bot
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+// BotSpec is an extra bot in BOTS_FILE: its token and the settings it overrides; empty fields keep the
+// main bot's configuration
+type BotSpec struct {
+	Token            string   `json:"token"`
+	Aliases          []string `json:"aliases,omitempty"`
+	PromptsFile      string   `json:"prompts_file,omitempty"`
+	AllowedChats     []int64  `json:"allowed_chats,omitempty"`
+	DisabledCommands []string `json:"disabled_commands,omitempty"`
+}
+
+// loadBotSpecs parses the extra bots in path, a JSON array of BotSpec; an empty path means none
+func loadBotSpecs(path string) ([]BotSpec, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bots file: %w", err)
+	}
+	var specs []BotSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("invalid bots file %s: %w", path, err)
+	}
+	for i, spec := range specs {
+		if spec.Token == "" {
+			return nil, fmt.Errorf("invalid bots file %s: bot %d has no token", path, i+1)
+		}
+	}
+	return specs, nil
+}
+
+// forBot returns a copy of cfg for an extra bot, with the settings its spec overrides
+func (cfg *Config) forBot(spec BotSpec) *Config {
+	c := *cfg
+	c.BotToken = spec.Token
+	c.Bots = nil
+	if spec.Aliases != nil {
+		c.Aliases = spec.Aliases
+	}
+	if spec.PromptsFile != "" {
+		c.PromptsFile = spec.PromptsFile
+	}
+	if spec.AllowedChats != nil {
+		c.AllowedChats = spec.AllowedChats
+	}
+	if spec.DisabledCommands != nil {
+		c.DisabledCommands = spec.DisabledCommands
+	}
+	return &c
+}
+
+// botMetrics count what one bot of a fleet handled since startup, for the dashboard
+type botMetrics struct {
+	updates    atomic.Int64
+	commands   atomic.Int64
+	aiRequests atomic.Int64
+	aiFailures atomic.Int64
+	// aiLatencyMS adds up how long model requests took
+	aiLatencyMS atomic.Int64
+	// rateLimited counts the messages Telegram refused with a flood limit
+	rateLimited atomic.Int64
+}
+
+// botStat is one row of the dashboard's bot table
+type botStat struct {
+	Bot        string
+	Updates    int64
+	Commands   int64
+	AIRequests int64
+	AIFailures int64
+}
+
+func (bs *BotService) stats() botStat {
+	return botStat{
+		Bot:        bs.botMention,
+		Updates:    bs.metrics.updates.Load(),
+		Commands:   bs.metrics.commands.Load(),
+		AIRequests: bs.metrics.aiRequests.Load(),
+		AIFailures: bs.metrics.aiFailures.Load(),
+	}
+}
+
+// sharedWith makes an extra bot use the main bot's storage, model, Redis, event bus and vector store,
+// and connect to Telegram with its own token
+func sharedWith(main *BotService, spec BotSpec) Option {
+	return func(bs *BotService) {
+		bs.api = nil
+		bs.store = main.store
+		bs.gemini = main.gemini
+		bs.cache = main.cache
+		bs.bus = main.bus
+		bs.vectors = main.vectors
+		bs.spec = &spec
+		bs.fleetMain = main
+	}
+}
+
+// Fleet is the main bot and the extra bots of BOTS_FILE, run from one process. Each bot has its own
+// token, mention and alias handling, prompts and chat allowlist; they share MongoDB, the model client,
+// Redis and the event bus. Process-wide work (the scheduler, HTTP server, dashboard and embedding worker)
+// runs on the main bot only; scheduled posts go out through a bot that is in the chat, see botFor.
+type Fleet struct {
+	bots []*BotService
+}
+
+// NewFleet creates the main bot with opts and one bot per entry of BOTS_FILE. Commands, message handlers
+// and middlewares given in opts are registered on every bot.
+func NewFleet(opts ...Option) (*Fleet, error) {
+	main, err := NewBot(opts...)
+	if err != nil {
+		return nil, err
+	}
+	fleet := &Fleet{bots: []*BotService{main}}
+	for i, spec := range main.config().Bots {
+		bs, err := NewBot(append(slices.Clone(opts), WithConfig(main.config().forBot(spec)), sharedWith(main, spec))...)
+		if err != nil {
+			main.Close()
+			return nil, fmt.Errorf("failed to start bot %d of BOTS_FILE: %w", i+1, err)
+		}
+		fleet.bots = append(fleet.bots, bs)
+	}
+	main.fleet = fleet.bots
+	return fleet, nil
+}
+
+// Bots returns the bots of the fleet, the main bot first
+func (f *Fleet) Bots() []*BotService {
+	return f.bots
+}
+
+// Run runs every bot until their update channels close
+func (f *Fleet) Run() {
+	var wg sync.WaitGroup
+	for _, bs := range f.bots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bs.Run()
+		}()
+	}
+	wg.Wait()
+}
+
+// Close releases the connections the bots share
+func (f *Fleet) Close() {
+	f.bots[0].Close()
+}
+
+// ReloadOnSignal reloads the configuration of every bot each time the process receives SIGHUP
+func (f *Fleet) ReloadOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			for _, bs := range f.bots {
+				if err := bs.Reload(); err != nil {
+					log.Printf("Error reloading configuration of %s: %v", bs.botMention, err)
+				}
+			}
+		}
+	}()
+}
+
+// fleetBots returns every bot of this bot's fleet, the main bot first; just this bot outside a Fleet
+func (bs *BotService) fleetBots() []*BotService {
+	main := cmp.Or(bs.fleetMain, bs)
+	if len(main.fleet) == 0 {
+		return []*BotService{main}
+	}
+	return main.fleet
+}
+
+// chatBots returns the bots of the fleet that are in a registered chat, the main bot first; for chats
+// registered before the registry kept track of its bots, the main bot
+func (bs *BotService) chatBots(chat store.ChatRecord) []*BotService {
+	fleet := bs.fleetBots()
+	if len(chat.Bots) == 0 {
+		return fleet[:1]
+	}
+	var bots []*BotService
+	for _, b := range fleet {
+		if slices.Contains(chat.Bots, b.id) {
+			bots = append(bots, b)
+		}
+	}
+	return bots
+}
+
+// botFor returns the bot to post in a chat with, for the scheduled jobs the main bot runs for the whole
+// fleet: this bot when it's in the chat or the chat is unknown, otherwise a fleet bot that is
+func (bs *BotService) botFor(chatID int64) *BotService {
+	if len(bs.fleetBots()) == 1 {
+		return bs
+	}
+	chat, err := bs.store.Chat(chatID)
+	if err != nil {
+		log.Printf("Error loading chat %d from the registry: %v", chatID, err)
+		return bs
+	}
+	if chat == nil || len(chat.Bots) == 0 || slices.Contains(chat.Bots, bs.id) {
+		return bs
+	}
+	if bots := bs.chatBots(*chat); len(bots) > 0 {
+		return bots[0]
+	}
+	return bs
+}
@@ -0,0 +1,385 @@
+package bot
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	githubAPIURL        = "https://api.github.com"
+	maxWebhookBodyBytes = 5 << 20
+	maxPRDiffChars      = 60000
+	githubUsageMsg      = `Usage:
+/github link <owner/repo> - post PR, issue and release notifications here
+/github unlink <owner/repo>
+/github list`
+	prUsageMsg       = "Usage: /pr <number>, or /pr <owner/repo> <number> if this chat follows several repositories"
+	noGitHubLinksMsg = "This chat isn't linked to any GitHub repository. Use /github link <owner/repo>."
+)
+
+var repoPattern = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
+// GitHubLink connects a chat to a GitHub repository's webhook notifications
+type GitHubLink struct {
+	ChatID    int64     `bson:"chat_id"`
+	Repo      string    `bson:"repo"`
+	CreatedBy int64     `bson:"created_by"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// githubEvent holds the parts of GitHub webhook payloads the bot uses
+type githubEvent struct {
+	Action     string `json:"action"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	PullRequest *struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+		Merged  bool   `json:"merged"`
+	} `json:"pull_request"`
+	Issue *struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+	} `json:"issue"`
+	Release *struct {
+		TagName string `json:"tag_name"`
+		Name    string `json:"name"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+	} `json:"release"`
+}
+
+func (bs *BotService) handleGitHubCommand(msg *tgbotapi.Message) {
+	sub, repo, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+	repo = strings.ToLower(strings.TrimSpace(repo))
+
+	switch strings.ToLower(sub) {
+	case "link", "unlink":
+		if bs.userRole(msg.Chat, msg.From) < ChatAdmin {
+			bs.Reply(msg, adminOnlyMsg)
+			return
+		}
+		if !repoPattern.MatchString(repo) {
+			bs.Reply(msg, githubUsageMsg)
+			return
+		}
+		if sub == "unlink" {
+			bs.unlinkGitHubRepo(msg, repo)
+			return
+		}
+		bs.linkGitHubRepo(msg, repo)
+	case "list":
+		links := bs.gitHubLinks(bson.M{"chat_id": msg.Chat.ID})
+		if len(links) == 0 {
+			bs.Reply(msg, noGitHubLinksMsg)
+			return
+		}
+		var repos []string
+		for _, link := range links {
+			repos = append(repos, "- "+link.Repo)
+		}
+		bs.Reply(msg, "🐙 Linked repositories:\n"+strings.Join(repos, "\n"))
+	default:
+		bs.Reply(msg, githubUsageMsg)
+	}
+}
+
+func (bs *BotService) linkGitHubRepo(msg *tgbotapi.Message, repo string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	link := GitHubLink{ChatID: msg.Chat.ID, Repo: repo, CreatedBy: msg.From.ID, CreatedAt: time.Now()}
+	if _, err := bs.db.Collection("github_links").UpdateOne(ctx,
+		bson.M{"chat_id": link.ChatID, "repo": link.Repo},
+		bson.M{"$setOnInsert": link},
+		options.Update().SetUpsert(true),
+	); err != nil {
+		log.Printf("Error linking GitHub repo: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	reply := fmt.Sprintf("🐙 Linked %s.", repo)
+	if bs.config().PublicURL == "" {
+		reply += "\nNote: PUBLIC_URL isn't configured, so ask the bot operator for the webhook address."
+	} else {
+		reply += fmt.Sprintf(`
+To finish the setup, add a webhook in the repository settings:
+- Payload URL: %s/github/webhook
+- Content type: application/json
+- Events: Pull requests, Issues, Releases`, strings.TrimSuffix(bs.config().PublicURL, "/"))
+		if bs.config().GitHubWebhookSecret != "" {
+			reply += "\n- Secret: ask the bot operator for the webhook secret"
+		}
+	}
+	bs.Reply(msg, reply)
+}
+
+func (bs *BotService) unlinkGitHubRepo(msg *tgbotapi.Message, repo string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := bs.db.Collection("github_links").DeleteOne(ctx, bson.M{"chat_id": msg.Chat.ID, "repo": repo}); err != nil {
+		log.Printf("Error unlinking GitHub repo: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("Unlinked %s.", repo))
+}
+
+func (bs *BotService) gitHubLinks(filter bson.M) []GitHubLink {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := bs.db.Collection("github_links").Find(ctx, filter)
+	if err != nil {
+		log.Printf("Error loading GitHub links: %v", err)
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var links []GitHubLink
+	if err := cursor.All(ctx, &links); err != nil {
+		log.Printf("Error decoding GitHub links: %v", err)
+	}
+	return links
+}
+
+// handleGitHubWebhook receives GitHub webhook deliveries and posts notifications to the linked chats
+func (bs *BotService) handleGitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		http.Error(w, "cannot read body", http.StatusBadRequest)
+		return
+	}
+
+	if secret := bs.config().GitHubWebhookSecret; secret != "" && !validGitHubSignature(secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var event githubEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	// Respond right away, GitHub times out deliveries after 10 seconds
+	w.WriteHeader(http.StatusAccepted)
+
+	kind := r.Header.Get("X-GitHub-Event")
+	bs.goSafe("GitHub "+kind+" notification", func() { bs.notifyGitHubEvent(kind, event) })
+}
+
+func validGitHubSignature(secret string, body []byte, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func (bs *BotService) notifyGitHubEvent(kind string, event githubEvent) {
+	var headline, details, link string
+
+	switch {
+	case kind == "pull_request" && event.PullRequest != nil:
+		action := event.Action
+		if action == "closed" && event.PullRequest.Merged {
+			action = "merged"
+		}
+		if action != "opened" && action != "reopened" && action != "closed" && action != "merged" {
+			return
+		}
+		headline = fmt.Sprintf("🔀 PR #%d %s by %s: %s", event.PullRequest.Number, action, event.Sender.Login, event.PullRequest.Title)
+		details, link = event.PullRequest.Body, event.PullRequest.HTMLURL
+	case kind == "issues" && event.Issue != nil:
+		if event.Action != "opened" && event.Action != "closed" && event.Action != "reopened" {
+			return
+		}
+		headline = fmt.Sprintf("🐛 Issue #%d %s by %s: %s", event.Issue.Number, event.Action, event.Sender.Login, event.Issue.Title)
+		details, link = event.Issue.Body, event.Issue.HTMLURL
+	case kind == "release" && event.Release != nil:
+		if event.Action != "published" {
+			return
+		}
+		name := event.Release.Name
+		if name == "" {
+			name = event.Release.TagName
+		}
+		headline = fmt.Sprintf("🚀 Release %s published", name)
+		details, link = event.Release.Body, event.Release.HTMLURL
+	default:
+		return
+	}
+
+	links := bs.gitHubLinks(bson.M{"repo": strings.ToLower(event.Repository.FullName)})
+	if len(links) == 0 {
+		return
+	}
+
+	text := fmt.Sprintf("[%s] %s", event.Repository.FullName, headline)
+	if summary := bs.summarizeGitHubText(headline, details); summary != "" {
+		text += "\n\n" + summary
+	}
+	text += "\n" + link
+
+	for _, l := range links {
+		bs.sendAs(kindNotification, tgbotapi.NewMessage(l.ChatID, text))
+	}
+}
+
+// summarizeGitHubText condenses a PR, issue or release description into a couple of sentences
+func (bs *BotService) summarizeGitHubText(headline, details string) string {
+	if strings.TrimSpace(details) == "" {
+		return ""
+	}
+
+	prompt := fmt.Sprintf(`Summarize this GitHub update for a developer group chat in 1-2 plain-text sentences (no markdown):
+
+%s`, untrusted("GitHub update", headline+"\n\n"+truncateRunes(details, maxPRDiffChars)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	summary, err := bs.generate(ctx, prompt)
+	if err != nil {
+		log.Printf("gemini GitHub summary error: %v", err)
+		return ""
+	}
+	return summary
+}
+
+// handlePRCommand summarizes a pull request diff on demand
+func (bs *BotService) handlePRCommand(msg *tgbotapi.Message) {
+	args := strings.Fields(msg.CommandArguments())
+
+	var repo, number string
+	switch len(args) {
+	case 1:
+		links := bs.gitHubLinks(bson.M{"chat_id": msg.Chat.ID})
+		if len(links) != 1 {
+			bs.Reply(msg, prUsageMsg)
+			return
+		}
+		repo, number = links[0].Repo, args[0]
+	case 2:
+		repo, number = strings.ToLower(args[0]), args[1]
+	default:
+		bs.Reply(msg, prUsageMsg)
+		return
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(number, "#"))
+	if err != nil || !repoPattern.MatchString(repo) {
+		bs.Reply(msg, prUsageMsg)
+		return
+	}
+
+	title, diff, err := bs.fetchPullRequest(repo, n)
+	if err != nil {
+		log.Printf("failed to fetch PR %s#%d: %v", repo, n, err)
+		bs.Reply(msg, fmt.Sprintf("I couldn't fetch %s#%d from GitHub.", repo, n))
+		return
+	}
+
+	prompt := fmt.Sprintf(`You are reviewing a GitHub pull request for a developer group chat.
+
+%s
+
+Summary instructions:
+1. Explain what the change does and why in 2-3 sentences
+2. List the most important files or areas touched
+3. Point out anything risky a reviewer should look at
+4. Plain text, no markdown, keep it under 150 words`,
+		untrusted("pull request", "Title: "+title+"\n\nDiff:\n"+truncateRunes(diff, maxPRDiffChars)))
+
+	ctx, cancel := context.WithTimeout(bs.billedContext(msg), 90*time.Second)
+	defer cancel()
+
+	summary, err := bs.generate(ctx, prompt)
+	if err != nil {
+		log.Printf("gemini PR summary error: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("🔀 %s#%d: %s\n\n%s\nhttps://github.com/%s/pull/%d", repo, n, title, summary, repo, n))
+}
+
+// fetchPullRequest returns the title and unified diff of a pull request
+func (bs *BotService) fetchPullRequest(repo string, number int) (string, string, error) {
+	url := fmt.Sprintf("%s/repos/%s/pulls/%d", githubAPIURL, repo, number)
+
+	var pr struct {
+		Title string `json:"title"`
+	}
+	meta, err := bs.githubGet(url, "application/vnd.github+json")
+	if err != nil {
+		return "", "", err
+	}
+	if err := json.Unmarshal(meta, &pr); err != nil {
+		return "", "", err
+	}
+
+	diff, err := bs.githubGet(url, "application/vnd.github.diff")
+	if err != nil {
+		return "", "", err
+	}
+	return pr.Title, string(diff), nil
+}
+
+func (bs *BotService) githubGet(url, accept string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if bs.config().GitHubToken != "" {
+		req.Header.Set("Authorization", "Bearer "+bs.config().GitHubToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("github API returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxWebhookBodyBytes))
+}
+
+// truncateRunes shortens s to at most n runes
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
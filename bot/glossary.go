@@ -0,0 +1,256 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+const (
+	glossaryMiningMessages = 500
+	// maxGlossaryPromptTerms caps the terms given to answer prompts
+	maxGlossaryPromptTerms  = 60
+	maxGlossaryTermRunes    = 60
+	maxGlossaryMeaningRunes = 300
+	glossaryUsageMsg        = `Usage:
+/glossary - list this chat's terms
+/glossary build - find jargon, nicknames and inside jokes in recent messages (admins)
+/glossary set <term> = <meaning> - add or correct a term (admins)
+/glossary remove <term> - remove a term (admins)`
+)
+
+// glossarySchema is the answer of the jargon mining
+var glossarySchema = &llm.Schema{
+	Type: "object",
+	Properties: map[string]*llm.Schema{
+		"terms": {Type: "array", Items: &llm.Schema{
+			Type: "object",
+			Properties: map[string]*llm.Schema{
+				"term":    {Type: "string", Description: "the term as the chat writes it"},
+				"meaning": {Type: "string", Description: "what it means in this chat, in one sentence"},
+			},
+			Required: []string{"term", "meaning"},
+		}},
+		"nicknames": {Type: "array", Items: &llm.Schema{
+			Type: "object",
+			Properties: map[string]*llm.Schema{
+				"username": {Type: "string", Description: "the member's @username as it appears in the messages"},
+				"nickname": {Type: "string", Description: "the name the chat calls them by"},
+			},
+			Required: []string{"username", "nickname"},
+		}},
+	},
+	Required: []string{"terms", "nicknames"},
+}
+
+func (bs *BotService) handleGlossary(msg *tgbotapi.Message) {
+	sub, rest, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+	sub = strings.ToLower(sub)
+	if sub != "" && bs.userRole(msg.Chat, msg.From) < ChatAdmin {
+		bs.Reply(msg, "Only admins can change the glossary.")
+		return
+	}
+
+	switch sub {
+	case "":
+		bs.listGlossary(msg)
+	case "build":
+		bs.buildGlossary(msg)
+	case "set":
+		term, meaning, ok := strings.Cut(rest, "=")
+		term, meaning = strings.TrimSpace(term), strings.TrimSpace(meaning)
+		if !ok || term == "" || meaning == "" {
+			bs.Reply(msg, glossaryUsageMsg)
+			return
+		}
+		t := store.GlossaryTerm{ChatID: msg.Chat.ID, Term: truncateRunes(term, maxGlossaryTermRunes),
+			Meaning: truncateRunes(meaning, maxGlossaryMeaningRunes), AddedBy: msg.From.ID}
+		if err := bs.store.SetGlossaryTerm(t); err != nil {
+			log.Printf("Error saving a glossary term of chat %d: %v", msg.Chat.ID, err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bs.Reply(msg, fmt.Sprintf("📖 %s: %s", t.Term, t.Meaning))
+	case "remove":
+		term := strings.TrimSpace(rest)
+		if term == "" {
+			bs.Reply(msg, glossaryUsageMsg)
+			return
+		}
+		removed, err := bs.store.RemoveGlossaryTerm(msg.Chat.ID, term)
+		if err != nil {
+			log.Printf("Error removing a glossary term of chat %d: %v", msg.Chat.ID, err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		if !removed {
+			bs.Reply(msg, fmt.Sprintf("%q isn't in the glossary.", term))
+			return
+		}
+		bs.Reply(msg, fmt.Sprintf("Removed %q from the glossary.", term))
+	default:
+		bs.Reply(msg, glossaryUsageMsg)
+	}
+}
+
+func (bs *BotService) listGlossary(msg *tgbotapi.Message) {
+	terms, err := bs.store.Glossary(msg.Chat.ID)
+	if err != nil {
+		log.Printf("Error loading the glossary of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(terms) == 0 {
+		bs.Reply(msg, "📖 The glossary is empty.\n\n"+glossaryUsageMsg)
+		return
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📖 %d terms of this chat:\n", len(terms))
+	for _, t := range terms {
+		fmt.Fprintf(&sb, "\n%s: %s", t.Term, t.Meaning)
+	}
+	bs.Reply(msg, sb.String())
+}
+
+// buildGlossary asks the model for the chat-specific terms of recent messages and adds those the glossary
+// doesn't have yet, leaving the meanings admins set alone
+func (bs *BotService) buildGlossary(msg *tgbotapi.Message) {
+	messages, err := bs.fetchMessagesFromDB(msg.Chat.ID, glossaryMiningMessages)
+	if err != nil {
+		log.Printf("Error loading messages of chat %d for the glossary: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(messages) == 0 {
+		bs.Reply(msg, "There are no stored messages to find terms in yet.")
+		return
+	}
+	known, err := bs.store.Glossary(msg.Chat.ID)
+	if err != nil {
+		log.Printf("Error loading the glossary of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	var knownTerms []string
+	for _, t := range known {
+		knownTerms = append(knownTerms, t.Term)
+	}
+	nicknamed, err := bs.store.Nicknames(msg.Chat.ID)
+	if err != nil {
+		log.Printf("Error loading the nicknames of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	var knownNicknames []string
+	for _, n := range nicknamed {
+		knownNicknames = append(knownNicknames, "@"+n.Username)
+	}
+
+	prompt := fmt.Sprintf(`Below are recent messages of a Telegram group. Find the terms specific to this community that an outsider or a newcomer wouldn't understand: jargon, abbreviations, nicknames of members or places (like "the usual place"), recurring inside jokes and running references.
+
+%s
+
+Instructions:
+1. Only include terms whose meaning you can infer from the messages, and explain them as this chat uses them
+2. Leave out common slang and words with their usual meaning
+3. Leave out the terms already in the glossary: %s
+4. At most 20 terms, an empty list if there are none
+5. Separately list the nicknames members are called by when others talk to or about them, with the @username of the member, like "Mili" for @sg_milad; only when the messages make clear who is meant
+6. Leave out members whose nickname is known already: %s`, untrusted("chat messages", strings.Join(messages, "\n")), strings.Join(knownTerms, ", "),
+		strings.Join(knownNicknames, ", "))
+
+	ctx, cancel := context.WithTimeout(bs.billedContext(msg), 90*time.Second)
+	defer cancel()
+	var answer struct {
+		Terms []struct {
+			Term    string `json:"term"`
+			Meaning string `json:"meaning"`
+		} `json:"terms"`
+		Nicknames []struct {
+			Username string `json:"username"`
+			Nickname string `json:"nickname"`
+		} `json:"nicknames"`
+	}
+	err = bs.generateJSON(ctx, prompt, glossarySchema, &answer, func() error {
+		for _, t := range answer.Terms {
+			if strings.TrimSpace(t.Term) == "" || strings.TrimSpace(t.Meaning) == "" {
+				return errors.New("every term needs a term and a meaning")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error mining the glossary of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	var terms []store.GlossaryTerm
+	for _, t := range answer.Terms {
+		terms = append(terms, store.GlossaryTerm{
+			Term:    truncateRunes(strings.TrimSpace(t.Term), maxGlossaryTermRunes),
+			Meaning: truncateRunes(strings.TrimSpace(t.Meaning), maxGlossaryMeaningRunes),
+		})
+	}
+	added, err := bs.store.AddGlossaryTerms(msg.Chat.ID, terms)
+	if err != nil {
+		log.Printf("Error saving the glossary of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	// Only members who posted in the mined messages can be nicknamed, the model may make others up
+	history := strings.ToLower(strings.Join(messages, "\n"))
+	var nicknames []store.Nickname
+	for _, n := range answer.Nicknames {
+		username := strings.TrimPrefix(strings.TrimSpace(n.Username), "@")
+		nickname := strings.TrimSpace(n.Nickname)
+		posted := "@" + strings.ToLower(username)
+		if username == "" || nickname == "" || !strings.Contains(history, posted+":") && !strings.Contains(history, posted+"):") {
+			continue
+		}
+		nicknames = append(nicknames, store.Nickname{Username: username, Nickname: truncateRunes(nickname, maxNicknameRunes)})
+	}
+	learned, err := bs.store.AddNicknames(msg.Chat.ID, nicknames)
+	if err != nil {
+		log.Printf("Error saving the nicknames of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	if added == 0 && learned == 0 {
+		bs.Reply(msg, "📖 I didn't find any new terms.")
+		return
+	}
+	reply := fmt.Sprintf("📖 Added %d terms, see /glossary. Correct them with /glossary set <term> = <meaning>.", added)
+	if learned > 0 {
+		reply += fmt.Sprintf("\n👤 Learned %d nicknames, see /alias names.", learned)
+	}
+	bs.Reply(msg, reply)
+}
+
+// glossaryContext is the part of the answer prompt with the chat's glossary, or "" when it has none
+func (bs *BotService) glossaryContext(chat *tgbotapi.Chat) string {
+	if chat.IsPrivate() {
+		return ""
+	}
+	terms, err := bs.store.Glossary(chat.ID)
+	if err != nil {
+		log.Printf("Error loading the glossary of chat %d: %v", chat.ID, err)
+		return ""
+	}
+	if len(terms) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for _, t := range terms[:min(len(terms), maxGlossaryPromptTerms)] {
+		fmt.Fprintf(&sb, "- %s: %s\n", t.Term, t.Meaning)
+	}
+	return "Terms this chat uses, understand the messages with them:\n" + untrusted("chat glossary", sb.String())
+}
@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// groundedMessages is how many chat messages are retrieved for an answer in grounded mode
+	groundedMessages = 8
+	// groundedUnknown is what the model answers in grounded mode when the context doesn't have the answer
+	groundedUnknown = "NOT_FOUND"
+
+	groundedUnknownMsg   = "🤷 I don't know. Nothing in this chat's history or knowledge base answers that."
+	groundedUsageMsg     = "Usage: /grounded on|off - answer only from this chat's history and knowledge base, and say so when they don't have the answer"
+	groundedOnMsg        = "📎 Grounded answers are on: I'll answer only from this chat's history and knowledge base, cite them, and say when I don't know."
+	groundedOffMsg       = "Grounded answers are off."
+	groundedNoHistoryMsg = "This group runs stateless, so grounded answers can only use the knowledge base."
+)
+
+// groundedSnippet is one retrieved piece of context, numbered for citations
+type groundedSnippet struct {
+	fromKnowledge bool
+	text          string
+}
+
+// groundedAnswer answers only from the chat messages and knowledge base items retrieved for the question, citing
+// them like [2], and says it doesn't know instead of guessing; a footer tells where the answer came from
+func (bs *BotService) groundedAnswer(ctx context.Context, chat *tgbotapi.Chat, query string, style answerStyle) string {
+	var snippets []groundedSnippet
+	if bs.historyEnabled(chat.ID) {
+		messages, err := bs.searchMessages(ctx, chat.ID, query, groundedMessages)
+		if err != nil {
+			log.Printf("Error searching messages of chat %d: %v", chat.ID, err)
+		}
+		loc := bs.chatLocation(chat.ID)
+		for _, m := range messages {
+			snippets = append(snippets, groundedSnippet{text: fmt.Sprintf("%s, %s: %s", m.Timestamp.In(loc).Format("2006-01-02"), m.Author(), m.Text)})
+		}
+	}
+	if !chat.IsPrivate() && bs.store.HasKnowledge(chat.ID) {
+		items, err := bs.store.SearchKnowledge(chat.ID, query, knowledgeResults)
+		if err != nil {
+			log.Printf("Error searching the knowledge base of chat %d: %v", chat.ID, err)
+		}
+		for _, item := range items {
+			snippets = append(snippets, groundedSnippet{fromKnowledge: true, text: item.Title + ": " + bestExcerpt(item.Text, query, knowledgeExcerptRunes)})
+		}
+	}
+	if len(snippets) == 0 {
+		return groundedUnknownMsg
+	}
+
+	var context strings.Builder
+	for i, s := range snippets {
+		fmt.Fprintf(&context, "[%d] %s\n", i+1, s.text)
+	}
+	prompt := fmt.Sprintf(`You answer questions in a Telegram chat using ONLY the numbered context below, taken from the chat's message history and its admins' knowledge base. Never add facts from your own knowledge.
+
+Context:
+%s
+
+Question: %s
+
+Guidelines:
+1. If the context doesn't clearly answer the question, reply with exactly %s and nothing else
+2. Cite the context you use by its number, like [2]
+3. %s
+4. %s
+5. DO NOT use markdown formatting
+Response language: %s`, untrusted("chat history and knowledge base", context.String()), query, groundedUnknown, style.Length, style.Tone, bs.responseLanguage(chat))
+
+	answer, err := bs.generate(ctx, prompt)
+	if errors.Is(err, errCancelled) {
+		return ""
+	}
+	if errors.Is(err, errFamilyFiltered) {
+		return familyFilteredMsg
+	}
+	if err != nil {
+		log.Printf("gemini grounded generation error: %v", err)
+		return responseErrorMsg
+	}
+	if strings.Contains(answer, groundedUnknown) {
+		return groundedUnknownMsg
+	}
+	return strings.TrimSpace(answer) + "\n\n" + groundedFooter(answer, snippets)
+}
+
+// groundedFooter says where an answer came from, like "source: chat history, 3 messages", counting the
+// snippets it cites, or all of them when it cites none
+func groundedFooter(answer string, snippets []groundedSnippet) string {
+	used := map[int]bool{}
+	for _, m := range citationRe.FindAllStringSubmatch(answer, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil && n >= 1 && n <= len(snippets) {
+			used[n-1] = true
+		}
+	}
+	var messages, items int
+	for i, s := range snippets {
+		if len(used) > 0 && !used[i] {
+			continue
+		}
+		if s.fromKnowledge {
+			items++
+		} else {
+			messages++
+		}
+	}
+
+	var parts []string
+	if messages > 0 {
+		parts = append(parts, fmt.Sprintf("chat history, %d message%s", messages, pluralS(messages)))
+	}
+	if items > 0 {
+		parts = append(parts, fmt.Sprintf("knowledge base, %d item%s", items, pluralS(items)))
+	}
+	return "📎 source: " + strings.Join(parts, " · ")
+}
+
+func pluralS(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// handleGrounded turns grounded answers on or off for the chat
+func (bs *BotService) handleGrounded(msg *tgbotapi.Message) {
+	var enabled bool
+	switch strings.ToLower(strings.TrimSpace(msg.CommandArguments())) {
+	case "on":
+		enabled = true
+	case "off":
+	default:
+		state := "off"
+		if bs.store.ChatSettings(msg.Chat.ID).Grounded {
+			state = "on"
+		}
+		bs.Reply(msg, "Grounded answers are "+state+".\n\n"+groundedUsageMsg)
+		return
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"grounded": enabled}); err != nil {
+		log.Printf("Error saving grounded setting for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	switch {
+	case !enabled:
+		bs.Reply(msg, groundedOffMsg)
+	case !bs.historyEnabled(msg.Chat.ID):
+		bs.Reply(msg, groundedOnMsg+"\n\n"+groundedNoHistoryMsg)
+	default:
+		bs.Reply(msg, groundedOnMsg)
+	}
+}
@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// groundingResults is how many results one web search gives the model
+	groundingResults = 5
+	// maxListedSources caps the "Sources:" links under an answer
+	maxListedSources  = 5
+	websearchUsageMsg = `Usage:
+/websearch on - let the bot search the web for questions about current events, citing its sources
+/websearch off - answer from the model's own knowledge only`
+)
+
+// citationRe matches citations like [2] in an answer
+var citationRe = regexp.MustCompile(`\[(\d+)\]`)
+
+// webSearchTool lets the model search the web; results are numbered across searches and collected in sources,
+// so the answer can cite them like [1] and list them under the reply
+func webSearchTool(search searchProvider, sources *[]webResult) llm.Tool {
+	return llm.Tool{
+		Name:        "web_search",
+		Description: "Searches the web. Use it for current events, recent facts and anything that may have changed after your training. Cite the results you use in the answer by their number, like [1].",
+		Parameters: map[string]llm.Parameter{
+			"query": {Type: "string", Description: "The search query"},
+		},
+		Required: []string{"query"},
+		Call: func(ctx context.Context, args map[string]any) (any, error) {
+			results, err := search.Search(ctx, llm.StringArg(args, "query"), groundingResults)
+			if err != nil {
+				return nil, err
+			}
+			type numbered struct {
+				Number int `json:"number"`
+				webResult
+			}
+			out := make([]numbered, len(results))
+			for i, r := range results {
+				out[i] = numbered{len(*sources) + i + 1, r}
+			}
+			*sources = append(*sources, results...)
+			return out, nil
+		},
+	}
+}
+
+// withSources appends the web results an answer cites as "Sources:" links; when it cites none,
+// the top results are listed, since the answer still drew on them
+func withSources(answer string, sources []webResult) string {
+	if len(sources) == 0 {
+		return answer
+	}
+	var cited []int
+	seen := make(map[int]bool)
+	for _, m := range citationRe.FindAllStringSubmatch(answer, -1) {
+		n, _ := strconv.Atoi(m[1])
+		if n >= 1 && n <= len(sources) && !seen[n] {
+			seen[n] = true
+			cited = append(cited, n)
+		}
+	}
+	if len(cited) == 0 {
+		for n := 1; n <= min(len(sources), 3); n++ {
+			cited = append(cited, n)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(answer) + "\n\nSources:")
+	for _, n := range cited[:min(len(cited), maxListedSources)] {
+		fmt.Fprintf(&sb, "\n[%d] %s\n%s", n, truncateRunes(sources[n-1].Title, 80), sources[n-1].Link)
+	}
+	return sb.String()
+}
+
+func (bs *BotService) handleWebSearch(msg *tgbotapi.Message) {
+	var enabled bool
+	switch strings.ToLower(strings.TrimSpace(msg.CommandArguments())) {
+	case "on":
+		if bs.searchProvider() == nil {
+			bs.Reply(msg, "Web search isn't set up for this bot.")
+			return
+		}
+		enabled = true
+	case "off":
+	default:
+		state := "off"
+		if bs.store.ChatSettings(msg.Chat.ID).WebSearch {
+			state = "on"
+		}
+		bs.Reply(msg, "Web search is "+state+".\n\n"+websearchUsageMsg)
+		return
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"web_search": enabled}); err != nil {
+		log.Printf("Error saving web search setting for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if enabled {
+		bs.Reply(msg, "🌐 Web search is on. Answers about current events will cite their sources.")
+		return
+	}
+	bs.Reply(msg, "Web search is off.")
+}
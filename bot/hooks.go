@@ -0,0 +1,225 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxHookBodyBytes = 64 << 10
+	hookUsageMsg     = `Usage:
+/hook create - create a webhook URL that posts into this chat (replaces the old one)
+/hook revoke - disable the webhook URL`
+)
+
+// ChatHook lets external systems post into a chat; only the SHA-256 of the token is stored
+type ChatHook struct {
+	ChatID    int64     `bson:"chat_id"`
+	TokenHash string    `bson:"token_hash"`
+	CreatedBy int64     `bson:"created_by"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// hookPayload is the JSON accepted by the ingestion endpoint; any other JSON object is rendered as key/value lines
+type hookPayload struct {
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+	Level    string `json:"level"`
+	URL      string `json:"url"`
+	Rephrase bool   `json:"rephrase"`
+}
+
+func (bs *BotService) handleHookCommand(msg *tgbotapi.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	hooks := bs.db.Collection("chat_hooks")
+
+	switch strings.ToLower(strings.TrimSpace(msg.CommandArguments())) {
+	case "create":
+		token, err := newHookToken()
+		if err != nil {
+			log.Printf("failed to generate hook token: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		hook := ChatHook{ChatID: msg.Chat.ID, TokenHash: hashHookToken(token), CreatedBy: msg.From.ID, CreatedAt: time.Now()}
+		if _, err := hooks.ReplaceOne(ctx, bson.M{"chat_id": msg.Chat.ID}, hook, options.Replace().SetUpsert(true)); err != nil {
+			log.Printf("Error storing chat hook: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+
+		base := strings.TrimSuffix(bs.config().PublicURL, "/")
+		if base == "" {
+			base = "<bot address>"
+		}
+		reply := fmt.Sprintf(`🔗 Webhook created. POST JSON to:
+%s/hooks/%s
+
+Example body: {"title": "Deploy finished", "text": "v1.2.3 is live", "level": "info", "rephrase": false}
+Keep this URL secret, anyone who has it can post here. Use /hook revoke to disable it.`, base, token)
+
+		// The token is a secret, so send it privately when the command came from a group
+		_, err = bs.api.Send(tgbotapi.NewMessage(msg.From.ID, reply))
+		switch {
+		case err != nil:
+			log.Printf("failed to DM hook token: %v", err)
+			bs.Reply(msg, "I couldn't DM you the webhook URL. Start a chat with me first, then run /hook create again.")
+		case !msg.Chat.IsPrivate():
+			bs.Reply(msg, "🔗 Webhook created, I sent you the URL in a DM.")
+		}
+	case "revoke":
+		if _, err := hooks.DeleteOne(ctx, bson.M{"chat_id": msg.Chat.ID}); err != nil {
+			log.Printf("Error revoking chat hook: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bs.Reply(msg, "Webhook revoked.")
+	default:
+		bs.Reply(msg, hookUsageMsg)
+	}
+}
+
+// handleHookIngest renders JSON pushed by external systems into the chat owning the token
+func (bs *BotService) handleHookIngest(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var hook ChatHook
+	err := bs.db.Collection("chat_hooks").FindOne(ctx, bson.M{"token_hash": hashHookToken(token)}).Decode(&hook)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("Error loading chat hook: %v", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, "unknown hook", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHookBodyBytes))
+	if err != nil || len(body) == 0 {
+		http.Error(w, "empty body", http.StatusBadRequest)
+		return
+	}
+
+	text, rephrase, err := renderHookBody(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("rephrase") == "1" {
+		rephrase = true
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	bs.goSafe("hook notification", func() {
+		if rephrase {
+			text = bs.rephraseNotification(text)
+		}
+		bs.sendAs(kindNotification, tgbotapi.NewMessage(hook.ChatID, text))
+	})
+}
+
+// renderHookBody turns a pushed body into message text: the known fields of hookPayload,
+// any other JSON object as sorted key/value lines, or a non-JSON body as plain text
+func renderHookBody(body []byte) (string, bool, error) {
+	if !json.Valid(body) {
+		return strings.TrimSpace(string(body)), false, nil
+	}
+
+	var payload hookPayload
+	if err := json.Unmarshal(body, &payload); err == nil && (payload.Text != "" || payload.Title != "") {
+		var sb strings.Builder
+		sb.WriteString(levelIcon(payload.Level))
+		if payload.Title != "" {
+			sb.WriteString(payload.Title + "\n")
+		}
+		if payload.Text != "" {
+			sb.WriteString(payload.Text + "\n")
+		}
+		if payload.URL != "" {
+			sb.WriteString(payload.URL)
+		}
+		return strings.TrimSpace(sb.String()), payload.Rephrase, nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return "", false, errors.New("body must be a JSON object")
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var lines []string
+	for _, k := range keys {
+		value, _ := json.Marshal(fields[k])
+		lines = append(lines, fmt.Sprintf("%s: %s", k, strings.Trim(string(value), `"`)))
+	}
+	return "🔔 " + strings.Join(lines, "\n"), false, nil
+}
+
+func levelIcon(level string) string {
+	switch strings.ToLower(level) {
+	case "error", "critical":
+		return "🚨 "
+	case "warning", "warn":
+		return "⚠️ "
+	case "success":
+		return "✅ "
+	default:
+		return "🔔 "
+	}
+}
+
+// rephraseNotification rewrites a machine notification into a short human-friendly message
+func (bs *BotService) rephraseNotification(text string) string {
+	prompt := fmt.Sprintf(`Rewrite this automated notification as a short, clear message for a Telegram group (1-3 sentences, plain text, no markdown). Keep all important facts, numbers and links:
+
+%s`, untrusted("webhook notification", text))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rephrased, err := bs.generate(ctx, prompt)
+	if err != nil {
+		log.Printf("gemini rephrase error: %v", err)
+		return text
+	}
+	return rephrased
+}
+
+func newHookToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashHookToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,42 @@
+package bot
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// startHTTPServer serves webhooks and other HTTP endpoints on cfg.HTTPAddr, if configured
+func (bs *BotService) startHTTPServer() {
+	if bs.config().HTTPAddr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	bs.registerRoutes(mux)
+
+	server := &http.Server{
+		Addr:              bs.config().HTTPAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("HTTP server listening on %s", bs.config().HTTPAddr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP server error: %v", err)
+		}
+	}()
+}
+
+func (bs *BotService) registerRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("GET /metrics", bs.handleMetrics)
+	mux.HandleFunc("POST /github/webhook", bs.handleGitHubWebhook)
+	mux.HandleFunc("POST /hooks/{token}", bs.handleHookIngest)
+	mux.HandleFunc("GET /files/{key...}", bs.handleFileDownload)
+	bs.registerAPIRoutes(mux)
+}
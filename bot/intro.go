@@ -0,0 +1,157 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const introMsg = `👋 Hi everyone, I'm %s! Mention me or reply to me to ask anything, and I'll answer with this chat's context in mind.
+
+- /summary sums up the latest messages, /search and /whosaid find what was said
+- /kb teaches me the group's own docs and FAQs
+- /events, /todo and /expense keep track of plans, tasks and shared costs
+
+Admins can set me up with the buttons below, and see everything with /settings and /help.`
+
+// introSetups are the quick-setup buttons of the intro, toggled by admins; web search is only offered when set up
+var introSetups = []struct{ key, label string }{
+	{"family", "👨‍👩‍👧 Family profile"},
+	{"grounded", "📎 Grounded answers"},
+	{"websearch", "🌐 Web search"},
+}
+
+// handleMyChatMember registers the groups the bot is added to or removed from, and introduces itself
+// when it joins one, unless a chat policy makes it leave right away
+func (bs *BotService) handleMyChatMember(update *tgbotapi.ChatMemberUpdated) {
+	if update.Chat.IsPrivate() || update.Chat.IsChannel() {
+		return
+	}
+	wasIn := update.OldChatMember.Status == "member" || update.OldChatMember.Status == "administrator" ||
+		update.OldChatMember.Status == "creator" || (update.OldChatMember.Status == "restricted" && update.OldChatMember.IsMember)
+	isIn := update.NewChatMember.Status == "member" || update.NewChatMember.Status == "administrator" ||
+		(update.NewChatMember.Status == "restricted" && update.NewChatMember.IsMember)
+
+	switch {
+	case !wasIn && isIn:
+		if err := bs.store.RecordChatJoin(update.Chat.ID, update.Chat.Title, update.Chat.Type, update.From.ID, bs.id); err != nil {
+			log.Printf("Error registering chat %d: %v", update.Chat.ID, err)
+		}
+		joined := store.ChatRecord{
+			ChatID:         update.Chat.ID,
+			Title:          update.Chat.Title,
+			Type:           update.Chat.Type,
+			MemberCount:    bs.chatInfo(update.Chat.ID).MemberCount,
+			LastActivityAt: time.Now(),
+		}
+		if reason := bs.leaveReason(joined, time.Now()); reason != "" {
+			bs.leaveChat(joined, reason)
+			return
+		}
+		bs.postIntro(&update.Chat, &update.From)
+		bs.postPrivacyNotice(&update.Chat)
+	case isIn:
+		// Changed rights may let the bot post again
+		bs.clearCannotPost(update.Chat.ID)
+	case wasIn && !isIn:
+		if err := bs.store.RecordChatLeave(update.Chat.ID, bs.id); err != nil {
+			log.Printf("Error marking chat %d as left: %v", update.Chat.ID, err)
+		}
+		bs.dropKnowledgeCache(update.Chat.ID)
+	}
+}
+
+// postIntro introduces the bot to a group, in the language of the user who added it
+func (bs *BotService) postIntro(chat *tgbotapi.Chat, adder *tgbotapi.User) {
+	text := fmt.Sprintf(introMsg, bs.botMention)
+	lang := cmp.Or(bs.store.UserPreferences(adder.ID).Language, adder.LanguageCode)
+	if lang != "" && !strings.HasPrefix(lang, "en") {
+		text = bs.localize(text, lang)
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	for _, setup := range introSetups {
+		if setup.key == "websearch" && bs.searchProvider() == nil {
+			continue
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(setup.label, "setup:"+setup.key))
+	}
+	intro := tgbotapi.NewMessage(chat.ID, text)
+	intro.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(row)
+	bs.sendResponse(intro)
+}
+
+// localize translates a bot message into the language with the given code, keeping it as is on error
+func (bs *BotService) localize(text, lang string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	prompt := fmt.Sprintf(`Translate this Telegram bot message into the language with the code %q. Keep the emoji, the line breaks, the bot's @username and the /commands exactly as they are, and reply with the translation only:
+
+%s`, lang, untrusted("bot message", text))
+	translated, err := bs.generate(ctx, prompt)
+	if err != nil || strings.TrimSpace(translated) == "" {
+		log.Printf("Error translating a message into %s: %v", lang, err)
+		return text
+	}
+	return strings.TrimSpace(translated)
+}
+
+// handleSetupCallback toggles a setting from the intro's quick-setup buttons
+func (bs *BotService) handleSetupCallback(cb *tgbotapi.CallbackQuery) {
+	_, key, _ := strings.Cut(cb.Data, ":")
+	if cb.Message == nil {
+		bs.answerCallback(cb.ID, "")
+		return
+	}
+	chatID := cb.Message.Chat.ID
+	if !bs.isChatAdmin(chatID, cb.From.ID) {
+		bs.answerCallback(cb.ID, consentAdminsOnlyMsg)
+		return
+	}
+
+	settings := bs.store.ChatSettings(chatID)
+	var change bson.M
+	var state string
+	switch key {
+	case "family":
+		profile := ""
+		if settings.Profile != profileFamily {
+			profile = profileFamily
+		}
+		change, state = bson.M{"profile": profile}, "Family profile "+onOff(profile == profileFamily)
+	case "grounded":
+		change, state = bson.M{"grounded": !settings.Grounded}, "Grounded answers "+onOff(!settings.Grounded)
+	case "websearch":
+		if bs.searchProvider() == nil {
+			bs.answerCallback(cb.ID, "Web search isn't set up for this bot.")
+			return
+		}
+		change, state = bson.M{"web_search": !settings.WebSearch}, "Web search "+onOff(!settings.WebSearch)
+	default:
+		bs.answerCallback(cb.ID, "")
+		return
+	}
+
+	if err := bs.store.UpdateChatSettings(chatID, change); err != nil {
+		log.Printf("Error saving quick setup of chat %d: %v", chatID, err)
+		bs.answerCallback(cb.ID, responseErrorMsg)
+		return
+	}
+	bs.audit(chatID, cb.From, "/settings", strings.ToLower(state))
+	bs.answerCallback(cb.ID, state)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
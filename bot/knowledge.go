@@ -0,0 +1,372 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// knowledgeStartPrefix is the /start payload of links that open the knowledge base DM for a group, like kb_-100123
+	knowledgeStartPrefix = "kb_"
+	// maxKnowledgeRunes caps the text kept of one item; maxKnowledgeFile caps the documents downloaded, in bytes
+	maxKnowledgeRunes = 50000
+	maxKnowledgeFile  = 1 << 20
+	// knowledgeResults items are searched per question, each contributing its most relevant knowledgeExcerptRunes
+	knowledgeResults      = 3
+	knowledgeExcerptRunes = 1500
+	knowledgeTitleRunes   = 60
+	// maxCachedKnowledgeRunes caps the knowledge bases read whole from the context cache, see cachedKnowledge
+	maxCachedKnowledgeRunes = 400000
+	knowledgePreface        = "The group's admins curated the knowledge below. Prefer it over your own knowledge when it answers the question.\n"
+	knowledgeDMUsageMsg     = `Send me text snippets or text documents (.txt, .md, .csv, .json) and I'll add them to %s's knowledge base; answers in the group prefer it.
+/kb list - list the knowledge base
+/kb remove <number> - remove an item
+/kb done - stop adding`
+	knowledgeNoGroupMsg  = "Send /kb in your group first and follow the link, so I know which group's knowledge base to manage."
+	knowledgeNotAdminMsg = "Only admins of %s can manage its knowledge base."
+	knowledgeFileTypeMsg = "I can only read text documents (.txt, .md, .csv, .json) up to 1 MB."
+)
+
+// knowledgeExtensions are the document types read as plain text, besides text/* MIME types
+var knowledgeExtensions = []string{".txt", ".md", ".markdown", ".csv", ".json"}
+
+// knowledgeLink is the deep link that opens the knowledge base DM for a group
+func (bs *BotService) knowledgeLink(chatID int64) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", strings.TrimPrefix(bs.botMention, "@"), knowledgeStartPrefix, chatID)
+}
+
+// groupTitle is a chat's title for messages, or its ID when the title isn't known
+func (bs *BotService) groupTitle(chatID int64) string {
+	if title := bs.chatInfo(chatID).Title; title != "" {
+		return title
+	}
+	return strconv.FormatInt(chatID, 10)
+}
+
+// startKnowledgeTraining opens the knowledge base DM for the group in a /kb deep link, for its admins
+func (bs *BotService) startKnowledgeTraining(msg *tgbotapi.Message, payload string) {
+	groupID, err := strconv.ParseInt(strings.TrimPrefix(payload, knowledgeStartPrefix), 10, 64)
+	if err != nil || groupID >= 0 {
+		bs.Reply(msg, knowledgeNoGroupMsg)
+		return
+	}
+	if !bs.isChatAdmin(groupID, msg.From.ID) {
+		bs.Reply(msg, fmt.Sprintf(knowledgeNotAdminMsg, bs.groupTitle(groupID)))
+		return
+	}
+	if err := bs.store.UpdateUserPreferences(msg.From.ID, bson.M{"knowledge_chat_id": groupID}); err != nil {
+		log.Printf("Error saving the knowledge base group of user %d: %v", msg.From.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, "📚 "+fmt.Sprintf(knowledgeDMUsageMsg, bs.groupTitle(groupID)))
+}
+
+// knowledgeGroup is the group whose knowledge base a user is adding to in the DM, or 0; users who are no longer
+// admins of it don't get it
+func (bs *BotService) knowledgeGroup(user *tgbotapi.User) int64 {
+	if user == nil {
+		return 0
+	}
+	groupID := bs.store.UserPreferences(user.ID).KnowledgeChatID
+	if groupID == 0 || !bs.isChatAdmin(groupID, user.ID) {
+		return 0
+	}
+	return groupID
+}
+
+func (bs *BotService) handleKnowledge(msg *tgbotapi.Message) {
+	if !msg.Chat.IsPrivate() {
+		reply := tgbotapi.NewMessage(msg.Chat.ID, "📚 Add documents and snippets to this group's knowledge base in a DM; my answers here will prefer them.")
+		reply.ReplyToMessageID = msg.MessageID
+		reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("📚 Manage the knowledge base", bs.knowledgeLink(msg.Chat.ID)),
+		))
+		bs.sendResponse(reply)
+		return
+	}
+
+	groupID := bs.knowledgeGroup(msg.From)
+	if groupID == 0 {
+		bs.Reply(msg, knowledgeNoGroupMsg)
+		return
+	}
+
+	args := strings.Fields(msg.CommandArguments())
+	if len(args) == 0 {
+		bs.Reply(msg, fmt.Sprintf(knowledgeDMUsageMsg, bs.groupTitle(groupID)))
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "list":
+		bs.listKnowledge(msg, groupID)
+	case "remove":
+		if len(args) < 2 {
+			bs.Reply(msg, "Usage: /kb remove <number>, with the number from /kb list")
+			return
+		}
+		bs.removeKnowledge(msg, groupID, args[1])
+	case "done":
+		if err := bs.store.UpdateUserPreferences(msg.From.ID, bson.M{"knowledge_chat_id": 0}); err != nil {
+			log.Printf("Error clearing the knowledge base group of user %d: %v", msg.From.ID, err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bs.Reply(msg, "✅ Done. Send /kb in a group to add more.")
+	default:
+		bs.Reply(msg, fmt.Sprintf(knowledgeDMUsageMsg, bs.groupTitle(groupID)))
+	}
+}
+
+func (bs *BotService) listKnowledge(msg *tgbotapi.Message, groupID int64) {
+	items, err := bs.store.ListKnowledge(groupID)
+	if err != nil {
+		log.Printf("Error listing the knowledge base of chat %d: %v", groupID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(items) == 0 {
+		bs.Reply(msg, fmt.Sprintf("%s's knowledge base is empty.", bs.groupTitle(groupID)))
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📚 %s's knowledge base:\n", bs.groupTitle(groupID))
+	for i, item := range items {
+		fmt.Fprintf(&sb, "\n%d. %s (%s)", i+1, item.Title, item.CreatedAt.Format("2006-01-02"))
+	}
+	bs.Reply(msg, sb.String())
+}
+
+// removeKnowledge removes the item numbered like in /kb list
+func (bs *BotService) removeKnowledge(msg *tgbotapi.Message, groupID int64, number string) {
+	items, err := bs.store.ListKnowledge(groupID)
+	if err != nil {
+		log.Printf("Error listing the knowledge base of chat %d: %v", groupID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 || n > len(items) {
+		bs.Reply(msg, fmt.Sprintf("There's no item %s, see /kb list.", number))
+		return
+	}
+	item := items[n-1]
+	if _, err := bs.store.RemoveKnowledge(groupID, item.ID); err != nil {
+		log.Printf("Error removing a knowledge item of chat %d: %v", groupID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("🗑 Removed %q.", item.Title))
+}
+
+// handleKnowledgeInput adds a snippet or document sent in the DM to the group's knowledge base
+func (bs *BotService) handleKnowledgeInput(msg *tgbotapi.Message) {
+	groupID := bs.knowledgeGroup(msg.From)
+	if groupID == 0 {
+		return
+	}
+
+	var title, text string
+	switch {
+	case msg.Document != nil:
+		var err error
+		if text, err = bs.readTextDocument(msg.Document); err != nil {
+			log.Printf("Error reading a knowledge document from user %d: %v", msg.From.ID, err)
+			bs.Reply(msg, knowledgeFileTypeMsg)
+			return
+		}
+		title = msg.Document.FileName
+	case msg.Text != "":
+		text = msg.Text
+		title, _, _ = strings.Cut(strings.TrimSpace(text), "\n")
+		title = truncateRunes(title, knowledgeTitleRunes)
+	default:
+		bs.Reply(msg, fmt.Sprintf(knowledgeDMUsageMsg, bs.groupTitle(groupID)))
+		return
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		bs.Reply(msg, "That's empty, there's nothing to add.")
+		return
+	}
+	truncated := utf8.RuneCountInString(text) > maxKnowledgeRunes
+	text = truncateRunes(text, maxKnowledgeRunes)
+
+	if err := bs.store.AddKnowledge(store.KnowledgeItem{ChatID: groupID, Title: title, Text: text, AddedBy: msg.From.ID}); err != nil {
+		log.Printf("Error adding to the knowledge base of chat %d: %v", groupID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	reply := fmt.Sprintf("✅ Added %q to %s's knowledge base.", title, bs.groupTitle(groupID))
+	if truncated {
+		reply += fmt.Sprintf(" Only the first %d characters were kept.", maxKnowledgeRunes)
+	}
+	bs.Reply(msg, reply)
+}
+
+// readTextDocument downloads a document sent to the bot, if it's a small text file
+func (bs *BotService) readTextDocument(doc *tgbotapi.Document) (string, error) {
+	ext := strings.ToLower(path.Ext(doc.FileName))
+	isText := strings.HasPrefix(doc.MimeType, "text/")
+	for _, e := range knowledgeExtensions {
+		isText = isText || ext == e
+	}
+	if !isText || doc.FileSize > maxKnowledgeFile {
+		return "", fmt.Errorf("unsupported document %q (%s, %d bytes)", doc.FileName, doc.MimeType, doc.FileSize)
+	}
+
+	data, err := bs.downloadFile(doc.FileID, maxKnowledgeFile)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("document %q is not UTF-8 text", doc.FileName)
+	}
+	return string(data), nil
+}
+
+// downloadFile fetches a file users sent, failing for files bigger than limit bytes
+func (bs *BotService) downloadFile(fileID string, limit int) ([]byte, error) {
+	url, err := bs.api.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading the file returned %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, fmt.Errorf("the file is bigger than %s", formatBytes(limit))
+	}
+	return data, nil
+}
+
+// knowledgeContext is the part of the answer prompt with the group's curated knowledge relevant to a question,
+// or "" when the chat has no knowledge base or nothing in it matches
+func (bs *BotService) knowledgeContext(chat *tgbotapi.Chat, question string) string {
+	if chat.IsPrivate() || !bs.store.HasKnowledge(chat.ID) {
+		return ""
+	}
+	items, err := bs.store.SearchKnowledge(chat.ID, question, knowledgeResults)
+	if err != nil {
+		log.Printf("Error searching the knowledge base of chat %d: %v", chat.ID, err)
+		return ""
+	}
+	if len(items) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, item := range items {
+		fmt.Fprintf(&sb, "\n[%s]\n%s\n", item.Title, bestExcerpt(item.Text, question, knowledgeExcerptRunes))
+	}
+	return knowledgePreface + untrusted("knowledge base", sb.String())
+}
+
+// cachedKnowledge is a group's whole knowledge base, for answers to read from the model's context cache
+// instead of searching it per question; "" when CONTEXT_CACHE_MINUTES is off, or the knowledge base is too
+// small to cache or too big to send whole
+func (bs *BotService) cachedKnowledge(chat *tgbotapi.Chat) string {
+	if bs.config().Model.ContextCacheTTL == 0 || chat.IsPrivate() || !bs.store.HasKnowledge(chat.ID) {
+		return ""
+	}
+	items, err := bs.store.KnowledgeTexts(chat.ID)
+	if err != nil {
+		log.Printf("Error loading the knowledge base of chat %d: %v", chat.ID, err)
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, item := range items {
+		fmt.Fprintf(&sb, "\n[%s]\n%s\n", item.Title, item.Text)
+	}
+	if n := utf8.RuneCountInString(sb.String()); n < llm.MinCachedContextRunes || n > maxCachedKnowledgeRunes {
+		return ""
+	}
+	return knowledgePreface + untrusted("knowledge base", sb.String())
+}
+
+// knowledgeCacheKey is the context cache key of a group's knowledge base
+func knowledgeCacheKey(chatID int64) string {
+	return fmt.Sprintf("knowledge:%d", chatID)
+}
+
+// dropKnowledgeCache deletes the context cache of a group's knowledge base, e.g. when the bot leaves the group
+func (bs *BotService) dropKnowledgeCache(chatID int64) {
+	cacher, ok := bs.gemini.(llm.ContextCacher)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cacher.DropCachedContext(ctx, knowledgeCacheKey(chatID))
+}
+
+// bestExcerpt returns the window of about limit runes of text, cut at paragraph boundaries, that contains
+// the most words of query
+func bestExcerpt(text, query string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsNumber(r) })
+	score := func(s string) int {
+		s = strings.ToLower(s)
+		n := 0
+		for _, w := range words {
+			if utf8.RuneCountInString(w) > 2 {
+				n += strings.Count(s, w)
+			}
+		}
+		return n
+	}
+
+	paragraphs := strings.Split(text, "\n")
+	best, bestScore := "", -1
+	for start := range paragraphs {
+		var window strings.Builder
+		runes := 0
+		for _, p := range paragraphs[start:] {
+			n := utf8.RuneCountInString(p) + 1
+			if runes > 0 && runes+n > limit {
+				break
+			}
+			window.WriteString(p + "\n")
+			runes += n
+		}
+		if s := score(window.String()); s > bestScore {
+			best, bestScore = window.String(), s
+		}
+	}
+	return truncateRunes(strings.TrimSpace(best), limit)
+}
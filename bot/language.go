@@ -0,0 +1,219 @@
+package bot
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// minDetectLetters is the fewest letters a message needs for its language to be guessed
+	minDetectLetters = 12
+	// minLanguageShare is the share of a chat's messages a language needs to count as one of its languages
+	minLanguageShare   = 0.2
+	summaryLangSplit   = "split"
+	summaryLangUsage   = "Usage: /summarylang [auto|split|<language code>]\nauto: summarize mixed chats in their main language\nsplit: one summary per language\ncode, like en or de: always summarize in that language"
+	summaryLangAutoMsg = "Summaries of chats with several languages are written in the main one."
+)
+
+// languageNames are the languages detectLanguage tells apart, by ISO 639-1 code
+var languageNames = map[string]string{
+	"ar": "Arabic", "de": "German", "el": "Greek", "en": "English", "es": "Spanish", "fa": "Persian",
+	"fr": "French", "he": "Hebrew", "hi": "Hindi", "id": "Indonesian", "it": "Italian", "ja": "Japanese",
+	"ko": "Korean", "nl": "Dutch", "pt": "Portuguese", "ru": "Russian", "th": "Thai", "tr": "Turkish",
+	"uk": "Ukrainian", "zh": "Chinese",
+}
+
+// languageMarkers are frequent short words of the Latin-script languages, to tell them apart
+var languageMarkers = map[string][]string{
+	"en": {"the", "and", "is", "are", "you", "that", "this", "with", "for", "have", "what", "not", "was", "it's", "i'm"},
+	"es": {"el", "la", "los", "las", "que", "es", "y", "por", "para", "con", "una", "pero", "está", "qué", "muy"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "du", "mit", "ein", "eine", "auch", "auf", "wir", "sie"},
+	"fr": {"le", "la", "les", "et", "est", "que", "je", "tu", "pas", "une", "des", "pour", "avec", "c'est", "vous"},
+	"it": {"il", "lo", "gli", "che", "è", "non", "per", "una", "sono", "con", "anche", "della", "ma", "questo", "ciao"},
+	"pt": {"o", "os", "que", "não", "é", "uma", "para", "com", "você", "mas", "isso", "está", "muito", "do", "da"},
+	"nl": {"de", "het", "een", "en", "is", "niet", "ik", "je", "van", "dat", "met", "voor", "ook", "maar", "wat"},
+	"tr": {"ve", "bir", "bu", "da", "de", "için", "ne", "çok", "ama", "mi", "ben", "sen", "var", "yok", "gibi"},
+	"id": {"yang", "dan", "di", "ini", "itu", "tidak", "saya", "aku", "kamu", "ada", "untuk", "dengan", "juga", "apa", "sudah"},
+}
+
+// detectLanguage guesses the ISO 639-1 code of text from its script and, for Latin script, its common words;
+// "" when the text is too short or unclear
+func detectLanguage(text string) string {
+	scripts := map[string]int{}
+	letters := 0
+	persian, ukrainian := false, false
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			scripts["latin"]++
+		case unicode.Is(unicode.Arabic, r):
+			scripts["ar"]++
+			persian = persian || strings.ContainsRune("پچژگکی", r)
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts["ru"]++
+			ukrainian = ukrainian || strings.ContainsRune("іїєґ", unicode.ToLower(r))
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			scripts["ja"] += 2 // Japanese mixes kana with kanji
+		case unicode.Is(unicode.Han, r):
+			scripts["zh"]++
+		case unicode.Is(unicode.Hangul, r):
+			scripts["ko"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Devanagari, r):
+			scripts["hi"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		}
+	}
+	// CJK packs more per letter than alphabets
+	if letters < minDetectLetters && scripts["zh"]+scripts["ja"]+scripts["ko"] < 4 {
+		return ""
+	}
+
+	script, best := "", 0
+	for s, n := range scripts {
+		if n > best || (n == best && s < script) {
+			script, best = s, n
+		}
+	}
+	switch {
+	case script == "ar" && persian:
+		return "fa"
+	case script == "ru" && ukrainian:
+		return "uk"
+	case script != "latin":
+		return script
+	}
+
+	scores := map[string]int{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		for lang, words := range languageMarkers {
+			if slices.Contains(words, word) {
+				scores[lang]++
+			}
+		}
+	}
+	lang, top, second := "", 0, 0
+	for l, n := range scores {
+		switch {
+		case n > top || (n == top && l < lang):
+			lang, top, second = l, n, max(top, second)
+		case n > second:
+			second = n
+		}
+	}
+	if top < 2 || top == second {
+		return ""
+	}
+	return lang
+}
+
+// languageShare is how many of a chat's messages are in a language
+type languageShare struct {
+	code  string
+	count int
+}
+
+// chatLanguages returns the languages of at least minLanguageShare of the messages whose language is known,
+// most used first
+func chatLanguages(messages []store.Message) []languageShare {
+	counts := map[string]int{}
+	known := 0
+	for _, m := range messages {
+		lang := cmp.Or(m.Language, detectLanguage(m.Text))
+		if lang == "" {
+			continue
+		}
+		counts[lang]++
+		known++
+	}
+	var shares []languageShare
+	for code, n := range counts {
+		if float64(n) >= minLanguageShare*float64(known) {
+			shares = append(shares, languageShare{code, n})
+		}
+	}
+	slices.SortFunc(shares, func(a, b languageShare) int {
+		return cmp.Or(cmp.Compare(b.count, a.count), cmp.Compare(a.code, b.code))
+	})
+	return shares
+}
+
+// summaryLanguage is the response language instruction of a summary of messages, following /summarylang when
+// the chat is multilingual or has a language set; "" leaves it to the default
+func (bs *BotService) summaryLanguage(chatID int64, messages []store.Message) string {
+	setting := bs.store.ChatSettings(chatID).SummaryLanguage
+	if name, ok := languageNames[setting]; ok {
+		return name + ", translating what was said in other languages"
+	}
+	languages := chatLanguages(messages)
+	if len(languages) < 2 {
+		return ""
+	}
+	if setting == summaryLangSplit {
+		var names []string
+		for _, l := range languages {
+			names = append(names, languageNames[l.code])
+		}
+		return fmt.Sprintf("one short summary per language (%s), each headed by the language name, written in that language and covering the messages written in it",
+			strings.Join(names, ", "))
+	}
+	return languageNames[languages[0].code] + ", the chat's main language, including what was said in the other languages"
+}
+
+// handleSummaryLang sets the language /summary uses in a chat with messages in several languages
+func (bs *BotService) handleSummaryLang(msg *tgbotapi.Message) {
+	arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+	if arg == "" {
+		status := summaryLangAutoMsg
+		switch setting := bs.store.ChatSettings(msg.Chat.ID).SummaryLanguage; {
+		case setting == summaryLangSplit:
+			status = "Summaries of chats with several languages have one summary per language."
+		case languageNames[setting] != "":
+			status = fmt.Sprintf("Summaries are written in %s.", languageNames[setting])
+		}
+		bs.Reply(msg, status+"\n\n"+summaryLangUsage)
+		return
+	}
+
+	value := arg
+	switch {
+	case arg == "auto":
+		value = ""
+	case arg == summaryLangSplit, languageNames[arg] != "":
+	default:
+		bs.Reply(msg, summaryLangUsage+"\n\nKnown codes: "+strings.Join(slices.Sorted(maps.Keys(languageNames)), ", "))
+		return
+	}
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"summary_language": value}); err != nil {
+		log.Printf("Error saving the summary language of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	switch {
+	case value == "":
+		bs.Reply(msg, summaryLangAutoMsg)
+	case value == summaryLangSplit:
+		bs.Reply(msg, "Summaries of chats with several languages now have one summary per language.")
+	default:
+		bs.Reply(msg, fmt.Sprintf("Summaries are now written in %s.", languageNames[value]))
+	}
+}
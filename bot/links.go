@@ -0,0 +1,233 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Link policies of a chat, stored in store.ChatSettings.LinkPolicy
+const (
+	linkPolicyOff       = ""
+	linkPolicyBlock     = "block"
+	linkPolicyAllowlist = "allowlist"
+)
+
+const (
+	// linkMuteAfter is the warning at which a user is muted for linkMuteDuration instead of warned again
+	linkMuteAfter    = 3
+	linkMuteDuration = 24 * time.Hour
+
+	linkPolicyUsageMsg = `Usage:
+/linkpolicy off - allow all links
+/linkpolicy block - delete every link from non-admins
+/linkpolicy allowlist - only allow links to allowed domains
+/linkpolicy allow|disallow <domain> - edit the allowed domains
+/linkpolicy invites on|off - delete Telegram invite links, whatever the policy
+Each removed link is a warning; after two warnings the sender is muted for a day.`
+)
+
+// telegramInvite matches invite links like t.me/+AbC, t.me/joinchat/AbC and tg://join?invite=AbC
+var telegramInvite = regexp.MustCompile(`(?i)(?:(?:t|telegram)\.(?:me|dog)/(?:\+|joinchat/)|tg://join\?)`)
+
+// messageLinks returns the URLs of a message's text and caption, from url and text_link entities
+func messageLinks(msg *tgbotapi.Message) []string {
+	var links []string
+	collect := func(text string, entities []tgbotapi.MessageEntity) {
+		encoded := utf16.Encode([]rune(text))
+		for _, e := range entities {
+			switch {
+			case e.IsTextLink():
+				links = append(links, e.URL)
+			case e.IsURL() && e.Offset >= 0 && e.Offset+e.Length <= len(encoded):
+				// Entity offsets count UTF-16 code units
+				links = append(links, string(utf16.Decode(encoded[e.Offset:e.Offset+e.Length])))
+			}
+		}
+	}
+	collect(msg.Text, msg.Entities)
+	collect(msg.Caption, msg.CaptionEntities)
+	return links
+}
+
+// linkHost returns the lowercased host of a link without "www.", adding the scheme Telegram leaves out
+func linkHost(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// domainAllowed reports whether host is one of the domains or a subdomain of one
+func domainAllowed(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkViolation returns why a link breaks the chat's link policy, or "" if it is allowed
+func linkViolation(link string, settings store.ChatSettings) string {
+	switch {
+	case settings.BlockInvites && telegramInvite.MatchString(link):
+		return "Telegram invite links aren't allowed here"
+	case settings.LinkPolicy == linkPolicyBlock:
+		return "links aren't allowed here"
+	case settings.LinkPolicy == linkPolicyAllowlist && !domainAllowed(linkHost(link), settings.AllowedDomains):
+		return "links to that site aren't allowed here"
+	}
+	return ""
+}
+
+// linkPolicy deletes links that break the chat's link policy and warns the sender, muting repeat offenders;
+// admins' links are always allowed and deleted messages go no further
+func (bs *BotService) linkPolicy(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		msg := update.Message
+		if msg == nil || msg.From == nil || msg.Chat.IsPrivate() {
+			next(update)
+			return
+		}
+		links := messageLinks(msg)
+		if len(links) == 0 {
+			next(update)
+			return
+		}
+
+		settings := bs.store.ChatSettings(msg.Chat.ID)
+		if settings.LinkPolicy == linkPolicyOff && !settings.BlockInvites {
+			next(update)
+			return
+		}
+
+		reason := ""
+		for _, link := range links {
+			if reason = linkViolation(link, settings); reason != "" {
+				break
+			}
+		}
+		if reason == "" || bs.isChatAdmin(msg.Chat.ID, msg.From.ID) {
+			next(update)
+			return
+		}
+
+		if _, err := bs.api.Request(tgbotapi.NewDeleteMessage(msg.Chat.ID, msg.MessageID)); err != nil {
+			log.Printf("failed to delete message %d in chat %d: %v", msg.MessageID, msg.Chat.ID, err)
+			next(update)
+			return
+		}
+		bs.warnForLink(msg, reason)
+	}
+}
+
+// warnForLink tells the sender of a removed link why, escalating to a mute on repeated offenses
+func (bs *BotService) warnForLink(msg *tgbotapi.Message, reason string) {
+	count, err := bs.store.AddWarning(msg.Chat.ID, msg.From.ID, "links")
+	if err != nil {
+		log.Printf("Error saving link warning for user %d in chat %d: %v", msg.From.ID, msg.Chat.ID, err)
+	}
+
+	name := displayName(msg.From)
+	switch {
+	case count >= linkMuteAfter:
+		_, err := bs.api.Request(tgbotapi.RestrictChatMemberConfig{
+			ChatMemberConfig: tgbotapi.ChatMemberConfig{ChatID: msg.Chat.ID, UserID: msg.From.ID},
+			UntilDate:        time.Now().Add(linkMuteDuration).Unix(),
+			Permissions:      &tgbotapi.ChatPermissions{},
+		})
+		if err != nil {
+			log.Printf("failed to mute user %d in chat %d: %v", msg.From.ID, msg.Chat.ID, err)
+			bs.sendResponse(tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("🚫 Removed a link from %s: %s. Admins, this is their warning #%d.", name, reason, count)))
+			return
+		}
+		bs.audit(msg.Chat.ID, nil, "mute", fmt.Sprintf("%s for 24 hours after %d link warnings", name, count))
+		bs.sendResponse(tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("🔇 Removed a link from %s: %s. After repeated warnings they are muted for 24 hours.", name, reason)))
+	case count == linkMuteAfter-1:
+		bs.sendResponse(tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("⚠️ Removed a link from %s: %s. Last warning, the next one gets you muted for 24 hours.", name, reason)))
+	default:
+		bs.sendResponse(tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("⚠️ Removed a link from %s: %s.", name, reason)))
+	}
+}
+
+func (bs *BotService) handleLinkPolicy(msg *tgbotapi.Message) {
+	sub, rest, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+	rest = strings.TrimSpace(rest)
+
+	var reply string
+	var err error
+	switch strings.ToLower(sub) {
+	case "":
+		bs.Reply(msg, linkPolicyStatus(bs.store.ChatSettings(msg.Chat.ID))+"\n\n"+linkPolicyUsageMsg)
+		return
+	case "off":
+		reply, err = "Links are allowed.", bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"link_policy": linkPolicyOff})
+	case linkPolicyBlock:
+		reply, err = "I'll delete links from non-admins.", bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"link_policy": linkPolicyBlock})
+	case linkPolicyAllowlist:
+		reply, err = "I'll only allow links to the allowed domains. Add some with /linkpolicy allow <domain>.", bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"link_policy": linkPolicyAllowlist})
+	case "allow", "disallow":
+		domain := linkHost(rest)
+		if rest == "" || domain == "" {
+			bs.Reply(msg, linkPolicyUsageMsg)
+			return
+		}
+		if strings.EqualFold(sub, "allow") {
+			reply, err = "Allowed links to "+domain+" ✅", bs.store.AddToChatList(msg.Chat.ID, "allowed_domains", domain)
+		} else {
+			reply, err = "Removed "+domain+" from the allowed domains.", bs.store.RemoveFromChatList(msg.Chat.ID, "allowed_domains", domain)
+		}
+	case "invites":
+		switch strings.ToLower(rest) {
+		case "on":
+			reply, err = "I'll delete Telegram invite links from non-admins.", bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"block_invites": true})
+		case "off":
+			reply, err = "Telegram invite links are allowed again.", bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"block_invites": false})
+		default:
+			bs.Reply(msg, linkPolicyUsageMsg)
+			return
+		}
+	default:
+		bs.Reply(msg, linkPolicyUsageMsg)
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error saving link policy for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, reply)
+}
+
+func linkPolicyStatus(settings store.ChatSettings) string {
+	var status string
+	switch settings.LinkPolicy {
+	case linkPolicyBlock:
+		status = "Link policy: links from non-admins are deleted."
+	case linkPolicyAllowlist:
+		status = "Link policy: only links to allowed domains: " + strings.Join(settings.AllowedDomains, ", ")
+		if len(settings.AllowedDomains) == 0 {
+			status += "none yet"
+		}
+	default:
+		status = "Link policy: off."
+	}
+	if settings.BlockInvites {
+		status += "\nTelegram invite links are deleted."
+	}
+	return status
+}
@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultMaintenanceMsg = "🛠 I'm under maintenance right now, so my AI features are paused. I'm still keeping up with the chat and will be back soon!"
+	maintenanceUsageMsg   = `Usage:
+/maintenance on [notice] - pause AI features, optionally with a custom notice
+/maintenance off - resume`
+)
+
+// errMaintenance is returned by generate while maintenance mode is on
+var errMaintenance = errors.New("maintenance mode is on")
+
+// maintenanceState is persisted so planned downtime survives restarts
+type maintenanceState struct {
+	mu      sync.RWMutex
+	Enabled bool   `bson:"enabled"`
+	Notice  string `bson:"notice,omitempty"`
+}
+
+func (m *maintenanceState) get() (bool, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.Enabled, m.Notice
+}
+
+// generate sends a prompt to the model unless maintenance mode is on; every AI feature goes through it
+func (bs *BotService) generate(ctx context.Context, prompt string) (string, error) {
+	if enabled, _ := bs.maintenance.get(); enabled {
+		return "", errMaintenance
+	}
+
+	// Content from chat members is delimited in the prompt; the rules about it go in the system role
+	_, cachedContext := llm.CachedContextFromContext(ctx)
+	if instruction := llm.SystemInstructionFromContext(ctx); hasUntrusted(prompt) || hasUntrusted(instruction) || hasUntrusted(cachedContext) {
+		ctx = llm.WithSystemInstruction(ctx, strings.TrimSpace(instruction+"\n\n"+injectionGuard))
+	}
+
+	// Identical prompts within RESPONSE_CACHE_MINUTES are answered from Redis, free of charge; not when tools
+	// are offered, as their results change
+	cacheable := len(llm.ToolsFromContext(ctx)) == 0 && !bs.config().StrictPrivacy
+	if cacheable {
+		if text, ok := bs.cachedResponse(ctx, prompt); ok {
+			bs.logAIResponse(ctx, prompt, text, nil)
+			return bs.familyFilter(ctx, text, nil)
+		}
+	}
+
+	// The tokens and latency of every call are recorded; calls made with billedContext are also charged to
+	// the payer's credits by their token usage
+	p, billed := ctx.Value(payerKey{}).(payer)
+	ctx, usage := llm.TrackUsage(ctx)
+
+	bs.metrics.aiRequests.Add(1)
+	started := time.Now()
+	text, err := bs.gemini.GenerateText(ctx, prompt)
+	bs.recordUsage(ctx, usage, time.Since(started), err)
+	if o, ok := ctx.Value(originKey{}).(origin); ok && err != nil && bs.isCancelled(o.chatID, o.messageID) {
+		return "", errCancelled
+	}
+	if err != nil {
+		bs.metrics.aiFailures.Add(1)
+	}
+	if billed && err == nil {
+		bs.chargeCredits(p, usage)
+	}
+	if err == nil && cacheable {
+		bs.cacheResponse(ctx, prompt, text)
+	}
+	bs.logAIResponse(ctx, prompt, text, err)
+	return bs.familyFilter(ctx, text, err)
+}
+
+// maintenanceNotice returns the notice users get instead of AI replies
+func (bs *BotService) maintenanceNotice() string {
+	if _, notice := bs.maintenance.get(); notice != "" {
+		return notice
+	}
+	if bs.config().MaintenanceMessage != "" {
+		return bs.config().MaintenanceMessage
+	}
+	return defaultMaintenanceMsg
+}
+
+// maintenanceMode answers questions and AI commands with the maintenance notice while it is on;
+// it runs after storage, so messages are still kept
+func (bs *BotService) maintenanceMode(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		msg := update.Message
+		if enabled, _ := bs.maintenance.get(); !enabled || msg == nil || !bs.needsAI(msg) {
+			next(update)
+			return
+		}
+		bs.Reply(msg, bs.maintenanceNotice())
+	}
+}
+
+func (bs *BotService) handleMaintenance(msg *tgbotapi.Message) {
+	sub, notice, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+
+	var enabled bool
+	switch strings.ToLower(sub) {
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	default:
+		if on, _ := bs.maintenance.get(); on {
+			bs.Reply(msg, "Maintenance mode is on.\nNotice: "+bs.maintenanceNotice()+"\n\n"+maintenanceUsageMsg)
+		} else {
+			bs.Reply(msg, "Maintenance mode is off.\n\n"+maintenanceUsageMsg)
+		}
+		return
+	}
+
+	bs.maintenance.mu.Lock()
+	bs.maintenance.Enabled = enabled
+	bs.maintenance.Notice = strings.TrimSpace(notice)
+	bs.maintenance.mu.Unlock()
+
+	if err := bs.saveMaintenanceState(); err != nil {
+		log.Printf("Error saving maintenance state: %v", err)
+		bs.Reply(msg, "Maintenance mode changed, but I couldn't save it, so it resets on restart.")
+		return
+	}
+
+	if enabled {
+		bs.Reply(msg, "🛠 Maintenance mode on. Users get this notice instead of AI replies:\n"+bs.maintenanceNotice()+"\n\nUse /broadcast to announce the downtime.")
+	} else {
+		bs.Reply(msg, "✅ Maintenance mode off, AI features are back.")
+	}
+}
+
+func (bs *BotService) saveMaintenanceState() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	enabled, notice := bs.maintenance.get()
+	_, err := bs.db.Collection("bot_state").UpdateOne(ctx,
+		bson.M{"_id": "maintenance"},
+		bson.M{"$set": bson.M{"enabled": enabled, "notice": notice, "updated_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// loadMaintenanceState restores maintenance mode after a restart
+func (bs *BotService) loadMaintenanceState() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bs.maintenance.mu.Lock()
+	defer bs.maintenance.mu.Unlock()
+
+	err := bs.db.Collection("bot_state").FindOne(ctx, bson.M{"_id": "maintenance"}).Decode(&bs.maintenance)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		log.Printf("Error loading maintenance state: %v", err)
+	}
+	if bs.maintenance.Enabled {
+		log.Printf("Starting in maintenance mode")
+	}
+}
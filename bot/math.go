@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/latex"
+)
+
+// maxFormulaImages caps the formulas of one answer rendered as images; Telegram albums take up to 10
+const maxFormulaImages = 4
+
+var (
+	// displayMathRe matches display formulas, $$...$$ or \[...\]
+	displayMathRe = regexp.MustCompile(`(?s)\$\$(.+?)\$\$|\\\[(.+?)\\\]`)
+	// inlineMathRe matches inline formulas, \(...\) or $...$ with a command, sub- or superscript in it,
+	// so prices like "$5 or $10" don't count
+	inlineMathRe = regexp.MustCompile(`\\\((.+?)\\\)|\$([^$\n]*[\\^_][^$\n]*)\$`)
+	// mathQuestionRe matches questions that likely need formulas
+	mathQuestionRe = regexp.MustCompile(`(?i)\b(integral|integrate|derivative|differentiate|equation|solve for|formula|matrix|determinant|eigen\w*|theorem|proof|prove|limit of|probability|quadratic|polynomial|logarithm|trigonometr\w*|calculus|algebra)\b|\\(frac|int|sum|sqrt|lim)\b|\b[a-z]\s*\^\s*\d`)
+)
+
+func looksLikeMath(question string) bool {
+	return mathQuestionRe.MatchString(question) || displayMathRe.MatchString(question) || inlineMathRe.MatchString(question)
+}
+
+func (bs *BotService) buildMathPrompt(chat *tgbotapi.Chat, question string) string {
+	chatContext := bs.chatContext(chat)
+	if chatContext != "" {
+		chatContext = "\n" + chatContext
+	}
+	return fmt.Sprintf(`You are a helpful math tutor in a Telegram chat.%s The user asked:
+%s
+
+Follow these response guidelines:
+1. Write each important formula in LaTeX on its own line between $$ and $$, at most %d of them; they are shown as images numbered (1), (2)... in order
+2. Refer to them by those numbers in the text, and write small inline math in plain text like x^2 + 1
+3. Keep the explanation short, in plain text with no markdown
+4. Only use standard amsmath commands; don't define macros
+Response language: %s`, chatContext, untrusted("question", question), maxFormulaImages, bs.responseLanguage(chat))
+}
+
+// answerMath answers a math question, with its formulas rendered as images when LaTeX is available
+func (bs *BotService) answerMath(msg *tgbotapi.Message, question string) {
+	ctx, cancel := context.WithTimeout(bs.billedContext(msg), 90*time.Second)
+	defer cancel()
+
+	answer, err := bs.generate(bs.premiumContext(ctx, msg.Chat), bs.buildMathPrompt(msg.Chat, question))
+	if err != nil {
+		log.Printf("gemini math answer error: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.replyWithMath(msg, answer)
+}
+
+// renderFormulas renders up to maxFormulaImages display formulas of text and returns the images, with the text
+// they were cut from: rendered formulas become their number, like "(1)", and inline math loses its delimiters.
+// Formulas that fail to render stay as LaTeX source.
+func renderFormulas(ctx context.Context, text string) ([][]byte, string) {
+	var images [][]byte
+	text = displayMathRe.ReplaceAllStringFunc(text, func(match string) string {
+		parts := displayMathRe.FindStringSubmatch(match)
+		formula := strings.TrimSpace(parts[1] + parts[2])
+		if len(images) == maxFormulaImages {
+			return formula
+		}
+		png, err := latex.Render(ctx, formula, latex.DefaultDPI)
+		if err != nil {
+			log.Printf("Error rendering a formula: %v", err)
+			return formula
+		}
+		images = append(images, png)
+		return fmt.Sprintf("(%d)", len(images))
+	})
+	text = inlineMathRe.ReplaceAllString(text, "$1$2")
+	return images, text
+}
+
+// replyWithMath sends an answer whose display formulas are rendered as numbered images, followed by the text.
+// Without MATH_RENDERING or a TeX installation it's sent as is.
+func (bs *BotService) replyWithMath(msg *tgbotapi.Message, answer string) {
+	if !bs.config().MathRendering || !latex.Available() {
+		bs.Reply(msg, answer)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	images, text := renderFormulas(ctx, answer)
+
+	if err := bs.sendFormulas(msg, images); err != nil {
+		log.Printf("failed to send formula images: %v", err)
+		bs.Reply(msg, answer)
+		return
+	}
+	bs.Reply(msg, text)
+}
+
+// sendFormulas sends formula images numbered like the answer refers to them, as an album when there are several
+func (bs *BotService) sendFormulas(msg *tgbotapi.Message, images [][]byte) error {
+	switch len(images) {
+	case 0:
+		return nil
+	case 1:
+		return bs.sendPNG(msg.Chat.ID, msg, kindAnswer, "formula.png", images[0], "(1)")
+	}
+
+	media := make([]any, len(images))
+	for i, png := range images {
+		photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileBytes{Name: fmt.Sprintf("formula%d.png", i+1), Bytes: png})
+		photo.Caption = fmt.Sprintf("(%d)", i+1)
+		media[i] = photo
+	}
+	album := tgbotapi.NewMediaGroup(msg.Chat.ID, media)
+	album.ReplyToMessageID = msg.MessageID
+	album.DisableNotification, _ = bs.sendOptions(msg.Chat.ID, kindAnswer)
+	_, err := bs.api.Request(album)
+	return err
+}
@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxRecentErrors = 50
+
+// loggedError is a log line that looked like an error
+type loggedError struct {
+	Time time.Time
+	Text string
+}
+
+// errorLog is an io.Writer for the standard logger that keeps the most recent error lines in memory
+type errorLog struct {
+	mu      sync.Mutex
+	entries []loggedError
+	// notify is called with every captured line, in its own goroutine
+	notify func(line string)
+}
+
+func (e *errorLog) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimSpace(p), []byte("\n")) {
+		text := string(line)
+		lower := strings.ToLower(text)
+		if !strings.Contains(lower, "error") && !strings.Contains(lower, "failed") && !strings.Contains(lower, "panic") {
+			continue
+		}
+
+		e.mu.Lock()
+		e.entries = append(e.entries, loggedError{Time: time.Now(), Text: text})
+		if len(e.entries) > maxRecentErrors {
+			e.entries = e.entries[len(e.entries)-maxRecentErrors:]
+		}
+		notify := e.notify
+		e.mu.Unlock()
+
+		if notify != nil {
+			go notify(text)
+		}
+	}
+	return len(p), nil
+}
+
+// setNotify registers the function that gets every captured line
+func (e *errorLog) setNotify(notify func(line string)) {
+	e.mu.Lock()
+	e.notify = notify
+	e.mu.Unlock()
+}
+
+// recent returns the captured errors, newest first
+func (e *errorLog) recent() []loggedError {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	out := make([]loggedError, len(e.entries))
+	for i, entry := range e.entries {
+		out[len(e.entries)-1-i] = entry
+	}
+	return out
+}
+
+// recentErrors collects error log lines for the admin dashboard
+var recentErrors = &errorLog{}
+
+// LogWriter returns a writer that captures error lines for the admin dashboard;
+// tee the standard logger into it with log.SetOutput(io.MultiWriter(os.Stderr, bot.LogWriter()))
+func LogWriter() io.Writer {
+	return recentErrors
+}
@@ -0,0 +1,229 @@
+package bot
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/eventbus"
+)
+
+const rateLimitedMsg = "You're sending me requests too quickly, give me a minute."
+
+// UpdateHandler processes one update
+type UpdateHandler func(update tgbotapi.Update)
+
+// Middleware wraps an UpdateHandler; call next to pass the update on, or return to stop it
+type Middleware func(next UpdateHandler) UpdateHandler
+
+// buildPipeline chains the built-in middlewares and the ones added with WithMiddleware in front of the router.
+// Storage and message hooks run before rate limiting, so every message is kept even when the bot won't reply.
+func (bs *BotService) buildPipeline() UpdateHandler {
+	middlewares := []Middleware{
+		bs.recoverPanics,
+		bs.logUpdates,
+		bs.accessControl,
+		bs.dedupeUpdates,
+		bs.storeMessages,
+		bs.ignoreBots,
+		bs.bannedWords,
+		bs.linkPolicy,
+		bs.spamFilter,
+		bs.messageHooks,
+	}
+	middlewares = append(middlewares, bs.middlewares...)
+	middlewares = append(middlewares, bs.maintenanceMode, bs.rateLimit, bs.chatCooldown, bs.creditGate)
+
+	handler := bs.routeUpdate
+	for _, m := range slices.Backward(middlewares) {
+		handler = m(handler)
+	}
+	return handler
+}
+
+// logUpdates logs every update with how long it took to handle
+func (bs *BotService) logUpdates(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		start := time.Now()
+		next(update)
+
+		chatID := int64(0)
+		if chat := updateChat(update); chat != nil {
+			chatID = chat.ID
+		}
+		log.Printf("handled update %d (%s) in chat %d in %s", update.UpdateID, updateKind(update), chatID, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// accessControl drops updates from chats outside cfg.AllowedChats, if the list is set; with
+// LEAVE_UNAUTHORIZED_CHATS the bot being added to one gets through, so it can leave
+func (bs *BotService) accessControl(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		// Pre-checkout queries have no chat; they are checked against the current offer instead
+		if len(bs.config().AllowedChats) > 0 && update.PreCheckoutQuery == nil {
+			chat := updateChat(update)
+			allowed := chat != nil && slices.Contains(bs.config().AllowedChats, chat.ID)
+			if !allowed && !(update.MyChatMember != nil && bs.config().LeaveUnauthorizedChats) {
+				return
+			}
+		}
+		next(update)
+	}
+}
+
+// storeMessages saves every chat message to MongoDB and publishes it to the event bus, in chats with history features on
+func (bs *BotService) storeMessages(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		if update.Message != nil && bs.historyEnabled(update.Message.Chat.ID) {
+			bs.storeMessage(update.Message)
+			bs.publish(messageEvent(eventbus.MessageReceived, update.Message))
+		}
+		next(update)
+	}
+}
+
+// messageHooks runs the passive per-message features and handlers registered with WithMessageHandler
+func (bs *BotService) messageHooks(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		if msg := update.Message; msg != nil {
+			bs.refreshChatInfo(msg)
+			bs.recordChatActivity(msg)
+			if msg.PinnedMessage != nil {
+				bs.recordPin(msg.Chat, msg.PinnedMessage, msg.From)
+			}
+
+			// Check the message against the chat rules when moderation mode is on
+			bs.goSafe("moderation", func() { bs.moderateMessage(msg) })
+			bs.goSafe("scam check", func() { bs.checkScam(msg) })
+
+			// Offer to save plans like "dinner Friday 8pm" to the chat calendar
+			bs.goSafe("event detection", func() { bs.detectEvent(msg) })
+
+			for _, handler := range bs.messageHandlers {
+				handler(bs, msg)
+			}
+		}
+		next(update)
+	}
+}
+
+// rateLimit caps how many commands and questions each user can send the bot per minute
+func (bs *BotService) rateLimit(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		msg := update.Message
+		if msg == nil || msg.From == nil || bs.config().RateLimitPerMinute <= 0 || !bs.isAddressedToBot(msg) {
+			next(update)
+			return
+		}
+
+		limit := bs.premiumLimit(bs.config().RateLimitPerMinute, bs.isPremium(premiumScopeUser, msg.From.ID))
+		allowed, warn := bs.allowRequest(&bs.limiter, "user", msg.From.ID, limit, time.Now())
+		if !allowed {
+			if warn {
+				bs.Reply(msg, rateLimitedMsg)
+			}
+			return
+		}
+		next(update)
+	}
+}
+
+// routeUpdate dispatches an update to the handler for its kind
+func (bs *BotService) routeUpdate(update tgbotapi.Update) {
+	switch {
+	case update.CallbackQuery != nil:
+		bs.handleCallback(update.CallbackQuery)
+	case update.PreCheckoutQuery != nil:
+		bs.handlePreCheckout(update.PreCheckoutQuery)
+	case update.ChannelPost != nil:
+		bs.handleChannelPost(update.ChannelPost)
+	case update.MyChatMember != nil:
+		bs.handleMyChatMember(update.MyChatMember)
+	case update.Message == nil:
+		return
+	case update.Message.SuccessfulPayment != nil:
+		bs.handleSuccessfulPayment(update.Message)
+	case update.Message.IsCommand():
+		bs.handleCommand(update.Message)
+	case update.Message.Chat.IsPrivate() && bs.knowledgeGroup(update.Message.From) != 0:
+		bs.handleKnowledgeInput(update.Message)
+	case update.Message.Location != nil && (update.Message.Chat.IsPrivate() || bs.isAddressedToBot(update.Message)):
+		bs.handleLocation(update.Message)
+	case bs.isAddressedToBot(update.Message):
+		// Answered in the background so /cancel and other updates get through while the model works
+		msg := update.Message
+		bs.goSafe("query", func() {
+			done := bs.startRequest(msg)
+			defer done()
+			bs.handleQuery(msg)
+		})
+	}
+}
+
+// isAddressedToBot reports whether a message is a command, mentions the bot or calls it by an alias, or replies to it
+func (bs *BotService) isAddressedToBot(msg *tgbotapi.Message) bool {
+	if msg.IsCommand() || bs.isBotMentioned(msg.Text) || bs.isAliasAddressed(msg) {
+		return true
+	}
+	return bs.isReplyToBot(msg)
+}
+
+// updateChat returns the chat of an update, including the bot's own membership changes, or nil if it has none
+func updateChat(update tgbotapi.Update) *tgbotapi.Chat {
+	if update.MyChatMember != nil {
+		return &update.MyChatMember.Chat
+	}
+	return update.FromChat()
+}
+
+func updateKind(update tgbotapi.Update) string {
+	switch {
+	case update.Message != nil && update.Message.IsCommand():
+		return "command /" + update.Message.Command()
+	case update.Message != nil:
+		return "message"
+	case update.CallbackQuery != nil:
+		return "callback"
+	case update.ChannelPost != nil:
+		return "channel post"
+	case update.EditedMessage != nil:
+		return "edited message"
+	case update.MyChatMember != nil:
+		return "membership change"
+	default:
+		return "other"
+	}
+}
+
+// rateLimiter tracks request times per user or chat over a sliding one-minute window
+type rateLimiter struct {
+	mu     sync.Mutex
+	hits   map[int64][]time.Time
+	warned map[int64]bool
+}
+
+// allow records a request and reports whether it is within limit, and whether the sender should be told they hit it
+func (rl *rateLimiter) allow(key int64, limit int, now time.Time) (allowed, warn bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.hits == nil {
+		rl.hits = make(map[int64][]time.Time)
+		rl.warned = make(map[int64]bool)
+	}
+
+	windowStart := now.Add(-time.Minute)
+	hits := slices.DeleteFunc(rl.hits[key], func(t time.Time) bool { return t.Before(windowStart) })
+	if len(hits) >= limit {
+		rl.hits[key] = hits
+		warn = !rl.warned[key]
+		rl.warned[key] = true
+		return false, warn
+	}
+
+	rl.hits[key] = append(hits, now)
+	rl.warned[key] = false
+	return true, false
+}
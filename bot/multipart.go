@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const maxQuestionParts = 5
+
+var (
+	// questionMarkRe and numberedLineRe are the hints that a message may ask several things; only those
+	// messages cost a model call to split
+	questionMarkRe = regexp.MustCompile(`[?？؟]`)
+	numberedLineRe = regexp.MustCompile(`(?m)^\s*(?:\d+[.)]|[-•*])\s+\S`)
+	// partFollowUpRe matches follow-ups to a multi-part answer like "more on 2" or "expand on #3"
+	partFollowUpRe = regexp.MustCompile(`(?i)^\s*(?:(?:tell me |say |go into )?more (?:on|about)|expand on|elaborate on|details (?:on|for))\s*(?:#|part |point |question |number |no\.?\s*)?(\d+)\b`)
+)
+
+// questionPartsSchema is the answer of the question splitting
+var questionPartsSchema = &llm.Schema{
+	Type: "object",
+	Properties: map[string]*llm.Schema{
+		"questions": {Type: "array", Items: &llm.Schema{Type: "string"}, Description: "the distinct questions, each rewritten to stand on its own"},
+	},
+	Required: []string{"questions"},
+}
+
+// splitQuestions returns the distinct questions a message asks, when it asks more than one; nil otherwise.
+// Questions that are parts of one topic, like "what is X and why does it matter?", stay together.
+func (bs *BotService) splitQuestions(msg *tgbotapi.Message, question string) []string {
+	if len(questionMarkRe.FindAllString(question, -1)) < 2 && len(numberedLineRe.FindAllString(question, -1)) < 2 {
+		return nil
+	}
+
+	prompt := fmt.Sprintf(`Split the message below into the distinct questions or requests it contains.
+
+%s
+
+Instructions:
+1. Only split questions about different things that each need their own answer
+2. Keep follow-up parts of one question together, like "what is X and how do I install it?"
+3. Rewrite each question so it can be answered on its own, in the message's language, keeping the details it needs
+4. A single question if the message asks one thing, at most %d`, untrusted("message", question), maxQuestionParts)
+
+	ctx, cancel := context.WithTimeout(bs.messageContext(msg), 15*time.Second)
+	defer cancel()
+	var answer struct {
+		Questions []string `json:"questions"`
+	}
+	err := bs.generateJSON(ctx, prompt, questionPartsSchema, &answer, func() error {
+		if len(answer.Questions) == 0 {
+			return errors.New("no questions")
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error splitting a question in chat %d: %v", msg.Chat.ID, err)
+		return nil
+	}
+	var parts []string
+	for _, q := range answer.Questions {
+		if q = strings.TrimSpace(q); q != "" {
+			parts = append(parts, q)
+		}
+	}
+	if len(parts) < 2 {
+		return nil
+	}
+	return parts[:min(len(parts), maxQuestionParts)]
+}
+
+// answerParts answers each question of a multi-part message, concurrently, as numbered sections of one reply,
+// or as a reply per question when together they are too long for one message. The parts are stored so
+// follow-ups like "more on 2" know what they refer to.
+func (bs *BotService) answerParts(msg *tgbotapi.Message, questions []string) {
+	parts := make([]store.AnswerPart, len(questions))
+	var wg sync.WaitGroup
+	for i, q := range questions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			parts[i] = store.AnswerPart{Question: q, Answer: bs.generateResponse(msg, q)}
+		}()
+	}
+	wg.Wait()
+
+	sections := make([]string, len(parts))
+	for i, p := range parts {
+		sections[i] = fmt.Sprintf("%d. %s\n%s", i+1, p.Question, p.Answer)
+	}
+	combined := strings.Join(sections, "\n\n")
+	if len([]rune(combined)) <= maxMessageLength {
+		sections = []string{combined}
+	}
+
+	var sentIDs []int
+	for _, text := range sections {
+		reply := tgbotapi.NewMessage(msg.Chat.ID, text)
+		reply.ReplyToMessageID = msg.MessageID
+		if sent := bs.sendAs(kindAnswer, reply); sent.MessageID != 0 {
+			sentIDs = append(sentIDs, sent.MessageID)
+		}
+	}
+	if len(sentIDs) == 0 {
+		return
+	}
+	if err := bs.store.SaveAnswerParts(msg.Chat.ID, sentIDs, parts); err != nil {
+		log.Printf("Error saving the answer parts of message %d in chat %d: %v", msg.MessageID, msg.Chat.ID, err)
+	}
+}
+
+// followUpPart returns the question for a follow-up like "more on 2" replying to a multi-part answer: the
+// follow-up pointed at the question of that part. ok is false for other messages.
+func (bs *BotService) followUpPart(msg *tgbotapi.Message, question string) (string, bool) {
+	if !bs.isReplyToBot(msg) {
+		return "", false
+	}
+	m := partFollowUpRe.FindStringSubmatch(question)
+	if m == nil {
+		return "", false
+	}
+	n, _ := strconv.Atoi(m[1])
+
+	answer, err := bs.store.AnswerPartsOf(msg.Chat.ID, msg.ReplyToMessage.MessageID)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("Error loading the answer parts of message %d in chat %d: %v", msg.ReplyToMessage.MessageID, msg.Chat.ID, err)
+		}
+		return "", false
+	}
+	if n < 1 || n > len(answer.Parts) {
+		return "", false
+	}
+	part := answer.Parts[n-1]
+	return fmt.Sprintf("%s\n\nThis is about part %d of your answer, the question %q, which you answered with:\n%s\n\nGo into more depth on it than that answer did.",
+		question, n, part.Question, part.Answer), true
+}
@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+const (
+	maxNicknameRunes = 40
+	nicknameUsageMsg = `Usage:
+/alias names - list the nicknames of this chat's members
+/alias @username = <nickname> - set what the chat calls someone, like /alias @sg_milad = Mili
+/alias forget @username - remove someone's nickname
+/glossary build also learns nicknames from the chat history.`
+)
+
+// handleNicknameCommand handles the nickname forms of /alias: names, @username = <nickname> and forget @username
+func (bs *BotService) handleNicknameCommand(msg *tgbotapi.Message, args string) {
+	if msg.Chat.IsPrivate() {
+		bs.Reply(msg, "Nicknames are set per group, use /alias in the group.")
+		return
+	}
+
+	if strings.EqualFold(args, "names") {
+		bs.listNicknames(msg)
+		return
+	}
+	if rest, ok := cutPrefixFold(args, "forget "); ok {
+		username := strings.TrimPrefix(strings.TrimSpace(rest), "@")
+		if username == "" {
+			bs.Reply(msg, nicknameUsageMsg)
+			return
+		}
+		removed, err := bs.store.RemoveNickname(msg.Chat.ID, username)
+		if err != nil {
+			log.Printf("Error removing the nickname of @%s in chat %d: %v", username, msg.Chat.ID, err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		if !removed {
+			bs.Reply(msg, fmt.Sprintf("@%s has no nickname.", username))
+			return
+		}
+		bs.Reply(msg, fmt.Sprintf("Forgot the nickname of @%s.", username))
+		return
+	}
+
+	username, nickname, ok := strings.Cut(args, "=")
+	username = strings.TrimPrefix(strings.TrimSpace(username), "@")
+	nickname = strings.TrimSpace(nickname)
+	if !ok || username == "" || strings.ContainsAny(username, " \n") || nickname == "" {
+		bs.Reply(msg, nicknameUsageMsg)
+		return
+	}
+	n := store.Nickname{ChatID: msg.Chat.ID, Username: username, Nickname: truncateRunes(nickname, maxNicknameRunes), AddedBy: msg.From.ID}
+	if err := bs.store.SetNickname(n); err != nil {
+		log.Printf("Error saving the nickname of @%s in chat %d: %v", username, msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("👤 I'll call @%s %s.", username, n.Nickname))
+}
+
+// cutPrefixFold is strings.CutPrefix ignoring case
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+func (bs *BotService) listNicknames(msg *tgbotapi.Message) {
+	nicknames, err := bs.store.Nicknames(msg.Chat.ID)
+	if err != nil {
+		log.Printf("Error loading the nicknames of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(nicknames) == 0 {
+		bs.Reply(msg, "👤 No nicknames yet.\n\n"+nicknameUsageMsg)
+		return
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "👤 %d nicknames of this chat:\n", len(nicknames))
+	for _, n := range nicknames {
+		fmt.Fprintf(&sb, "\n@%s: %s", n.Username, n.Nickname)
+		if n.AddedBy == 0 {
+			sb.WriteString(" (learned)")
+		}
+	}
+	bs.Reply(msg, sb.String())
+}
+
+// chatNicknames returns the nicknames of a chat's members by lowercase username, nil when it has none
+func (bs *BotService) chatNicknames(chatID int64) map[string]string {
+	if chatID > 0 {
+		return nil
+	}
+	nicknames, err := bs.store.Nicknames(chatID)
+	if err != nil {
+		log.Printf("Error loading the nicknames of chat %d: %v", chatID, err)
+		return nil
+	}
+	if len(nicknames) == 0 {
+		return nil
+	}
+	names := make(map[string]string, len(nicknames))
+	for _, n := range nicknames {
+		names[n.Username] = n.Nickname
+	}
+	return names
+}
+
+// speaker names the sender of a message for prompts: their nickname followed by the username, so the model
+// can use the name the chat knows and still tell who it is, or store.Message.Author without one
+func speaker(m store.Message, nicknames map[string]string) string {
+	if nickname := nicknames[strings.ToLower(m.FromUsername)]; nickname != "" {
+		return nickname + " (@" + m.FromUsername + ")"
+	}
+	return m.Author()
+}
+
+// nicknamesContext is the part of the answer prompt with the nicknames of the chat's members, so questions
+// like "what did Mili say?" find their messages; "" when there are none
+func (bs *BotService) nicknamesContext(chat *tgbotapi.Chat) string {
+	if chat.IsPrivate() {
+		return ""
+	}
+	nicknames, err := bs.store.Nicknames(chat.ID)
+	if err != nil {
+		log.Printf("Error loading the nicknames of chat %d: %v", chat.ID, err)
+		return ""
+	}
+	if len(nicknames) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for _, n := range nicknames {
+		fmt.Fprintf(&sb, "- %s: @%s\n", n.Nickname, n.Username)
+	}
+	return "Members of this chat go by these nicknames, call them by their nickname:\n" + untrusted("member nicknames", sb.String())
+}
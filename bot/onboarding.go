@@ -0,0 +1,213 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// groupStartPrefix is the /start payload of links that bring users from a group, like t.me/bot?start=from_group_-100123
+	groupStartPrefix = "from_group_"
+
+	onboardingStepLanguage  = "language"
+	onboardingStepPrivacy   = "privacy"
+	onboardingStepSubscribe = "subscribe"
+
+	onboardingLanguageMsg = "👋 Welcome%s! Let's get you set up in a few taps.\n\nWhich language should I answer you in?"
+	onboardingPrivacyMsg  = `🔒 Before we start, a quick privacy note:
+- In groups I store messages so I can summarize chats and answer questions about them
+- Your DM conversations with me are sent to an AI model to generate answers
+- Group admins can turn features like moderation and event detection on or off
+- You can ask the bot owner to delete your data at any time`
+	onboardingSubscribeMsg = "📰 Would you like a copy of %s's daily feed digest here in your DMs?"
+	onboardingDoneMsg      = "✅ All set! Ask me anything here, or mention me with %s in your groups. Use /help to see what I can do."
+	onboardingGoneCallback = "This step is over, send /start to begin again."
+	digestsUsageMsg        = "Usage: /digests [off] - list the groups whose digests you get here, or stop them all"
+)
+
+// onboardingLanguages are the languages offered in the wizard, in button order; "" matches the user's messages
+var onboardingLanguages = []struct{ code, name string }{
+	{"en", "English"}, {"es", "Español"}, {"de", "Deutsch"}, {"fr", "Français"},
+	{"fa", "فارسی"}, {"ru", "Русский"}, {"", "Match my messages"},
+}
+
+func languageName(code string) string {
+	for _, lang := range onboardingLanguages {
+		if lang.code == code {
+			return lang.name
+		}
+	}
+	return ""
+}
+
+// onboardingLink is the deep link that starts the DM onboarding for members of a group
+func (bs *BotService) onboardingLink(chatID int64) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", strings.TrimPrefix(bs.botMention, "@"), groupStartPrefix, chatID)
+}
+
+// startOnboarding begins the DM wizard for a user who followed a group's deep link
+func (bs *BotService) startOnboarding(msg *tgbotapi.Message, payload string) {
+	groupID, err := strconv.ParseInt(strings.TrimPrefix(payload, groupStartPrefix), 10, 64)
+	if err != nil || groupID >= 0 {
+		groupID = 0
+	}
+
+	if err := bs.store.UpdateUserPreferences(msg.From.ID, bson.M{"onboarding_step": onboardingStepLanguage, "source_chat_id": groupID}); err != nil {
+		log.Printf("Error saving onboarding state for user %d: %v", msg.From.ID, err)
+	}
+
+	from := ""
+	if groupID != 0 {
+		if title := bs.chatInfo(groupID).Title; title != "" {
+			from = " from " + title
+		}
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, lang := range onboardingLanguages {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(lang.name, "onboard:lang:"+lang.code))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	wizard := tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf(onboardingLanguageMsg, from))
+	wizard.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	bs.sendResponse(wizard)
+}
+
+// handleOnboardingCallback advances the wizard; each button only works on the step it was sent for
+func (bs *BotService) handleOnboardingCallback(cb *tgbotapi.CallbackQuery) {
+	parts := strings.SplitN(cb.Data, ":", 3)
+	if len(parts) != 3 || cb.Message == nil || !cb.Message.Chat.IsPrivate() {
+		bs.answerCallback(cb.ID, "")
+		return
+	}
+	userID, chatID, messageID := cb.From.ID, cb.Message.Chat.ID, cb.Message.MessageID
+	prefs := bs.store.UserPreferences(userID)
+
+	switch {
+	case parts[1] == "lang" && prefs.OnboardingStep == onboardingStepLanguage:
+		if err := bs.store.UpdateUserPreferences(userID, bson.M{"language": parts[2], "onboarding_step": onboardingStepPrivacy}); err != nil {
+			log.Printf("Error saving language for user %d: %v", userID, err)
+			bs.answerCallback(cb.ID, responseErrorMsg)
+			return
+		}
+		edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, onboardingPrivacyMsg, tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("👍 Got it", "onboard:privacy:ok")),
+		))
+		if _, err := bs.api.Send(edit); err != nil {
+			log.Printf("failed to edit onboarding message: %v", err)
+		}
+		bs.answerCallback(cb.ID, languageName(parts[2]))
+
+	case parts[1] == "privacy" && prefs.OnboardingStep == onboardingStepPrivacy:
+		// Only offer the digest of a group the user is actually in, so the link can't be used to read other groups
+		if prefs.SourceChatID != 0 && bs.isChatMember(prefs.SourceChatID, userID) {
+			if err := bs.store.UpdateUserPreferences(userID, bson.M{"privacy_accepted_at": time.Now(), "onboarding_step": onboardingStepSubscribe}); err != nil {
+				log.Printf("Error saving onboarding state for user %d: %v", userID, err)
+			}
+			edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID,
+				fmt.Sprintf(onboardingSubscribeMsg, bs.chatInfo(prefs.SourceChatID).Title), tgbotapi.NewInlineKeyboardMarkup(
+					tgbotapi.NewInlineKeyboardRow(
+						tgbotapi.NewInlineKeyboardButtonData("Yes please", "onboard:subscribe:yes"),
+						tgbotapi.NewInlineKeyboardButtonData("No thanks", "onboard:subscribe:no"),
+					),
+				))
+			if _, err := bs.api.Send(edit); err != nil {
+				log.Printf("failed to edit onboarding message: %v", err)
+			}
+			bs.answerCallback(cb.ID, "")
+			return
+		}
+		bs.finishOnboarding(cb, bson.M{"privacy_accepted_at": time.Now()})
+
+	case parts[1] == "subscribe" && prefs.OnboardingStep == onboardingStepSubscribe:
+		if parts[2] == "yes" {
+			if err := bs.store.SubscribeToDigests(userID, prefs.SourceChatID); err != nil {
+				log.Printf("Error subscribing user %d to digests: %v", userID, err)
+			}
+		}
+		bs.finishOnboarding(cb, bson.M{})
+
+	default:
+		bs.answerCallback(cb.ID, onboardingGoneCallback)
+	}
+}
+
+func (bs *BotService) finishOnboarding(cb *tgbotapi.CallbackQuery, changes bson.M) {
+	changes["onboarding_step"] = ""
+	changes["onboarded_at"] = time.Now()
+	if err := bs.store.UpdateUserPreferences(cb.From.ID, changes); err != nil {
+		log.Printf("Error saving onboarding state for user %d: %v", cb.From.ID, err)
+	}
+	bs.editMessageText(cb.Message.Chat.ID, cb.Message.MessageID, fmt.Sprintf(onboardingDoneMsg, bs.botMention))
+	bs.answerCallback(cb.ID, "")
+}
+
+// isChatMember reports whether a user is currently in a group
+func (bs *BotService) isChatMember(chatID, userID int64) bool {
+	member, err := bs.api.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID},
+	})
+	if err != nil {
+		log.Printf("failed to get member %d of chat %d: %v", userID, chatID, err)
+		return false
+	}
+	// Restricted users are still in the group if IsMember is set
+	return member.IsCreator() || member.IsAdministrator() || member.Status == "member" || (member.Status == "restricted" && member.IsMember)
+}
+
+// sendDigestToSubscribers sends a copy of a group's digest to the users who subscribed during onboarding
+func (bs *BotService) sendDigestToSubscribers(chatID int64, text string) {
+	userIDs, err := bs.store.DigestSubscribers(chatID)
+	if err != nil {
+		log.Printf("Error loading digest subscribers of chat %d: %v", chatID, err)
+		return
+	}
+	title := bs.chatInfo(chatID).Title
+	for _, userID := range userIDs {
+		// Members who left the group stop getting its digests
+		if !bs.isChatMember(chatID, userID) {
+			continue
+		}
+		bs.sendDM(userID, dmDigests, kindDigest, fmt.Sprintf("From %s:\n\n%s", title, text))
+		time.Sleep(broadcastDelay)
+	}
+}
+
+func (bs *BotService) handleDigests(msg *tgbotapi.Message) {
+	switch strings.ToLower(strings.TrimSpace(msg.CommandArguments())) {
+	case "":
+		chats := bs.store.UserPreferences(msg.From.ID).DigestChats
+		if len(chats) == 0 {
+			bs.Reply(msg, "You don't get any group digests here.\n\n"+digestsUsageMsg)
+			return
+		}
+		var titles []string
+		for _, chatID := range chats {
+			titles = append(titles, "- "+bs.chatInfo(chatID).Title)
+		}
+		bs.Reply(msg, "You get the digests of:\n"+strings.Join(titles, "\n")+"\n\n"+digestsUsageMsg)
+	case "off":
+		if err := bs.store.UpdateUserPreferences(msg.From.ID, bson.M{"digest_chats": nil}); err != nil {
+			log.Printf("Error unsubscribing user %d from digests: %v", msg.From.ID, err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bs.Reply(msg, "Done, you won't get group digests here anymore.")
+	default:
+		bs.Reply(msg, digestsUsageMsg)
+	}
+}
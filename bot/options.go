@@ -0,0 +1,175 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/cache"
+	"github.com/sg-milad/ChatBuddy/eventbus"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+// HandlerFunc handles a message on behalf of a program embedding the bot
+type HandlerFunc func(bs *BotService, msg *tgbotapi.Message)
+
+// Option customizes a bot created with NewBot
+type Option func(*BotService)
+
+// WithConfig sets the configuration; without it NewBot calls LoadConfig
+func WithConfig(cfg *Config) Option {
+	return func(bs *BotService) { bs.cfg.Store(cfg) }
+}
+
+// WithStore uses an existing store instead of connecting to cfg.MongoURI
+func WithStore(s *store.Store) Option {
+	return func(bs *BotService) { bs.store = s }
+}
+
+// WithGenerator uses the given model instead of creating a Gemini client from cfg.GeminiAPIKey
+func WithGenerator(g llm.Generator) Option {
+	return func(bs *BotService) { bs.gemini = g }
+}
+
+// WithTelegram uses the given client instead of connecting with cfg.BotToken; self is the bot's own user
+func WithTelegram(client TelegramClient, self tgbotapi.User) Option {
+	return func(bs *BotService) {
+		bs.api = client
+		bs.botMention = "@" + self.UserName
+		bs.id = self.ID
+	}
+}
+
+// WithCommand registers a command, replacing the built-in command of the same name.
+// Commands with a Description show up in /help and in the Telegram command menu
+func WithCommand(cmd Command) Option {
+	return func(bs *BotService) { bs.registerCommand(cmd) }
+}
+
+// WithMessageHandler registers a handler that sees every incoming non-channel message after it is stored.
+// Handlers run on the update loop, so start a goroutine for slow work
+func WithMessageHandler(handler HandlerFunc) Option {
+	return func(bs *BotService) { bs.messageHandlers = append(bs.messageHandlers, handler) }
+}
+
+// WithMiddleware adds a middleware to the update pipeline, after storage and before rate limiting and routing
+func WithMiddleware(m Middleware) Option {
+	return func(bs *BotService) { bs.middlewares = append(bs.middlewares, m) }
+}
+
+// NewBot connects to Telegram, MongoDB and Gemini, unless given by options, and returns a bot ready to Run
+func NewBot(opts ...Option) (*BotService, error) {
+	bs := &BotService{commands: make(map[string]Command)}
+	for _, cmd := range builtinCommands() {
+		bs.registerCommand(cmd)
+	}
+	for _, opt := range opts {
+		opt(bs)
+	}
+
+	if bs.cfg.Load() == nil {
+		cfg, err := LoadConfig()
+		if err != nil {
+			return nil, err
+		}
+		bs.cfg.Store(cfg)
+	}
+	cfg := bs.config()
+
+	var err error
+	if bs.api == nil {
+		api, err := tgbotapi.NewBotAPI(cfg.BotToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize bot API: %w", err)
+		}
+		log.Printf("authorized as @%s", api.Self.UserName)
+		bs.api = api
+		bs.botMention = "@" + api.Self.UserName
+		bs.id = api.Self.ID
+	}
+
+	if bs.store == nil {
+		if bs.store, err = store.Connect(cfg.MongoURI, store.DefaultDatabase); err != nil {
+			return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+		}
+	}
+	bs.db = bs.store.DB()
+
+	if cfg.RedisURL != "" && bs.cache == nil {
+		if bs.cache, err = cache.Dial(cfg.RedisURL); err != nil {
+			return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		}
+		bs.store.SetCache(bs.cache)
+		log.Println("Connected to Redis successfully")
+	}
+
+	if bs.vectors == nil {
+		if bs.vectors, err = newVectorStore(cfg, bs.store); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.EventBusURL != "" && bs.bus == nil {
+		if bs.bus, err = eventbus.Dial(cfg.EventBusURL); err != nil {
+			return nil, fmt.Errorf("failed to connect to the event bus: %w", err)
+		}
+		log.Println("Connected to NATS successfully")
+	}
+
+	if bs.gemini == nil {
+		if bs.gemini, err = llm.NewGemini(context.Background(), cfg.GeminiAPIKey, cfg.Model.Model); err != nil {
+			return nil, err
+		}
+	}
+	bs.configureModel(cfg.Model)
+
+	prompts, err := loadPrompts(cfg.PromptsFile)
+	if err != nil {
+		return nil, err
+	}
+	bs.prompts.Store(prompts)
+	bs.aliases.Store(newAliasMatcher(cfg.Aliases))
+
+	blocklist, err := loadScamBlocklist(cfg.ScamBlocklistFile)
+	if err != nil {
+		return nil, err
+	}
+	bs.scamBlocklist.Store(blocklist)
+
+	bs.pipeline = bs.buildPipeline()
+	return bs, nil
+}
+
+// API returns the Telegram client, for handlers that need more than Reply
+func (bs *BotService) API() TelegramClient {
+	return bs.api
+}
+
+// Store returns the message and settings store
+func (bs *BotService) Store() *store.Store {
+	return bs.store
+}
+
+// Generator returns the language model
+func (bs *BotService) Generator() llm.Generator {
+	return bs.gemini
+}
+
+// Close releases the model client, the database connections, Redis and the event bus
+func (bs *BotService) Close() {
+	if closer, ok := bs.gemini.(interface{ Close() }); ok {
+		closer.Close()
+	}
+	bs.store.Close()
+	if bs.cache != nil {
+		bs.cache.Close()
+	}
+	if bs.bus != nil {
+		bs.bus.Close()
+	}
+	if closer, ok := bs.vectors.(interface{ Close() }); ok {
+		closer.Close()
+	}
+}
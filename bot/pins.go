@@ -0,0 +1,263 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const pinUsageMsg = `Usage:
+/pin - reply to a message to pin it
+/pin summaries on|off - pin every /summary, replacing my previous pin
+/pin digests on|off - pin scheduled digests, replacing my previous pin`
+
+const (
+	maxListedPins = 30
+	// pinSummaryRunes is the longest pinned text shown as is; longer ones get an AI summary
+	pinSummaryRunes = 120
+	pinsUsageMsg    = "Usage: /pins lists the pinned messages, /pins forget <n> removes one that was unpinned (admins)"
+)
+
+// pinSummariesSchema is the answer of the pinned message summaries, one per message in order
+var pinSummariesSchema = &llm.Schema{
+	Type: "object",
+	Properties: map[string]*llm.Schema{
+		"summaries": {Type: "array", Items: &llm.Schema{Type: "string"}},
+	},
+	Required: []string{"summaries"},
+}
+
+// pinBotMessage pins a message the bot posted, unpinning its previous pin so the chat only has the latest one.
+// Pins are silent; failures (usually a missing "pin messages" right) are only logged.
+func (bs *BotService) pinBotMessage(chatID int64, messageID int) {
+	if previous := bs.store.ChatSettings(chatID).BotPinnedMessageID; previous != 0 && previous != messageID {
+		if _, err := bs.api.Request(tgbotapi.UnpinChatMessageConfig{ChatID: chatID, MessageID: previous}); err != nil {
+			log.Printf("failed to unpin message %d in chat %d: %v", previous, chatID, err)
+		} else if err := bs.store.RemovePin(chatID, previous); err != nil {
+			log.Printf("Error forgetting pinned message %d of chat %d: %v", previous, chatID, err)
+		}
+	}
+
+	if _, err := bs.api.Request(tgbotapi.PinChatMessageConfig{ChatID: chatID, MessageID: messageID, DisableNotification: true}); err != nil {
+		log.Printf("failed to pin message %d in chat %d: %v", messageID, chatID, err)
+		return
+	}
+	if err := bs.store.UpdateChatSettings(chatID, bson.M{"bot_pinned_message_id": messageID}); err != nil {
+		log.Printf("Error saving pinned message for chat %d: %v", chatID, err)
+	}
+}
+
+func (bs *BotService) handlePin(msg *tgbotapi.Message) {
+	args := strings.Fields(strings.ToLower(msg.CommandArguments()))
+	if len(args) == 0 {
+		if msg.ReplyToMessage == nil {
+			settings := bs.store.ChatSettings(msg.Chat.ID)
+			onOff := map[bool]string{true: "on", false: "off"}
+			bs.Reply(msg, fmt.Sprintf("Pinning summaries: %s\nPinning digests: %s\n\n%s", onOff[settings.PinSummaries], onOff[settings.PinDigests], pinUsageMsg))
+			return
+		}
+		if _, err := bs.api.Request(tgbotapi.PinChatMessageConfig{ChatID: msg.Chat.ID, MessageID: msg.ReplyToMessage.MessageID}); err != nil {
+			log.Printf("failed to pin message in chat %d: %v", msg.Chat.ID, err)
+			bs.Reply(msg, "I couldn't pin that message. Make sure I'm an admin with the right to pin messages.")
+			return
+		}
+		// Telegram doesn't send the bot the service message of its own pins
+		bs.recordPin(msg.Chat, msg.ReplyToMessage, msg.From)
+		return
+	}
+
+	fields := map[string]string{"summaries": "pin_summaries", "digests": "pin_digests"}
+	field, ok := fields[args[0]]
+	if !ok || len(args) != 2 || (args[1] != "on" && args[1] != "off") {
+		bs.Reply(msg, pinUsageMsg)
+		return
+	}
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{field: args[1] == "on"}); err != nil {
+		log.Printf("Error saving pin settings: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if args[1] == "on" {
+		bs.Reply(msg, fmt.Sprintf("📌 I'll pin %s from now on. I need the right to pin messages for this.", args[0]))
+	} else {
+		bs.Reply(msg, fmt.Sprintf("I won't pin %s anymore.", args[0]))
+	}
+}
+
+// recordPin stores a message pinned in a chat with stored history, for /pins
+func (bs *BotService) recordPin(chat *tgbotapi.Chat, pinned *tgbotapi.Message, by *tgbotapi.User) {
+	if !bs.historyEnabled(chat.ID) {
+		return
+	}
+	text := pinned.Text
+	if text == "" {
+		text = pinned.Caption
+	}
+	if text == "" {
+		text = "[" + cmp.Or(mediaKind(pinned), "message") + "]"
+	}
+	author := displayName(pinned.From)
+	if pinned.From == nil && pinned.SenderChat != nil {
+		author = pinned.SenderChat.Title
+	}
+	pin := store.Pin{ChatID: chat.ID, MessageID: pinned.MessageID, Author: author, Text: text, PinnedAt: time.Now()}
+	if by != nil {
+		pin.PinnedBy = by.ID
+	}
+	if err := bs.store.SavePin(pin); err != nil {
+		log.Printf("Error saving pinned message %d of chat %d: %v", pinned.MessageID, chat.ID, err)
+	}
+}
+
+// handlePins lists the chat's pinned messages, most recent first, each with a one-line summary and a link
+func (bs *BotService) handlePins(msg *tgbotapi.Message) {
+	args := strings.Fields(msg.CommandArguments())
+	if len(args) > 0 {
+		bs.forgetPin(msg, args)
+		return
+	}
+
+	bs.reconcilePins(msg.Chat)
+	pins, err := bs.store.Pins(msg.Chat.ID, maxListedPins)
+	if err != nil {
+		log.Printf("Error loading the pinned messages of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(pins) == 0 {
+		bs.Reply(msg, "📌 I haven't seen any pinned messages here yet. I keep track of those pinned while I'm in the chat.")
+		return
+	}
+	bs.summarizePins(bs.billedContext(msg), pins)
+
+	loc := bs.chatLocation(msg.Chat.ID)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📌 %d pinned messages:\n", len(pins))
+	for i, p := range pins {
+		fmt.Fprintf(&sb, "\n%d. %s\n   %s, pinned %s", i+1, p.Summary, p.Author, p.PinnedAt.In(loc).Format("Jan 2"))
+		if link := messageLink(msg.Chat, p.MessageID); link != "" {
+			sb.WriteString("\n   " + link)
+		}
+	}
+	bs.Reply(msg, sb.String())
+}
+
+// reconcilePins forgets the stored pins when the chat has none left, and adds its latest pin if it was pinned
+// before the bot joined; Telegram sends no updates for unpins and only tells the latest pin
+func (bs *BotService) reconcilePins(chat *tgbotapi.Chat) {
+	info, err := bs.api.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: chat.ID}})
+	if err != nil {
+		log.Printf("failed to get chat %d: %v", chat.ID, err)
+		return
+	}
+	if info.PinnedMessage == nil {
+		if err := bs.store.ClearPins(chat.ID); err != nil {
+			log.Printf("Error clearing the pinned messages of chat %d: %v", chat.ID, err)
+		}
+		return
+	}
+	pins, err := bs.store.Pins(chat.ID, maxListedPins)
+	if err != nil {
+		return
+	}
+	for _, p := range pins {
+		if p.MessageID == info.PinnedMessage.MessageID {
+			return
+		}
+	}
+	bs.recordPin(chat, info.PinnedMessage, nil)
+}
+
+// summarizePins fills in the summaries of pins that have none: short texts as they are, longer ones in one
+// model request for all of them
+func (bs *BotService) summarizePins(ctx context.Context, pins []store.Pin) {
+	var long []int
+	for i, p := range pins {
+		if p.Summary != "" {
+			continue
+		}
+		line := strings.Join(strings.Fields(p.Text), " ")
+		if utf8.RuneCountInString(line) <= pinSummaryRunes {
+			pins[i].Summary = line
+			continue
+		}
+		long = append(long, i)
+	}
+
+	if len(long) > 0 {
+		var texts strings.Builder
+		for n, i := range long {
+			fmt.Fprintf(&texts, "Message %d:\n%s\n\n", n+1, pins[i].Text)
+		}
+		prompt := fmt.Sprintf(`Summarize each of these %d pinned messages of a Telegram group in one short line (at most 15 words) that tells a newcomer why it matters. Write in the language of the message.
+
+%s
+
+Answer with the summaries in the order of the messages.`, len(long), untrusted("pinned messages", texts.String()))
+
+		var answer struct {
+			Summaries []string `json:"summaries"`
+		}
+		ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+		defer cancel()
+		err := bs.generateJSON(ctx, prompt, pinSummariesSchema, &answer, func() error {
+			if len(answer.Summaries) != len(long) {
+				return fmt.Errorf("got %d summaries for %d messages", len(answer.Summaries), len(long))
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error summarizing pinned messages: %v", err)
+		}
+		for n, i := range long {
+			if err != nil || strings.TrimSpace(answer.Summaries[n]) == "" {
+				// Shown truncated this time and summarized on the next /pins
+				pins[i].Summary = truncateRunes(strings.Join(strings.Fields(pins[i].Text), " "), pinSummaryRunes)
+				continue
+			}
+			pins[i].Summary = strings.TrimSpace(answer.Summaries[n])
+			if err := bs.store.SetPinSummary(pins[i].ChatID, pins[i].MessageID, pins[i].Summary); err != nil {
+				log.Printf("Error saving a pinned message summary: %v", err)
+			}
+		}
+	}
+}
+
+// forgetPin removes a pin that was unpinned from the list, for admins
+func (bs *BotService) forgetPin(msg *tgbotapi.Message, args []string) {
+	if len(args) != 2 || args[0] != "forget" {
+		bs.Reply(msg, pinsUsageMsg)
+		return
+	}
+	if bs.userRole(msg.Chat, msg.From) < ChatAdmin {
+		bs.Reply(msg, "Only admins can change the pin list.")
+		return
+	}
+	n, err := strconv.Atoi(args[1])
+	pins, loadErr := bs.store.Pins(msg.Chat.ID, maxListedPins)
+	if loadErr != nil {
+		log.Printf("Error loading the pinned messages of chat %d: %v", msg.Chat.ID, loadErr)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if err != nil || n < 1 || n > len(pins) {
+		bs.Reply(msg, fmt.Sprintf("There's no pin %s, see /pins.", args[1]))
+		return
+	}
+	if err := bs.store.RemovePin(msg.Chat.ID, pins[n-1].MessageID); err != nil {
+		log.Printf("Error forgetting a pinned message of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("Removed pin %d from the list.", n))
+}
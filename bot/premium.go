@@ -0,0 +1,323 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// starsCurrency is the currency code of Telegram Stars; Stars invoices need no payment provider token
+	starsCurrency = "XTR"
+	// premiumCacheTTL is how long an entitlement lookup is reused, since limits check it on every message
+	premiumCacheTTL = 5 * time.Minute
+
+	premiumScopeUser = "user"
+	premiumScopeChat = "chat"
+
+	premiumUsageMsg = `Usage:
+/premium - show the premium status of you and this chat
+/premium buy - buy premium for yourself
+/premium buy chat - buy premium for this group
+Owner: /premium grant <user|chat> <id> <days>, /premium revoke <user|chat> <id>`
+	premiumNotForSaleMsg = "Premium isn't for sale on this bot."
+)
+
+// Entitlement is a premium tier bought for, or granted to, a user or a chat
+type Entitlement struct {
+	Scope     string    `bson:"scope"`
+	SubjectID int64     `bson:"subject_id"`
+	ExpiresAt time.Time `bson:"expires_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// Payment records a successful Stars payment, keeping the charge ID needed for refunds
+type Payment struct {
+	// Product is "premium" or "credits"
+	Product   string    `bson:"product"`
+	Scope     string    `bson:"scope"`
+	SubjectID int64     `bson:"subject_id"`
+	PaidBy    int64     `bson:"paid_by"`
+	Stars     int       `bson:"stars"`
+	Days      int       `bson:"days,omitempty"`
+	Credits   int       `bson:"credits,omitempty"`
+	ChargeID  string    `bson:"charge_id"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+type premiumCacheEntry struct {
+	expiresAt time.Time
+	fetched   time.Time
+}
+
+// premiumUntil returns when the premium tier of a user or chat ends, the zero time if it has none
+func (bs *BotService) premiumUntil(scope string, id int64) time.Time {
+	key := fmt.Sprintf("%s:%d", scope, id)
+	if cached, ok := bs.premiumCache.Load(key); ok {
+		if entry := cached.(premiumCacheEntry); time.Since(entry.fetched) < premiumCacheTTL {
+			return entry.expiresAt
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var entitlement Entitlement
+	err := bs.db.Collection("entitlements").FindOne(ctx, bson.M{"scope": scope, "subject_id": id}).Decode(&entitlement)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		// Don't cache lookup failures, a paying user shouldn't lose premium for five minutes over one error
+		log.Printf("Error loading entitlement of %s: %v", key, err)
+		return time.Time{}
+	}
+	bs.premiumCache.Store(key, premiumCacheEntry{expiresAt: entitlement.ExpiresAt, fetched: time.Now()})
+	return entitlement.ExpiresAt
+}
+
+func (bs *BotService) isPremium(scope string, id int64) bool {
+	return bs.premiumUntil(scope, id).After(time.Now())
+}
+
+// isPremiumChat reports whether a chat gets premium features; in a DM the user's own premium counts
+func (bs *BotService) isPremiumChat(chat *tgbotapi.Chat) bool {
+	if chat.IsPrivate() {
+		return bs.isPremium(premiumScopeUser, chat.ID)
+	}
+	return bs.isPremium(premiumScopeChat, chat.ID)
+}
+
+// premiumLimit raises a rate limit for premium users and chats; 0 stays unlimited
+func (bs *BotService) premiumLimit(limit int, premium bool) int {
+	if premium && bs.config().PremiumLimitMultiplier > 1 {
+		return limit * bs.config().PremiumLimitMultiplier
+	}
+	return limit
+}
+
+// premiumContext makes model calls for premium chats use PREMIUM_GEMINI_MODEL, if set
+func (bs *BotService) premiumContext(ctx context.Context, chat *tgbotapi.Chat) context.Context {
+	if model := bs.config().PremiumModel; model != "" && bs.isPremiumChat(chat) {
+		return llm.WithModel(ctx, model)
+	}
+	return ctx
+}
+
+// extendPremium adds days to the premium tier of a user or chat, starting now if it had expired
+func (bs *BotService) extendPremium(scope string, id int64, days int) (time.Time, error) {
+	start := time.Now()
+	if until := bs.premiumUntil(scope, id); until.After(start) {
+		start = until
+	}
+	return bs.setPremiumUntil(scope, id, start.AddDate(0, 0, days))
+}
+
+func (bs *BotService) setPremiumUntil(scope string, id int64, until time.Time) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := bs.db.Collection("entitlements").UpdateOne(ctx,
+		bson.M{"scope": scope, "subject_id": id},
+		bson.M{"$set": bson.M{"expires_at": until, "updated_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	bs.premiumCache.Delete(fmt.Sprintf("%s:%d", scope, id))
+	return until, err
+}
+
+func (bs *BotService) handlePremium(msg *tgbotapi.Message) {
+	args := strings.Fields(strings.ToLower(msg.CommandArguments()))
+	if len(args) == 0 {
+		bs.Reply(msg, bs.premiumStatus(msg)+"\n\n"+premiumUsageMsg)
+		return
+	}
+
+	switch args[0] {
+	case "buy":
+		scope, id := premiumScopeUser, msg.From.ID
+		if len(args) > 1 && args[1] == "chat" {
+			if msg.Chat.IsPrivate() {
+				bs.Reply(msg, "Use /premium buy chat in the group you want premium for.")
+				return
+			}
+			scope, id = premiumScopeChat, msg.Chat.ID
+		}
+		bs.sendPremiumInvoice(msg, scope, id)
+	case "grant", "revoke":
+		if bs.userRole(msg.Chat, msg.From) < Owner {
+			bs.Reply(msg, "Only the bot owner can grant or revoke premium.")
+			return
+		}
+		bs.audit(msg.Chat.ID, msg.From, "/premium", msg.CommandArguments())
+		bs.handlePremiumGrant(msg, args)
+	default:
+		bs.Reply(msg, premiumUsageMsg)
+	}
+}
+
+func (bs *BotService) premiumStatus(msg *tgbotapi.Message) string {
+	describe := func(until time.Time) string {
+		if until.After(time.Now()) {
+			return "✨ premium until " + until.In(bs.chatLocation(msg.Chat.ID)).Format("2006-01-02")
+		}
+		return "free tier"
+	}
+
+	status := "You: " + describe(bs.premiumUntil(premiumScopeUser, msg.From.ID))
+	if !msg.Chat.IsPrivate() {
+		status += "\nThis chat: " + describe(bs.premiumUntil(premiumScopeChat, msg.Chat.ID))
+	}
+	if bs.config().PremiumPriceStars > 0 {
+		status += fmt.Sprintf("\n\nPremium costs %d ⭐ for %d days: higher rate limits", bs.config().PremiumPriceStars, bs.config().PremiumDays)
+		if bs.config().PremiumModel != "" {
+			status += " and answers from a stronger model"
+		}
+		status += "."
+	}
+	return status
+}
+
+func (bs *BotService) sendPremiumInvoice(msg *tgbotapi.Message, scope string, id int64) {
+	price := bs.config().PremiumPriceStars
+	if price <= 0 {
+		bs.Reply(msg, premiumNotForSaleMsg)
+		return
+	}
+
+	target := "you"
+	if scope == premiumScopeChat {
+		target = chatDisplayName(*msg.Chat)
+	}
+	invoice := tgbotapi.NewInvoice(msg.Chat.ID,
+		"ChatBuddy Premium",
+		fmt.Sprintf("%d days of premium for %s: higher rate limits and premium model answers.", bs.config().PremiumDays, target),
+		fmt.Sprintf("premium:%s:%d", scope, id),
+		"", "", starsCurrency,
+		[]tgbotapi.LabeledPrice{{Label: fmt.Sprintf("Premium, %d days", bs.config().PremiumDays), Amount: price}},
+	)
+	invoice.ReplyToMessageID = msg.MessageID
+	if _, err := bs.api.Send(invoice); err != nil {
+		log.Printf("failed to send premium invoice: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+	}
+}
+
+// parseInvoicePayload reads the product, scope and subject from an invoice payload like "premium:chat:-100123"
+func parseInvoicePayload(payload string) (product, scope string, id int64, ok bool) {
+	parts := strings.Split(payload, ":")
+	if len(parts) != 3 || (parts[0] != "premium" && parts[0] != "credits") || (parts[1] != premiumScopeUser && parts[1] != premiumScopeChat) {
+		return "", "", 0, false
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	return parts[0], parts[1], id, err == nil
+}
+
+// invoicePrice is the current Stars price of a product, 0 when it isn't for sale
+func (bs *BotService) invoicePrice(product string) int {
+	switch product {
+	case "premium":
+		return bs.config().PremiumPriceStars
+	case "credits":
+		return bs.config().CreditsPackStars
+	default:
+		return 0
+	}
+}
+
+// handlePreCheckout confirms a payment is for a current offer before Telegram charges the user
+func (bs *BotService) handlePreCheckout(query *tgbotapi.PreCheckoutQuery) {
+	answer := tgbotapi.PreCheckoutConfig{PreCheckoutQueryID: query.ID, OK: true}
+	product, _, _, ok := parseInvoicePayload(query.InvoicePayload)
+	price := bs.invoicePrice(product)
+	if !ok || query.Currency != starsCurrency || price <= 0 || query.TotalAmount != price {
+		answer.OK = false
+		answer.ErrorMessage = "This offer has changed, please start the purchase again."
+	}
+	if _, err := bs.api.Request(answer); err != nil {
+		log.Printf("failed to answer pre-checkout query: %v", err)
+	}
+}
+
+// handleSuccessfulPayment records a Stars payment and delivers the premium tier or credits it was for
+func (bs *BotService) handleSuccessfulPayment(msg *tgbotapi.Message) {
+	payment := msg.SuccessfulPayment
+	product, scope, id, ok := parseInvoicePayload(payment.InvoicePayload)
+	if !ok {
+		log.Printf("payment %s has an unknown payload %q", payment.TelegramPaymentChargeID, payment.InvoicePayload)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	record := Payment{Product: product, Scope: scope, SubjectID: id, PaidBy: msg.From.ID, Stars: payment.TotalAmount,
+		ChargeID: payment.TelegramPaymentChargeID, CreatedAt: time.Now()}
+	if product == "credits" {
+		record.Credits = bs.config().CreditsPackSize
+	} else {
+		record.Days = bs.config().PremiumDays
+	}
+	if _, err := bs.db.Collection("payments").InsertOne(ctx, record); err != nil {
+		log.Printf("Error recording payment %s: %v", payment.TelegramPaymentChargeID, err)
+	}
+
+	if product == "credits" {
+		bs.deliverCredits(msg, record)
+		return
+	}
+	days := record.Days
+
+	until, err := bs.extendPremium(scope, id, days)
+	if err != nil {
+		log.Printf("Error extending premium for payment %s: %v", payment.TelegramPaymentChargeID, err)
+		bs.Reply(msg, "Thanks for your payment! Something went wrong activating premium, please contact the bot owner.")
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("✨ Thank you! Premium is active until %s.", until.In(bs.chatLocation(msg.Chat.ID)).Format("2006-01-02")))
+}
+
+func (bs *BotService) handlePremiumGrant(msg *tgbotapi.Message, args []string) {
+	if len(args) < 3 || (args[1] != premiumScopeUser && args[1] != premiumScopeChat) {
+		bs.Reply(msg, premiumUsageMsg)
+		return
+	}
+	id, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		bs.Reply(msg, premiumUsageMsg)
+		return
+	}
+
+	if args[0] == "revoke" {
+		if _, err := bs.setPremiumUntil(args[1], id, time.Time{}); err != nil {
+			log.Printf("Error revoking premium: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bs.Reply(msg, fmt.Sprintf("Premium revoked for %s %d.", args[1], id))
+		return
+	}
+
+	days := 0
+	if len(args) == 4 {
+		days, _ = strconv.Atoi(args[3])
+	}
+	if days <= 0 {
+		bs.Reply(msg, premiumUsageMsg)
+		return
+	}
+	until, err := bs.extendPremium(args[1], id, days)
+	if err != nil {
+		log.Printf("Error granting premium: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("✨ %s %d has premium until %s.", args[1], id, until.Format("2006-01-02")))
+}
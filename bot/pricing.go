@@ -0,0 +1,167 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+const (
+	defaultPricingCurrency = "USD"
+	budgetWarningMsg       = "💸 The estimated AI cost this month is %s, %d%% of the %s monthly budget."
+)
+
+// ModelPrice is what a model costs per million tokens; CachedInput is the price of prompt tokens read from a
+// context cache
+type ModelPrice struct {
+	Input       float64 `json:"input"`
+	Output      float64 `json:"output"`
+	CachedInput float64 `json:"cached_input,omitempty"`
+}
+
+// Pricing is the price table cost estimates are made with, in one currency
+type Pricing struct {
+	Currency string                `json:"currency"`
+	Models   map[string]ModelPrice `json:"models"`
+}
+
+// defaultModelPrices are Gemini's list prices in USD at the time of writing; PRICING_FILE keeps them current
+var defaultModelPrices = map[string]ModelPrice{
+	"gemini-2.0-flash":      {Input: 0.10, Output: 0.40, CachedInput: 0.025},
+	"gemini-2.0-flash-lite": {Input: 0.075, Output: 0.30},
+	"gemini-2.5-flash":      {Input: 0.30, Output: 2.50, CachedInput: 0.075},
+	"gemini-2.5-pro":        {Input: 1.25, Output: 10.00, CachedInput: 0.31},
+	"gemini-1.5-flash":      {Input: 0.075, Output: 0.30, CachedInput: 0.01875},
+	"gemini-1.5-pro":        {Input: 1.25, Output: 5.00, CachedInput: 0.3125},
+}
+
+// loadPricing reads the price table of path, a JSON Pricing whose models are added to or replace the default
+// ones; an empty path means the defaults in USD
+func loadPricing(path string) (Pricing, error) {
+	pricing := Pricing{Currency: defaultPricingCurrency, Models: maps.Clone(defaultModelPrices)}
+	if path == "" {
+		return pricing, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pricing, fmt.Errorf("failed to read pricing file: %w", err)
+	}
+	var file Pricing
+	if err := json.Unmarshal(data, &file); err != nil {
+		return pricing, fmt.Errorf("invalid pricing file %s: %w", path, err)
+	}
+	if file.Currency != "" && file.Currency != pricing.Currency {
+		// Prices in another currency replace the table, the USD defaults would mix in
+		pricing = Pricing{Currency: file.Currency, Models: map[string]ModelPrice{}}
+	}
+	maps.Copy(pricing.Models, file.Models)
+	return pricing, nil
+}
+
+// price returns the price of a model, matching versioned names like gemini-2.0-flash-001 to the longest
+// model name they start with
+func (p Pricing) price(model string) (ModelPrice, bool) {
+	model = strings.TrimPrefix(model, "models/")
+	if price, ok := p.Models[model]; ok {
+		return price, true
+	}
+	var best string
+	for name := range p.Models {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	price, ok := p.Models[best]
+	return price, ok && best != ""
+}
+
+// cost estimates what usage totals of one model cost; ok is false for models missing from the table
+func (p Pricing) cost(t store.UsageTotals) (cost float64, ok bool) {
+	price, ok := p.price(t.Model)
+	if !ok {
+		return 0, false
+	}
+	cachedPrice := price.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = price.Input
+	}
+	uncached := t.PromptTokens - t.CachedTokens
+	return (float64(uncached)*price.Input + float64(t.CachedTokens)*cachedPrice + float64(t.OutputTokens)*price.Output) / 1e6, true
+}
+
+// totalCost estimates what usage totals per model cost, returning the models missing from the table too
+func (p Pricing) totalCost(models []store.UsageTotals) (float64, []string) {
+	var total float64
+	var unpriced []string
+	for _, m := range models {
+		if c, ok := p.cost(m); ok {
+			total += c
+		} else {
+			unpriced = append(unpriced, m.Model)
+		}
+	}
+	return total, unpriced
+}
+
+// formatCost formats an amount of the currency, with more decimals for the small amounts single chats cost
+func (p Pricing) formatCost(amount float64) string {
+	decimals := 2
+	if amount < 1 {
+		decimals = 4
+	}
+	if p.Currency == "USD" {
+		return fmt.Sprintf("$%.*f", decimals, amount)
+	}
+	return fmt.Sprintf("%.*f %s", decimals, amount, p.Currency)
+}
+
+// costSummary is the estimated cost line of usage totals per model, or "" when no model is priced
+func (p Pricing) costSummary(models []store.UsageTotals) string {
+	total, unpriced := p.totalCost(models)
+	if len(unpriced) == len(models) {
+		return ""
+	}
+	line := "Estimated cost: " + p.formatCost(total)
+	if len(unpriced) > 0 {
+		line += fmt.Sprintf(" (no prices for %s)", strings.Join(unpriced, ", "))
+	}
+	return line
+}
+
+// budgetThresholds are the shares of MONTHLY_BUDGET, in percent, at which the owner is warned
+var budgetThresholds = []int{80, 100}
+
+// checkBudget warns the owner once a month for each threshold the estimated AI cost of the month crosses
+func (bs *BotService) checkBudget(now time.Time) {
+	cfg := bs.config()
+	if cfg.MonthlyBudget <= 0 || cfg.OwnerID == 0 || now.Minute() != 0 {
+		return
+	}
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	models, err := bs.store.UsageByModel(monthStart, now)
+	if err != nil {
+		log.Printf("Error loading this month's model usage: %v", err)
+		return
+	}
+	cost, _ := cfg.Pricing.totalCost(models)
+	spent := int(cost / cfg.MonthlyBudget * 100)
+
+	for i := len(budgetThresholds) - 1; i >= 0; i-- {
+		threshold := budgetThresholds[i]
+		if spent < threshold {
+			continue
+		}
+		if bs.claimDailyRun(fmt.Sprintf("budget_warning_%d", threshold), 0, monthStart.Format("2006-01")) {
+			bs.sendDM(cfg.OwnerID, dmReports, kindNotification, fmt.Sprintf(budgetWarningMsg,
+				cfg.Pricing.formatCost(cost), spent, cfg.Pricing.formatCost(cfg.MonthlyBudget)))
+		}
+		// Only the highest threshold crossed is reported
+		return
+	}
+}
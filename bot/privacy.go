@@ -0,0 +1,35 @@
+package bot
+
+import (
+	"io"
+	"regexp"
+)
+
+const strictPrivacyMsg = "This bot doesn't keep chat messages, so this command is turned off."
+
+// userIDPattern and usernamePattern match user identifiers in log lines; private chats share their ID with
+// the user, so positive chat IDs count too, while groups and channels have negative ones
+var (
+	userIDPattern   = regexp.MustCompile(`\b(user|member|admin|referrer|chat) \d+\b`)
+	usernamePattern = regexp.MustCompile(`@\w+`)
+)
+
+// redactingWriter removes user identifiers from log lines before they reach the wrapped writer
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	redacted := userIDPattern.ReplaceAll(p, []byte("$1 [redacted]"))
+	redacted = usernamePattern.ReplaceAll(redacted, []byte("@[redacted]"))
+	if _, err := r.w.Write(redacted); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// RedactingLogWriter wraps the standard logger's output so STRICT_PRIVACY logs keep no user IDs or usernames;
+// use it as log.SetOutput(bot.RedactingLogWriter(log.Writer()))
+func RedactingLogWriter(w io.Writer) io.Writer {
+	return redactingWriter{w: w}
+}
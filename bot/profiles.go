@@ -0,0 +1,117 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	profileFamily = "family"
+
+	familyFilteredMsg  = "🙈 I'd rather not answer that here, this chat uses the family profile. Try asking another way!"
+	familyEdgyStyleMsg = "The %s style isn't available with the family profile."
+	settingsUsageMsg   = `Usage:
+/settings - show this chat's settings
+/settings profile family|default - the family profile blocks unsafe and rude answers and edgy styles`
+)
+
+// errFamilyFiltered is returned by generate when an answer for a family chat was blocked or filtered out
+var errFamilyFiltered = errors.New("answer withheld by the family profile")
+
+// familyBlockedWords are withheld from answers in family chats, on top of the chat's banned words; matching
+// undoes leetspeak and stretched letters like findBannedWord does for members' messages
+var familyBlockedWords = []string{
+	"fuck", "fucking", "fucker", "motherfucker", "shit", "bullshit", "bitch", "bastard", "asshole", "pussy",
+	"cunt", "slut", "whore", "wanker", "twat", "piss", "crap", "damn", "porn", "nude", "nudes",
+}
+
+// familyChat reports whether a chat picked the family profile
+func (bs *BotService) familyChat(chatID int64) bool {
+	return bs.store.ChatSettings(chatID).Profile == profileFamily
+}
+
+// familyFilter withholds an answer made with llm.WithStrictSafety when the model's safety filters blocked it
+// or it contains a blocked word
+func (bs *BotService) familyFilter(ctx context.Context, text string, err error) (string, error) {
+	if !llm.StrictSafetyFromContext(ctx) {
+		return text, err
+	}
+	if errors.Is(err, llm.ErrBlocked) {
+		return "", errFamilyFiltered
+	}
+	if err != nil {
+		return text, err
+	}
+
+	blocked := familyBlockedWords
+	if o, ok := ctx.Value(originKey{}).(origin); ok {
+		blocked = slices.Concat(bs.store.ChatSettings(o.chatID).BannedWords, blocked)
+	}
+	if word := findBannedWord(text, blocked); word != "" {
+		log.Printf("Withheld an answer containing %q from a family chat", word)
+		return "", errFamilyFiltered
+	}
+	return text, nil
+}
+
+// handleSettings shows the chat's main settings, or picks its profile
+func (bs *BotService) handleSettings(msg *tgbotapi.Message) {
+	sub, arg, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+	switch strings.ToLower(sub) {
+	case "":
+		bs.Reply(msg, bs.settingsOverview(msg.Chat.ID)+"\n\n"+settingsUsageMsg)
+		return
+	case "profile":
+	default:
+		bs.Reply(msg, settingsUsageMsg)
+		return
+	}
+
+	var profile string
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case profileFamily:
+		profile = profileFamily
+	case "default":
+	default:
+		bs.Reply(msg, settingsUsageMsg)
+		return
+	}
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"profile": profile}); err != nil {
+		log.Printf("Error saving the profile of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.audit(msg.Chat.ID, msg.From, "/settings", "profile "+cmp.Or(profile, "default"))
+	if profile == profileFamily {
+		bs.Reply(msg, "👨‍👩‍👧 Family profile on: I'll block unsafe content at the strictest level, hold back answers with rude words and keep to friendly styles.")
+		return
+	}
+	bs.Reply(msg, "Back to the default profile.")
+}
+
+// settingsOverview lists the settings that shape the bot's answers in a chat
+func (bs *BotService) settingsOverview(chatID int64) string {
+	settings := bs.store.ChatSettings(chatID)
+	var sb strings.Builder
+	sb.WriteString("⚙️ Settings of this chat:\n")
+	fmt.Fprintf(&sb, "\nProfile: %s", cmp.Or(settings.Profile, "default"))
+	fmt.Fprintf(&sb, "\nAnswer style: %s (/style)", cmp.Or(bs.chatStyle(chatID).Name, "default"))
+	fmt.Fprintf(&sb, "\nGrounded answers: %s (/grounded)", onOff(settings.Grounded))
+	fmt.Fprintf(&sb, "\nWeb search: %s (/websearch)", onOff(settings.WebSearch))
+	if settings.ContextMessages > 0 {
+		fmt.Fprintf(&sb, "\nAnswer context: %d preceding messages (/context)", settings.ContextMessages)
+	} else {
+		sb.WriteString("\nAnswer context: off (/context)")
+	}
+	fmt.Fprintf(&sb, "\nHistory features: %s (/privacy)", onOff(bs.historyEnabled(chatID)))
+	return sb.String()
+}
@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"text/template"
+)
+
+// promptTemplates are the prompt overrides loaded from PROMPTS_FILE, keyed by prompt name.
+// The file is a JSON object with any of these keys, each a text/template:
+//
+//	"response": the system instruction of answers to mentions and replies, whose question is sent as the user's
+//	            message; with {{.Question}}, {{.Chat}}, the group description from chatContext, {{.Glossary}},
+//	            the chat's terms and member nicknames, {{.Knowledge}}, the matching items of the group's knowledge
+//	            base, and {{.Style}}, the length and tone guidelines of the answer style
+//	"summary":  /summary, with {{.Count}} and {{.Messages}}
+type promptTemplates map[string]*template.Template
+
+var promptNames = []string{"response", "summary"}
+
+// loadPrompts parses the prompt overrides in path; an empty path means the built-in prompts
+func loadPrompts(path string) (*promptTemplates, error) {
+	prompts := promptTemplates{}
+	if path == "" {
+		return &prompts, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompts file: %w", err)
+	}
+	var sources map[string]string
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, fmt.Errorf("invalid prompts file %s: %w", path, err)
+	}
+
+	for _, name := range promptNames {
+		src, ok := sources[name]
+		if !ok {
+			continue
+		}
+		t, err := template.New(name).Option("missingkey=error").Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %q prompt in %s: %w", name, path, err)
+		}
+		prompts[name] = t
+		delete(sources, name)
+	}
+	for name := range sources {
+		log.Printf("Ignoring unknown prompt %q in %s", name, path)
+	}
+	return &prompts, nil
+}
+
+// customPrompt renders the named prompt override; ok is false when there is none
+// or it fails to render, and the caller falls back to its built-in prompt
+func (bs *BotService) customPrompt(name string, data any) (prompt string, ok bool) {
+	prompts := bs.prompts.Load()
+	if prompts == nil {
+		return "", false
+	}
+	t, ok := (*prompts)[name]
+	if !ok {
+		return "", false
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		log.Printf("Error rendering %s prompt: %v", name, err)
+		return "", false
+	}
+	return buf.String(), true
+}
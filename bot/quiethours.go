@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const quietHoursUsageMsg = `Usage:
+/quiethours 23:00-07:00 - hold back digests, reminders, birthday posts and suggestions during these hours
+/quiethours off - post them any time
+Held-back posts are sent when quiet hours end, in the chat timezone.`
+
+// deferredMessage is a post held back during quiet hours, or a DM held back during the user's do-not-disturb hours
+type deferredMessage struct {
+	ChatID    int64     `bson:"chat_id"`
+	Text      string    `bson:"text"`
+	Pin       bool      `bson:"pin,omitempty"`
+	CreatedAt time.Time `bson:"created_at"`
+	// Kind is how the message is sent, kindDigest when unset
+	Kind messageKind `bson:"kind,omitempty"`
+}
+
+// parseQuietHours parses "HH:MM-HH:MM" into minutes after midnight
+func parseQuietHours(spec string) (start, end int, err error) {
+	from, to, ok := strings.Cut(strings.ReplaceAll(spec, " ", ""), "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected a range like 23:00-07:00")
+	}
+	parse := func(clock string) (int, error) {
+		t, err := time.Parse("15:04", clock)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %q, use HH:MM", clock)
+		}
+		return t.Hour()*60 + t.Minute(), nil
+	}
+	if start, err = parse(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parse(to); err != nil {
+		return 0, 0, err
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("quiet hours must not start and end at the same time")
+	}
+	return start, end, nil
+}
+
+// inQuietHours reports whether now falls into a chat's quiet hours, in the chat timezone. The quiet hours of a
+// private chat are the user's do-not-disturb hours, in their timezone.
+func (bs *BotService) inQuietHours(chatID int64, now time.Time) bool {
+	spec, loc := bs.store.ChatSettings(chatID).QuietHours, bs.chatLocation(chatID)
+	if chatID > 0 {
+		spec, loc = bs.store.UserPreferences(chatID).DNDHours, bs.userLocation(chatID, chatID)
+	}
+	return withinHours(spec, now.In(loc))
+}
+
+// withinHours reports whether local falls into a "HH:MM-HH:MM" range; false for an empty or invalid one
+func withinHours(spec string, local time.Time) bool {
+	if spec == "" {
+		return false
+	}
+	start, end, err := parseQuietHours(spec)
+	if err != nil {
+		return false
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	// The range wraps around midnight, like 23:00-07:00
+	return minute >= start || minute < end
+}
+
+// sendNonEssential posts a digest, reminder or other post nobody asked for, holding it back
+// until the chat's quiet hours end
+func (bs *BotService) sendNonEssential(chatID int64, text string) {
+	bs.holdOrSend(deferredMessage{ChatID: chatID, Text: text})
+}
+
+// sendScheduledDigest is sendNonEssential for digests, which are also pinned if the chat asked for it
+func (bs *BotService) sendScheduledDigest(chatID int64, text string) {
+	bs.holdOrSend(deferredMessage{ChatID: chatID, Text: text, Pin: bs.store.ChatSettings(chatID).PinDigests})
+}
+
+func (bs *BotService) holdOrSend(m deferredMessage) {
+	if !bs.inQuietHours(m.ChatID, time.Now()) {
+		bs.deliver(m)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m.CreatedAt = time.Now()
+	if _, err := bs.db.Collection("deferred_messages").InsertOne(ctx, m); err != nil {
+		log.Printf("Error deferring message for chat %d: %v", m.ChatID, err)
+		bs.deliver(m)
+	}
+}
+
+// deliver posts a held-back or scheduled message through a bot of the fleet that is in the chat
+func (bs *BotService) deliver(m deferredMessage) {
+	b := bs.botFor(m.ChatID)
+	b.sendLater(cmp.Or(m.Kind, kindDigest), tgbotapi.NewMessage(m.ChatID, m.Text), func(sent tgbotapi.Message) {
+		if m.Pin && sent.MessageID != 0 {
+			b.pinBotMessage(m.ChatID, sent.MessageID)
+		}
+	})
+}
+
+// sendDeferredMessages delivers the held-back posts of chats whose quiet hours are over
+func (bs *BotService) sendDeferredMessages(now time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := bs.db.Collection("deferred_messages")
+	chatIDs, err := collection.Distinct(ctx, "chat_id", bson.M{})
+	if err != nil {
+		log.Printf("Error loading deferred messages: %v", err)
+		return
+	}
+
+	for _, id := range chatIDs {
+		chatID, ok := id.(int64)
+		if !ok || bs.inQuietHours(chatID, now) {
+			continue
+		}
+
+		cursor, err := collection.Find(ctx, bson.M{"chat_id": chatID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+		if err != nil {
+			log.Printf("Error loading deferred messages for chat %d: %v", chatID, err)
+			continue
+		}
+		var messages []deferredMessage
+		if err := cursor.All(ctx, &messages); err != nil {
+			log.Printf("Error decoding deferred messages for chat %d: %v", chatID, err)
+			continue
+		}
+		if len(messages) == 0 {
+			continue
+		}
+
+		// Delete first, so a crash mid-way can't post the same messages twice
+		if _, err := collection.DeleteMany(ctx, bson.M{"chat_id": chatID, "created_at": bson.M{"$lte": messages[len(messages)-1].CreatedAt}}); err != nil {
+			log.Printf("Error deleting deferred messages for chat %d: %v", chatID, err)
+			continue
+		}
+		for _, m := range messages {
+			bs.deliver(m)
+		}
+	}
+}
+
+func (bs *BotService) handleQuietHours(msg *tgbotapi.Message) {
+	arg := strings.TrimSpace(msg.CommandArguments())
+	switch {
+	case arg == "":
+		status := "No quiet hours in this chat."
+		if spec := bs.store.ChatSettings(msg.Chat.ID).QuietHours; spec != "" {
+			status = fmt.Sprintf("Quiet hours: %s (%s).", spec, bs.chatLocation(msg.Chat.ID))
+		}
+		bs.Reply(msg, status+"\n\n"+quietHoursUsageMsg)
+		return
+	case strings.EqualFold(arg, "off"):
+		arg = ""
+	default:
+		if _, _, err := parseQuietHours(arg); err != nil {
+			bs.Reply(msg, fmt.Sprintf("%v\n\n%s", err, quietHoursUsageMsg))
+			return
+		}
+		arg = strings.ReplaceAll(arg, " ", "")
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"quiet_hours": arg}); err != nil {
+		log.Printf("Error saving quiet hours for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if arg == "" {
+		bs.Reply(msg, "Quiet hours are off.")
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("Quiet hours set to %s (%s). I'll hold back digests, reminders and birthday posts until they end.", arg, bs.chatLocation(msg.Chat.ID)))
+}
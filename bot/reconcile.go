@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+const (
+	// reconcileBatch is how many messages are checked per tick; each check is a forward and a delete in the
+	// scratch chat, which Telegram limits to about 20 messages a minute in groups
+	reconcileBatch = 10
+
+	forgetMessageUsageMsg = "Reply to a message with /forgetmessage to leave it out of summaries, searches and exports."
+	forgotMessageMsg      = "🗑 Forgotten: I'll leave that message out of summaries, searches and exports."
+	notStoredMessageMsg   = "I don't have that message stored."
+)
+
+// reconcileRunning keeps a reconciliation run from overlapping with the next tick
+var reconcileRunning atomic.Bool
+
+// reconcileMessages checks whether recent stored messages still exist in Telegram, which doesn't tell bots
+// about deletions, and hides the deleted ones. A message is forwarded to RECONCILE_CHAT_ID and the copy deleted
+// right away; Telegram refuses to forward a deleted message.
+func (bs *BotService) reconcileMessages(now time.Time) {
+	target := bs.config().ReconcileChatID
+	if target == 0 || !reconcileRunning.CompareAndSwap(false, true) {
+		return
+	}
+	bs.goSafe("message reconciliation", func() {
+		defer reconcileRunning.Store(false)
+
+		messages, err := bs.store.MessagesToReconcile(now.AddDate(0, 0, -bs.config().ReconcileDays), reconcileBatch)
+		if err != nil {
+			log.Printf("Error loading messages to reconcile: %v", err)
+			return
+		}
+
+		// Messages that couldn't be checked count as checked too, so they go to the back of the queue
+		var checked []store.Message
+		for _, m := range messages {
+			exists, err := bs.messageExists(target, m)
+			if err != nil {
+				log.Printf("Error checking message %d of chat %d: %v", m.MessageID, m.ChatID, err)
+			}
+			if exists || err != nil {
+				checked = append(checked, m)
+				continue
+			}
+			if _, err := bs.store.MarkMessagesDeleted(m.ChatID, []int{m.MessageID}); err != nil {
+				log.Printf("Error marking message %d of chat %d deleted: %v", m.MessageID, m.ChatID, err)
+			}
+		}
+		if err := bs.store.MarkReconciled(checked); err != nil {
+			log.Printf("Error saving reconciled messages: %v", err)
+		}
+	})
+}
+
+// messageExists forwards a stored message to the scratch chat and deletes the copy; only Telegram's
+// "not found" answer means the message is gone, other errors like the bot leaving the chat are returned
+func (bs *BotService) messageExists(target int64, m store.Message) (bool, error) {
+	forward := tgbotapi.NewForward(target, m.ChatID, m.MessageID)
+	forward.DisableNotification = true
+	copied, err := bs.api.Send(forward)
+	if err != nil {
+		if strings.Contains(err.Error(), "message to forward not found") {
+			return false, nil
+		}
+		return false, err
+	}
+	if _, err := bs.api.Request(tgbotapi.NewDeleteMessage(target, copied.MessageID)); err != nil {
+		log.Printf("failed to delete reconciliation copy in chat %d: %v", target, err)
+	}
+	return true, nil
+}
+
+// handleForgetMessage hides the replied-to message from the archive, for messages deleted before the
+// reconciliation noticed or that shouldn't be summarized
+func (bs *BotService) handleForgetMessage(msg *tgbotapi.Message) {
+	if msg.ReplyToMessage == nil {
+		bs.Reply(msg, forgetMessageUsageMsg)
+		return
+	}
+	n, err := bs.store.MarkMessagesDeleted(msg.Chat.ID, []int{msg.ReplyToMessage.MessageID})
+	if err != nil {
+		log.Printf("Error forgetting message %d of chat %d: %v", msg.ReplyToMessage.MessageID, msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if n == 0 {
+		bs.Reply(msg, notStoredMessageMsg)
+		return
+	}
+	bs.Reply(msg, forgotMessageMsg)
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// referralStartPrefix is the /start payload of referral links, like t.me/bot?start=ref_123
+	referralStartPrefix = "ref_"
+	referralWelcomeMsg  = "🎉 Thanks for joining through an invite!"
+	referralsMsg        = `🤝 Invite friends and earn %d credits for each one who starts me, and for each group that adds me.
+
+Invite a friend: %s
+Add me to a group: %s
+
+You referred %d users and %d groups. Credit balance: %d`
+)
+
+// referralLinks are a user's deep links for inviting people to a DM and for adding the bot to a group
+func (bs *BotService) referralLinks(userID int64) (dm, group string) {
+	username := strings.TrimPrefix(bs.botMention, "@")
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", username, referralStartPrefix, userID),
+		fmt.Sprintf("https://t.me/%s?startgroup=%s%d", username, referralStartPrefix, userID)
+}
+
+// recordReferral credits the referrer of a /start ref_<id> payload. In a DM the user must be new to the bot;
+// in a group it is the /start Telegram sends when the bot is added through a startgroup link.
+func (bs *BotService) recordReferral(msg *tgbotapi.Message, payload string) bool {
+	referrerID, err := strconv.ParseInt(strings.TrimPrefix(payload, referralStartPrefix), 10, 64)
+	if err != nil || referrerID <= 0 || msg.From == nil || referrerID == msg.From.ID {
+		return false
+	}
+
+	kind, referredID := "group", msg.Chat.ID
+	if msg.Chat.IsPrivate() {
+		// Users who already talked to the bot don't count
+		if !bs.store.UserPreferences(msg.From.ID).UpdatedAt.IsZero() {
+			return false
+		}
+		kind, referredID = "user", msg.From.ID
+	}
+
+	recorded, err := bs.store.RecordReferral(kind, referredID, referrerID)
+	if err != nil {
+		log.Printf("Error recording referral of %s %d: %v", kind, referredID, err)
+		return false
+	}
+	if !recorded {
+		return false
+	}
+
+	if reward := bs.config().ReferralRewardCredits; reward > 0 {
+		if err := bs.addCredits(store.CreditScopeUser, referrerID, reward, "referral:"+kind); err != nil {
+			log.Printf("Error crediting referral reward to user %d: %v", referrerID, err)
+		}
+	}
+	if msg.Chat.IsPrivate() {
+		// Remember the user, so starting again with another link isn't a new referral
+		if err := bs.store.UpdateUserPreferences(msg.From.ID, bson.M{"referred_by": referrerID}); err != nil {
+			log.Printf("Error saving referrer of user %d: %v", msg.From.ID, err)
+		}
+	}
+	return true
+}
+
+func (bs *BotService) handleReferrals(msg *tgbotapi.Message) {
+	users, groups, err := bs.store.ReferralCounts(msg.From.ID)
+	if err != nil {
+		log.Printf("Error loading referrals of user %d: %v", msg.From.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	balance := bs.creditBalance(store.CreditScopeUser, msg.From.ID)
+
+	dm, group := bs.referralLinks(msg.From.ID)
+	bs.Reply(msg, fmt.Sprintf(referralsMsg, bs.config().ReferralRewardCredits, dm, group, users, groups, balance))
+}
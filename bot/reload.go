@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+)
+
+// config returns the current configuration; hold on to the result within one request
+// rather than calling it repeatedly, since Reload may swap it in between
+func (bs *BotService) config() *Config {
+	return bs.cfg.Load()
+}
+
+// Reload re-reads the environment, .env, the prompts file and the scam blocklist and applies them
+// without restarting: allowlists, rate limits, disabled commands, prompts, model parameters, alias
+// names and blocked domains take effect for the next update. Connections and listeners are kept,
+// so settings they depend on only change on restart.
+func (bs *BotService) Reload() error {
+	cfg, err := loadConfig(true)
+	if err != nil {
+		return err
+	}
+	if bs.spec != nil {
+		cfg = cfg.forBot(*bs.spec)
+	}
+	prompts, err := loadPrompts(cfg.PromptsFile)
+	if err != nil {
+		return err
+	}
+	blocklist, err := loadScamBlocklist(cfg.ScamBlocklistFile)
+	if err != nil {
+		return err
+	}
+
+	keepRestartOnly(cfg, bs.config())
+	bs.cfg.Store(cfg)
+	bs.prompts.Store(prompts)
+	bs.aliases.Store(newAliasMatcher(cfg.Aliases))
+	bs.scamBlocklist.Store(blocklist)
+	bs.configureModel(cfg.Model)
+	bs.registerBotCommands()
+
+	log.Printf("Configuration reloaded")
+	return nil
+}
+
+// keepRestartOnly copies the settings that need a restart from old to cfg, logging the ones that were changed
+func keepRestartOnly(cfg, old *Config) {
+	keep := func(name string, value *string, previous string) {
+		if *value != previous {
+			log.Printf("%s changed, restart the bot to apply it", name)
+			*value = previous
+		}
+	}
+	keep("TELEGRAM_BOT_TOKEN", &cfg.BotToken, old.BotToken)
+	keep("GEMINI_API_KEY", &cfg.GeminiAPIKey, old.GeminiAPIKey)
+	keep("MONGO_URI", &cfg.MongoURI, old.MongoURI)
+	keep("HTTP_ADDR", &cfg.HTTPAddr, old.HTTPAddr)
+	keep("API_TOKEN", &cfg.APIToken, old.APIToken)
+	keep("DASHBOARD_ADDR", &cfg.DashboardAddr, old.DashboardAddr)
+	keep("DASHBOARD_USER", &cfg.DashboardUser, old.DashboardUser)
+	keep("DASHBOARD_PASSWORD", &cfg.DashboardPassword, old.DashboardPassword)
+	if cfg.StrictPrivacy != old.StrictPrivacy {
+		log.Printf("STRICT_PRIVACY changed, restart the bot to apply it")
+		cfg.StrictPrivacy = old.StrictPrivacy
+	}
+	if !reflect.DeepEqual(cfg.Bots, old.Bots) {
+		log.Printf("BOTS_FILE changed, restart the bot to apply it")
+		cfg.Bots = old.Bots
+	}
+}
+
+// configureModel applies the model parameters if the generator supports changing them
+func (bs *BotService) configureModel(p llm.Params) {
+	if model, ok := bs.gemini.(llm.Configurable); ok {
+		model.Configure(p)
+	}
+}
+
+// ReloadOnSignal reloads the configuration every time the process receives SIGHUP
+func (bs *BotService) ReloadOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			if err := bs.Reload(); err != nil {
+				log.Printf("Error reloading configuration: %v", err)
+			}
+		}
+	}()
+}
+
+func (bs *BotService) handleReload(msg *tgbotapi.Message) {
+	if err := bs.Reload(); err != nil {
+		log.Printf("Error reloading configuration: %v", err)
+		bs.Reply(msg, fmt.Sprintf("❌ Reload failed, the current configuration is kept:\n%v", err))
+		return
+	}
+	bs.Reply(msg, "🔄 Configuration reloaded.")
+}
@@ -0,0 +1,108 @@
+package bot_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/bot/bottest"
+)
+
+var alice = tgbotapi.User{ID: 1, FirstName: "Alice", UserName: "alice"}
+
+// newHarness runs the bot on fakes and a throwaway database on the MongoDB at MONGO_TEST_URI, skipping the
+// test when it isn't set
+func newHarness(t *testing.T) *bottest.Harness {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+
+	s, cleanup, err := bottest.ConnectTestStore(uri)
+	if err != nil {
+		t.Fatalf("connecting to %s: %v", uri, err)
+	}
+	t.Cleanup(cleanup)
+
+	h, err := bottest.New(s)
+	if err != nil {
+		t.Fatalf("creating the bot: %v", err)
+	}
+	return h
+}
+
+// waitForText waits until the bot sent a message to chatID containing want, and returns it
+func waitForText(t *testing.T, h *bottest.Harness, chatID int64, want string) bottest.Sent {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for n := 1; ; n++ {
+		sent, err := h.Telegram.WaitForSent(n, time.Until(deadline))
+		for _, s := range sent {
+			if s.ChatID == chatID && strings.Contains(s.Text, want) {
+				return s
+			}
+		}
+		if err != nil {
+			t.Fatalf("no message to chat %d containing %q, sent: %q", chatID, want, h.Telegram.Texts())
+		}
+	}
+}
+
+func TestReplayCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "start", text: "/start", want: "Hello! I'm ChatBuddy"},
+		{name: "help", text: "/help", want: "/summary"},
+		{name: "help for a command", text: "/help ask", want: "/ask"},
+		{name: "help for an unknown command", text: "/help nope", want: "There's no /nope command."},
+	}
+
+	h := newHarness(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.Telegram.Reset()
+			h.Replay(bottest.Message(bottest.Private(alice), alice, tt.text))
+			waitForText(t, h, alice.ID, tt.want)
+		})
+	}
+}
+
+func TestReplayMention(t *testing.T) {
+	h := newHarness(t)
+	h.Generator.Default = "Paris is the capital of France."
+
+	group := bottest.Group(-100, "Test")
+	update := bottest.Message(group, alice, "@"+bottest.Self.UserName+" what is the capital of France?")
+	h.Replay(update)
+
+	sent := waitForText(t, h, group.ID, "Paris is the capital of France.")
+	reply, ok := sent.Config.(tgbotapi.MessageConfig)
+	if !ok {
+		t.Fatalf("answer was sent as %T, want a tgbotapi.MessageConfig", sent.Config)
+	}
+	if reply.ReplyToMessageID != update.Message.MessageID {
+		t.Errorf("answer replies to message %d, want %d", reply.ReplyToMessageID, update.Message.MessageID)
+	}
+}
+
+func TestReplayDisabledAlias(t *testing.T) {
+	h := newHarness(t)
+	group := bottest.Group(-100, "Test")
+	h.Telegram.SetAdmin(group.ID, alice)
+
+	h.Replay(bottest.Message(group, alice, "/disable ask"))
+	waitForText(t, h, group.ID, "Turned off /ask")
+
+	// The hidden /ai alias is turned off with /ask
+	h.Replay(bottest.Message(group, alice, "/ai what is the capital of France?"))
+	waitForText(t, h, group.ID, "/ai is disabled by this chat's admins.")
+	if prompts := h.Generator.Prompts(); len(prompts) > 0 {
+		t.Errorf("the disabled alias called the model with %q", prompts)
+	}
+}
@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// replyAction is what the bot does with a reply to one of its messages
+type replyAction int
+
+const (
+	replyAnswer replyAction = iota
+	replyReact
+	replyIgnore
+)
+
+// ackReactions maps short acknowledgements to the reaction the bot leaves instead of answering;
+// an empty reaction means the reply is ignored
+var ackReactions = map[string]string{
+	"thanks": "🙏", "thank you": "🙏", "thx": "🙏", "ty": "🙏", "merci": "🙏", "danke": "🙏", "mersi": "🙏", "ممنون": "🙏", "مرسی": "🙏",
+	"ok": "👌", "okay": "👌", "k": "👌", "got it": "👌", "alright": "👌",
+	"nice": "👍", "cool": "👍", "great": "👍", "perfect": "👍", "awesome": "👍", "good bot": "❤",
+	"lol": "", "haha": "", "hahaha": "", "lmao": "", "xd": "",
+}
+
+// replyNeedsAnswer matches replies that are more than an acknowledgement
+var replyNeedsAnswer = regexp.MustCompile(`(?i)[?？؟]|\b(why|how|what|when|where|who|which|can|could|should|would|explain|tell|more|but|and|also)\b`)
+
+// classifyReply decides how to handle a reply to the bot that doesn't mention it. Obvious cases
+// are settled by heuristics; only short, unclear replies cost a model call.
+func (bs *BotService) classifyReply(msg *tgbotapi.Message) (replyAction, string) {
+	text := strings.ToLower(strings.TrimSpace(msg.Text))
+	if text == "" {
+		return replyIgnore, ""
+	}
+
+	normalized := strings.TrimFunc(text, func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSpace(r) })
+	if reaction, ok := ackReactions[normalized]; ok {
+		if reaction == "" {
+			return replyIgnore, ""
+		}
+		return replyReact, reaction
+	}
+	if isEmojiOnly(text) {
+		return replyIgnore, ""
+	}
+	if replyNeedsAnswer.MatchString(text) || len(strings.Fields(text)) > 4 {
+		return replyAnswer, ""
+	}
+	return bs.classifyReplyWithModel(msg)
+}
+
+// classifyReplyWithModel asks the model whether a short reply needs an answer; errors default to answering
+func (bs *BotService) classifyReplyWithModel(msg *tgbotapi.Message) (replyAction, string) {
+	prompt := fmt.Sprintf(`A user replied to a message from a Telegram bot.
+
+Bot message:
+%s
+
+User reply:
+%s
+
+Does the reply need an answer from the bot? Answer with exactly one word:
+- ANSWER if it asks something, continues the conversation or needs a response
+- REACT if it is a simple acknowledgement like thanks or agreement
+- IGNORE if it is laughter, small talk with others or needs nothing`,
+		untrusted("bot message", truncateRunes(msg.ReplyToMessage.Text, 500)), untrusted("reply", msg.Text))
+
+	ctx, cancel := context.WithTimeout(bs.messageContext(msg), 10*time.Second)
+	defer cancel()
+
+	verdict, err := bs.generate(ctx, prompt)
+	if err != nil {
+		log.Printf("gemini reply classification error: %v", err)
+		return replyAnswer, ""
+	}
+
+	switch verdict = strings.ToUpper(verdict); {
+	case strings.Contains(verdict, "IGNORE"):
+		return replyIgnore, ""
+	case strings.Contains(verdict, "REACT"):
+		return replyReact, "👍"
+	default:
+		return replyAnswer, ""
+	}
+}
+
+// isReplyToBot reports whether a message replies to one of the bot's
+func (bs *BotService) isReplyToBot(msg *tgbotapi.Message) bool {
+	return msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil && msg.ReplyToMessage.From.ID == bs.id
+}
+
+// isPlainReplyToBot reports whether a message only reaches the bot by replying to it, without a mention or alias
+func (bs *BotService) isPlainReplyToBot(msg *tgbotapi.Message) bool {
+	if !bs.isReplyToBot(msg) || msg.IsCommand() {
+		return false
+	}
+	return !bs.isBotMentioned(msg.Text) && !bs.isAliasAddressed(msg)
+}
+
+// react sets an emoji reaction on a message
+func (bs *BotService) react(msg *tgbotapi.Message, emoji string) {
+	params := tgbotapi.Params{}
+	params.AddNonZero64("chat_id", msg.Chat.ID)
+	params.AddNonZero("message_id", msg.MessageID)
+	if err := params.AddInterface("reaction", []map[string]string{{"type": "emoji", "emoji": emoji}}); err != nil {
+		log.Printf("failed to encode reaction: %v", err)
+		return
+	}
+	if _, err := bs.api.MakeRequest("setMessageReaction", params); err != nil {
+		log.Printf("failed to react to message %d in chat %d: %v", msg.MessageID, msg.Chat.ID, err)
+	}
+}
+
+func isEmojiOnly(text string) bool {
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// monthlyReportHour is the owner's local hour the report of the past month is sent on the 1st
+	monthlyReportHour = 9
+	maxReportChats    = 5
+)
+
+// sendMonthlyReport DMs the owner a report of the month that just ended, on the first day of the next one
+func (bs *BotService) sendMonthlyReport(now time.Time) {
+	owner := bs.config().OwnerID
+	if owner == 0 {
+		return
+	}
+	local := now.In(bs.userLocation(owner, owner))
+	if local.Day() != 1 || local.Hour() != monthlyReportHour {
+		return
+	}
+	to := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, local.Location())
+	from := to.AddDate(0, -1, 0)
+	if !bs.claimDailyRun("monthly_report", 0, from.Format("2006-01")) {
+		return
+	}
+
+	report, err := bs.monthlyReport(from, to)
+	if err != nil {
+		log.Printf("Error building the monthly report: %v", err)
+		return
+	}
+	bs.sendDM(owner, dmReports, kindNotification, report)
+}
+
+// monthlyReport sums up the chats, stored messages and model usage of [from, to)
+func (bs *BotService) monthlyReport(from, to time.Time) (string, error) {
+	chats, err := bs.store.Chats(true)
+	if err != nil {
+		return "", fmt.Errorf("loading the chats registry: %w", err)
+	}
+	var current, active, joined, left int
+	for _, c := range chats {
+		if c.LeftAt == nil {
+			current++
+			if !c.LastActivityAt.Before(from) {
+				active++
+			}
+		} else if !c.LeftAt.Before(from) && c.LeftAt.Before(to) {
+			left++
+		}
+		if !c.JoinedAt.Before(from) && c.JoinedAt.Before(to) {
+			joined++
+		}
+	}
+
+	messages, err := bs.store.CountMessages(bson.M{"timestamp": bson.M{"$gte": from, "$lt": to}})
+	if err != nil {
+		return "", fmt.Errorf("counting messages: %w", err)
+	}
+	models, err := bs.store.UsageByModel(from, to)
+	if err != nil {
+		return "", fmt.Errorf("loading the model usage: %w", err)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🗓 ChatBuddy report for %s\n\n", from.Format("January 2006"))
+	fmt.Fprintf(&sb, "Chats: %d, %d active this month (%d joined, %d left)\n", current, active, joined, left)
+	fmt.Fprintf(&sb, "Messages stored: %d\n\n", messages)
+	if len(models) == 0 {
+		sb.WriteString("No AI requests this month.")
+		return sb.String(), nil
+	}
+
+	total := sumUsage(models)
+	sb.WriteString(formatUsageTotals(total))
+	fmt.Fprintf(&sb, "\nError rate: %.1f%%", float64(total.Failures)/float64(total.Requests)*100)
+	if line := bs.config().Pricing.costSummary(models); line != "" {
+		sb.WriteString("\n" + line)
+	}
+	if top := bs.topUsageChats(from, to, maxReportChats); top != "" {
+		sb.WriteString("\n\nTop chats by usage:" + top)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// adminCacheTTL is how long a chat's administrator list is reused before asking Telegram again
+const adminCacheTTL = 5 * time.Minute
+
+// Role is what a user may do in a chat; each role includes the ones below it
+type Role int
+
+const (
+	// Member is anyone in the chat
+	Member Role = iota
+	// ChatAdmin is an administrator of the group; in a DM the user counts as admin
+	ChatAdmin
+	// Owner is the operator of the bot, set with OWNER_ID, and counts as admin everywhere
+	Owner
+)
+
+func (r Role) String() string {
+	switch r {
+	case Owner:
+		return "owner"
+	case ChatAdmin:
+		return "admin"
+	default:
+		return "member"
+	}
+}
+
+// adminCache keeps the administrator lists fetched with getChatAdministrators
+type adminCache struct {
+	mu      sync.Mutex
+	entries map[int64]adminCacheEntry
+}
+
+type adminCacheEntry struct {
+	admins  []tgbotapi.ChatMember
+	fetched time.Time
+}
+
+// userRole returns the role of a user in a chat
+func (bs *BotService) userRole(chat *tgbotapi.Chat, user *tgbotapi.User) Role {
+	switch {
+	case user == nil:
+		return Member
+	case bs.config().OwnerID != 0 && user.ID == bs.config().OwnerID:
+		return Owner
+	case chat.IsPrivate() || bs.isChatAdmin(chat.ID, user.ID):
+		return ChatAdmin
+	default:
+		return Member
+	}
+}
+
+// isChatAdmin reports whether a user is the creator or an administrator of a group or channel
+func (bs *BotService) isChatAdmin(chatID, userID int64) bool {
+	admins, err := bs.chatAdmins(chatID)
+	if err != nil {
+		log.Printf("failed to get administrators of chat %d: %v", chatID, err)
+		return false
+	}
+	for _, admin := range admins {
+		if admin.User != nil && admin.User.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// chatAdmins returns the administrators of a chat, cached for adminCacheTTL
+func (bs *BotService) chatAdmins(chatID int64) ([]tgbotapi.ChatMember, error) {
+	bs.admins.mu.Lock()
+	entry, ok := bs.admins.entries[chatID]
+	bs.admins.mu.Unlock()
+	if ok && time.Since(entry.fetched) < adminCacheTTL {
+		return entry.admins, nil
+	}
+
+	admins, err := bs.api.GetChatAdministrators(tgbotapi.ChatAdministratorsConfig{
+		ChatConfig: tgbotapi.ChatConfig{ChatID: chatID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bs.admins.mu.Lock()
+	if bs.admins.entries == nil {
+		bs.admins.entries = make(map[int64]adminCacheEntry)
+	}
+	bs.admins.entries[chatID] = adminCacheEntry{admins: admins, fetched: time.Now()}
+	bs.admins.mu.Unlock()
+	return admins, nil
+}
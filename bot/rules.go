@@ -0,0 +1,256 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	noRulesMsg         = "No rules have been set for this chat yet. Admins can add them with /setrules <rules>."
+	setRulesUsageMsg   = "Usage: /setrules <rules text>, or reply to a message containing the rules with /setrules"
+	rulesSavedMsg      = "Rules saved ✅"
+	adminOnlyMsg       = "Only chat admins can do that."
+	groupOnlyMsg       = "This command only works in group chats."
+	privateOnlyMsg     = "This command only works in a private chat, please DM me."
+	ownerOnlyMsg       = "Only the bot owner can do that."
+	moderationUsageMsg = "Usage: /moderation on|off"
+	moderationOnMsg    = "Moderation mode is on. I'll alert admins about messages that look like rule violations."
+	moderationOffMsg   = "Moderation mode is off."
+
+	// moderationMinScore is the confidence from which a flagged message is reported to admins
+	moderationMinScore = 0.5
+)
+
+// moderationSchema is the model's verdict on a message
+var moderationSchema = &llm.Schema{
+	Type: "object",
+	Properties: map[string]*llm.Schema{
+		"violation": {Type: "boolean"},
+		"score":     {Type: "number", Description: "confidence that the message violates the rules, from 0 to 1"},
+		"rule":      {Type: "string", Description: "the rule it most likely violates, quoted or summarized"},
+		"reason":    {Type: "string"},
+	},
+	Required: []string{"violation", "score"},
+}
+
+// rulesQuestionPattern matches questions about this chat's rules, like "is X allowed here?", "is that against
+// the rules?" or "what do the rules say about ads?", but not "what are the rules of chess?"
+var rulesQuestionPattern = regexp.MustCompile(`(?i)\b(` +
+	`(allowed|permitted|ok|okay) (here|in (this|the) (group|chat))|` +
+	`against (the|our|group|chat) rules|` +
+	`(break|breaks|breaking|violate|violates|violating) (the|our|a|any) (group |chat )?rules?|` +
+	`(the|our|group|chat) rules (say|allow|permit|forbid)|` +
+	`rules (here|(of|for|in) (this|the) (group|chat)))\b`)
+
+func (bs *BotService) handleSetRules(msg *tgbotapi.Message) {
+	rules := strings.TrimSpace(msg.CommandArguments())
+	if rules == "" && msg.ReplyToMessage != nil {
+		rules = strings.TrimSpace(msg.ReplyToMessage.Text)
+	}
+	if rules == "" {
+		bs.Reply(msg, setRulesUsageMsg)
+		return
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"rules": rules}); err != nil {
+		log.Printf("Error saving rules for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, rulesSavedMsg)
+}
+
+// handleRules shows the chat rules, or answers a question about them when one is given
+func (bs *BotService) handleRules(msg *tgbotapi.Message) {
+	settings := bs.store.ChatSettings(msg.Chat.ID)
+	if settings.Rules == "" {
+		bs.Reply(msg, noRulesMsg)
+		return
+	}
+
+	question := strings.TrimSpace(msg.CommandArguments())
+	if question == "" {
+		bs.Reply(msg, "📜 Chat rules:\n\n"+settings.Rules)
+		return
+	}
+	bs.Reply(msg, bs.answerRulesQuestion(msg, settings.Rules, question))
+}
+
+func (bs *BotService) isRulesQuestion(question string) bool {
+	return rulesQuestionPattern.MatchString(question)
+}
+
+func (bs *BotService) answerRulesQuestion(msg *tgbotapi.Message, rules, question string) string {
+	prompt := fmt.Sprintf(`You are the rules assistant of a Telegram group. These are the group rules:
+
+%s
+
+A member asks: "%s"
+
+Answer instructions:
+1. Answer only based on the rules above
+2. Quote or name the rule that applies
+3. If the rules don't cover the question, say so and suggest asking an admin
+4. Keep it brief (2-3 sentences maximum), plain text, no markdown
+5. Response language: Same as the user's message`, rules, question)
+
+	ctx, cancel := context.WithTimeout(bs.billedContext(msg), 60*time.Second)
+	defer cancel()
+
+	answer, err := bs.generate(ctx, prompt)
+	if err != nil {
+		log.Printf("gemini rules answer error: %v", err)
+		return responseErrorMsg
+	}
+	return answer
+}
+
+func (bs *BotService) handleModerationCommand(msg *tgbotapi.Message) {
+	var enabled bool
+	switch strings.ToLower(strings.TrimSpace(msg.CommandArguments())) {
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	default:
+		bs.Reply(msg, moderationUsageMsg)
+		return
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"moderation_enabled": enabled}); err != nil {
+		log.Printf("Error saving moderation setting for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	if enabled {
+		reply := moderationOnMsg
+		if bs.store.ChatSettings(msg.Chat.ID).Rules == "" {
+			reply += "\nTip: set the chat rules with /setrules so I can tell you which rule a message breaks."
+		}
+		bs.Reply(msg, reply)
+		return
+	}
+	bs.Reply(msg, moderationOffMsg)
+}
+
+// moderateMessage checks a group message against the chat rules and alerts admins about likely violations
+func (bs *BotService) moderateMessage(msg *tgbotapi.Message) {
+	if msg.Chat.IsPrivate() || msg.From == nil || msg.Text == "" || msg.IsCommand() {
+		return
+	}
+
+	settings := bs.store.ChatSettings(msg.Chat.ID)
+	if !settings.ModerationEnabled {
+		return
+	}
+
+	// Admins are trusted, skip checking their own messages
+	if bs.isChatAdmin(msg.Chat.ID, msg.From.ID) {
+		return
+	}
+
+	rules := settings.Rules
+	if rules == "" {
+		rules = "No explicit rules. Flag only clear spam, scams, harassment, hate speech or explicit content."
+	}
+
+	prompt := fmt.Sprintf(`You are a moderation assistant for a Telegram group. These are the group rules:
+
+%s
+
+Check this message from @%s:
+%s
+
+Set violation if it breaks a rule, with the rule and a short reason, and score how confident you are.`,
+		rules, msg.From.UserName, untrusted("message", msg.Text))
+
+	ctx, cancel := context.WithTimeout(bs.messageContext(msg), 30*time.Second)
+	defer cancel()
+
+	var verdict struct {
+		Violation bool    `json:"violation"`
+		Score     float64 `json:"score"`
+		Rule      string  `json:"rule"`
+		Reason    string  `json:"reason"`
+	}
+	validate := func() error {
+		if verdict.Score < 0 || verdict.Score > 1 {
+			return fmt.Errorf("score %v is not between 0 and 1", verdict.Score)
+		}
+		return nil
+	}
+	if err := bs.generateJSON(ctx, prompt, moderationSchema, &verdict, validate); err != nil {
+		log.Printf("gemini moderation error: %v", err)
+		return
+	}
+	if !verdict.Violation || verdict.Score < moderationMinScore {
+		return
+	}
+	rule := cmp.Or(strings.TrimSpace(verdict.Rule), "unspecified")
+	reason := strings.TrimSpace(verdict.Reason)
+	bs.alertAdmins(msg, rule, reason)
+}
+
+// alertAdmins sends a moderation alert to every human admin of the chat via DM
+func (bs *BotService) alertAdmins(msg *tgbotapi.Message, rule, reason string) {
+	admins, err := bs.chatAdmins(msg.Chat.ID)
+	if err != nil {
+		log.Printf("failed to get administrators of chat %d: %v", msg.Chat.ID, err)
+		return
+	}
+
+	alert := fmt.Sprintf("⚠️ Possible rule violation in %s\n\nFrom: %s\nMessage: %s\nLikely rule: %s",
+		msg.Chat.Title, displayName(msg.From), msg.Text, rule)
+	if reason != "" {
+		alert += "\nReason: " + reason
+	}
+	if link := messageLink(msg.Chat, msg.MessageID); link != "" {
+		alert += "\n" + link
+	}
+
+	for _, admin := range admins {
+		if admin.User == nil || admin.User.IsBot {
+			continue
+		}
+		// Admins who never started a DM with the bot can't be reached, send logs it
+		bs.sendDM(admin.User.ID, dmAlerts, kindNotification, alert)
+	}
+}
+
+// displayName formats a Telegram user as @username or their full name
+func displayName(user *tgbotapi.User) string {
+	if user == nil {
+		return "Unknown"
+	}
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+	name := user.FirstName
+	if user.LastName != "" {
+		name += " " + user.LastName
+	}
+	return name
+}
+
+// messageLink builds a t.me link to a message in a public or private supergroup
+func messageLink(chat *tgbotapi.Chat, messageID int) string {
+	if chat.UserName != "" {
+		return fmt.Sprintf("https://t.me/%s/%d", chat.UserName, messageID)
+	}
+	id := strconv.FormatInt(chat.ID, 10)
+	if !strings.HasPrefix(id, "-100") {
+		return "" // Basic groups have no message links
+	}
+	return fmt.Sprintf("https://t.me/c/%s/%d", strings.TrimPrefix(id, "-100"), messageID)
+}
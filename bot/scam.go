@@ -0,0 +1,210 @@
+package bot
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+)
+
+const (
+	safeBrowsingURL = "https://safebrowsing.googleapis.com/v4/threatMatches:find"
+)
+
+// cryptoScamPattern matches the wording of typical crypto giveaway and wallet phishing messages,
+// which are then judged by the model
+var cryptoScamPattern = regexp.MustCompile(`(?i)\b(giveaway|airdrop|double your|free (btc|eth|usdt|crypto)|send \d+(\.\d+)? ?(btc|eth|usdt|ton|bnb)|seed phrase|recovery phrase|connect your wallet|claim your (reward|tokens?)|guaranteed (profit|returns?)|investment plan)\b`)
+
+// domainSet is a set of blocked domains; subdomains of a blocked domain are blocked too
+type domainSet map[string]bool
+
+func (s domainSet) contains(host string) bool {
+	for host != "" {
+		if s[host] {
+			return true
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			return false
+		}
+		host = parent
+	}
+	return false
+}
+
+// loadScamBlocklist reads a blocklist with one domain per line; # starts a comment. An empty path means no blocklist.
+func loadScamBlocklist(path string) (*domainSet, error) {
+	domains := domainSet{}
+	if path == "" {
+		return &domains, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scam blocklist: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if domain := linkHost(strings.TrimSpace(line)); domain != "" {
+			domains[domain] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read scam blocklist: %w", err)
+	}
+	return &domains, nil
+}
+
+// checkScam alerts the admins of moderation-enabled chats about links on the blocklist or flagged by
+// Safe Browsing, and about messages the model judges to be crypto giveaway or phishing scams
+func (bs *BotService) checkScam(msg *tgbotapi.Message) {
+	if msg.Chat.IsPrivate() || msg.From == nil {
+		return
+	}
+	links := messageLinks(msg)
+	text := strings.TrimSpace(msg.Text + "\n" + msg.Caption)
+	suspicious := cryptoScamPattern.MatchString(text)
+	if len(links) == 0 && !suspicious {
+		return
+	}
+	if !bs.store.ChatSettings(msg.Chat.ID).ModerationEnabled || bs.isChatAdmin(msg.Chat.ID, msg.From.ID) {
+		return
+	}
+
+	if blocklist := bs.scamBlocklist.Load(); blocklist != nil {
+		for _, link := range links {
+			if host := linkHost(link); blocklist.contains(host) {
+				bs.alertAdmins(msg, "Scam or phishing link", host+" is on the scam blocklist")
+				return
+			}
+		}
+	}
+
+	if key := bs.config().SafeBrowsingAPIKey; key != "" && len(links) > 0 {
+		threat, err := safeBrowsingLookup(key, links)
+		if err != nil {
+			log.Printf("Error checking links with Safe Browsing: %v", err)
+		} else if threat != "" {
+			bs.alertAdmins(msg, "Scam or phishing link", "Safe Browsing reports "+threat)
+			return
+		}
+	}
+
+	if suspicious {
+		if reason := bs.assessScam(text); reason != "" {
+			bs.alertAdmins(msg, "Likely scam", reason)
+		}
+	}
+}
+
+// scamSchema is the model's verdict on a message
+var scamSchema = &llm.Schema{
+	Type: "object",
+	Properties: map[string]*llm.Schema{
+		"scam":   {Type: "boolean"},
+		"reason": {Type: "string"},
+	},
+	Required: []string{"scam"},
+}
+
+// assessScam asks the model whether a message is a scam and returns the reason if it is
+func (bs *BotService) assessScam(text string) string {
+	prompt := fmt.Sprintf(`You protect a Telegram group from scams. Is this message a scam, such as a fake crypto giveaway,
+an airdrop or wallet phishing, a "double your money" offer or an investment fraud?
+
+Message:
+%s
+
+Set scam if it likely is one, with a short reason.`, untrusted("message", truncateRunes(text, 2000)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var verdict struct {
+		Scam   bool   `json:"scam"`
+		Reason string `json:"reason"`
+	}
+	if err := bs.generateJSON(ctx, prompt, scamSchema, &verdict, nil); err != nil {
+		log.Printf("gemini scam assessment error: %v", err)
+		return ""
+	}
+	if !verdict.Scam {
+		return ""
+	}
+	return cmp.Or(strings.TrimSpace(verdict.Reason), "the message looks like a scam")
+}
+
+// safeBrowsingLookup checks links with the Google Safe Browsing Lookup API and returns the first threat type found
+func safeBrowsingLookup(apiKey string, links []string) (string, error) {
+	type entry struct {
+		URL string `json:"url"`
+	}
+	var entries []entry
+	for _, link := range links {
+		if !strings.Contains(link, "://") {
+			link = "http://" + link
+		}
+		entries = append(entries, entry{URL: link})
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"client": map[string]string{"clientId": "chatbuddy", "clientVersion": "1.0"},
+		"threatInfo": map[string]any{
+			"threatTypes":      []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"},
+			"platformTypes":    []string{"ANY_PLATFORM"},
+			"threatEntryTypes": []string{"URL"},
+			"threatEntries":    entries,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, safeBrowsingURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// The key goes in a header rather than the URL, so it never shows up in logged errors
+	req.Header.Set("X-Goog-Api-Key", apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("safe browsing returned %s", resp.Status)
+	}
+
+	var result struct {
+		Matches []struct {
+			ThreatType string `json:"threatType"`
+		} `json:"matches"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if len(result.Matches) == 0 {
+		return "", nil
+	}
+	return strings.ToLower(strings.ReplaceAll(result.Matches[0].ThreatType, "_", " ")), nil
+}
@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const schedulerInterval = time.Minute
+
+// scheduledJob is a periodic task run by the scheduler on every tick
+type scheduledJob struct {
+	name string
+	run  func(now time.Time)
+}
+
+func (bs *BotService) scheduledJobs() []scheduledJob {
+	return []scheduledJob{
+		{name: "event_reminders", run: bs.sendEventReminders},
+		{name: "birthday_greetings", run: bs.sendBirthdayGreetings},
+		{name: "feed_poll", run: bs.pollFeeds},
+		{name: "feed_digests", run: bs.sendFeedDigests},
+		{name: "deferred_messages", run: bs.sendDeferredMessages},
+		{name: "backups", run: bs.runScheduledBackups},
+		{name: "file_cleanup", run: bs.cleanupFiles},
+		{name: "topics", run: bs.updateTopics},
+		{name: "auto_tags", run: bs.updateTags},
+		{name: "weekly_wrapped", run: bs.sendWeeklyWrapped},
+		{name: "reconcile_messages", run: bs.reconcileMessages},
+		{name: "chat_policies", run: bs.enforceChatPolicies},
+		{name: "budget", run: bs.checkBudget},
+		{name: "monthly_report", run: bs.sendMonthlyReport},
+	}
+}
+
+// runScheduler runs all scheduled jobs once per schedulerInterval until the process exits
+func (bs *BotService) runScheduler() {
+	jobs := bs.scheduledJobs()
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for _, job := range jobs {
+			bs.runJob(job, now)
+		}
+	}
+}
+
+func (bs *BotService) runJob(job scheduledJob, now time.Time) {
+	defer bs.recoverPanic("scheduled job " + job.name)
+	if !bs.claimJobRun(job.name) {
+		return
+	}
+	job.run(now)
+}
+
+// claimDailyRun records that a job ran for a chat on a given day, returning false if it already did.
+// This keeps daily posts from being sent twice across ticks and restarts.
+func (bs *BotService) claimDailyRun(job string, chatID int64, day string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	key := bson.M{"job": job, "chat_id": chatID, "day": day}
+	res, err := bs.db.Collection("job_runs").UpdateOne(ctx, key,
+		bson.M{"$setOnInsert": bson.M{"ran_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("Error claiming %s run for chat %d: %v", job, chatID, err)
+		return false
+	}
+	return res.UpsertedCount == 1
+}
@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/store"
+	"github.com/sg-milad/ChatBuddy/vectorstore"
+)
+
+const (
+	searchResults  = 8
+	searchUsageMsg = "Usage: /search <what you're looking for>, like /search when is the team dinner"
+	noResultsMsg   = "I found nothing matching that in this chat."
+)
+
+// newVectorStore opens the vector store selected by VECTOR_STORE
+func newVectorStore(cfg *Config, db *store.Store) (vectorstore.VectorStore, error) {
+	switch cfg.VectorStore {
+	case "qdrant":
+		return &vectorstore.Qdrant{URL: cfg.QdrantURL, APIKey: cfg.QdrantAPIKey, Collection: cfg.QdrantCollection}, nil
+	case "pgvector":
+		return &vectorstore.Postgres{URL: cfg.PgvectorURL}, nil
+	case "mongo", "":
+		return vectorstore.NewMongo(db.DB()), nil
+	default:
+		return nil, fmt.Errorf("unknown vector store %q", cfg.VectorStore)
+	}
+}
+
+// semanticSearch finds the chat messages closest in meaning to query. It returns false when the query
+// couldn't be embedded or nothing is embedded yet, so callers can fall back to full-text search.
+func (bs *BotService) semanticSearch(ctx context.Context, chatID int64, query string, limit int) ([]store.Message, bool) {
+	embedder, ok := bs.gemini.(llm.Embedder)
+	if !ok {
+		return nil, false
+	}
+	vector, err := embedder.EmbedQuery(ctx, bs.config().EmbeddingModel, query)
+	if err != nil {
+		log.Printf("Error embedding search query: %v", err)
+		return nil, false
+	}
+	matches, err := bs.vectors.Query(ctx, chatID, vector, limit)
+	if err != nil {
+		log.Printf("Error querying the %s vector store: %v", bs.config().VectorStore, err)
+		return nil, false
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	ids := make([]int, len(matches))
+	for i, m := range matches {
+		ids[i] = m.MessageID
+	}
+	messages, err := bs.store.MessagesByID(chatID, ids)
+	if err != nil {
+		log.Printf("Error loading search results: %v", err)
+		return nil, false
+	}
+	return messages, true
+}
+
+// searchMessages tries semantic search and falls back to MongoDB full-text search
+func (bs *BotService) searchMessages(ctx context.Context, chatID int64, query string, limit int) ([]store.Message, error) {
+	if messages, ok := bs.semanticSearch(ctx, chatID, query, limit); ok {
+		return messages, nil
+	}
+	return bs.store.SearchMessages(chatID, query, limit)
+}
+
+func (bs *BotService) handleSearch(msg *tgbotapi.Message) {
+	query := strings.TrimSpace(msg.CommandArguments())
+	if query == "" {
+		bs.Reply(msg, searchUsageMsg)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	messages, err := bs.searchMessages(ctx, msg.Chat.ID, query, searchResults)
+	if err != nil {
+		log.Printf("Error searching messages: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(messages) == 0 {
+		bs.Reply(msg, noResultsMsg)
+		return
+	}
+
+	loc := bs.chatLocation(msg.Chat.ID)
+	var sb strings.Builder
+	sb.WriteString("🔎 Found:\n")
+	for _, m := range messages {
+		sb.WriteString(fmt.Sprintf("\n%s, %s: %s", m.Author(), m.Timestamp.In(loc).Format("Jan 2 15:04"), truncateRunes(m.Text, 200)))
+		if link := messageLink(msg.Chat, m.MessageID); link != "" {
+			sb.WriteString("\n" + link)
+		}
+		sb.WriteString("\n")
+	}
+	bs.Reply(msg, sb.String())
+}
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/eventbus"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// messageKind groups the bot's messages for the send options of a chat
+type messageKind string
+
+const (
+	// kindAnswer is anything sent in response to a user: answers, command replies and notices
+	kindAnswer messageKind = "answers"
+	// kindDigest is a post nobody asked for: digests, reminders and greetings
+	kindDigest messageKind = "digests"
+	// kindNotification is a message pushed by an integration, like GitHub events or webhooks
+	kindNotification messageKind = "notifications"
+)
+
+var messageKinds = []messageKind{kindAnswer, kindDigest, kindNotification}
+
+// defaultSendOptions apply when a chat hasn't changed a kind's options
+var defaultSendOptions = map[messageKind]store.SendOptions{
+	kindAnswer:       {Silent: boolPtr(false), Previews: boolPtr(false)},
+	kindDigest:       {Silent: boolPtr(true), Previews: boolPtr(false)},
+	kindNotification: {Silent: boolPtr(false), Previews: boolPtr(true)},
+}
+
+const sendOptionsUsageMsg = `Usage:
+/sendoptions <answers|digests|notifications> silent on|off - send without a notification sound
+/sendoptions <answers|digests|notifications> previews on|off - show link previews
+/sendoptions <answers|digests|notifications> default - use the defaults again`
+
+func boolPtr(b bool) *bool { return &b }
+
+// sendOptions returns the effective options of a message kind in a chat
+func (bs *BotService) sendOptions(chatID int64, kind messageKind) (silent, previews bool) {
+	defaults := defaultSendOptions[kind]
+	silent, previews = *defaults.Silent, *defaults.Previews
+
+	custom := bs.store.ChatSettings(chatID).SendOptions[string(kind)]
+	if custom.Silent != nil {
+		silent = *custom.Silent
+	}
+	if custom.Previews != nil {
+		previews = *custom.Previews
+	}
+	return silent, previews
+}
+
+// sendAs sends a message with the chat's send options for its kind and returns the first chunk sent. Digests
+// and notifications are queued while the chat is throttled, and an empty message is returned for them.
+func (bs *BotService) sendAs(kind messageKind, response tgbotapi.MessageConfig) tgbotapi.Message {
+	if kind != kindAnswer && bs.enqueue(kind, response, nil) {
+		return tgbotapi.Message{}
+	}
+	return bs.sendNow(kind, response)
+}
+
+// sendLater sends a digest or notification now, or once its chat accepts messages again, and calls then with
+// the first chunk sent
+func (bs *BotService) sendLater(kind messageKind, response tgbotapi.MessageConfig, then func(tgbotapi.Message)) {
+	if !bs.enqueue(kind, response, then) {
+		then(bs.sendNow(kind, response))
+	}
+}
+
+// sendNow sends a message with the chat's send options for its kind, bypassing the send queue
+func (bs *BotService) sendNow(kind messageKind, response tgbotapi.MessageConfig) tgbotapi.Message {
+	// Answers to a request cancelled with /cancel, including its error message, are dropped
+	if response.ReplyToMessageID != 0 && bs.isCancelled(response.ChatID, response.ReplyToMessageID) {
+		return tgbotapi.Message{}
+	}
+	silent, previews := bs.sendOptions(response.ChatID, kind)
+	response.DisableNotification = silent
+	response.DisableWebPagePreview = !previews
+	sent := bs.send(response)
+	if sent.MessageID == 0 && kind == kindAnswer {
+		// Answers the group can't get go to whoever asked
+		bs.replyInDM(response)
+	}
+	if sent.MessageID != 0 {
+		bs.publish(eventbus.Event{
+			Type:      eventbus.ResponseSent,
+			ChatID:    response.ChatID,
+			MessageID: sent.MessageID,
+			ReplyTo:   response.ReplyToMessageID,
+			Text:      response.Text,
+			Kind:      string(kind),
+		})
+	}
+	return sent
+}
+
+func (bs *BotService) handleSendOptions(msg *tgbotapi.Message) {
+	args := strings.Fields(strings.ToLower(msg.CommandArguments()))
+	if len(args) == 0 {
+		bs.Reply(msg, bs.sendOptionsStatus(msg.Chat.ID)+"\n\n"+sendOptionsUsageMsg)
+		return
+	}
+
+	kind := messageKind(args[0])
+	if _, ok := defaultSendOptions[kind]; !ok || len(args) < 2 {
+		bs.Reply(msg, sendOptionsUsageMsg)
+		return
+	}
+
+	var change bson.M
+	field := "send_options." + string(kind)
+	switch {
+	case args[1] == "default":
+		change = bson.M{field: nil}
+	case len(args) == 3 && (args[1] == "silent" || args[1] == "previews") && (args[2] == "on" || args[2] == "off"):
+		change = bson.M{field + "." + args[1]: args[2] == "on"}
+	default:
+		bs.Reply(msg, sendOptionsUsageMsg)
+		return
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, change); err != nil {
+		log.Printf("Error saving send options for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, "Saved ✅\n\n"+bs.sendOptionsStatus(msg.Chat.ID))
+}
+
+func (bs *BotService) sendOptionsStatus(chatID int64) string {
+	onOff := map[bool]string{true: "on", false: "off"}
+	var sb strings.Builder
+	sb.WriteString("Send options:\n")
+	for _, kind := range messageKinds {
+		silent, previews := bs.sendOptions(chatID, kind)
+		sb.WriteString(fmt.Sprintf("- %s: silent %s, previews %s\n", kind, onOff[silent], onOff[previews]))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,161 @@
+package bot
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// maxQueuedPerChat caps the posts held back for one chat; more are sent right away
+	maxQueuedPerChat   = 50
+	sendQueueInterval  = time.Second
+	maxRateLimitedWait = 10 * time.Minute
+)
+
+// queuedMessage is a digest or notification waiting for its chat to accept messages again
+type queuedMessage struct {
+	kind     messageKind
+	response tgbotapi.MessageConfig
+	// then is called with the message once it was sent, may be nil
+	then     func(tgbotapi.Message)
+	queuedAt time.Time
+}
+
+// sendQueue holds back the posts nobody is waiting for while a chat is throttled, by Telegram's slow mode or
+// a flood limit, so answers to users get its messages first. It lives in memory: posts still queued when the
+// bot stops are lost.
+type sendQueue struct {
+	mu     sync.Mutex
+	byChat map[int64][]queuedMessage
+	// limitedUntil is when Telegram allows messages to a chat again after a flood limit
+	limitedUntil map[int64]time.Time
+	// lastSent is when the bot last posted in a chat, for slow mode
+	lastSent map[int64]time.Time
+	depth    int
+}
+
+// throttledFor returns how long the bot should wait before posting in a chat
+func (bs *BotService) throttledFor(chatID int64, now time.Time) time.Duration {
+	q := &bs.sendQueue
+	q.mu.Lock()
+	wait := q.limitedUntil[chatID].Sub(now)
+	last := q.lastSent[chatID]
+	q.mu.Unlock()
+
+	// Slow mode only applies to groups, whose IDs are negative
+	if chatID < 0 && !last.IsZero() {
+		if delay := time.Duration(bs.chatInfo(chatID).SlowModeDelay) * time.Second; delay > 0 {
+			wait = max(wait, last.Add(delay).Sub(now))
+		}
+	}
+	return max(wait, 0)
+}
+
+// noteSent records that the bot posted in a chat, starting its slow mode delay
+func (bs *BotService) noteSent(chatID int64) {
+	q := &bs.sendQueue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.lastSent == nil {
+		q.lastSent = map[int64]time.Time{}
+	}
+	q.lastSent[chatID] = time.Now()
+}
+
+// noteRateLimited records that Telegram refuses messages to a chat for retryAfter seconds
+func (bs *BotService) noteRateLimited(chatID int64, retryAfter int) {
+	bs.metrics.rateLimited.Add(1)
+	q := &bs.sendQueue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.limitedUntil == nil {
+		q.limitedUntil = map[int64]time.Time{}
+	}
+	until := time.Now().Add(min(time.Duration(retryAfter)*time.Second, maxRateLimitedWait))
+	if until.After(q.limitedUntil[chatID]) {
+		q.limitedUntil[chatID] = until
+	}
+}
+
+// enqueue holds back a digest or notification while its chat is throttled or has posts queued before it, and
+// reports whether it did; those not held back are for the caller to send
+func (bs *BotService) enqueue(kind messageKind, response tgbotapi.MessageConfig, then func(tgbotapi.Message)) bool {
+	now := time.Now()
+	throttled := bs.throttledFor(response.ChatID, now) > 0
+
+	q := &bs.sendQueue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	queued := q.byChat[response.ChatID]
+	if (!throttled && len(queued) == 0) || len(queued) >= maxQueuedPerChat {
+		return false
+	}
+	if q.byChat == nil {
+		q.byChat = map[int64][]queuedMessage{}
+	}
+	q.byChat[response.ChatID] = append(queued, queuedMessage{kind: kind, response: response, then: then, queuedAt: now})
+	q.depth++
+	return true
+}
+
+// queueDepth is the number of posts held back across chats
+func (bs *BotService) queueDepth() int {
+	bs.sendQueue.mu.Lock()
+	defer bs.sendQueue.mu.Unlock()
+	return bs.sendQueue.depth
+}
+
+// runSendQueue sends the oldest held-back post of every chat that accepts messages again, one per chat and
+// round so a chat's slow mode starts over after each
+func (bs *BotService) runSendQueue() {
+	ticker := time.NewTicker(sendQueueInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		bs.sendQueue.mu.Lock()
+		var chatIDs []int64
+		for chatID := range bs.sendQueue.byChat {
+			chatIDs = append(chatIDs, chatID)
+		}
+		bs.sendQueue.mu.Unlock()
+
+		now := time.Now()
+		for _, chatID := range chatIDs {
+			if bs.throttledFor(chatID, now) > 0 {
+				continue
+			}
+			m, ok := bs.dequeue(chatID)
+			if !ok {
+				continue
+			}
+			if waited := time.Since(m.queuedAt); waited > time.Minute {
+				log.Printf("Sending a %s post to chat %d after holding it back for %s", m.kind, chatID, waited.Round(time.Second))
+			}
+			sent := bs.sendNow(m.kind, m.response)
+			if m.then != nil {
+				m.then(sent)
+			}
+		}
+	}
+}
+
+// dequeue takes the oldest post held back for a chat
+func (bs *BotService) dequeue(chatID int64) (queuedMessage, bool) {
+	q := &bs.sendQueue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	queued := q.byChat[chatID]
+	if len(queued) == 0 {
+		return queuedMessage{}, false
+	}
+	if len(queued) == 1 {
+		delete(q.byChat, chatID)
+	} else {
+		q.byChat[chatID] = queued[1:]
+	}
+	q.depth--
+	return queued[0], true
+}
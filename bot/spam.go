@@ -0,0 +1,159 @@
+package bot
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Spam actions of a chat, stored in store.ChatSettings.SpamAction
+const (
+	spamActionOff    = ""
+	spamActionFlag   = "flag"
+	spamActionDelete = "delete"
+)
+
+const (
+	// spamWindow is how far back repeated messages are looked for
+	spamWindow = 10 * time.Minute
+	// spamRepeats is how often a user may post the same message in one chat within spamWindow before it counts as spam
+	spamRepeats = 3
+	// spamMinLength keeps short messages like "ok" or "lol" from ever counting as repeats
+	spamMinLength = 12
+
+	spamUsageMsg = `Usage:
+/spam flag - alert admins about repeated or cross-posted messages
+/spam delete - delete them
+/spam off - do nothing (default)
+A message is spam when its sender posts it %d times within %d minutes, or into several chats I'm in.`
+)
+
+// spamHit is one message a user posted, by text hash
+type spamHit struct {
+	hash   [sha256.Size]byte
+	chatID int64
+	at     time.Time
+}
+
+// spamDetector remembers what each user posted within spamWindow, across all chats, and counts what it caught
+type spamDetector struct {
+	mu      sync.Mutex
+	hits    map[int64][]spamHit
+	records int
+
+	flagged atomic.Int64
+	deleted atomic.Int64
+}
+
+// record adds a message and reports whether it is spam: the same text posted spamRepeats times into
+// one chat, or into more than one chat, within spamWindow
+func (d *spamDetector) record(userID, chatID int64, hash [sha256.Size]byte, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.hits == nil {
+		d.hits = make(map[int64][]spamHit)
+	}
+	// Sweep users who went quiet now and then so the map doesn't grow forever
+	if d.records++; d.records%1000 == 0 {
+		for id, hits := range d.hits {
+			if len(hits) == 0 || now.Sub(hits[len(hits)-1].at) > spamWindow {
+				delete(d.hits, id)
+			}
+		}
+	}
+
+	var kept []spamHit
+	sameChat, otherChats := 1, 0
+	for _, hit := range d.hits[userID] {
+		if now.Sub(hit.at) > spamWindow {
+			continue
+		}
+		kept = append(kept, hit)
+		if hit.hash == hash {
+			if hit.chatID == chatID {
+				sameChat++
+			} else {
+				otherChats++
+			}
+		}
+	}
+	d.hits[userID] = append(kept, spamHit{hash: hash, chatID: chatID, at: now})
+	return sameChat >= spamRepeats || otherChats > 0
+}
+
+// spamHash hashes a message's text with case and whitespace normalized; ok is false for short messages
+func spamHash(msg *tgbotapi.Message) (hash [sha256.Size]byte, ok bool) {
+	text := strings.ToLower(strings.Join(strings.Fields(msg.Text+" "+msg.Caption), " "))
+	if len([]rune(text)) < spamMinLength {
+		return hash, false
+	}
+	return sha256.Sum256([]byte(text)), true
+}
+
+// spamFilter flags or deletes repeated and cross-posted messages, as the chat's spam policy says;
+// deleted messages go no further
+func (bs *BotService) spamFilter(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		msg := update.Message
+		if msg == nil || msg.From == nil || msg.Chat.IsPrivate() || msg.IsCommand() {
+			next(update)
+			return
+		}
+		hash, ok := spamHash(msg)
+		if !ok || !bs.spam.record(msg.From.ID, msg.Chat.ID, hash, time.Now()) {
+			next(update)
+			return
+		}
+
+		settings := bs.store.ChatSettings(msg.Chat.ID)
+		if settings.SpamAction == spamActionOff || bs.isChatAdmin(msg.Chat.ID, msg.From.ID) {
+			next(update)
+			return
+		}
+
+		if settings.SpamAction == spamActionDelete {
+			if _, err := bs.api.Request(tgbotapi.NewDeleteMessage(msg.Chat.ID, msg.MessageID)); err != nil {
+				log.Printf("failed to delete message %d in chat %d: %v", msg.MessageID, msg.Chat.ID, err)
+			} else {
+				bs.spam.deleted.Add(1)
+				bs.sendResponse(tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("🚫 Removed a repeated message from %s.", displayName(msg.From))))
+				return
+			}
+		}
+
+		bs.spam.flagged.Add(1)
+		bs.alertAdmins(msg, "Spam", "the same message was posted repeatedly or into several chats")
+		next(update)
+	}
+}
+
+func (bs *BotService) handleSpamCommand(msg *tgbotapi.Message) {
+	action := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+	var reply string
+	switch action {
+	case spamActionFlag:
+		reply = "I'll alert admins about repeated and cross-posted messages."
+	case spamActionDelete:
+		reply = "I'll delete repeated and cross-posted messages."
+	case "off":
+		action, reply = spamActionOff, "Spam detection is off."
+	default:
+		bs.Reply(msg, fmt.Sprintf(spamUsageMsg, spamRepeats, int(spamWindow.Minutes())))
+		return
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"spam_action": action}); err != nil {
+		log.Printf("Error saving spam action for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, reply)
+}
@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/sg-milad/ChatBuddy/llm"
+)
+
+// jsonAttempts is how many times generateJSON asks before giving up on malformed or invalid answers
+const jsonAttempts = 2
+
+// generateJSON asks the model for a JSON answer matching schema and decodes it into out. Malformed JSON, or
+// an answer validate rejects, is asked for again with the error, up to jsonAttempts times in all; validate
+// may be nil.
+func (bs *BotService) generateJSON(ctx context.Context, prompt string, schema *llm.Schema, out any, validate func() error) error {
+	ctx = llm.WithJSONSchema(ctx, schema)
+	var invalid error
+	for range jsonAttempts {
+		attempt := prompt
+		if invalid != nil {
+			// The error may quote the answer, which can echo the untrusted content of the prompt
+			attempt += fmt.Sprintf("\n\nYour previous answer was invalid:\n%s\nAnswer again with JSON that matches the schema.",
+				untrusted("validation error", invalid.Error()))
+		}
+		text, err := bs.generate(ctx, attempt)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(text)), out); err != nil {
+			invalid = fmt.Errorf("malformed JSON: %w", err)
+			log.Printf("Retrying a structured answer: %v", invalid)
+			continue
+		}
+		if validate != nil {
+			if err := validate(); err != nil {
+				invalid = err
+				log.Printf("Retrying a structured answer: %v", invalid)
+				continue
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("no valid structured answer after %d attempts: %w", jsonAttempts, invalid)
+}
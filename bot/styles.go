@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// answerStyle shapes answers to mentions: Length and Tone replace the default guidelines of the response prompt,
+// and MaxOutputTokens, when set, replaces GEMINI_MAX_OUTPUT_TOKENS. Edgy styles are off in family chats.
+type answerStyle struct {
+	Name            string
+	Description     string
+	Length          string
+	Tone            string
+	MaxOutputTokens int32
+	Edgy            bool
+}
+
+// defaultStyle is the one chats get until they pick another
+var defaultStyle = answerStyle{
+	Length: "Keep all responses brief and concise (2-3 sentences maximum)",
+	Tone:   "Be conversational and friendly",
+}
+
+// answerStyles are the presets /style and the !style suffix choose from, in /style's order
+var answerStyles = []answerStyle{
+	{Name: "concise", Description: "one or two sentences",
+		Length: "Answer in one or two short sentences, nothing more",
+		Tone:   "Be direct", MaxOutputTokens: 256},
+	{Name: "detailed", Description: "a thorough explanation with examples",
+		Length: "Give a thorough answer in a few short paragraphs, with an example where it helps",
+		Tone:   "Be clear and friendly", MaxOutputTokens: 2048},
+	{Name: "eli5", Description: "explained like you're five",
+		Length: "Explain it like to a five-year-old in 3-5 short sentences, with a simple analogy and no jargon",
+		Tone:   "Be warm and patient", MaxOutputTokens: 512},
+	{Name: "formal", Description: "a professional tone",
+		Length: "Answer in 2-4 complete sentences",
+		Tone:   "Use a formal, professional tone without slang or jokes", MaxOutputTokens: 512},
+	{Name: "sarcastic", Description: "dry wit, still correct",
+		Length: "Keep it to 2-3 sentences",
+		Tone:   "Be playfully sarcastic and dry, but never mean, and still give the correct answer", MaxOutputTokens: 512,
+		Edgy: true},
+}
+
+// styleSuffixRe matches a "!eli5" style suffix at the end of a question
+var styleSuffixRe = regexp.MustCompile(`\s*!(\w+)\s*$`)
+
+func findStyle(name string) (answerStyle, bool) {
+	i := slices.IndexFunc(answerStyles, func(s answerStyle) bool { return s.Name == strings.ToLower(name) })
+	if i < 0 {
+		return answerStyle{}, false
+	}
+	return answerStyles[i], true
+}
+
+// chatStyle returns the style a chat picked with /style, or the default
+func (bs *BotService) chatStyle(chatID int64) answerStyle {
+	if style, ok := findStyle(bs.store.ChatSettings(chatID).Style); ok && !(style.Edgy && bs.familyChat(chatID)) {
+		return style
+	}
+	return defaultStyle
+}
+
+// questionStyle strips a style suffix like "!eli5" from a question and returns the style it asks for,
+// or the chat's style when there is none
+func (bs *BotService) questionStyle(chatID int64, question string) (string, answerStyle) {
+	if m := styleSuffixRe.FindStringSubmatch(question); m != nil {
+		if style, ok := findStyle(m[1]); ok && !(style.Edgy && bs.familyChat(chatID)) {
+			return strings.TrimSpace(strings.TrimSuffix(question, m[0])), style
+		}
+	}
+	return question, bs.chatStyle(chatID)
+}
+
+func styleUsage() string {
+	var sb strings.Builder
+	sb.WriteString("Usage: /style <style>|default\n")
+	for _, s := range answerStyles {
+		fmt.Fprintf(&sb, "\n%s - %s", s.Name, s.Description)
+	}
+	sb.WriteString("\n\nAdd a style to one question instead with a suffix, like \"how do vaccines work? !eli5\".")
+	return sb.String()
+}
+
+// handleStyle shows or sets the chat's answer style
+func (bs *BotService) handleStyle(msg *tgbotapi.Message) {
+	arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+	if arg == "" {
+		name := cmp.Or(bs.chatStyle(msg.Chat.ID).Name, "default")
+		bs.Reply(msg, fmt.Sprintf("Answers here use the %s style.\n\n%s", name, styleUsage()))
+		return
+	}
+
+	name := arg
+	if arg == "default" {
+		name = ""
+	} else if style, ok := findStyle(arg); !ok {
+		bs.Reply(msg, styleUsage())
+		return
+	} else if style.Edgy && bs.familyChat(msg.Chat.ID) {
+		bs.Reply(msg, fmt.Sprintf(familyEdgyStyleMsg, style.Name))
+		return
+	}
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"style": name}); err != nil {
+		log.Printf("Error saving the answer style of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if name == "" {
+		bs.Reply(msg, "Answers are back to the default style.")
+		return
+	}
+	bs.Reply(msg, fmt.Sprintf("Answers here now use the %s style.", name))
+}
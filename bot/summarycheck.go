@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// mainParticipantShare is the share of the messages someone must have posted for a summary to name them
+	mainParticipantShare = 0.25
+	maxMainParticipants  = 3
+	// summaryKeywords is how many of the most used words are checked; a summary should use at least one
+	summaryKeywords    = 5
+	minKeywordMentions = 3
+)
+
+// formattedLineRe splits a line of formatMessages into its sender and text
+var formattedLineRe = regexp.MustCompile(`^\[[^\]]*\] (.+?): (.*)$`)
+
+// checkSummary is the quality check of a summary of formatted messages, made without a model call: it should
+// name the people who carried the conversation, use some of its most frequent words and be written in the
+// expected language. It returns what the summary got wrong, as feedback for a rewrite; nil when it passes.
+func checkSummary(messages []string, language, summary string) []string {
+	lower := strings.ToLower(summary)
+	var senders, texts []string
+	for _, line := range messages {
+		if m := formattedLineRe.FindStringSubmatch(line); m != nil {
+			senders = append(senders, m[1])
+			texts = append(texts, m[2])
+		}
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+
+	var problems []string
+	var missing []string
+	for _, sender := range mainParticipants(senders) {
+		if !slices.ContainsFunc(senderNames(sender), func(name string) bool { return strings.Contains(lower, strings.ToLower(name)) }) {
+			missing = append(missing, sender)
+		}
+	}
+	if len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf("it doesn't mention %s, who wrote much of the conversation; say what they contributed",
+			strings.Join(missing, ", ")))
+	}
+
+	var keywords []string
+	for _, w := range wordFrequencies(texts, summaryKeywords) {
+		if w.Weight >= minKeywordMentions {
+			keywords = append(keywords, w.Text)
+		}
+	}
+	if len(keywords) > 0 && !slices.ContainsFunc(keywords, func(k string) bool { return strings.Contains(lower, k) }) {
+		problems = append(problems, fmt.Sprintf("it seems to miss the main topics; the messages keep coming back to %s",
+			strings.Join(keywords, ", ")))
+	}
+
+	if want := expectedSummaryLanguage(texts, language); want != "" {
+		if got := detectLanguage(summary); got != "" && got != want {
+			problems = append(problems, fmt.Sprintf("it is written in %s instead of %s",
+				cmp.Or(languageNames[got], got), cmp.Or(languageNames[want], want)))
+		}
+	}
+	return problems
+}
+
+// mainParticipants are the senders of at least mainParticipantShare of the messages, most active first
+func mainParticipants(senders []string) []string {
+	counts := map[string]int{}
+	for _, s := range senders {
+		counts[s]++
+	}
+	var main []string
+	for s, n := range counts {
+		if n >= minKeywordMentions && float64(n) >= mainParticipantShare*float64(len(senders)) {
+			main = append(main, s)
+		}
+	}
+	slices.SortFunc(main, func(a, b string) int { return cmp.Or(cmp.Compare(counts[b], counts[a]), strings.Compare(a, b)) })
+	return main[:min(len(main), maxMainParticipants)]
+}
+
+// senderNames are the ways a summary may refer to a sender of formatMessages: "Mili (@sg_milad)" by nickname or
+// username, "@sg_milad" by username and "Milad Smith" by first name
+func senderNames(sender string) []string {
+	if nickname, username, ok := strings.Cut(sender, " (@"); ok {
+		return []string{nickname, strings.TrimSuffix(username, ")")}
+	}
+	if username, ok := strings.CutPrefix(sender, "@"); ok {
+		return []string{username}
+	}
+	first, _, _ := strings.Cut(sender, " ")
+	return []string{first}
+}
+
+// expectedSummaryLanguage is the language code a summary should be in: the one /summarylang forces, or the
+// main language of the messages when no language was asked for; "" when it can't tell, like for split summaries
+func expectedSummaryLanguage(texts []string, language string) string {
+	if language != "" {
+		for code, name := range languageNames {
+			if strings.HasPrefix(language, name+",") {
+				return code
+			}
+		}
+		return ""
+	}
+	counts := map[string]int{}
+	for _, t := range texts {
+		if lang := detectLanguage(t); lang != "" {
+			counts[lang]++
+		}
+	}
+	var main string
+	for code, n := range counts {
+		if n > counts[main] || (n == counts[main] && code < main) {
+			main = code
+		}
+	}
+	return main
+}
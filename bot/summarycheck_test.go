@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckSummary(t *testing.T) {
+	deploy := []string{
+		"[10:00] Alice: the deploy failed again this morning",
+		"[10:01] Bob: which deploy, the staging one?",
+		"[10:02] Alice: yes, staging, the deploy script timed out",
+		"[10:03] Bob: I will look at the script after lunch",
+		"[10:04] Alice: thanks, the release is blocked until then",
+		"[10:05] Bob: sure, I will ping you when it works",
+		"[10:06] Carol: good luck with that",
+		"[10:07] Alice: we need the release before Friday",
+	}
+	tests := []struct {
+		name     string
+		messages []string
+		language string
+		summary  string
+		// want are parts of the problems found, one per problem; empty means the summary passes
+		want []string
+	}{
+		{
+			name:     "good summary",
+			messages: deploy,
+			summary:  "Alice reported that the staging deploy failed and the release is blocked. Bob will fix the script after lunch.",
+		},
+		{
+			name:     "occasional writer left out",
+			messages: deploy,
+			summary:  "Alice says the deploy broke, and Bob is fixing the script so the release can go out before Friday.",
+		},
+		{
+			name:     "main participant left out",
+			messages: deploy,
+			summary:  "Alice reported that the staging deploy failed and the release is blocked until it works.",
+			want:     []string{"doesn't mention Bob"},
+		},
+		{
+			name:     "main topic missed",
+			messages: deploy,
+			summary:  "Alice and Bob chatted about their plans for lunch and the weekend.",
+			want:     []string{"the messages keep coming back to"},
+		},
+		{
+			name:     "wrong language",
+			messages: deploy,
+			summary:  "Alice и Bob обсуждали deploy: скрипт сломался, и релиз заблокирован до пятницы.",
+			want:     []string{"written in Russian instead of English"},
+		},
+		{
+			name:     "asked language",
+			messages: deploy,
+			language: "German, whatever language the messages are in",
+			summary:  "Alice reported that the staging deploy failed and the release is blocked. Bob will fix the script after lunch.",
+			want:     []string{"written in English instead of German"},
+		},
+		{
+			name: "nickname or username",
+			messages: []string{
+				"[09:00] Mili (@sg_milad): the build cache is broken",
+				"[09:01] Mili (@sg_milad): clearing the cache fixed the build",
+				"[09:02] Mili (@sg_milad): I pushed the cache fix",
+			},
+			summary: "@sg_milad fixed the broken build cache.",
+		},
+		{
+			name: "everything wrong",
+			messages: []string{
+				"[09:00] Dana Smith: the build cache is broken",
+				"[09:01] Dana Smith: clearing the cache fixed the build",
+				"[09:02] Dana Smith: I pushed the cache fix",
+			},
+			summary: "Кто-то что-то написал.",
+			want:    []string{"doesn't mention Dana Smith", "keep coming back to", "instead of English"},
+		},
+		{name: "no formatted messages", messages: []string{"just text"}, summary: "Nothing."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkSummary(tt.messages, tt.language, tt.summary)
+			if len(got) != len(tt.want) {
+				t.Fatalf("checkSummary(%q) = %q, want %d problems", tt.summary, got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("checkSummary(%q) problem %d = %q, want it to contain %q", tt.summary, i+1, got[i], want)
+				}
+			}
+		})
+	}
+}
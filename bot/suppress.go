@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const botsUsageMsg = `Usage:
+/bots on - answer other bots and inline results sent via bots
+/bots off - ignore them (default)`
+
+// dedupeUpdates drops updates that were already handled, so a redelivery after a restart
+// isn't stored or answered twice
+func (bs *BotService) dedupeUpdates(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		// Synthetic updates, e.g. from replayed exports, have no ID to go by
+		if update.UpdateID != 0 {
+			claimed, err := bs.store.ClaimUpdate(bs.id, update.UpdateID)
+			if err != nil {
+				// Better to risk a duplicate answer than to drop the update
+				log.Printf("Error claiming update %d: %v", update.UpdateID, err)
+			} else if !claimed {
+				log.Printf("skipping update %d, it was already handled", update.UpdateID)
+				return
+			}
+		}
+		next(update)
+	}
+}
+
+// ignoreBots stops messages from bots after they are stored: the bot's own channel posts
+// forwarded into the discussion group are always skipped, other bots and via-bot inline
+// results unless the chat turned them on with /bots on
+func (bs *BotService) ignoreBots(next UpdateHandler) UpdateHandler {
+	return func(update tgbotapi.Update) {
+		msg := update.Message
+		if msg == nil {
+			next(update)
+			return
+		}
+
+		switch {
+		case msg.IsAutomaticForward, msg.From != nil && msg.From.ID == bs.id:
+			return
+		case (msg.From != nil && msg.From.IsBot) || msg.ViaBot != nil:
+			if !bs.store.ChatSettings(msg.Chat.ID).AnswerBots {
+				return
+			}
+		}
+		next(update)
+	}
+}
+
+func (bs *BotService) handleBotsCommand(msg *tgbotapi.Message) {
+	var enabled bool
+	switch strings.ToLower(strings.TrimSpace(msg.CommandArguments())) {
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	default:
+		bs.Reply(msg, botsUsageMsg)
+		return
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"answer_bots": enabled}); err != nil {
+		log.Printf("Error saving bots setting for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if enabled {
+		bs.Reply(msg, "I'll answer other bots and messages sent via bots here.")
+	} else {
+		bs.Reply(msg, "I'll ignore other bots and messages sent via bots here.")
+	}
+}
@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	maxContextMessages = 30
+	contextUsageMsg    = `Usage:
+/context <1-30> - give answers the messages before a question, so "what does he mean by that?" knows what was said
+/context off - only give answers the question and what it replies to`
+)
+
+// handleContextWindow sets how many preceding messages answers get as context
+func (bs *BotService) handleContextWindow(msg *tgbotapi.Message) {
+	arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+	var k int
+	switch arg {
+	case "":
+		state := "off"
+		if n := bs.store.ChatSettings(msg.Chat.ID).ContextMessages; n > 0 {
+			state = fmt.Sprintf("the %d messages before a question", n)
+		}
+		bs.Reply(msg, "Answer context: "+state+".\n\n"+contextUsageMsg)
+		return
+	case "off", "0":
+	default:
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > maxContextMessages {
+			bs.Reply(msg, contextUsageMsg)
+			return
+		}
+		k = n
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"context_messages": k}); err != nil {
+		log.Printf("Error saving the context window of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	switch {
+	case k == 0:
+		bs.Reply(msg, "Answers no longer get the preceding messages.")
+	case !bs.historyEnabled(msg.Chat.ID):
+		bs.Reply(msg, fmt.Sprintf("Answers will get the %d messages before a question, once history is on (/privacy).", k))
+	default:
+		bs.Reply(msg, fmt.Sprintf("🧵 Answers will get the %d messages before a question.", k))
+	}
+}
+
+// surroundingContext is the part of the answer prompt with the messages posted before the question, as many as
+// the chat's /context setting asks for, even when they aren't replies; "" when it's off
+func (bs *BotService) surroundingContext(msg *tgbotapi.Message) string {
+	k := bs.store.ChatSettings(msg.Chat.ID).ContextMessages
+	if k <= 0 || msg.Chat.IsPrivate() || !bs.historyEnabled(msg.Chat.ID) {
+		return ""
+	}
+	before, err := bs.store.FindMessages(bson.M{"chat_id": msg.Chat.ID, "message_id": bson.M{"$lt": msg.MessageID}}, k)
+	if err != nil {
+		log.Printf("Error loading the messages before %d in chat %d: %v", msg.MessageID, msg.Chat.ID, err)
+		return ""
+	}
+	if len(before) == 0 {
+		return ""
+	}
+	lines := formatMessages(before, bs.chatLocation(msg.Chat.ID), bs.chatNicknames(msg.Chat.ID))
+	return "The messages posted in the chat right before the question, oldest first; the question may refer to them:\n" +
+		untrusted("preceding messages", strings.Join(lines, "\n"))
+}
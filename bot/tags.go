@@ -0,0 +1,249 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// tagBatch is how many messages are tagged with one model call
+	tagBatch = 30
+	// tagMaxWait is how long a message waits for its batch to fill before it's tagged anyway
+	tagMaxWait     = 15 * time.Minute
+	maxMessageTags = 3
+	maxTagRunes    = 32
+	findResults    = 10
+	// knownTags is how many of the chat's existing tags are offered to the model, to keep tags consistent
+	knownTags       = 30
+	topicTagCounts  = 10
+	findUsageMsg    = "Usage: /find #tag [#tag...] - list recent messages with all the given tags"
+	autotagUsageMsg = `Usage:
+/autotag on - tag new messages with hashtags for /find (one extra AI call per 30 messages)
+/autotag off - stop tagging`
+)
+
+var (
+	// tagsRunning keeps a tagging run from overlapping with the next tick
+	tagsRunning atomic.Bool
+	hashtagRe   = regexp.MustCompile(`#[\p{L}\p{N}_-]+`)
+)
+
+// normalizeTag lowercases a tag and drops the "#" and any characters Telegram wouldn't make part of a hashtag
+func normalizeTag(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "#")))
+	tag = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-':
+			return r
+		case r == ' ':
+			return '-'
+		}
+		return -1
+	}, tag)
+	return truncateRunes(strings.Trim(tag, "-_"), maxTagRunes)
+}
+
+// textHashtags returns the hashtags people wrote themselves, which are tagged without asking the model
+func textHashtags(text string) []string {
+	var tags []string
+	for _, h := range hashtagRe.FindAllString(text, -1) {
+		if tag := normalizeTag(h); tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// tagMessages asks the model for up to maxMessageTags hashtags per message and merges in the hashtags in the text
+func (bs *BotService) tagMessages(ctx context.Context, chatID int64, messages []store.Message) (map[int][]string, error) {
+	var known []string
+	if counts, err := bs.store.TagCounts(chatID, time.Now().AddDate(0, 0, -30), knownTags); err != nil {
+		log.Printf("Error loading tags for chat %d: %v", chatID, err)
+	} else {
+		for _, c := range counts {
+			known = append(known, "#"+c.Tag)
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `Tag each numbered chat message with up to %d short lowercase hashtags describing its subject, like #travel or #bug-report.
+Use hyphens instead of spaces. Small talk, greetings and messages without a clear subject get no tags.
+`, maxMessageTags)
+	if len(known) > 0 {
+		sb.WriteString("Prefer these tags already used in the chat when they fit: " + strings.Join(known, " ") + "\n")
+	}
+	sb.WriteString(`Answer with one line per message, "N: #tag1 #tag2", or "N: -" for no tags, and nothing else.
+
+`)
+	var lines strings.Builder
+	for i, m := range messages {
+		fmt.Fprintf(&lines, "%d: %s\n", i+1, strings.ReplaceAll(truncateRunes(m.Text, 500), "\n", " "))
+	}
+	sb.WriteString(untrusted("chat messages", lines.String()))
+
+	answer, err := bs.generate(ctx, sb.String())
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[int][]string, len(messages))
+	for _, m := range messages {
+		tags[m.MessageID] = textHashtags(m.Text)
+	}
+	for _, line := range strings.Split(answer, "\n") {
+		num, rest, ok := strings.Cut(strings.TrimSpace(line), ":")
+		n, err := strconv.Atoi(strings.TrimSpace(num))
+		if !ok || err != nil || n < 1 || n > len(messages) {
+			continue
+		}
+		id := messages[n-1].MessageID
+		for i, field := range strings.Fields(rest) {
+			if i >= maxMessageTags {
+				break
+			}
+			if tag := normalizeTag(field); tag != "" && !slices.Contains(tags[id], tag) {
+				tags[id] = append(tags[id], tag)
+			}
+		}
+	}
+	return tags, nil
+}
+
+// tagChat tags the next batch of untagged messages of a chat, once the batch is full or its oldest message waited tagMaxWait
+func (bs *BotService) tagChat(settings store.ChatSettings, now time.Time) error {
+	messages, err := bs.store.UntaggedMessages(settings.ChatID, settings.AutoTaggingSince, tagBatch)
+	if err != nil || len(messages) == 0 {
+		return err
+	}
+	if len(messages) < tagBatch && now.Sub(messages[0].Timestamp) < tagMaxWait {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tags, err := bs.tagMessages(ctx, settings.ChatID, messages)
+	if err != nil {
+		return err
+	}
+	return bs.store.SetMessageTags(settings.ChatID, tags)
+}
+
+// updateTags tags new messages of the chats with auto-tagging on, one chat after another in the background
+func (bs *BotService) updateTags(now time.Time) {
+	if enabled, _ := bs.maintenance.get(); enabled || !tagsRunning.CompareAndSwap(false, true) {
+		return
+	}
+	bs.goSafe("auto-tagging", func() {
+		defer tagsRunning.Store(false)
+
+		chats, err := bs.store.FindChatSettings(bson.M{"auto_tagging": true})
+		if err != nil {
+			log.Printf("Error loading auto-tagging chats: %v", err)
+			return
+		}
+		for _, settings := range chats {
+			if err := bs.tagChat(settings, now); err != nil {
+				log.Printf("Error tagging messages of chat %d: %v", settings.ChatID, err)
+			}
+		}
+	})
+}
+
+func (bs *BotService) handleFind(msg *tgbotapi.Message) {
+	var tags []string
+	for _, field := range strings.Fields(msg.CommandArguments()) {
+		if tag := normalizeTag(field); tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		bs.Reply(msg, findUsageMsg)
+		return
+	}
+
+	messages, err := bs.store.FindTagged(msg.Chat.ID, tags, findResults)
+	if err != nil {
+		log.Printf("Error finding tagged messages: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(messages) == 0 {
+		reply := "No messages tagged #" + strings.Join(tags, " #") + "."
+		if !bs.store.ChatSettings(msg.Chat.ID).AutoTagging {
+			reply += " Auto-tagging is off here; admins can turn it on with /autotag on."
+		}
+		bs.Reply(msg, reply)
+		return
+	}
+
+	loc := bs.chatLocation(msg.Chat.ID)
+	var sb strings.Builder
+	sb.WriteString("🏷 #" + strings.Join(tags, " #") + ":\n")
+	for _, m := range messages {
+		sb.WriteString(fmt.Sprintf("\n%s, %s: %s", m.Author(), m.Timestamp.In(loc).Format("Jan 2 15:04"), truncateRunes(m.Text, 200)))
+		if link := messageLink(msg.Chat, m.MessageID); link != "" {
+			sb.WriteString("\n" + link)
+		}
+		sb.WriteString("\n")
+	}
+	bs.Reply(msg, sb.String())
+}
+
+func (bs *BotService) handleAutotag(msg *tgbotapi.Message) {
+	var change bson.M
+	switch strings.ToLower(strings.TrimSpace(msg.CommandArguments())) {
+	case "on":
+		// Only messages from now on are tagged, so turning it on doesn't pay for the whole history
+		change = bson.M{"auto_tagging": true, "auto_tagging_since": time.Now()}
+	case "off":
+		change = bson.M{"auto_tagging": false}
+	default:
+		state := "off"
+		if bs.store.ChatSettings(msg.Chat.ID).AutoTagging {
+			state = "on"
+		}
+		bs.Reply(msg, "Auto-tagging is "+state+".\n\n"+autotagUsageMsg)
+		return
+	}
+
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, change); err != nil {
+		log.Printf("Error saving auto-tagging setting for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if change["auto_tagging"] == true {
+		bs.Reply(msg, "🏷 Auto-tagging is on. New messages get hashtags within a few minutes; find them with /find #tag.")
+		return
+	}
+	bs.Reply(msg, "Auto-tagging is off. Existing tags are kept.")
+}
+
+// formatTagCounts lists the most used tags of the last topicWindow for /topics
+func (bs *BotService) formatTagCounts(chatID int64) string {
+	counts, err := bs.store.TagCounts(chatID, time.Now().Add(-topicWindow), topicTagCounts)
+	if err != nil {
+		log.Printf("Error counting tags for chat %d: %v", chatID, err)
+		return ""
+	}
+	if len(counts) == 0 {
+		return ""
+	}
+	parts := make([]string, len(counts))
+	for i, c := range counts {
+		parts[i] = fmt.Sprintf("#%s (%d)", c.Tag, c.Count)
+	}
+	return "🏷 Top tags: " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,21 @@
+package bot
+
+import tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+// TelegramClient is the part of the Bot API the bot uses; *tgbotapi.BotAPI implements it,
+// and tests can swap in a fake such as bottest.FakeTelegram
+type TelegramClient interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	// MakeRequest calls Bot API methods this version of tgbotapi has no config for, like setMessageReaction
+	MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error)
+	GetChat(config tgbotapi.ChatInfoConfig) (tgbotapi.Chat, error)
+	GetChatMember(config tgbotapi.GetChatMemberConfig) (tgbotapi.ChatMember, error)
+	GetChatAdministrators(config tgbotapi.ChatAdministratorsConfig) ([]tgbotapi.ChatMember, error)
+	GetChatMembersCount(config tgbotapi.ChatMemberCountConfig) (int, error)
+	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
+	// GetFileDirectURL returns the download URL of a file users sent, like a document
+	GetFileDirectURL(fileID string) (string, error)
+}
+
+var _ TelegramClient = (*tgbotapi.BotAPI)(nil)
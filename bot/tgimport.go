@@ -0,0 +1,215 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+const (
+	// maxImportFile is the biggest export the bot can download; bigger ones are imported with the CLI
+	maxImportFile  = 20 << 20
+	importBatch    = 1000
+	importUsageMsg = "Export the chat with Telegram Desktop as machine-readable JSON, send me result.json here and reply to it with /import. Bigger exports than 20 MB are imported with the chatbuddy import command."
+)
+
+// telegramExport is the result.json of a chat exported with Telegram Desktop
+type telegramExport struct {
+	Name     string                  `json:"name"`
+	Type     string                  `json:"type"`
+	ID       int64                   `json:"id"`
+	Messages []telegramExportMessage `json:"messages"`
+}
+
+type telegramExportMessage struct {
+	ID           int             `json:"id"`
+	Type         string          `json:"type"`
+	DateUnixtime string          `json:"date_unixtime"`
+	From         string          `json:"from"`
+	FromID       string          `json:"from_id"`
+	ReplyTo      int             `json:"reply_to_message_id"`
+	Text         json.RawMessage `json:"text"`
+	Photo        string          `json:"photo"`
+	MediaType    string          `json:"media_type"`
+	File         string          `json:"file"`
+	FileName     string          `json:"file_name"`
+}
+
+// exportMediaKinds names the media_type values of exports like mediaKind names Bot API media
+var exportMediaKinds = map[string]string{
+	"animation":     "GIF",
+	"audio_file":    "audio",
+	"sticker":       "sticker",
+	"video_file":    "video",
+	"video_message": "video message",
+	"voice_message": "voice message",
+}
+
+// botAPIChatID converts the ID of an exported chat to the Bot API's, which prefixes supergroups and channels
+// with -100 and negates basic groups
+func (e telegramExport) botAPIChatID() int64 {
+	switch {
+	case strings.HasSuffix(e.Type, "supergroup"), strings.HasSuffix(e.Type, "channel"):
+		id, _ := strconv.ParseInt(fmt.Sprintf("-100%d", e.ID), 10, 64)
+		return id
+	case strings.HasSuffix(e.Type, "group"):
+		return -e.ID
+	default:
+		return e.ID
+	}
+}
+
+// parseTelegramExport reads a Telegram Desktop export into messages of chatID, or of the exported chat when 0.
+// Service messages and posts without text are left out.
+func parseTelegramExport(r io.Reader, chatID int64) (store.ChatRecord, []store.Message, error) {
+	var export telegramExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return store.ChatRecord{}, nil, fmt.Errorf("not a Telegram export: %w", err)
+	}
+	if export.Messages == nil {
+		return store.ChatRecord{}, nil, errors.New("not a Telegram export of a single chat: it has no messages")
+	}
+	if chatID == 0 {
+		chatID = export.botAPIChatID()
+	}
+	chat := store.ChatRecord{ChatID: chatID, Title: export.Name, Type: export.Type}
+
+	var messages []store.Message
+	for _, m := range export.Messages {
+		if m.Type != "message" {
+			continue
+		}
+		unix, err := strconv.ParseInt(m.DateUnixtime, 10, 64)
+		if err != nil {
+			return chat, nil, fmt.Errorf("message %d has no valid date_unixtime, export with a newer Telegram Desktop", m.ID)
+		}
+		message := store.Message{
+			ChatID:        chatID,
+			MessageID:     m.ID,
+			FromFirstName: m.From,
+			Text:          exportText(m.Text),
+			Timestamp:     time.Unix(unix, 0),
+			ReplyTo:       m.ReplyTo,
+			Media:         m.mediaKind(),
+		}
+		// Users are "user123", channels and anonymous admins "channel123"
+		if id, ok := strings.CutPrefix(m.FromID, "user"); ok {
+			message.FromID, _ = strconv.ParseInt(id, 10, 64)
+		}
+		message.Language = detectLanguage(message.Text)
+		if message.Text == "" {
+			// Like live messages, media are only kept with a caption
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return chat, messages, nil
+}
+
+func (m telegramExportMessage) mediaKind() string {
+	switch {
+	case m.Photo != "":
+		return "photo"
+	case m.MediaType != "":
+		if kind, ok := exportMediaKinds[m.MediaType]; ok {
+			return kind
+		}
+		return strings.ReplaceAll(m.MediaType, "_", " ")
+	case m.File != "":
+		return strings.TrimSpace("file " + m.FileName)
+	default:
+		return ""
+	}
+}
+
+// exportText flattens the text of an exported message, a string or a list of strings and entities with text
+func exportText(raw json.RawMessage) string {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	var parts []json.RawMessage
+	if json.Unmarshal(raw, &parts) != nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, part := range parts {
+		var entity struct {
+			Text string `json:"text"`
+		}
+		if json.Unmarshal(part, &text) == nil {
+			sb.WriteString(text)
+		} else if json.Unmarshal(part, &entity) == nil {
+			sb.WriteString(entity.Text)
+		}
+	}
+	return sb.String()
+}
+
+// importMessages stores exported messages in batches and returns how many weren't stored yet
+func importMessages(ctx context.Context, db *store.Store, messages []store.Message) (int64, error) {
+	var imported int64
+	for start := 0; start < len(messages); start += importBatch {
+		n, err := db.ImportMessages(ctx, messages[start:min(start+importBatch, len(messages))])
+		imported += n
+		if err != nil {
+			return imported, err
+		}
+	}
+	return imported, nil
+}
+
+// handleImport imports the Telegram Desktop export the command replies to in a DM into the exported group,
+// for its admins and the owner
+func (bs *BotService) handleImport(msg *tgbotapi.Message) {
+	reply := msg.ReplyToMessage
+	if reply == nil || reply.Document == nil {
+		bs.Reply(msg, importUsageMsg)
+		return
+	}
+	if reply.Document.FileSize > maxImportFile {
+		bs.Reply(msg, "That export is too big for me to download. "+importUsageMsg)
+		return
+	}
+	data, err := bs.downloadFile(reply.Document.FileID, maxImportFile)
+	if err != nil {
+		log.Printf("Error downloading an export from user %d: %v", msg.From.ID, err)
+		bs.Reply(msg, "I couldn't download that file. "+importUsageMsg)
+		return
+	}
+	chat, messages, err := parseTelegramExport(bytes.NewReader(data), 0)
+	if err != nil {
+		bs.Reply(msg, fmt.Sprintf("I couldn't read that export: %v", err))
+		return
+	}
+	if chat.ChatID == msg.From.ID {
+		bs.Reply(msg, "That's an export of a private chat, only group and channel history can be imported.")
+		return
+	}
+	if msg.From.ID != bs.config().OwnerID && !bs.isChatAdmin(chat.ChatID, msg.From.ID) {
+		bs.Reply(msg, fmt.Sprintf("Only admins of %s can import its history, and I need to be in it.", chat.Title))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	imported, err := importMessages(ctx, bs.store, messages)
+	if err != nil {
+		log.Printf("Error importing the history of chat %d: %v", chat.ChatID, err)
+		bs.Reply(msg, fmt.Sprintf("Something went wrong after importing %d messages, send /import again to continue.", imported))
+		return
+	}
+	bs.audit(chat.ChatID, msg.From, "import", fmt.Sprintf("%d of %d exported messages", imported, len(messages)))
+	bs.Reply(msg, fmt.Sprintf("📥 Imported %d messages into %s (%d were already stored). /summary and /search cover them now.",
+		imported, chat.Title, int64(len(messages))-imported))
+}
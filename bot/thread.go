@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// maxThreadMessages caps how much of a reply thread /summarize reads
+	maxThreadMessages = 200
+
+	summarizeUsageMsg   = "Reply to a message with /summarize to summarize the discussion that followed it."
+	threadNotStoredMsg  = "I don't have that message stored, so I can't follow its replies."
+	threadNoRepliesMsg  = "Nobody replied to that message yet, there's no discussion to summarize."
+	threadSummaryHeader = "🧵 Thread of %d messages:\n\n"
+)
+
+// handleSummarize summarizes the discussion descending from the replied-to message, rebuilt from the reply
+// links of stored messages
+func (bs *BotService) handleSummarize(msg *tgbotapi.Message) {
+	if msg.ReplyToMessage == nil {
+		bs.Reply(msg, summarizeUsageMsg)
+		return
+	}
+
+	thread, err := bs.store.ReplyThread(msg.Chat.ID, msg.ReplyToMessage.MessageID, maxThreadMessages)
+	if err != nil {
+		log.Printf("Error loading reply thread of message %d in chat %d: %v", msg.ReplyToMessage.MessageID, msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	switch len(thread) {
+	case 0:
+		bs.Reply(msg, threadNotStoredMsg)
+		return
+	case 1:
+		bs.Reply(msg, threadNoRepliesMsg)
+		return
+	}
+
+	loc := bs.chatLocation(msg.Chat.ID)
+	nicknames := bs.chatNicknames(msg.Chat.ID)
+	lines := make([]string, 0, len(thread))
+	for _, m := range thread {
+		lines = append(lines, fmt.Sprintf("[%s] %s: %s", m.Timestamp.In(loc).Format("2006-01-02 15:04:05"), speaker(m, nicknames), m.Text))
+	}
+	summary, err := bs.summarizeThread(bs.billedContext(msg), lines)
+	if err != nil {
+		log.Printf("gemini thread summarization error: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	response := tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf(threadSummaryHeader, len(thread))+summary)
+	response.ReplyToMessageID = msg.ReplyToMessage.MessageID
+	bs.sendAs(kindAnswer, response)
+}
+
+// summarizeThread asks the model for a summary of a reply thread, the first message being the one that started it
+func (bs *BotService) summarizeThread(ctx context.Context, messages []string) (string, error) {
+	prompt := fmt.Sprintf(`Below is one discussion from a Telegram chat: the first message and every reply that followed it. Summarize it for someone catching up:
+
+%s
+
+Summary instructions:
+1. Say what the discussion is about and where each side stands
+2. Note any questions that were answered and any that are still open
+3. Highlight any agreement or decision reached
+4. Keep it brief (3-5 sentences) and neutral, even if the discussion got heated
+5. Format the summary in plain text (no markdown)
+6. Response language: Same as the messages`, untrusted("chat messages", strings.Join(messages, "\n")))
+
+	ctx, cancel := context.WithTimeout(ctx, 90*time.Second)
+	defer cancel()
+	return bs.generate(ctx, prompt)
+}
@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const timezoneUsageMsg = `Usage:
+/timezone - show the timezones in effect
+/timezone set <Area/City> - set the chat timezone, e.g. /timezone set Europe/Berlin
+/timezone me <Area/City> - set your personal timezone`
+
+// chatLocation returns the timezone of a chat, falling back to the bot default
+func (bs *BotService) chatLocation(chatID int64) *time.Location {
+	if loc := loadLocation(bs.store.ChatSettings(chatID).Timezone); loc != nil {
+		return loc
+	}
+	return bs.config().DefaultLocation
+}
+
+// userLocation returns a user's personal timezone, falling back to the chat timezone and then the bot default
+func (bs *BotService) userLocation(userID, chatID int64) *time.Location {
+	if loc := loadLocation(bs.store.UserPreferences(userID).Timezone); loc != nil {
+		return loc
+	}
+	return bs.chatLocation(chatID)
+}
+
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("invalid stored timezone %q: %v", name, err)
+		return nil
+	}
+	return loc
+}
+
+func (bs *BotService) handleTimezone(msg *tgbotapi.Message) {
+	sub, zone, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+	zone = strings.TrimSpace(zone)
+
+	switch strings.ToLower(sub) {
+	case "":
+		bs.Reply(msg, fmt.Sprintf("Chat timezone: %s\nYour timezone: %s",
+			bs.chatLocation(msg.Chat.ID), bs.userLocation(msg.From.ID, msg.Chat.ID)))
+	case "set", "me":
+		loc, err := time.LoadLocation(zone)
+		if zone == "" || err != nil {
+			bs.Reply(msg, "Unknown timezone. Use a name like Europe/Berlin or America/New_York.")
+			return
+		}
+
+		// In a DM the chat and the user are the same, so "set" is personal too
+		if sub == "me" || msg.Chat.IsPrivate() {
+			err = bs.store.UpdateUserPreferences(msg.From.ID, bson.M{"timezone": loc.String()})
+		} else if bs.userRole(msg.Chat, msg.From) < ChatAdmin {
+			bs.Reply(msg, adminOnlyMsg)
+			return
+		} else {
+			err = bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"timezone": loc.String()})
+		}
+		if err != nil {
+			log.Printf("Error saving timezone: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bs.Reply(msg, fmt.Sprintf("Timezone set to %s (local time now %s).", loc, time.Now().In(loc).Format("15:04")))
+	default:
+		bs.Reply(msg, timezoneUsageMsg)
+	}
+}
+
+// parseDayRange turns a relative day expression like "today" or "yesterday" into a [from, to) range
+// in the timezone of now.
+func parseDayRange(expr string, now time.Time) (time.Time, time.Time, bool) {
+	start, end := dayBounds(now)
+
+	switch strings.ToLower(strings.TrimSpace(expr)) {
+	case "today":
+		return start, end, true
+	case "yesterday":
+		return start.AddDate(0, 0, -1), start, true
+	case "week", "this week":
+		// Weeks start on Monday
+		offset := (int(now.Weekday()) + 6) % 7
+		return start.AddDate(0, 0, -offset), end, true
+	case "last week":
+		offset := (int(now.Weekday()) + 6) % 7
+		weekStart := start.AddDate(0, 0, -offset)
+		return weekStart.AddDate(0, 0, -7), weekStart, true
+	}
+	return time.Time{}, time.Time{}, false
+}
@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDayRange(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+	wednesday := time.Date(2026, 10, 14, 15, 30, 0, 0, berlin)
+
+	tests := []struct {
+		name string
+		expr string
+		now  time.Time
+		// from and to are dates in the timezone of now; both empty means expr isn't a day range
+		from, to string
+		hours    float64
+	}{
+		{name: "today", expr: "today", now: wednesday, from: "2026-10-14", to: "2026-10-15", hours: 24},
+		{name: "yesterday", expr: "yesterday", now: wednesday, from: "2026-10-13", to: "2026-10-14", hours: 24},
+		{name: "case and space", expr: "  Yesterday ", now: wednesday, from: "2026-10-13", to: "2026-10-14", hours: 24},
+		{name: "week", expr: "week", now: wednesday, from: "2026-10-12", to: "2026-10-15", hours: 72},
+		{name: "this week", expr: "this week", now: wednesday, from: "2026-10-12", to: "2026-10-15", hours: 72},
+		{name: "week on a Monday", expr: "week", now: time.Date(2026, 10, 12, 9, 0, 0, 0, berlin), from: "2026-10-12", to: "2026-10-13", hours: 24},
+		{name: "week on a Sunday", expr: "week", now: time.Date(2026, 10, 18, 23, 59, 0, 0, berlin), from: "2026-10-12", to: "2026-10-19", hours: 168},
+		{name: "last week", expr: "last week", now: wednesday, from: "2026-10-05", to: "2026-10-12", hours: 168},
+		{name: "just after midnight", expr: "today", now: time.Date(2026, 10, 14, 0, 0, 1, 0, berlin), from: "2026-10-14", to: "2026-10-15", hours: 24},
+		{name: "other timezone", expr: "today", now: wednesday.In(time.FixedZone("UTC+14", 14*3600)), from: "2026-10-15", to: "2026-10-16", hours: 24},
+		{name: "day clocks go back", expr: "yesterday", now: time.Date(2026, 10, 26, 10, 0, 0, 0, berlin), from: "2026-10-25", to: "2026-10-26", hours: 25},
+		{name: "day clocks go forward", expr: "today", now: time.Date(2026, 3, 29, 12, 0, 0, 0, berlin), from: "2026-03-29", to: "2026-03-30", hours: 23},
+
+		{name: "tomorrow", expr: "tomorrow", now: wednesday},
+		{name: "last month", expr: "last month", now: wednesday},
+		{name: "a date", expr: "2026-10-14", now: wednesday},
+		{name: "a number", expr: "32", now: wednesday},
+		{name: "empty", expr: "", now: wednesday},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, ok := parseDayRange(tt.expr, tt.now)
+			if tt.from == "" {
+				if ok {
+					t.Errorf("parseDayRange(%q) = %v, %v, want no range", tt.expr, from, to)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("parseDayRange(%q) found no range", tt.expr)
+			}
+			if got := from.Format(time.DateOnly); got != tt.from || !from.Equal(midnight(from)) {
+				t.Errorf("parseDayRange(%q) starts at %v, want midnight on %s", tt.expr, from, tt.from)
+			}
+			if got := to.Format(time.DateOnly); got != tt.to || !to.Equal(midnight(to)) {
+				t.Errorf("parseDayRange(%q) ends at %v, want midnight on %s", tt.expr, to, tt.to)
+			}
+			if got := to.Sub(from).Hours(); got != tt.hours {
+				t.Errorf("parseDayRange(%q) lasts %v hours, want %v", tt.expr, got, tt.hours)
+			}
+			if from.Location() != tt.now.Location() {
+				t.Errorf("parseDayRange(%q) is in %v, want %v", tt.expr, from.Location(), tt.now.Location())
+			}
+		})
+	}
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
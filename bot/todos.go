@@ -0,0 +1,218 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	todoUsageMsg = `Usage:
+/todo add <task> - add to the chat's shared list
+/todo list - show open tasks
+/todo done <number> - mark a task as done
+Prefix with "my" (e.g. /todo my add <task>) for your private list. In a DM with me, the list is always private.`
+	noTodosMsg      = "Nothing left to do 🎉"
+	todoNotFoundMsg = "There's no open task with that number. Check /todo list."
+	maxTodosInReply = 50
+)
+
+// todoQuestionPattern matches questions like "what's left to do?"
+var todoQuestionPattern = regexp.MustCompile(`(?i)\b(left to do|to-?dos?|open tasks|still need to)\b`)
+
+// Todo is a task on a shared chat list (UserID 0) or on a user's private list (ChatID 0)
+type Todo struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	ChatID    int64              `bson:"chat_id"`
+	UserID    int64              `bson:"user_id"`
+	Text      string             `bson:"text"`
+	Done      bool               `bson:"done"`
+	CreatedBy int64              `bson:"created_by"`
+	CreatedAt time.Time          `bson:"created_at"`
+	DoneAt    time.Time          `bson:"done_at,omitempty"`
+}
+
+// todoScope returns the list filter a todo command operates on: private in DMs or when prefixed with "my"
+func todoScope(msg *tgbotapi.Message, private bool) bson.M {
+	if private || msg.Chat.IsPrivate() {
+		return bson.M{"chat_id": int64(0), "user_id": msg.From.ID}
+	}
+	return bson.M{"chat_id": msg.Chat.ID, "user_id": int64(0)}
+}
+
+func (bs *BotService) handleTodo(msg *tgbotapi.Message) {
+	args := strings.Fields(msg.CommandArguments())
+	private := len(args) > 0 && strings.EqualFold(args[0], "my")
+	if private {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		bs.Reply(msg, todoUsageMsg)
+		return
+	}
+
+	scope := todoScope(msg, private)
+	switch strings.ToLower(args[0]) {
+	case "add":
+		text := strings.Join(args[1:], " ")
+		if text == "" {
+			bs.Reply(msg, todoUsageMsg)
+			return
+		}
+		todo := Todo{
+			ChatID:    scope["chat_id"].(int64),
+			UserID:    scope["user_id"].(int64),
+			Text:      text,
+			CreatedBy: msg.From.ID,
+			CreatedAt: time.Now(),
+		}
+		if err := bs.insertTodo(todo); err != nil {
+			log.Printf("Error storing todo: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bs.Reply(msg, "📝 Added: "+text)
+	case "list":
+		todos, err := bs.openTodos(scope)
+		if err != nil {
+			log.Printf("Error loading todos: %v", err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		bs.Reply(msg, formatTodos(todos))
+	case "done":
+		if len(args) != 2 {
+			bs.Reply(msg, todoUsageMsg)
+			return
+		}
+		bs.completeTodo(msg, scope, args[1])
+	default:
+		bs.Reply(msg, todoUsageMsg)
+	}
+}
+
+func (bs *BotService) completeTodo(msg *tgbotapi.Message, scope bson.M, number string) {
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		bs.Reply(msg, todoNotFoundMsg)
+		return
+	}
+
+	todos, err := bs.openTodos(scope)
+	if err != nil {
+		log.Printf("Error loading todos: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if n > len(todos) {
+		bs.Reply(msg, todoNotFoundMsg)
+		return
+	}
+	todo := todos[n-1]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := bs.db.Collection("todos").UpdateByID(ctx, todo.ID,
+		bson.M{"$set": bson.M{"done": true, "done_at": time.Now()}},
+	); err != nil {
+		log.Printf("Error completing todo: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.Reply(msg, "✅ Done: "+todo.Text)
+}
+
+func (bs *BotService) insertTodo(todo Todo) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := bs.db.Collection("todos").InsertOne(ctx, todo)
+	return err
+}
+
+// openTodos returns the open tasks of a list in the order they were added
+func (bs *BotService) openTodos(scope bson.M) ([]Todo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"done": false}
+	for k, v := range scope {
+		filter[k] = v
+	}
+
+	cursor, err := bs.db.Collection("todos").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(maxTodosInReply),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var todos []Todo
+	if err := cursor.All(ctx, &todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func formatTodos(todos []Todo) string {
+	if len(todos) == 0 {
+		return noTodosMsg
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📝 To do:\n")
+	for i, todo := range todos {
+		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, todo.Text))
+	}
+	return sb.String()
+}
+
+func (bs *BotService) isTodoQuestion(question string) bool {
+	return todoQuestionPattern.MatchString(question)
+}
+
+// answerFromTodos answers questions like "what's left to do?" from the open tasks.
+// Private lists are only used in DMs so they never leak into a group.
+func (bs *BotService) answerFromTodos(msg *tgbotapi.Message, question string) string {
+	todos, err := bs.openTodos(todoScope(msg, false))
+	if err != nil {
+		log.Printf("Error loading todos: %v", err)
+	}
+	if len(todos) == 0 {
+		return bs.generateResponse(msg, question)
+	}
+
+	prompt := fmt.Sprintf(`You are a helpful Telegram bot that keeps the to-do lists of a chat.
+
+Open tasks:
+%s
+
+The user asked:
+%s
+
+Answer instructions:
+1. Answer from the open tasks above
+2. Keep it brief (a short list or 2-3 sentences), plain text, no markdown
+3. Response language: Same as the user's message`, untrusted("open tasks", formatTodos(todos)), untrusted("question", question))
+
+	ctx, cancel := context.WithTimeout(bs.billedContext(msg), 60*time.Second)
+	defer cancel()
+
+	answer, err := bs.generate(ctx, prompt)
+	if err != nil {
+		log.Printf("gemini todo answer error: %v", err)
+		return responseErrorMsg
+	}
+	return answer
+}
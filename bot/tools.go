@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+)
+
+// assistantTools are the functions the model may call while answering msg
+func (bs *BotService) assistantTools(msg *tgbotapi.Message) []llm.Tool {
+	return []llm.Tool{
+		{
+			Name:        "convert",
+			Description: "Converts an amount between units of length, mass, volume, area, speed, time, data or temperature, or between currencies at the latest exchange rates. Use it for any unit or currency conversion instead of calculating.",
+			Parameters: map[string]llm.Parameter{
+				"amount": {Type: "number", Description: "The amount to convert"},
+				"from":   {Type: "string", Description: `The unit or currency of the amount, like "mi", "kg", "°F" or "USD"`},
+				"to":     {Type: "string", Description: `The unit or currency to convert to, like "km", "lb", "°C" or "EUR"`},
+			},
+			Required: []string{"amount", "from", "to"},
+			Call: func(ctx context.Context, args map[string]any) (any, error) {
+				amount, ok := llm.NumberArg(args, "amount")
+				if !ok {
+					return nil, fmt.Errorf("amount must be a number")
+				}
+				return bs.convert(ctx, conversion{amount, llm.StringArg(args, "from"), llm.StringArg(args, "to")})
+			},
+		},
+		{
+			Name:        "weather",
+			Description: "Gets the current weather and the daily forecast for a place, in °C, km/h and mm. Use it for questions about the weather or whether it's a good day for something outside.",
+			Parameters: map[string]llm.Parameter{
+				"location": {Type: "string", Description: "A city or place name; leave it empty for the place the user last asked about"},
+				"days":     {Type: "integer", Description: "Days of forecast, today included, 1 to 7"},
+			},
+			Call: func(ctx context.Context, args map[string]any) (any, error) {
+				days, ok := llm.NumberArg(args, "days")
+				if !ok {
+					days = forecastDays
+				}
+				return bs.weatherTool(ctx, msg, llm.StringArg(args, "location"), int(days))
+			},
+		},
+		{
+			Name:        "wikipedia",
+			Description: "Looks a topic up on Wikipedia and returns the lead summary of the best matching article with its link. Use it for factual questions about people, places, things and history, and mention the article link in the answer.",
+			Parameters: map[string]llm.Parameter{
+				"query":    {Type: "string", Description: "What to look up, like an article title"},
+				"language": {Type: "string", Description: `The Wikipedia language code, like "en" or "de"; leave it empty for the chat's default`},
+			},
+			Required: []string{"query"},
+			Call: func(ctx context.Context, args map[string]any) (any, error) {
+				language := llm.StringArg(args, "language")
+				if language == "" {
+					language = bs.wikiLanguage(msg.Chat)
+				}
+				return wikiLookup(ctx, language, llm.StringArg(args, "query"))
+			},
+		},
+	}
+}
@@ -0,0 +1,260 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+	"github.com/sg-milad/ChatBuddy/vectorstore"
+)
+
+const (
+	// topicWindow is how far back topics look
+	topicWindow      = 7 * 24 * time.Hour
+	topicMaxMessages = 500
+	// topicSimilarity is how close to a topic's centroid a message must be to join it
+	topicSimilarity = 0.72
+	topicMinSize    = 3
+	maxTopics       = 8
+	// topicHour is the local hour topics are recomputed each day
+	topicHour        = 4
+	topicSamples     = 12
+	topicLinks       = 3
+	noTopicsMsg      = "No topics yet. They are computed daily from the last week's messages once they are embedded."
+	topicsRefreshMsg = "Clustering the last week's messages... This may take a moment."
+)
+
+// topicsRunning keeps the daily run from overlapping with itself
+var topicsRunning atomic.Bool
+
+// topicCluster is a group of similar messages while clustering
+type topicCluster struct {
+	centroid []float32
+	messages []store.Message
+}
+
+// clusterMessages groups messages by embedding similarity: each message joins the cluster whose centroid
+// is most similar, if it is similar enough, else starts a new one. Clusters smaller than topicMinSize are dropped.
+func clusterMessages(messages []store.Message, vectors map[int][]float32) []topicCluster {
+	var clusters []topicCluster
+	for _, m := range messages {
+		v, ok := vectors[m.MessageID]
+		if !ok {
+			continue
+		}
+		best, bestScore := -1, topicSimilarity
+		for i, c := range clusters {
+			if score := vectorstore.Cosine(v, c.centroid); score >= bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			clusters = append(clusters, topicCluster{centroid: append([]float32(nil), v...), messages: []store.Message{m}})
+			continue
+		}
+
+		// Move the centroid to the running mean of the cluster
+		c := &clusters[best]
+		n := float32(len(c.messages))
+		for i := range c.centroid {
+			c.centroid[i] = (c.centroid[i]*n + v[i]) / (n + 1)
+		}
+		c.messages = append(c.messages, m)
+	}
+
+	kept := clusters[:0]
+	for _, c := range clusters {
+		if len(c.messages) >= topicMinSize {
+			kept = append(kept, c)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return len(kept[i].messages) > len(kept[j].messages) })
+	if len(kept) > maxTopics {
+		kept = kept[:maxTopics]
+	}
+	return kept
+}
+
+// computeTopics clusters a chat's embedded messages of the last week and names the clusters with the model
+func (bs *BotService) computeTopics(ctx context.Context, chatID int64) (store.ChatTopics, error) {
+	result := store.ChatTopics{ChatID: chatID, ComputedAt: time.Now()}
+
+	messages, err := bs.store.EmbeddedMessages(chatID, time.Now().Add(-topicWindow), topicMaxMessages)
+	if err != nil {
+		return result, err
+	}
+	result.Messages = len(messages)
+	if len(messages) < topicMinSize {
+		return result, nil
+	}
+
+	ids := make([]int, len(messages))
+	for i, m := range messages {
+		ids[i] = m.MessageID
+	}
+	points, err := bs.vectors.Fetch(ctx, chatID, ids)
+	if err != nil {
+		return result, fmt.Errorf("loading vectors: %w", err)
+	}
+	vectors := make(map[int][]float32, len(points))
+	for _, p := range points {
+		vectors[p.MessageID] = p.Vector
+	}
+
+	clusters := clusterMessages(messages, vectors)
+	if len(clusters) == 0 {
+		return result, nil
+	}
+	labels, err := bs.labelTopics(ctx, clusters)
+	if err != nil {
+		return result, err
+	}
+
+	for i, c := range clusters {
+		topic := store.Topic{Label: labels[i], Count: len(c.messages), LatestAt: c.messages[0].Timestamp}
+		for _, m := range c.messages[:min(topicLinks, len(c.messages))] {
+			topic.MessageIDs = append(topic.MessageIDs, m.MessageID)
+		}
+		result.Topics = append(result.Topics, topic)
+	}
+	return result, nil
+}
+
+// labelTopics asks the model for a short name per cluster, in one call for all of them
+func (bs *BotService) labelTopics(ctx context.Context, clusters []topicCluster) ([]string, error) {
+	var sb strings.Builder
+	for i, c := range clusters {
+		fmt.Fprintf(&sb, "\nGroup %d:\n", i+1)
+		for _, m := range c.messages[:min(topicSamples, len(c.messages))] {
+			sb.WriteString("- " + truncateRunes(m.Text, 200) + "\n")
+		}
+	}
+
+	prompt := fmt.Sprintf(`These are groups of similar messages from a group chat. Name the topic of each group in 2-5 words, in the language of the messages.
+%s
+Answer with exactly one line per group, like "1. Weekend hiking trip", and nothing else.`, untrusted("chat messages", sb.String()))
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	answer, err := bs.generate(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, len(clusters))
+	for _, line := range strings.Split(answer, "\n") {
+		num, label, ok := strings.Cut(strings.TrimSpace(line), ".")
+		n, err := strconv.Atoi(strings.TrimSpace(num))
+		if !ok || err != nil || n < 1 || n > len(labels) {
+			continue
+		}
+		labels[n-1] = strings.Trim(strings.TrimSpace(label), `"*`)
+	}
+	for i := range labels {
+		if labels[i] == "" {
+			labels[i] = fmt.Sprintf("Topic %d", i+1)
+		}
+	}
+	return labels, nil
+}
+
+// refreshTopics recomputes and stores the topics of a chat
+func (bs *BotService) refreshTopics(chatID int64) (store.ChatTopics, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	topics, err := bs.computeTopics(ctx, chatID)
+	if err != nil {
+		return topics, err
+	}
+	return topics, bs.store.SaveChatTopics(topics)
+}
+
+// updateTopics recomputes the topics of every chat active in the last week once a day, at topicHour local time
+func (bs *BotService) updateTopics(now time.Time) {
+	if !bs.config().TopicsEnabled || topicsRunning.Load() {
+		return
+	}
+	chatIDs, err := bs.store.ActiveChatIDs(now.Add(-topicWindow))
+	if err != nil {
+		log.Printf("Error loading active chats: %v", err)
+		return
+	}
+
+	var due []int64
+	for _, chatID := range chatIDs {
+		local := now.In(bs.chatLocation(chatID))
+		if local.Hour() == topicHour && bs.claimDailyRun("topics", chatID, local.Format("2006-01-02")) {
+			due = append(due, chatID)
+		}
+	}
+	if len(due) == 0 || !topicsRunning.CompareAndSwap(false, true) {
+		return
+	}
+
+	// One chat after another in the background, so the model isn't flooded and the scheduler keeps ticking
+	bs.goSafe("topic clustering", func() {
+		defer topicsRunning.Store(false)
+		for _, chatID := range due {
+			if _, err := bs.refreshTopics(chatID); err != nil {
+				log.Printf("Error computing topics for chat %d: %v", chatID, err)
+			}
+		}
+	})
+}
+
+func (bs *BotService) handleTopics(msg *tgbotapi.Message) {
+	var topics store.ChatTopics
+	var ok bool
+	var err error
+
+	if strings.TrimSpace(msg.CommandArguments()) == "refresh" {
+		if bs.userRole(msg.Chat, msg.From) < ChatAdmin {
+			bs.Reply(msg, "Only admins can refresh the topics.")
+			return
+		}
+		bs.Reply(msg, topicsRefreshMsg)
+		topics, err = bs.refreshTopics(msg.Chat.ID)
+		ok = err == nil
+	} else {
+		topics, ok, err = bs.store.LoadChatTopics(msg.Chat.ID)
+	}
+	if err != nil {
+		log.Printf("Error loading topics for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	tags := bs.formatTagCounts(msg.Chat.ID)
+	if !ok || len(topics.Topics) == 0 {
+		bs.Reply(msg, strings.TrimSpace(noTopicsMsg+"\n\n"+tags))
+		return
+	}
+	reply := bs.formatTopics(msg.Chat, topics)
+	if tags != "" {
+		reply += "\n\n" + tags
+	}
+	bs.Reply(msg, reply)
+}
+
+func (bs *BotService) formatTopics(chat *tgbotapi.Chat, topics store.ChatTopics) string {
+	loc := bs.chatLocation(chat.ID)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🗂 What this chat has been about this week (%d messages):\n", topics.Messages)
+	for i, t := range topics.Topics {
+		fmt.Fprintf(&sb, "\n%d. %s: %d messages, latest %s", i+1, t.Label, t.Count, t.LatestAt.In(loc).Format("Jan 2"))
+		for _, id := range t.MessageIDs {
+			if link := messageLink(chat, id); link != "" {
+				sb.WriteString("\n   " + link)
+			}
+		}
+	}
+	fmt.Fprintf(&sb, "\n\nUpdated %s", topics.ComputedAt.In(loc).Format("Jan 2 15:04"))
+	return sb.String()
+}
@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	maxTranscriptMessages = 5000
+	// maxTranscriptDepth is how deep reply chains are indented
+	maxTranscriptDepth = 4
+	transcriptUsageMsg = "Usage: /transcript [today|yesterday|this week|last week] [md|html], today as Markdown by default"
+	transcriptHelp     = `Sends the stored messages of a day or week as a document: Markdown, or a standalone HTML page with html. Names,
+times and media are kept, and replies are indented under the message they answer.
+Examples:
+/transcript
+/transcript last week html`
+)
+
+// mediaKind names the media a message carries, "" for text messages
+func mediaKind(msg *tgbotapi.Message) string {
+	switch {
+	case msg.Photo != nil:
+		return "photo"
+	case msg.Video != nil:
+		return "video"
+	case msg.Animation != nil:
+		return "GIF"
+	case msg.Voice != nil:
+		return "voice message"
+	case msg.Audio != nil:
+		return "audio"
+	case msg.Document != nil:
+		return "file " + msg.Document.FileName
+	case msg.Sticker != nil:
+		return "sticker"
+	default:
+		return ""
+	}
+}
+
+// transcriptLine is a stored message with how deep in a reply chain it is
+type transcriptLine struct {
+	store.Message
+	depth int
+}
+
+// handleTranscript sends the messages of a day or week as a Markdown or standalone HTML document, with replies
+// indented under the message they answer
+func (bs *BotService) handleTranscript(msg *tgbotapi.Message) {
+	format, period := "md", "today"
+	var rest []string
+	for _, arg := range strings.Fields(strings.ToLower(msg.CommandArguments())) {
+		if arg == "md" || arg == "markdown" || arg == "html" {
+			format = strings.TrimSuffix(arg, "down")
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	if len(rest) > 0 {
+		period = strings.Join(rest, " ")
+	}
+	loc := bs.chatLocation(msg.Chat.ID)
+	from, to, ok := parseDayRange(period, time.Now().In(loc))
+	if !ok {
+		bs.Reply(msg, transcriptUsageMsg)
+		return
+	}
+
+	messages, err := bs.store.FindMessages(bson.M{"chat_id": msg.Chat.ID, "timestamp": bson.M{"$gte": from, "$lt": to}},
+		maxTranscriptMessages)
+	if err != nil {
+		log.Printf("Error loading messages of chat %d for a transcript: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(messages) == 0 {
+		bs.Reply(msg, fmt.Sprintf("No messages stored for %s.", period))
+		return
+	}
+	slices.Reverse(messages)
+
+	title := fmt.Sprintf("%s, %s", bs.transcriptChatTitle(msg.Chat), transcriptPeriod(from, to))
+	lines := threadTranscript(messages)
+	var data []byte
+	if format == "html" {
+		data = renderTranscriptHTML(title, lines, loc)
+	} else {
+		data = renderTranscriptMarkdown(title, lines, loc)
+	}
+
+	caption := fmt.Sprintf("📜 Transcript of %d messages", len(messages))
+	if len(messages) == maxTranscriptMessages {
+		caption += fmt.Sprintf(" (the last %d)", maxTranscriptMessages)
+	}
+	bs.sendFile(msg, fmt.Sprintf("transcript-%s.%s", from.Format("2006-01-02"), format), data, caption)
+}
+
+func (bs *BotService) transcriptChatTitle(chat *tgbotapi.Chat) string {
+	if chat.IsPrivate() {
+		return "Chat with " + strings.TrimPrefix(bs.botMention, "@")
+	}
+	if chat.Title != "" {
+		return chat.Title
+	}
+	return "Untitled chat"
+}
+
+// transcriptPeriod describes [from, to) as one day or a range of days
+func transcriptPeriod(from, to time.Time) string {
+	last := to.AddDate(0, 0, -1)
+	if !last.After(from) {
+		return from.Format("Monday, January 2, 2006")
+	}
+	return fmt.Sprintf("%s to %s", from.Format("January 2"), last.Format("January 2, 2006"))
+}
+
+// threadTranscript sets how deep in a reply chain each message is; replies to messages outside the transcript
+// aren't indented
+func threadTranscript(messages []store.Message) []transcriptLine {
+	depths := make(map[int]int, len(messages))
+	lines := make([]transcriptLine, len(messages))
+	for i, m := range messages {
+		depth := 0
+		if parent, ok := depths[m.ReplyTo]; ok && m.ReplyTo != 0 {
+			depth = min(parent+1, maxTranscriptDepth)
+		}
+		depths[m.MessageID] = depth
+		lines[i] = transcriptLine{Message: m, depth: depth}
+	}
+	return lines
+}
+
+func renderTranscriptMarkdown(title string, lines []transcriptLine, loc *time.Location) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n", title)
+	var day string
+	for _, l := range lines {
+		local := l.Timestamp.In(loc)
+		if d := local.Format("Monday, January 2"); d != day {
+			day = d
+			fmt.Fprintf(&sb, "\n## %s\n", day)
+		}
+		quote := strings.Repeat("> ", l.depth)
+		fmt.Fprintf(&sb, "\n%s**%s** · %s\n", quote, l.Author(), local.Format("15:04"))
+		if l.Media != "" {
+			fmt.Fprintf(&sb, "%s_[%s]_\n", quote, l.Media)
+		}
+		for _, line := range strings.Split(l.Text, "\n") {
+			fmt.Fprintf(&sb, "%s%s  \n", quote, line)
+		}
+	}
+	return []byte(sb.String())
+}
+
+const transcriptStyle = `body{font-family:system-ui,sans-serif;max-width:48rem;margin:2rem auto;padding:0 1rem;color:#222}
+h2{font-size:1rem;color:#666;border-bottom:1px solid #ddd;padding-bottom:.25rem;margin-top:2rem}
+.msg{margin:.75rem 0}.reply{border-left:3px solid #ccd;padding-left:.75rem}
+.author{font-weight:600}.time{color:#888;font-size:.85em;margin-left:.5rem}
+.media{color:#666;font-style:italic}.text{white-space:pre-wrap}`
+
+func renderTranscriptHTML(title string, lines []transcriptLine, loc *time.Location) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n<h1>%s</h1>\n",
+		html.EscapeString(title), transcriptStyle, html.EscapeString(title))
+	var day string
+	for _, l := range lines {
+		local := l.Timestamp.In(loc)
+		if d := local.Format("Monday, January 2"); d != day {
+			day = d
+			fmt.Fprintf(&sb, "<h2>%s</h2>\n", day)
+		}
+		class := "msg"
+		if l.depth > 0 {
+			class += " reply"
+		}
+		fmt.Fprintf(&sb, `<div class="%s" style="margin-left:%drem">`, class, l.depth*2)
+		fmt.Fprintf(&sb, `<span class="author">%s</span><span class="time">%s</span>`, html.EscapeString(l.Author()), local.Format("15:04"))
+		if l.Media != "" {
+			fmt.Fprintf(&sb, `<div class="media">[%s]</div>`, html.EscapeString(l.Media))
+		}
+		fmt.Fprintf(&sb, "<div class=\"text\">%s</div></div>\n", html.EscapeString(l.Text))
+	}
+	sb.WriteString("</body>\n</html>\n")
+	return []byte(sb.String())
+}
@@ -0,0 +1,174 @@
+package bot
+
+import (
+	"errors"
+	"log"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// maxMessageLength is the longest text Telegram accepts in one message
+	maxMessageLength = 4096
+	// sendRetries is how many more times a chunk is sent after Telegram rejects it for being busy
+	sendRetries      = 2
+	maxSendRetryWait = 30 * time.Second
+
+	truncatedButton      = "✂️ Message truncated, tap to get the rest in a DM"
+	remainderSentMsg     = "Sent you the rest in a DM."
+	remainderGoneMsg     = "The rest of this message is no longer available."
+	remainderStartDMMsg  = "I couldn't DM you, start a chat with me first and tap again."
+	remainderMissingNote = "(The rest of this message couldn't be delivered.)"
+)
+
+// splitMessage splits text into chunks of at most limit bytes that join back into text. Chunks end at a line
+// break or space where there is one, before a code fence or, in HTML, an element like <pre> rather than inside
+// it, and never inside a character or an HTML tag or entity.
+func splitMessage(text string, limit int, isHTML bool) []string {
+	var chunks []string
+	for len(text) > limit {
+		cut := splitPoint(text, limit, isHTML)
+		chunks = append(chunks, text[:cut])
+		text = text[cut:]
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+// splitPoint is where to end the first chunk of text, which is longer than limit
+func splitPoint(text string, limit int, isHTML bool) int {
+	best, bestRank := 0, math.MinInt
+	fenced, depth, inTag, inEntity := false, 0, false, false
+	for i := 0; i < limit; i++ {
+		switch c := text[i]; {
+		case c == '`' && strings.HasPrefix(text[i:], codeFence):
+			fenced = !fenced
+			i += len(codeFence) - 1
+		case isHTML && c == '<':
+			inTag = true
+			if strings.HasPrefix(text[i+1:], "/") {
+				depth--
+			} else {
+				depth++
+			}
+		case isHTML && c == '>':
+			inTag = false
+		case isHTML && c == '&':
+			inEntity = true
+		case c == ';' || c == ' ' || c == '\n':
+			inEntity = false
+		}
+
+		end := i + 1
+		if end > limit || inTag || inEntity || !utf8.RuneStart(text[end]) {
+			continue
+		}
+		rank := 0
+		switch text[i] {
+		case '\n':
+			rank = 2
+		case ' ':
+			rank = 1
+		}
+		// A short chunk beats a cut element, which Telegram rejects, and any end in the second half beats a
+		// needlessly short chunk
+		if !fenced && depth <= 0 {
+			rank += 20
+		}
+		if end < limit/2 {
+			rank -= 10
+		}
+		if rank >= bestRank {
+			best, bestRank = end, rank
+		}
+	}
+
+	if best == 0 {
+		// A tag or entity longer than a chunk is cut between characters
+		best = limit
+		for best > 0 && !utf8.RuneStart(text[best]) {
+			best--
+		}
+	}
+	return best
+}
+
+// sendChunk sends one message, retrying when Telegram asks to slow down or fails on its side. Network
+// errors are not retried, the message may have gone through and would be sent twice.
+func (bs *BotService) sendChunk(chunk tgbotapi.MessageConfig) (tgbotapi.Message, error) {
+	for attempt := 0; ; attempt++ {
+		sent, err := bs.api.Send(chunk)
+		if err == nil || attempt == sendRetries {
+			return sent, err
+		}
+		var tgErr *tgbotapi.Error
+		if !errors.As(err, &tgErr) {
+			return sent, err
+		}
+		switch {
+		case tgErr.RetryAfter > 0:
+			bs.noteRateLimited(chunk.ChatID, tgErr.RetryAfter)
+			time.Sleep(min(time.Duration(tgErr.RetryAfter)*time.Second, maxSendRetryWait))
+		case tgErr.Code >= 500:
+			time.Sleep(time.Duration(attempt+1) * time.Second)
+		default:
+			return sent, err
+		}
+	}
+}
+
+// offerRemainder keeps the unsent end of a message and adds a button to the last chunk sent that DMs it to
+// whoever taps it
+func (bs *BotService) offerRemainder(last tgbotapi.Message, parseMode, text string) {
+	id, err := bs.store.SaveRemainder(last.Chat.ID, parseMode, text)
+	if err != nil {
+		log.Printf("Error saving the remainder of a truncated message in chat %d: %v", last.Chat.ID, err)
+		bs.send(tgbotapi.NewMessage(last.Chat.ID, remainderMissingNote))
+		return
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(truncatedButton, "rest:"+id.Hex()),
+	))
+	if _, err := bs.api.Send(tgbotapi.NewEditMessageReplyMarkup(last.Chat.ID, last.MessageID, keyboard)); err != nil {
+		log.Printf("failed to mark message %d in chat %d as truncated: %v", last.MessageID, last.Chat.ID, err)
+	}
+}
+
+// handleRemainderCallback DMs the rest of a truncated message to the user who tapped its button
+func (bs *BotService) handleRemainderCallback(cb *tgbotapi.CallbackQuery) {
+	id, err := primitive.ObjectIDFromHex(cb.Data[len("rest:"):])
+	if err != nil {
+		bs.answerCallback(cb.ID, "")
+		return
+	}
+	remainder, err := bs.store.Remainder(id)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("Error loading a message remainder: %v", err)
+		}
+		bs.answerCallback(cb.ID, remainderGoneMsg)
+		return
+	}
+
+	// The first chunk shows whether the DM can be sent at all
+	chunks := splitMessage(remainder.Text, maxMessageLength, remainder.ParseMode == tgbotapi.ModeHTML)
+	dm := tgbotapi.NewMessage(cb.From.ID, chunks[0])
+	dm.ParseMode = remainder.ParseMode
+	if _, err := bs.sendChunk(dm); err != nil {
+		bs.answerCallback(cb.ID, remainderStartDMMsg)
+		return
+	}
+	if len(chunks) > 1 {
+		dm.Text = remainder.Text[len(chunks[0]):]
+		bs.send(dm)
+	}
+	bs.answerCallback(cb.ID, remainderSentMsg)
+}
@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	fenced := "```go\nfmt.Println(1)\nfmt.Println(2)\n```"
+	tests := []struct {
+		name   string
+		text   string
+		limit  int
+		isHTML bool
+		want   []string
+	}{
+		{name: "empty", text: "", limit: 10},
+		{name: "short", text: "hello", limit: 10, want: []string{"hello"}},
+		{name: "exact limit", text: "0123456789", limit: 10, want: []string{"0123456789"}},
+		{name: "one over the limit", text: "0123456789a", limit: 10, want: []string{"0123456789", "a"}},
+		{name: "at a line break", text: "first line\nsecond line", limit: 16, want: []string{"first line\n", "second line"}},
+		{name: "at a space", text: "lorem ipsum dolor", limit: 14, want: []string{"lorem ipsum ", "dolor"}},
+		{name: "line break over space", text: "one two\nthree four five", limit: 16, want: []string{"one two\n", "three four five"}},
+		{name: "not needlessly short", text: "a\nbcdefghijklmnop", limit: 10, want: []string{"a\nbcdefghi", "jklmnop"}},
+		{name: "multi-byte runes", text: "ééééé", limit: 5, want: []string{"éé", "éé", "é"}},
+		{name: "emoji", text: "👍👍👍", limit: 6, want: []string{"👍", "👍", "👍"}},
+		{name: "before a code fence", text: "intro text\n" + fenced, limit: 45, want: []string{"intro text\n", fenced}},
+		{name: "inside a code fence too long for a chunk", text: fenced + " end", limit: 30, want: []string{"```go\nfmt.Println(1)\n", "fmt.Println(2)\n``` end"}},
+		{name: "not inside a tag", text: `see <a href="https://example.com">this</a>`, limit: 40, isHTML: true, want: []string{"see ", `<a href="https://example.com">this</a>`}},
+		{name: "not inside an entity", text: "salt &amp; pepper", limit: 7, isHTML: true, want: []string{"salt ", "&amp; ", "pepper"}},
+		{name: "before a pre block", text: "Run this:\n<pre>go test ./...</pre>", limit: 30, isHTML: true, want: []string{"Run this:\n", "<pre>go test ./...</pre>"}},
+		{name: "angle bracket in plain text", text: "a < b and b > c", limit: 9, want: []string{"a < b ", "and b > c"}},
+		{name: "tag longer than a chunk", text: `<a href="https://example.com">x</a>`, limit: 10, isHTML: true, want: []string{`<a href="h`, `ttps://exa`, `mple.com">`, `x</a>`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMessage(tt.text, tt.limit, tt.isHTML)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("splitMessage(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+			}
+			if strings.Join(got, "") != tt.text {
+				t.Errorf("splitMessage(%q, %d) = %q, which doesn't join back into the text", tt.text, tt.limit, got)
+			}
+			for _, chunk := range got {
+				if len(chunk) > tt.limit || !utf8.ValidString(chunk) {
+					t.Errorf("splitMessage(%q, %d) has the chunk %q, want at most %d bytes of valid UTF-8", tt.text, tt.limit, chunk, tt.limit)
+				}
+			}
+		})
+	}
+}
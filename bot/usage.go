@@ -0,0 +1,223 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/llm"
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+const (
+	defaultUsageDays = 30
+	maxUsageDays     = 365
+	maxUsageChats    = 10
+	usageUsageMsg    = "Usage: /usage [days], 30 days by default"
+)
+
+// recordUsage stores the tokens and latency of a model request, for /usage and the metrics endpoint
+func (bs *BotService) recordUsage(ctx context.Context, usage *llm.TokenUsage, latency time.Duration, genErr error) {
+	bs.metrics.aiLatencyMS.Add(latency.Milliseconds())
+
+	record := store.UsageRecord{
+		Model:        cmp.Or(llm.ModelFromContext(ctx), bs.config().Model.Model, llm.DefaultModel),
+		PromptTokens: usage.PromptTokens.Load(),
+		OutputTokens: usage.OutputTokens.Load(),
+		CachedTokens: usage.CachedTokens.Load(),
+		LatencyMS:    latency.Milliseconds(),
+		Failed:       genErr != nil,
+		CreatedAt:    time.Now(),
+	}
+	if o, ok := ctx.Value(originKey{}).(origin); ok {
+		record.ChatID = o.chatID
+		if !bs.config().StrictPrivacy {
+			record.UserID = o.userID
+		}
+	}
+	if err := bs.store.InsertUsage(record); err != nil {
+		log.Printf("Error recording model usage: %v", err)
+	}
+}
+
+// handleUsage shows the model requests, tokens and latency of the chat; the owner gets the whole bot's in a DM,
+// with the chats using the most tokens
+func (bs *BotService) handleUsage(msg *tgbotapi.Message) {
+	days := defaultUsageDays
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > maxUsageDays {
+			bs.Reply(msg, usageUsageMsg)
+			return
+		}
+		days = n
+	}
+	now := time.Now()
+	since := now.AddDate(0, 0, -days)
+	everything := msg.Chat.IsPrivate() && bs.userRole(msg.Chat, msg.From) == Owner
+
+	var chatIDs []int64
+	if !everything {
+		chatIDs = []int64{msg.Chat.ID}
+	}
+	models, err := bs.store.UsageByModel(since, now, chatIDs...)
+	if err != nil {
+		log.Printf("Error loading the model usage of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if len(models) == 0 {
+		bs.Reply(msg, fmt.Sprintf("No AI requests in the last %d days.", days))
+		return
+	}
+
+	var sb strings.Builder
+	scope := "this chat"
+	if everything {
+		scope = "all chats"
+	}
+	fmt.Fprintf(&sb, "📊 AI usage of %s in the last %d days\n\n", scope, days)
+	sb.WriteString(formatUsageTotals(sumUsage(models)))
+	pricing := bs.config().Pricing
+	if line := pricing.costSummary(models); line != "" {
+		sb.WriteString("\n" + line)
+	}
+	if len(models) > 1 {
+		sb.WriteString("\n\nBy model:")
+		for _, m := range models {
+			fmt.Fprintf(&sb, "\n%s: %d requests, %d tokens", m.Model, m.Requests, m.Tokens())
+			if cost, ok := pricing.cost(m); ok {
+				fmt.Fprintf(&sb, ", %s", pricing.formatCost(cost))
+			}
+		}
+	}
+
+	if everything {
+		if top := bs.topUsageChats(since, now, maxUsageChats); top != "" {
+			sb.WriteString("\n\nTop chats:" + top)
+		}
+	}
+	bs.Reply(msg, sb.String())
+}
+
+// sumUsage adds up usage totals, like those of every model
+func sumUsage(rows []store.UsageTotals) store.UsageTotals {
+	var total store.UsageTotals
+	for _, r := range rows {
+		total.Requests += r.Requests
+		total.Failures += r.Failures
+		total.PromptTokens += r.PromptTokens
+		total.OutputTokens += r.OutputTokens
+		total.CachedTokens += r.CachedTokens
+		total.LatencyMS += r.LatencyMS
+		total.MaxLatencyMS = max(total.MaxLatencyMS, r.MaxLatencyMS)
+	}
+	return total
+}
+
+// chatUsage is the usage of one chat across models and what it is estimated to cost
+type chatUsage struct {
+	store.UsageTotals
+	cost float64
+}
+
+// topUsageChats lists the chats that used the most tokens in [from, to), with their estimated cost, one per line;
+// "" when there was no usage
+func (bs *BotService) topUsageChats(from, to time.Time, limit int) string {
+	rows, err := bs.store.UsageByChatAndModel(from, to)
+	if err != nil {
+		log.Printf("Error loading the model usage per chat: %v", err)
+		return ""
+	}
+	pricing := bs.config().Pricing
+	byChat := map[int64]*chatUsage{}
+	var chats []*chatUsage
+	for _, r := range rows {
+		c, ok := byChat[r.ChatID]
+		if !ok {
+			c = &chatUsage{}
+			byChat[r.ChatID] = c
+			chats = append(chats, c)
+		}
+		c.UsageTotals = sumUsage([]store.UsageTotals{c.UsageTotals, r})
+		c.ChatID = r.ChatID
+		if cost, ok := pricing.cost(r); ok {
+			c.cost += cost
+		}
+	}
+	slices.SortStableFunc(chats, func(a, b *chatUsage) int { return cmp.Compare(b.Tokens(), a.Tokens()) })
+
+	var sb strings.Builder
+	for i, c := range chats[:min(len(chats), limit)] {
+		name := "scheduled jobs"
+		if c.ChatID != 0 {
+			name = fmt.Sprintf("%s (%d)", cmp.Or(bs.chatTitle(c.ChatID), "untitled"), c.ChatID)
+		}
+		fmt.Fprintf(&sb, "\n%d. %s: %d requests, %d tokens, %s", i+1, name, c.Requests, c.Tokens(), pricing.formatCost(c.cost))
+	}
+	return sb.String()
+}
+
+// formatUsageTotals describes the requests, tokens and latency of usage totals
+func formatUsageTotals(t store.UsageTotals) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Requests: %d", t.Requests)
+	if t.Failures > 0 {
+		fmt.Fprintf(&sb, " (%d failed)", t.Failures)
+	}
+	fmt.Fprintf(&sb, "\nTokens: %d prompt", t.PromptTokens)
+	if t.CachedTokens > 0 {
+		fmt.Fprintf(&sb, " (%d cached)", t.CachedTokens)
+	}
+	fmt.Fprintf(&sb, ", %d output", t.OutputTokens)
+	fmt.Fprintf(&sb, "\nLatency: %s average, %s slowest", t.AverageLatency().Round(10*time.Millisecond),
+		(time.Duration(t.MaxLatencyMS) * time.Millisecond).Round(10*time.Millisecond))
+	return sb.String()
+}
+
+// handleMetrics serves the counters of every bot and the model's usage since startup in the Prometheus
+// text format
+func (bs *BotService) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	bots := bs.fleet
+	if len(bots) == 0 {
+		bots = []*BotService{bs}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	counters := []struct {
+		name, help string
+		value      func(*BotService) int64
+	}{
+		{"chatbuddy_updates_total", "Telegram updates handled.", func(b *BotService) int64 { return b.metrics.updates.Load() }},
+		{"chatbuddy_commands_total", "Commands handled.", func(b *BotService) int64 { return b.metrics.commands.Load() }},
+		{"chatbuddy_ai_requests_total", "Model requests made.", func(b *BotService) int64 { return b.metrics.aiRequests.Load() }},
+		{"chatbuddy_ai_failures_total", "Model requests that failed.", func(b *BotService) int64 { return b.metrics.aiFailures.Load() }},
+		{"chatbuddy_ai_request_duration_milliseconds_sum", "Total latency of model requests.", func(b *BotService) int64 { return b.metrics.aiLatencyMS.Load() }},
+		{"chatbuddy_rate_limited_total", "Messages Telegram refused with a flood limit.", func(b *BotService) int64 { return b.metrics.rateLimited.Load() }},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, b := range bots {
+			fmt.Fprintf(w, "%s{bot=%q} %d\n", c.name, strings.TrimPrefix(b.botMention, "@"), c.value(b))
+		}
+	}
+	fmt.Fprintf(w, "# HELP chatbuddy_send_queue_depth Digests and notifications held back for throttled chats.\n# TYPE chatbuddy_send_queue_depth gauge\n")
+	for _, b := range bots {
+		fmt.Fprintf(w, "chatbuddy_send_queue_depth{bot=%q} %d\n", strings.TrimPrefix(b.botMention, "@"), b.queueDepth())
+	}
+
+	if reporter, ok := bs.gemini.(llm.UsageReporter); ok {
+		usage := reporter.Usage()
+		fmt.Fprintf(w, "# HELP chatbuddy_model_tokens_total Tokens used by model requests of all bots.\n# TYPE chatbuddy_model_tokens_total counter\n")
+		fmt.Fprintf(w, "chatbuddy_model_tokens_total{kind=\"prompt\"} %d\n", usage.PromptTokens)
+		fmt.Fprintf(w, "chatbuddy_model_tokens_total{kind=\"output\"} %d\n", usage.OutputTokens)
+		fmt.Fprintf(w, "chatbuddy_model_tokens_total{kind=\"cached\"} %d\n", usage.CachedTokens)
+	}
+}
@@ -0,0 +1,288 @@
+package bot
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// The utility commands answer locally, without the model
+const (
+	maxRegexMatches = 10
+	maxUUIDs        = 10
+	regexHelp       = `Tests a Go (RE2) regular expression: the pattern is the first word, the text the rest, or the replied message.
+Examples:
+/regex \d{3}-\d{4} call 555-1234 or 555-9876
+/regex (?i)^hello (reply to a message)`
+	jsonHelp = `Checks and pretty-prints JSON, or minifies it. The JSON is the rest of the message or the replied message.
+Examples:
+/json {"a":1,"b":[1,2]}
+/json minify (reply to a message)`
+	base64Help = `Encodes text to base64 or decodes it; decoding accepts standard and URL-safe base64, padded or not.
+Examples:
+/base64 encode hello world
+/base64 decode aGVsbG8gd29ybGQ=`
+	hashHelp = `Hashes text with md5, sha1, sha256 or sha512; without an algorithm you get them all.
+Examples:
+/hash sha256 hello
+/hash hello`
+	uuidHelp = `Generates random (version 4) UUIDs, one by default and up to 10.
+Example: /uuid 3`
+	timestampHelp = `Converts between Unix timestamps and dates, in UTC and the chat's timezone. Without an argument it shows the current time.
+Examples:
+/timestamp 1700000000
+/timestamp 2024-03-01 14:30
+/timestamp 2024-03-01T14:30:00Z`
+)
+
+type hashAlgorithm struct {
+	name string
+	new  func() hash.Hash
+}
+
+// hashes are the algorithms /hash offers, in the order it lists them
+var hashes = []hashAlgorithm{
+	{"md5", md5.New}, {"sha1", sha1.New}, {"sha256", sha256.New}, {"sha512", sha512.New},
+}
+
+// timestampLayouts are the date formats /timestamp understands, tried in order
+var timestampLayouts = []string{
+	time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", time.RFC1123Z, time.RFC1123,
+}
+
+// utilityInput is the text a utility command works on: the arguments after skip words, or the replied message
+func utilityInput(msg *tgbotapi.Message, skip int) string {
+	args := msg.CommandArguments()
+	for range skip {
+		args = strings.TrimLeft(args, " \t\n")
+		if i := strings.IndexAny(args, " \t\n"); i >= 0 {
+			args = args[i:]
+		} else {
+			args = ""
+		}
+	}
+	if args = strings.TrimSpace(args); args == "" && msg.ReplyToMessage != nil {
+		return msg.ReplyToMessage.Text + msg.ReplyToMessage.Caption
+	}
+	return args
+}
+
+// replyCode replies with text as a copyable code block, with an optional note above it
+func (bs *BotService) replyCode(msg *tgbotapi.Message, note, lang, text string) {
+	bs.replyWithCode(msg, strings.TrimSpace(note+"\n"+codeFence+lang+"\n"+text+"\n"+codeFence))
+}
+
+func (bs *BotService) handleRegex(msg *tgbotapi.Message) {
+	fields := strings.Fields(msg.CommandArguments())
+	if len(fields) == 0 {
+		bs.Reply(msg, "Usage: /regex <pattern> <text>\n\n"+regexHelp)
+		return
+	}
+	re, err := regexp.Compile(fields[0])
+	if err != nil {
+		bs.Reply(msg, "That's not a valid pattern: "+err.Error())
+		return
+	}
+	text := utilityInput(msg, 1)
+	if text == "" {
+		bs.Reply(msg, "Give me some text to test, after the pattern or by replying to a message.")
+		return
+	}
+
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		bs.Reply(msg, "❌ No match.")
+		return
+	}
+	var sb strings.Builder
+	for i, m := range matches[:min(len(matches), maxRegexMatches)] {
+		fmt.Fprintf(&sb, "%d: %q at %d", i+1, text[m[0]:m[1]], utf8.RuneCountInString(text[:m[0]]))
+		for g := 1; g < len(m)/2; g++ {
+			if m[2*g] < 0 {
+				continue
+			}
+			name := strconv.Itoa(g)
+			if n := re.SubexpNames()[g]; n != "" {
+				name = n
+			}
+			fmt.Fprintf(&sb, "\n   group %s: %q", name, text[m[2*g]:m[2*g+1]])
+		}
+		sb.WriteString("\n")
+	}
+	if len(matches) > maxRegexMatches {
+		fmt.Fprintf(&sb, "... and %d more\n", len(matches)-maxRegexMatches)
+	}
+	bs.replyCode(msg, fmt.Sprintf("✅ %d matches:", len(matches)), "", strings.TrimSuffix(sb.String(), "\n"))
+}
+
+func (bs *BotService) handleJSON(msg *tgbotapi.Message) {
+	minify := false
+	skip := 0
+	if fields := strings.Fields(msg.CommandArguments()); len(fields) > 0 {
+		switch strings.ToLower(fields[0]) {
+		case "minify":
+			minify, skip = true, 1
+		case "pretty":
+			skip = 1
+		}
+	}
+	input := utilityInput(msg, skip)
+	if input == "" {
+		bs.Reply(msg, "Usage: /json [pretty|minify] <json>\n\n"+jsonHelp)
+		return
+	}
+
+	var out bytes.Buffer
+	var err error
+	if minify {
+		err = json.Compact(&out, []byte(input))
+	} else {
+		err = json.Indent(&out, []byte(input), "", "  ")
+	}
+	if err != nil {
+		bs.Reply(msg, "❌ Invalid JSON: "+err.Error())
+		return
+	}
+	bs.replyCode(msg, "", "json", out.String())
+}
+
+func (bs *BotService) handleBase64(msg *tgbotapi.Message) {
+	fields := strings.Fields(msg.CommandArguments())
+	input := utilityInput(msg, 1)
+	if len(fields) == 0 || input == "" {
+		bs.Reply(msg, "Usage: /base64 encode|decode <text>\n\n"+base64Help)
+		return
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "encode":
+		bs.replyCode(msg, "", "", base64.StdEncoding.EncodeToString([]byte(input)))
+	case "decode":
+		decoded, err := decodeBase64(input)
+		if err != nil {
+			bs.Reply(msg, "❌ That's not valid base64.")
+			return
+		}
+		if !utf8.Valid(decoded) {
+			bs.replyCode(msg, fmt.Sprintf("%d bytes of binary data, as hex:", len(decoded)), "", hex.EncodeToString(decoded))
+			return
+		}
+		bs.replyCode(msg, "", "", string(decoded))
+	default:
+		bs.Reply(msg, "Usage: /base64 encode|decode <text>\n\n"+base64Help)
+	}
+}
+
+// decodeBase64 accepts standard and URL-safe base64, with or without padding
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Join(strings.Fields(s), "")
+	var err error
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		var decoded []byte
+		if decoded, err = enc.DecodeString(s); err == nil {
+			return decoded, nil
+		}
+	}
+	return nil, err
+}
+
+func (bs *BotService) handleHash(msg *tgbotapi.Message) {
+	selected := hashes
+	skip := 0
+	if fields := strings.Fields(msg.CommandArguments()); len(fields) > 0 {
+		if i := slices.IndexFunc(hashes, func(h hashAlgorithm) bool { return strings.EqualFold(h.name, fields[0]) }); i >= 0 {
+			selected, skip = hashes[i:i+1], 1
+		}
+	}
+	input := utilityInput(msg, skip)
+	if input == "" {
+		bs.Reply(msg, "Usage: /hash [md5|sha1|sha256|sha512] <text>\n\n"+hashHelp)
+		return
+	}
+
+	var sb strings.Builder
+	for _, h := range selected {
+		sum := h.new()
+		sum.Write([]byte(input))
+		if len(selected) > 1 {
+			sb.WriteString(h.name + ": ")
+		}
+		sb.WriteString(hex.EncodeToString(sum.Sum(nil)) + "\n")
+	}
+	bs.replyCode(msg, "", "", strings.TrimSuffix(sb.String(), "\n"))
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func (bs *BotService) handleUUID(msg *tgbotapi.Message) {
+	n := 1
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		var err error
+		if n, err = strconv.Atoi(arg); err != nil || n < 1 || n > maxUUIDs {
+			bs.Reply(msg, "Usage: /uuid [count]\n\n"+uuidHelp)
+			return
+		}
+	}
+	uuids := make([]string, n)
+	for i := range uuids {
+		uuids[i] = newUUID()
+	}
+	bs.replyCode(msg, "", "", strings.Join(uuids, "\n"))
+}
+
+// parseTimestamp reads a Unix timestamp in seconds or milliseconds, or a date in one of timestampLayouts;
+// dates without a zone are in loc
+func parseTimestamp(s string, loc *time.Location) (time.Time, bool) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		// Timestamps past the year 33658 in seconds are taken as milliseconds
+		if n > 1e12 || n < -1e12 {
+			return time.UnixMilli(n), true
+		}
+		return time.Unix(n, 0), true
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (bs *BotService) handleTimestamp(msg *tgbotapi.Message) {
+	loc := bs.chatLocation(msg.Chat.ID)
+	t := time.Now()
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		var ok bool
+		if t, ok = parseTimestamp(arg, loc); !ok {
+			bs.Reply(msg, "I can't read that date.\n\n"+timestampHelp)
+			return
+		}
+	}
+
+	text := fmt.Sprintf("Unix: %d\nUnix ms: %d\nUTC: %s\n%s: %s",
+		t.Unix(), t.UnixMilli(), t.UTC().Format(time.RFC3339), loc, t.In(loc).Format("2006-01-02 15:04:05 MST (Mon)"))
+	bs.replyCode(msg, "", "", text)
+}
@@ -0,0 +1,266 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// forecastDays is how many days /weather shows, today included
+	forecastDays = 3
+	// maxToolForecastDays bounds the forecast the weather tool can ask for
+	maxToolForecastDays = 7
+)
+
+// errPlaceNotFound is returned when geocoding finds nothing for a place name
+var errPlaceNotFound = errors.New("place not found")
+
+// place is a geocoded location
+type place struct {
+	Name      string  `json:"name"`
+	Admin1    string  `json:"admin1"`
+	Country   string  `json:"country"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+func (p place) String() string {
+	parts := []string{p.Name}
+	if p.Admin1 != "" && p.Admin1 != p.Name {
+		parts = append(parts, p.Admin1)
+	}
+	if p.Country != "" {
+		parts = append(parts, p.Country)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// forecast is the Open-Meteo forecast response, in °C, km/h and mm
+type forecast struct {
+	Timezone string `json:"timezone"`
+	Current  struct {
+		Time        string  `json:"time"`
+		Temperature float64 `json:"temperature_2m"`
+		FeelsLike   float64 `json:"apparent_temperature"`
+		Humidity    float64 `json:"relative_humidity_2m"`
+		WeatherCode int     `json:"weather_code"`
+		WindSpeed   float64 `json:"wind_speed_10m"`
+	} `json:"current"`
+	Daily struct {
+		Time          []string  `json:"time"`
+		WeatherCode   []int     `json:"weather_code"`
+		Max           []float64 `json:"temperature_2m_max"`
+		Min           []float64 `json:"temperature_2m_min"`
+		Precipitation []float64 `json:"precipitation_sum"`
+		RainChance    []float64 `json:"precipitation_probability_max"`
+		WindSpeed     []float64 `json:"wind_speed_10m_max"`
+	} `json:"daily"`
+}
+
+// weatherCodes describes the WMO weather interpretation codes Open-Meteo uses
+var weatherCodes = map[int]struct{ emoji, text string }{
+	0: {"☀️", "clear sky"}, 1: {"🌤", "mainly clear"}, 2: {"⛅", "partly cloudy"}, 3: {"☁️", "overcast"},
+	45: {"🌫", "fog"}, 48: {"🌫", "rime fog"},
+	51: {"🌦", "light drizzle"}, 53: {"🌦", "drizzle"}, 55: {"🌧", "dense drizzle"}, 56: {"🌧", "freezing drizzle"}, 57: {"🌧", "freezing drizzle"},
+	61: {"🌦", "light rain"}, 63: {"🌧", "rain"}, 65: {"🌧", "heavy rain"}, 66: {"🌧", "freezing rain"}, 67: {"🌧", "freezing rain"},
+	71: {"🌨", "light snow"}, 73: {"🌨", "snow"}, 75: {"❄️", "heavy snow"}, 77: {"🌨", "snow grains"},
+	80: {"🌦", "light showers"}, 81: {"🌧", "showers"}, 82: {"⛈", "violent showers"}, 85: {"🌨", "snow showers"}, 86: {"❄️", "heavy snow showers"},
+	95: {"⛈", "thunderstorm"}, 96: {"⛈", "thunderstorm with hail"}, 99: {"⛈", "thunderstorm with heavy hail"},
+}
+
+func weatherDescription(code int) (emoji, text string) {
+	if w, ok := weatherCodes[code]; ok {
+		return w.emoji, w.text
+	}
+	return "🌡", "unknown"
+}
+
+// geocode finds a place by name with the Open-Meteo geocoding API at WEATHER_GEOCODING_URL
+func (bs *BotService) geocode(ctx context.Context, name string) (place, error) {
+	params := url.Values{"name": {name}, "count": {"1"}, "format": {"json"}}
+	var result struct {
+		Results []place `json:"results"`
+	}
+	if err := getJSON(ctx, bs.config().WeatherGeocodingURL+"?"+params.Encode(), &result); err != nil {
+		return place{}, err
+	}
+	if len(result.Results) == 0 {
+		return place{}, errPlaceNotFound
+	}
+	return result.Results[0], nil
+}
+
+// fetchForecast gets the current weather and a daily forecast for days days from the Open-Meteo compatible
+// API at WEATHER_API_URL, in the local time of the place
+func (bs *BotService) fetchForecast(ctx context.Context, latitude, longitude float64, days int) (*forecast, error) {
+	params := url.Values{
+		"latitude":      {strconv.FormatFloat(latitude, 'f', 4, 64)},
+		"longitude":     {strconv.FormatFloat(longitude, 'f', 4, 64)},
+		"current":       {"temperature_2m,apparent_temperature,relative_humidity_2m,weather_code,wind_speed_10m"},
+		"daily":         {"weather_code,temperature_2m_max,temperature_2m_min,precipitation_sum,precipitation_probability_max,wind_speed_10m_max"},
+		"timezone":      {"auto"},
+		"forecast_days": {strconv.Itoa(days)},
+	}
+	var f forecast
+	if err := getJSON(ctx, bs.config().WeatherAPIURL+"?"+params.Encode(), &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// formatForecast renders the current weather and the daily forecast as a short message
+func formatForecast(title string, f *forecast) string {
+	var sb strings.Builder
+	emoji, text := weatherDescription(f.Current.WeatherCode)
+	fmt.Fprintf(&sb, "%s Weather in %s\n\nNow: %s %s, %.0f°C (feels like %.0f°C), humidity %.0f%%, wind %.0f km/h\n",
+		emoji, title, emoji, text, f.Current.Temperature, f.Current.FeelsLike, f.Current.Humidity, f.Current.WindSpeed)
+
+	d := f.Daily
+	for i, day := range d.Time {
+		if i >= len(d.WeatherCode) || i >= len(d.Max) || i >= len(d.Min) {
+			break
+		}
+		label := day
+		if t, err := time.Parse("2006-01-02", day); err == nil {
+			label = t.Format("Mon Jan 2")
+		}
+		emoji, text := weatherDescription(d.WeatherCode[i])
+		fmt.Fprintf(&sb, "\n%s: %s %s, %.0f…%.0f°C", label, emoji, text, d.Min[i], d.Max[i])
+		if i < len(d.RainChance) && d.RainChance[i] > 0 {
+			fmt.Fprintf(&sb, ", %.0f%% chance of rain", d.RainChance[i])
+		}
+		if i < len(d.WindSpeed) {
+			fmt.Fprintf(&sb, ", wind up to %.0f km/h", d.WindSpeed[i])
+		}
+	}
+	return sb.String()
+}
+
+func (bs *BotService) handleWeather(msg *tgbotapi.Message) {
+	name := strings.TrimSpace(msg.CommandArguments())
+	if name == "" {
+		bs.Reply(msg, "Usage: /weather <city>\nYou can also send me a location.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	p, err := bs.geocode(ctx, name)
+	if errors.Is(err, errPlaceNotFound) {
+		bs.Reply(msg, fmt.Sprintf("I couldn't find a place called %q.", name))
+		return
+	}
+	if err != nil {
+		log.Printf("Error geocoding %q: %v", name, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	f, err := bs.fetchForecast(ctx, p.Latitude, p.Longitude, forecastDays)
+	if err != nil {
+		log.Printf("Error fetching the weather for %s: %v", p, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	bs.rememberWeatherPlace(msg, p.String(), p.Latitude, p.Longitude)
+	bs.Reply(msg, formatForecast(p.String(), f))
+}
+
+// rememberWeatherPlace saves the place a user last asked the weather for, which the weather tool uses
+// when a question doesn't name one
+func (bs *BotService) rememberWeatherPlace(msg *tgbotapi.Message, name string, latitude, longitude float64) {
+	if msg.From == nil {
+		return
+	}
+	err := bs.store.UpdateUserPreferences(msg.From.ID, bson.M{
+		"weather_place": name, "weather_latitude": latitude, "weather_longitude": longitude,
+	})
+	if err != nil {
+		log.Printf("Error saving the weather place of user %d: %v", msg.From.ID, err)
+	}
+}
+
+// handleLocation answers a shared location with its weather forecast
+func (bs *BotService) handleLocation(msg *tgbotapi.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	loc := msg.Location
+	f, err := bs.fetchForecast(ctx, loc.Latitude, loc.Longitude, forecastDays)
+	if err != nil {
+		log.Printf("Error fetching the weather for a location: %v", err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	title := fmt.Sprintf("%.3f, %.3f", loc.Latitude, loc.Longitude)
+	if msg.Venue != nil && msg.Venue.Title != "" {
+		title = msg.Venue.Title
+	}
+	bs.rememberWeatherPlace(msg, title, loc.Latitude, loc.Longitude)
+	bs.Reply(msg, formatForecast(title, f))
+}
+
+// weatherTool looks up the weather for the model, so questions like "should I bike tomorrow?" get real forecasts.
+// Without a location it uses the place the user last asked about or shared.
+func (bs *BotService) weatherTool(ctx context.Context, msg *tgbotapi.Message, location string, days int) (any, error) {
+	var p place
+	if location != "" {
+		var err error
+		if p, err = bs.geocode(ctx, location); err != nil {
+			return nil, err
+		}
+	} else {
+		var prefs store.UserPreferences
+		if msg.From != nil {
+			prefs = bs.store.UserPreferences(msg.From.ID)
+		}
+		if prefs.WeatherPlace == "" {
+			return nil, fmt.Errorf("no location known; ask the user where they are")
+		}
+		p = place{Name: prefs.WeatherPlace, Latitude: prefs.WeatherLatitude, Longitude: prefs.WeatherLongitude}
+	}
+	f, err := bs.fetchForecast(ctx, p.Latitude, p.Longitude, min(max(days, 1), maxToolForecastDays))
+	if err != nil {
+		return nil, err
+	}
+
+	type day struct {
+		Date                string  `json:"date"`
+		Conditions          string  `json:"conditions"`
+		MinC                float64 `json:"min_c"`
+		MaxC                float64 `json:"max_c"`
+		PrecipitationMM     float64 `json:"precipitation_mm"`
+		PrecipitationChance float64 `json:"precipitation_chance_percent"`
+		MaxWindKmh          float64 `json:"max_wind_kmh"`
+	}
+	d := f.Daily
+	daily := make([]day, 0, len(d.Time))
+	for i := range d.Time {
+		if i >= len(d.WeatherCode) || i >= len(d.Max) || i >= len(d.Min) || i >= len(d.Precipitation) ||
+			i >= len(d.RainChance) || i >= len(d.WindSpeed) {
+			break
+		}
+		_, text := weatherDescription(d.WeatherCode[i])
+		daily = append(daily, day{d.Time[i], text, d.Min[i], d.Max[i], d.Precipitation[i], d.RainChance[i], d.WindSpeed[i]})
+	}
+	_, now := weatherDescription(f.Current.WeatherCode)
+	return map[string]any{
+		"place":    p.String(),
+		"timezone": f.Timezone,
+		"current": map[string]any{
+			"time": f.Current.Time, "conditions": now, "temperature_c": f.Current.Temperature,
+			"feels_like_c": f.Current.FeelsLike, "humidity_percent": f.Current.Humidity, "wind_kmh": f.Current.WindSpeed,
+		},
+		"daily": daily,
+	}, nil
+}
@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const customSearchURL = "https://www.googleapis.com/customsearch/v1"
+
+// webResult is a page found by web search
+type webResult struct {
+	Title   string `json:"title"`
+	Link    string `json:"link"`
+	Snippet string `json:"snippet"`
+}
+
+// searchProvider searches the web and returns up to n results
+type searchProvider interface {
+	Search(ctx context.Context, query string, n int) ([]webResult, error)
+}
+
+// searchProvider returns the configured web search: Google Programmable Search when its key and engine ID
+// are set, otherwise SearXNG at SEARXNG_URL; nil when neither is
+func (bs *BotService) searchProvider() searchProvider {
+	cfg := bs.config()
+	switch {
+	case cfg.WebSearchAPIKey != "" && cfg.WebSearchEngineID != "":
+		return googleSearch{apiKey: cfg.WebSearchAPIKey, engineID: cfg.WebSearchEngineID}
+	case cfg.SearXNGURL != "":
+		return searxngSearch{baseURL: strings.TrimSuffix(cfg.SearXNGURL, "/")}
+	default:
+		return nil
+	}
+}
+
+// googleSearch uses the Google Programmable Search JSON API
+type googleSearch struct {
+	apiKey, engineID string
+}
+
+func (g googleSearch) Search(ctx context.Context, query string, n int) ([]webResult, error) {
+	params := url.Values{"cx": {g.engineID}, "q": {query}, "num": {strconv.Itoa(min(n, 10))}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, customSearchURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// The key goes in a header rather than the URL, so it never shows up in logged errors
+	req.Header.Set("X-Goog-Api-Key", g.apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("web search returned %s", resp.Status)
+	}
+
+	var result struct {
+		Items []webResult `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Items, nil
+}
+
+// searxngSearch uses the JSON API of a SearXNG instance, which needs the json format enabled in its settings
+type searxngSearch struct {
+	baseURL string
+}
+
+func (s searxngSearch) Search(ctx context.Context, query string, n int) ([]webResult, error) {
+	params := url.Values{"q": {query}, "format": {"json"}}
+	var result struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := getJSON(ctx, s.baseURL+"/search?"+params.Encode(), &result); err != nil {
+		return nil, err
+	}
+
+	results := make([]webResult, 0, min(n, len(result.Results)))
+	for _, r := range result.Results[:min(n, len(result.Results))] {
+		results = append(results, webResult{Title: r.Title, Link: r.URL, Snippet: r.Content})
+	}
+	return results, nil
+}
@@ -0,0 +1,224 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	whoisWindow = 30 * 24 * time.Hour
+	// whoisMessages is how many of the user's latest messages the profile is written from
+	whoisMessages = 200
+	whoisTags     = 5
+	// activeHours is the length of the busiest stretch of the day shown in a profile
+	activeHours   = 3
+	whoisUsageMsg = `Usage:
+/whois @username - what someone usually talks about here, or reply to one of their messages with /whois
+/whois optout - keep yourself out of /whois
+/whois optin - allow /whois again`
+)
+
+// profileTarget is the user a profile is about; ID is 0 when only the username is known
+type profileTarget struct {
+	ID       int64
+	Username string
+	Name     string
+}
+
+// filter matches the target's messages, by ID or by username for messages stored without a sender ID
+func (t profileTarget) filter() bson.M {
+	var match bson.A
+	if t.ID != 0 {
+		match = append(match, bson.M{"from_id": t.ID})
+	}
+	if t.Username != "" {
+		match = append(match, bson.M{"from_username": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(t.Username) + "$", Options: "i"}})
+	}
+	return bson.M{"$or": match}
+}
+
+// resolveProfileTarget finds who /whois is about: the sender of the replied message, or an @username
+// that posted in the chat. ok is false when there's no such user.
+func (bs *BotService) resolveProfileTarget(msg *tgbotapi.Message) (target profileTarget, ok bool) {
+	if reply := msg.ReplyToMessage; reply != nil && reply.From != nil && strings.TrimSpace(msg.CommandArguments()) == "" {
+		return profileTarget{ID: reply.From.ID, Username: reply.From.UserName, Name: displayName(reply.From)}, true
+	}
+
+	username := strings.TrimPrefix(strings.TrimSpace(msg.CommandArguments()), "@")
+	if username == "" || strings.ContainsAny(username, " \n") {
+		return profileTarget{}, false
+	}
+	target = profileTarget{Username: username, Name: "@" + username}
+	latest, err := bs.store.FindMessages(bson.M{"chat_id": msg.Chat.ID, "$and": bson.A{target.filter()}}, 1)
+	if err != nil {
+		log.Printf("Error looking up @%s in chat %d: %v", username, msg.Chat.ID, err)
+		return profileTarget{}, false
+	}
+	if len(latest) == 0 {
+		return profileTarget{}, false
+	}
+	target.ID = latest[0].FromID
+	target.Username = latest[0].FromUsername
+	target.Name = "@" + latest[0].FromUsername
+	return target, true
+}
+
+// userProfile composes the profile of a chat member from their messages of the last 30 days:
+// how much and when they post, the replies they get, their tags and what they talk about
+func (bs *BotService) userProfile(ctx context.Context, chat *tgbotapi.Chat, target profileTarget, now time.Time) (string, error) {
+	filter := bson.M{
+		"chat_id":   chat.ID,
+		"timestamp": bson.M{"$gte": now.Add(-whoisWindow), "$lt": now},
+		"$and":      bson.A{target.filter()},
+	}
+	total, err := bs.store.CountMessages(filter)
+	if err != nil || total == 0 {
+		return "", err
+	}
+	messages, err := bs.store.FindMessages(filter, whoisMessages)
+	if err != nil {
+		return "", err
+	}
+
+	loc := bs.chatLocation(chat.ID)
+	var hours [24]int
+	var weekdays [7]int
+	tags := make(map[string]int)
+	ids := make([]int, len(messages))
+	texts := make([]string, 0, len(messages))
+	for i, m := range messages {
+		local := m.Timestamp.In(loc)
+		hours[local.Hour()]++
+		weekdays[local.Weekday()]++
+		for _, tag := range m.Tags {
+			tags[tag]++
+		}
+		ids[i] = m.MessageID
+		texts = append(texts, truncateRunes(m.Text, 300))
+	}
+	replies, err := bs.store.CountMessages(bson.M{"chat_id": chat.ID, "reply_to": bson.M{"$in": ids}})
+	if err != nil {
+		log.Printf("Error counting replies to %s in chat %d: %v", target.Name, chat.ID, err)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "👤 %s in the last 30 days\n\n", target.Name)
+	fmt.Fprintf(&sb, "💬 %d messages, which got %d replies\n", total, replies)
+	start := busiestStretch(hours, activeHours)
+	fmt.Fprintf(&sb, "🕒 Most active %02d:00–%02d:00 (%s), mostly on %ss\n",
+		start, (start+activeHours)%24, loc, time.Weekday(slices.Index(weekdays[:], slices.Max(weekdays[:]))))
+	if top := topTags(tags, whoisTags); len(top) > 0 {
+		sb.WriteString("🏷 #" + strings.Join(top, ", #") + "\n")
+	}
+	if about := bs.profileNarrative(ctx, target.Name, texts); about != "" {
+		sb.WriteString("\n📝 " + about)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// busiestStretch returns the hour that starts the length hours of the day with the most messages, wrapping past midnight
+func busiestStretch(hours [24]int, length int) int {
+	best, bestCount := 0, -1
+	for start := range 24 {
+		count := 0
+		for i := range length {
+			count += hours[(start+i)%24]
+		}
+		if count > bestCount {
+			best, bestCount = start, count
+		}
+	}
+	return best
+}
+
+// topTags returns the n most used tags, most used first
+func topTags(counts map[string]int, n int) []string {
+	tags := make([]string, 0, len(counts))
+	for tag := range counts {
+		tags = append(tags, tag)
+	}
+	slices.SortFunc(tags, func(a, b string) int { return cmp.Or(cmp.Compare(counts[b], counts[a]), strings.Compare(a, b)) })
+	return tags[:min(n, len(tags))]
+}
+
+// profileNarrative asks the model what someone talks about, steering clear of anything personal; it returns "" on error
+func (bs *BotService) profileNarrative(ctx context.Context, name string, texts []string) string {
+	prompt := fmt.Sprintf(`Below are recent messages %s wrote in a Telegram group. In 2-3 sentences (plain text, no markdown), tell a newcomer what %s usually talks about, helps with or is into, so they know who to ask about what.
+Rules:
+- Only mention topics and interests that are evident from the messages
+- Never guess or mention personal traits, health, religion, politics, sexuality, ethnicity, location or relationships
+- Be respectful: don't judge, rate or quote them
+- Response language: the language most of the messages are in
+
+%s`, name, name, untrusted("chat messages", strings.Join(texts, "\n")))
+
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	about, err := bs.generate(ctx, prompt)
+	if err != nil {
+		log.Printf("gemini profile error: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(about)
+}
+
+func (bs *BotService) handleWhois(msg *tgbotapi.Message) {
+	switch arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments())); arg {
+	case "optout", "optin":
+		if msg.From == nil {
+			return
+		}
+		optOut := arg == "optout"
+		if err := bs.store.UpdateUserPreferences(msg.From.ID, bson.M{
+			"profile_opt_out": optOut,
+			"username":        strings.ToLower(msg.From.UserName),
+		}); err != nil {
+			log.Printf("Error saving the profile opt-out of user %d: %v", msg.From.ID, err)
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+		if optOut {
+			bs.Reply(msg, "Done, /whois won't show a profile of you in any chat. Send /whois optin to allow it again.")
+			return
+		}
+		bs.Reply(msg, "Done, /whois can show a profile of you again.")
+		return
+	}
+
+	if !msg.Chat.IsGroup() && !msg.Chat.IsSuperGroup() {
+		bs.Reply(msg, "Profiles only work in groups.\n\n"+whoisUsageMsg)
+		return
+	}
+	target, ok := bs.resolveProfileTarget(msg)
+	if !ok {
+		bs.Reply(msg, "I don't know anyone by that name here.\n\n"+whoisUsageMsg)
+		return
+	}
+	if bs.store.ProfileOptedOut(target.ID, target.Username) {
+		bs.Reply(msg, fmt.Sprintf("%s asked not to be profiled.", target.Name))
+		return
+	}
+
+	profile, err := bs.userProfile(bs.billedContext(msg), msg.Chat, target, time.Now())
+	if err != nil {
+		log.Printf("Error building the profile of %s in chat %d: %v", target.Name, msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if profile == "" {
+		bs.Reply(msg, fmt.Sprintf("%s hasn't posted here in the last 30 days.", target.Name))
+		return
+	}
+	bs.Reply(msg, profile)
+}
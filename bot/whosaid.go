@@ -0,0 +1,92 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/store"
+)
+
+const (
+	// whoSaidCandidates is how many messages each search contributes before the results are merged
+	whoSaidCandidates = 10
+	whoSaidResults    = 3
+	// rrfK damps the weight of top ranks in reciprocal rank fusion, 60 as in the original paper
+	rrfK            = 60
+	whoSaidUsageMsg = "Usage: /whosaid <quote or paraphrase>, like /whosaid pineapple belongs on pizza"
+)
+
+// fuseRankings merges ranked result lists with reciprocal rank fusion, so messages found by both
+// full-text and semantic search come first. Commands are dropped, since they'd mostly match earlier /whosaid calls.
+func fuseRankings(limit int, rankings ...[]store.Message) []store.Message {
+	scores := make(map[int]float64)
+	byID := make(map[int]store.Message)
+	for _, ranking := range rankings {
+		for rank, m := range ranking {
+			if strings.HasPrefix(m.Text, "/") {
+				continue
+			}
+			scores[m.MessageID] += 1.0 / float64(rrfK+rank+1)
+			byID[m.MessageID] = m
+		}
+	}
+
+	fused := make([]store.Message, 0, len(byID))
+	for _, m := range byID {
+		fused = append(fused, m)
+	}
+	slices.SortFunc(fused, func(a, b store.Message) int {
+		return cmp.Or(cmp.Compare(scores[b.MessageID], scores[a.MessageID]), a.Timestamp.Compare(b.Timestamp))
+	})
+	return fused[:min(limit, len(fused))]
+}
+
+func (bs *BotService) handleWhoSaid(msg *tgbotapi.Message) {
+	query := strings.TrimSpace(msg.CommandArguments())
+	if query == "" {
+		bs.Reply(msg, whoSaidUsageMsg)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	semantic, _ := bs.semanticSearch(ctx, msg.Chat.ID, query, whoSaidCandidates)
+	fullText, err := bs.store.SearchMessages(msg.Chat.ID, query, whoSaidCandidates)
+	if err != nil {
+		log.Printf("Error searching messages: %v", err)
+		if len(semantic) == 0 {
+			bs.Reply(msg, responseErrorMsg)
+			return
+		}
+	}
+	matches := fuseRankings(whoSaidResults, fullText, semantic)
+	if len(matches) == 0 {
+		bs.Reply(msg, "Nobody here said anything like that, as far as I can tell.")
+		return
+	}
+
+	loc := bs.chatLocation(msg.Chat.ID)
+	var sb strings.Builder
+	best := matches[0]
+	fmt.Fprintf(&sb, "🗣 %s said it, on %s:\n«%s»\n", best.Author(), best.Timestamp.In(loc).Format("Jan 2, 2006 at 15:04"), truncateRunes(best.Text, 300))
+	if link := messageLink(msg.Chat, best.MessageID); link != "" {
+		sb.WriteString(link + "\n")
+	}
+	if len(matches) > 1 {
+		sb.WriteString("\nAlso close:\n")
+		for _, m := range matches[1:] {
+			fmt.Fprintf(&sb, "%s, %s: %s\n", m.Author(), m.Timestamp.In(loc).Format("Jan 2 15:04"), truncateRunes(m.Text, 150))
+			if link := messageLink(msg.Chat, m.MessageID); link != "" {
+				sb.WriteString(link + "\n")
+			}
+		}
+	}
+	bs.Reply(msg, sb.String())
+}
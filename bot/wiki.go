@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxWikiExtract caps the article summary /wiki sends, in runes
+const maxWikiExtract = 1200
+
+// errNoArticle is returned when Wikipedia has no article for a query
+var errNoArticle = errors.New("no article found")
+
+// wikiLanguageRe matches Wikipedia language codes like "en", "fa" or "zh-yue"
+var wikiLanguageRe = regexp.MustCompile(`^[a-z]{2,3}(-[a-z]+)*$`)
+
+// wikiArticle is the summary of a Wikipedia article, with its Wikidata item
+type wikiArticle struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Extract     string `json:"extract"`
+	URL         string `json:"url"`
+	WikidataURL string `json:"wikidata_url,omitempty"`
+}
+
+// wikiLookup finds the Wikipedia article best matching query in the given language edition
+// and returns its lead summary
+func wikiLookup(ctx context.Context, language, query string) (*wikiArticle, error) {
+	if !wikiLanguageRe.MatchString(language) {
+		return nil, fmt.Errorf("invalid Wikipedia language %q", language)
+	}
+	base := "https://" + language + ".wikipedia.org"
+
+	var search struct {
+		Pages []struct {
+			Key string `json:"key"`
+		} `json:"pages"`
+	}
+	params := url.Values{"q": {query}, "limit": {"1"}}
+	if err := getJSON(ctx, base+"/w/rest.php/v1/search/page?"+params.Encode(), &search); err != nil {
+		return nil, err
+	}
+	if len(search.Pages) == 0 {
+		return nil, errNoArticle
+	}
+
+	var summary struct {
+		Type         string `json:"type"`
+		Title        string `json:"title"`
+		Description  string `json:"description"`
+		Extract      string `json:"extract"`
+		WikibaseItem string `json:"wikibase_item"`
+		ContentURLs  struct {
+			Desktop struct {
+				Page string `json:"page"`
+			} `json:"desktop"`
+		} `json:"content_urls"`
+	}
+	if err := getJSON(ctx, base+"/api/rest_v1/page/summary/"+url.PathEscape(search.Pages[0].Key), &summary); err != nil {
+		return nil, err
+	}
+	if summary.Extract == "" {
+		return nil, errNoArticle
+	}
+
+	article := &wikiArticle{
+		Title:       summary.Title,
+		Description: summary.Description,
+		Extract:     summary.Extract,
+		URL:         summary.ContentURLs.Desktop.Page,
+	}
+	if summary.WikibaseItem != "" {
+		article.WikidataURL = "https://www.wikidata.org/wiki/" + summary.WikibaseItem
+	}
+	// Disambiguation pages only list other articles, so the summary says so
+	if summary.Type == "disambiguation" {
+		article.Description = "disambiguation page, the query is ambiguous"
+	}
+	return article, nil
+}
+
+// wikiLanguage is the Wikipedia edition for a chat: the language picked during onboarding in a DM,
+// otherwise WIKIPEDIA_LANGUAGE
+func (bs *BotService) wikiLanguage(chat *tgbotapi.Chat) string {
+	if chat.IsPrivate() {
+		if code := bs.store.UserPreferences(chat.ID).Language; code != "" {
+			return code
+		}
+	}
+	return bs.config().WikipediaLanguage
+}
+
+func (bs *BotService) handleWiki(msg *tgbotapi.Message) {
+	query := strings.TrimSpace(msg.CommandArguments())
+	if query == "" {
+		bs.Reply(msg, "Usage: /wiki <topic>")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	article, err := wikiLookup(ctx, bs.wikiLanguage(msg.Chat), query)
+	if errors.Is(err, errNoArticle) {
+		bs.Reply(msg, fmt.Sprintf("Wikipedia has no article about %q.", query))
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up %q on Wikipedia: %v", query, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📖 " + article.Title)
+	if article.Description != "" {
+		sb.WriteString(" — " + article.Description)
+	}
+	sb.WriteString("\n\n" + truncateRunes(article.Extract, maxWikiExtract) + "\n\n" + article.URL)
+	if article.WikidataURL != "" {
+		sb.WriteString("\nWikidata: " + article.WikidataURL)
+	}
+	bs.Reply(msg, sb.String())
+}
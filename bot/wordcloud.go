@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/chart"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	defaultWordCloudDays = 7
+	maxWordCloudDays     = 30
+	// wordCloudMessages caps how many of the period's messages are counted
+	wordCloudMessages = 5000
+	wordCloudWords    = 70
+	minWordLength     = 3
+	wordCloudUsageMsg = "Usage: /wordcloud [days] - the most used words of the last 7 days, or up to 30"
+)
+
+// stopwords are the common words left out of word clouds, per language. Only languages the chart font
+// can draw are listed, since words in other scripts are skipped anyway.
+var stopwords = map[string]string{
+	"en": `the and for are but not you all any can had her was one our out day get has him his how man new now old
+see two way who boy did its let put say she too use that with have this will your from they know want been good
+much some time very when come here just like long make many over such take them well were what what's there
+their then these those would could should about after again also because before being both does doing down each
+few into more most only other same so than through under until where which while whom why yes yeah okay lol haha
+it's i'm don't can't didn't doesn't isn't that's you're i've i'll we're they're`,
+	"es": `que los las del por con una para como más pero sus les ya muy sin sobre también hasta hay donde quien desde
+todo nos durante todos uno ni contra otros ese eso ante ellos esto mí antes algunos qué unos yo otro otras otra él
+tanto esa estos mucho quienes nada muchos cual poco ella estar estas algunas algo nosotros mis tú te ti tu tus ellas
+está están era fue ser son jaja`,
+	"de": `der die das und ist nicht ein eine einen einem einer den dem des mit sich auf für von zu im dass auch es an
+als wie noch nach aus bei wenn nur sie wir ihr ich du er aber oder schon mal hat haben sind war doch so was dann
+denn mir mich dir dich uns euch kein keine hier da ja nein`,
+	"fr": `les des une est que qui dans pour pas sur sont avec plus par mais comme tout nous vous ils elles elle son
+ses leur leurs été être avoir fait faire bien aussi donc très peut cette ces aux ont mon ton moi toi lui oui non
+quand alors`,
+}
+
+// stopwordSet merges the stopwords of all languages, since chats often mix them
+var stopwordSet = func() map[string]bool {
+	set := make(map[string]bool)
+	for _, words := range stopwords {
+		for _, w := range strings.Fields(words) {
+			set[w] = true
+		}
+	}
+	return set
+}()
+
+// wordFrequencies counts the words of texts, leaving out stopwords, links, numbers, short words,
+// commands and words the chart font can't draw. It returns the limit most used, most used first.
+func wordFrequencies(texts []string, limit int) []chart.Word {
+	counts := make(map[string]int)
+	for _, text := range texts {
+		if strings.HasPrefix(text, "/") {
+			continue
+		}
+		for _, field := range strings.Fields(strings.ToLower(text)) {
+			if strings.Contains(field, "://") || strings.HasPrefix(field, "@") {
+				continue
+			}
+			word := strings.TrimFunc(field, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+			if utf8.RuneCountInString(word) < minWordLength || stopwordSet[word] || !chart.CanDraw(word) {
+				continue
+			}
+			if _, err := strconv.Atoi(word); err == nil {
+				continue
+			}
+			counts[word]++
+		}
+	}
+
+	words := make([]chart.Word, 0, len(counts))
+	for w, n := range counts {
+		words = append(words, chart.Word{Text: w, Weight: float64(n)})
+	}
+	slices.SortFunc(words, func(a, b chart.Word) int {
+		return cmp.Or(cmp.Compare(b.Weight, a.Weight), strings.Compare(a.Text, b.Text))
+	})
+	return words[:min(limit, len(words))]
+}
+
+func (bs *BotService) handleWordCloud(msg *tgbotapi.Message) {
+	days := defaultWordCloudDays
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > maxWordCloudDays {
+			bs.Reply(msg, wordCloudUsageMsg)
+			return
+		}
+		days = n
+	}
+
+	now := time.Now()
+	messages, err := bs.store.FindMessages(bson.M{
+		"chat_id":   msg.Chat.ID,
+		"timestamp": bson.M{"$gte": now.AddDate(0, 0, -days), "$lt": now},
+	}, wordCloudMessages)
+	if err != nil {
+		log.Printf("Error loading messages for the word cloud of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	texts := make([]string, len(messages))
+	for i, m := range messages {
+		texts[i] = m.Text
+	}
+	words := wordFrequencies(texts, wordCloudWords)
+	if len(words) == 0 {
+		bs.Reply(msg, fmt.Sprintf("Not enough words in the last %d days for a word cloud.", days))
+		return
+	}
+
+	data, err := chart.Cloud{Words: words}.PNG()
+	if err != nil {
+		log.Printf("Error rendering the word cloud of chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	caption := fmt.Sprintf("☁️ The most used words of the last %d days (%d messages)", days, len(messages))
+	if err := bs.sendPNG(msg.Chat.ID, msg, kindAnswer, "wordcloud.png", data, caption); err != nil {
+		log.Printf("failed to send word cloud: %v", err)
+	}
+}
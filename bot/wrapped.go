@@ -0,0 +1,227 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sg-milad/ChatBuddy/chart"
+	"github.com/sg-milad/ChatBuddy/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// The weekly recap is posted on wrappedWeekday at wrappedHour, chat local time
+	wrappedWeekday = time.Sunday
+	wrappedHour    = 19
+	wrappedTop     = 3
+	// wrappedMessages is how many of the week's messages the narrative is written from
+	wrappedMessages = 300
+	wrappedUsageMsg = `Usage:
+/wrapped - the recap of the last 7 days
+/wrapped on - post it every Sunday evening
+/wrapped off - stop posting it`
+	wrappedBuildingMsg = "Putting this week's recap together... This may take a moment."
+	noActivityMsg      = "Nothing to recap, there were no messages in the last 7 days."
+)
+
+// weeklyWrapped builds the recap of a chat's last 7 days: stats, the most replied messages, topics and tags,
+// and a narrative written by the model, with a chart of the messages per day. ok is false when there were no messages.
+func (bs *BotService) weeklyWrapped(ctx context.Context, chat *tgbotapi.Chat, now time.Time) (text string, daily chart.Bar, ok bool, err error) {
+	from := now.Add(-7 * 24 * time.Hour)
+	activity, err := bs.store.Activity(chat.ID, from, now, wrappedTop)
+	if err != nil || activity.Messages == 0 {
+		return "", chart.Bar{}, false, err
+	}
+
+	loc := bs.chatLocation(chat.ID)
+	daily = dailyChart("Messages per day", activity.Hourly, now, 7, loc)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🎁 %s wrapped: %s – %s\n\n", chatDisplayName(*chat), from.In(loc).Format("Jan 2"), now.In(loc).Format("Jan 2"))
+	fmt.Fprintf(&sb, "💬 %d messages from %d people\n", activity.Messages, activity.Posters)
+	if day, count := busiestDay(activity.Hourly, loc); count > 0 {
+		fmt.Fprintf(&sb, "📈 Busiest day: %s (%d messages)\n", day, count)
+	}
+
+	sb.WriteString("\n🏆 Top posters:\n")
+	medals := []string{"🥇", "🥈", "🥉"}
+	for i, p := range activity.TopPosters {
+		fmt.Fprintf(&sb, "%s %s: %d\n", medals[i%len(medals)], p.Poster.Author(), p.Count)
+	}
+
+	if highlights := bs.formatHighlights(chat, activity.MostReplied); highlights != "" {
+		sb.WriteString("\n⭐ Most replied:\n" + highlights)
+	}
+
+	topics, found, err := bs.store.LoadChatTopics(chat.ID)
+	if err != nil {
+		log.Printf("Error loading topics for chat %d: %v", chat.ID, err)
+	}
+	var labels []string
+	if found && topics.ComputedAt.After(from) {
+		for _, t := range topics.Topics {
+			labels = append(labels, t.Label)
+		}
+		sb.WriteString("\n🗂 Topics: " + strings.Join(labels, ", ") + "\n")
+	}
+	if tags := bs.formatTagCounts(chat.ID); tags != "" {
+		sb.WriteString("\n" + tags + "\n")
+	}
+
+	if recap := bs.wrappedNarrative(ctx, chat.ID, from, now, labels); recap != "" {
+		sb.WriteString("\n📝 " + recap)
+	}
+	return strings.TrimSpace(sb.String()), daily, true, nil
+}
+
+// busiestDay returns the local day with the most messages, formatted like "Tuesday, Jan 2"
+func busiestDay(hourly map[time.Time]int, loc *time.Location) (string, int) {
+	days := make(map[string]int)
+	var best string
+	for hour, count := range hourly {
+		day := hour.In(loc).Format("Monday, Jan 2")
+		days[day] += count
+		if days[day] > days[best] || (days[day] == days[best] && day < best) {
+			best = day
+		}
+	}
+	return best, days[best]
+}
+
+// formatHighlights lists the most replied messages with their jump links
+func (bs *BotService) formatHighlights(chat *tgbotapi.Chat, replied []store.ReplyCount) string {
+	if len(replied) == 0 {
+		return ""
+	}
+	ids := make([]int, len(replied))
+	replies := make(map[int]int, len(replied))
+	for i, r := range replied {
+		ids[i] = r.MessageID
+		replies[r.MessageID] = r.Replies
+	}
+	messages, err := bs.store.MessagesByID(chat.ID, ids)
+	if err != nil {
+		log.Printf("Error loading highlights for chat %d: %v", chat.ID, err)
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, m := range messages {
+		fmt.Fprintf(&sb, "%s: %s (%d replies)\n", m.Author(), truncateRunes(m.Text, 120), replies[m.MessageID])
+		if link := messageLink(chat, m.MessageID); link != "" {
+			sb.WriteString(link + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// wrappedNarrative asks the model for a short, upbeat recap of the week; it returns "" on error
+func (bs *BotService) wrappedNarrative(ctx context.Context, chatID int64, from, to time.Time, topics []string) string {
+	messages, err := bs.fetchMessagesInRangeFromDB(chatID, from, to, wrappedMessages)
+	if err != nil {
+		log.Printf("Error loading messages for the weekly recap of chat %d: %v", chatID, err)
+		return ""
+	}
+	if len(messages) == 0 {
+		return ""
+	}
+
+	var topicHint string
+	if len(topics) > 0 {
+		topicHint = "\nThe topics of the week were: " + strings.Join(topics, ", ") + "."
+	}
+	prompt := fmt.Sprintf(`Below are messages from a Telegram group's last week. Write a short, warm, "year in review" style recap of the week for the group (3-5 sentences, plain text, no markdown): the big moments, running jokes and what people were up to. Don't invent anything that isn't in the messages and don't list statistics.%s
+Response language: the language most of the messages are in.
+
+%s`, topicHint, untrusted("chat messages", strings.Join(messages, "\n")))
+
+	ctx, cancel := context.WithTimeout(ctx, 90*time.Second)
+	defer cancel()
+
+	recap, err := bs.generate(ctx, prompt)
+	if err != nil {
+		log.Printf("gemini weekly recap error: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(recap)
+}
+
+// sendWeeklyWrapped posts the weekly recap to the chats that opted in, and to their digest subscribers
+func (bs *BotService) sendWeeklyWrapped(now time.Time) {
+	chats, err := bs.store.FindChatSettings(bson.M{"weekly_wrapped": true})
+	if err != nil {
+		log.Printf("Error loading weekly recap chats: %v", err)
+		return
+	}
+
+	for _, settings := range chats {
+		local := now.In(bs.chatLocation(settings.ChatID))
+		if local.Weekday() != wrappedWeekday || local.Hour() != wrappedHour ||
+			!bs.claimDailyRun("weekly_wrapped", settings.ChatID, local.Format("2006-01-02")) {
+			continue
+		}
+
+		chat := &tgbotapi.Chat{ID: settings.ChatID, Title: bs.chatInfo(settings.ChatID).Title}
+		text, daily, ok, err := bs.weeklyWrapped(context.Background(), chat, now)
+		if err != nil {
+			log.Printf("Error building the weekly recap of chat %d: %v", settings.ChatID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		// The chart isn't held back with the text, so it's left out during quiet hours
+		if !bs.inQuietHours(settings.ChatID, now) {
+			if err := bs.botFor(settings.ChatID).sendChart(settings.ChatID, nil, kindDigest, daily, ""); err != nil {
+				log.Printf("failed to send the weekly recap chart of chat %d: %v", settings.ChatID, err)
+			}
+		}
+		bs.sendScheduledDigest(settings.ChatID, text)
+		bs.sendDigestToSubscribers(settings.ChatID, text)
+	}
+}
+
+func (bs *BotService) handleWrapped(msg *tgbotapi.Message) {
+	arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+	switch arg {
+	case "":
+		bs.Reply(msg, wrappedBuildingMsg)
+		text, daily, ok, err := bs.weeklyWrapped(bs.billedContext(msg), msg.Chat, time.Now())
+		switch {
+		case err != nil:
+			log.Printf("Error building the weekly recap of chat %d: %v", msg.Chat.ID, err)
+			bs.Reply(msg, responseErrorMsg)
+		case !ok:
+			bs.Reply(msg, noActivityMsg)
+		default:
+			if err := bs.sendChart(msg.Chat.ID, nil, kindAnswer, daily, ""); err != nil {
+				log.Printf("failed to send the weekly recap chart of chat %d: %v", msg.Chat.ID, err)
+			}
+			bs.sendAs(kindAnswer, tgbotapi.NewMessage(msg.Chat.ID, text))
+		}
+		return
+	case "on", "off":
+	default:
+		bs.Reply(msg, wrappedUsageMsg)
+		return
+	}
+
+	if bs.userRole(msg.Chat, msg.From) < ChatAdmin {
+		bs.Reply(msg, adminOnlyMsg)
+		return
+	}
+	if err := bs.store.UpdateChatSettings(msg.Chat.ID, bson.M{"weekly_wrapped": arg == "on"}); err != nil {
+		log.Printf("Error saving weekly recap setting for chat %d: %v", msg.Chat.ID, err)
+		bs.Reply(msg, responseErrorMsg)
+		return
+	}
+	if arg == "on" {
+		bs.Reply(msg, fmt.Sprintf("🎁 I'll post the weekly recap every %s at %02d:00 (%s). Members subscribed to this chat's digests get it in a DM too.",
+			wrappedWeekday, wrappedHour, bs.chatLocation(msg.Chat.ID)))
+		return
+	}
+	bs.Reply(msg, "The weekly recap is off.")
+}
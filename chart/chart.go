@@ -0,0 +1,146 @@
+// Package chart renders simple bar charts and word clouds as PNG images with the standard library only,
+// so stats can be sent as pictures without a plotting dependency.
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+)
+
+type rgba = color.RGBA
+
+var (
+	background = rgba{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	ink        = rgba{R: 0x33, G: 0x33, B: 0x33, A: 0xff}
+	grid       = rgba{R: 0xe3, G: 0xe3, B: 0xe3, A: 0xff}
+	// DefaultColor is the bar color when Bar.Color is unset
+	DefaultColor = rgba{R: 0x2a, G: 0xab, B: 0xee, A: 0xff}
+)
+
+const (
+	scale      = 2
+	lineHeight = glyphHeight * scale
+	margin     = 24
+	minBarSlot = 14
+	maxBarSlot = 64
+	plotHeight = 300
+	minWidth   = 640
+	gridLines  = 4
+)
+
+// Bar is a bar chart with one bar per label
+type Bar struct {
+	Title  string
+	Labels []string
+	Values []float64
+	Color  color.RGBA
+}
+
+// PNG renders the chart. Labels are thinned out when they don't fit under their bars.
+func (b Bar) PNG() ([]byte, error) {
+	if len(b.Values) == 0 || len(b.Labels) != len(b.Values) {
+		return nil, errors.New("chart: need one label per value")
+	}
+	bar := b.Color
+	if bar.A == 0 {
+		bar = DefaultColor
+	}
+
+	maxValue := 0.0
+	for _, v := range b.Values {
+		maxValue = math.Max(maxValue, v)
+	}
+	top, step := niceScale(maxValue)
+
+	axisWidth := textWidth(formatValue(top), scale) + 8
+	slot := min(maxBarSlot, max(minBarSlot, (minWidth-2*margin-axisWidth)/len(b.Values)))
+	plotWidth := slot * len(b.Values)
+	width := max(minWidth, plotWidth+axisWidth+2*margin)
+	height := margin + lineHeight + margin + plotHeight + 8 + lineHeight + margin
+
+	c := newCanvas(width, height)
+	c.drawText(b.Title, margin, margin, scale, ink)
+
+	plotX := margin + axisWidth
+	plotY := margin + lineHeight + margin
+	for i := 0; i <= gridLines; i++ {
+		value := step * float64(i)
+		y := plotY + plotHeight - int(math.Round(value/top*plotHeight))
+		c.fill(plotX, y, plotWidth, 1, grid)
+		label := formatValue(value)
+		c.drawText(label, plotX-8-textWidth(label, scale), y-lineHeight/2, scale, ink)
+	}
+
+	// Show every nth label so neighbours don't overlap
+	widest := 0
+	for _, l := range b.Labels {
+		widest = max(widest, textWidth(l, scale))
+	}
+	every := max(1, int(math.Ceil(float64(widest+12)/float64(slot))))
+
+	barWidth := max(2, slot*3/4)
+	for i, v := range b.Values {
+		x := plotX + i*slot + (slot-barWidth)/2
+		h := int(math.Round(v / top * plotHeight))
+		c.fill(x, plotY+plotHeight-h, barWidth, h, bar)
+
+		if i%every == 0 {
+			l := b.Labels[i]
+			c.drawText(l, plotX+i*slot+slot/2-textWidth(l, scale)/2, plotY+plotHeight+8, scale, ink)
+		}
+	}
+	c.fill(plotX, plotY+plotHeight, plotWidth, 1, ink)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// niceScale rounds the axis up to a multiple of a 1, 2 or 5 step, so grid labels are round numbers
+func niceScale(maxValue float64) (top, step float64) {
+	if maxValue <= 0 {
+		return gridLines, 1
+	}
+	raw := maxValue / gridLines
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if step = m * magnitude; step >= raw {
+			break
+		}
+	}
+	return step * gridLines, step
+}
+
+func formatValue(v float64) string {
+	if v == math.Trunc(v) {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+type canvas struct {
+	img *image.RGBA
+}
+
+func newCanvas(width, height int) *canvas {
+	c := &canvas{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+	c.fill(0, 0, width, height, background)
+	return c
+}
+
+// fill paints a rectangle, clipped to the image
+func (c *canvas) fill(x, y, w, h int, col rgba) {
+	r := image.Rect(x, y, x+w, y+h).Intersect(c.img.Bounds())
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			c.img.SetRGBA(px, py, col)
+		}
+	}
+}
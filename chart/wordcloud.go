@@ -0,0 +1,106 @@
+package chart
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"image"
+	"image/png"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	cloudWidth   = 900
+	cloudHeight  = 560
+	minWordScale = 2
+	maxWordScale = 9
+	wordPadding  = 6
+	// The spiral is stretched sideways, since words are wider than they are tall
+	spiralAspect = 1.6
+	spiralStep   = 0.05
+)
+
+// palette colors the words of a cloud in turn
+var palette = []rgba{
+	{R: 0x2a, G: 0xab, B: 0xee, A: 0xff},
+	{R: 0xe6, G: 0x7e, B: 0x22, A: 0xff},
+	{R: 0x27, G: 0xae, B: 0x60, A: 0xff},
+	{R: 0x8e, G: 0x44, B: 0xad, A: 0xff},
+	{R: 0xc0, G: 0x39, B: 0x2b, A: 0xff},
+	{R: 0x16, G: 0xa0, B: 0x85, A: 0xff},
+	{R: 0x34, G: 0x49, B: 0x5e, A: 0xff},
+}
+
+// Word is a word of a cloud with its weight, e.g. how often it was used
+type Word struct {
+	Text   string
+	Weight float64
+}
+
+// Cloud is a word cloud; heavier words are drawn larger and closer to the center
+type Cloud struct {
+	Title string
+	Words []Word
+}
+
+// PNG renders the cloud. Words that don't fit anymore are left out.
+func (c Cloud) PNG() ([]byte, error) {
+	words := slices.DeleteFunc(slices.Clone(c.Words), func(w Word) bool { return w.Weight <= 0 || w.Text == "" })
+	if len(words) == 0 {
+		return nil, errors.New("chart: no words to draw")
+	}
+	slices.SortStableFunc(words, func(a, b Word) int { return cmp.Compare(b.Weight, a.Weight) })
+
+	cv := newCanvas(cloudWidth, cloudHeight)
+	top := margin
+	if c.Title != "" {
+		cv.drawText(c.Title, margin, margin, scale, ink)
+		top += lineHeight + margin
+	}
+	area := image.Rect(margin, top, cloudWidth-margin, cloudHeight-margin)
+	center := image.Pt((area.Min.X+area.Max.X)/2, (area.Min.Y+area.Max.Y)/2)
+
+	heaviest := words[0].Weight
+	var placed []image.Rectangle
+	for i, w := range words {
+		s := minWordScale + int(math.Round(math.Sqrt(w.Weight/heaviest)*(maxWordScale-minWordScale)))
+		size := image.Pt(textWidth(w.Text, s)+2*wordPadding, glyphHeight*s+2*wordPadding)
+		if r, ok := place(area, center, size, placed); ok {
+			placed = append(placed, r)
+			cv.drawText(w.Text, r.Min.X+wordPadding, r.Min.Y+wordPadding, s, palette[i%len(palette)])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, cv.img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// place walks a spiral out of the center and returns the first spot of the given size
+// that is inside area and clear of the placed rectangles
+func place(area image.Rectangle, center, size image.Point, placed []image.Rectangle) (image.Rectangle, bool) {
+	reach := math.Hypot(float64(area.Dx()), float64(area.Dy()))
+	for t := 0.0; t*spiralAspect < reach; t += spiralStep {
+		offset := image.Pt(int(t*spiralAspect*math.Cos(t*4)), int(t*math.Sin(t*4)))
+		corner := center.Add(offset).Sub(size.Div(2))
+		r := image.Rectangle{Min: corner, Max: corner.Add(size)}
+		if r.In(area) && !slices.ContainsFunc(placed, r.Overlaps) {
+			return r, true
+		}
+	}
+	return image.Rectangle{}, false
+}
+
+// CanDraw reports whether the font has every character of s, so it won't come out with blanks
+func CanDraw(s string) bool {
+	for _, r := range strings.ToUpper(s) {
+		if _, ok := glyphs[r]; !ok && r != ' ' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+// Package eventbus publishes ChatBuddy events to NATS, so workers in other processes can react to them.
+package eventbus
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types published by the bot, used as the last part of the subject, like "chatbuddy.message.received"
+const (
+	MessageReceived  = "message.received"
+	SummaryRequested = "summary.requested"
+	ResponseSent     = "response.sent"
+)
+
+// Event is the normalized JSON payload of every event
+type Event struct {
+	Type      string `json:"type"`
+	ChatID    int64  `json:"chat_id"`
+	ChatType  string `json:"chat_type,omitempty"`
+	MessageID int    `json:"message_id,omitempty"`
+	// ReplyTo is the message a response answers, or the message a summary was requested with
+	ReplyTo  int    `json:"reply_to,omitempty"`
+	UserID   int64  `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Text     string `json:"text,omitempty"`
+	// Kind is the kind of a sent response: "answers", "digests" or "notifications"
+	Kind string    `json:"kind,omitempty"`
+	At   time.Time `json:"at"`
+}
+
+// Decode parses an event received by a worker
+func Decode(data []byte) (Event, error) {
+	var e Event
+	err := json.Unmarshal(data, &e)
+	return e, err
+}
@@ -0,0 +1,111 @@
+// Package latex renders LaTeX formulas to PNG images with a local TeX installation (latex and dvipng).
+package latex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	// MaxFormula bounds the formulas rendered, in bytes
+	MaxFormula = 2000
+	// DefaultDPI is a resolution that reads well in Telegram photos
+	DefaultDPI = 250
+	timeout    = 15 * time.Second
+)
+
+// ErrUnsafe is returned for formulas using commands that could read or write files or redefine TeX
+var ErrUnsafe = errors.New("latex: formula uses a disallowed command")
+
+// unsafeCommand matches TeX primitives and macros that touch files, run commands or change how input is read
+var unsafeCommand = regexp.MustCompile(`\\(input|include|openin|openout|read|write|immediate|catcode|def|edef|gdef|xdef|let|csname|newcommand|renewcommand|usepackage|documentclass|special|jobname|loop|makeatletter|endinput|directlua)\b|\^\^`)
+
+const document = `\documentclass[12pt]{article}
+\usepackage{amsmath,amssymb}
+\pagestyle{empty}
+\begin{document}
+\begin{displaymath}
+%s
+\end{displaymath}
+\end{document}
+`
+
+// Available reports whether latex and dvipng are on the PATH
+func Available() bool {
+	for _, bin := range []string{"latex", "dvipng"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Render typesets a formula in display math mode and returns it as a PNG, cropped to the formula.
+// TeX runs without shell escape and may only open files in its working directory.
+func Render(ctx context.Context, formula string, dpi int) ([]byte, error) {
+	if len(formula) > MaxFormula {
+		return nil, fmt.Errorf("latex: formula longer than %d bytes", MaxFormula)
+	}
+	if unsafeCommand.MatchString(formula) {
+		return nil, ErrUnsafe
+	}
+	if dpi <= 0 {
+		dpi = DefaultDPI
+	}
+
+	dir, err := os.MkdirTemp("", "chatbuddy-latex-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "formula.tex"), fmt.Appendf(nil, document, formula), 0o600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := run(ctx, dir, "latex", "-no-shell-escape", "-interaction=nonstopmode", "-halt-on-error", "formula.tex"); err != nil {
+		return nil, fmt.Errorf("latex: formula doesn't compile: %w", err)
+	}
+	if err := run(ctx, dir, "dvipng", "-q", "-T", "tight", "-D", strconv.Itoa(dpi), "-bg", "White", "-o", "formula.png", "formula.dvi"); err != nil {
+		return nil, fmt.Errorf("latex: dvipng failed: %w", err)
+	}
+	return os.ReadFile(filepath.Join(dir, "formula.png"))
+}
+
+func run(ctx context.Context, dir, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	// Paranoid mode keeps TeX from opening files outside dir or dotfiles
+	cmd.Env = append(os.Environ(), "openin_any=p", "openout_any=p", "shell_escape=f")
+	var stderr bytes.Buffer
+	cmd.Stdout = &stderr
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if line := lastErrorLine(stderr.Bytes()); line != "" {
+			return fmt.Errorf("%w: %s", err, line)
+		}
+		return err
+	}
+	return nil
+}
+
+// lastErrorLine picks the "! ..." line TeX prints for the error that stopped it
+func lastErrorLine(output []byte) string {
+	var last []byte
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("! ")) {
+			last = line
+		}
+	}
+	return string(bytes.TrimSpace(last))
+}
@@ -0,0 +1,182 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// MinCachedContextRunes is about the smallest context the API caches, 4096 tokens; shorter ones are sent
+// inline with the request
+const MinCachedContextRunes = 16000
+
+type cachedContextKey struct{}
+
+type cachedContext struct {
+	key  string
+	text string
+}
+
+// WithCachedContext asks generators that support it to send text, a large context repeated across requests
+// like a knowledge base, from a server-side cache kept under key instead of with every request. *Gemini
+// sends it inline, before the system instruction, when it can't cache it.
+func WithCachedContext(ctx context.Context, key, text string) context.Context {
+	return context.WithValue(ctx, cachedContextKey{}, cachedContext{key: key, text: text})
+}
+
+// CachedContextFromContext returns the key and text set with WithCachedContext, or "" for both
+func CachedContextFromContext(ctx context.Context) (key, text string) {
+	c, _ := ctx.Value(cachedContextKey{}).(cachedContext)
+	return c.key, c.text
+}
+
+// ContextCacher is implemented by generators that keep contexts set with WithCachedContext on the server
+type ContextCacher interface {
+	// DropCachedContext deletes the cache kept under key, if any
+	DropCachedContext(ctx context.Context, key string)
+}
+
+// contextCache is the API cache of one key: the hash of what it holds and when it expires
+type contextCache struct {
+	name    string
+	hash    string
+	expires time.Time
+}
+
+// contextCaches are the API caches Gemini created, by key
+type contextCaches struct {
+	mu     sync.Mutex
+	byKey  map[string]*contextCache
+	client *genai.Client
+}
+
+// use returns the name of the API cache holding text, instruction and tools for model under key. A cache
+// holding something else is replaced, and one used within half its ttl of expiring is extended.
+func (c *contextCaches) use(ctx context.Context, model, key, text, instruction string, tools []*genai.Tool, ttl time.Duration) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(model + "\x00" + instruction + "\x00" + text + "\x00"))
+	if err := json.NewEncoder(h).Encode(tools); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if cache, ok := c.byKey[key]; ok {
+		switch {
+		case cache.hash != hash:
+			c.drop(ctx, key)
+		case now.After(cache.expires):
+			delete(c.byKey, key)
+		case cache.expires.Sub(now) < ttl/2:
+			_, err := c.client.UpdateCachedContent(ctx, &genai.CachedContent{Name: cache.name},
+				&genai.CachedContentToUpdate{Expiration: &genai.ExpireTimeOrTTL{TTL: ttl}})
+			if err != nil {
+				// Gone or about to be, make a new one
+				delete(c.byKey, key)
+				break
+			}
+			cache.expires = now.Add(ttl)
+			return cache.name, nil
+		default:
+			return cache.name, nil
+		}
+	}
+
+	cc := &genai.CachedContent{
+		Model:       fullModelName(model),
+		DisplayName: key,
+		Contents:    []*genai.Content{genai.NewUserContent(genai.Text(text))},
+		Tools:       tools,
+		Expiration:  genai.ExpireTimeOrTTL{TTL: ttl},
+	}
+	if instruction != "" {
+		cc.SystemInstruction = genai.NewUserContent(genai.Text(instruction))
+	}
+	created, err := c.client.CreateCachedContent(ctx, cc)
+	if err != nil {
+		return "", err
+	}
+	c.byKey[key] = &contextCache{name: created.Name, hash: hash, expires: now.Add(ttl)}
+	return created.Name, nil
+}
+
+// drop deletes the API cache of key; c.mu must be held
+func (c *contextCaches) drop(ctx context.Context, key string) {
+	cache, ok := c.byKey[key]
+	if !ok {
+		return
+	}
+	delete(c.byKey, key)
+	if err := c.client.DeleteCachedContent(ctx, cache.name); err != nil {
+		log.Printf("error deleting the context cache of %s: %v", key, err)
+	}
+}
+
+// DropCachedContext deletes the API cache kept under key, so it doesn't linger until it expires
+func (g *Gemini) DropCachedContext(ctx context.Context, key string) {
+	g.caches.mu.Lock()
+	defer g.caches.mu.Unlock()
+	g.caches.drop(ctx, key)
+}
+
+// dropCachedContexts deletes every API cache that hasn't expired yet
+func (g *Gemini) dropCachedContexts() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	g.caches.mu.Lock()
+	defer g.caches.mu.Unlock()
+	for key, cache := range g.caches.byKey {
+		if time.Now().Before(cache.expires) {
+			g.caches.drop(ctx, key)
+		}
+	}
+}
+
+// withCachedContext returns model set to read the context of ctx from an API cache, and the system
+// instruction still to send with the request: none when the cache holds it, and the context followed by
+// instruction when the context can't be cached, because caching is off, the context is too short or the
+// cache couldn't be created. The API takes the system instruction and tools only from the cache when one is
+// used, so they are cached along with the context.
+func (g *Gemini) withCachedContext(ctx context.Context, model *genai.GenerativeModel, name, instruction string) (*genai.GenerativeModel, string) {
+	key, text := CachedContextFromContext(ctx)
+	if text == "" {
+		return model, instruction
+	}
+	ttl := time.Duration(g.cacheTTL.Load())
+	if ttl > 0 && utf8.RuneCountInString(text) >= MinCachedContextRunes {
+		var tools []*genai.Tool
+		if t := ToolsFromContext(ctx); len(t) > 0 {
+			tools = declarations(t)
+		}
+		cacheName, err := g.caches.use(ctx, name, key, text, instruction, tools, ttl)
+		if err == nil {
+			cached := *model
+			cached.CachedContentName = cacheName
+			return &cached, ""
+		}
+		log.Printf("error caching the context of %s, sending it inline: %v", key, err)
+	}
+	return model, strings.TrimSpace(text + "\n\n" + instruction)
+}
+
+// fullModelName is the API's name of a model, like models/gemini-2.0-flash
+func fullModelName(name string) string {
+	if strings.ContainsRune(name, '/') {
+		return name
+	}
+	return "models/" + name
+}
+
+var _ ContextCacher = (*Gemini)(nil)
@@ -0,0 +1,262 @@
+// Package llm wraps the language model used by ChatBuddy.
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+const (
+	// DefaultModel is the Gemini model used when none is given
+	DefaultModel = "gemini-2.0-flash"
+	// DefaultEmbeddingModel is the model EmbedTexts uses when none is given
+	DefaultEmbeddingModel = "text-embedding-004"
+	// MaxEmbedBatch is the most texts the API embeds in one call
+	MaxEmbedBatch = 100
+)
+
+// strictSafetySettings block content of every harm category from a low probability up
+var strictSafetySettings = []*genai.SafetySetting{
+	{Category: genai.HarmCategoryHarassment, Threshold: genai.HarmBlockLowAndAbove},
+	{Category: genai.HarmCategoryHateSpeech, Threshold: genai.HarmBlockLowAndAbove},
+	{Category: genai.HarmCategorySexuallyExplicit, Threshold: genai.HarmBlockLowAndAbove},
+	{Category: genai.HarmCategoryDangerousContent, Threshold: genai.HarmBlockLowAndAbove},
+}
+
+// Gemini generates text with a Google Gemini model and keeps usage counters
+type Gemini struct {
+	client *genai.Client
+	// model is replaced by Configure, so in-flight requests keep the model they started with
+	model     atomic.Pointer[genai.GenerativeModel]
+	modelName atomic.Pointer[string]
+	// cacheTTL is how long contexts set with WithCachedContext are cached, 0 to send them inline
+	cacheTTL atomic.Int64
+	caches   contextCaches
+
+	requests     atomic.Int64
+	failures     atomic.Int64
+	promptTokens atomic.Int64
+	outputTokens atomic.Int64
+	cachedTokens atomic.Int64
+}
+
+// Params are the model settings that can change at runtime; nil fields use the API defaults
+type Params struct {
+	Model           string
+	Temperature     *float32
+	MaxOutputTokens *int32
+	// ContextCacheTTL is how long contexts set with WithCachedContext are kept in the API's cache after
+	// their last use; 0 sends them inline with every request
+	ContextCacheTTL time.Duration
+}
+
+// Usage is a snapshot of the Gemini calls and tokens since the process started
+type Usage struct {
+	Requests     int64
+	Failures     int64
+	PromptTokens int64
+	OutputTokens int64
+	// CachedTokens are the prompt tokens read from context caches, which cost less
+	CachedTokens int64
+}
+
+// NewGemini connects to the Gemini API; an empty model name means DefaultModel
+func NewGemini(ctx context.Context, apiKey, model string) (*Gemini, error) {
+	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Gemini client: %w", err)
+	}
+
+	g := &Gemini{client: client, caches: contextCaches{client: client, byKey: map[string]*contextCache{}}}
+	g.Configure(Params{Model: model})
+	return g, nil
+}
+
+// Configure switches the model and its parameters; an empty model name means DefaultModel
+func (g *Gemini) Configure(p Params) {
+	if p.Model == "" {
+		p.Model = DefaultModel
+	}
+	model := g.client.GenerativeModel(p.Model)
+	model.Temperature = p.Temperature
+	model.MaxOutputTokens = p.MaxOutputTokens
+	g.model.Store(model)
+	g.modelName.Store(&p.Model)
+	g.cacheTTL.Store(int64(p.ContextCacheTTL))
+}
+
+// GenerateText sends a single text prompt to the model and returns the first text part of the reply;
+// a model set with WithModel replaces the configured one, keeping its generation parameters, a cap set with
+// WithMaxOutputTokens replaces the configured one, WithStrictSafety blocks from the lowest harm probability,
+// WithSystemInstruction is sent in the system role, a context set with WithCachedContext is read from an API
+// cache, WithJSONSchema asks for a JSON answer, turns set with WithHistory come before the prompt, and tools
+// set with WithTools may be called before the model answers. Blocked requests return ErrBlocked.
+func (g *Gemini) GenerateText(ctx context.Context, prompt string) (string, error) {
+	text, err := g.generateText(ctx, prompt)
+	var blocked *genai.BlockedError
+	if errors.As(err, &blocked) {
+		return "", fmt.Errorf("%w: %v", ErrBlocked, err)
+	}
+	return text, err
+}
+
+func (g *Gemini) generateText(ctx context.Context, prompt string) (string, error) {
+	model, name := g.model.Load(), *g.modelName.Load()
+	if override := ModelFromContext(ctx); override != "" {
+		name = override
+		overridden := g.client.GenerativeModel(name)
+		overridden.GenerationConfig = model.GenerationConfig
+		model = overridden
+	}
+	if n := MaxOutputTokensFromContext(ctx); n > 0 {
+		limited := *model
+		limited.MaxOutputTokens = &n
+		model = &limited
+	}
+	if StrictSafetyFromContext(ctx) {
+		strict := *model
+		strict.SafetySettings = strictSafetySettings
+		model = &strict
+	}
+	model, instruction := g.withCachedContext(ctx, model, name, SystemInstructionFromContext(ctx))
+	if instruction != "" {
+		instructed := *model
+		instructed.SystemInstruction = genai.NewUserContent(genai.Text(instruction))
+		model = &instructed
+	}
+	if schema := JSONSchemaFromContext(ctx); schema != nil {
+		structured := *model
+		structured.ResponseMIMEType = "application/json"
+		structured.ResponseSchema = genaiSchema(schema)
+		model = &structured
+	}
+	if tools := ToolsFromContext(ctx); len(tools) > 0 {
+		return g.generateWithTools(ctx, model, prompt, tools)
+	}
+
+	var resp *genai.GenerateContentResponse
+	var err error
+	if history := HistoryFromContext(ctx); len(history) > 0 {
+		session := model.StartChat()
+		session.History = contents(history)
+		resp, err = session.SendMessage(ctx, genai.Text(prompt))
+	} else {
+		resp, err = model.GenerateContent(ctx, genai.Text(prompt))
+	}
+	g.record(ctx, resp, err)
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("empty response from model")
+	}
+
+	if text, ok := resp.Candidates[0].Content.Parts[0].(genai.Text); ok {
+		return string(text), nil
+	}
+	return "", fmt.Errorf("unexpected response part type")
+}
+
+// contents converts conversation turns to the API's content
+func contents(turns []Turn) []*genai.Content {
+	history := make([]*genai.Content, len(turns))
+	for i, t := range turns {
+		history[i] = &genai.Content{Role: t.Role, Parts: []genai.Part{genai.Text(t.Text)}}
+	}
+	return history
+}
+
+// EmbedTexts embeds up to MaxEmbedBatch texts in one call, returning one vector per text in order;
+// an empty model name means DefaultEmbeddingModel
+func (g *Gemini) EmbedTexts(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	if model == "" {
+		model = DefaultEmbeddingModel
+	}
+	em := g.client.EmbeddingModel(model)
+	em.TaskType = genai.TaskTypeRetrievalDocument
+	batch := em.NewBatch()
+	for _, text := range texts {
+		batch.AddContent(genai.Text(text))
+	}
+
+	g.requests.Add(1)
+	resp, err := em.BatchEmbedContents(ctx, batch)
+	if err != nil {
+		g.failures.Add(1)
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(resp.Embeddings), len(texts))
+	}
+	vectors := make([][]float32, len(texts))
+	for i, e := range resp.Embeddings {
+		vectors[i] = e.Values
+	}
+	return vectors, nil
+}
+
+// EmbedQuery embeds a search query; an empty model name means DefaultEmbeddingModel
+func (g *Gemini) EmbedQuery(ctx context.Context, model string, text string) ([]float32, error) {
+	if model == "" {
+		model = DefaultEmbeddingModel
+	}
+	em := g.client.EmbeddingModel(model)
+	em.TaskType = genai.TaskTypeRetrievalQuery
+
+	g.requests.Add(1)
+	resp, err := em.EmbedContent(ctx, genai.Text(text))
+	if err != nil {
+		g.failures.Add(1)
+		return nil, err
+	}
+	if resp.Embedding == nil {
+		return nil, fmt.Errorf("empty embedding from model")
+	}
+	return resp.Embedding.Values, nil
+}
+
+// Usage returns the current usage counters
+func (g *Gemini) Usage() Usage {
+	return Usage{
+		Requests:     g.requests.Load(),
+		Failures:     g.failures.Load(),
+		PromptTokens: g.promptTokens.Load(),
+		OutputTokens: g.outputTokens.Load(),
+		CachedTokens: g.cachedTokens.Load(),
+	}
+}
+
+func (g *Gemini) record(ctx context.Context, resp *genai.GenerateContentResponse, err error) {
+	g.requests.Add(1)
+	if err != nil {
+		g.failures.Add(1)
+		return
+	}
+	if resp != nil && resp.UsageMetadata != nil {
+		prompt, output := int64(resp.UsageMetadata.PromptTokenCount), int64(resp.UsageMetadata.CandidatesTokenCount)
+		cached := int64(resp.UsageMetadata.CachedContentTokenCount)
+		g.promptTokens.Add(prompt)
+		g.outputTokens.Add(output)
+		g.cachedTokens.Add(cached)
+		if usage, ok := ctx.Value(usageKey{}).(*TokenUsage); ok {
+			usage.PromptTokens.Add(prompt)
+			usage.OutputTokens.Add(output)
+			usage.CachedTokens.Add(cached)
+		}
+	}
+}
+
+func (g *Gemini) Close() {
+	g.dropCachedContexts()
+	if err := g.client.Close(); err != nil {
+		log.Printf("error closing Gemini client: %v", err)
+	}
+}
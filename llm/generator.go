@@ -0,0 +1,168 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrBlocked is returned by generators when the model's safety filters blocked the prompt or the answer
+var ErrBlocked = errors.New("blocked by the model's safety filters")
+
+// Generator produces text from a prompt; *Gemini implements it, and tests can swap in a fake
+type Generator interface {
+	GenerateText(ctx context.Context, prompt string) (string, error)
+}
+
+// Embedder turns texts into vectors for semantic search; *Gemini implements it
+type Embedder interface {
+	// EmbedTexts embeds documents to search in
+	EmbedTexts(ctx context.Context, model string, texts []string) ([][]float32, error)
+	// EmbedQuery embeds a search query, which models may place differently from documents
+	EmbedQuery(ctx context.Context, model string, text string) ([]float32, error)
+}
+
+// UsageReporter is implemented by generators that count their calls and tokens
+type UsageReporter interface {
+	Usage() Usage
+}
+
+// Configurable is implemented by generators whose model parameters can change at runtime
+type Configurable interface {
+	Configure(p Params)
+}
+
+type modelKey struct{}
+
+// WithModel asks generators that support it to use another model for requests made with ctx
+func WithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey{}, model)
+}
+
+// ModelFromContext returns the model set with WithModel, or "" for the generator's default
+func ModelFromContext(ctx context.Context) string {
+	model, _ := ctx.Value(modelKey{}).(string)
+	return model
+}
+
+type maxOutputTokensKey struct{}
+
+// WithMaxOutputTokens asks generators that support it to cap the answers to requests made with ctx at n tokens
+func WithMaxOutputTokens(ctx context.Context, n int32) context.Context {
+	return context.WithValue(ctx, maxOutputTokensKey{}, n)
+}
+
+// MaxOutputTokensFromContext returns the cap set with WithMaxOutputTokens, or 0 for the generator's default
+func MaxOutputTokensFromContext(ctx context.Context) int32 {
+	n, _ := ctx.Value(maxOutputTokensKey{}).(int32)
+	return n
+}
+
+type systemInstructionKey struct{}
+
+// WithSystemInstruction asks generators that support it to send instruction in the system role for requests
+// made with ctx, apart from the prompt
+func WithSystemInstruction(ctx context.Context, instruction string) context.Context {
+	return context.WithValue(ctx, systemInstructionKey{}, instruction)
+}
+
+// SystemInstructionFromContext returns the instruction set with WithSystemInstruction, or ""
+func SystemInstructionFromContext(ctx context.Context) string {
+	instruction, _ := ctx.Value(systemInstructionKey{}).(string)
+	return instruction
+}
+
+// Roles of the turns of a conversation
+const (
+	RoleUser  = "user"
+	RoleModel = "model"
+)
+
+// Turn is one earlier message of a conversation with the model
+type Turn struct {
+	Role string
+	Text string
+}
+
+type historyKey struct{}
+
+// WithHistory asks generators that support it to send the prompt as the next user message of a conversation
+// with these earlier turns, oldest first
+func WithHistory(ctx context.Context, turns ...Turn) context.Context {
+	return context.WithValue(ctx, historyKey{}, turns)
+}
+
+// HistoryFromContext returns the turns set with WithHistory
+func HistoryFromContext(ctx context.Context) []Turn {
+	turns, _ := ctx.Value(historyKey{}).([]Turn)
+	return turns
+}
+
+// Schema describes the JSON answer asked for with WithJSONSchema
+type Schema struct {
+	// Type is "object", "array", "string", "number", "integer" or "boolean"
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// Items is the schema of the elements of an array
+	Items    *Schema  `json:"items,omitempty"`
+	Enum     []string `json:"enum,omitempty"`
+	Nullable bool     `json:"nullable,omitempty"`
+}
+
+type jsonSchemaKey struct{}
+
+// WithJSONSchema asks generators that support it to answer requests made with ctx with JSON matching schema
+func WithJSONSchema(ctx context.Context, schema *Schema) context.Context {
+	return context.WithValue(ctx, jsonSchemaKey{}, schema)
+}
+
+// JSONSchemaFromContext returns the schema set with WithJSONSchema, or nil for a text answer
+func JSONSchemaFromContext(ctx context.Context) *Schema {
+	schema, _ := ctx.Value(jsonSchemaKey{}).(*Schema)
+	return schema
+}
+
+type strictSafetyKey struct{}
+
+// WithStrictSafety asks generators that support it to block even mildly unsafe content, like harassment,
+// hate speech, sexual or dangerous content, in requests made with ctx
+func WithStrictSafety(ctx context.Context) context.Context {
+	return context.WithValue(ctx, strictSafetyKey{}, true)
+}
+
+// StrictSafetyFromContext reports whether ctx was made with WithStrictSafety
+func StrictSafetyFromContext(ctx context.Context) bool {
+	strict, _ := ctx.Value(strictSafetyKey{}).(bool)
+	return strict
+}
+
+// TokenUsage collects the tokens of the requests made with a context from TrackUsage
+type TokenUsage struct {
+	PromptTokens atomic.Int64
+	OutputTokens atomic.Int64
+	// CachedTokens are the part of PromptTokens read from a context cache
+	CachedTokens atomic.Int64
+}
+
+// Total returns the prompt and output tokens together
+func (u *TokenUsage) Total() int64 {
+	return u.PromptTokens.Load() + u.OutputTokens.Load()
+}
+
+type usageKey struct{}
+
+// TrackUsage returns a context whose requests add their token counts to the returned TokenUsage,
+// for generators that report usage
+func TrackUsage(ctx context.Context) (context.Context, *TokenUsage) {
+	usage := &TokenUsage{}
+	return context.WithValue(ctx, usageKey{}, usage), usage
+}
+
+var (
+	_ Generator     = (*Gemini)(nil)
+	_ Embedder      = (*Gemini)(nil)
+	_ UsageReporter = (*Gemini)(nil)
+	_ Configurable  = (*Gemini)(nil)
+)
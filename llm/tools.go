@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// maxToolRounds bounds how many times the model may call tools before it has to answer
+const maxToolRounds = 5
+
+// Tool is a function the model may call while answering, like a unit converter or a weather lookup
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]Parameter
+	Required    []string
+	// Call runs the tool with the arguments the model chose; the result is sent back to the model as JSON
+	Call func(ctx context.Context, args map[string]any) (any, error)
+}
+
+// Parameter describes an argument of a tool; Type is "string", "number", "integer" or "boolean"
+type Parameter struct {
+	Type        string
+	Description string
+	Enum        []string
+}
+
+type toolsKey struct{}
+
+// WithTools offers tools to the model for requests made with ctx, on generators that support function calling
+func WithTools(ctx context.Context, tools ...Tool) context.Context {
+	return context.WithValue(ctx, toolsKey{}, slices.Concat(ToolsFromContext(ctx), tools))
+}
+
+// ToolsFromContext returns the tools set with WithTools
+func ToolsFromContext(ctx context.Context) []Tool {
+	tools, _ := ctx.Value(toolsKey{}).([]Tool)
+	return tools
+}
+
+// StringArg returns a string argument of a tool call, or "" if it's missing
+func StringArg(args map[string]any, name string) string {
+	s, _ := args[name].(string)
+	return strings.TrimSpace(s)
+}
+
+// NumberArg returns a numeric argument of a tool call
+func NumberArg(args map[string]any, name string) (float64, bool) {
+	n, ok := args[name].(float64)
+	return n, ok
+}
+
+var schemaTypes = map[string]genai.Type{
+	"string":  genai.TypeString,
+	"number":  genai.TypeNumber,
+	"integer": genai.TypeInteger,
+	"boolean": genai.TypeBoolean,
+	"object":  genai.TypeObject,
+	"array":   genai.TypeArray,
+}
+
+// genaiSchema converts a Schema to the API's
+func genaiSchema(s *Schema) *genai.Schema {
+	if s == nil {
+		return nil
+	}
+	out := &genai.Schema{Type: schemaTypes[s.Type], Description: s.Description, Required: s.Required,
+		Items: genaiSchema(s.Items), Enum: s.Enum, Nullable: s.Nullable}
+	if len(s.Properties) > 0 {
+		out.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, p := range s.Properties {
+			out.Properties[name] = genaiSchema(p)
+		}
+	}
+	return out
+}
+
+func declarations(tools []Tool) []*genai.Tool {
+	decls := make([]*genai.FunctionDeclaration, len(tools))
+	for i, t := range tools {
+		params := &genai.Schema{Type: genai.TypeObject, Properties: map[string]*genai.Schema{}, Required: t.Required}
+		for name, p := range t.Parameters {
+			params.Properties[name] = &genai.Schema{Type: schemaTypes[p.Type], Description: p.Description, Enum: p.Enum}
+		}
+		decls[i] = &genai.FunctionDeclaration{Name: t.Name, Description: t.Description, Parameters: params}
+	}
+	return []*genai.Tool{{FunctionDeclarations: decls}}
+}
+
+// generateWithTools runs a chat with the model in which it may call tools, up to maxToolRounds times,
+// and returns its final text answer
+func (g *Gemini) generateWithTools(ctx context.Context, model *genai.GenerativeModel, prompt string, tools []Tool) (string, error) {
+	withTools := *model
+	// A context cache already holds the declarations
+	if model.CachedContentName == "" {
+		withTools.Tools = declarations(tools)
+	}
+	byName := make(map[string]Tool, len(tools))
+	for _, t := range tools {
+		byName[t.Name] = t
+	}
+
+	session := withTools.StartChat()
+	session.History = contents(HistoryFromContext(ctx))
+	parts := []genai.Part{genai.Text(prompt)}
+	for range maxToolRounds + 1 {
+		resp, err := session.SendMessage(ctx, parts...)
+		g.record(ctx, resp, err)
+		if err != nil {
+			return "", err
+		}
+		if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+			return "", fmt.Errorf("empty response from model")
+		}
+
+		var text strings.Builder
+		// The session keeps the parts sent in its history, so they aren't reused
+		parts = nil
+		for _, part := range resp.Candidates[0].Content.Parts {
+			switch p := part.(type) {
+			case genai.Text:
+				text.WriteString(string(p))
+			case genai.FunctionCall:
+				parts = append(parts, callTool(ctx, byName, p))
+			}
+		}
+		if len(parts) == 0 {
+			if text.Len() == 0 {
+				return "", fmt.Errorf("empty response from model")
+			}
+			return text.String(), nil
+		}
+	}
+	return "", fmt.Errorf("model kept calling tools after %d rounds", maxToolRounds)
+}
+
+// callTool runs a tool the model asked for; errors go back to the model, which can explain or try again
+func callTool(ctx context.Context, tools map[string]Tool, call genai.FunctionCall) genai.FunctionResponse {
+	tool, ok := tools[call.Name]
+	if !ok {
+		return genai.FunctionResponse{Name: call.Name, Response: map[string]any{"error": "unknown tool " + call.Name}}
+	}
+	result, err := tool.Call(ctx, call.Args)
+	if err != nil {
+		log.Printf("tool %s failed: %v", call.Name, err)
+		return genai.FunctionResponse{Name: call.Name, Response: map[string]any{"error": err.Error()}}
+	}
+	// Responses are sent as protobuf structs, which only take JSON values, so structs are converted first
+	var value any
+	data, err := json.Marshal(result)
+	if err == nil {
+		err = json.Unmarshal(data, &value)
+	}
+	if err != nil {
+		return genai.FunctionResponse{Name: call.Name, Response: map[string]any{"error": "the tool returned an unreadable result"}}
+	}
+	return genai.FunctionResponse{Name: call.Name, Response: map[string]any{"result": value}}
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PosterCount is how many messages someone posted
+type PosterCount struct {
+	Poster Message `bson:"_id"`
+	Count  int     `bson:"count"`
+}
+
+// ReplyCount is how many replies a message got
+type ReplyCount struct {
+	MessageID int `bson:"_id"`
+	Replies   int `bson:"count"`
+}
+
+// ChatActivity sums up the messages of a chat over a period
+type ChatActivity struct {
+	Messages int
+	Posters  int
+	// TopPosters are the most active posters, most messages first
+	TopPosters []PosterCount
+	// Hourly counts messages per UTC hour, keyed by the start of the hour
+	Hourly map[time.Time]int
+	// MostReplied are the messages with the most replies
+	MostReplied []ReplyCount
+}
+
+// Activity computes the activity of a chat in [from, to), with up to limit top posters and most replied messages
+func (s *Store) Activity(chatID int64, from, to time.Time, limit int) (ChatActivity, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	count := bson.M{"$sum": 1}
+	byCount := bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}
+	cursor, err := s.db.Collection("messages").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"chat_id": chatID, "timestamp": bson.M{"$gte": from, "$lt": to}}}},
+		{{Key: "$facet", Value: bson.M{
+			"posters": bson.A{
+				bson.M{"$group": bson.M{"_id": bson.M{
+					"from_username":   "$from_username",
+					"from_first_name": "$from_first_name",
+					"from_last_name":  "$from_last_name",
+				}, "count": count}},
+				bson.M{"$sort": byCount},
+			},
+			"hours": bson.A{
+				bson.M{"$group": bson.M{"_id": hourOf("$timestamp"), "count": count}},
+			},
+			"replied": bson.A{
+				bson.M{"$match": bson.M{"reply_to": bson.M{"$gt": 0}}},
+				bson.M{"$group": bson.M{"_id": "$reply_to", "count": count}},
+				bson.M{"$sort": byCount},
+				bson.M{"$limit": limit},
+			},
+		}}},
+	})
+	if err != nil {
+		return ChatActivity{}, err
+	}
+
+	var facets []struct {
+		Posters []PosterCount `bson:"posters"`
+		Hours   []hourCount   `bson:"hours"`
+		Replied []ReplyCount  `bson:"replied"`
+	}
+	if err := cursor.All(ctx, &facets); err != nil || len(facets) == 0 {
+		return ChatActivity{}, err
+	}
+
+	f := facets[0]
+	activity := ChatActivity{Posters: len(f.Posters), Hourly: hourly(f.Hours), MostReplied: f.Replied}
+	for _, p := range f.Posters {
+		activity.Messages += p.Count
+	}
+	activity.TopPosters = f.Posters[:min(limit, len(f.Posters))]
+	return activity, nil
+}
+
+// AIResponsesHourly counts the logged AI responses of a chat per UTC hour in [from, to)
+func (s *Store) AIResponsesHourly(chatID int64, from, to time.Time) (map[time.Time]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.Collection("ai_responses").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"chat_id": chatID, "created_at": bson.M{"$gte": from, "$lt": to}}}},
+		{{Key: "$group", Value: bson.M{"_id": hourOf("$created_at"), "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var hours []hourCount
+	if err := cursor.All(ctx, &hours); err != nil {
+		return nil, err
+	}
+	return hourly(hours), nil
+}
+
+type hourCount struct {
+	Hour  string `bson:"_id"`
+	Count int    `bson:"count"`
+}
+
+// hourOf groups by the UTC hour of a date field
+func hourOf(field string) bson.M {
+	return bson.M{"$dateToString": bson.M{"format": "%Y-%m-%dT%H", "date": field}}
+}
+
+func hourly(hours []hourCount) map[time.Time]int {
+	counts := make(map[time.Time]int, len(hours))
+	for _, h := range hours {
+		if hour, err := time.Parse("2006-01-02T15", h.Hour); err == nil {
+			counts[hour] = h.Count
+		}
+	}
+	return counts
+}
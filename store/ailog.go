@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AIResponse is a prompt sent to the model on behalf of a chat message and what the model answered
+type AIResponse struct {
+	ChatID    int64     `bson:"chat_id" json:"chat_id"`
+	MessageID int       `bson:"message_id" json:"message_id"`
+	UserID    int64     `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Prompt    string    `bson:"prompt" json:"prompt"`
+	Response  string    `bson:"response,omitempty" json:"response,omitempty"`
+	Error     string    `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	// ExpiresAt is when MongoDB deletes the record, following the chat's retention
+	ExpiresAt time.Time `bson:"expires_at" json:"-"`
+}
+
+// EnsureAILogIndexes creates the indexes of the AI response log, including the one expiring old records
+func (s *Store) EnsureAILogIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("ai_responses").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// InsertAIResponse adds a record to the AI response log
+func (s *Store) InsertAIResponse(r AIResponse) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("ai_responses").InsertOne(ctx, r)
+	return err
+}
+
+// AIResponses returns the latest logged AI responses of a chat, newest first; limit 0 returns all of them
+func (s *Store) AIResponses(chatID int64, limit int) ([]AIResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cursor, err := s.db.Collection("ai_responses").Find(ctx, bson.M{"chat_id": chatID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var responses []AIResponse
+	err = cursor.All(ctx, &responses)
+	return responses, err
+}
+
+// SetAIResponseRetention makes the logged responses of a chat expire the given days after they were created;
+// 0 deletes them right away
+func (s *Store) SetAIResponseRetention(chatID int64, days int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := s.db.Collection("ai_responses")
+	if days == 0 {
+		_, err := collection.DeleteMany(ctx, bson.M{"chat_id": chatID})
+		return err
+	}
+	_, err := collection.UpdateMany(ctx, bson.M{"chat_id": chatID}, mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"expires_at": bson.M{"$dateAdd": bson.M{"startDate": "$created_at", "unit": "day", "amount": days}},
+	}}}})
+	return err
+}
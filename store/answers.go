@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AnswerPartsRetention is how long follow-ups like "more on 2" can refer to the parts of an answer
+const AnswerPartsRetention = 30 * 24 * time.Hour
+
+// AnswerPart is the answer to one of the questions of a multi-part question
+type AnswerPart struct {
+	Question string `bson:"question"`
+	Answer   string `bson:"answer"`
+}
+
+// AnswerParts are the numbered parts of an answer the bot sent as one message or as separate replies
+type AnswerParts struct {
+	ChatID int64 `bson:"chat_id"`
+	// MessageID is one of the messages the answer was sent as
+	MessageID int          `bson:"message_id"`
+	Parts     []AnswerPart `bson:"parts"`
+	CreatedAt time.Time    `bson:"created_at"`
+}
+
+// SaveAnswerParts records the parts of an answer under each message it was sent as
+func (s *Store) SaveAnswerParts(chatID int64, messageIDs []int, parts []AnswerPart) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	docs := make([]any, 0, len(messageIDs))
+	for _, id := range messageIDs {
+		docs = append(docs, AnswerParts{ChatID: chatID, MessageID: id, Parts: parts, CreatedAt: now})
+	}
+	_, err := s.db.Collection("answer_parts").InsertMany(ctx, docs)
+	return err
+}
+
+// AnswerPartsOf returns the parts of the answer sent as a message, or mongo.ErrNoDocuments when it wasn't a
+// multi-part answer or they expired
+func (s *Store) AnswerPartsOf(chatID int64, messageID int) (AnswerParts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var a AnswerParts
+	err := s.db.Collection("answer_parts").FindOne(ctx, bson.M{"chat_id": chatID, "message_id": messageID}).Decode(&a)
+	return a, err
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditEntry records an admin or configuration action in a chat: who did what, and when
+type AuditEntry struct {
+	ChatID int64 `bson:"chat_id"`
+	// ActorID is 0 for actions taken by the bot itself or from the dashboard
+	ActorID   int64     `bson:"actor_id,omitempty"`
+	Actor     string    `bson:"actor"`
+	Action    string    `bson:"action"`
+	Details   string    `bson:"details,omitempty"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// InsertAuditEntry adds an entry to the audit log
+func (s *Store) InsertAuditEntry(entry AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("audit_log").InsertOne(ctx, entry)
+	return err
+}
+
+// AuditEntries returns the latest audit entries of a chat, newest first
+func (s *Store) AuditEntries(chatID int64, limit int) ([]AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.Collection("audit_log").Find(ctx,
+		bson.M{"chat_id": chatID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var entries []AuditEntry
+	err = cursor.All(ctx, &entries)
+	return entries, err
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// restoreBatchSize is how many documents Restore inserts at once
+const restoreBatchSize = 500
+
+// backupLine is one document of a backup: gzip-compressed JSON lines of canonical extended JSON,
+// so types like ObjectIDs, dates and int64s survive the round trip
+type backupLine struct {
+	Collection string          `json:"c"`
+	Document   json.RawMessage `json:"d"`
+}
+
+// Backup writes every collection of the database to w and returns the number of documents per collection
+func (s *Store) Backup(ctx context.Context, w io.Writer) (map[string]int, error) {
+	names, err := s.db.ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+	counts := make(map[string]int)
+	for _, name := range names {
+		cursor, err := s.db.Collection(name).Find(ctx, bson.M{})
+		if err != nil {
+			return counts, fmt.Errorf("reading %s: %w", name, err)
+		}
+		for cursor.Next(ctx) {
+			doc, err := bson.MarshalExtJSON(cursor.Current, true, false)
+			if err != nil {
+				cursor.Close(ctx)
+				return counts, fmt.Errorf("encoding a document of %s: %w", name, err)
+			}
+			if err := enc.Encode(backupLine{Collection: name, Document: doc}); err != nil {
+				cursor.Close(ctx)
+				return counts, err
+			}
+			counts[name]++
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return counts, fmt.Errorf("reading %s: %w", name, err)
+		}
+	}
+	return counts, gz.Close()
+}
+
+// Restore loads a backup made by Backup. Collections that already have documents are skipped, unless
+// overwrite is set, which drops them first; it returns the documents restored and the collections skipped.
+func (s *Store) Restore(ctx context.Context, r io.Reader, overwrite bool) (map[string]int, []string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	defer gz.Close()
+
+	counts := make(map[string]int)
+	// skip holds the collections seen so far, true for those that keep their documents
+	skip := make(map[string]bool)
+	var skipped []string
+	batches := make(map[string][]any)
+
+	flush := func(name string) error {
+		if len(batches[name]) == 0 {
+			return nil
+		}
+		if _, err := s.db.Collection(name).InsertMany(ctx, batches[name]); err != nil {
+			return fmt.Errorf("restoring %s: %w", name, err)
+		}
+		counts[name] += len(batches[name])
+		batches[name] = nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(gz)
+	// MongoDB documents can be up to 16 MB, and extended JSON is larger still
+	scanner.Buffer(make([]byte, 0, 64<<10), 64<<20)
+	for scanner.Scan() {
+		var line backupLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return counts, skipped, fmt.Errorf("corrupt backup line: %w", err)
+		}
+
+		if _, seen := skip[line.Collection]; !seen {
+			skip[line.Collection] = false
+			collection := s.db.Collection(line.Collection)
+			n, err := collection.EstimatedDocumentCount(ctx)
+			if err != nil {
+				return counts, skipped, err
+			}
+			switch {
+			case n > 0 && !overwrite:
+				skip[line.Collection] = true
+				skipped = append(skipped, line.Collection)
+			case n > 0:
+				if err := collection.Drop(ctx); err != nil {
+					return counts, skipped, fmt.Errorf("dropping %s: %w", line.Collection, err)
+				}
+			}
+		}
+		if skip[line.Collection] {
+			continue
+		}
+
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(line.Document, true, &doc); err != nil {
+			return counts, skipped, fmt.Errorf("decoding a document of %s: %w", line.Collection, err)
+		}
+		batches[line.Collection] = append(batches[line.Collection], doc)
+		if len(batches[line.Collection]) >= restoreBatchSize {
+			if err := flush(line.Collection); err != nil {
+				return counts, skipped, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return counts, skipped, err
+	}
+	for name := range batches {
+		if err := flush(name); err != nil {
+			return counts, skipped, err
+		}
+	}
+	return counts, skipped, nil
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChatRecord is an entry of the registry of chats the bot is or was in
+type ChatRecord struct {
+	ChatID      int64  `bson:"_id"`
+	Title       string `bson:"title"`
+	Type        string `bson:"type"`
+	MemberCount int    `bson:"member_count,omitempty"`
+	// AddedBy is the user who added the bot, most recently if it was added more than once; JoinedAt is zero
+	// for chats the bot was in before the registry existed
+	AddedBy  int64      `bson:"added_by,omitempty"`
+	JoinedAt time.Time  `bson:"joined_at,omitempty"`
+	LeftAt   *time.Time `bson:"left_at,omitempty"`
+	// MigratedTo is the supergroup a group was upgraded to
+	MigratedTo int64 `bson:"migrated_to,omitempty"`
+	// Bots are the user IDs of the bots of a fleet that are in the chat; empty for chats registered before
+	// the registry kept track of them
+	Bots []int64 `bson:"bots,omitempty"`
+	// CannotPostSince is when Telegram started refusing the bot's messages for lack of rights
+	CannotPostSince *time.Time `bson:"cannot_post_since,omitempty"`
+	// Messages counts the messages seen in the chat, stored or not, since the registry existed
+	Messages       int64     `bson:"messages"`
+	LastActivityAt time.Time `bson:"last_activity_at,omitempty"`
+	// Settings is a snapshot of the chat's settings, taken with its latest activity or lifecycle change
+	Settings  ChatSettings `bson:"settings"`
+	UpdatedAt time.Time    `bson:"updated_at"`
+}
+
+// ChatActivityUpdate is what the bot saw of a chat since its last registry update
+type ChatActivityUpdate struct {
+	// BotID is the bot that saw the activity, which is in the chat
+	BotID       int64
+	Title       string
+	Type        string
+	MemberCount int
+	Messages    int64
+	At          time.Time
+	Settings    ChatSettings
+}
+
+// RecordChatJoin registers a chat the bot botID was added to, or marks a chat it left as joined again
+func (s *Store) RecordChatJoin(chatID int64, title, chatType string, addedBy, botID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	_, err := s.db.Collection("chats").UpdateOne(ctx,
+		bson.M{"_id": chatID},
+		bson.M{
+			"$set": bson.M{"title": title, "type": chatType, "added_by": addedBy, "joined_at": now,
+				"last_activity_at": now, "settings": s.ChatSettings(chatID), "updated_at": now},
+			"$unset":    bson.M{"left_at": "", "migrated_to": "", "cannot_post_since": ""},
+			"$addToSet": bson.M{"bots": botID},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// RecordChatLeave records that the bot botID was removed from a registered chat or left it; the chat is
+// marked as left once no bot of the fleet is in it
+func (s *Store) RecordChatLeave(chatID, botID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	chats := s.db.Collection("chats")
+	if _, err := chats.UpdateOne(ctx, bson.M{"_id": chatID}, bson.M{"$pull": bson.M{"bots": botID}}); err != nil {
+		return err
+	}
+	now := time.Now()
+	_, err := chats.UpdateOne(ctx, bson.M{"_id": chatID, "bots.0": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"left_at": now, "settings": s.ChatSettings(chatID), "updated_at": now}})
+	return err
+}
+
+// RecordCannotPost marks a registered chat as one the bot lacks the rights to post in, or clears the mark
+func (s *Store) RecordCannotPost(chatID int64, cannotPost bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{"$unset": bson.M{"cannot_post_since": ""}}
+	if cannotPost {
+		update = bson.M{"$set": bson.M{"cannot_post_since": time.Now()}}
+	}
+	_, err := s.db.Collection("chats").UpdateOne(ctx, bson.M{"_id": chatID}, update)
+	return err
+}
+
+// RecordChatMigration marks a group as left for the supergroup it was upgraded to, which inherits when and
+// by whom the bot was added
+func (s *Store) RecordChatMigration(fromID, toID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	chats := s.db.Collection("chats")
+	var old ChatRecord
+	err := chats.FindOneAndUpdate(ctx, bson.M{"_id": fromID},
+		bson.M{"$set": bson.M{"left_at": time.Now(), "migrated_to": toID, "updated_at": time.Now()}}).Decode(&old)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = chats.UpdateOne(ctx, bson.M{"_id": toID},
+		bson.M{"$setOnInsert": bson.M{"added_by": old.AddedBy, "joined_at": old.JoinedAt, "messages": old.Messages}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// RecordChatActivity updates a chat's title, member count, settings snapshot and activity, registering it
+// if the bot was in it before the registry existed
+func (s *Store) RecordChatActivity(chatID int64, update ChatActivityUpdate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{"type": update.Type, "last_activity_at": update.At, "settings": update.Settings, "updated_at": time.Now()}
+	if update.Title != "" {
+		set["title"] = update.Title
+	}
+	if update.MemberCount > 0 {
+		set["member_count"] = update.MemberCount
+	}
+	change := bson.M{"$set": set, "$inc": bson.M{"messages": update.Messages}, "$unset": bson.M{"left_at": ""}}
+	if update.BotID != 0 {
+		change["$addToSet"] = bson.M{"bots": update.BotID}
+	}
+	_, err := s.db.Collection("chats").UpdateOne(ctx,
+		bson.M{"_id": chatID},
+		change,
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// Chats returns the registered chats, most recently active first; left chats only with includeLeft
+func (s *Store) Chats(includeLeft bool) ([]ChatRecord, error) {
+	filter := bson.M{}
+	if !includeLeft {
+		filter["left_at"] = nil
+	}
+	return s.findChats(filter, options.Find().SetSort(bson.D{{Key: "last_activity_at", Value: -1}}))
+}
+
+// Chat returns a chat's registry entry, or nil if it isn't registered
+func (s *Store) Chat(chatID int64) (*ChatRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var chat ChatRecord
+	err := s.db.Collection("chats").FindOne(ctx, bson.M{"_id": chatID}).Decode(&chat)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+func (s *Store) findChats(filter bson.M, opts *options.FindOptions) ([]ChatRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.Collection("chats").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var chats []ChatRecord
+	err = cursor.All(ctx, &chats)
+	return chats, err
+}
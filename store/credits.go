@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Credit ledger scopes: credits belong to a user or to a group chat
+const (
+	CreditScopeUser = "user"
+	CreditScopeChat = "chat"
+)
+
+// CreditEntry is one change of a credit balance; spending is a negative amount
+type CreditEntry struct {
+	Scope     string    `bson:"scope"`
+	SubjectID int64     `bson:"subject_id"`
+	Amount    int       `bson:"amount"`
+	Reason    string    `bson:"reason"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// AddCredits appends an entry to the credit ledger of a user or chat, like referral rewards or spending
+func (s *Store) AddCredits(scope string, subjectID int64, amount int, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entry := CreditEntry{Scope: scope, SubjectID: subjectID, Amount: amount, Reason: reason, CreatedAt: time.Now()}
+	_, err := s.db.Collection("credit_ledger").InsertOne(ctx, entry)
+	return err
+}
+
+// CreditBalance sums the ledger of a user or chat; opened is false while the wallet has no entries yet
+func (s *Store) CreditBalance(scope string, subjectID int64) (balance int, opened bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.Collection("credit_ledger").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"scope": scope, "subject_id": subjectID}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "balance": bson.M{"$sum": "$amount"}}}},
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	var totals []struct {
+		Balance int `bson:"balance"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil || len(totals) == 0 {
+		return 0, false, err
+	}
+	return totals[0].Balance, true, nil
+}
+
+// GrantCreditsOnce adds credits under a key that can only be used once per user or chat, like a starter grant,
+// returning false if it was granted before
+func (s *Store) GrantCreditsOnce(scope string, subjectID int64, amount int, reason string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	doc := bson.M{
+		"_id":        fmt.Sprintf("%s:%s:%d", reason, scope, subjectID),
+		"scope":      scope,
+		"subject_id": subjectID,
+		"amount":     amount,
+		"reason":     reason,
+		"created_at": time.Now(),
+	}
+	_, err := s.db.Collection("credit_ledger").InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
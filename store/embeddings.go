@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxEmbedAttempts is how often embedding a message is tried before it is given up on
+const MaxEmbedAttempts = 5
+
+// PendingEmbedding is a stored message that has no embedding yet
+type PendingEmbedding struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	ChatID    int64              `bson:"chat_id"`
+	MessageID int                `bson:"message_id"`
+	Text      string             `bson:"text"`
+	Attempts  int                `bson:"embed_attempts"`
+}
+
+// EmbeddingStats counts messages by embedding state
+type EmbeddingStats struct {
+	Embedded int64
+	Pending  int64
+	Failed   int64
+}
+
+// EnsureEmbeddingIndexes creates the index the embedding worker polls with
+func (s *Store) EnsureEmbeddingIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("messages").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "embedded_at", Value: 1}, {Key: "timestamp", Value: -1}},
+	})
+	return err
+}
+
+// pendingEmbeddingFilter matches messages not embedded yet, including those stored before embeddings were enabled,
+// whose retry is due and which haven't failed too often
+func pendingEmbeddingFilter(now time.Time) bson.M {
+	return bson.M{
+		"embedded_at":    nil,
+		"deleted_at":     nil,
+		"text":           bson.M{"$ne": ""},
+		"embed_attempts": bson.M{"$not": bson.M{"$gte": MaxEmbedAttempts}},
+		"$or": bson.A{
+			bson.M{"embed_retry_at": bson.M{"$exists": false}},
+			bson.M{"embed_retry_at": bson.M{"$lte": now}},
+		},
+	}
+}
+
+// PendingEmbeddings returns up to limit messages to embed, newest first, so new messages are searchable
+// quickly while the backfill works through history
+func (s *Store) PendingEmbeddings(limit int) ([]PendingEmbedding, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"chat_id": 1, "message_id": 1, "text": 1, "embed_attempts": 1})
+	cursor, err := s.db.Collection("messages").Find(ctx, pendingEmbeddingFilter(time.Now()), opts)
+	if err != nil {
+		return nil, err
+	}
+	var pending []PendingEmbedding
+	err = cursor.All(ctx, &pending)
+	return pending, err
+}
+
+// MarkEmbedded records that messages were embedded and stored in the vector store
+func (s *Store) MarkEmbedded(ids []primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("messages").UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{
+			"$set":   bson.M{"embedded_at": time.Now()},
+			"$unset": bson.M{"embed_retry_at": "", "embed_error": ""},
+		},
+	)
+	return err
+}
+
+// MarkEmbeddingFailed counts a failed attempt and schedules the next one with exponential backoff
+func (s *Store) MarkEmbeddingFailed(pending []PendingEmbedding, cause error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	writes := make([]mongo.WriteModel, 0, len(pending))
+	for _, p := range pending {
+		backoff := time.Minute << p.Attempts
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": p.ID}).SetUpdate(bson.M{
+			"$inc": bson.M{"embed_attempts": 1},
+			"$set": bson.M{"embed_retry_at": time.Now().Add(backoff), "embed_error": cause.Error()},
+		}))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+	_, err := s.db.Collection("messages").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// EmbeddedMessages returns up to limit messages of a chat since the given time that have embeddings, newest first
+func (s *Store) EmbeddedMessages(chatID int64, since time.Time, limit int) ([]Message, error) {
+	return s.FindMessages(bson.M{
+		"chat_id":     chatID,
+		"timestamp":   bson.M{"$gte": since},
+		"embedded_at": bson.M{"$ne": nil},
+	}, limit)
+}
+
+// EmbeddingStats reports how far the embedding worker has come
+func (s *Store) EmbeddingStats() (EmbeddingStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var stats EmbeddingStats
+	var err error
+	messages := s.db.Collection("messages")
+	if stats.Embedded, err = messages.CountDocuments(ctx, bson.M{"embedded_at": bson.M{"$ne": nil}}); err != nil {
+		return stats, err
+	}
+	if stats.Failed, err = messages.CountDocuments(ctx, bson.M{"embedded_at": nil, "embed_attempts": bson.M{"$gte": MaxEmbedAttempts}}); err != nil {
+		return stats, err
+	}
+	stats.Pending, err = messages.CountDocuments(ctx, bson.M{
+		"embedded_at":    nil,
+		"text":           bson.M{"$ne": ""},
+		"embed_attempts": bson.M{"$not": bson.M{"$gte": MaxEmbedAttempts}},
+	})
+	return stats, err
+}
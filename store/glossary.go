@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GlossaryTerm is a chat-specific term, like a nickname, inside joke or "the usual place", and what it means
+type GlossaryTerm struct {
+	ChatID  int64  `bson:"chat_id"`
+	Term    string `bson:"term"`
+	Meaning string `bson:"meaning"`
+	// AddedBy is the admin who set the term, 0 for terms mined from the chat history
+	AddedBy   int64     `bson:"added_by,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// glossaryKey is the case-insensitive identity of a term
+func glossaryKey(term string) string {
+	return strings.ToLower(strings.TrimSpace(term))
+}
+
+// Glossary returns a chat's terms in alphabetical order
+func (s *Store) Glossary(chatID int64) ([]GlossaryTerm, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "key", Value: 1}})
+	cursor, err := s.db.Collection("glossary").Find(ctx, bson.M{"chat_id": chatID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var terms []GlossaryTerm
+	err = cursor.All(ctx, &terms)
+	return terms, err
+}
+
+// SetGlossaryTerm adds a term to a chat's glossary or replaces its meaning
+func (s *Store) SetGlossaryTerm(t GlossaryTerm) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.UpdatedAt = time.Now()
+	_, err := s.db.Collection("glossary").UpdateOne(ctx,
+		bson.M{"chat_id": t.ChatID, "key": glossaryKey(t.Term)},
+		bson.M{"$set": bson.M{"term": t.Term, "meaning": t.Meaning, "added_by": t.AddedBy, "updated_at": t.UpdatedAt}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// AddGlossaryTerms adds terms to a chat's glossary, keeping the meaning of those it already has, and returns how
+// many were new
+func (s *Store) AddGlossaryTerms(chatID int64, terms []GlossaryTerm) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	added := 0
+	for _, t := range terms {
+		res, err := s.db.Collection("glossary").UpdateOne(ctx,
+			bson.M{"chat_id": chatID, "key": glossaryKey(t.Term)},
+			bson.M{"$setOnInsert": bson.M{"term": t.Term, "meaning": t.Meaning, "updated_at": time.Now()}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return added, err
+		}
+		if res.UpsertedCount > 0 {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// RemoveGlossaryTerm removes a term from a chat's glossary and reports whether it was there
+func (s *Store) RemoveGlossaryTerm(chatID int64, term string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := s.db.Collection("glossary").DeleteOne(ctx, bson.M{"chat_id": chatID, "key": glossaryKey(term)})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// KnowledgeItem is a document or text snippet chat admins added to a group's knowledge base
+type KnowledgeItem struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty"`
+	ChatID  int64              `bson:"chat_id"`
+	Title   string             `bson:"title"`
+	Text    string             `bson:"text"`
+	AddedBy int64              `bson:"added_by"`
+	// Score is the full-text relevance, only set by SearchKnowledge
+	Score     float64   `bson:"score,omitempty"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// EnsureKnowledgeIndexes creates the indexes of the knowledge base, including the full-text one answers search
+func (s *Store) EnsureKnowledgeIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("knowledge").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "title", Value: "text"}, {Key: "text", Value: "text"}},
+			Options: options.Index().SetWeights(bson.M{"title": 3, "text": 1})},
+	})
+	return err
+}
+
+// AddKnowledge adds an item to a chat's knowledge base
+func (s *Store) AddKnowledge(item KnowledgeItem) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	item.CreatedAt = time.Now()
+	_, err := s.db.Collection("knowledge").InsertOne(ctx, item)
+	return err
+}
+
+// ListKnowledge returns a chat's knowledge base, oldest first, without the text of the items
+func (s *Store) ListKnowledge(chatID int64) ([]KnowledgeItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetProjection(bson.M{"text": 0})
+	cursor, err := s.db.Collection("knowledge").Find(ctx, bson.M{"chat_id": chatID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var items []KnowledgeItem
+	err = cursor.All(ctx, &items)
+	return items, err
+}
+
+// KnowledgeTexts returns a chat's whole knowledge base with the text of the items, oldest first
+func (s *Store) KnowledgeTexts(chatID int64) ([]KnowledgeItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := s.db.Collection("knowledge").Find(ctx, bson.M{"chat_id": chatID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var items []KnowledgeItem
+	err = cursor.All(ctx, &items)
+	return items, err
+}
+
+// SearchKnowledge returns the items of a chat's knowledge base that best match a full-text query
+func (s *Store) SearchKnowledge(chatID int64, query string, limit int) ([]KnowledgeItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().SetProjection(bson.M{"score": score}).SetSort(bson.M{"score": score}).SetLimit(int64(limit))
+	cursor, err := s.db.Collection("knowledge").Find(ctx, bson.M{"chat_id": chatID, "$text": bson.M{"$search": query}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var items []KnowledgeItem
+	err = cursor.All(ctx, &items)
+	return items, err
+}
+
+// HasKnowledge reports whether a chat has a knowledge base, so answers only search chats that do
+func (s *Store) HasKnowledge(chatID int64) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n, err := s.db.Collection("knowledge").CountDocuments(ctx, bson.M{"chat_id": chatID}, options.Count().SetLimit(1))
+	return err == nil && n > 0
+}
+
+// RemoveKnowledge deletes an item from a chat's knowledge base and reports whether it existed
+func (s *Store) RemoveKnowledge(chatID int64, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := s.db.Collection("knowledge").DeleteOne(ctx, bson.M{"_id": id, "chat_id": chatID})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
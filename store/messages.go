@@ -0,0 +1,333 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Message represents a chat message stored in MongoDB
+type Message struct {
+	ChatID    int64 `bson:"chat_id"`
+	MessageID int   `bson:"message_id"`
+	// FromID is the sender's user ID; messages stored before it was recorded don't have it
+	FromID        int64     `bson:"from_id,omitempty"`
+	FromUsername  string    `bson:"from_username"`
+	FromFirstName string    `bson:"from_first_name"`
+	FromLastName  string    `bson:"from_last_name"`
+	Text          string    `bson:"text"`
+	Timestamp     time.Time `bson:"timestamp"`
+	// ReplyTo is the ID of the message this one replies to, if any
+	ReplyTo int `bson:"reply_to,omitempty"`
+	// Language is the ISO 639-1 code the text was detected to be in, "" when unclear
+	Language string `bson:"language,omitempty"`
+	// Media is the kind of media a captioned post carried, like "photo"; Text is its caption
+	Media string `bson:"media,omitempty"`
+	// Tags are lowercase hashtags without "#"; nil means the message wasn't tagged yet
+	Tags []string `bson:"tags,omitempty"`
+	// DeletedAt is set when the message was found deleted in Telegram or an admin had it forgotten; deleted
+	// messages are left out of every query
+	DeletedAt *time.Time `bson:"deleted_at,omitempty"`
+	// ReconciledAt is when the message was last checked to still exist in Telegram
+	ReconciledAt *time.Time `bson:"reconciled_at,omitempty"`
+}
+
+// visible returns a copy of filter that also excludes deleted messages
+func visible(filter bson.M) bson.M {
+	out := bson.M{"deleted_at": nil}
+	maps.Copy(out, filter)
+	return out
+}
+
+// Author formats the sender of the message for display
+func (m Message) Author() string {
+	if m.FromUsername != "" {
+		return "@" + m.FromUsername
+	}
+	if m.FromFirstName != "" {
+		name := m.FromFirstName
+		if m.FromLastName != "" {
+			name += " " + m.FromLastName
+		}
+		return name
+	}
+	return "Unknown"
+}
+
+// EnsureMessageIndexes creates the indexes used by message queries and search
+func (s *Store) EnsureMessageIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("messages").Indexes().CreateMany(
+		ctx,
+		[]mongo.IndexModel{
+			{
+				// Index on chat_id and timestamp for efficient queries
+				Keys: bson.D{
+					{Key: "chat_id", Value: 1},
+					{Key: "timestamp", Value: -1},
+				},
+			},
+			{
+				// Full-text index for message search
+				Keys: bson.D{{Key: "text", Value: "text"}},
+			},
+			{
+				// Indexes on the sender for /whois
+				Keys: bson.D{
+					{Key: "chat_id", Value: 1},
+					{Key: "from_id", Value: 1},
+					{Key: "timestamp", Value: -1},
+				},
+			},
+			{
+				Keys: bson.D{
+					{Key: "chat_id", Value: 1},
+					{Key: "from_username", Value: 1},
+					{Key: "timestamp", Value: -1},
+				},
+			},
+			{
+				// Index on chat_id and tags for /find
+				Keys: bson.D{
+					{Key: "chat_id", Value: 1},
+					{Key: "tags", Value: 1},
+					{Key: "timestamp", Value: -1},
+				},
+			},
+		},
+	)
+	return err
+}
+
+// InsertMessage stores a chat message; one already stored, e.g. by another bot of a fleet in the same
+// group, is kept as it is
+func (s *Store) InsertMessage(message Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("messages").InsertOne(ctx, message)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// ImportMessages stores messages from a chat export, skipping those already stored by chat and message ID, and
+// returns how many were new
+func (s *Store) ImportMessages(ctx context.Context, messages []Message) (int64, error) {
+	if len(messages) == 0 {
+		return 0, nil
+	}
+	models := make([]mongo.WriteModel, len(messages))
+	for i, m := range messages {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"chat_id": m.ChatID, "message_id": m.MessageID}).
+			SetUpdate(bson.M{"$setOnInsert": m}).
+			SetUpsert(true)
+	}
+	res, err := s.db.Collection("messages").BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if res == nil {
+		return 0, err
+	}
+	return res.UpsertedCount, err
+}
+
+// FindMessages returns up to limit messages matching filter, newest first
+func (s *Store) FindMessages(filter bson.M, limit int) ([]Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "timestamp", Value: -1}})
+	findOptions.SetLimit(int64(limit))
+
+	cursor, err := s.db.Collection("messages").Find(ctx, visible(filter), findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("database query error: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var messages []Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, fmt.Errorf("error decoding messages: %w", err)
+	}
+	return messages, nil
+}
+
+// MessagesByID returns the messages of a chat with the given IDs, in the order of ids
+func (s *Store) MessagesByID(chatID int64, ids []int) ([]Message, error) {
+	messages, err := s.FindMessages(bson.M{"chat_id": chatID, "message_id": bson.M{"$in": ids}}, len(ids))
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]Message, len(messages))
+	for _, m := range messages {
+		byID[m.MessageID] = m
+	}
+	ordered := make([]Message, 0, len(messages))
+	for _, id := range ids {
+		if m, ok := byID[id]; ok {
+			ordered = append(ordered, m)
+		}
+	}
+	return ordered, nil
+}
+
+// ReplyThread returns a message and the replies descending from it, oldest first, following reply_to links
+// level by level until limit messages are found
+func (s *Store) ReplyThread(chatID int64, rootID int, limit int) ([]Message, error) {
+	thread, err := s.MessagesByID(chatID, []int{rootID})
+	if err != nil || len(thread) == 0 {
+		return nil, err
+	}
+
+	parents := []int{rootID}
+	for len(parents) > 0 && len(thread) < limit {
+		replies, err := s.FindMessages(bson.M{"chat_id": chatID, "reply_to": bson.M{"$in": parents}}, limit-len(thread))
+		if err != nil {
+			return nil, err
+		}
+		parents = parents[:0]
+		for _, m := range replies {
+			thread = append(thread, m)
+			parents = append(parents, m.MessageID)
+		}
+	}
+
+	slices.SortFunc(thread, func(a, b Message) int { return a.Timestamp.Compare(b.Timestamp) })
+	return thread, nil
+}
+
+// CountMessages counts the messages matching filter
+func (s *Store) CountMessages(filter bson.M) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.db.Collection("messages").CountDocuments(ctx, visible(filter))
+}
+
+// SearchMessages returns the most recent messages of a chat matching a full-text query
+func (s *Store) SearchMessages(chatID int64, query string, limit int) ([]Message, error) {
+	return s.FindMessages(bson.M{"chat_id": chatID, "$text": bson.M{"$search": query}}, limit)
+}
+
+// ChatIDs returns every chat that has stored messages
+func (s *Store) ChatIDs() ([]int64, error) {
+	return s.chatIDs(bson.M{})
+}
+
+// ActiveChatIDs returns the chats with messages stored since the given time
+func (s *Store) ActiveChatIDs(since time.Time) ([]int64, error) {
+	return s.chatIDs(bson.M{"timestamp": bson.M{"$gte": since}})
+}
+
+func (s *Store) chatIDs(filter bson.M) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	values, err := s.db.Collection("messages").Distinct(ctx, "chat_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(int64); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// EachMessage calls fn with every message of a chat, oldest first, stopping at the first error
+func (s *Store) EachMessage(ctx context.Context, chatID int64, fn func(Message) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}})
+	cursor, err := s.db.Collection("messages").Find(ctx, visible(bson.M{"chat_id": chatID}), opts)
+	if err != nil {
+		return fmt.Errorf("database query error: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var m Message
+		if err := cursor.Decode(&m); err != nil {
+			return fmt.Errorf("error decoding messages: %w", err)
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// DeleteMessagesBefore deletes the messages stored before the given time, of one chat or of every chat when
+// chatID is 0, and returns how many it deleted
+func (s *Store) DeleteMessagesBefore(ctx context.Context, chatID int64, before time.Time) (int64, error) {
+	filter := bson.M{"timestamp": bson.M{"$lt": before}}
+	if chatID != 0 {
+		filter["chat_id"] = chatID
+	}
+	res, err := s.db.Collection("messages").DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// MarkMessagesDeleted hides messages of a chat from every query and returns how many it hid
+func (s *Store) MarkMessagesDeleted(chatID int64, ids []int) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := s.db.Collection("messages").UpdateMany(ctx,
+		bson.M{"chat_id": chatID, "message_id": bson.M{"$in": ids}, "deleted_at": nil},
+		bson.M{"$set": bson.M{"deleted_at": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// MessagesToReconcile returns up to limit messages posted since the given time, those never checked and then
+// those checked longest ago first
+func (s *Store) MessagesToReconcile(since time.Time, limit int) ([]Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "reconciled_at", Value: 1}}).SetLimit(int64(limit)).
+		SetProjection(bson.M{"chat_id": 1, "message_id": 1})
+	cursor, err := s.db.Collection("messages").Find(ctx, visible(bson.M{"timestamp": bson.M{"$gte": since}}), opts)
+	if err != nil {
+		return nil, err
+	}
+	var messages []Message
+	err = cursor.All(ctx, &messages)
+	return messages, err
+}
+
+// MarkReconciled records that the given messages still existed in Telegram
+func (s *Store) MarkReconciled(messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(messages))
+	for _, m := range messages {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"chat_id": m.ChatID, "message_id": m.MessageID}).
+			SetUpdate(bson.M{"$set": bson.M{"reconciled_at": now}}))
+	}
+	_, err := s.db.Collection("messages").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
@@ -0,0 +1,255 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is one versioned change to the database, like an index change, a field rename or a backfill.
+// Up must be safe to run again if it was interrupted: it is only recorded as applied once it returns nil.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, s *Store) error
+}
+
+// Migrations are applied in order by Migrate. Add new ones at the end with the next version; never change
+// or reorder a released one, since deployments that applied it won't run it again.
+var Migrations = []Migration{
+	{Version: 1, Description: "create the indexes of messages, updates, the AI log, knowledge bases and embeddings",
+		Up: func(ctx context.Context, s *Store) error {
+			for _, ensure := range []func() error{
+				s.EnsureMessageIndexes,
+				s.EnsureUpdateIndexes,
+				s.EnsureAILogIndexes,
+				s.EnsureKnowledgeIndexes,
+				s.EnsureEmbeddingIndexes,
+			} {
+				if err := ensure(); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+	{Version: 2, Description: "index messages by when they were last checked for deletion",
+		Up: func(ctx context.Context, s *Store) error {
+			_, err := s.db.Collection("messages").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "reconciled_at", Value: 1}},
+			})
+			return err
+		}},
+	{Version: 3, Description: "index messages by the message they reply to, for reply threads",
+		Up: func(ctx context.Context, s *Store) error {
+			_, err := s.db.Collection("messages").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "reply_to", Value: 1}},
+			})
+			return err
+		}},
+	{Version: 4, Description: "index the chats registry by last activity, for /chats and auto-leave",
+		Up: func(ctx context.Context, s *Store) error {
+			_, err := s.db.Collection("chats").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "last_activity_at", Value: 1}},
+			})
+			return err
+		}},
+	{Version: 5, Description: "index the usage of model requests by chat and expire it after UsageRetention",
+		Up: func(ctx context.Context, s *Store) error {
+			_, err := s.db.Collection("usage").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "created_at", Value: -1}}},
+				{Keys: bson.D{{Key: "created_at", Value: 1}},
+					Options: options.Index().SetExpireAfterSeconds(int32(UsageRetention.Seconds()))},
+			})
+			return err
+		}},
+	{Version: 6, Description: "expire the remainders of truncated messages after RemainderRetention",
+		Up: func(ctx context.Context, s *Store) error {
+			_, err := s.db.Collection("remainders").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "created_at", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(int32(RemainderRetention.Seconds())),
+			})
+			return err
+		}},
+	{Version: 7, Description: "index messages by chat and message ID, for imports from Telegram exports",
+		Up: func(ctx context.Context, s *Store) error {
+			_, err := s.db.Collection("messages").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "message_id", Value: 1}},
+			})
+			return err
+		}},
+	{Version: 8, Description: "index pinned messages by chat and message ID",
+		Up: func(ctx context.Context, s *Store) error {
+			_, err := s.db.Collection("pins").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "chat_id", Value: 1}, {Key: "message_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})
+			return err
+		}},
+	{Version: 9, Description: "index chat glossaries by term",
+		Up: func(ctx context.Context, s *Store) error {
+			_, err := s.db.Collection("glossary").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "chat_id", Value: 1}, {Key: "key", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})
+			return err
+		}},
+	{Version: 10, Description: "index member nicknames by chat and username",
+		Up: func(ctx context.Context, s *Store) error {
+			_, err := s.db.Collection("nicknames").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "chat_id", Value: 1}, {Key: "username", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})
+			return err
+		}},
+	{Version: 11, Description: "index the parts of multi-part answers by message and expire them after AnswerPartsRetention",
+		Up: func(ctx context.Context, s *Store) error {
+			_, err := s.db.Collection("answer_parts").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "message_id", Value: 1}}},
+				{
+					Keys:    bson.D{{Key: "created_at", Value: 1}},
+					Options: options.Index().SetExpireAfterSeconds(int32(AnswerPartsRetention.Seconds())),
+				},
+			})
+			return err
+		}},
+	{Version: 12, Description: "remove messages stored twice by the bots of a fleet and index messages uniquely by chat and message ID",
+		Up: func(ctx context.Context, s *Store) error {
+			messages := s.db.Collection("messages")
+			cursor, err := messages.Aggregate(ctx, mongo.Pipeline{
+				{{Key: "$group", Value: bson.M{
+					"_id":   bson.M{"chat_id": "$chat_id", "message_id": "$message_id"},
+					"ids":   bson.M{"$push": "$_id"},
+					"count": bson.M{"$sum": 1},
+				}}},
+				{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+			}, options.Aggregate().SetAllowDiskUse(true))
+			if err != nil {
+				return err
+			}
+			defer cursor.Close(ctx)
+			for cursor.Next(ctx) {
+				var duplicate struct {
+					IDs []any `bson:"ids"`
+				}
+				if err := cursor.Decode(&duplicate); err != nil {
+					return err
+				}
+				if _, err := messages.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": duplicate.IDs[1:]}}); err != nil {
+					return err
+				}
+			}
+			if err := cursor.Err(); err != nil {
+				return err
+			}
+
+			// Migration 7 created the same index without the unique option
+			var cmdErr mongo.CommandError
+			if _, err := messages.Indexes().DropOne(ctx, "chat_id_1_message_id_1"); err != nil &&
+				!(errors.As(err, &cmdErr) && cmdErr.Code == indexNotFoundCode) {
+				return err
+			}
+			_, err = messages.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "chat_id", Value: 1}, {Key: "message_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})
+			return err
+		}},
+}
+
+// indexNotFoundCode is the MongoDB error code of dropping an index that doesn't exist
+const indexNotFoundCode = 27
+
+// migrationLockTTL is how long an instance may hold the migration lock before others assume it crashed
+const migrationLockTTL = 10 * time.Minute
+
+// appliedMigration is a document of the schema_version collection
+type appliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// SchemaVersion returns the highest migration version applied to the database, 0 for a new database
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	var latest appliedMigration
+	opts := options.FindOne().SetSort(bson.M{"_id": -1})
+	err := s.db.Collection("schema_version").FindOne(ctx, bson.M{}, opts).Decode(&latest)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return latest.Version, err
+}
+
+// Migrate applies the migrations newer than the database's schema version, in order, and returns those it
+// applied. Instances starting together take turns through a lock, so each migration runs once.
+func (s *Store) Migrate(ctx context.Context, migrations []Migration) ([]Migration, error) {
+	if err := s.lockMigrations(ctx); err != nil {
+		return nil, err
+	}
+	defer s.unlockMigrations()
+
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading the schema version: %w", err)
+	}
+	if n := len(migrations); n > 0 && current > migrations[n-1].Version {
+		log.Printf("Database schema version %d is newer than this build knows (%d), was ChatBuddy downgraded?",
+			current, migrations[n-1].Version)
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := m.Up(ctx, s); err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		_, err := s.db.Collection("schema_version").InsertOne(ctx, appliedMigration{
+			Version:     m.Version,
+			Description: m.Description,
+			AppliedAt:   time.Now(),
+		})
+		if err != nil {
+			return applied, fmt.Errorf("recording migration %d: %w", m.Version, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// lockMigrations waits until this instance holds the migration lock or ctx is done
+func (s *Store) lockMigrations(ctx context.Context) error {
+	for {
+		now := time.Now()
+		_, err := s.db.Collection("schema_lock").UpdateOne(ctx,
+			bson.M{"_id": "migrations", "locked_until": bson.M{"$lt": now}},
+			bson.M{"$set": bson.M{"locked_until": now.Add(migrationLockTTL)}},
+			options.Update().SetUpsert(true))
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+
+		// Another instance is migrating
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for another instance to finish migrating: %w", ctx.Err())
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func (s *Store) unlockMigrations() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := s.db.Collection("schema_lock").DeleteOne(ctx, bson.M{"_id": "migrations"}); err != nil {
+		log.Printf("Error releasing the migration lock: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Nickname is the name a chat calls one of its members by, like "Mili" for @sg_milad
+type Nickname struct {
+	ChatID int64 `bson:"chat_id"`
+	// Username is the member's Telegram username without "@", lowercase
+	Username string `bson:"username"`
+	Nickname string `bson:"nickname"`
+	// AddedBy is the admin who set the nickname, 0 for nicknames learned from the chat history
+	AddedBy   int64     `bson:"added_by,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// nicknameKey is the case-insensitive identity of a username
+func nicknameKey(username string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(username), "@"))
+}
+
+// Nicknames returns a chat's nicknames, ordered by username
+func (s *Store) Nicknames(chatID int64) ([]Nickname, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "username", Value: 1}})
+	cursor, err := s.db.Collection("nicknames").Find(ctx, bson.M{"chat_id": chatID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var nicknames []Nickname
+	err = cursor.All(ctx, &nicknames)
+	return nicknames, err
+}
+
+// SetNickname sets the nickname of a chat member, replacing a learned or earlier one
+func (s *Store) SetNickname(n Nickname) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("nicknames").UpdateOne(ctx,
+		bson.M{"chat_id": n.ChatID, "username": nicknameKey(n.Username)},
+		bson.M{"$set": bson.M{"nickname": n.Nickname, "added_by": n.AddedBy, "updated_at": time.Now()}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// AddNicknames adds learned nicknames of a chat, keeping those of members that already have one, and returns
+// how many were new
+func (s *Store) AddNicknames(chatID int64, nicknames []Nickname) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	added := 0
+	for _, n := range nicknames {
+		res, err := s.db.Collection("nicknames").UpdateOne(ctx,
+			bson.M{"chat_id": chatID, "username": nicknameKey(n.Username)},
+			bson.M{"$setOnInsert": bson.M{"nickname": n.Nickname, "updated_at": time.Now()}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return added, err
+		}
+		if res.UpsertedCount > 0 {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// RemoveNickname removes the nickname of a chat member and reports whether there was one
+func (s *Store) RemoveNickname(chatID int64, username string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := s.db.Collection("nicknames").DeleteOne(ctx, bson.M{"chat_id": chatID, "username": nicknameKey(username)})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
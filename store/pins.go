@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Pin is a message pinned in a chat, with the one-line summary /pins shows for it
+type Pin struct {
+	ChatID    int64     `bson:"chat_id"`
+	MessageID int       `bson:"message_id"`
+	Author    string    `bson:"author"`
+	Text      string    `bson:"text"`
+	PinnedBy  int64     `bson:"pinned_by,omitempty"`
+	PinnedAt  time.Time `bson:"pinned_at"`
+	// Summary is generated when the pins are first listed
+	Summary string `bson:"summary,omitempty"`
+}
+
+// SavePin records a pinned message, replacing an earlier record of it and its summary
+func (s *Store) SavePin(p Pin) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("pins").ReplaceOne(ctx, bson.M{"chat_id": p.ChatID, "message_id": p.MessageID}, p,
+		options.Replace().SetUpsert(true))
+	return err
+}
+
+// RemovePin forgets a message that was unpinned
+func (s *Store) RemovePin(chatID int64, messageID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("pins").DeleteOne(ctx, bson.M{"chat_id": chatID, "message_id": messageID})
+	return err
+}
+
+// ClearPins forgets every pinned message of a chat
+func (s *Store) ClearPins(chatID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("pins").DeleteMany(ctx, bson.M{"chat_id": chatID})
+	return err
+}
+
+// Pins returns up to limit pinned messages of a chat, the most recently pinned first
+func (s *Store) Pins(chatID int64, limit int) ([]Pin, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "pinned_at", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := s.db.Collection("pins").Find(ctx, bson.M{"chat_id": chatID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var pins []Pin
+	err = cursor.All(ctx, &pins)
+	return pins, err
+}
+
+// SetPinSummary saves the one-line summary of a pinned message
+func (s *Store) SetPinSummary(chatID int64, messageID int, summary string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("pins").UpdateOne(ctx, bson.M{"chat_id": chatID, "message_id": messageID},
+		bson.M{"$set": bson.M{"summary": summary}})
+	return err
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RecordReferral saves a referral of a "user" or "group", returning false if that user or group was referred before
+func (s *Store) RecordReferral(kind string, referredID, referrerID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	doc := bson.M{
+		"_id":         fmt.Sprintf("%s:%d", kind, referredID),
+		"kind":        kind,
+		"referred_id": referredID,
+		"referrer_id": referrerID,
+		"created_at":  time.Now(),
+	}
+	_, err := s.db.Collection("referrals").InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReferralCounts returns how many users and groups a user referred
+func (s *Store) ReferralCounts(referrerID int64) (users, groups int64, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := s.db.Collection("referrals")
+	if users, err = collection.CountDocuments(ctx, bson.M{"referrer_id": referrerID, "kind": "user"}); err != nil {
+		return 0, 0, err
+	}
+	groups, err = collection.CountDocuments(ctx, bson.M{"referrer_id": referrerID, "kind": "group"})
+	return users, groups, err
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RemainderRetention is how long the unsent end of a truncated message can be asked for
+const RemainderRetention = 7 * 24 * time.Hour
+
+// Remainder is the end of a message that couldn't be sent to a chat, delivered in a DM on request
+type Remainder struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	ChatID    int64              `bson:"chat_id"`
+	ParseMode string             `bson:"parse_mode,omitempty"`
+	Text      string             `bson:"text"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// SaveRemainder stores the unsent end of a message, sent with parseMode, and returns its id
+func (s *Store) SaveRemainder(chatID int64, parseMode, text string) (primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r := Remainder{ID: primitive.NewObjectID(), ChatID: chatID, ParseMode: parseMode, Text: text, CreatedAt: time.Now()}
+	_, err := s.db.Collection("remainders").InsertOne(ctx, r)
+	return r.ID, err
+}
+
+// Remainder returns a stored message remainder, or mongo.ErrNoDocuments once it expired
+func (s *Store) Remainder(id primitive.ObjectID) (Remainder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var r Remainder
+	err := s.db.Collection("remainders").FindOne(ctx, bson.M{"_id": id}).Decode(&r)
+	return r, err
+}
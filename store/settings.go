@@ -0,0 +1,330 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// settingsCacheTTL bounds how stale cached settings can get if an invalidation is lost
+const settingsCacheTTL = 5 * time.Minute
+
+// ChatSettings holds per-chat configuration stored in MongoDB
+type ChatSettings struct {
+	ChatID            int64  `bson:"chat_id"`
+	Rules             string `bson:"rules,omitempty"`
+	ModerationEnabled bool   `bson:"moderation_enabled"`
+	// EventDetectionDisabled turns off offering to save plans mentioned in the chat
+	EventDetectionDisabled bool `bson:"event_detection_disabled"`
+	// Timezone is an IANA zone name like "Europe/Berlin"
+	Timezone string `bson:"timezone,omitempty"`
+	// FeedDigestHour is the local hour of the daily feed digest; nil means the bot default
+	FeedDigestHour *int `bson:"feed_digest_hour,omitempty"`
+	// AliasMode is when the bot answers to its alias names: "" at the start of a message, "anywhere" or "off"
+	AliasMode string `bson:"alias_mode,omitempty"`
+	// AIRepliesPerMinute is the chat's cooldown on AI replies; nil means the bot default, 0 no limit
+	AIRepliesPerMinute *int `bson:"ai_replies_per_minute,omitempty"`
+	// BannedWords are removed or warned about, depending on BannedWordAction ("" deletes, "warn" warns)
+	BannedWords      []string `bson:"banned_words,omitempty"`
+	BannedWordAction string   `bson:"banned_word_action,omitempty"`
+	// LinkPolicy is "" for all links, "block" or "allowlist" for AllowedDomains only; BlockInvites removes invite links either way
+	LinkPolicy     string   `bson:"link_policy,omitempty"`
+	AllowedDomains []string `bson:"allowed_domains,omitempty"`
+	BlockInvites   bool     `bson:"block_invites"`
+	// SpamAction is what happens to repeated and cross-posted messages: "" nothing, "flag" or "delete"
+	SpamAction string `bson:"spam_action,omitempty"`
+	// QuietHours like "23:00-07:00" hold back posts nobody asked for, in the chat timezone
+	QuietHours string `bson:"quiet_hours,omitempty"`
+	// SendOptions are keyed by message kind: "answers", "digests" or "notifications"
+	SendOptions map[string]SendOptions `bson:"send_options,omitempty"`
+	// PinSummaries and PinDigests pin /summary results and scheduled digests; BotPinnedMessageID is the
+	// bot's latest pin, unpinned when it pins the next one
+	PinSummaries       bool `bson:"pin_summaries"`
+	PinDigests         bool `bson:"pin_digests"`
+	BotPinnedMessageID int  `bson:"bot_pinned_message_id,omitempty"`
+	// AILogRetentionDays is how long prompts and responses are kept for /history; nil means the bot default, 0 no log
+	AILogRetentionDays *int `bson:"ai_log_retention_days,omitempty"`
+	// AutoTagging tags new messages with AI-derived hashtags for /find, starting at AutoTaggingSince
+	AutoTagging      bool      `bson:"auto_tagging"`
+	AutoTaggingSince time.Time `bson:"auto_tagging_since,omitempty"`
+	// WeeklyWrapped posts the weekly recap of stats, highlights and topics
+	WeeklyWrapped bool `bson:"weekly_wrapped"`
+	// AnswerBots lets the bot answer other bots and inline results sent via bots
+	AnswerBots bool `bson:"answer_bots"`
+	// WebSearch lets the model search the web while answering, listing the sources it used
+	WebSearch bool `bson:"web_search"`
+	// Profile is "family" for kid-friendly chats, with the strictest safety settings and filtered answers;
+	// "" is the default
+	Profile string `bson:"profile,omitempty"`
+	// Grounded answers only from the chat's history and knowledge base, saying so when they don't have the answer
+	Grounded bool `bson:"grounded"`
+	// SummaryLanguage is how /summary handles chats in several languages: "" in the main one, "split" one
+	// summary per language, or a language code to always summarize in
+	SummaryLanguage string `bson:"summary_language,omitempty"`
+	// ContextMessages is how many of the messages before a question answers get as context; 0 is none
+	ContextMessages int `bson:"context_messages,omitempty"`
+	// DisabledCommands are the commands admins turned off in the chat with /disable
+	DisabledCommands []string `bson:"disabled_commands,omitempty"`
+	// Style is the answer style picked with /style, like "eli5"; "" is the default
+	Style string `bson:"style,omitempty"`
+	// History is the admins' answer to the privacy notice: "on" keeps messages for history features, "off" runs
+	// stateless and "pending" stores nothing until they answer; "" is a chat from before the notice and keeps them
+	History          string    `bson:"history,omitempty"`
+	HistoryDecidedBy int64     `bson:"history_decided_by,omitempty"`
+	UpdatedAt        time.Time `bson:"updated_at"`
+}
+
+// SendOptions are a chat's Telegram send flags for one kind of bot message; nil fields use the bot defaults
+type SendOptions struct {
+	Silent   *bool `bson:"silent,omitempty"`
+	Previews *bool `bson:"previews,omitempty"`
+}
+
+// ChatSettings returns the settings of a chat, or defaults if none were saved
+func (s *Store) ChatSettings(chatID int64) ChatSettings {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	settings := ChatSettings{ChatID: chatID}
+	if s.cache != nil {
+		if data, err := s.cache.Get(ctx, settingsCacheKey(chatID)); err == nil && bson.Unmarshal(data, &settings) == nil {
+			return settings
+		}
+	}
+
+	err := s.db.Collection("chat_settings").FindOne(ctx, bson.M{"chat_id": chatID}).Decode(&settings)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		log.Printf("Error loading settings for chat %d: %v", chatID, err)
+		return settings
+	}
+	if s.cache != nil {
+		if data, err := bson.Marshal(settings); err == nil {
+			if err := s.cache.Set(ctx, settingsCacheKey(chatID), data, settingsCacheTTL); err != nil {
+				log.Printf("Error caching settings for chat %d: %v", chatID, err)
+			}
+		}
+	}
+	return settings
+}
+
+func settingsCacheKey(chatID int64) string {
+	return fmt.Sprintf("chat_settings:%d", chatID)
+}
+
+// forgetChatSettings drops the cached copy after a change, so every instance reloads it
+func (s *Store) forgetChatSettings(ctx context.Context, chatID int64) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.Delete(ctx, settingsCacheKey(chatID)); err != nil {
+		log.Printf("Error clearing cached settings for chat %d: %v", chatID, err)
+	}
+}
+
+// FindChatSettings returns the settings of the chats matching filter, like {"auto_tagging": true}
+func (s *Store) FindChatSettings(filter bson.M) ([]ChatSettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.Collection("chat_settings").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var chats []ChatSettings
+	err = cursor.All(ctx, &chats)
+	return chats, err
+}
+
+// UpdateChatSettings applies the given field changes to a chat's settings, creating them if needed
+func (s *Store) UpdateChatSettings(chatID int64, changes bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	changes["updated_at"] = time.Now()
+	_, err := s.db.Collection("chat_settings").UpdateOne(
+		ctx,
+		bson.M{"chat_id": chatID},
+		bson.M{"$set": changes},
+		options.Update().SetUpsert(true),
+	)
+	s.forgetChatSettings(ctx, chatID)
+	return err
+}
+
+// ChatsWithDisabledCommands returns the settings of the chats whose admins turned commands off
+func (s *Store) ChatsWithDisabledCommands() ([]ChatSettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.Collection("chat_settings").Find(ctx, bson.M{"disabled_commands.0": bson.M{"$exists": true}})
+	if err != nil {
+		return nil, err
+	}
+	var settings []ChatSettings
+	err = cursor.All(ctx, &settings)
+	return settings, err
+}
+
+// AddToChatList adds values to a list field of a chat's settings, skipping values already in it
+func (s *Store) AddToChatList(chatID int64, field string, values ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("chat_settings").UpdateOne(
+		ctx,
+		bson.M{"chat_id": chatID},
+		bson.M{
+			"$addToSet": bson.M{field: bson.M{"$each": values}},
+			"$set":      bson.M{"updated_at": time.Now()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	s.forgetChatSettings(ctx, chatID)
+	return err
+}
+
+// RemoveFromChatList removes values from a list field of a chat's settings
+func (s *Store) RemoveFromChatList(chatID int64, field string, values ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("chat_settings").UpdateOne(
+		ctx,
+		bson.M{"chat_id": chatID},
+		bson.M{
+			"$pullAll": bson.M{field: values},
+			"$set":     bson.M{"updated_at": time.Now()},
+		},
+	)
+	s.forgetChatSettings(ctx, chatID)
+	return err
+}
+
+// UserPreferences holds per-user configuration stored in MongoDB
+type UserPreferences struct {
+	UserID   int64  `bson:"user_id"`
+	Timezone string `bson:"timezone,omitempty"`
+	// Language is the code of the language picked during onboarding; "" matches the user's messages
+	Language string `bson:"language,omitempty"`
+	// OnboardingStep is the DM wizard step the user is on, "" when not onboarding;
+	// SourceChatID is the group whose deep link started it
+	OnboardingStep    string     `bson:"onboarding_step,omitempty"`
+	SourceChatID      int64      `bson:"source_chat_id,omitempty"`
+	PrivacyAcceptedAt *time.Time `bson:"privacy_accepted_at,omitempty"`
+	OnboardedAt       *time.Time `bson:"onboarded_at,omitempty"`
+	// ReferredBy is the user whose referral link brought this user to the bot
+	ReferredBy int64 `bson:"referred_by,omitempty"`
+	// DigestChats are the groups whose scheduled digests are also sent to the user
+	DigestChats []int64 `bson:"digest_chats,omitempty"`
+	// ProfileOptOut keeps the user out of /whois; Username is saved with it to match messages stored without a sender ID
+	ProfileOptOut bool   `bson:"profile_opt_out,omitempty"`
+	Username      string `bson:"username,omitempty"`
+	// KnowledgeChatID is the group whose knowledge base the user is adding to in the DM, 0 when not
+	KnowledgeChatID int64 `bson:"knowledge_chat_id,omitempty"`
+	// DNDHours like "22:00-08:00" hold back the bot's DMs during these hours, in the user's timezone
+	DNDHours string `bson:"dnd_hours,omitempty"`
+	// MutedDMs are the kinds of DMs the user turned off with /notify, like "digests"
+	MutedDMs []string `bson:"muted_dms,omitempty"`
+	// WeatherPlace and its coordinates are the last place the user asked the weather for or shared
+	WeatherPlace     string    `bson:"weather_place,omitempty"`
+	WeatherLatitude  float64   `bson:"weather_latitude,omitempty"`
+	WeatherLongitude float64   `bson:"weather_longitude,omitempty"`
+	UpdatedAt        time.Time `bson:"updated_at"`
+}
+
+// UserPreferences returns the preferences of a user, or defaults if none were saved
+func (s *Store) UserPreferences(userID int64) UserPreferences {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	prefs := UserPreferences{UserID: userID}
+	err := s.db.Collection("user_preferences").FindOne(ctx, bson.M{"user_id": userID}).Decode(&prefs)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		log.Printf("Error loading preferences for user %d: %v", userID, err)
+	}
+	return prefs
+}
+
+// UpdateUserPreferences applies the given field changes to a user's preferences, creating them if needed
+func (s *Store) UpdateUserPreferences(userID int64, changes bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	changes["updated_at"] = time.Now()
+	_, err := s.db.Collection("user_preferences").UpdateOne(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": changes},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// SubscribeToDigests adds a group to the digests sent to a user
+func (s *Store) SubscribeToDigests(userID, chatID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("user_preferences").UpdateOne(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{
+			"$addToSet": bson.M{"digest_chats": chatID},
+			"$set":      bson.M{"updated_at": time.Now()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// DigestSubscribers returns the users who get a copy of a group's digests
+func (s *Store) DigestSubscribers(chatID int64) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.Collection("user_preferences").Find(ctx, bson.M{"digest_chats": chatID})
+	if err != nil {
+		return nil, err
+	}
+	var prefs []UserPreferences
+	if err := cursor.All(ctx, &prefs); err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]int64, 0, len(prefs))
+	for _, p := range prefs {
+		userIDs = append(userIDs, p.UserID)
+	}
+	return userIDs, nil
+}
+
+// ProfileOptedOut reports whether a user asked to be left out of /whois, matched by ID or by lowercase username
+func (s *Store) ProfileOptedOut(userID int64, username string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	match := bson.A{}
+	if userID != 0 {
+		match = append(match, bson.M{"user_id": userID})
+	}
+	if username != "" {
+		match = append(match, bson.M{"username": strings.ToLower(username)})
+	}
+	if len(match) == 0 {
+		return false
+	}
+	n, err := s.db.Collection("user_preferences").CountDocuments(ctx, bson.M{"profile_opt_out": true, "$or": match})
+	if err != nil {
+		// Err on the side of privacy
+		log.Printf("Error checking the profile opt-out of user %d (%s): %v", userID, username, err)
+		return true
+	}
+	return n > 0
+}
@@ -0,0 +1,78 @@
+// Package store persists ChatBuddy's messages and settings in MongoDB.
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultDatabase is the database used when none is given
+const DefaultDatabase = "telegram_bot"
+
+// Store gives access to the bot's MongoDB database
+type Store struct {
+	client *mongo.Client
+	db     *mongo.Database
+	cache  Cache
+}
+
+// Cache keeps copies of hot documents like chat settings, shared between bot instances; any Get error is a miss
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// SetCache makes the store cache chat settings in c
+func (s *Store) SetCache(c Cache) {
+	s.cache = c
+}
+
+// Connect opens a MongoDB connection and verifies it with a ping; an empty database name means DefaultDatabase
+func Connect(uri, database string) (*Store, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, err
+	}
+
+	// Ping the database to verify connection
+	if err := client.Ping(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	log.Println("Connected to MongoDB successfully")
+	if database == "" {
+		database = DefaultDatabase
+	}
+	return &Store{client: client, db: client.Database(database)}, nil
+}
+
+// New wraps a database whose connection is managed by the caller
+func New(db *mongo.Database) *Store {
+	return &Store{db: db}
+}
+
+// DB returns the underlying database for feature-specific collections
+func (s *Store) DB() *mongo.Database {
+	return s.db
+}
+
+// Close disconnects from MongoDB if the connection was opened by Connect
+func (s *Store) Close() {
+	if s.client == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.client.Disconnect(ctx); err != nil {
+		log.Printf("error disconnecting from MongoDB: %v", err)
+	}
+}
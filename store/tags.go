@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TagCount is how many messages carry a tag
+type TagCount struct {
+	Tag   string `bson:"_id"`
+	Count int    `bson:"count"`
+}
+
+// UntaggedMessages returns up to limit messages of a chat since the given time that weren't tagged yet, oldest first
+func (s *Store) UntaggedMessages(chatID int64, since time.Time, limit int) ([]Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := s.db.Collection("messages").Find(ctx, visible(bson.M{
+		"chat_id":   chatID,
+		"timestamp": bson.M{"$gte": since},
+		"text":      bson.M{"$ne": ""},
+		"tags":      bson.M{"$exists": false},
+	}), opts)
+	if err != nil {
+		return nil, err
+	}
+	var messages []Message
+	err = cursor.All(ctx, &messages)
+	return messages, err
+}
+
+// SetMessageTags stores the tags of messages by message ID; an empty list marks a message as tagged without tags
+func (s *Store) SetMessageTags(chatID int64, tags map[int][]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	writes := make([]mongo.WriteModel, 0, len(tags))
+	for messageID, t := range tags {
+		if t == nil {
+			t = []string{}
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"chat_id": chatID, "message_id": messageID}).
+			SetUpdate(bson.M{"$set": bson.M{"tags": t}}))
+	}
+	_, err := s.db.Collection("messages").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// FindTagged returns up to limit messages of a chat carrying all the given tags, newest first
+func (s *Store) FindTagged(chatID int64, tags []string, limit int) ([]Message, error) {
+	return s.FindMessages(bson.M{"chat_id": chatID, "tags": bson.M{"$all": tags}}, limit)
+}
+
+// TagCounts returns the most used tags of a chat since the given time
+func (s *Store) TagCounts(chatID int64, since time.Time, limit int) ([]TagCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.Collection("messages").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: visible(bson.M{"chat_id": chatID, "timestamp": bson.M{"$gte": since}, "tags.0": bson.M{"$exists": true}})}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var counts []TagCount
+	err = cursor.All(ctx, &counts)
+	return counts, err
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Topic is a cluster of similar recent messages in a chat
+type Topic struct {
+	Label string `bson:"label"`
+	Count int    `bson:"count"`
+	// MessageIDs are a few of the topic's messages, newest first, for jump links
+	MessageIDs []int     `bson:"message_ids"`
+	LatestAt   time.Time `bson:"latest_at"`
+}
+
+// ChatTopics are the topics last computed for a chat, biggest first
+type ChatTopics struct {
+	ChatID     int64     `bson:"chat_id"`
+	Topics     []Topic   `bson:"topics"`
+	Messages   int       `bson:"messages"`
+	ComputedAt time.Time `bson:"computed_at"`
+}
+
+// SaveChatTopics replaces the stored topics of a chat
+func (s *Store) SaveChatTopics(topics ChatTopics) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("chat_topics").ReplaceOne(ctx, bson.M{"chat_id": topics.ChatID}, topics, options.Replace().SetUpsert(true))
+	return err
+}
+
+// LoadChatTopics returns the stored topics of a chat; ok is false if none were computed yet
+func (s *Store) LoadChatTopics(chatID int64) (topics ChatTopics, ok bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = s.db.Collection("chat_topics").FindOne(ctx, bson.M{"chat_id": chatID}).Decode(&topics)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return topics, false, nil
+	}
+	return topics, err == nil, err
+}
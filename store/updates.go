@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// handledUpdateTTL is how long handled update IDs are remembered; Telegram keeps
+// unconfirmed updates for 24 hours, so redeliveries can't come later than that
+const handledUpdateTTL = 48 * time.Hour
+
+// EnsureUpdateIndexes creates the index that expires old handled update IDs
+func (s *Store) EnsureUpdateIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("handled_updates").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "handled_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(handledUpdateTTL.Seconds())),
+	})
+	return err
+}
+
+// ClaimUpdate records that an update of the bot botID is being handled, returning false if it already was,
+// e.g. when Telegram redelivers updates after a restart or reconnect. Update IDs count per bot, so the bots
+// of a fleet claim theirs separately.
+func (s *Store) ClaimUpdate(botID int64, updateID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id := bson.D{{Key: "bot_id", Value: botID}, {Key: "update_id", Value: updateID}}
+	_, err := s.db.Collection("handled_updates").InsertOne(ctx, bson.M{"_id": id, "handled_at": time.Now()})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UsageRetention is how long the usage of model calls is kept
+const UsageRetention = 400 * 24 * time.Hour
+
+// UsageRecord is the token counts and latency of one model request
+type UsageRecord struct {
+	// ChatID and UserID are who the request was made for, 0 for scheduled jobs
+	ChatID       int64  `bson:"chat_id,omitempty"`
+	UserID       int64  `bson:"user_id,omitempty"`
+	Model        string `bson:"model"`
+	PromptTokens int64  `bson:"prompt_tokens"`
+	OutputTokens int64  `bson:"output_tokens"`
+	// CachedTokens are the prompt tokens read from a context cache
+	CachedTokens int64     `bson:"cached_tokens,omitempty"`
+	LatencyMS    int64     `bson:"latency_ms"`
+	Failed       bool      `bson:"failed,omitempty"`
+	CreatedAt    time.Time `bson:"created_at"`
+}
+
+// UsageTotals sums up the usage records of one model, or of one chat
+type UsageTotals struct {
+	Model        string `bson:"model,omitempty"`
+	ChatID       int64  `bson:"chat_id,omitempty"`
+	Requests     int64  `bson:"requests"`
+	Failures     int64  `bson:"failures"`
+	PromptTokens int64  `bson:"prompt_tokens"`
+	OutputTokens int64  `bson:"output_tokens"`
+	CachedTokens int64  `bson:"cached_tokens"`
+	LatencyMS    int64  `bson:"latency_ms"`
+	MaxLatencyMS int64  `bson:"max_latency_ms"`
+}
+
+// Tokens returns the prompt and output tokens together
+func (t UsageTotals) Tokens() int64 {
+	return t.PromptTokens + t.OutputTokens
+}
+
+// AverageLatency is the mean latency of the requests
+func (t UsageTotals) AverageLatency() time.Duration {
+	if t.Requests == 0 {
+		return 0
+	}
+	return time.Duration(t.LatencyMS/t.Requests) * time.Millisecond
+}
+
+// InsertUsage records the usage of a model request
+func (s *Store) InsertUsage(r UsageRecord) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("usage").InsertOne(ctx, r)
+	return err
+}
+
+// UsageByModel sums up the usage in [from, to) per model, most tokens first, for the given chats or, with
+// none, for all of them
+func (s *Store) UsageByModel(from, to time.Time, chatIDs ...int64) ([]UsageTotals, error) {
+	return s.usageTotals(from, to, chatIDs, bson.M{"model": "$model"})
+}
+
+// UsageByChatAndModel sums up the usage in [from, to) per chat and model, most tokens first; the chats of
+// different models are priced apart
+func (s *Store) UsageByChatAndModel(from, to time.Time) ([]UsageTotals, error) {
+	return s.usageTotals(from, to, nil, bson.M{"chat_id": "$chat_id", "model": "$model"})
+}
+
+func (s *Store) usageTotals(from, to time.Time, chatIDs []int64, groupBy bson.M) ([]UsageTotals, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	match := bson.M{"created_at": bson.M{"$gte": from, "$lt": to}}
+	if len(chatIDs) > 0 {
+		match["chat_id"] = bson.M{"$in": chatIDs}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":            groupBy,
+			"requests":       bson.M{"$sum": 1},
+			"failures":       bson.M{"$sum": bson.M{"$cond": bson.A{"$failed", 1, 0}}},
+			"prompt_tokens":  bson.M{"$sum": "$prompt_tokens"},
+			"output_tokens":  bson.M{"$sum": "$output_tokens"},
+			"cached_tokens":  bson.M{"$sum": "$cached_tokens"},
+			"latency_ms":     bson.M{"$sum": "$latency_ms"},
+			"max_latency_ms": bson.M{"$max": "$latency_ms"},
+		}}},
+		{{Key: "$replaceWith", Value: bson.M{"$mergeObjects": bson.A{"$_id", "$$ROOT",
+			bson.M{"tokens": bson.M{"$add": bson.A{"$prompt_tokens", "$output_tokens"}}}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "tokens", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+	cursor, err := s.db.Collection("usage").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var totals []UsageTotals
+	err = cursor.All(ctx, &totals)
+	return totals, err
+}
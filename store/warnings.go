@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WarningDecay is how long a user has to keep clean before their warning count starts over
+const WarningDecay = 7 * 24 * time.Hour
+
+// AddWarning counts a warning for a user in a chat, like "links", and returns how many
+// warnings of that kind they got since their last clean WarningDecay period
+func (s *Store) AddWarning(chatID, userID int64, kind string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	// A pipeline update, so the count is reset and incremented in one atomic step
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"count": bson.M{"$cond": bson.A{
+			bson.M{"$lt": bson.A{bson.M{"$ifNull": bson.A{"$updated_at", time.Time{}}}, now.Add(-WarningDecay)}},
+			1,
+			bson.M{"$add": bson.A{"$count", 1}},
+		}},
+		"updated_at": now,
+	}}}}
+
+	var doc struct {
+		Count int `bson:"count"`
+	}
+	err := s.db.Collection("warnings").FindOneAndUpdate(ctx,
+		bson.M{"chat_id": chatID, "user_id": userID, "kind": kind},
+		update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&doc)
+	return doc.Count, err
+}
@@ -0,0 +1,142 @@
+// Package units converts between units of length, mass, volume, area, speed, time, data and temperature.
+package units
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dimension is what a unit measures; only units of the same dimension convert into each other
+type Dimension string
+
+const (
+	Length      Dimension = "length"
+	Mass        Dimension = "mass"
+	Volume      Dimension = "volume"
+	Area        Dimension = "area"
+	Speed       Dimension = "speed"
+	Time        Dimension = "time"
+	Data        Dimension = "data"
+	Temperature Dimension = "temperature"
+)
+
+// Unit is a unit of measurement. A value in the unit is value*Factor + Offset in the dimension's base unit:
+// meters, kilograms, liters, square meters, meters per second, seconds, bytes or kelvin.
+type Unit struct {
+	Name      string
+	Symbol    string
+	Dimension Dimension
+	Factor    float64
+	Offset    float64
+}
+
+var table = []struct {
+	unit    Unit
+	aliases []string
+}{
+	{Unit{"millimeter", "mm", Length, 0.001, 0}, []string{"millimeters", "millimetre", "millimetres"}},
+	{Unit{"centimeter", "cm", Length, 0.01, 0}, []string{"centimeters", "centimetre", "centimetres"}},
+	{Unit{"meter", "m", Length, 1, 0}, []string{"meters", "metre", "metres"}},
+	{Unit{"kilometer", "km", Length, 1000, 0}, []string{"kilometers", "kilometre", "kilometres", "kms"}},
+	{Unit{"inch", "in", Length, 0.0254, 0}, []string{"inches", `"`}},
+	{Unit{"foot", "ft", Length, 0.3048, 0}, []string{"feet", "'"}},
+	{Unit{"yard", "yd", Length, 0.9144, 0}, []string{"yards", "yds"}},
+	{Unit{"mile", "mi", Length, 1609.344, 0}, []string{"miles"}},
+	{Unit{"nautical mile", "nmi", Length, 1852, 0}, []string{"nautical miles"}},
+
+	{Unit{"milligram", "mg", Mass, 1e-6, 0}, []string{"milligrams"}},
+	{Unit{"gram", "g", Mass, 0.001, 0}, []string{"grams", "gr"}},
+	{Unit{"kilogram", "kg", Mass, 1, 0}, []string{"kilograms", "kilo", "kilos", "kgs"}},
+	{Unit{"tonne", "t", Mass, 1000, 0}, []string{"tonnes", "metric ton", "metric tons"}},
+	{Unit{"ounce", "oz", Mass, 0.028349523125, 0}, []string{"ounces"}},
+	{Unit{"pound", "lb", Mass, 0.45359237, 0}, []string{"pounds", "lbs"}},
+	{Unit{"stone", "st", Mass, 6.35029318, 0}, []string{"stones"}},
+
+	{Unit{"milliliter", "ml", Volume, 0.001, 0}, []string{"milliliters", "millilitre", "millilitres"}},
+	{Unit{"liter", "l", Volume, 1, 0}, []string{"liters", "litre", "litres", "ltr"}},
+	{Unit{"cubic meter", "m3", Volume, 1000, 0}, []string{"cubic meters", "m³"}},
+	{Unit{"teaspoon", "tsp", Volume, 0.00492892159375, 0}, []string{"teaspoons"}},
+	{Unit{"tablespoon", "tbsp", Volume, 0.01478676478125, 0}, []string{"tablespoons"}},
+	{Unit{"fluid ounce", "fl oz", Volume, 0.0295735295625, 0}, []string{"fluid ounces", "floz"}},
+	{Unit{"cup", "cup", Volume, 0.2365882365, 0}, []string{"cups"}},
+	{Unit{"pint", "pt", Volume, 0.473176473, 0}, []string{"pints"}},
+	{Unit{"quart", "qt", Volume, 0.946352946, 0}, []string{"quarts"}},
+	{Unit{"gallon", "gal", Volume, 3.785411784, 0}, []string{"gallons"}},
+
+	{Unit{"square meter", "m2", Area, 1, 0}, []string{"square meters", "sqm", "m²"}},
+	{Unit{"square kilometer", "km2", Area, 1e6, 0}, []string{"square kilometers", "km²"}},
+	{Unit{"square foot", "ft2", Area, 0.09290304, 0}, []string{"square feet", "sqft", "ft²"}},
+	{Unit{"square mile", "mi2", Area, 2589988.110336, 0}, []string{"square miles", "mi²"}},
+	{Unit{"hectare", "ha", Area, 10000, 0}, []string{"hectares"}},
+	{Unit{"acre", "ac", Area, 4046.8564224, 0}, []string{"acres"}},
+
+	{Unit{"meter per second", "m/s", Speed, 1, 0}, []string{"meters per second"}},
+	{Unit{"kilometer per hour", "km/h", Speed, 1000.0 / 3600, 0}, []string{"kilometers per hour", "kph", "kmh"}},
+	{Unit{"mile per hour", "mph", Speed, 1609.344 / 3600, 0}, []string{"miles per hour"}},
+	{Unit{"knot", "kn", Speed, 1852.0 / 3600, 0}, []string{"knots", "kt"}},
+
+	{Unit{"millisecond", "ms", Time, 0.001, 0}, []string{"milliseconds"}},
+	{Unit{"second", "s", Time, 1, 0}, []string{"seconds", "sec", "secs"}},
+	{Unit{"minute", "min", Time, 60, 0}, []string{"minutes", "mins"}},
+	{Unit{"hour", "h", Time, 3600, 0}, []string{"hours", "hr", "hrs"}},
+	{Unit{"day", "d", Time, 86400, 0}, []string{"days"}},
+	{Unit{"week", "wk", Time, 604800, 0}, []string{"weeks"}},
+	{Unit{"year", "yr", Time, 31557600, 0}, []string{"years"}},
+
+	{Unit{"byte", "B", Data, 1, 0}, []string{"bytes"}},
+	{Unit{"kilobyte", "KB", Data, 1e3, 0}, []string{"kilobytes"}},
+	{Unit{"megabyte", "MB", Data, 1e6, 0}, []string{"megabytes"}},
+	{Unit{"gigabyte", "GB", Data, 1e9, 0}, []string{"gigabytes"}},
+	{Unit{"terabyte", "TB", Data, 1e12, 0}, []string{"terabytes"}},
+	{Unit{"kibibyte", "KiB", Data, 1 << 10, 0}, []string{"kibibytes"}},
+	{Unit{"mebibyte", "MiB", Data, 1 << 20, 0}, []string{"mebibytes"}},
+	{Unit{"gibibyte", "GiB", Data, 1 << 30, 0}, []string{"gibibytes"}},
+
+	{Unit{"kelvin", "K", Temperature, 1, 0}, []string{"kelvins"}},
+	{Unit{"degree Celsius", "°C", Temperature, 1, 273.15}, []string{"celsius", "c", "degrees celsius", "centigrade"}},
+	{Unit{"degree Fahrenheit", "°F", Temperature, 5.0 / 9, 273.15 - 32*5.0/9}, []string{"fahrenheit", "f", "degrees fahrenheit"}},
+}
+
+// byName indexes units by lowercase name, symbol and alias; data symbols are case sensitive, so they're
+// indexed as written too
+var byName = func() map[string]Unit {
+	m := make(map[string]Unit)
+	for _, entry := range table {
+		u := entry.unit
+		for _, name := range append([]string{u.Name, u.Symbol}, entry.aliases...) {
+			m[strings.ToLower(name)] = u
+		}
+		if u.Dimension == Data {
+			m[u.Symbol] = u
+		}
+	}
+	// "b" is a bit elsewhere, keep it off to avoid a confusion by a factor of 8
+	delete(m, "b")
+	return m
+}()
+
+// Lookup finds a unit by name, symbol or alias, like "km", "miles" or "°F"
+func Lookup(name string) (Unit, bool) {
+	name = strings.TrimSpace(name)
+	if u, ok := byName[name]; ok {
+		return u, true
+	}
+	u, ok := byName[strings.ToLower(name)]
+	return u, ok
+}
+
+// Convert converts value between two units of the same dimension
+func Convert(value float64, from, to string) (float64, error) {
+	f, ok := Lookup(from)
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", from)
+	}
+	t, ok := Lookup(to)
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", to)
+	}
+	if f.Dimension != t.Dimension {
+		return 0, fmt.Errorf("can't convert %s (%s) to %s (%s)", f.Name, f.Dimension, t.Name, t.Dimension)
+	}
+	return (value*f.Factor + f.Offset - t.Offset) / t.Factor, nil
+}
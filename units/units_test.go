@@ -0,0 +1,110 @@
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		// want is the name of the unit found; empty means none
+		want string
+	}{
+		{name: "symbol", text: "km", want: "kilometer"},
+		{name: "name", text: "mile", want: "mile"},
+		{name: "plural", text: "miles", want: "mile"},
+		{name: "british spelling", text: "litres", want: "liter"},
+		{name: "uppercase", text: "KG", want: "kilogram"},
+		{name: "surrounding space", text: "  lbs ", want: "pound"},
+		{name: "degree symbol", text: "°F", want: "degree Fahrenheit"},
+		{name: "bare letter", text: "c", want: "degree Celsius"},
+		{name: "multi-word", text: "fluid ounces", want: "fluid ounce"},
+		{name: "superscript", text: "m²", want: "square meter"},
+		{name: "quote for inches", text: `"`, want: "inch"},
+		{name: "data symbol", text: "MB", want: "megabyte"},
+		{name: "lowercase data symbol", text: "mb", want: "megabyte"},
+		{name: "binary data symbol", text: "GiB", want: "gibibyte"},
+
+		{name: "bit or byte", text: "b"},
+		{name: "unknown", text: "furlong"},
+		{name: "empty", text: ""},
+		{name: "currency", text: "usd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, ok := Lookup(tt.text)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("Lookup(%q) = %s, want no unit", tt.text, u.Name)
+				}
+				return
+			}
+			if !ok || u.Name != tt.want {
+				t.Errorf("Lookup(%q) = %s, %t, want %s", tt.text, u.Name, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{name: "length", value: 5, from: "km", to: "miles", want: 3.106855961},
+		{name: "same unit", value: 42, from: "kg", to: "kilograms", want: 42},
+		{name: "mass", value: 1, from: "lb", to: "g", want: 453.59237},
+		{name: "volume", value: 1, from: "gallon", to: "l", want: 3.785411784},
+		{name: "area", value: 1, from: "acre", to: "m2", want: 4046.8564224},
+		{name: "speed", value: 100, from: "km/h", to: "mph", want: 62.137119224},
+		{name: "time", value: 1.5, from: "hours", to: "min", want: 90},
+		{name: "decimal data", value: 1, from: "GB", to: "MB", want: 1000},
+		{name: "binary data", value: 1, from: "GiB", to: "MiB", want: 1024},
+		{name: "celsius to fahrenheit", value: 100, from: "c", to: "f", want: 212},
+		{name: "fahrenheit to celsius", value: -40, from: "°F", to: "°C", want: -40},
+		{name: "celsius to kelvin", value: 0, from: "celsius", to: "K", want: 273.15},
+		{name: "zero", value: 0, from: "ft", to: "m", want: 0},
+		{name: "negative", value: -3, from: "ft", to: "in", want: -36},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(tt.value, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("Convert(%v, %q, %q): %v", tt.value, tt.from, tt.to, err)
+			}
+			if math.Abs(got-tt.want) > 1e-6*math.Max(1, math.Abs(tt.want)) {
+				t.Errorf("Convert(%v, %q, %q) = %v, want %v", tt.value, tt.from, tt.to, got, tt.want)
+			}
+
+			// Converting back rounds to the value again
+			back, err := Convert(got, tt.to, tt.from)
+			if err != nil || math.Abs(back-tt.value) > 1e-9*math.Max(1, math.Abs(tt.value)) {
+				t.Errorf("Convert(%v, %q, %q) = %v, %v, want %v", got, tt.to, tt.from, back, err, tt.value)
+			}
+		})
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+	}{
+		{name: "unknown from", from: "furlong", to: "m"},
+		{name: "unknown to", from: "m", to: "furlong"},
+		{name: "bit", from: "b", to: "KB"},
+		{name: "different dimensions", from: "kg", to: "km"},
+		{name: "temperature to time", from: "c", to: "s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Convert(1, tt.from, tt.to); err == nil {
+				t.Errorf("Convert(1, %q, %q) = %v, want an error", tt.from, tt.to, got)
+			}
+		})
+	}
+}
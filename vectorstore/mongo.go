@@ -0,0 +1,179 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	mongoCollection  = "message_embeddings"
+	mongoSearchIndex = "message_vectors"
+	// mongoScanLimit caps the vectors compared in process when Atlas Vector Search isn't available
+	mongoScanLimit = 20000
+	// mongoRetryVectorSearch is how long queries scan after Atlas Vector Search failed before trying it again
+	mongoRetryVectorSearch = 10 * time.Minute
+)
+
+// Mongo stores vectors in the message_embeddings collection and queries them with Atlas Vector Search.
+// On MongoDB servers without it, queries compare the chat's most recent vectors in process instead.
+type Mongo struct {
+	coll    *mongo.Collection
+	indexed atomic.Bool
+	// scanUntil is the Unix time until which queries skip Atlas Vector Search after it failed
+	scanUntil atomic.Int64
+}
+
+var _ VectorStore = (*Mongo)(nil)
+
+// NewMongo uses the message_embeddings collection of db
+func NewMongo(db *mongo.Database) *Mongo {
+	return &Mongo{coll: db.Collection(mongoCollection)}
+}
+
+type mongoPoint struct {
+	ID        any       `bson:"_id"`
+	ChatID    int64     `bson:"chat_id"`
+	MessageID int       `bson:"message_id"`
+	Vector    []float32 `bson:"vector"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+func mongoID(id string) any {
+	if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+		return oid
+	}
+	return id
+}
+
+func (m *Mongo) Upsert(ctx context.Context, points []Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+	m.ensureIndexes(ctx, len(points[0].Vector))
+
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(points))
+	for _, p := range points {
+		doc := mongoPoint{ID: mongoID(p.ID), ChatID: p.ChatID, MessageID: p.MessageID, Vector: p.Vector, CreatedAt: now}
+		writes = append(writes, mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": doc.ID}).SetReplacement(doc).SetUpsert(true))
+	}
+	_, err := m.coll.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// ensureIndexes creates the chat index and, on Atlas, the vector search index sized for the first vector seen
+func (m *Mongo) ensureIndexes(ctx context.Context, dimensions int) {
+	if !m.indexed.CompareAndSwap(false, true) {
+		return
+	}
+	_, err := m.coll.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "message_id", Value: -1}}})
+	if err != nil {
+		log.Printf("Error creating index: %v", err)
+	}
+
+	definition := bson.M{"fields": bson.A{
+		bson.M{"type": "vector", "path": "vector", "numDimensions": dimensions, "similarity": "cosine"},
+		bson.M{"type": "filter", "path": "chat_id"},
+	}}
+	opts := options.SearchIndexes().SetName(mongoSearchIndex).SetType("vectorSearch")
+	if _, err := m.coll.SearchIndexes().CreateOne(ctx, mongo.SearchIndexModel{Definition: definition, Options: opts}); err != nil {
+		// Expected on self-hosted MongoDB and when the index already exists
+		log.Printf("Atlas vector search index not created, %v", err)
+	}
+}
+
+func (m *Mongo) Query(ctx context.Context, chatID int64, vector []float32, limit int) ([]Match, error) {
+	if time.Now().Unix() >= m.scanUntil.Load() {
+		matches, err := m.vectorSearch(ctx, chatID, vector, limit)
+		if err == nil {
+			return matches, nil
+		}
+		log.Printf("Atlas vector search failed, comparing vectors in process for a while: %v", err)
+		m.scanUntil.Store(time.Now().Add(mongoRetryVectorSearch).Unix())
+	}
+	return m.scan(ctx, chatID, vector, limit)
+}
+
+func (m *Mongo) vectorSearch(ctx context.Context, chatID int64, vector []float32, limit int) ([]Match, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$vectorSearch", Value: bson.M{
+			"index":         mongoSearchIndex,
+			"path":          "vector",
+			"queryVector":   vector,
+			"numCandidates": limit * 20,
+			"limit":         limit,
+			"filter":        bson.M{"chat_id": chatID},
+		}}},
+		{{Key: "$project", Value: bson.M{"chat_id": 1, "message_id": 1, "score": bson.M{"$meta": "vectorSearchScore"}}}},
+	}
+	cursor, err := m.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var results []struct {
+		ChatID    int64   `bson:"chat_id"`
+		MessageID int     `bson:"message_id"`
+		Score     float64 `bson:"score"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	matches := make([]Match, len(results))
+	for i, r := range results {
+		matches[i] = Match{ChatID: r.ChatID, MessageID: r.MessageID, Score: r.Score}
+	}
+	return matches, nil
+}
+
+// scan compares vector with the chat's most recent mongoScanLimit vectors
+func (m *Mongo) scan(ctx context.Context, chatID int64, vector []float32, limit int) ([]Match, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "message_id", Value: -1}}).SetLimit(mongoScanLimit)
+	cursor, err := m.coll.Find(ctx, bson.M{"chat_id": chatID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var matches []Match
+	for cursor.Next(ctx) {
+		var p mongoPoint
+		if err := cursor.Decode(&p); err != nil {
+			return nil, err
+		}
+		matches = append(matches, Match{ChatID: p.ChatID, MessageID: p.MessageID, Score: Cosine(vector, p.Vector)})
+	}
+	return topMatches(matches, limit), cursor.Err()
+}
+
+func (m *Mongo) Fetch(ctx context.Context, chatID int64, messageIDs []int) ([]Point, error) {
+	cursor, err := m.coll.Find(ctx, bson.M{"chat_id": chatID, "message_id": bson.M{"$in": messageIDs}})
+	if err != nil {
+		return nil, err
+	}
+	var docs []mongoPoint
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	points := make([]Point, len(docs))
+	for i, d := range docs {
+		id := fmt.Sprint(d.ID)
+		if oid, ok := d.ID.(primitive.ObjectID); ok {
+			id = oid.Hex()
+		}
+		points[i] = Point{ID: id, ChatID: d.ChatID, MessageID: d.MessageID, Vector: d.Vector}
+	}
+	return points, nil
+}
+
+func (m *Mongo) DeleteChat(ctx context.Context, chatID int64) error {
+	_, err := m.coll.DeleteMany(ctx, bson.M{"chat_id": chatID})
+	return err
+}
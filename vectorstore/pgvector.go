@@ -0,0 +1,167 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// pgvectorSchema creates the table on first use; the vector column has no fixed size, so any embedding model fits
+const pgvectorSchema = `CREATE EXTENSION IF NOT EXISTS vector;
+CREATE TABLE IF NOT EXISTS message_vectors (
+	id text PRIMARY KEY,
+	chat_id bigint NOT NULL,
+	message_id integer NOT NULL,
+	embedding vector NOT NULL
+);
+CREATE INDEX IF NOT EXISTS message_vectors_chat_id ON message_vectors (chat_id)`
+
+// Postgres stores vectors in PostgreSQL with the pgvector extension, over one connection it reopens after failures
+type Postgres struct {
+	URL string
+
+	mu     sync.Mutex
+	conn   *pgConn
+	schema bool
+}
+
+var _ VectorStore = (*Postgres)(nil)
+
+// exec runs sql on the shared connection, connecting and creating the schema first if needed.
+// The simple query protocol has no cancellation, so ctx's deadline is applied to the socket.
+func (p *Postgres) exec(ctx context.Context, sql string) ([][]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if p.conn == nil {
+		conn, err := dialPostgres(p.URL)
+		if err != nil {
+			return nil, err
+		}
+		p.conn = conn
+	}
+	if !p.schema {
+		if _, err := p.run(ctx, pgvectorSchema); err != nil {
+			return nil, fmt.Errorf("creating pgvector schema: %w", err)
+		}
+		p.schema = true
+	}
+	return p.run(ctx, sql)
+}
+
+func (p *Postgres) run(ctx context.Context, sql string) ([][]string, error) {
+	deadline, _ := ctx.Deadline()
+	p.conn.conn.SetDeadline(deadline)
+	rows, err := p.conn.query(sql)
+	var pgErr *pgError
+	if err != nil && !errors.As(err, &pgErr) {
+		p.conn.conn.Close()
+		p.conn = nil
+	}
+	return rows, err
+}
+
+// vectorLiteral formats a vector as pgvector text input, like '[0.1,0.2]'
+func vectorLiteral(v []float32) string {
+	parts := make([]string, len(v))
+	for i, x := range v {
+		parts[i] = strconv.FormatFloat(float64(x), 'g', -1, 32)
+	}
+	return "'[" + strings.Join(parts, ",") + "]'"
+}
+
+func (p *Postgres) Upsert(ctx context.Context, points []Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+	values := make([]string, len(points))
+	for i, pt := range points {
+		values[i] = fmt.Sprintf("(%s, %d, %d, %s)", quoteLiteral(pt.ID), pt.ChatID, pt.MessageID, vectorLiteral(pt.Vector))
+	}
+	_, err := p.exec(ctx, "INSERT INTO message_vectors (id, chat_id, message_id, embedding) VALUES "+strings.Join(values, ", ")+
+		" ON CONFLICT (id) DO UPDATE SET embedding = EXCLUDED.embedding")
+	return err
+}
+
+func (p *Postgres) Query(ctx context.Context, chatID int64, vector []float32, limit int) ([]Match, error) {
+	v := vectorLiteral(vector)
+	rows, err := p.exec(ctx, fmt.Sprintf(
+		"SELECT chat_id, message_id, 1 - (embedding <=> %s) FROM message_vectors WHERE chat_id = %d AND vector_dims(embedding) = %d ORDER BY embedding <=> %s LIMIT %d",
+		v, chatID, len(vector), v, limit))
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]Match, 0, len(rows))
+	for _, row := range rows {
+		if len(row) != 3 {
+			continue
+		}
+		var m Match
+		m.ChatID, _ = strconv.ParseInt(row[0], 10, 64)
+		m.MessageID, _ = strconv.Atoi(row[1])
+		m.Score, _ = strconv.ParseFloat(row[2], 64)
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+func (p *Postgres) Fetch(ctx context.Context, chatID int64, messageIDs []int) ([]Point, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(messageIDs))
+	for i, id := range messageIDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	rows, err := p.exec(ctx, fmt.Sprintf("SELECT id, message_id, embedding FROM message_vectors WHERE chat_id = %d AND message_id IN (%s)",
+		chatID, strings.Join(ids, ", ")))
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]Point, 0, len(rows))
+	for _, row := range rows {
+		if len(row) != 3 {
+			continue
+		}
+		messageID, _ := strconv.Atoi(row[1])
+		points = append(points, Point{ID: row[0], ChatID: chatID, MessageID: messageID, Vector: parseVector(row[2])})
+	}
+	return points, nil
+}
+
+// parseVector reads pgvector text output like "[0.1,0.2]"
+func parseVector(s string) []float32 {
+	fields := strings.Split(strings.Trim(s, "[]"), ",")
+	v := make([]float32, 0, len(fields))
+	for _, f := range fields {
+		x, err := strconv.ParseFloat(strings.TrimSpace(f), 32)
+		if err != nil {
+			return nil
+		}
+		v = append(v, float32(x))
+	}
+	return v
+}
+
+func (p *Postgres) DeleteChat(ctx context.Context, chatID int64) error {
+	_, err := p.exec(ctx, fmt.Sprintf("DELETE FROM message_vectors WHERE chat_id = %d", chatID))
+	return err
+}
+
+// Close closes the connection
+func (p *Postgres) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
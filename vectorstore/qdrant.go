@@ -0,0 +1,186 @@
+package vectorstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Qdrant stores vectors in a Qdrant collection over its REST API, creating the collection on first use
+type Qdrant struct {
+	// URL is the REST endpoint, like "http://localhost:6333"
+	URL        string
+	APIKey     string
+	Collection string
+	Client     *http.Client
+
+	ready atomic.Bool
+}
+
+var _ VectorStore = (*Qdrant)(nil)
+
+func (q *Qdrant) client() *http.Client {
+	if q.Client != nil {
+		return q.Client
+	}
+	return http.DefaultClient
+}
+
+// call sends a JSON request and decodes the "result" of the response into result, if given
+func (q *Qdrant) call(ctx context.Context, method, path string, body, result any) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(q.URL, "/")+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if q.APIKey != "" {
+		req.Header.Set("api-key", q.APIKey)
+	}
+
+	resp, err := q.client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("qdrant %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if result == nil {
+		return resp.StatusCode, nil
+	}
+	envelope := struct {
+		Result any `json:"result"`
+	}{Result: result}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(&envelope)
+}
+
+// ensureCollection creates the collection with cosine distance and a chat_id index if it doesn't exist
+func (q *Qdrant) ensureCollection(ctx context.Context, dimensions int) error {
+	if q.ready.Load() {
+		return nil
+	}
+	path := "/collections/" + q.Collection
+	status, err := q.call(ctx, http.MethodGet, path, nil, nil)
+	if status == http.StatusNotFound {
+		create := map[string]any{"vectors": map[string]any{"size": dimensions, "distance": "Cosine"}}
+		if _, err := q.call(ctx, http.MethodPut, path, create, nil); err != nil {
+			return err
+		}
+		index := map[string]any{"field_name": "chat_id", "field_schema": "integer"}
+		if _, err := q.call(ctx, http.MethodPut, path+"/index?wait=true", index, nil); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	q.ready.Store(true)
+	return nil
+}
+
+// pointID turns a message ID into the UUID Qdrant requires as point ID
+func pointID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	h := hex.EncodeToString(sum[:16])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func chatFilter(chatID int64) map[string]any {
+	return map[string]any{"must": []any{map[string]any{"key": "chat_id", "match": map[string]any{"value": chatID}}}}
+}
+
+func (q *Qdrant) Upsert(ctx context.Context, points []Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+	if err := q.ensureCollection(ctx, len(points[0].Vector)); err != nil {
+		return err
+	}
+
+	body := make([]map[string]any, len(points))
+	for i, p := range points {
+		body[i] = map[string]any{
+			"id":      pointID(p.ID),
+			"vector":  p.Vector,
+			"payload": map[string]any{"chat_id": p.ChatID, "message_id": p.MessageID, "message": p.ID},
+		}
+	}
+	_, err := q.call(ctx, http.MethodPut, "/collections/"+q.Collection+"/points?wait=true", map[string]any{"points": body}, nil)
+	return err
+}
+
+func (q *Qdrant) Query(ctx context.Context, chatID int64, vector []float32, limit int) ([]Match, error) {
+	var results []struct {
+		Score   float64 `json:"score"`
+		Payload struct {
+			ChatID    int64 `json:"chat_id"`
+			MessageID int   `json:"message_id"`
+		} `json:"payload"`
+	}
+	search := map[string]any{"vector": vector, "limit": limit, "filter": chatFilter(chatID), "with_payload": true}
+	status, err := q.call(ctx, http.MethodPost, "/collections/"+q.Collection+"/points/search", search, &results)
+	if status == http.StatusNotFound {
+		return nil, nil // Nothing was stored yet
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]Match, len(results))
+	for i, r := range results {
+		matches[i] = Match{ChatID: r.Payload.ChatID, MessageID: r.Payload.MessageID, Score: r.Score}
+	}
+	return matches, nil
+}
+
+func (q *Qdrant) Fetch(ctx context.Context, chatID int64, messageIDs []int) ([]Point, error) {
+	var result struct {
+		Points []struct {
+			Vector  []float32 `json:"vector"`
+			Payload struct {
+				ChatID    int64  `json:"chat_id"`
+				MessageID int    `json:"message_id"`
+				Message   string `json:"message"`
+			} `json:"payload"`
+		} `json:"points"`
+	}
+	filter := chatFilter(chatID)
+	filter["must"] = append(filter["must"].([]any), map[string]any{"key": "message_id", "match": map[string]any{"any": messageIDs}})
+	scroll := map[string]any{"filter": filter, "limit": len(messageIDs), "with_payload": true, "with_vector": true}
+	status, err := q.call(ctx, http.MethodPost, "/collections/"+q.Collection+"/points/scroll", scroll, &result)
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]Point, len(result.Points))
+	for i, p := range result.Points {
+		points[i] = Point{ID: p.Payload.Message, ChatID: p.Payload.ChatID, MessageID: p.Payload.MessageID, Vector: p.Vector}
+	}
+	return points, nil
+}
+
+func (q *Qdrant) DeleteChat(ctx context.Context, chatID int64) error {
+	status, err := q.call(ctx, http.MethodPost, "/collections/"+q.Collection+"/points/delete?wait=true", map[string]any{"filter": chatFilter(chatID)}, nil)
+	if status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
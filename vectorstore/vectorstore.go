@@ -0,0 +1,60 @@
+// Package vectorstore keeps message embeddings for semantic search in MongoDB (Atlas Vector Search),
+// Qdrant or PostgreSQL with pgvector.
+package vectorstore
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// Point is the embedding of one message; ID is the message's MongoDB ObjectID in hex
+type Point struct {
+	ID        string
+	ChatID    int64
+	MessageID int
+	Vector    []float32
+}
+
+// Match is a message found by Query, with its cosine similarity to the query vector
+type Match struct {
+	ChatID    int64
+	MessageID int
+	Score     float64
+}
+
+// VectorStore stores embeddings and finds the nearest ones within a chat
+type VectorStore interface {
+	Upsert(ctx context.Context, points []Point) error
+	// Query returns up to limit messages of chatID closest to vector, best first
+	Query(ctx context.Context, chatID int64, vector []float32, limit int) ([]Match, error)
+	// Fetch returns the stored vectors of the given messages of chatID, skipping those without one
+	Fetch(ctx context.Context, chatID int64, messageIDs []int) ([]Point, error)
+	DeleteChat(ctx context.Context, chatID int64) error
+}
+
+// Cosine returns the cosine similarity of two vectors, or 0 if their sizes differ
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// topMatches sorts matches by score and keeps the best limit
+func topMatches(matches []Match, limit int) []Match {
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}